@@ -2,20 +2,46 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	//"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
+	grpcapi "E.E/internal/primary/grpc"
 	"E.E/internal/primary/http"
 	"E.E/internal/primary/http/handlers"
+	"E.E/internal/primary/http/middleware"
+	"E.E/internal/primary/watch"
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
 	"E.E/internal/core/services"
+	"E.E/internal/secondary/chaos"
+	"E.E/internal/secondary/engine"
+	"E.E/internal/secondary/gcs"
+	"E.E/internal/secondary/keystore"
+	"E.E/internal/secondary/oidc"
+	"E.E/internal/secondary/kubernetes"
+	"E.E/internal/secondary/probe"
+	"E.E/internal/secondary/replication"
 	"E.E/internal/secondary/repository"
-	//"E.E/internal/secondary/s3"
-	//"E.E/pkg/metrics"
+	"E.E/internal/secondary/s3"
+	"E.E/internal/secondary/source"
+	"E.E/internal/secondary/sqs"
+	"E.E/internal/secondary/storage"
+	"E.E/internal/secondary/transcode"
+	"E.E/pkg/config"
+	"E.E/pkg/httpclient"
+	"E.E/pkg/metrics"
+	"E.E/pkg/systemd"
 )
 
 func main() {
@@ -23,28 +49,77 @@ func main() {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
+	// Load configuration from defaults, config file, environment and flags
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	if cfg.Service.PIDFile != "" {
+		if err := systemd.WritePIDFile(cfg.Service.PIDFile); err != nil {
+			logger.Fatal("Failed to write PID file", zap.Error(err))
+		}
+		defer systemd.RemovePIDFile(cfg.Service.PIDFile)
+	}
+
 	// Initialize metrics
-	// metricsClient := metrics.NewMetrics("encryption_service")
+	metricsClient := metrics.NewMetrics("encryption_service")
 
 	// Create working directory
-	workDir := "./tmp/storage"
+	workDir := cfg.Storage.WorkDir
 	if err := os.MkdirAll(workDir, 0755); err != nil {
 		logger.Fatal("Failed to create working directory", zap.Error(err))
 	}
 
-	// Initialize storage components
-	// fileStorage, err := storage.NewFileStorage(workDir, logger)
-	// if err != nil {
-	// 	logger.Fatal("Failed to initialize file storage", zap.Error(err))
-	// }
+	// Workers keep files there while jobs run, such as sources being
+	// transcoded
+	scratchDir := cfg.Storage.ScratchDir
+	if scratchDir == "" {
+		scratchDir = filepath.Join(workDir, "scratch")
+	}
+	if err := os.MkdirAll(scratchDir, 0755); err != nil {
+		logger.Fatal("Failed to create scratch directory", zap.Error(err))
+	}
 
-	// s3Client := s3.NewS3Client(logger)
+	s3Client, err := s3.NewS3Client(context.Background(), s3.Config{
+		Region:    cfg.S3.Region,
+		Endpoint:  cfg.S3.Endpoint,
+		PathStyle: cfg.S3.PathStyle,
+		Credentials: s3.Credentials{
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			SessionToken:    cfg.S3.SessionToken,
+		},
+		MaxAttempts: cfg.S3.MaxAttempts,
+		PartSize:    cfg.S3.PartSize,
+		Concurrency: cfg.S3.UploadConcurrency,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize S3 client", zap.Error(err))
+	}
+
+	localStorage, err := storage.NewLocalStorage(workDir)
+	if err != nil {
+		logger.Fatal("Failed to initialize local storage", zap.Error(err))
+	}
+	localStorage.SetMinFree(cfg.Storage.MinFreeBytes)
 
 	// Initialize Redis repositories
 	redisConfig := repository.DefaultRedisConfig()
-	if envURL := os.Getenv("REDIS_URL"); envURL != "" {
-		redisConfig.URL = envURL
-	}
+	redisConfig.URL = cfg.Redis.URL
+	redisConfig.Password = cfg.Redis.Password
+	redisConfig.DB = cfg.Redis.DB
+	redisConfig.MaxRetries = cfg.Redis.MaxRetries
+	redisConfig.MinIdleConns = cfg.Redis.MinIdleConns
+	redisConfig.PoolSize = cfg.Redis.PoolSize
+	redisConfig.PoolTimeout = cfg.Redis.PoolTimeout.Duration
+	redisConfig.ConnectTimeout = cfg.Redis.ConnectTimeout.Duration
+	redisConfig.ReadTimeout = cfg.Redis.ReadTimeout.Duration
+	redisConfig.WriteTimeout = cfg.Redis.WriteTimeout.Duration
+	redisConfig.JobTTL = cfg.Redis.JobTTL.Duration
 
 	// Initialize job repository
 	jobRepository, err := repository.NewRedisJobRepository(redisConfig, logger)
@@ -60,65 +135,792 @@ func main() {
 	}
 	defer batchRepository.Close()
 
-	// Initialize encryption service with both repositories
-	encryptionService := services.NewEncryptionService(
-		jobRepository,
-		batchRepository,
-		logger,
-	)
+	runAPI := cfg.Mode != config.ModeWorker
+	runWorkers := cfg.Mode != config.ModeAPI
+	logger.Info("Starting encryption service", zap.String("mode", cfg.Mode))
+
+	// Queue between the API and the encryption workers. The Redis queue lets
+	// API and worker processes run separately
+	var jobQueue ports.JobQueue
+	if cfg.Worker.Queue == config.QueueRedis {
+		redisQueue, err := repository.NewRedisJobQueue(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize Redis job queue", zap.Error(err))
+		}
+		defer redisQueue.Close()
+		jobQueue = redisQueue
+	} else {
+		jobQueue = repository.NewMemoryJobQueue(cfg.Worker.QueueSize)
+	}
+
+	// Workers publish job progress as they store it, for status streams to
+	// follow; with the Redis queue it crosses processes over Redis pub/sub
+	var progressBroker ports.EncryptionProgress
+	if cfg.Worker.Queue == config.QueueRedis {
+		redisProgress, err := repository.NewRedisProgressBroker(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize Redis progress broker", zap.Error(err))
+		}
+		defer redisProgress.Close()
+		progressBroker = redisProgress
+	} else {
+		progressBroker = repository.NewMemoryProgressBroker()
+	}
+
+	// Workers record heartbeats of their running jobs for the stuck job
+	// detector; with the Redis queue any process's workers can be followed
+	var jobHeartbeats ports.JobHeartbeats
+	if cfg.Worker.Queue == config.QueueRedis {
+		redisHeartbeats, err := repository.NewRedisJobHeartbeats(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize Redis job heartbeats", zap.Error(err))
+		}
+		defer redisHeartbeats.Close()
+		jobHeartbeats = redisHeartbeats
+	} else {
+		jobHeartbeats = repository.NewMemoryJobHeartbeats()
+	}
+
+	// Workers lease the jobs they dequeue, so each job runs once even when
+	// several processes take jobs from the Redis queue
+	var jobLocks ports.JobLocks
+	if cfg.Worker.Queue == config.QueueRedis {
+		redisLocks, err := repository.NewRedisJobLocks(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize Redis job locks", zap.Error(err))
+		}
+		defer redisLocks.Close()
+		jobLocks = redisLocks
+	} else {
+		jobLocks = repository.NewMemoryJobLocks()
+	}
+
+	// Outputs are written to the output bucket when one is configured
+	var outputStorage ports.FileStorage = localStorage
+	var outputBucket *s3.Storage
+	if cfg.S3.OutputBucket != "" {
+		outputBucket = s3.NewStorage(s3Client, cfg.S3.OutputBucket, cfg.S3.OutputPrefix)
+		outputStorage = outputBucket
+	}
+	var gcsBucket *gcs.Storage
+	if cfg.GCS.OutputBucket != "" {
+		gcsClient, err := gcs.NewClient(gcs.Config{
+			Endpoint:        cfg.GCS.Endpoint,
+			CredentialsFile: cfg.GCS.CredentialsFile,
+			ChunkSize:       cfg.GCS.ChunkSize,
+			MaxAttempts:     cfg.GCS.MaxAttempts,
+			Timeout:         cfg.GCS.Timeout.Duration,
+		}, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize GCS client", zap.Error(err))
+		}
+		gcsBucket = gcs.NewStorage(gcsClient, cfg.GCS.OutputBucket, cfg.GCS.OutputPrefix)
+		outputStorage = gcsBucket
+	}
+	// Jobs may name their own destination for their outputs. Destinations
+	// are opened even when no new job may name one, for the jobs that did.
+	outputDestinations := storage.NewDestinations(s3Client, workDir)
+	outputDestinations.SetMinFree(cfg.Storage.MinFreeBytes)
+	var encryptionEngine ports.EncryptionEngine = engine.NewAEADEngine()
+
+	// Content keys are sealed with KMS or Vault unless they are kept inline
+	contentKeys, keyStoreHealth := newKeyStore(cfg, logger)
+
+	// Chaos mode wraps the adapters to inject faults for resilience testing
+	var injector *chaos.Injector
+	if cfg.Chaos.Enabled {
+		logger.Warn("Chaos mode enabled: faults will be injected",
+			zap.Float64("storage_failure_rate", cfg.Chaos.StorageFailureRate),
+			zap.Float64("redis_timeout_rate", cfg.Chaos.RedisTimeoutRate),
+			zap.Float64("slow_encryption_rate", cfg.Chaos.SlowEncryptionRate))
 
-	// Initialize batch service
-	batchService := services.NewBatchService(
-		encryptionService,
-		jobRepository,
-		batchRepository,
+		injector = chaos.NewInjector(chaos.Config{
+			StorageFailureRate:  cfg.Chaos.StorageFailureRate,
+			RedisTimeoutRate:    cfg.Chaos.RedisTimeoutRate,
+			RedisTimeoutDelay:   cfg.Chaos.RedisTimeoutDelay.Duration,
+			SlowEncryptionRate:  cfg.Chaos.SlowEncryptionRate,
+			SlowEncryptionDelay: cfg.Chaos.SlowEncryptionDelay.Duration,
+			Seed:                cfg.Chaos.Seed,
+		}, metricsClient, logger)
+
+		jobRepository = chaos.NewJobRepository(jobRepository, injector)
+		batchRepository = chaos.NewBatchRepository(batchRepository, injector)
+		jobQueue = chaos.NewJobQueue(jobQueue, injector)
+		outputStorage = chaos.NewFileStorage(outputStorage, injector)
+		encryptionEngine = chaos.NewEncryptionEngine(encryptionEngine, injector)
+	}
+
+	// Replication mirrors every successful write to the replica region
+	var replicator *replication.Replicator
+	if cfg.Replication.Enabled {
+		replicaConfig := redisConfig
+		replicaConfig.URL = cfg.Replication.RedisURL
+		replicaConfig.Password = cfg.Replication.RedisPassword
+		replicaConfig.DB = cfg.Replication.RedisDB
+
+		replicaJobs, err := repository.NewRedisJobRepository(replicaConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize replica job repository", zap.Error(err))
+		}
+		defer replicaJobs.Close()
+		replicaBatches, err := repository.NewRedisBatchRepository(replicaConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize replica batch repository", zap.Error(err))
+		}
+		defer replicaBatches.Close()
+
+		replicator = replication.NewReplicator(replicaJobs, replicaBatches, replication.Config{
+			QueueSize:     cfg.Replication.QueueSize,
+			MaxRetryDelay: cfg.Replication.MaxRetryDelay.Duration,
+		}, metricsClient, logger)
+		replicator.Start()
+
+		jobRepository = replication.NewJobRepository(jobRepository, replicator)
+		batchRepository = replication.NewBatchRepository(batchRepository, replicator)
+		logger.Info("Replicating job records", zap.String("replica", cfg.Replication.RedisURL))
+	}
+	if cfg.Replication.ReadOnly {
+		logger.Warn("Serving read-only: changes through the API are rejected")
+	}
+
+	// Dependency health gates job intake and the systemd watchdog
+	healthMonitor := services.NewHealthMonitor(
+		cfg.Health.CheckInterval.Duration,
+		cfg.Health.CheckTimeout.Duration,
+		cfg.Health.DegradedLatency.Duration,
+		metricsClient,
 		logger,
 	)
+	healthMonitor.AddDependency("redis", jobRepository.HealthCheck)
+	healthMonitor.AddDependency("storage", localStorage.HealthCheck)
+	if outputBucket != nil {
+		healthMonitor.AddDependency("s3", outputBucket.HealthCheck)
+	}
+	if gcsBucket != nil {
+		healthMonitor.AddDependency("gcs", gcsBucket.HealthCheck)
+	}
+	if keyStoreHealth != nil {
+		healthMonitor.AddDependency("key_store", keyStoreHealth)
+	}
+	var tokenVerifier *oidc.Verifier
+	if runAPI {
+		tokenVerifier = newTokenVerifier(cfg, logger)
+	}
+	if tokenVerifier != nil {
+		healthMonitor.AddDependency("jwks", tokenVerifier.HealthCheck)
+	}
+	healthMonitor.Start()
+	defer healthMonitor.Stop()
 
-	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler(logger)
-	encryptionHandler := handlers.NewEncryptionHandler(
-			encryptionService,
-			logger,
+	// Webhooks and source downloads share one pool of outbound connections
+	httpPool := httpclient.NewPool(httpclient.Config{
+		MaxIdleConns:          cfg.HTTPClient.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.HTTPClient.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.HTTPClient.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.HTTPClient.IdleConnTimeout.Duration,
+		DialTimeout:           cfg.HTTPClient.DialTimeout.Duration,
+		KeepAlive:             cfg.HTTPClient.KeepAlive.Duration,
+		TLSHandshakeTimeout:   cfg.HTTPClient.TLSHandshakeTimeout.Duration,
+		ResponseHeaderTimeout: cfg.HTTPClient.ResponseHeaderTimeout.Duration,
+	}, metricsClient)
+	defer httpPool.CloseIdleConnections()
+
+	fetcher, err := source.NewFetcher(workDir, s3Client, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize source fetcher", zap.Error(err))
+	}
+	fetcher.SetHTTPClient(httpPool.Client("download", cfg.HTTPClient.DownloadTimeout.Duration))
+
+	var sourceFetcher ports.SourceFetcher = fetcher
+	if injector != nil {
+		sourceFetcher = chaos.NewSourceFetcher(sourceFetcher, injector)
+	}
+
+	// Sources are probed before encryption when enabled
+	var mediaProber ports.MediaProber
+	mediaPolicy := domain.MediaPolicy{
+		Containers:  cfg.Media.AllowedContainers,
+		VideoCodecs: cfg.Media.AllowedVideoCodecs,
+	}
+	if cfg.Media.Probe {
+		mediaProber, err = probe.NewFFProbe(cfg.Media.FFProbePath, sourceFetcher, cfg.Media.ProbeTimeout.Duration, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize media prober", zap.Error(err))
+		}
+	}
+
+	// Job events wait in a queue until the webhook dispatchers deliver them,
+	// so slow receivers never delay recording a job's outcome
+	var (
+		eventQueue     ports.EventQueue
+		webhookService *services.WebhookService
 	)
-	batchHandler := handlers.NewBatchHandler(
-		batchService,
-		logger,
+	if endpoints, _ := cfg.Webhooks.Parse(); len(endpoints) > 0 || cfg.Webhooks.Registration {
+		if cfg.Worker.Queue == config.QueueRedis {
+			redisEvents, err := repository.NewRedisEventQueue(redisConfig, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize Redis event queue", zap.Error(err))
+			}
+			defer redisEvents.Close()
+			eventQueue = redisEvents
+		} else {
+			eventQueue = repository.NewMemoryEventQueue(cfg.Webhooks.QueueSize)
+		}
+
+		webhookService = services.NewWebhookService(logger)
+		webhookService.SetHTTPClient(httpPool.Client("webhook", cfg.HTTPClient.WebhookTimeout.Duration))
+		for _, endpoint := range endpoints {
+			events := make([]domain.WebhookEvent, len(endpoint.Events))
+			for i, event := range endpoint.Events {
+				events[i] = domain.WebhookEvent(event)
+			}
+			if err := webhookService.RegisterWebhook(domain.WebhookConfig{
+				URL:        endpoint.URL,
+				Secret:     endpoint.Secret,
+				EventTypes: events,
+			}); err != nil {
+				logger.Fatal("Invalid webhook endpoint", zap.String("url", endpoint.URL), zap.Error(err))
+			}
+		}
+		// Deliveries that failed for good, and tenants' own webhooks, are
+		// kept in Redis, where every dispatcher looks them up
+		webhookRepository, err := repository.NewRedisWebhookRepository(redisConfig,
+			cfg.Webhooks.DeadLetters, cfg.Webhooks.DeadLetterRetention.Duration, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize webhook repository", zap.Error(err))
+		}
+		defer webhookRepository.Close()
+		webhookService.SetDeadLetters(webhookRepository)
+		if cfg.Webhooks.Registration {
+			webhookService.SetRepository(webhookRepository)
+		}
+		// A single-job worker only queues its events; the long-running
+		// instances deliver them
+		if cfg.Worker.JobID == "" {
+			webhookService.StartDispatch(eventQueue, services.DispatchConfig{
+				Workers:       cfg.Webhooks.Workers,
+				MaxAttempts:   cfg.Webhooks.MaxAttempts,
+				RetryDelay:    cfg.Webhooks.RetryDelay.Duration,
+				MaxRetryDelay: cfg.Webhooks.MaxRetryDelay.Duration,
+			})
+		}
+	}
+
+	// Initialize encryption workers. With the kubernetes backend the jobs run
+	// in launched Kubernetes Jobs, each of which starts this service with
+	// worker.job_id set to encrypt its one job in process.
+	var (
+		workerPool       *services.WorkerPool
+		stuckJobs        *services.StuckJobDetector
+		workspaceJanitor *services.WorkspaceJanitor
+		jobScheduler     *services.JobScheduler
+		taskDispatcher   *services.TaskDispatcher
+		metricsPusher    *metrics.Pusher
 	)
+	if runWorkers && cfg.Worker.Backend == config.WorkerKubernetes && cfg.Worker.JobID == "" {
+		taskDispatcher = newTaskDispatcher(cfg, jobRepository, jobQueue, logger)
+		if eventQueue != nil {
+			taskDispatcher.SetEventQueue(eventQueue)
+		}
+		taskDispatcher.Start()
+	} else if runWorkers {
+		workerPool = services.NewWorkerPool(
+			jobRepository,
+			jobQueue,
+			encryptionEngine,
+			sourceFetcher,
+			outputStorage,
+			services.WorkerConfig{
+				Concurrency:      cfg.Worker.Concurrency,
+				OutputPrefix:     "outputs",
+				ProgressInterval: cfg.Worker.ProgressInterval.Duration,
+				CheckpointSize:   cfg.Worker.CheckpointBytes,
+			},
+			logger,
+		)
+		if mediaProber != nil {
+			workerPool.SetMediaProber(mediaProber, mediaPolicy)
+		}
+		// The watermarker runs ffmpeg as the transcoder does
+		if cfg.Media.Transcode || cfg.Media.Watermark {
+			transcodeDir := cfg.Media.TranscodeDir
+			if transcodeDir == "" {
+				transcodeDir = scratchDir
+			}
+			transcoder, err := transcode.NewFFmpeg(transcode.Config{
+				Path:       cfg.Media.FFmpegPath,
+				ScratchDir: transcodeDir,
+				MinFree:    cfg.Storage.MinFreeBytes,
+				Timeout:    cfg.Media.TranscodeTimeout.Duration,
+				VideoCodec: cfg.Media.VideoCodec,
+				AudioCodec: cfg.Media.AudioCodec,
+				Preset:     cfg.Media.Preset,
+			}, sourceFetcher, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize transcoder", zap.Error(err))
+			}
+			if cfg.Media.Transcode {
+				workerPool.SetTranscoder(transcoder)
+			}
+			if cfg.Media.Watermark {
+				watermarker, err := transcode.NewWatermarker(transcoder, cfg.Media.WatermarkFont)
+				if err != nil {
+					logger.Fatal("Failed to initialize watermarker", zap.Error(err))
+				}
+				workerPool.AddSourceProcessor(watermarker)
+			}
+		}
+		workspaces, err := storage.NewWorkspaces(filepath.Join(scratchDir, "jobs"), cfg.Storage.WorkspaceMaxBytes)
+		if err != nil {
+			logger.Fatal("Failed to initialize job workspaces", zap.Error(err))
+		}
+		workerPool.SetWorkspaces(workspaces)
+		workerPool.SetRetryPolicy(domain.RetryPolicy{
+			MaxAttempts: cfg.Worker.RetryMaxAttempts,
+			Backoff:     cfg.Worker.RetryBackoff.Duration,
+			MaxBackoff:  cfg.Worker.RetryMaxBackoff.Duration,
+			Classes:     cfg.Worker.RetryClasses,
+		})
+		if eventQueue != nil {
+			workerPool.SetEventQueue(eventQueue)
+		}
+		workerPool.SetProgress(progressBroker)
+		workerPool.SetMetrics(metricsClient)
+		workerPool.SetKeyStore(contentKeys)
+		// Keys replaced by key rotation jobs are retired in their policies
+		keyPolicies, err := repository.NewRedisKeyStore(redisConfig, cfg.Keys.AuditRetention.Duration, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize key store", zap.Error(err))
+		}
+		defer keyPolicies.Close()
+		workerPool.SetKeyPolicies(keyPolicies, keyPolicies)
+		workerPool.SetHeartbeats(jobHeartbeats, cfg.Worker.HeartbeatInterval.Duration)
+		workerPool.SetLocks(jobLocks, cfg.Worker.LeaseTTL.Duration)
+		workerPool.SetDestinations(outputDestinations)
+		if cfg.Pushgateway.URL != "" {
+			metricsPusher = newMetricsPusher(cfg, logger)
+		}
 
-	// Add Redis health check to the health handler
-	healthHandler.AddCheck("redis", jobRepository.HealthCheck)
-
-	// Initialize HTTP server
-	server := http.NewServer(logger)
-
-	// Setup router configuration
-	routerConfig := http.RouterConfig{
-		EncryptionHandler: encryptionHandler,
-		BatchHandler:      batchHandler,
-		HealthHandler:     healthHandler,
-		Logger:           logger,
-		RateLimit: struct {
-			Enabled    bool
-			Requests   int
-			TimeWindow time.Duration
-		}{
-			Enabled:    true,
-			Requests:   100,
-			TimeWindow: time.Minute,
-		},
+		if cfg.Worker.JobID != "" {
+			// Encrypt the one job and exit. A SIGTERM, e.g. on pod eviction,
+			// returns the job to PENDING so its dispatcher requeues it
+			jobCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			err := workerPool.RunJob(jobCtx, cfg.Worker.JobID)
+			stop()
+			// The metrics of the job are gone once the process exits
+			pushMetrics(metricsPusher, cfg.Server.ShutdownTimeout.Duration, logger)
+			if err != nil {
+				logger.Fatal("Job did not finish", zap.String("job_id", cfg.Worker.JobID), zap.Error(err))
+			}
+			logger.Info("Job finished", zap.String("job_id", cfg.Worker.JobID))
+			flushReplication(replicator, cfg.Server.ShutdownTimeout.Duration, logger)
+			return
+		}
+		// Jobs left behind by workers that died are requeued or failed
+		// before new ones are taken
+		recovery := services.NewJobRecovery(jobRepository, jobQueue, services.RecoveryConfig{
+			Policy:     cfg.Worker.Recovery,
+			StaleAfter: cfg.Worker.StaleAfter.Duration,
+			QueueLost:  cfg.Worker.Queue == config.QueueMemory,
+		}, logger)
+		if eventQueue != nil {
+			recovery.SetEventQueue(eventQueue)
+		}
+		if _, err := recovery.Reconcile(context.Background()); err != nil {
+			logger.Error("Failed to reconcile jobs of lost workers", zap.Error(err))
+		}
+
+		// Workspaces left by jobs that died with an earlier process are
+		// removed before new ones are created
+		workspaceJanitor = services.NewWorkspaceJanitor(workspaces, jobRepository, cfg.Storage.WorkspaceSweepInterval.Duration, logger)
+		workspaceJanitor.SetMetrics(metricsClient)
+		if _, err := workspaceJanitor.Sweep(context.Background()); err != nil {
+			logger.Error("Failed to sweep job workspaces", zap.Error(err))
+		}
+		workspaceJanitor.Start()
+
+		workerPool.Start()
+
+		// Running jobs that stop moving are reported, then requeued or failed
+		if cfg.Worker.SweepInterval.Duration > 0 {
+			stuckJobs = services.NewStuckJobDetector(jobRepository, jobQueue, services.StuckJobConfig{
+				StuckAfter:    cfg.Worker.StuckAfter.Duration,
+				SweepInterval: cfg.Worker.SweepInterval.Duration,
+				Action:        cfg.Worker.StuckAction,
+			}, logger)
+			stuckJobs.SetHeartbeats(jobHeartbeats)
+			stuckJobs.SetMetrics(metricsClient)
+			if eventQueue != nil {
+				stuckJobs.SetEventQueue(eventQueue)
+			}
+			stuckJobs.Start()
+		}
+		if metricsPusher != nil {
+			metricsPusher.Start()
+		}
+	}
+	if runWorkers && cfg.Worker.ScheduleInterval.Duration > 0 {
+		// Scheduled jobs are queued once their start comes
+		jobScheduler = services.NewJobScheduler(jobRepository, jobQueue, cfg.Worker.ScheduleInterval.Duration, logger)
+		jobScheduler.Start()
 	}
 
-	// Setup routes
-	http.SetupRouter(server.Router(), routerConfig)
+	var (
+		encryptionService *services.EncryptionService
+		ingestService     *services.IngestService
+		folderWatcher     *watch.FolderWatcher
+		recurringService  *services.RecurringService
+		resumableUploads  *services.ResumableUploadService
+		server            *http.Server
+		grpcServer        *grpc.Server
+	)
+	if runAPI {
+		// Initialize encryption service with both repositories
+		encryptionService = services.NewEncryptionService(
+			jobRepository,
+			batchRepository,
+			jobQueue,
+			logger,
+		)
 
-	// Start server
-	go func() {
-		logger.Info("Starting server on :8080")
-		if err := server.Start(8080); err != nil {
-			logger.Fatal("Failed to start server", zap.Error(err))
+		limits := engineLimits(cfg.Engine)
+		if err := limits.Validate(); err != nil {
+			logger.Fatal("Invalid engine configuration", zap.Error(err))
 		}
-	}()
+		encryptionService.SetEngineLimits(limits)
+		encryptionService.SetSummaryCacheTTL(cfg.Cache.SummaryTTL.Duration)
+		encryptionService.SetProgress(progressBroker)
+		encryptionService.SetMetrics(metricsClient)
+		encryptionService.SetKeyStore(contentKeys)
+		encryptionService.SetQuotas(quotaPolicy(cfg.Quotas))
+		if eventQueue != nil {
+			encryptionService.SetEventQueue(eventQueue)
+		}
+
+		if cfg.Preflight.Enabled {
+			var inspector ports.SourceInspector
+			if cfg.Preflight.CheckSource {
+				inspector = fetcher
+			}
+			encryptionService.SetSourcePreflight(inspector, domain.SourcePolicy{
+				Schemes:      cfg.Preflight.AllowedSchemes,
+				MaxSize:      cfg.Preflight.MaxSize,
+				ContentTypes: cfg.Preflight.AllowedContentTypes,
+			}, cfg.Preflight.Timeout.Duration)
+		}
+		if cfg.Media.ProbeOnSubmit {
+			encryptionService.SetMediaProber(mediaProber, mediaPolicy)
+		}
+		if cfg.Media.Transcode {
+			encryptionService.EnableTranscoding()
+			profiles, err := transcodeProfiles(cfg.Media)
+			if err != nil {
+				logger.Fatal("Invalid transcode profile", zap.Error(err))
+			}
+			encryptionService.SetTranscodeProfiles(profiles)
+		}
+		if cfg.Media.Watermark {
+			encryptionService.EnableWatermarking()
+		}
+
+		// Batch service shared with the encryption service
+		batchService := encryptionService.Batches()
+
+		// Sources that a start batch can expand from
+		batchService.RegisterSourceLister(services.SourceKindS3, s3Client)
+		batchService.RegisterSourceLister(services.SourceKindLocal, localStorage)
+		batchService.SetOutputStorage(outputStorage)
+		batchService.SetDestinations(outputDestinations)
+		encryptionService.SetOutputStorage(outputStorage)
+		if cfg.Destination.Enabled {
+			encryptionService.SetDestinations(outputDestinations, domain.DestinationPolicy{
+				Buckets: cfg.Destination.Buckets,
+				Local:   cfg.Destination.Local,
+			}, cfg.Destination.CheckWrite, cfg.Destination.Timeout.Duration)
+		}
+
+		// Uploaded sources are kept with the outputs, where workers read them
+		if cfg.Uploads.Enabled {
+			encryptionService.SetUploadStorage(outputStorage, cfg.Uploads.Prefix, cfg.Uploads.MaxSize)
+		}
+
+		// New uploads announced by S3 event notifications become jobs
+		if cfg.Ingest.SQSQueueURL != "" {
+			var dedupe *repository.RedisDedupeStore
+			ingestService, dedupe = newS3IngestService(cfg.Ingest, redisConfig, encryptionService, metricsClient, logger)
+			defer dedupe.Close()
+			ingestService.Start()
+		}
+
+		// Files dropped into the watch folder become jobs too
+		if cfg.Ingest.WatchDir != "" {
+			folderWatcher, err = watch.NewFolderWatcher(cfg.Ingest.WatchDir, encryptionService, watch.Config{
+				Settle:       cfg.Ingest.WatchSettle.Duration,
+				PollInterval: cfg.Ingest.WatchPollInterval.Duration,
+				RetryDelay:   cfg.Ingest.RetryDelay.Duration,
+				Owner:        cfg.Ingest.Owner,
+			}, metricsClient, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize folder watcher", zap.Error(err))
+			}
+			if err := folderWatcher.Start(); err != nil {
+				logger.Fatal("Failed to start folder watcher", zap.Error(err))
+			}
+		}
+
+		// Initialize handlers
+		healthHandler := handlers.NewHealthHandler(healthMonitor, logger)
+		encryptionHandler := handlers.NewEncryptionHandler(
+			encryptionService,
+			logger,
+		)
+		encryptionHandler.SetExpiryWarning(cfg.Redis.ExpiryWarning.Duration)
+		encryptionHandler.SetExportLimit(cfg.Export.MaxJobs)
+		encryptionHandler.SetQuotaHeaders(cfg.Quotas.Enabled())
+		encryptionHandler.SetStatusInterval(cfg.Server.StatusInterval.Duration)
+		encryptionHandler.SetUploadLimits(cfg.Uploads.MaxSize, cfg.Uploads.Timeout.Duration)
+		var uploadRoutes []string
+		if cfg.Uploads.Enabled {
+			uploadRoutes = []string{http.UploadRoute}
+		}
+
+		// Resumable uploads keep their state in Redis, so any api process
+		// takes the next chunk of an upload
+		if cfg.Uploads.Resumable {
+			uploadStore, err := repository.NewRedisUploadStore(redisConfig, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize resumable upload store", zap.Error(err))
+			}
+			defer uploadStore.Close()
+
+			resumableUploads = services.NewResumableUploadService(uploadStore, outputStorage, encryptionService, jobLocks, services.ResumableUploadConfig{
+				Prefix:        cfg.Uploads.Prefix,
+				MaxSize:       cfg.Uploads.MaxSize,
+				Expiry:        cfg.Uploads.Expiry.Duration,
+				SweepInterval: cfg.Uploads.SweepInterval.Duration,
+				LockTTL:       cfg.Uploads.Timeout.Duration,
+			}, logger)
+			if !cfg.Replication.ReadOnly {
+				resumableUploads.Start()
+			}
+			encryptionHandler.SetResumableUploads(resumableUploads)
+			uploadRoutes = append(uploadRoutes, http.ResumableUploadRoute)
+		}
+		batchHandler := handlers.NewBatchHandler(
+			batchService,
+			logger,
+		)
+
+		// Initialize HTTP server
+		server = http.NewServer(logger, http.ServerConfig{
+			ReadTimeout:  cfg.Server.ReadTimeout.Duration,
+			WriteTimeout: cfg.Server.WriteTimeout.Duration,
+			IdleTimeout:  cfg.Server.IdleTimeout.Duration,
+			MaxBodyBytes: cfg.Server.MaxBodyBytes,
+			UploadRoutes: uploadRoutes,
+
+			ReadHeaderTimeout:    cfg.Server.ReadHeaderTimeout.Duration,
+			MaxHeaderBytes:       cfg.Server.MaxHeaderBytes,
+			KeepAlives:           cfg.Server.KeepAlive,
+			MaxConnections:       cfg.Server.MaxConnections,
+			H2C:                  cfg.Server.H2C,
+			MaxConcurrentStreams: uint32(cfg.Server.MaxConcurrentStreams),
+			CORS: middleware.CORSConfig{
+				AllowOrigins:     cfg.CORS.AllowOrigins,
+				AllowMethods:     cfg.CORS.AllowMethods,
+				AllowHeaders:     cfg.CORS.AllowHeaders,
+				ExposeHeaders:    cfg.CORS.ExposeHeaders,
+				AllowCredentials: cfg.CORS.AllowCredentials,
+				MaxAge:           cfg.CORS.MaxAge.Duration,
+			},
+			Metrics: metricsClient,
+		})
+
+		// The Redis limiter shares each client's limit between API processes
+		var rateLimitStore middleware.RateLimitStore
+		if cfg.RateLimit.Enabled && cfg.RateLimit.Backend == config.RateLimitRedis {
+			redisLimiter, err := repository.NewRedisRateLimiter(redisConfig, cfg.RateLimit.Requests, cfg.RateLimit.TimeWindow.Duration, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize Redis rate limiter", zap.Error(err))
+			}
+			defer redisLimiter.Close()
+			rateLimitStore = redisLimiter
+		}
+
+		// Key policies and the key audit trail. Keys retrieved by job owners
+		// are audited whether or not key delivery is enabled
+		keyStore, err := repository.NewRedisKeyStore(redisConfig, cfg.Keys.AuditRetention.Duration, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize key store", zap.Error(err))
+		}
+		defer keyStore.Close()
+		encryptionService.SetKeyAudit(keyStore)
+
+		// Key delivery serves the keys of completed jobs to players and packagers
+		var keyHandler *handlers.KeyHandler
+		if cfg.Keys.Enabled {
+			keyService := services.NewKeyService(jobRepository, keyStore, keyStore, services.KeyServiceConfig{
+				TokenSecret:     []byte(cfg.Keys.TokenSecret),
+				DefaultTokenTTL: cfg.Keys.TokenTTL.Duration,
+				MaxTokenTTL:     cfg.Keys.MaxTokenTTL.Duration,
+			}, metricsClient, logger)
+			keyService.SetKeyStore(contentKeys)
+			keyHandler = handlers.NewKeyHandler(keyService, logger)
+		}
+
+		// Share links hand job results to external partners
+		var shareHandler *handlers.ShareHandler
+		if cfg.Share.Enabled {
+			shareStore, err := repository.NewRedisShareStore(redisConfig, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize share link store", zap.Error(err))
+			}
+			defer shareStore.Close()
+
+			shareService := services.NewShareService(jobRepository, shareStore, outputStorage, services.ShareServiceConfig{
+				Secret:     []byte(cfg.Share.Secret),
+				BaseURL:    cfg.Share.BaseURL,
+				DefaultTTL: cfg.Share.DefaultTTL.Duration,
+				MaxTTL:     cfg.Share.MaxTTL.Duration,
+				PresignTTL: cfg.Share.PresignTTL.Duration,
+			}, logger)
+			shareService.SetKeyStore(contentKeys)
+			shareService.SetDestinations(outputDestinations)
+			shareHandler = handlers.NewShareHandler(shareService, logger)
+		}
+
+		// Callers authenticate with the configured API keys, keys admins
+		// create through the API, or JWTs from the SSO provider
+		var authenticator *services.APIKeyService
+		var apiKeyHandler *handlers.APIKeyHandler
+		if cfg.Auth.Enabled() {
+			apiKeyStore, err := repository.NewRedisAPIKeyStore(redisConfig, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize API key store", zap.Error(err))
+			}
+			defer apiKeyStore.Close()
+
+			authenticator = services.NewAPIKeyService(apiKeyStore, apiKeyPrincipals(cfg.Auth), logger)
+			if tokenVerifier != nil {
+				authenticator.SetTokenVerifier(tokenVerifier)
+			}
+			apiKeyHandler = handlers.NewAPIKeyHandler(authenticator, logger)
+		}
+
+		// Tenants register the webhooks their job events are delivered to
+		var webhookHandler *handlers.WebhookHandler
+		if cfg.Webhooks.Registration {
+			webhookHandler = handlers.NewWebhookHandler(webhookService, logger)
+		}
+
+		// Tenants register batches started on a cron schedule. Every api
+		// process serves them, and the one holding the leader lock starts them
+		recurringRepository, err := repository.NewRedisRecurringRepository(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize recurring batch repository", zap.Error(err))
+		}
+		defer recurringRepository.Close()
+		leaderLocks, err := repository.NewRedisLeaderLocks(redisConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize leader locks", zap.Error(err))
+		}
+		defer leaderLocks.Close()
+
+		recurringService = services.NewRecurringService(recurringRepository, jobRepository, encryptionService, cfg.Recurring.Interval.Duration, logger)
+		recurringService.SetLeaderLocks(leaderLocks)
+		if cfg.Recurring.Interval.Duration > 0 && !cfg.Replication.ReadOnly {
+			recurringService.Start()
+		}
+		recurringHandler := handlers.NewRecurringHandler(recurringService, logger)
+
+		// Admins import jobs migrated from other encryption systems
+		importHandler := handlers.NewImportHandler(services.NewImportService(jobRepository, logger), logger)
+
+		// Jobs followed over WebSockets are each watched once, however many
+		// clients follow them
+		statusHub := handlers.NewStatusHub(encryptionService, cfg.Server.StatusInterval.Duration, logger)
+		statusSocketHandler := handlers.NewStatusSocketHandler(encryptionService, statusHub, logger)
+
+		// Setup router configuration
+		routerConfig := http.RouterConfig{
+			EncryptionHandler: encryptionHandler,
+			BatchHandler:      batchHandler,
+			HealthHandler:     healthHandler,
+			KeyHandler:        keyHandler,
+			ShareHandler:      shareHandler,
+			ImportHandler:     importHandler,
+			StatusSocketHandler: statusSocketHandler,
+			Readiness:         healthMonitor,
+			APIKeyHandler:     apiKeyHandler,
+			WebhookHandler:    webhookHandler,
+			RecurringHandler:  recurringHandler,
+			ReadOnly:          cfg.Replication.ReadOnly,
+			Uploads:           cfg.Uploads.Enabled,
+			ResumableUploads:  cfg.Uploads.Resumable,
+			Logger:            logger,
+			RateLimit: struct {
+				Enabled    bool
+				Requests    int
+				TimeWindow  time.Duration
+				IdleTimeout time.Duration
+				MaxClients  int
+				Store       middleware.RateLimitStore
+			}{
+				Enabled:     cfg.RateLimit.Enabled,
+				Requests:    cfg.RateLimit.Requests,
+				TimeWindow:  cfg.RateLimit.TimeWindow.Duration,
+				IdleTimeout: cfg.RateLimit.IdleTimeout.Duration,
+				MaxClients:  cfg.RateLimit.MaxClients,
+				Store:       rateLimitStore,
+			},
+		}
+		if authenticator != nil {
+			routerConfig.Authenticator = authenticator
+		}
+
+		// Setup routes
+		http.SetupRouter(server.Router(), routerConfig)
+
+		// Start server
+		go func() {
+			logger.Info("Starting server", zap.Int("port", cfg.Server.Port))
+			if err := server.Start(cfg.Server.Port); err != nil {
+				logger.Fatal("Failed to start server", zap.Error(err))
+			}
+		}()
+
+		if cfg.Server.GRPCAddress != "" {
+			grpcConfig := grpcapi.Config{
+				Readiness:      healthMonitor,
+				ReadOnly:       cfg.Replication.ReadOnly,
+				StatusInterval: cfg.Server.StatusInterval.Duration,
+				Logger:         logger,
+			}
+			if authenticator != nil {
+				grpcConfig.Authenticator = authenticator
+			}
+			grpcServer = grpcapi.NewServer(encryptionService, grpcConfig)
+
+			listener, err := net.Listen("tcp", cfg.Server.GRPCAddress)
+			if err != nil {
+				logger.Fatal("Failed to listen for gRPC", zap.String("address", cfg.Server.GRPCAddress), zap.Error(err))
+			}
+			go func() {
+				logger.Info("Starting gRPC server", zap.String("address", cfg.Server.GRPCAddress))
+				if err := grpcServer.Serve(listener); err != nil {
+					logger.Fatal("Failed to start gRPC server", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	// Tell systemd we are up and keep its watchdog fed while healthy
+	if _, err := systemd.Notify(systemd.Ready); err != nil {
+		logger.Warn("Failed to notify systemd", zap.Error(err))
+	}
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go runWatchdog(watchdogCtx, healthMonitor, logger)
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -126,16 +928,430 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	if _, err := systemd.Notify(systemd.Stopping); err != nil {
+		logger.Warn("Failed to notify systemd", zap.Error(err))
+	}
+	stopWatchdog()
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if ingestService != nil {
+		// Finish the notifications in hand before job intake stops, so none
+		// is retried only because the service was shutting down
+		ingestCtx, cancelIngest := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+		defer cancelIngest()
+
+		if err := ingestService.Stop(ingestCtx); err != nil {
+			logger.Warn("Job ingestion did not stop cleanly", zap.Error(err))
+		}
+	}
+	if folderWatcher != nil {
+		// Files still processing are followed again on the next start
+		watchCtx, cancelWatch := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+		defer cancelWatch()
+
+		if err := folderWatcher.Stop(watchCtx); err != nil {
+			logger.Warn("Folder watcher did not stop cleanly", zap.Error(err))
+		}
+	}
+
+	if runAPI {
+		// Stop accepting jobs, then stop the HTTP server. The context is used to
+		// inform the server how long it has to finish the requests it is handling
+		encryptionService.StopAccepting()
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("Server forced to shutdown", zap.Error(err))
+		}
+		stopGRPC(grpcServer, cfg.Server.ShutdownTimeout.Duration, logger)
+	}
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+	if recurringService != nil {
+		recurringService.Stop()
+	}
+	if resumableUploads != nil {
+		resumableUploads.Stop()
+	}
+	if jobScheduler != nil {
+		jobScheduler.Stop()
+	}
+	if stuckJobs != nil {
+		// Draining jobs are not stuck
+		stuckJobs.Stop()
+	}
+	if workspaceJanitor != nil {
+		workspaceJanitor.Stop()
+	}
+	if runWorkers {
+		// Give in-flight encryptions the drain window to finish; jobs still
+		// running afterwards are interrupted and returned to PENDING
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Worker.DrainTimeout.Duration)
+		defer cancelDrain()
+
+		if taskDispatcher != nil {
+			// Launched Jobs keep running and are followed again on the next start
+			if err := taskDispatcher.Shutdown(drainCtx); err != nil {
+				logger.Warn("Task dispatcher did not stop cleanly", zap.Error(err))
+			}
+		} else if err := workerPool.Shutdown(drainCtx); err != nil {
+			logger.Warn("Encryption workers did not drain cleanly", zap.Error(err))
+		}
+		pushMetrics(metricsPusher, cfg.Server.ShutdownTimeout.Duration, logger)
 	}
 
+	if webhookService != nil {
+		// Flush in-flight webhook deliveries before the repositories are
+		// closed; events still queued in Redis wait for the next start
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+		defer cancelFlush()
+
+		if err := webhookService.Flush(flushCtx); err != nil {
+			logger.Warn("Webhook deliveries did not flush", zap.Error(err))
+		}
+	}
+
+	// Mirror the last writes before the repositories are closed
+	flushReplication(replicator, cfg.Server.ShutdownTimeout.Duration, logger)
+
+	// Redis connections are closed by the deferred Close calls once main returns
 	logger.Info("Server exiting")
 }
 
+// stopGRPC lets the gRPC server finish its calls for up to timeout, then
+// closes the ones left, such as WatchJob streams of running jobs
+func stopGRPC(server *grpc.Server, timeout time.Duration, logger *zap.Logger) {
+	if server == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		logger.Warn("gRPC server forced to shutdown")
+		server.Stop()
+	}
+}
+
+// flushReplication waits up to timeout for queued writes to reach the replica
+func flushReplication(replicator *replication.Replicator, timeout time.Duration, logger *zap.Logger) {
+	if replicator == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := replicator.Flush(ctx); err != nil {
+		logger.Warn("Replication did not flush", zap.Error(err))
+	}
+}
+
+// newMetricsPusher returns a pusher of this worker's metrics, grouped by its
+// host name and, for a single-job worker, by the job it runs
+// newKeyStore creates the store content keys are sealed with, and its health
+// check, or returns nil when keys are kept inline
+func newKeyStore(cfg *config.Config, logger *zap.Logger) (ports.KeyStore, func(context.Context) error) {
+	switch cfg.KeyStore.Backend {
+	case config.KeyStoreKMS:
+		region := cfg.KeyStore.KMSRegion
+		if region == "" {
+			region = cfg.S3.Region
+		}
+		store, err := keystore.NewKMS(context.Background(), keystore.KMSConfig{
+			KeyID:    cfg.KeyStore.KMSKeyID,
+			Region:   region,
+			Endpoint: cfg.KeyStore.KMSEndpoint,
+			Timeout:  cfg.KeyStore.Timeout.Duration,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize KMS key store", zap.Error(err))
+		}
+		logger.Info("Sealing content keys with AWS KMS", zap.String("key_id", cfg.KeyStore.KMSKeyID))
+		return store, store.HealthCheck
+
+	case config.KeyStoreVault:
+		store, err := keystore.NewVault(keystore.VaultConfig{
+			Address:   cfg.KeyStore.VaultAddress,
+			Token:     cfg.KeyStore.VaultToken,
+			Namespace: cfg.KeyStore.VaultNamespace,
+			Mount:     cfg.KeyStore.VaultMount,
+			KeyName:   cfg.KeyStore.VaultKey,
+			Timeout:   cfg.KeyStore.Timeout.Duration,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize Vault key store", zap.Error(err))
+		}
+		logger.Info("Sealing content keys with Vault",
+			zap.String("address", cfg.KeyStore.VaultAddress),
+			zap.String("key", cfg.KeyStore.VaultKey))
+		return store, store.HealthCheck
+
+	default:
+		return nil, nil
+	}
+}
+
+// newTokenVerifier creates the verifier of JWTs from the SSO provider, or
+// returns nil when JWTs are not accepted
+func newTokenVerifier(cfg *config.Config, logger *zap.Logger) *oidc.Verifier {
+	if !cfg.Auth.JWT.Enabled {
+		return nil
+	}
+	verifier, err := oidc.NewVerifier(oidc.Config{
+		Issuer:        cfg.Auth.JWT.Issuer,
+		Audience:      cfg.Auth.JWT.Audience,
+		JWKSURL:       cfg.Auth.JWT.JWKSURL,
+		OwnerClaim:    cfg.Auth.JWT.OwnerClaim,
+		TenantClaim:   cfg.Auth.JWT.TenantClaim,
+		ScopesClaim:   cfg.Auth.JWT.ScopesClaim,
+		DefaultScopes: cfg.Auth.JWT.DefaultScopes,
+		Leeway:        cfg.Auth.JWT.Leeway.Duration,
+		Refresh:       cfg.Auth.JWT.JWKSRefresh.Duration,
+		Timeout:       cfg.Auth.JWT.Timeout.Duration,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize JWT verifier", zap.Error(err))
+	}
+	logger.Info("Accepting JWTs", zap.String("issuer", cfg.Auth.JWT.Issuer), zap.String("audience", cfg.Auth.JWT.Audience))
+	return verifier
+}
+
+func newMetricsPusher(cfg *config.Config, logger *zap.Logger) *metrics.Pusher {
+	grouping, err := cfg.Pushgateway.ParseLabels()
+	if err != nil {
+		logger.Fatal("Invalid Pushgateway labels", zap.Error(err))
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		grouping["instance"] = hostname
+	}
+	if cfg.Worker.JobID != "" {
+		grouping["job_id"] = cfg.Worker.JobID
+	}
+
+	return metrics.NewPusher(metrics.PushConfig{
+		URL:      cfg.Pushgateway.URL,
+		Job:      cfg.Pushgateway.Job,
+		Grouping: grouping,
+		Interval: cfg.Pushgateway.Interval.Duration,
+		Timeout:  cfg.Pushgateway.Timeout.Duration,
+	}, func(err error) {
+		logger.Warn("Failed to push metrics", zap.Error(err))
+	})
+}
+
+// pushMetrics stops periodic pushes and pushes the final metrics, waiting up
+// to timeout
+func pushMetrics(pusher *metrics.Pusher, timeout time.Duration, logger *zap.Logger) {
+	if pusher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := pusher.Stop(ctx); err != nil {
+		logger.Warn("Failed to push metrics", zap.Error(err))
+	}
+}
+
+// runWatchdog pings the systemd watchdog at half its timeout, but only while
+// every dependency is healthy, so systemd restarts a service that cannot
+// recover on its own
+func runWatchdog(ctx context.Context, monitor *services.HealthMonitor, logger *zap.Logger) {
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		logger.Warn("Ignoring systemd watchdog settings", zap.Error(err))
+		return
+	}
+	if interval == 0 {
+		return
+	}
+
+	logger.Info("systemd watchdog enabled", zap.Duration("timeout", interval))
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if ready, down := monitor.Ready(); !ready {
+				logger.Warn("Withholding watchdog ping while dependencies are down", zap.Strings("dependencies", down))
+				continue
+			}
+			if _, err := systemd.Notify(systemd.Watchdog); err != nil {
+				logger.Warn("Failed to ping systemd watchdog", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newS3IngestService creates the service turning S3 event notifications read
+// from SQS into jobs, with the Redis store it remembers uploads in; the config
+// was validated when it was loaded
+func newS3IngestService(cfg config.IngestConfig, redisConfig repository.RedisConfig, jobs ports.EncryptionService, metricsClient *metrics.Metrics, logger *zap.Logger) (*services.IngestService, *repository.RedisDedupeStore) {
+	client, err := sqs.NewClient(sqs.Config{
+		QueueURL:    cfg.SQSQueueURL,
+		Endpoint:    cfg.SQSEndpoint,
+		Region:      cfg.SQSRegion,
+		WaitTime:    cfg.SQSWaitTime.Duration,
+		Credentials: sqs.CredentialsFromEnv(),
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize SQS client", zap.Error(err))
+	}
+
+	dedupe, err := repository.NewRedisDedupeStore(redisConfig, "ingest:s3:", logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize ingest dedupe store", zap.Error(err))
+	}
+
+	buckets, _ := cfg.ParseBuckets()
+	rules := make([]domain.IngestRule, len(buckets))
+	for i, b := range buckets {
+		rules[i] = domain.IngestRule{Location: b.Bucket, Prefix: b.Prefix}
+	}
+
+	service := services.NewIngestService(jobs, sqs.NewS3EventSource(client, logger), dedupe, services.IngestConfig{
+		Source:     "s3",
+		Rules:      rules,
+		Owner:      cfg.Owner,
+		DedupeTTL:  cfg.DedupeTTL.Duration,
+		RetryDelay: cfg.RetryDelay.Duration,
+	}, metricsClient, logger)
+	return service, dedupe
+}
+
+// transcodeProfiles returns the configured transcode profiles, checked as
+// the transcode parameters of a request are; their format was validated when
+// the config was loaded
+func transcodeProfiles(media config.MediaConfig) (domain.TranscodeProfiles, error) {
+	parsed, _ := media.ParseTranscodeProfiles()
+	profiles := make(domain.TranscodeProfiles, len(parsed))
+	for _, profile := range parsed {
+		params := domain.TranscodeParams{
+			Format:         profile.Format,
+			SegmentSeconds: profile.SegmentSeconds,
+			VideoCodec:     profile.VideoCodec,
+			AudioCodec:     profile.AudioCodec,
+		}
+		for _, r := range profile.Renditions {
+			params.Renditions = append(params.Renditions, domain.Rendition{
+				Name:         r.Name,
+				Height:       r.Height,
+				VideoBitrate: r.VideoBitrate,
+				AudioBitrate: r.AudioBitrate,
+			})
+		}
+		if err := params.Validate(); err != nil {
+			return nil, fmt.Errorf("media.transcode_profiles %q: %w", profile.Name, err)
+		}
+		profiles[profile.Name] = params
+	}
+	return profiles, nil
+}
+
+// quotaPolicy returns the configured tenant quotas; the tenants were
+// validated when the config was loaded
+func quotaPolicy(quotas config.QuotaConfig) domain.QuotaPolicy {
+	tenants, _ := quotas.ParseTenants()
+	policy := domain.QuotaPolicy{
+		Default: domain.Quota{
+			MaxConcurrentJobs: quotas.MaxConcurrentJobs,
+			MaxJobsPerDay:     quotas.MaxJobsPerDay,
+			MaxBytes:          quotas.MaxBytes,
+		},
+		Tenants: make(map[string]domain.Quota, len(tenants)),
+	}
+	for tenant, quota := range tenants {
+		policy.Tenants[tenant] = domain.Quota{
+			MaxConcurrentJobs: quota.MaxConcurrentJobs,
+			MaxJobsPerDay:     quota.MaxJobsPerDay,
+			MaxBytes:          quota.MaxBytes,
+		}
+	}
+	return policy
+}
+
+// apiKeyPrincipals maps each configured API key to the principal it
+// authenticates; the keys were validated when the config was loaded. Keys
+// without a role may read and write jobs.
+func apiKeyPrincipals(auth config.AuthConfig) map[string]domain.Principal {
+	keys, _ := auth.Keys()
+	principals := make(map[string]domain.Principal, len(keys))
+	for _, key := range keys {
+		scopes := []string{domain.ScopeJobsRead, domain.ScopeJobsWrite}
+		switch {
+		case key.Admin:
+			scopes = []string{domain.ScopeAdmin}
+		case key.Keys:
+			scopes = append(scopes, domain.ScopeKeys)
+		}
+		principals[key.Key] = domain.NewPrincipal(key.Owner, "", scopes)
+	}
+	return principals
+}
+
+// engineLimits converts the engine configuration to the bounds jobs are
+// validated against
+func engineLimits(cfg config.EngineConfig) domain.EngineLimits {
+	return domain.EngineLimits{
+		Defaults: domain.EngineParams{
+			Algorithm:  cfg.Algorithm,
+			ChunkSize:  cfg.ChunkSize,
+			IVStrategy: cfg.IVStrategy,
+			Container:  cfg.Container,
+		},
+		Algorithms:   cfg.AllowedAlgorithms,
+		IVStrategies: cfg.AllowedIVStrategies,
+		Containers:   cfg.AllowedContainers,
+		MinChunkSize: cfg.MinChunkSize,
+		MaxChunkSize: cfg.MaxChunkSize,
+	}
+}
+
+// newTaskDispatcher creates the kubernetes worker backend, which launches a
+// Kubernetes Job per queued job; the config was validated when it was loaded
+func newTaskDispatcher(cfg *config.Config, jobs ports.JobRepository, queue ports.JobQueue, logger *zap.Logger) *services.TaskDispatcher {
+	k := cfg.Kubernetes
+	env, _ := k.ParseEnv()
+	parsed, _ := k.ParseEnvFrom()
+	envFrom := make([]kubernetes.EnvSource, len(parsed))
+	for i, source := range parsed {
+		envFrom[i] = kubernetes.EnvSource{Kind: source.Kind, Name: source.Name}
+	}
+
+	launcher, err := kubernetes.NewLauncher(kubernetes.Config{
+		APIServer:       k.APIServer,
+		Namespace:       k.Namespace,
+		TokenFile:       k.TokenFile,
+		CAFile:          k.CAFile,
+		Image:           k.Image,
+		ImagePullPolicy: k.ImagePullPolicy,
+		ServiceAccount:  k.ServiceAccount,
+		Env:             env,
+		EnvFrom:         envFrom,
+		Resources: kubernetes.Resources{
+			CPURequest:    k.CPURequest,
+			CPULimit:      k.CPULimit,
+			MemoryRequest: k.MemoryRequest,
+			MemoryLimit:   k.MemoryLimit,
+		},
+		StorageClaim:     k.StorageClaim,
+		StorageMountPath: cfg.Storage.WorkDir,
+		ActiveDeadline:   k.ActiveDeadline.Duration,
+		TTLAfterFinished: k.TTLAfterFinished.Duration,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kubernetes launcher", zap.Error(err))
+	}
+
+	return services.NewTaskDispatcher(jobs, queue, launcher, services.TaskDispatcherConfig{
+		Concurrency:  cfg.Worker.Concurrency,
+		PollInterval: k.PollInterval.Duration,
+	}, logger)
+}
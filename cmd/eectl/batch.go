@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"E.E/internal/core/domain"
+)
+
+func newBatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run and inspect batch operations",
+	}
+
+	cmd.AddCommand(
+		newBatchRunCommand(),
+		newBatchStatusCommand(),
+	)
+	return cmd
+}
+
+func newBatchRunCommand() *cobra.Command {
+	var (
+		file     string
+		action   string
+		dedupe   bool
+		metadata map[string]string
+		at       string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "run -f FILE",
+		Aliases: []string{"submit"},
+		Short:   "Run a batch described in a file",
+		Long: `Run a batch described in a file.
+
+A .json file holds a request body for POST /encrypt (source_urls, job_ids,
+source, action...). Any other file lists one source URL (or, for non-start
+actions, one job ID) per line; blank lines and lines starting with # are
+skipped.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req, err := readBatchFile(file, domain.BatchAction(action))
+			if err != nil {
+				return err
+			}
+			req.Batch = true
+			if cmd.Flags().Changed("dedupe") {
+				req.Dedupe = dedupe
+			}
+			if len(metadata) > 0 {
+				req.Metadata = metadata
+			}
+			if at != "" {
+				scheduledAt, err := parseTime(at)
+				if err != nil {
+					return err
+				}
+				req.ScheduledAt = &scheduledAt
+			}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			var result domain.BatchResult
+			if err := newAPIClient().do(http.MethodPost, "/encrypt", nil, req, &result); err != nil {
+				return err
+			}
+
+			if wantJSON() {
+				return printJSON(result)
+			}
+			return printBatchResult(&result)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "batch file (.json request or one entry per line)")
+	cmd.Flags().StringVar(&action, "action", string(domain.BatchActionStart), "batch action for line-based files")
+	cmd.Flags().BoolVar(&dedupe, "dedupe", false, "merge duplicate source URLs instead of rejecting the batch")
+	cmd.Flags().StringToStringVar(&metadata, "metadata", nil, "metadata to attach to started jobs, as key=value")
+	cmd.Flags().StringVar(&at, "at", "", "queue started jobs at this time (RFC 3339) or after this delay (e.g. 2h)")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func newBatchStatusCommand() *cobra.Command {
+	var csv bool
+
+	cmd := &cobra.Command{
+		Use:   "status BATCH_ID",
+		Short: "Show the result of a batch operation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient()
+			path := "/batch/" + url.PathEscape(args[0])
+
+			if csv {
+				data, err := client.doRaw(http.MethodGet, path, url.Values{"format": {"csv"}}, nil)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+
+			var result domain.BatchResult
+			if err := client.do(http.MethodGet, path, nil, nil, &result); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(result)
+			}
+			return printBatchResult(&result)
+		},
+	}
+
+	cmd.Flags().BoolVar(&csv, "csv", false, "print one CSV row per job")
+	return cmd
+}
+
+// readBatchFile builds a batch request from a JSON request file or a list file
+func readBatchFile(path string, action domain.BatchAction) (*domain.EncryptionRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	req := &domain.EncryptionRequest{Action: action}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, req); err != nil {
+			return nil, fmt.Errorf("failed to parse batch file: %w", err)
+		}
+		if req.Action == "" {
+			req.Action = action
+		}
+		return req, nil
+	}
+
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("batch file %s has no entries", path)
+	}
+
+	if action == domain.BatchActionStart {
+		req.SourceURLs = entries
+	} else {
+		req.JobIDs = entries
+	}
+	return req, nil
+}
+
+func printBatchResult(result *domain.BatchResult) error {
+	fmt.Printf("Batch:    %s\n", result.BatchID)
+	fmt.Printf("Action:   %s\n", result.Action)
+	fmt.Printf("Jobs:     %d total, %d succeeded, %d failed\n",
+		result.Summary.TotalJobs, result.Summary.SuccessCount, result.Summary.FailureCount)
+	for _, dup := range result.Duplicates {
+		fmt.Printf("Merged:   %s (%d occurrences)\n", dup.SourceURL, dup.Occurrences)
+	}
+	fmt.Println()
+
+	rows := make([][]string, 0, len(result.Successful)+len(result.Failed))
+	for _, jobID := range result.Successful {
+		rows = append(rows, []string{jobID, domain.BatchOutcomeSuccess, ""})
+	}
+	for _, failed := range result.Failed {
+		rows = append(rows, []string{failed.JobID, domain.BatchOutcomeFailed, failed.Error})
+	}
+	return printTable([]string{"JOB ID", "OUTCOME", "ERROR"}, rows)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"E.E/internal/core/domain"
+)
+
+// apiClient is a minimal JSON client for the /api/v1 endpoints
+type apiClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newAPIClient() *apiClient {
+	return &apiClient{
+		baseURL:    strings.TrimRight(serverURL, "/") + "/api/v1",
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil)
+func (c *apiClient) do(method, path string, query url.Values, body, out interface{}) error {
+	data, err := c.doRaw(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// doRaw sends a request and returns the raw response body
+func (c *apiClient) doRaw(method, path string, query url.Values, body interface{}) ([]byte, error) {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, apiError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// stream sends a GET request and returns the response body to be read as it
+// arrives. Streams are not bound by the request timeout.
+func (c *apiClient) stream(path string, query url.Values) (io.ReadCloser, error) {
+	req, err := c.newRequest(http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Transport: c.httpClient.Transport}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, apiError(resp.StatusCode, data)
+	}
+	return resp.Body, nil
+}
+
+// upload streams the local file name as the "file" part of a multipart
+// request, after req as its "request" part, and decodes the JSON response
+// into out. Uploads are not bound by the request timeout.
+func (c *apiClient) upload(path string, req interface{}, name string, out interface{}) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeUpload(form, req, file))
+	}()
+	defer body.Close()
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey != "" {
+		httpReq.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := (&http.Client{Transport: c.httpClient.Transport}).Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return apiError(resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// writeUpload writes the parts of an upload to form
+func writeUpload(form *multipart.Writer, req interface{}, file *os.File) error {
+	part, err := form.CreateFormField("request")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(req); err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	part, err = form.CreateFormFile("file", filepath.Base(file.Name()))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file.Name(), err)
+	}
+	return form.Close()
+}
+
+// newRequest builds an API request with the JSON body and API key set
+func (c *apiClient) newRequest(method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	return req, nil
+}
+
+// apiError turns an error response into a readable error
+func apiError(status int, body []byte) error {
+	var errResp domain.BatchErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && len(errResp.Errors) > 0 {
+		msgs := make([]string, 0, len(errResp.Errors))
+		for _, e := range errResp.Errors {
+			msgs = append(msgs, e.Error())
+		}
+		return fmt.Errorf("%s (HTTP %d): %s", errResp.Message, status, strings.Join(msgs, "; "))
+	}
+
+	var simple struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &simple); err == nil && simple.Error != "" {
+		return fmt.Errorf("%s (HTTP %d)", simple.Error, status)
+	}
+
+	return fmt.Errorf("HTTP %d: %s", status, strings.TrimSpace(string(body)))
+}
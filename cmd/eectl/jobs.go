@@ -0,0 +1,988 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"E.E/internal/core/domain"
+)
+
+func newJobCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Submit, inspect, control and list encryption jobs",
+	}
+
+	cmd.AddCommand(
+		newJobSubmitCommand(),
+		newJobStatusCommand(),
+		newJobWatchCommand(),
+		newJobListCommand(),
+		newJobExportCommand(),
+		newJobUpdateCommand(),
+		newJobExtendCommand(),
+		newJobRescheduleCommand(),
+		newJobUnscheduleCommand(),
+		newJobResultCommand(),
+		newJobVerifyCommand(),
+		newJobKeyCommand(),
+		newJobRotateKeyCommand(),
+		newJobActionCommand("pause", "Pause a running job"),
+		newJobActionCommand("resume", "Resume a paused job"),
+		newJobActionCommand("stop", "Stop a job, cancelling it"),
+		newJobActionCommand("cancel", "Cancel a job; the same as stop"),
+		newJobRetryCommand(),
+	)
+	return cmd
+}
+
+func newJobSubmitCommand() *cobra.Command {
+	var watch bool
+	var interval time.Duration
+	var metadata map[string]string
+	var engine domain.EngineParams
+	var outputs []string
+	var at string
+	var sourceHash, hashFile string
+	var reuse bool
+	var customerKey domain.CustomerKey
+	var upload bool
+	var destination string
+	var copyTo []string
+	var transcodeProfile string
+	var watermark string
+
+	cmd := &cobra.Command{
+		Use:     "submit SOURCE_URL...",
+		Aliases: []string{"start"},
+		Short:   "Start an encryption job for each source URL, or each local file with --upload",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if hashFile != "" {
+				hash, err := hashLocalFile(hashFile)
+				if err != nil {
+					return err
+				}
+				sourceHash = hash
+			}
+			if sourceHash != "" && len(args) > 1 {
+				return fmt.Errorf("--source-hash and --hash-file describe a single source")
+			}
+			var dest *domain.OutputDestination
+			if destination != "" {
+				parsed, err := domain.ParseDestination(destination)
+				if err != nil {
+					return err
+				}
+				dest = parsed
+			}
+			var copies []domain.OutputDestination
+			for _, c := range copyTo {
+				parsed, err := domain.ParseDestination(c)
+				if err != nil {
+					return err
+				}
+				copies = append(copies, *parsed)
+			}
+
+			client := newAPIClient()
+			responses := make([]domain.EncryptionResponse, 0, len(args))
+
+			for _, sourceURL := range args {
+				var resp domain.EncryptionResponse
+				req := domain.EncryptionRequest{Metadata: metadata, SourceHash: sourceHash, Reuse: reuse, Destination: dest, CopyTo: copies, TranscodeProfile: transcodeProfile}
+				if watermark != "" {
+					req.Watermark = &domain.Watermark{Text: watermark}
+				}
+				if engine != (domain.EngineParams{}) {
+					req.EncryptionOptions = &engine
+				}
+				if customerKey != (domain.CustomerKey{}) {
+					req.CustomerKey = &customerKey
+				}
+				if at != "" {
+					scheduledAt, err := parseTime(at)
+					if err != nil {
+						return err
+					}
+					req.ScheduledAt = &scheduledAt
+				}
+				for _, spec := range outputs {
+					profile, err := parseOutputProfile(spec)
+					if err != nil {
+						return err
+					}
+					req.Outputs = append(req.Outputs, profile)
+				}
+				if upload {
+					if err := req.ValidateUpload(); err != nil {
+						return fmt.Errorf("invalid request for %s: %w", sourceURL, err)
+					}
+					if err := client.upload("/encrypt/upload", req, sourceURL, &resp); err != nil {
+						return fmt.Errorf("failed to upload %s: %w", sourceURL, err)
+					}
+				} else {
+					req.SourceURL = sourceURL
+					if err := req.Validate(); err != nil {
+						return fmt.Errorf("invalid request for %s: %w", sourceURL, err)
+					}
+					if err := client.do(http.MethodPost, "/encrypt", nil, req, &resp); err != nil {
+						return fmt.Errorf("failed to submit %s: %w", sourceURL, err)
+					}
+				}
+				responses = append(responses, resp)
+			}
+
+			if wantJSON() {
+				if err := printJSON(responses); err != nil {
+					return err
+				}
+			} else {
+				rows := make([][]string, 0, len(responses))
+				for i, resp := range responses {
+					status := string(resp.Status)
+					if resp.Reused {
+						status += " (reused)"
+					}
+					rows = append(rows, []string{resp.JobID, status, args[i]})
+				}
+				if err := printTable([]string{"JOB ID", "STATUS", "SOURCE"}, rows); err != nil {
+					return err
+				}
+			}
+
+			if watch {
+				for _, resp := range responses {
+					if err := watchJob(client, resp.JobID, interval); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "follow progress until the jobs finish")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "polling interval when watching")
+	cmd.Flags().StringToStringVar(&metadata, "metadata", nil, "metadata to attach to the jobs, as key=value")
+	cmd.Flags().StringVar(&engine.Algorithm, "algorithm", "", "cipher to encrypt with (default: the server's)")
+	cmd.Flags().IntVar(&engine.ChunkSize, "chunk-size", 0, "plaintext bytes per sealed chunk (default: the server's)")
+	cmd.Flags().StringVar(&engine.IVStrategy, "iv-strategy", "", "nonce strategy, counter or random (default: the server's)")
+	cmd.Flags().IntVar(&engine.KeyLength, "key-length", 0, "key bits, picking an algorithm with keys this long (default: the algorithm's)")
+	cmd.Flags().StringVar(&engine.Container, "container", "", "output container, stream or base64 (default: the server's)")
+	cmd.Flags().StringArrayVar(&outputs, "profile", nil, "produce an output as NAME[:ALGORITHM[:CHUNK_SIZE[:IV_STRATEGY]]]; repeat for several outputs")
+	cmd.Flags().StringVar(&at, "at", "", "queue the jobs at this time (RFC 3339, e.g. 2024-05-01T02:00:00Z) or after this delay (e.g. 2h) instead of at once")
+	cmd.Flags().StringVar(&sourceHash, "source-hash", "", "digest of the source content, as sha256:<hex>")
+	cmd.Flags().StringVar(&hashFile, "hash-file", "", "compute --source-hash from a local copy of the source")
+	cmd.Flags().BoolVar(&reuse, "reuse", false, "return a completed job with the same source hash and parameters instead of encrypting again")
+	cmd.Flags().StringVar(&customerKey.WrappedKey, "wrapped-key", "", "encrypt with your own key, given as base64 KMS ciphertext")
+	cmd.Flags().StringVar(&customerKey.KMSKeyID, "kms-key-id", "", "seal the generated key under your own KMS key (ID, ARN or alias)")
+	cmd.Flags().BoolVar(&upload, "upload", false, "upload the arguments as local files instead of passing them as source URLs")
+	cmd.Flags().StringVar(&destination, "destination", "", "write the outputs to s3://bucket/prefix or local:directory instead of the output storage")
+	cmd.Flags().StringArrayVar(&copyTo, "copy-to", nil, "also copy the outputs to s3://bucket/prefix or local:directory; repeat for several destinations")
+	cmd.Flags().StringVar(&transcodeProfile, "transcode-profile", "", "transcode the source with this operator profile before it is encrypted")
+	cmd.Flags().StringVar(&watermark, "watermark", "", "overlay this text on the source's video before it is encrypted; {job_id} becomes the job's ID")
+	return cmd
+}
+
+// hashLocalFile computes the source hash of a local file
+func hashLocalFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", name, err)
+	}
+	return domain.SourceHash(digest.Sum(nil)), nil
+}
+
+// parseTime parses an RFC 3339 time, or a delay from now such as 2h
+func parseTime(value string) (time.Time, error) {
+	if delay, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(delay), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected RFC 3339 or a delay such as 2h", value)
+	}
+	return at, nil
+}
+
+// parseOutputProfile parses NAME[:ALGORITHM[:CHUNK_SIZE[:IV_STRATEGY]]];
+// empty fields use the server's defaults
+func parseOutputProfile(spec string) (domain.OutputProfile, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 4 {
+		return domain.OutputProfile{}, fmt.Errorf("invalid output %q: expected NAME[:ALGORITHM[:CHUNK_SIZE[:IV_STRATEGY]]]", spec)
+	}
+	profile := domain.OutputProfile{Name: parts[0]}
+	var engine domain.EngineParams
+	if len(parts) > 1 {
+		engine.Algorithm = parts[1]
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		size, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return domain.OutputProfile{}, fmt.Errorf("invalid chunk size in output %q: %w", spec, err)
+		}
+		engine.ChunkSize = size
+	}
+	if len(parts) > 3 {
+		engine.IVStrategy = parts[3]
+	}
+	if engine != (domain.EngineParams{}) {
+		profile.Engine = &engine
+	}
+	return profile, nil
+}
+
+func newJobStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status JOB_ID",
+		Short: "Show the current state of a job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			job, err := getJob(newAPIClient(), args[0])
+			if err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(job)
+			}
+			if err := printJobs([]domain.EncryptionJob{*job}); err != nil {
+				return err
+			}
+			if len(job.Outputs) == 0 {
+				return nil
+			}
+			fmt.Println()
+			return printOutputs(job.Outputs)
+		},
+	}
+}
+
+func newJobWatchCommand() *cobra.Command {
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "watch JOB_ID",
+		Short: "Follow a job's progress until it finishes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return watchJob(newAPIClient(), args[0], interval)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "polling interval")
+	return cmd
+}
+
+func newJobListCommand() *cobra.Command {
+	var (
+		status      string
+		sourceURL   string
+		minProgress float64
+		startDate   string
+		endDate     string
+		limit       int
+		offset      int
+		sortBy      []string
+		order       []string
+		metadata    map[string]string
+		createdBy   string
+		sourceHash  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List jobs with optional filters",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			query.Set("limit", strconv.Itoa(limit))
+			query.Set("offset", strconv.Itoa(offset))
+			setIfNotEmpty(query, "status", status)
+			setIfNotEmpty(query, "source_url", sourceURL)
+			setIfNotEmpty(query, "start_date", startDate)
+			setIfNotEmpty(query, "end_date", endDate)
+			setIfNotEmpty(query, "created_by", createdBy)
+			setIfNotEmpty(query, "source_hash", sourceHash)
+			if minProgress > 0 {
+				query.Set("min_progress", strconv.FormatFloat(minProgress, 'f', -1, 64))
+			}
+			for key, value := range metadata {
+				query.Set("metadata."+key, value)
+			}
+			for _, field := range sortBy {
+				query.Add("sort_by", field)
+			}
+			for _, o := range order {
+				query.Add("order", o)
+			}
+
+			var resp struct {
+				Jobs     []domain.EncryptionJob `json:"jobs"`
+				Warnings []domain.ExpiryWarning `json:"warnings"`
+			}
+			if err := newAPIClient().do(http.MethodGet, "/jobs", query, nil, &resp); err != nil {
+				return err
+			}
+			for _, warning := range resp.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", warning.JobID, warning.Message)
+			}
+
+			if wantJSON() {
+				return printJSON(resp.Jobs)
+			}
+			return printJobs(resp.Jobs)
+		},
+	}
+
+	cmd.Flags().StringVar(&status, "status", "", "filter by status (e.g. COMPLETED)")
+	cmd.Flags().StringVar(&sourceURL, "source-url", "", "filter by source URL substring")
+	cmd.Flags().StringVar(&sourceHash, "source-hash", "", "filter by source content digest (sha256:<hex>)")
+	cmd.Flags().Float64Var(&minProgress, "min-progress", 0, "filter by minimum progress")
+	cmd.Flags().StringVar(&startDate, "since", "", "only jobs created at or after this time (unix or RFC3339)")
+	cmd.Flags().StringVar(&endDate, "until", "", "only jobs created at or before this time (unix or RFC3339)")
+	cmd.Flags().IntVar(&limit, "limit", 10, "maximum number of jobs")
+	cmd.Flags().IntVar(&offset, "offset", 0, "number of jobs to skip")
+	cmd.Flags().StringSliceVar(&sortBy, "sort-by", nil, "sort fields, in priority order")
+	cmd.Flags().StringSliceVar(&order, "order", nil, "sort order per field: asc or desc")
+	cmd.Flags().StringToStringVar(&metadata, "metadata", nil, "only jobs with these metadata entries, as key=value")
+	cmd.Flags().StringVar(&createdBy, "created-by", "", "only jobs submitted by this owner")
+	return cmd
+}
+
+func newJobExportCommand() *cobra.Command {
+	var (
+		status    string
+		startDate string
+		endDate   string
+		metadata  map[string]string
+		createdBy string
+		cursor    string
+		limit     int
+		all       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write jobs to stdout as NDJSON, one job per line",
+		Long: "Streams jobs in creation order. The server caps each request; with --all the\n" +
+			"export follows the resume cursor until every job is written, otherwise the\n" +
+			"cursor to resume from is printed to stderr.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			setIfNotEmpty(query, "status", status)
+			setIfNotEmpty(query, "start_date", startDate)
+			setIfNotEmpty(query, "end_date", endDate)
+			setIfNotEmpty(query, "created_by", createdBy)
+			for key, value := range metadata {
+				query.Set("metadata."+key, value)
+			}
+			if limit > 0 {
+				query.Set("limit", strconv.Itoa(limit))
+			}
+
+			client := newAPIClient()
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			for {
+				setIfNotEmpty(query, "cursor", cursor)
+				next, err := exportPage(client, query, out)
+				if err != nil {
+					return err
+				}
+				if next == "" {
+					return nil
+				}
+				if !all {
+					fmt.Fprintf(os.Stderr, "More jobs remain; resume with --cursor %s\n", next)
+					return nil
+				}
+				cursor = next
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&status, "status", "", "filter by status (e.g. COMPLETED)")
+	cmd.Flags().StringVar(&startDate, "since", "", "only jobs created at or after this time (unix or RFC3339)")
+	cmd.Flags().StringVar(&endDate, "until", "", "only jobs created at or before this time (unix or RFC3339)")
+	cmd.Flags().StringToStringVar(&metadata, "metadata", nil, "only jobs with these metadata entries, as key=value")
+	cmd.Flags().StringVar(&createdBy, "created-by", "", "only jobs submitted by this owner")
+	cmd.Flags().StringVar(&cursor, "cursor", "", "resume after the job this cursor points at")
+	cmd.Flags().IntVar(&limit, "limit", 0, "most jobs per request (default and cap set by the server)")
+	cmd.Flags().BoolVar(&all, "all", false, "follow resume cursors until every job is exported")
+	return cmd
+}
+
+// exportPage copies one export response's job lines to out and returns the
+// cursor it ended with, empty when the export is complete
+func exportPage(client *apiClient, query url.Values, out io.Writer) (string, error) {
+	body, err := client.stream("/jobs/export", query)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// last is where to resume if the stream is cut short
+	last := query.Get("cursor")
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry struct {
+			ID         string `json:"id"`
+			CreatedAt  int64  `json:"created_at"`
+			NextCursor string `json:"next_cursor"`
+			Complete   bool   `json:"complete"`
+			Error      string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return "", fmt.Errorf("failed to decode export line: %w", err)
+		}
+		switch {
+		case entry.ID != "":
+			if _, err := out.Write(append(line, '\n')); err != nil {
+				return "", err
+			}
+			last = domain.JobCursor{CreatedAt: entry.CreatedAt, ID: entry.ID}.String()
+		case entry.Error != "":
+			return "", fmt.Errorf("export failed: %s (resume with --cursor %s)", entry.Error, entry.NextCursor)
+		case entry.Complete:
+			return "", nil
+		default:
+			return entry.NextCursor, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read export: %w (resume with --cursor %s)", err, last)
+	}
+	return "", fmt.Errorf("export ended early; resume with --cursor %s", last)
+}
+
+func newJobUpdateCommand() *cobra.Command {
+	var (
+		set   map[string]string
+		unset []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update JOB_ID",
+		Short: "Set or remove metadata entries on a job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(set) == 0 && len(unset) == 0 {
+				return fmt.Errorf("nothing to update: use --set or --unset")
+			}
+
+			req := domain.JobUpdateRequest{Metadata: make(map[string]*string, len(set)+len(unset))}
+			for key, value := range set {
+				value := value
+				req.Metadata[key] = &value
+			}
+			for _, key := range unset {
+				req.Metadata[key] = nil
+			}
+
+			var job domain.EncryptionJob
+			if err := newAPIClient().do(http.MethodPatch, "/job/"+url.PathEscape(args[0]), nil, req, &job); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(job)
+			}
+			return printMetadata(job.Metadata)
+		},
+	}
+
+	cmd.Flags().StringToStringVar(&set, "set", nil, "metadata entries to add or replace, as key=value")
+	cmd.Flags().StringSliceVar(&unset, "unset", nil, "metadata keys to remove")
+	return cmd
+}
+
+func newJobExtendCommand() *cobra.Command {
+	var by time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "extend JOB_ID",
+		Short: "Keep a job's record longer before it expires",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := domain.RetentionRequest{ExtendBy: domain.Duration(by)}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			var job domain.EncryptionJob
+			if err := newAPIClient().do(http.MethodPost, "/job/"+url.PathEscape(args[0])+"/retention", nil, req, &job); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(job)
+			}
+			fmt.Printf("Job %s now expires at %s\n", job.ID, formatUnix(job.ExpiresAt))
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&by, "by", 24*time.Hour, "how much longer to keep the job")
+	return cmd
+}
+
+func newJobRescheduleCommand() *cobra.Command {
+	var at string
+
+	cmd := &cobra.Command{
+		Use:   "reschedule JOB_ID --at TIME",
+		Short: "Move the start of a scheduled job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scheduledAt, err := parseTime(at)
+			if err != nil {
+				return err
+			}
+			req := domain.ScheduleRequest{ScheduledAt: &scheduledAt}
+
+			var job domain.EncryptionJob
+			if err := newAPIClient().do(http.MethodPut, "/job/"+url.PathEscape(args[0])+"/schedule", nil, req, &job); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(job)
+			}
+			fmt.Printf("Job %s now starts at %s\n", job.ID, formatUnix(job.ScheduledAt))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&at, "at", "", "time to queue the job at (RFC 3339) or delay from now (e.g. 2h)")
+	cmd.MarkFlagRequired("at")
+	return cmd
+}
+
+func newJobUnscheduleCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unschedule JOB_ID",
+		Short: "Cancel a scheduled job before its start",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp struct {
+				JobID   string                  `json:"job_id"`
+				Status  domain.EncryptionStatus `json:"status"`
+				Message string                  `json:"message"`
+			}
+			if err := newAPIClient().do(http.MethodDelete, "/job/"+url.PathEscape(args[0])+"/schedule", nil, nil, &resp); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(resp)
+			}
+			fmt.Printf("Job %s unscheduled\n", resp.JobID)
+			return nil
+		},
+	}
+}
+
+func newJobResultCommand() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "result JOB_ID",
+		Short: "Show the output of a completed job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			setIfNotEmpty(query, "output", name)
+			var result domain.JobResult
+			if err := newAPIClient().do(http.MethodGet, "/job/"+url.PathEscape(args[0])+"/result", query, nil, &result); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(result)
+			}
+			rows := [][]string{
+				{"output", result.OutputURL},
+				{"size", formatBytes(result.Size)},
+				{"checksum", result.Checksum},
+				{"source hash", result.SourceHash},
+				{"algorithm", result.Algorithm},
+				{"chunk size", formatBytes(int64(result.ChunkSize))},
+				{"iv strategy", result.IVStrategy},
+				{"key", result.KeyRef},
+			}
+			if result.DRM != "" {
+				rows = append(rows, []string{"drm", result.DRM}, []string{"kid", result.KeyID})
+			}
+			rows = append(rows,
+				[]string{"fetch", result.Timings.Fetch.String()},
+				[]string{"encrypt", result.Timings.Encrypt.String()},
+				[]string{"store", result.Timings.Store.String()},
+				[]string{"total", result.Timings.Total.String()},
+			)
+			return printTable([]string{"FIELD", "VALUE"}, rows)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "show one output of a multi-output job")
+	return cmd
+}
+
+func newJobVerifyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify JOB_ID",
+		Short: "Read a completed job's outputs back and check their checksums",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var verification domain.JobVerification
+			if err := newAPIClient().do(http.MethodPost, "/jobs/"+url.PathEscape(args[0])+"/verify", nil, nil, &verification); err != nil {
+				return err
+			}
+			if wantJSON() {
+				if err := printJSON(verification); err != nil {
+					return err
+				}
+			} else {
+				rows := make([][]string, 0, len(verification.Outputs))
+				for _, output := range verification.Outputs {
+					name := output.Output
+					if name == "" {
+						name = "-"
+					}
+					status := "ok"
+					if !output.Verified {
+						status = output.Error
+					}
+					rows = append(rows, []string{name, output.Checksum, formatBytes(output.Size), status})
+				}
+				if err := printTable([]string{"OUTPUT", "CHECKSUM", "SIZE", "STATUS"}, rows); err != nil {
+					return err
+				}
+			}
+			if !verification.Verified {
+				return fmt.Errorf("job %s failed verification", args[0])
+			}
+			return nil
+		},
+	}
+}
+
+// newJobActionCommand returns a command posting to /job/JOB_ID/<action>
+// for each job ID
+func newJobActionCommand(action, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   action + " JOB_ID...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			type actionResponse struct {
+				JobID   string                  `json:"job_id"`
+				Status  domain.EncryptionStatus `json:"status"`
+				Message string                  `json:"message"`
+			}
+
+			client := newAPIClient()
+			responses := make([]actionResponse, 0, len(args))
+			for _, jobID := range args {
+				var resp actionResponse
+				if err := client.do(http.MethodPost, "/job/"+url.PathEscape(jobID)+"/"+action, nil, nil, &resp); err != nil {
+					return fmt.Errorf("failed to %s %s: %w", action, jobID, err)
+				}
+				responses = append(responses, resp)
+			}
+
+			if wantJSON() {
+				return printJSON(responses)
+			}
+			rows := make([][]string, 0, len(responses))
+			for _, resp := range responses {
+				rows = append(rows, []string{resp.JobID, string(resp.Status)})
+			}
+			return printTable([]string{"JOB ID", "STATUS"}, rows)
+		},
+	}
+}
+
+func newJobRetryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry JOB_ID...",
+		Short: "Run failed jobs again, each as a new job",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient()
+			responses := make([]domain.EncryptionResponse, 0, len(args))
+			for _, jobID := range args {
+				var resp domain.EncryptionResponse
+				if err := client.do(http.MethodPost, "/job/"+url.PathEscape(jobID)+"/retry", nil, nil, &resp); err != nil {
+					return fmt.Errorf("failed to retry %s: %w", jobID, err)
+				}
+				responses = append(responses, resp)
+			}
+
+			if wantJSON() {
+				return printJSON(responses)
+			}
+			rows := make([][]string, 0, len(responses))
+			for _, resp := range responses {
+				rows = append(rows, []string{resp.RetryOf, resp.JobID, string(resp.Status)})
+			}
+			return printTable([]string{"FAILED JOB", "RETRY JOB", "STATUS"}, rows)
+		},
+	}
+}
+
+func newJobKeyCommand() *cobra.Command {
+	var name, wrapKeyFile string
+	var ttl time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "key JOB_ID",
+		Short: "Print the decryption key of a completed job",
+		Long: "Print the decryption key of a completed job. The API key must have the\n" +
+			"keys or admin role. With --wrap-key, the key is encrypted to an RSA public\n" +
+			"key (PEM) and the wrapped key is printed instead.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			setIfNotEmpty(query, "output", name)
+			if wrapKeyFile != "" {
+				publicKey, err := readPublicKey(wrapKeyFile)
+				if err != nil {
+					return err
+				}
+				query.Set("wrap_key", publicKey)
+				if ttl > 0 {
+					query.Set("ttl_seconds", strconv.Itoa(int(ttl.Seconds())))
+				}
+			}
+			// Keys are only handed out by this call, which is audited
+			var key domain.JobKey
+			if err := newAPIClient().do(http.MethodGet, "/jobs/"+url.PathEscape(args[0])+"/key", query, nil, &key); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(key)
+			}
+			if key.WrappedKey != "" {
+				fmt.Println(key.WrappedKey)
+				return nil
+			}
+			fmt.Println(key.Key)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "print the key of one output of a multi-output job")
+	cmd.Flags().StringVar(&wrapKeyFile, "wrap-key", "", "PEM file of an RSA public key to wrap the key with")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "how long the wrapped key is valid (server default when 0)")
+	return cmd
+}
+
+func newJobRotateKeyCommand() *cobra.Command {
+	var keyRef string
+	var watch bool
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "rotate-key JOB_ID",
+		Short: "Re-encrypt a completed job's output under a new key",
+		Long: "Re-encrypt a completed job's output under a new key, as a new job. Once\n" +
+			"the new output is verified the job's key is retired and no longer\n" +
+			"delivered. The API key must have the keys or admin role.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient()
+			if keyRef == "" {
+				job, err := getJob(client, args[0])
+				if err != nil {
+					return err
+				}
+				if job.Result == nil {
+					return fmt.Errorf("job %s has no result to rotate", args[0])
+				}
+				keyRef = job.Result.KeyRef
+			}
+
+			var resp domain.EncryptionResponse
+			req := domain.KeyRotationRequest{KeyRef: keyRef}
+			if err := client.do(http.MethodPost, "/jobs/"+url.PathEscape(args[0])+"/rotate-key", nil, req, &resp); err != nil {
+				return err
+			}
+			if wantJSON() {
+				if err := printJSON(resp); err != nil {
+					return err
+				}
+			} else if err := printTable([]string{"JOB ID", "STATUS", "ROTATES"}, [][]string{{resp.JobID, string(resp.Status), args[0]}}); err != nil {
+				return err
+			}
+			if watch {
+				return watchJob(client, resp.JobID, interval)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keyRef, "key-ref", "", "key_ref of the key to retire (default: the job's current one)")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "follow progress until the rotation finishes")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "polling interval when watching")
+	return cmd
+}
+
+// readPublicKey reads a PEM encoded public key and returns its DER bytes in
+// base64, as the key endpoint expects
+func readPublicKey(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return "", fmt.Errorf("%s is not a PEM encoded public key", file)
+	}
+	return base64.StdEncoding.EncodeToString(block.Bytes), nil
+}
+
+func getJob(client *apiClient, jobID string) (*domain.EncryptionJob, error) {
+	var job domain.EncryptionJob
+	if err := client.do(http.MethodGet, "/status/"+url.PathEscape(jobID), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// watchJob polls a job and prints each change in status or progress until the
+// job reaches a terminal state
+func watchJob(client *apiClient, jobID string, interval time.Duration) error {
+	var lastStatus domain.EncryptionStatus
+	var lastProgress domain.Progress
+
+	for {
+		job, err := getJob(client, jobID)
+		if err != nil {
+			return err
+		}
+
+		if job.Status != lastStatus || job.Progress != lastProgress {
+			if wantJSON() {
+				if err := printJSON(job); err != nil {
+					return err
+				}
+			} else {
+				line := fmt.Sprintf("%s  %s  %-11s %5.1f%%", time.Now().Format(time.TimeOnly), job.ID, job.Status, job.Progress.Percent)
+				if details := formatProgress(job.Progress); details != "" {
+					line += "  " + details
+				}
+				if job.Error != "" {
+					line += "  " + job.Error
+				} else if job.Status == domain.StatusScheduled && job.Attempts > 0 {
+					line += fmt.Sprintf("  attempt %d failed, retrying at %s: %s", job.Attempts, formatUnix(job.ScheduledAt), job.LastError)
+				}
+				fmt.Println(line)
+			}
+			lastStatus, lastProgress = job.Status, job.Progress
+		}
+
+		if job.IsTerminal() {
+			if job.Status == domain.StatusFailed {
+				return fmt.Errorf("job %s failed: %s", job.ID, job.Error)
+			}
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+func printJobs(jobs []domain.EncryptionJob) error {
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		rows = append(rows, []string{
+			job.ID,
+			string(job.Status),
+			fmt.Sprintf("%.1f%%", job.Progress.Percent),
+			formatUnix(job.CreatedAt),
+			formatUnix(job.ExpiresAt),
+			job.SourceURL,
+		})
+	}
+	return printTable([]string{"JOB ID", "STATUS", "PROGRESS", "CREATED", "EXPIRES", "SOURCE"}, rows)
+}
+
+func printOutputs(outputs []domain.JobOutput) error {
+	rows := make([][]string, 0, len(outputs))
+	for _, out := range outputs {
+		rows = append(rows, []string{
+			out.Name,
+			string(out.Status),
+			fmt.Sprintf("%.1f%%", out.Progress.Percent),
+			out.Engine.Algorithm,
+			out.Error,
+		})
+	}
+	return printTable([]string{"OUTPUT", "STATUS", "PROGRESS", "ALGORITHM", "ERROR"}, rows)
+}
+
+// formatProgress describes the stage, bytes, media position, throughput,
+// elapsed time and ETA of a job
+func formatProgress(p domain.Progress) string {
+	var parts []string
+	if p.Stage != "" {
+		parts = append(parts, string(p.Stage))
+	}
+	if p.BytesTotal > 0 {
+		parts = append(parts, formatBytes(p.BytesProcessed)+"/"+formatBytes(p.BytesTotal))
+	} else if p.BytesProcessed > 0 {
+		parts = append(parts, formatBytes(p.BytesProcessed))
+	}
+	if p.Position > 0 {
+		parts = append(parts, "at "+p.Position.String())
+	}
+	if mbps := p.ThroughputMbps(); mbps > 0 {
+		parts = append(parts, strconv.FormatFloat(mbps, 'f', -1, 64)+" Mbit/s")
+	}
+	if p.Elapsed > 0 {
+		parts = append(parts, p.Elapsed.String()+" elapsed")
+	}
+	if eta := p.ETASeconds(); eta > 0 {
+		parts = append(parts, "ETA "+(time.Duration(eta)*time.Second).String())
+	}
+	return strings.Join(parts, "  ")
+}
+
+func printMetadata(metadata map[string]string) error {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, []string{key, metadata[key]})
+	}
+	return printTable([]string{"KEY", "VALUE"}, rows)
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
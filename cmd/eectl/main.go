@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Global flags shared by every command
+var (
+	serverURL string
+	apiKey    string
+	output    string
+	timeout   time.Duration
+)
+
+func main() {
+	root := &cobra.Command{
+		Use:           "eectl",
+		Short:         "Command-line client for the encryption service API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	defaultServer := os.Getenv("EECTL_SERVER")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8080"
+	}
+
+	root.PersistentFlags().StringVarP(&serverURL, "server", "s", defaultServer, "API base URL (env EECTL_SERVER)")
+	root.PersistentFlags().StringVar(&apiKey, "api-key", os.Getenv("EECTL_API_KEY"), "API key sent with every request (env EECTL_API_KEY)")
+	root.PersistentFlags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	root.PersistentFlags().DurationVar(&timeout, "timeout", 30*time.Second, "HTTP request timeout")
+
+	root.AddCommand(newJobCommand(), newBatchCommand(), newWebhookCommand(), newRecurringCommand())
+
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
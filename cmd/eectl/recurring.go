@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+
+	"E.E/internal/core/domain"
+)
+
+func newRecurringCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recurring",
+		Short: "Register and inspect batches started on a cron schedule",
+	}
+
+	cmd.AddCommand(
+		newRecurringAddCommand(),
+		newRecurringListCommand(),
+		newRecurringGetCommand(),
+		newRecurringRemoveCommand(),
+	)
+	return cmd
+}
+
+func newRecurringAddCommand() *cobra.Command {
+	var (
+		req    domain.RecurringRequest
+		filter domain.RecurringFilter
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add CRON",
+		Short: "Register a batch started whenever CRON matches",
+		Long: `Register a batch started whenever CRON matches.
+
+CRON has five fields (minute hour day-of-month month day-of-week), e.g.
+"0 2 * * SUN", or is one of @yearly, @monthly, @weekly, @daily and @hourly.
+Each run starts a batch over the --source-url sources and the sources of the
+tenant's existing jobs matching the --filter-* flags.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.Cron = args[0]
+			if filter.Status != "" || filter.SourceURL != "" || len(filter.Metadata) > 0 {
+				req.Template.Filter = &filter
+			}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			var recurring domain.RecurringBatch
+			if err := newAPIClient().do(http.MethodPost, "/recurring-batches", nil, req, &recurring); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(recurring)
+			}
+			return printRecurring([]domain.RecurringBatch{recurring})
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Name, "name", "", "name to recognise the recurring batch by")
+	cmd.Flags().StringVar(&req.Timezone, "timezone", "", "IANA time zone CRON is read in, e.g. Europe/Paris (default UTC)")
+	cmd.Flags().StringSliceVar(&req.Template.SourceURLs, "source-url", nil, "source to encrypt on every run; repeat for several")
+	cmd.Flags().StringVar(&filter.Status, "filter-status", "", "also encrypt the sources of existing jobs with this status")
+	cmd.Flags().StringVar(&filter.SourceURL, "filter-source", "", "also encrypt the sources of existing jobs whose source URL contains this")
+	cmd.Flags().StringToStringVar(&filter.Metadata, "filter-metadata", nil, "also encrypt the sources of existing jobs with these metadata entries, as key=value")
+	cmd.Flags().StringToStringVar(&req.Template.Metadata, "metadata", nil, "metadata to attach to started jobs, as key=value")
+	cmd.Flags().BoolVar(&req.Paused, "paused", false, "register without running it until resumed")
+	cmd.Flags().StringVar(&req.Tenant, "tenant", "", "register for another tenant (admin keys only)")
+	return cmd
+}
+
+func newRecurringListCommand() *cobra.Command {
+	var tenant string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the registered recurring batches",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			setIfNotEmpty(query, "tenant", tenant)
+			var resp struct {
+				RecurringBatches []domain.RecurringBatch `json:"recurring_batches"`
+			}
+			if err := newAPIClient().do(http.MethodGet, "/recurring-batches", query, nil, &resp); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(resp.RecurringBatches)
+			}
+			return printRecurring(resp.RecurringBatches)
+		},
+	}
+
+	cmd.Flags().StringVar(&tenant, "tenant", "", "list another tenant's recurring batches (admin keys only)")
+	return cmd
+}
+
+func newRecurringGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get RECURRING_ID",
+		Short: "Show a recurring batch with its next and last runs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var recurring domain.RecurringBatch
+			if err := newAPIClient().do(http.MethodGet, "/recurring-batches/"+url.PathEscape(args[0]), nil, nil, &recurring); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(recurring)
+			}
+			rows := [][]string{
+				{"ID", recurring.ID},
+				{"Name", recurring.Name},
+				{"Tenant", recurring.Tenant},
+				{"Cron", recurring.Cron},
+				{"Timezone", recurring.Timezone},
+				{"Paused", fmt.Sprint(recurring.Paused)},
+				{"Next run", formatUnix(recurring.NextRunAt)},
+				{"Last run", formatUnix(recurring.LastRunAt)},
+				{"Last batch", recurring.LastBatchID},
+				{"Last error", recurring.LastError},
+			}
+			return printTable([]string{"FIELD", "VALUE"}, rows)
+		},
+	}
+}
+
+func newRecurringRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove RECURRING_ID",
+		Short: "Stop a recurring batch from starting more batches",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var recurring domain.RecurringBatch
+			if err := newAPIClient().do(http.MethodDelete, "/recurring-batches/"+url.PathEscape(args[0]), nil, nil, &recurring); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(recurring)
+			}
+			fmt.Printf("Recurring batch %s removed\n", recurring.ID)
+			return nil
+		},
+	}
+}
+
+func printRecurring(batches []domain.RecurringBatch) error {
+	rows := make([][]string, 0, len(batches))
+	for _, recurring := range batches {
+		next := formatUnix(recurring.NextRunAt)
+		if recurring.Paused {
+			next = "paused"
+		}
+		rows = append(rows, []string{recurring.ID, recurring.Name, recurring.Tenant, recurring.Cron, next, recurring.LastBatchID})
+	}
+	return printTable([]string{"ID", "NAME", "TENANT", "CRON", "NEXT RUN", "LAST BATCH"}, rows)
+}
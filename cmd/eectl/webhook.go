@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"E.E/internal/core/domain"
+)
+
+func newWebhookCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Register and inspect the webhooks job events are sent to",
+	}
+
+	cmd.AddCommand(
+		newWebhookAddCommand(),
+		newWebhookListCommand(),
+		newWebhookRemoveCommand(),
+		newWebhookDeliveriesCommand(),
+	)
+	return cmd
+}
+
+func newWebhookAddCommand() *cobra.Command {
+	var req domain.WebhookRequest
+	var events []string
+
+	cmd := &cobra.Command{
+		Use:   "add URL",
+		Short: "Register a webhook for your tenant",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.URL = args[0]
+			for _, event := range events {
+				req.EventTypes = append(req.EventTypes, domain.WebhookEvent(event))
+			}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			var webhook domain.WebhookConfig
+			if err := newAPIClient().do(http.MethodPost, "/webhooks", nil, req, &webhook); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(webhook)
+			}
+			return printWebhooks([]domain.WebhookConfig{webhook})
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Secret, "secret", "", "secret deliveries are signed with")
+	cmd.Flags().StringSliceVar(&events, "event", nil, "event to send, e.g. job.completed; repeat for several (default: every event)")
+	cmd.Flags().StringVar(&req.Tenant, "tenant", "", "register for another tenant (admin keys only)")
+	return cmd
+}
+
+func newWebhookListCommand() *cobra.Command {
+	var tenant string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the registered webhooks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			setIfNotEmpty(query, "tenant", tenant)
+			var resp struct {
+				Webhooks []domain.WebhookConfig `json:"webhooks"`
+			}
+			if err := newAPIClient().do(http.MethodGet, "/webhooks", query, nil, &resp); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(resp.Webhooks)
+			}
+			return printWebhooks(resp.Webhooks)
+		},
+	}
+
+	cmd.Flags().StringVar(&tenant, "tenant", "", "list another tenant's webhooks (admin keys only)")
+	return cmd
+}
+
+func newWebhookRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove WEBHOOK_ID",
+		Short: "Stop sending events to a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var webhook domain.WebhookConfig
+			if err := newAPIClient().do(http.MethodDelete, "/webhooks/"+url.PathEscape(args[0]), nil, nil, &webhook); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(webhook)
+			}
+			fmt.Printf("Webhook %s removed\n", webhook.ID)
+			return nil
+		},
+	}
+}
+
+func newWebhookDeliveriesCommand() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "deliveries WEBHOOK_ID",
+		Short: "List the deliveries to a webhook that failed for good",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{"limit": {strconv.Itoa(limit)}}
+			var resp struct {
+				Deliveries []domain.WebhookDelivery `json:"deliveries"`
+			}
+			if err := newAPIClient().do(http.MethodGet, "/webhooks/"+url.PathEscape(args[0])+"/deliveries", query, nil, &resp); err != nil {
+				return err
+			}
+			if wantJSON() {
+				return printJSON(resp.Deliveries)
+			}
+			rows := make([][]string, 0, len(resp.Deliveries))
+			for _, delivery := range resp.Deliveries {
+				rows = append(rows, []string{
+					formatUnix(delivery.FailedAt),
+					string(delivery.Payload.EventType),
+					strconv.Itoa(delivery.Attempts),
+					delivery.Error,
+				})
+			}
+			return printTable([]string{"FAILED AT", "EVENT", "ATTEMPTS", "ERROR"}, rows)
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 20, "maximum number of deliveries to list")
+	return cmd
+}
+
+func printWebhooks(webhooks []domain.WebhookConfig) error {
+	rows := make([][]string, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		events := "*"
+		if len(webhook.EventTypes) > 0 {
+			names := make([]string, len(webhook.EventTypes))
+			for i, event := range webhook.EventTypes {
+				names[i] = string(event)
+			}
+			events = strings.Join(names, ",")
+		}
+		rows = append(rows, []string{webhook.ID, webhook.Tenant, webhook.URL, events})
+	}
+	return printTable([]string{"ID", "TENANT", "URL", "EVENTS"}, rows)
+}
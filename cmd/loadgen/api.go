@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"E.E/internal/core/domain"
+)
+
+// maxKnownJobs bounds the job IDs kept for polling
+const maxKnownJobs = 10000
+
+type apiLoadConfig struct {
+	server      string // Base URL including /api/v1
+	apiKey      string
+	duration    time.Duration
+	concurrency int
+	rate        float64
+	weights     map[string]int
+	sources     []string
+	batchSize   int
+	timeout     time.Duration
+}
+
+// apiLoad is the state shared by the clients of one run
+type apiLoad struct {
+	config   apiLoadConfig
+	client   *http.Client
+	limiter  *rate.Limiter
+	recorder *recorder
+	ops      []string // One entry per unit of weight
+
+	mu   sync.Mutex
+	jobs []string // Submitted job IDs, polled at random
+}
+
+// runAPILoad sends the weighted operation mix from config.concurrency clients
+// until config.duration has passed
+func runAPILoad(config apiLoadConfig) (*Report, error) {
+	load, err := newAPILoad(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < config.concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			load.run(ctx, rand.New(rand.NewSource(seed)))
+		}(start.UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	return load.recorder.report("api", time.Since(start)), nil
+}
+
+// newAPILoad prepares the state of a run, with a client keeping a connection
+// open for each of config.concurrency clients
+func newAPILoad(config apiLoadConfig) (*apiLoad, error) {
+	if config.concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if config.batchSize < 1 {
+		return nil, fmt.Errorf("batch size must be at least 1")
+	}
+
+	load := &apiLoad{
+		config:   config,
+		limiter:  rate.NewLimiter(rate.Inf, 0),
+		recorder: newRecorder(),
+		client: &http.Client{
+			Timeout: config.timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        config.concurrency,
+				MaxIdleConnsPerHost: config.concurrency,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+	if config.rate > 0 {
+		load.limiter = rate.NewLimiter(rate.Limit(config.rate), config.concurrency)
+	}
+	for _, op := range []string{opEncrypt, opBatch, opPoll, opList, opSummary} {
+		for i := 0; i < config.weights[op]; i++ {
+			load.ops = append(load.ops, op)
+		}
+	}
+	if len(load.ops) == 0 {
+		return nil, fmt.Errorf("every operation has weight 0")
+	}
+	return load, nil
+}
+
+// run sends operations until ctx is done
+func (l *apiLoad) run(ctx context.Context, rng *rand.Rand) {
+	for {
+		if err := l.limiter.Wait(ctx); err != nil {
+			return
+		}
+		op := l.ops[rng.Intn(len(l.ops))]
+
+		start := time.Now()
+		err := l.do(op, rng)
+		latency := time.Since(start)
+
+		// A request cut off by the end of the run says nothing about the server
+		if ctx.Err() != nil {
+			return
+		}
+		l.recorder.record(op, latency, err)
+	}
+}
+
+func (l *apiLoad) do(op string, rng *rand.Rand) error {
+	switch op {
+	case opEncrypt:
+		var resp domain.EncryptionResponse
+		err := l.send(http.MethodPost, "/encrypt", domain.EncryptionRequest{
+			SourceURL: l.source(rng),
+		}, &resp)
+		if err == nil {
+			l.remember(resp.JobID)
+		}
+		return err
+
+	case opBatch:
+		sources := make([]string, l.config.batchSize)
+		for i := range sources {
+			sources[i] = l.source(rng)
+		}
+		var result domain.BatchResult
+		err := l.send(http.MethodPost, "/encrypt", domain.EncryptionRequest{
+			Batch:      true,
+			SourceURLs: sources,
+		}, &result)
+		if err == nil {
+			l.remember(result.Successful...)
+		}
+		return err
+
+	case opPoll:
+		jobID := l.knownJob(rng)
+		if jobID == "" {
+			// Nothing submitted yet; list instead so the client keeps working
+			return l.send(http.MethodGet, "/jobs?limit=20", nil, nil)
+		}
+		return l.send(http.MethodGet, "/status/"+jobID, nil, nil)
+
+	case opList:
+		return l.send(http.MethodGet, "/jobs?limit=20", nil, nil)
+
+	case opSummary:
+		return l.send(http.MethodGet, "/jobs/status", nil, nil)
+	}
+	return fmt.Errorf("unknown operation %q", op)
+}
+
+// send performs one request, decoding a successful response into out if set.
+// Any status other than 2xx counts as an error.
+func (l *apiLoad) send(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, l.config.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if l.config.apiKey != "" {
+		req.Header.Set("X-API-Key", l.config.apiKey)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (l *apiLoad) source(rng *rand.Rand) string {
+	return l.config.sources[rng.Intn(len(l.config.sources))]
+}
+
+// remember keeps submitted job IDs for polling, replacing old ones at random
+// once maxKnownJobs are kept
+func (l *apiLoad) remember(jobIDs ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range jobIDs {
+		if id == "" {
+			continue
+		}
+		if len(l.jobs) < maxKnownJobs {
+			l.jobs = append(l.jobs, id)
+		} else {
+			l.jobs[rand.Intn(maxKnownJobs)] = id
+		}
+	}
+}
+
+func (l *apiLoad) knownJob(rng *rand.Rand) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.jobs) == 0 {
+		return ""
+	}
+	return l.jobs[rng.Intn(len(l.jobs))]
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/services"
+	"E.E/internal/primary/http"
+	"E.E/internal/primary/http/handlers"
+	"E.E/internal/secondary/repository"
+)
+
+// The benchmarks load the instance at EECTL_SERVER, with EECTL_API_KEY, when
+// it is set, and otherwise an in-process API over in-memory storage whose
+// queued jobs are discarded, so the HTTP and service layers are measured
+// without the workers.
+
+// BenchmarkSubmit measures POST /encrypt of a single source
+func BenchmarkSubmit(b *testing.B) {
+	load := benchmarkLoad(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			if err := load.do(opEncrypt, rng); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkStatus measures GET /status/:jobId of submitted jobs
+func BenchmarkStatus(b *testing.B) {
+	load := benchmarkLoad(b)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if err := load.do(opEncrypt, rng); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			if err := load.do(opPoll, rng); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func benchmarkLoad(b *testing.B) *apiLoad {
+	b.Helper()
+	server := os.Getenv("EECTL_SERVER")
+	if server == "" {
+		server = benchmarkServer(b)
+	}
+
+	load, err := newAPILoad(apiLoadConfig{
+		server:      strings.TrimRight(server, "/") + "/api/v1",
+		apiKey:      os.Getenv("EECTL_API_KEY"),
+		concurrency: 8,
+		weights:     map[string]int{opEncrypt: 1},
+		sources:     []string{"file:///loadgen/sample.mp4"},
+		batchSize:   1,
+		timeout:     10 * time.Second,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return load
+}
+
+// benchmarkServer starts an in-process API and returns its base URL
+func benchmarkServer(b *testing.B) string {
+	b.Helper()
+	gin.SetMode(gin.ReleaseMode)
+	logger := zap.NewNop()
+
+	queue := repository.NewMemoryJobQueue(1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(cancel)
+	go func() {
+		for {
+			if _, err := queue.Dequeue(ctx); err != nil {
+				return
+			}
+		}
+	}()
+
+	service := services.NewEncryptionService(repository.NewMemoryRepository(), nil, queue, logger)
+	router := gin.New()
+	http.SetupRouter(router, http.RouterConfig{
+		EncryptionHandler: handlers.NewEncryptionHandler(service, logger),
+		Logger:            logger,
+	})
+
+	server := httptest.NewServer(router)
+	b.Cleanup(server.Close)
+	return server.URL
+}
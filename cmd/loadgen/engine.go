@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/secondary/engine"
+)
+
+type engineBenchConfig struct {
+	duration    time.Duration
+	concurrency int
+	size        int
+	chunkSize   int
+	algorithm   string
+}
+
+// runEngineBench encrypts config.size bytes of zeroes per stream from
+// config.concurrency goroutines until config.duration has passed, discarding
+// the output, so only the engine itself is measured
+func runEngineBench(config engineBenchConfig) (*Report, error) {
+	if config.concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if config.size < 1 {
+		return nil, fmt.Errorf("size must be at least 1")
+	}
+	limits := domain.EngineLimits{
+		Algorithms:   domain.SupportedAlgorithms,
+		IVStrategies: domain.SupportedIVStrategies,
+		Containers:   domain.SupportedContainers,
+		MinChunkSize: 1,
+		MaxChunkSize: domain.MaxChunkSize,
+	}
+	params, err := limits.Resolve(&domain.EngineParams{Algorithm: config.algorithm, ChunkSize: config.chunkSize})
+	if err != nil {
+		return nil, err
+	}
+
+	eng := engine.NewAEADEngine()
+	key, err := eng.GenerateKey(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Warm the buffer pools so the first streams do not skew allocations
+	if err := eng.EncryptWithKey(io.LimitReader(zeroReader{}, int64(config.size)), io.Discard, key, params); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.duration)
+	defer cancel()
+
+	rec := newRecorder()
+	op := "encrypt " + params.Algorithm
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	for i := 0; i < config.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				streamStart := time.Now()
+				input := io.LimitReader(zeroReader{}, int64(config.size))
+				if err := eng.EncryptWithKey(input, io.Discard, key, params); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					return
+				}
+				rec.recordStream(op, time.Since(streamStart), int64(config.size))
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// Allocations cannot be attributed per goroutine, so the run's total is
+	// spread over its streams
+	rec.recordAllocs(op, after.Mallocs-before.Mallocs)
+	return rec.report("engine", elapsed), nil
+}
+
+// zeroReader is an endless source of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
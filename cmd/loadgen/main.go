@@ -0,0 +1,140 @@
+// Command loadgen drives realistic traffic against a running instance (job
+// submissions, batches, status polls, listings and summaries) and reports
+// throughput and latency percentiles per operation. With -mode engine it
+// benchmarks the encryption engine in process instead, reporting throughput
+// and allocations per stream.
+//
+// Thresholds (-max-error-rate, -max-p99) make it exit non-zero, so a release
+// pipeline can fail on performance regressions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operations loadgen can send, with the weights used when -mix is not set
+var defaultMix = map[string]int{
+	opEncrypt: 2,
+	opBatch:   1,
+	opPoll:    10,
+	opList:    2,
+	opSummary: 1,
+}
+
+const (
+	opEncrypt = "encrypt" // POST /encrypt with one source
+	opBatch   = "batch"   // POST /encrypt with a start batch
+	opPoll    = "poll"    // GET /status/:jobId of a submitted job
+	opList    = "list"    // GET /jobs
+	opSummary = "summary" // GET /jobs/status
+)
+
+func main() {
+	fs := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	mode := fs.String("mode", "api", "api: load a running instance, engine: benchmark the encryption engine in process")
+	server := fs.String("server", envOr("EECTL_SERVER", "http://localhost:8080"), "API base URL (env EECTL_SERVER)")
+	apiKey := fs.String("api-key", os.Getenv("EECTL_API_KEY"), "API key sent with every request (env EECTL_API_KEY)")
+	duration := fs.Duration("duration", 30*time.Second, "how long to generate load")
+	concurrency := fs.Int("concurrency", 8, "concurrent clients")
+	rps := fs.Float64("rate", 0, "requests per second across all clients (0 for as fast as possible)")
+	mix := fs.String("mix", "", "operation weights, e.g. encrypt=2,batch=1,poll=10,list=2,summary=1")
+	sources := fs.String("sources", "file:///loadgen/sample.mp4", "comma-separated source URLs to submit")
+	batchSize := fs.Int("batch-size", 10, "source URLs per batch")
+	timeout := fs.Duration("timeout", 10*time.Second, "HTTP request timeout")
+	size := fs.Int("size", 64<<20, "engine mode: plaintext bytes per stream")
+	chunkSize := fs.Int("chunk-size", 1<<20, "engine mode: chunk size")
+	algorithm := fs.String("algorithm", "AES-256-GCM", "engine mode: cipher")
+	jsonOutput := fs.Bool("json", false, "print the report as JSON")
+	maxErrorRate := fs.Float64("max-error-rate", -1, "exit 1 if the share of failed requests exceeds this (0-1, negative disables)")
+	maxP99 := fs.Duration("max-p99", 0, "exit 1 if any operation's p99 latency exceeds this (0 disables)")
+	fs.Parse(os.Args[1:])
+
+	var (
+		report *Report
+		err    error
+	)
+	switch *mode {
+	case "api":
+		weights, parseErr := parseMix(*mix)
+		if parseErr != nil {
+			fail(parseErr)
+		}
+		report, err = runAPILoad(apiLoadConfig{
+			server:      strings.TrimRight(*server, "/") + "/api/v1",
+			apiKey:      *apiKey,
+			duration:    *duration,
+			concurrency: *concurrency,
+			rate:        *rps,
+			weights:     weights,
+			sources:     strings.Split(*sources, ","),
+			batchSize:   *batchSize,
+			timeout:     *timeout,
+		})
+	case "engine":
+		report, err = runEngineBench(engineBenchConfig{
+			duration:    *duration,
+			concurrency: *concurrency,
+			size:        *size,
+			chunkSize:   *chunkSize,
+			algorithm:   *algorithm,
+		})
+	default:
+		err = fmt.Errorf("unknown mode %q", *mode)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
+
+	if violations := report.Check(*maxErrorRate, *maxP99); len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Fprintln(os.Stderr, "Threshold exceeded:", v)
+		}
+		os.Exit(1)
+	}
+}
+
+// parseMix reads operation weights as op=weight pairs
+func parseMix(mix string) (map[string]int, error) {
+	if mix == "" {
+		return defaultMix, nil
+	}
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(mix, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, known := defaultMix[op]; !ok || !known {
+			return nil, fmt.Errorf("invalid -mix entry %q; use op=weight with op one of encrypt, batch, poll, list, summary", pair)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight in -mix entry %q", pair)
+		}
+		weights[op] = n
+	}
+	return weights, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(2)
+}
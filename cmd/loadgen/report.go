@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the latency and outcome of every request per operation.
+// It is safe for concurrent use.
+type recorder struct {
+	mu  sync.Mutex
+	ops map[string]*opSamples
+}
+
+type opSamples struct {
+	latencies []time.Duration
+	errors    int
+	bytes     int64
+	allocs    uint64
+}
+
+func newRecorder() *recorder {
+	return &recorder{ops: make(map[string]*opSamples)}
+}
+
+func (r *recorder) samples(op string) *opSamples {
+	s, ok := r.ops[op]
+	if !ok {
+		s = &opSamples{}
+		r.ops[op] = s
+	}
+	return s
+}
+
+// record adds one request's latency, counting it as an error if err is set
+func (r *recorder) record(op string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.samples(op)
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.errors++
+	}
+}
+
+// recordStream adds one engine stream with the bytes it encrypted
+func (r *recorder) recordStream(op string, latency time.Duration, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.samples(op)
+	s.latencies = append(s.latencies, latency)
+	s.bytes += bytes
+}
+
+// recordAllocs adds heap allocations made by an operation's requests
+func (r *recorder) recordAllocs(op string, allocs uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.samples(op).allocs += allocs
+}
+
+// Report summarizes a run
+type Report struct {
+	Mode       string        `json:"mode"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	Operations []OpReport    `json:"operations"`
+}
+
+// OpReport summarizes one operation's requests
+type OpReport struct {
+	Operation  string        `json:"operation"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"throughput_rps"`
+	MBPerSec   float64       `json:"mb_per_sec,omitempty"`
+	AllocsPer  float64       `json:"allocs_per_op,omitempty"`
+	P50        time.Duration `json:"p50_ns"`
+	P90        time.Duration `json:"p90_ns"`
+	P99        time.Duration `json:"p99_ns"`
+	Max        time.Duration `json:"max_ns"`
+}
+
+// report computes the percentiles of everything recorded over elapsed
+func (r *recorder) report(mode string, elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Mode: mode, Elapsed: elapsed}
+	for name, s := range r.ops {
+		latencies := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		op := OpReport{
+			Operation:  name,
+			Requests:   len(latencies),
+			Errors:     s.errors,
+			Throughput: float64(len(latencies)) / elapsed.Seconds(),
+			P50:        percentile(latencies, 0.50),
+			P90:        percentile(latencies, 0.90),
+			P99:        percentile(latencies, 0.99),
+		}
+		if len(latencies) > 0 {
+			op.Max = latencies[len(latencies)-1]
+			op.AllocsPer = float64(s.allocs) / float64(len(latencies))
+		}
+		if s.bytes > 0 {
+			op.MBPerSec = float64(s.bytes) / (1 << 20) / elapsed.Seconds()
+		}
+		report.Operations = append(report.Operations, op)
+	}
+	sort.Slice(report.Operations, func(i, j int) bool {
+		return report.Operations[i].Operation < report.Operations[j].Operation
+	})
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
+
+// Print writes the report as a table
+func (r *Report) Print(out io.Writer) {
+	fmt.Fprintf(out, "%s run over %s\n\n", r.Mode, r.Elapsed.Round(time.Millisecond))
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tREQUESTS\tERRORS\tRPS\tMB/S\tALLOCS/OP\tP50\tP90\tP99\tMAX")
+	for _, op := range r.Operations {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			op.Operation, op.Requests, op.Errors, op.Throughput,
+			optional(op.MBPerSec, "%.1f"), optional(op.AllocsPer, "%.0f"),
+			op.P50.Round(time.Microsecond), op.P90.Round(time.Microsecond),
+			op.P99.Round(time.Microsecond), op.Max.Round(time.Microsecond))
+	}
+	w.Flush()
+}
+
+func optional(value float64, format string) string {
+	if value == 0 {
+		return "-"
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// Check returns the thresholds the run exceeded. A negative maxErrorRate or
+// zero maxP99 disables that check.
+func (r *Report) Check(maxErrorRate float64, maxP99 time.Duration) []string {
+	var violations []string
+	requests, errors := 0, 0
+	for _, op := range r.Operations {
+		requests += op.Requests
+		errors += op.Errors
+		if maxP99 > 0 && op.P99 > maxP99 {
+			violations = append(violations, fmt.Sprintf("%s p99 %s > %s", op.Operation, op.P99, maxP99))
+		}
+	}
+	if maxErrorRate >= 0 && requests > 0 {
+		if rate := float64(errors) / float64(requests); rate > maxErrorRate {
+			violations = append(violations, fmt.Sprintf("error rate %.3f > %.3f", rate, maxErrorRate))
+		}
+	}
+	if requests == 0 {
+		violations = append(violations, "no requests completed")
+	}
+	return violations
+}
@@ -0,0 +1,414 @@
+// Command seed fills the job and batch repositories with realistic fixture
+// data: jobs in every state with their histories, and batch results that
+// reference them. It is meant for development and integration environments.
+//
+// Redis settings come from the same config file and EE_* environment
+// variables as the API.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/secondary/repository"
+	"E.E/pkg/config"
+)
+
+var (
+	shows     = []string{"the-long-night", "harbor-lights", "deep-field", "paper-towns", "northbound"}
+	buckets   = []string{"media-ingest", "media-archive", "studio-uploads"}
+	qualities = []string{"1080p", "720p", "2160p"}
+	owners    = []string{"ingest-team", "archive-team", "studio-ops"}
+	envs      = []string{"production", "staging"}
+	failures  = []string{
+		"failed to open source: s3 object not found",
+		"failed to open source: unexpected HTTP status 403 Forbidden",
+		"encryption failed: unexpected EOF",
+		"failed to store output: no space left on device",
+	}
+)
+
+// seeder generates fixtures from a deterministic random source, so the same
+// seed always produces the same data
+type seeder struct {
+	rng     *rand.Rand
+	now     time.Time
+	span    time.Duration
+	jobs    ports.JobRepository
+	batches ports.BatchRepository
+}
+
+func main() {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	jobCount := fs.Int("jobs", 60, "number of jobs to create")
+	batchCount := fs.Int("batches", 8, "number of batch results to create")
+	seed := fs.Int64("seed", 1, "random seed; the same seed reproduces the same data")
+	span := fs.Duration("span", 7*24*time.Hour, "how far back job creation times are spread")
+	fs.Parse(os.Args[1:])
+
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	cfg, err := config.Load("seed", nil)
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	redisConfig := repository.DefaultRedisConfig()
+	redisConfig.URL = cfg.Redis.URL
+	redisConfig.Password = cfg.Redis.Password
+	redisConfig.DB = cfg.Redis.DB
+	redisConfig.ConnectTimeout = cfg.Redis.ConnectTimeout.Duration
+	redisConfig.JobTTL = cfg.Redis.JobTTL.Duration
+
+	jobRepository, err := repository.NewRedisJobRepository(redisConfig, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis job repository", zap.Error(err))
+	}
+	defer jobRepository.Close()
+
+	batchRepository, err := repository.NewRedisBatchRepository(redisConfig, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis batch repository", zap.Error(err))
+	}
+	defer batchRepository.Close()
+
+	s := &seeder{
+		rng:     rand.New(rand.NewSource(*seed)),
+		now:     time.Now(),
+		span:    *span,
+		jobs:    jobRepository,
+		batches: batchRepository,
+	}
+
+	ctx := context.Background()
+	jobs, err := s.seedJobs(ctx, *jobCount)
+	if err != nil {
+		logger.Fatal("Failed to seed jobs", zap.Error(err))
+	}
+	results, err := s.seedBatches(ctx, jobs, *batchCount)
+	if err != nil {
+		logger.Fatal("Failed to seed batches", zap.Error(err))
+	}
+
+	fmt.Printf("Seeded %d jobs and %d batch results into %s (db %d)\n", len(jobs), len(results), cfg.Redis.URL, cfg.Redis.DB)
+}
+
+// seedJobs creates jobs spread over every status, each with a history that
+// matches its current state
+func (s *seeder) seedJobs(ctx context.Context, count int) ([]*domain.EncryptionJob, error) {
+	jobs := make([]*domain.EncryptionJob, 0, count)
+
+	for i := 0; i < count; i++ {
+		created := s.now.Add(-time.Duration(s.rng.Int63n(int64(s.span))))
+		job := &domain.EncryptionJob{
+			ID:        s.uuid(),
+			SourceURL: s.sourceURL(),
+			Status:    domain.StatusQueued,
+			CreatedAt: created.Unix(),
+			UpdatedAt: created.Unix(),
+			Metadata:  s.metadata(),
+			Engine:    domain.DefaultEngineParams,
+		}
+		job.CreatedBy = job.Metadata["owner"]
+		history := []domain.JobHistoryEntry{s.entry(created, "created", job.Status, "")}
+
+		status := s.pickStatus()
+		if status == domain.StatusCancelled && s.rng.Intn(2) == 0 {
+			// Cancelled before a worker picked it up
+			job.Status = domain.StatusCancelled
+			history = append(history, s.entry(created.Add(s.duration(time.Second, time.Minute)), "stop", job.Status, ""))
+		} else if status != domain.StatusQueued {
+			started := created.Add(s.duration(5*time.Second, 10*time.Minute))
+			job.Status = domain.StatusProgress
+			job.Media = s.media()
+			history = append(history, s.entry(started, "started", job.Status, ""))
+
+			finished := started.Add(s.duration(30*time.Second, 45*time.Minute))
+			if finished.After(s.now) {
+				finished = s.now
+			}
+
+			switch status {
+			case domain.StatusProgress:
+				job.Progress = s.progress(s.rng.Intn(99), domain.StageEncrypting)
+			case domain.StatusPaused:
+				job.Status = domain.StatusPaused
+				job.Progress = s.progress(s.rng.Intn(99), domain.StageEncrypting)
+				history = append(history, s.entry(finished, "pause", job.Status, ""))
+			case domain.StatusCompleted:
+				job.Status = domain.StatusCompleted
+				job.Progress = s.progress(100, domain.StageDone)
+				job.DecryptionKey = s.key()
+				job.OutputPath = "outputs/" + job.ID + ".enc"
+				job.Result = s.result(job, finished.Sub(started))
+				history = append(history, s.entry(finished, "completed", job.Status, ""))
+			case domain.StatusFailed:
+				job.Status = domain.StatusFailed
+				job.Progress = s.progress(s.rng.Intn(60), domain.StageEncrypting)
+				job.Error = failures[s.rng.Intn(len(failures))]
+				history = append(history, s.entry(finished, "failed", job.Status, job.Error))
+			case domain.StatusCancelled:
+				job.Status = domain.StatusCancelled
+				job.Progress = s.progress(s.rng.Intn(99), domain.StageEncrypting)
+				history = append(history, s.entry(finished, "stop", job.Status, ""))
+			case domain.StatusPending:
+				job.Status = domain.StatusPending
+				history = append(history, s.entry(finished, "interrupted", job.Status, ""))
+			}
+			job.UpdatedAt = finished.Unix()
+		}
+
+		if err := s.jobs.Create(ctx, job); err != nil {
+			return nil, err
+		}
+		for _, entry := range history {
+			if err := s.jobs.AddJobHistory(ctx, job.ID, entry); err != nil {
+				return nil, err
+			}
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// seedBatches groups jobs into start batches and adds follow-up pause, retry
+// and rollback operations against some of them
+func (s *seeder) seedBatches(ctx context.Context, jobs []*domain.EncryptionJob, count int) ([]*domain.BatchResult, error) {
+	results := make([]*domain.BatchResult, 0, count)
+	if len(jobs) == 0 {
+		return results, nil
+	}
+
+	var starts []*domain.BatchResult
+	for i := 0; i < count; i++ {
+		var result *domain.BatchResult
+		if len(starts) == 0 || s.rng.Intn(3) > 0 {
+			result = s.startBatch(jobs)
+			starts = append(starts, result)
+		} else {
+			result = s.followUpBatch(starts[s.rng.Intn(len(starts))], jobs)
+		}
+
+		if err := s.batches.StoreBatchResult(ctx, result); err != nil {
+			return nil, err
+		}
+		for _, jobID := range result.Successful {
+			entry := s.entry(result.StartTime, string(result.Action), "", "")
+			entry.Status = "created"
+			if result.Action != domain.BatchActionStart {
+				entry.Status = "success"
+			}
+			entry.BatchID = result.BatchID
+			if err := s.jobs.AddJobHistory(ctx, jobID, entry); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *seeder) startBatch(jobs []*domain.EncryptionJob) *domain.BatchResult {
+	size := 2 + s.rng.Intn(8)
+	if size > len(jobs) {
+		size = len(jobs)
+	}
+
+	result := s.newBatch(domain.BatchActionStart, "")
+	for _, i := range s.rng.Perm(len(jobs))[:size] {
+		result.Successful = append(result.Successful, jobs[i].ID)
+	}
+
+	// Some start batches include sources that could not be turned into jobs
+	if s.rng.Intn(4) == 0 {
+		result.Failed = append(result.Failed, domain.BatchJobError{
+			JobID: "N/A",
+			Error: fmt.Sprintf("Failed to create job for %s: source_url: invalid URL format", "ftp://legacy/"+shows[s.rng.Intn(len(shows))]+".mov"),
+		})
+	}
+	return s.finish(result)
+}
+
+func (s *seeder) followUpBatch(parent *domain.BatchResult, jobs []*domain.EncryptionJob) *domain.BatchResult {
+	actions := []domain.BatchAction{domain.BatchActionPause, domain.BatchActionRetry, domain.BatchActionRollback}
+	action := actions[s.rng.Intn(len(actions))]
+
+	parentID := ""
+	if action == domain.BatchActionRollback {
+		parentID = parent.BatchID
+	}
+	result := s.newBatch(action, parentID)
+
+	for _, jobID := range parent.Successful {
+		if s.rng.Intn(5) == 0 {
+			result.Failed = append(result.Failed, domain.BatchJobError{
+				JobID: jobID,
+				Error: fmt.Sprintf("invalid job state transition: cannot %s job %s", action, jobID),
+			})
+			continue
+		}
+		result.Successful = append(result.Successful, jobID)
+	}
+	return s.finish(result)
+}
+
+func (s *seeder) newBatch(action domain.BatchAction, parentID string) *domain.BatchResult {
+	return &domain.BatchResult{
+		BatchID:       "batch_" + s.uuid(),
+		ParentBatchID: parentID,
+		CreatedBy:     owners[s.rng.Intn(len(owners))],
+		StartTime:     s.now.Add(-time.Duration(s.rng.Int63n(int64(s.span)))),
+		Action:        action,
+		Successful:    make([]string, 0),
+		Failed:        make([]domain.BatchJobError, 0),
+	}
+}
+
+func (s *seeder) finish(result *domain.BatchResult) *domain.BatchResult {
+	result.EndTime = result.StartTime.Add(s.duration(50*time.Millisecond, 5*time.Second))
+	result.Summary = domain.BatchSummary{
+		TotalJobs:    len(result.Successful) + len(result.Failed),
+		SuccessCount: len(result.Successful),
+		FailureCount: len(result.Failed),
+		Duration:     domain.Duration(result.EndTime.Sub(result.StartTime)),
+	}
+	return result
+}
+
+// pickStatus draws a job status, weighted towards finished jobs
+func (s *seeder) pickStatus() domain.EncryptionStatus {
+	switch n := s.rng.Intn(100); {
+	case n < 45:
+		return domain.StatusCompleted
+	case n < 60:
+		return domain.StatusFailed
+	case n < 70:
+		return domain.StatusProgress
+	case n < 78:
+		return domain.StatusPaused
+	case n < 85:
+		return domain.StatusCancelled
+	case n < 88:
+		return domain.StatusPending
+	default:
+		return domain.StatusQueued
+	}
+}
+
+func (s *seeder) sourceURL() string {
+	show := shows[s.rng.Intn(len(shows))]
+	file := fmt.Sprintf("s%02de%02d-%s.mp4", 1+s.rng.Intn(4), 1+s.rng.Intn(12), qualities[s.rng.Intn(len(qualities))])
+	if s.rng.Intn(5) == 0 {
+		return fmt.Sprintf("file:///srv/media/%s/%s", show, file)
+	}
+	return fmt.Sprintf("s3://%s/%s/%s", buckets[s.rng.Intn(len(buckets))], show, file)
+}
+
+// progress returns progress details for a source between 50MB and 4GB
+func (s *seeder) progress(percent int, stage domain.ProgressStage) domain.Progress {
+	total := (50 + s.rng.Int63n(4000)) << 20
+	progress := domain.Progress{
+		Percent:        float64(percent),
+		Stage:          stage,
+		BytesProcessed: total * int64(percent) / 100,
+		BytesTotal:     total,
+		Throughput:     float64((5 + s.rng.Int63n(75)) << 20),
+	}
+	if stage != domain.StageDone {
+		progress.ETA = domain.Duration(float64(total-progress.BytesProcessed) / progress.Throughput * float64(time.Second))
+	}
+	return progress
+}
+
+// result returns a plausible result for a completed job that ran for total
+// media describes a typical HD or UHD H.264/HEVC source
+func (s *seeder) media() *domain.MediaInfo {
+	sizes := [][2]int{{1280, 720}, {1920, 1080}, {3840, 2160}}
+	size := sizes[s.rng.Intn(len(sizes))]
+	codecs := []string{"h264", "hevc"}
+	return &domain.MediaInfo{
+		Container:  "mov,mp4,m4a,3gp,3g2,mj2",
+		Duration:   domain.Duration(s.duration(30*time.Second, 2*time.Hour).Round(time.Millisecond)),
+		Width:      size[0],
+		Height:     size[1],
+		VideoCodec: codecs[s.rng.Intn(len(codecs))],
+		AudioCodec: "aac",
+		Bitrate:    int64(size[1]) * 4000,
+	}
+}
+
+func (s *seeder) result(job *domain.EncryptionJob, total time.Duration) *domain.JobResult {
+	checksum := make([]byte, sha256.Size)
+	s.rng.Read(checksum)
+	keyRef := make([]byte, 8)
+	s.rng.Read(keyRef)
+
+	fetch := total / time.Duration(20+s.rng.Intn(30))
+	store := total / time.Duration(5+s.rng.Intn(10))
+	return &domain.JobResult{
+		OutputPath: job.OutputPath,
+		OutputURL:  "file:///srv/ee/storage/" + job.OutputPath,
+		Size:       job.Progress.BytesTotal + job.Progress.BytesTotal/(1<<20)*21 + 16, // header and per-chunk overhead
+		Checksum:   "sha256:" + hex.EncodeToString(checksum),
+		Algorithm:  job.Engine.Algorithm,
+		ChunkSize:  job.Engine.ChunkSize,
+		IVStrategy: job.Engine.IVStrategy,
+		KeyRef:     "sha256:" + hex.EncodeToString(keyRef),
+		Timings: domain.StageTimings{
+			Fetch:   domain.Duration(fetch),
+			Encrypt: domain.Duration(total - fetch - store),
+			Store:   domain.Duration(store),
+			Total:   domain.Duration(total),
+		},
+	}
+}
+
+func (s *seeder) metadata() map[string]string {
+	metadata := map[string]string{
+		"owner":       owners[s.rng.Intn(len(owners))],
+		"environment": envs[s.rng.Intn(len(envs))],
+	}
+	if s.rng.Intn(2) == 0 {
+		metadata["catalog_id"] = fmt.Sprintf("CAT-%06d", s.rng.Intn(1000000))
+	}
+	return metadata
+}
+
+func (s *seeder) entry(at time.Time, action string, status domain.EncryptionStatus, errMsg string) domain.JobHistoryEntry {
+	return domain.JobHistoryEntry{
+		Timestamp: at,
+		Action:    action,
+		Status:    string(status),
+		Error:     errMsg,
+		Details:   map[string]interface{}{"seeded": true},
+	}
+}
+
+func (s *seeder) duration(min, max time.Duration) time.Duration {
+	return min + time.Duration(s.rng.Int63n(int64(max-min)))
+}
+
+func (s *seeder) uuid() string {
+	id, _ := uuid.NewRandomFromReader(s.rng)
+	return id.String()
+}
+
+func (s *seeder) key() string {
+	key := make([]byte, 32)
+	s.rng.Read(key)
+	return hex.EncodeToString(key)
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for malformed API key requests
+	ErrInvalidAPIKey = errors.New("invalid API key request")
+	// ErrAPIKeyNotFound is returned for API keys that do not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrUnauthenticated is returned for API keys that are unknown, expired or
+	// revoked
+	ErrUnauthenticated = errors.New("API key is unknown, expired or revoked")
+)
+
+// Scopes an API key may be granted
+const (
+	ScopeJobsRead  = "jobs:read"  // Read jobs, batches and their results
+	ScopeJobsWrite = "jobs:write" // Submit and change jobs and batches
+	ScopeKeys      = "keys"       // Retrieve the decryption keys of jobs
+	ScopeAdmin     = "admin"      // Everything, for every owner
+)
+
+// Scopes lists every scope, in the order they are documented
+var Scopes = []string{ScopeJobsRead, ScopeJobsWrite, ScopeKeys, ScopeAdmin}
+
+// APIKeySecretPrefix starts every API key created through the API, so they
+// can be told apart from configured keys
+const APIKeySecretPrefix = "ee_"
+
+// MaxAPIKeyNameLength limits the name of an API key
+const MaxAPIKeyNameLength = 128
+
+// APIKey is an API key created through the API. Only a hash of its secret is
+// stored; the secret itself is returned once, when the key is created.
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name,omitempty"`   // What the key is for, e.g. a service's name
+	Owner      string   `json:"owner"`            // Owner the key acts for
+	Tenant     string   `json:"tenant,omitempty"` // Tenant of the owner; the owner itself when empty
+	Scopes     []string `json:"scopes"`
+	Prefix     string   `json:"prefix"`                // Start of the secret, to recognise the key by
+	Secret     string   `json:"key,omitempty"`         // Set when the key is created, never stored
+	SecretHash string   `json:"secret_hash,omitempty"` // Stored, never returned
+	CreatedBy  string   `json:"created_by,omitempty"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  int64    `json:"expires_at,omitempty"` // 0 if the key does not expire
+	RevokedAt  int64    `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can be used at the unix time now
+func (k *APIKey) Active(now int64) bool {
+	return k.RevokedAt == 0 && (k.ExpiresAt == 0 || now < k.ExpiresAt)
+}
+
+// Principal returns the caller the key acts for
+func (k *APIKey) Principal() Principal {
+	principal := NewPrincipal(k.Owner, k.ID, k.Scopes)
+	principal.Tenant = k.Tenant
+	return principal
+}
+
+// APIKeyRequest creates an API key
+type APIKeyRequest struct {
+	Name       string   `json:"name,omitempty"`
+	Owner      string   `json:"owner"`
+	Tenant     string   `json:"tenant,omitempty"`
+	Scopes     []string `json:"scopes"`
+	TTLSeconds int      `json:"ttl_seconds,omitempty"` // 0 for a key that does not expire
+}
+
+// Validate checks the API key request
+func (r APIKeyRequest) Validate() error {
+	if r.Owner == "" {
+		return fmt.Errorf("%w: owner is required", ErrInvalidAPIKey)
+	}
+	if len(r.Name) > MaxAPIKeyNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidAPIKey, MaxAPIKeyNameLength)
+	}
+	if len(r.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	for _, scope := range r.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("%w: unknown scope %q, expected one of %v", ErrInvalidAPIKey, scope, Scopes)
+		}
+	}
+	if r.TTLSeconds < 0 {
+		return fmt.Errorf("%w: ttl_seconds must not be negative", ErrInvalidAPIKey)
+	}
+	return nil
+}
@@ -11,13 +11,36 @@ type BatchFilter struct {
     MinSuccess   *int        `json:"min_success,omitempty"`    // Filter by minimum successful jobs
     MaxFailures  *int        `json:"max_failures,omitempty"`   // Filter by maximum failed jobs
     JobIDs       []string    `json:"job_ids,omitempty"`       // Filter by specific job IDs
+    CreatedBy    string      `json:"created_by,omitempty"`    // Filter by the principal that ran the batch
+    Tenant       string      `json:"tenant,omitempty"`        // Filter by the tenant of the principal that ran the batch
 }
 
 // BatchOperation represents a batch action request
 type BatchOperation struct {
-    JobIDs     []string    `json:"job_ids"`
-    Action     BatchAction `json:"action"`
-    SourceURLs []string    `json:"source_urls,omitempty"`
+    JobIDs     []string     `json:"job_ids"`
+    Action     BatchAction  `json:"action"`
+    SourceURLs []string     `json:"source_urls,omitempty"`
+    Source     *BatchSource `json:"source,omitempty"`
+    Dedupe     bool         `json:"dedupe,omitempty"` // Merge duplicate source URLs instead of rejecting them
+    Metadata   map[string]string `json:"metadata,omitempty"` // Applied to every job the start action creates
+    Engine     *EngineParams     `json:"engine,omitempty"`   // Engine parameters for every job the start action creates
+    Outputs    []OutputProfile   `json:"outputs,omitempty"`  // Output profiles for every job the start action creates
+    Transcode  *TranscodeParams  `json:"transcode,omitempty"` // Transcoding for every job the start action creates
+    TranscodeProfile string      `json:"transcode_profile,omitempty"` // Operator transcode profile for every job the start action creates
+    Watermark   *Watermark       `json:"watermark,omitempty"`    // Watermark for every job the start action creates
+    ScheduledAt *time.Time       `json:"scheduled_at,omitempty"` // When every job the start action creates is queued
+    Destination *OutputDestination `json:"destination,omitempty"` // Where every job the start action creates writes its outputs
+    CopyTo      []OutputDestination `json:"copy_to,omitempty"`    // Where every job the start action creates copies its outputs
+}
+
+// BatchSource describes a location whose objects are expanded into one job each.
+// Exactly one of Bucket or Directory must be set.
+type BatchSource struct {
+    Bucket    string   `json:"bucket,omitempty"`    // S3 bucket to list
+    Prefix    string   `json:"prefix,omitempty"`    // Key (or sub-path) prefix to list under
+    Directory string   `json:"directory,omitempty"` // Local directory to list
+    Include   []string `json:"include,omitempty"`   // Glob patterns an object must match
+    Exclude   []string `json:"exclude,omitempty"`   // Glob patterns that drop an object
 }
 
 type BatchAction string
@@ -28,29 +51,62 @@ const (
     BatchActionResume BatchAction = "resume"
     BatchActionStop   BatchAction = "stop"
     BatchActionRetry  BatchAction = "retry"
+    BatchActionRollback BatchAction = "rollback"
 )
 
+// BatchRollbackRequest represents a request to roll back a start batch
+type BatchRollbackRequest struct {
+    DeleteOutputs bool `json:"delete_outputs"` // Also delete the outputs written by the batch's jobs
+}
+
 // BatchResult represents the outcome of a batch operation
 type BatchResult struct {
     BatchID    string         `json:"batch_id"`
+    ParentBatchID string      `json:"parent_batch_id,omitempty"` // Batch this operation was applied to (rollback)
+    CreatedBy  string         `json:"created_by,omitempty"`      // Principal that ran the batch
+    Tenant     string         `json:"tenant,omitempty"`          // Tenant of the principal that ran the batch
     StartTime  time.Time      `json:"start_time"`
     EndTime    time.Time      `json:"end_time"`
     Action     BatchAction    `json:"action"`
     Successful []string       `json:"successful"`
     Failed     []BatchJobError `json:"failed"`
+    Duplicates []BatchDuplicate `json:"duplicates,omitempty"`
     Summary    BatchSummary   `json:"summary"`
 }
 
+// BatchDuplicate records a source URL that appeared more than once in a start
+// batch and was merged into a single job
+type BatchDuplicate struct {
+    SourceURL   string `json:"source_url"`
+    Occurrences int    `json:"occurrences"`
+}
+
 type BatchJobError struct {
     JobID string `json:"job_id"`
     Error string `json:"error"`
 }
 
+// BatchJobReport is the per-job outcome of a batch, used for exports
+type BatchJobReport struct {
+    JobID           string           `json:"job_id"`
+    Outcome         string           `json:"outcome"` // "success" or "failed" within the batch
+    Status          EncryptionStatus `json:"status,omitempty"`
+    Error           string           `json:"error,omitempty"`
+    Duration        Duration         `json:"duration"` // From creation to the job's last update
+    OutputPath      string           `json:"output_path,omitempty"`
+}
+
+// Batch job outcomes
+const (
+    BatchOutcomeSuccess = "success"
+    BatchOutcomeFailed  = "failed"
+)
+
 type BatchSummary struct {
     TotalJobs    int           `json:"total_jobs"`
     SuccessCount int           `json:"success_count"`
     FailureCount int           `json:"failure_count"`
-    Duration     time.Duration `json:"duration"`
+    Duration     Duration      `json:"duration"`
 }
 
 // JobHistoryEntry represents a single history entry for a job
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCustomerKey is returned for customer keys that are malformed, do
+// not fit the job, or cannot be used by the configured key store
+var ErrInvalidCustomerKey = errors.New("invalid customer key")
+
+// Where the key of a job comes from
+const (
+	KeySourceGenerated   = "generated"        // Generated by the worker and sealed by the service's key store
+	KeySourceCustomer    = "customer_wrapped" // Supplied by the caller, wrapped with KMS
+	KeySourceCustomerKMS = "customer_kms"     // Generated by the worker and sealed under the caller's KMS key
+)
+
+// maxKMSKeyIDLength is the longest KMS key ID, ARN or alias KMS accepts
+const maxKMSKeyIDLength = 2048
+
+// CustomerKey is a caller's own key for a job: either the key itself, wrapped
+// with KMS so only the service's key store can unwrap it, or the caller's KMS
+// key the generated key is sealed under. Neither is ever stored unwrapped.
+type CustomerKey struct {
+	WrappedKey string `json:"wrapped_key,omitempty"` // Base64 KMS ciphertext of the raw key, encrypted without encryption context
+	KMSKeyID   string `json:"kms_key_id,omitempty"`  // Key ID, ARN or alias of the caller's KMS key
+}
+
+// Validate checks that exactly one of the wrapped key and the KMS key is set
+func (k *CustomerKey) Validate() error {
+	switch {
+	case k.WrappedKey != "" && k.KMSKeyID != "":
+		return fmt.Errorf("%w: set either wrapped_key or kms_key_id", ErrInvalidCustomerKey)
+	case k.WrappedKey != "":
+		if _, err := base64.StdEncoding.DecodeString(k.WrappedKey); err != nil {
+			return fmt.Errorf("%w: wrapped_key must be base64 encoded", ErrInvalidCustomerKey)
+		}
+	case k.KMSKeyID != "":
+		if len(k.KMSKeyID) > maxKMSKeyIDLength || strings.ContainsAny(k.KMSKeyID, " \t\r\n") {
+			return fmt.Errorf("%w: kms_key_id must be a KMS key ID, ARN or alias", ErrInvalidCustomerKey)
+		}
+	default:
+		return fmt.Errorf("%w: wrapped_key or kms_key_id is required", ErrInvalidCustomerKey)
+	}
+	return nil
+}
+
+// Source returns the key source of jobs using the customer key
+func (k *CustomerKey) Source() string {
+	if k.WrappedKey != "" {
+		return KeySourceCustomer
+	}
+	return KeySourceCustomerKMS
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of jobs. Jobs stored before decryption jobs existed have no kind and
+// are encryption jobs.
+const (
+	JobKindEncrypt = "encrypt"
+	JobKindDecrypt = "decrypt"
+	JobKindRotate  = "rotate" // Re-encrypts another job's output under a new key
+)
+
+// ErrKeyUnavailable is returned when the key of an encryption job cannot be
+// used for decryption, e.g. because the job has not completed or its key is
+// held outside the service
+var ErrKeyUnavailable = errors.New("key unavailable")
+
+// DecryptionRequest asks for an encrypted object to be decrypted with the key
+// of the encryption job that produced it
+type DecryptionRequest struct {
+	JobID     string            `json:"job_id"`               // Encryption job whose key is used, and whose output is decrypted unless source_url is set
+	Output    string            `json:"output,omitempty"`     // Output of a multi-output job; the primary output when empty
+	SourceURL string            `json:"source_url,omitempty"` // Encrypted object to decrypt instead of the job's output, e.g. a copy moved elsewhere
+	KeyRef    string            `json:"key_ref"`              // The key_ref of the job's result, confirming which key is meant
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	RetryOf   string            `json:"-"` // Failed decryption job the job runs again
+}
+
+// Validate checks that the request names a job and a key. It returns
+// ValidationErrors; metadata is checked when the job is created.
+func (r DecryptionRequest) Validate() error {
+	var errs ValidationErrors
+	if r.JobID == "" {
+		errs = append(errs, BatchValidationError{Field: "job_id", Message: "job_id is required"})
+	}
+	if r.KeyRef == "" {
+		errs = append(errs, BatchValidationError{Field: "key_ref", Message: "key_ref is required"})
+	}
+	if r.SourceURL != "" {
+		if err := ValidateSourceURL(r.SourceURL); err != nil {
+			errs = append(errs, BatchValidationError{Field: "source_url", Message: err.Error(), Value: r.SourceURL})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Decryption describes what a decryption job decrypts. The key stays on the
+// encryption job and is read by the worker when the job runs.
+type Decryption struct {
+	KeyJobID string `json:"key_job_id"`       // Encryption job holding the key
+	Output   string `json:"output,omitempty"` // Output of KeyJobID whose key is used
+	KeyRef   string `json:"key_ref"`
+}
+
+// IsDecryption reports whether the job decrypts rather than encrypts
+func (j *EncryptionJob) IsDecryption() bool {
+	return j.Kind == JobKindDecrypt
+}
+
+// DecryptionSource returns the result and stored key of the output of a
+// completed encryption job that a decryption job uses; output names one output
+// of a multi-output job, or is empty for the primary output
+func (j *EncryptionJob) DecryptionSource(output string) (*JobResult, StoredKey, error) {
+	if j.IsDecryption() {
+		return nil, StoredKey{}, fmt.Errorf("%w: job %s is a decryption job", ErrKeyUnavailable, j.ID)
+	}
+	if j.Status != StatusCompleted {
+		return nil, StoredKey{}, fmt.Errorf("%w: job %s has not completed", ErrKeyUnavailable, j.ID)
+	}
+
+	result, key := j.Result, j.StoredKey()
+	if output != "" {
+		out, err := j.Output(output)
+		if err != nil {
+			return nil, StoredKey{}, err
+		}
+		result, key = out.Result, out.StoredKey()
+	}
+	if result == nil || key.IsZero() {
+		// Imported jobs refer to keys held by another system
+		return nil, StoredKey{}, fmt.Errorf("%w: the key of job %s is not held by the service", ErrKeyUnavailable, j.ID)
+	}
+	if result.DRM != "" {
+		return nil, StoredKey{}, fmt.Errorf("%w: job %s has DRM packaged renditions, which players decrypt", ErrKeyUnavailable, j.ID)
+	}
+	return result, key, nil
+}
@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Storage backends outputs may be written to
+const (
+	DestinationS3    = "s3"    // A bucket, under an optional key prefix
+	DestinationLocal = "local" // A directory inside the working storage
+)
+
+var (
+	// ErrInvalidDestination is returned for destinations that are malformed
+	// or that the operator does not allow
+	ErrInvalidDestination = errors.New("invalid destination")
+
+	// ErrDestinationUnwritable is returned for destinations outputs cannot be
+	// written to
+	ErrDestinationUnwritable = errors.New("destination is not writable")
+)
+
+// MaxCopyDestinations is how many destinations a job's outputs may be copied to
+const MaxCopyDestinations = 4
+
+// bucketName matches the S3 bucket naming rules
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// OutputDestination is where a job's outputs are written in place of the
+// service's output storage
+type OutputDestination struct {
+	Storage string `json:"storage"`          // s3 or local
+	Bucket  string `json:"bucket,omitempty"` // Required for s3
+	Prefix  string `json:"prefix,omitempty"` // Key prefix, or directory for local
+}
+
+// Validate checks that the destination names a storage backend and a
+// location it can hold
+func (d *OutputDestination) Validate() error {
+	switch d.Storage {
+	case DestinationS3:
+		if !bucketName.MatchString(d.Bucket) {
+			return fmt.Errorf("%w: bucket %q is not a valid S3 bucket name", ErrInvalidDestination, d.Bucket)
+		}
+	case DestinationLocal:
+		if d.Bucket != "" {
+			return fmt.Errorf("%w: local destinations take no bucket", ErrInvalidDestination)
+		}
+	default:
+		return fmt.Errorf("%w: storage must be %s or %s", ErrInvalidDestination, DestinationS3, DestinationLocal)
+	}
+	if d.Prefix != "" {
+		prefix := path.Clean(strings.Trim(d.Prefix, "/"))
+		if prefix == ".." || strings.HasPrefix(prefix, "../") || strings.ContainsRune(d.Prefix, '\\') {
+			return fmt.Errorf("%w: prefix %q must stay inside the destination", ErrInvalidDestination, d.Prefix)
+		}
+	}
+	return nil
+}
+
+// String returns the destination as a URL, s3://bucket/prefix or
+// local:prefix
+func (d OutputDestination) String() string {
+	prefix := strings.Trim(d.Prefix, "/")
+	if d.Storage == DestinationS3 {
+		return strings.TrimSuffix("s3://"+d.Bucket+"/"+prefix, "/")
+	}
+	return d.Storage + ":" + prefix
+}
+
+// ParseDestination parses a destination written as s3://bucket/prefix or
+// local:prefix
+func ParseDestination(s string) (*OutputDestination, error) {
+	var d OutputDestination
+	if rest, ok := strings.CutPrefix(s, "s3://"); ok {
+		d.Storage = DestinationS3
+		d.Bucket, d.Prefix, _ = strings.Cut(rest, "/")
+	} else if rest, ok := strings.CutPrefix(s, DestinationLocal+":"); ok {
+		d.Storage = DestinationLocal
+		d.Prefix = rest
+	} else {
+		return nil, fmt.Errorf("%w: %q must be s3://bucket/prefix or local:prefix", ErrInvalidDestination, s)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DestinationPolicy is which destinations jobs may name
+type DestinationPolicy struct {
+	Buckets []string // S3 buckets outputs may be written to
+	Local   bool     // Whether directories inside the working storage are allowed
+}
+
+// Check returns an error wrapping ErrInvalidDestination if d is not allowed
+func (p DestinationPolicy) Check(d *OutputDestination) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if d.Storage == DestinationLocal {
+		if !p.Local {
+			return fmt.Errorf("%w: local destinations are not allowed", ErrInvalidDestination)
+		}
+		return nil
+	}
+	for _, bucket := range p.Buckets {
+		if bucket == d.Bucket {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: bucket %q is not one outputs may be written to", ErrInvalidDestination, d.Bucket)
+}
+
+// DestinationCopy reports the copy of a job's outputs to one of the
+// destinations it names in copy_to
+type DestinationCopy struct {
+	Destination OutputDestination `json:"destination"`
+	Status      EncryptionStatus  `json:"status"`          // COMPLETED, or FAILED if any output was not copied
+	URLs        []string          `json:"urls,omitempty"`  // Location of each copied output
+	Error       string            `json:"error,omitempty"` // Why the copy failed
+	CopiedAt    int64             `json:"copied_at"`
+}
+
+// ValidateCopyDestinations checks the destinations a job's outputs are copied
+// to besides primary: at most MaxCopyDestinations, each valid, and each named
+// once
+func ValidateCopyDestinations(primary *OutputDestination, copies []OutputDestination) error {
+	if len(copies) > MaxCopyDestinations {
+		return fmt.Errorf("%w: outputs may be copied to at most %d destinations, got %d", ErrInvalidDestination, MaxCopyDestinations, len(copies))
+	}
+	seen := make(map[string]bool, len(copies)+1)
+	if primary != nil {
+		seen[primary.String()] = true
+	}
+	for i := range copies {
+		if err := copies[i].Validate(); err != nil {
+			return err
+		}
+		name := copies[i].String()
+		if seen[name] {
+			return fmt.Errorf("%w: %s is named more than once", ErrInvalidDestination, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
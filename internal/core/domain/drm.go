@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// DRM protection schemes transcoded renditions may be packaged with. CBCS,
+// which FairPlay needs, is not offered: ffmpeg cannot write it.
+const (
+	DRMSchemeCENC = "cenc" // MPEG Common Encryption with AES-128 in CTR mode
+)
+
+// SupportedDRMSchemes lists every scheme renditions can be protected with
+var SupportedDRMSchemes = []string{DRMSchemeCENC}
+
+// drmAlgorithms name the cipher of each scheme in job results
+var drmAlgorithms = map[string]string{
+	DRMSchemeCENC: "AES-128-CTR",
+}
+
+// DRMKeySize is the length in bytes of the content keys of protected
+// renditions; every scheme uses AES-128
+const DRMKeySize = 16
+
+// DRMKey is the content key protected renditions are encrypted with, and the
+// key ID written into them. The key ID is KeyID(key), so the key server
+// delivers the key under the ID players and license servers look it up by.
+type DRMKey struct {
+	Key   []byte
+	KeyID []byte
+}
+
+// GenerateDRMKey returns a new random content key for protected renditions,
+// hex encoded like the keys of encrypted outputs
+func GenerateDRMKey() (string, error) {
+	key := make([]byte, DRMKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate DRM key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// NewDRMKey returns the DRM key of a hex encoded content key
+func NewDRMKey(key string) (*DRMKey, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil || len(raw) != DRMKeySize {
+		return nil, errors.New("DRM key must be 16 hex encoded bytes")
+	}
+	return &DRMKey{Key: raw, KeyID: KeyID(key)}, nil
+}
+
+// DRMAlgorithm returns the cipher of a protection scheme, as recorded in job
+// results
+func DRMAlgorithm(scheme string) string {
+	return drmAlgorithms[scheme]
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that marshals to JSON as milliseconds plus a
+// human-readable string, e.g. {"duration_ms": 1500, "human": "1.5s"}, instead
+// of raw nanoseconds. Every duration in API responses uses it.
+type Duration time.Duration
+
+type durationJSON struct {
+	Milliseconds float64 `json:"duration_ms"`
+	Human        string  `json:"human"`
+}
+
+// Std returns the duration as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(durationJSON{
+		Milliseconds: float64(d) / float64(time.Millisecond),
+		Human:        d.String(),
+	})
+}
+
+// UnmarshalJSON accepts the object form, a duration string such as "1.5s",
+// or a bare number of nanoseconds as stored before durations were objects
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var ns int64
+	if err := json.Unmarshal(data, &ns); err == nil {
+		*d = Duration(ns)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var v durationJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	// The human form is exact; milliseconds may have lost precision
+	if parsed, err := time.ParseDuration(v.Human); err == nil {
+		*d = Duration(parsed)
+		return nil
+	}
+	*d = Duration(v.Milliseconds * float64(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,240 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ciphers the encryption engine can seal chunks with
+const (
+	AlgorithmAES256GCM        = "AES-256-GCM"
+	AlgorithmAES128GCM        = "AES-128-GCM"
+	AlgorithmChaCha20Poly1305 = "CHACHA20-POLY1305"
+)
+
+// algorithmKeyLengths are the key lengths, in bits, of the ciphers
+var algorithmKeyLengths = map[string]int{
+	AlgorithmAES256GCM:        256,
+	AlgorithmAES128GCM:        128,
+	AlgorithmChaCha20Poly1305: 256,
+}
+
+// Nonce strategies for sealed chunks
+const (
+	IVStrategyCounter = "counter" // Random per-stream prefix followed by the chunk counter
+	IVStrategyRandom  = "random"  // Fresh random nonce per chunk, stored with the chunk
+)
+
+// Containers an encrypted output may be written in
+const (
+	ContainerStream = "stream" // The engine's chunked binary stream
+	ContainerBase64 = "base64" // The same stream base64 encoded, for transports that only carry text
+)
+
+// MaxChunkSize caps the chunk size operators may allow, since workers hold a
+// whole chunk in memory
+const MaxChunkSize = 64 << 20
+
+// SupportedAlgorithms, SupportedIVStrategies and SupportedContainers list
+// every value the engine implements; operators may allow a subset
+var (
+	SupportedAlgorithms   = []string{AlgorithmAES256GCM, AlgorithmAES128GCM, AlgorithmChaCha20Poly1305}
+	SupportedIVStrategies = []string{IVStrategyCounter, IVStrategyRandom}
+	SupportedContainers   = []string{ContainerStream, ContainerBase64}
+)
+
+// DefaultEngineParams are used for jobs created before parameters were
+// recorded, and for any parameter neither the caller nor the operator set
+var DefaultEngineParams = EngineParams{
+	Algorithm:  AlgorithmAES256GCM,
+	KeyLength:  256,
+	ChunkSize:  1 << 20,
+	IVStrategy: IVStrategyCounter,
+	Container:  ContainerStream,
+}
+
+// ErrInvalidEngineParams is returned for engine parameters outside the
+// operator-configured bounds
+var ErrInvalidEngineParams = fmt.Errorf("invalid engine parameters")
+
+// EngineParams selects how a job's source is encrypted. Requests may leave
+// any field empty to use the operator's default.
+type EngineParams struct {
+	Algorithm  string `json:"algorithm,omitempty"`
+	KeyLength  int    `json:"key_length,omitempty"` // Key bits; set by the algorithm, or picks an algorithm with keys this long
+	ChunkSize  int    `json:"chunk_size,omitempty"` // Plaintext bytes per sealed chunk
+	IVStrategy string `json:"iv_strategy,omitempty"`
+	Container  string `json:"container,omitempty"` // How the encrypted output is written
+}
+
+// AlgorithmKeyLength returns the key length in bits of a supported
+// algorithm, or 0 for others
+func AlgorithmKeyLength(algorithm string) int {
+	if canonical, ok := lookupFold(SupportedAlgorithms, algorithm); ok {
+		return algorithmKeyLengths[canonical]
+	}
+	return 0
+}
+
+// WithDefaults fills unset fields from DefaultEngineParams
+func (p EngineParams) WithDefaults() EngineParams {
+	if p.Algorithm == "" {
+		p.Algorithm = DefaultEngineParams.Algorithm
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = AlgorithmKeyLength(p.Algorithm)
+	}
+	if p.ChunkSize == 0 {
+		p.ChunkSize = DefaultEngineParams.ChunkSize
+	}
+	if p.IVStrategy == "" {
+		p.IVStrategy = DefaultEngineParams.IVStrategy
+	}
+	if p.Container == "" {
+		p.Container = DefaultEngineParams.Container
+	}
+	return p
+}
+
+// EngineLimits are the operator-configured bounds on per-job engine parameters
+type EngineLimits struct {
+	Defaults     EngineParams
+	Algorithms   []string
+	IVStrategies []string
+	Containers   []string
+	MinChunkSize int
+	MaxChunkSize int
+}
+
+// Validate checks that the limits only allow parameters the engine
+// implements and that the defaults are within them
+func (l EngineLimits) Validate() error {
+	for _, algorithm := range l.Algorithms {
+		if _, ok := lookupFold(SupportedAlgorithms, algorithm); !ok {
+			return fmt.Errorf("unsupported algorithm %q (supported: %s)", algorithm, strings.Join(SupportedAlgorithms, ", "))
+		}
+	}
+	for _, strategy := range l.IVStrategies {
+		if _, ok := lookupFold(SupportedIVStrategies, strategy); !ok {
+			return fmt.Errorf("unsupported IV strategy %q (supported: %s)", strategy, strings.Join(SupportedIVStrategies, ", "))
+		}
+	}
+	for _, container := range l.Containers {
+		if _, ok := lookupFold(SupportedContainers, container); !ok {
+			return fmt.Errorf("unsupported container %q (supported: %s)", container, strings.Join(SupportedContainers, ", "))
+		}
+	}
+	if l.MinChunkSize <= 0 || l.MaxChunkSize > MaxChunkSize || l.MinChunkSize > l.MaxChunkSize {
+		return fmt.Errorf("chunk size bounds must be between 1 and %d bytes", MaxChunkSize)
+	}
+	if _, err := l.Resolve(&l.Defaults); err != nil {
+		return fmt.Errorf("default engine parameters: %w", err)
+	}
+	return nil
+}
+
+// Resolve validates requested parameters against the limits and fills unset
+// fields from the defaults. A nil request resolves to the defaults.
+func (l EngineLimits) Resolve(requested *EngineParams) (EngineParams, error) {
+	params := l.Defaults.WithDefaults()
+	if requested == nil {
+		return params.canonical(), nil
+	}
+
+	if requested.Algorithm != "" {
+		if _, ok := lookupFold(l.Algorithms, requested.Algorithm); !ok {
+			return EngineParams{}, fmt.Errorf("%w: algorithm must be one of %s", ErrInvalidEngineParams, strings.Join(l.Algorithms, ", "))
+		}
+		params.Algorithm = requested.Algorithm
+	}
+	if requested.KeyLength != 0 {
+		algorithm, err := l.algorithmFor(requested.KeyLength, requested.Algorithm != "", params.Algorithm)
+		if err != nil {
+			return EngineParams{}, err
+		}
+		params.Algorithm = algorithm
+		params.KeyLength = requested.KeyLength
+	}
+	if requested.IVStrategy != "" {
+		if _, ok := lookupFold(l.IVStrategies, requested.IVStrategy); !ok {
+			return EngineParams{}, fmt.Errorf("%w: iv_strategy must be one of %s", ErrInvalidEngineParams, strings.Join(l.IVStrategies, ", "))
+		}
+		params.IVStrategy = requested.IVStrategy
+	}
+	if requested.ChunkSize != 0 {
+		if requested.ChunkSize < l.MinChunkSize || requested.ChunkSize > l.MaxChunkSize {
+			return EngineParams{}, fmt.Errorf("%w: chunk_size must be between %d and %d bytes", ErrInvalidEngineParams, l.MinChunkSize, l.MaxChunkSize)
+		}
+		params.ChunkSize = requested.ChunkSize
+	}
+	if requested.Container != "" {
+		if _, ok := lookupFold(l.Containers, requested.Container); !ok {
+			return EngineParams{}, fmt.Errorf("%w: container must be one of %s", ErrInvalidEngineParams, strings.Join(l.Containers, ", "))
+		}
+		params.Container = requested.Container
+	}
+	return params.canonical(), nil
+}
+
+// algorithmFor returns the algorithm a request for keys of keyLength bits is
+// encrypted with: algorithm itself when the request named it, else algorithm
+// if its keys are that long, else the first allowed one whose keys are
+func (l EngineLimits) algorithmFor(keyLength int, named bool, algorithm string) (string, error) {
+	if AlgorithmKeyLength(algorithm) == keyLength {
+		return algorithm, nil
+	}
+	if named {
+		return "", fmt.Errorf("%w: %s uses %d-bit keys, not %d", ErrInvalidEngineParams, algorithm, AlgorithmKeyLength(algorithm), keyLength)
+	}
+	for _, allowed := range l.Algorithms {
+		if AlgorithmKeyLength(allowed) == keyLength {
+			return allowed, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no allowed algorithm uses %d-bit keys", ErrInvalidEngineParams, keyLength)
+}
+
+// canonical spells the algorithm, IV strategy and container the way the
+// engine names them, and sets the key length of the algorithm
+func (p EngineParams) canonical() EngineParams {
+	if algorithm, ok := lookupFold(SupportedAlgorithms, p.Algorithm); ok {
+		p.Algorithm = algorithm
+	}
+	p.KeyLength = AlgorithmKeyLength(p.Algorithm)
+	if strategy, ok := lookupFold(SupportedIVStrategies, p.IVStrategy); ok {
+		p.IVStrategy = strategy
+	}
+	if container, ok := lookupFold(SupportedContainers, p.Container); ok {
+		p.Container = container
+	}
+	return p
+}
+
+// lookupFold returns the entry of values equal to name ignoring case
+func lookupFold(values []string, name string) (string, bool) {
+	for _, v := range values {
+		if strings.EqualFold(v, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// JobOptions are the caller's choices for a new job beyond its source
+type JobOptions struct {
+	Metadata         map[string]string
+	Engine           *EngineParams       // Nil uses the operator's defaults
+	Outputs          []OutputProfile     // Several outputs instead of one; exclusive with Engine
+	Transcode        *TranscodeParams    // Nil encrypts the source as it is
+	TranscodeProfile string              // Operator profile to transcode with when Transcode is nil; otherwise recorded as Transcode's origin
+	Watermark        *Watermark          // Nil leaves the source's video as it is
+	ScheduledAt      time.Time           // Queue the job at this time; zero or past queues it at once
+	SourceHash       string              // Digest of the source content; checked by the worker when it reads the source
+	Reuse            bool                // Return a completed job with the same source hash and parameters instead
+	CustomerKey      *CustomerKey        // The caller's own key; nil generates one
+	Upload           string              // Storage path of an uploaded source, deleted once the job ends
+	Destination      *OutputDestination  // Where the outputs are written; nil for the output storage
+	CopyTo           []OutputDestination // Further destinations the outputs are copied to
+	RetryOf          string              // Failed job the job runs again
+}
@@ -75,6 +75,24 @@ const (
     ErrCodeInvalidState    = "invalid_state"
     ErrCodeInvalidAction   = "invalid_action"
     ErrCodeEncryptionFailed = "encryption_failed"
+    ErrCodeUnavailable     = "service_unavailable"
+    ErrCodeUnsupportedMedia = "unsupported_media"
+    ErrCodeTranscodeFailed = "transcode_failed"
+    ErrCodeWatermarkFailed = "watermark_failed"
+    ErrCodeRequestTooLarge = "request_too_large"
+    ErrCodeKeyUnavailable  = "key_unavailable"
+    ErrCodeQuotaExceeded   = "quota_exceeded"
+    ErrCodeWorkerLost      = "worker_lost"
+    ErrCodeJobStuck        = "job_stuck"
+    ErrCodeSourceHashMismatch = "source_hash_mismatch"
+    ErrCodeRotationMismatch   = "rotation_mismatch"
+    ErrCodeSourceRejected     = "source_rejected"
+    ErrCodeUploadOffset       = "upload_offset_mismatch"
+    ErrCodeUploadLocked       = "upload_locked"
+    ErrCodePrecondition       = "precondition_failed"
+    ErrCodeUnsupportedType    = "unsupported_content_type"
+    ErrCodeDestinationUnwritable = "destination_unwritable"
+    ErrCodeInsufficientSpace  = "insufficient_space"
 )
 
 // HTTP Status codes
@@ -86,6 +104,11 @@ const (
     StatusForbidden          = http.StatusForbidden
     StatusNotFound           = http.StatusNotFound
     StatusConflict           = http.StatusConflict
+    StatusPreconditionFailed = http.StatusPreconditionFailed
+    StatusRequestTooLarge    = http.StatusRequestEntityTooLarge
+    StatusUnsupportedMediaType = http.StatusUnsupportedMediaType
+    StatusUnprocessableEntity = http.StatusUnprocessableEntity
+    StatusLocked             = http.StatusLocked
     StatusTooManyRequests    = http.StatusTooManyRequests
     StatusInternalServerError = http.StatusInternalServerError
     StatusServiceUnavailable = http.StatusServiceUnavailable
@@ -108,6 +131,18 @@ var ErrorStatusMap = map[string]int{
     ErrCodeInvalidState:     StatusConflict,
     ErrCodeInvalidAction:    StatusBadRequest,
     ErrCodeEncryptionFailed: StatusInternalServerError,
+    ErrCodeUnavailable:      StatusServiceUnavailable,
+    ErrCodeUnsupportedMedia: StatusUnprocessableEntity,
+    ErrCodeTranscodeFailed:  StatusUnprocessableEntity,
+    ErrCodeRequestTooLarge:  StatusRequestTooLarge,
+    ErrCodeKeyUnavailable:   StatusConflict,
+    ErrCodeQuotaExceeded:    StatusTooManyRequests,
+    ErrCodeSourceRejected:   StatusUnprocessableEntity,
+    ErrCodeUploadOffset:     StatusConflict,
+    ErrCodeUploadLocked:     StatusLocked,
+    ErrCodePrecondition:     StatusPreconditionFailed,
+    ErrCodeUnsupportedType:  StatusUnsupportedMediaType,
+    ErrCodeDestinationUnwritable: StatusUnprocessableEntity,
 }
 
 // NewBatchErrorResponse creates a new BatchErrorResponse
@@ -170,6 +205,16 @@ func NewNotFoundError(resourceType, identifier string) BatchError {
     }
 }
 
+// NewForbiddenError creates a BatchError for resources the caller may not act on
+func NewForbiddenError(resourceType, identifier string) BatchError {
+    return BatchError{
+        Field:   resourceType,
+        Message: fmt.Sprintf("%s belongs to another owner: %s", resourceType, identifier),
+        Value:   identifier,
+        Code:    ErrCodeForbidden,
+    }
+}
+
 // NewEncryptionError creates a BatchError for encryption-related errors
 func NewEncryptionError(message string, details string) BatchError {
     return BatchError{
@@ -292,5 +337,7 @@ var (
     // Common errors
     ErrJobNotFound = fmt.Errorf("job not found")
     ErrBatchNotFound = fmt.Errorf("batch not found")
+    ErrJobResultNotFound = fmt.Errorf("job result not found")
     ErrInvalidJobState = fmt.Errorf("invalid job state")
+    ErrNotAcceptingJobs = fmt.Errorf("service is not accepting new jobs")
 )
\ No newline at end of file
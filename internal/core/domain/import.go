@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrInvalidImport is returned for imported job records that cannot be stored
+var ErrInvalidImport = errors.New("invalid job import")
+
+// Limits of job imports
+const (
+	MaxImportHistory = 1000 // History entries kept per imported job
+	MaxImportErrors  = 100  // Per-line errors reported per import request
+)
+
+var importIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// JobImportRecord is one historical job migrated from another encryption
+// system. Keys are imported by reference only: result.key_ref names the key
+// in the system that holds it, and no key material is accepted.
+type JobImportRecord struct {
+	ID         string            `json:"id"`
+	SourceURL  string            `json:"source_url"`
+	Status     EncryptionStatus  `json:"status"`
+	CreatedAt  int64             `json:"created_at"`
+	UpdatedAt  int64             `json:"updated_at,omitempty"` // Defaults to created_at
+	CreatedBy  string            `json:"created_by,omitempty"` // Defaults to the importing principal
+	OutputPath string            `json:"output_path,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Engine     EngineParams      `json:"engine"`
+	Result     *JobResult        `json:"result,omitempty"`
+	History    []JobHistoryEntry `json:"history,omitempty"` // Oldest first
+}
+
+// Validate checks that the record describes a finished job
+func (r JobImportRecord) Validate() error {
+	if !importIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("%w: id must be 1-128 letters, digits, '.', '_', ':' or '-'", ErrInvalidImport)
+	}
+	if r.SourceURL == "" {
+		return fmt.Errorf("%w: source_url is required", ErrInvalidImport)
+	}
+	// Jobs that never finished would sit in the store without a worker
+	switch r.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled:
+	default:
+		return fmt.Errorf("%w: status must be %s, %s or %s, got %q", ErrInvalidImport, StatusCompleted, StatusFailed, StatusCancelled, r.Status)
+	}
+	if r.CreatedAt <= 0 || (r.UpdatedAt != 0 && r.UpdatedAt < r.CreatedAt) {
+		return fmt.Errorf("%w: created_at is required and updated_at must not precede it", ErrInvalidImport)
+	}
+	if r.Status == StatusCompleted && (r.Result == nil || r.Result.KeyRef == "") {
+		return fmt.Errorf("%w: completed jobs need a result with a key_ref", ErrInvalidImport)
+	}
+	if len(r.History) > MaxImportHistory {
+		return fmt.Errorf("%w: at most %d history entries are allowed", ErrInvalidImport, MaxImportHistory)
+	}
+	if err := ValidateMetadata(r.Metadata); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	return nil
+}
+
+// Job builds the job a record is stored as, imported at now. Its history is
+// the record's followed by an entry recording the import.
+func (r JobImportRecord) Job(importedBy string, now time.Time) *EncryptionJob {
+	job := &EncryptionJob{
+		ID:         r.ID,
+		SourceURL:  r.SourceURL,
+		Status:     r.Status,
+		OutputPath: r.OutputPath,
+		Error:      r.Error,
+		ErrorCode:  r.ErrorCode,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		CreatedBy:  r.CreatedBy,
+		Metadata:   r.Metadata,
+		Engine:     r.Engine,
+		Result:     r.Result,
+		ImportedAt: now.Unix(),
+	}
+	if job.UpdatedAt == 0 {
+		job.UpdatedAt = job.CreatedAt
+	}
+	if job.CreatedBy == "" {
+		job.CreatedBy = importedBy
+	}
+	if job.Status == StatusCompleted {
+		job.Progress = Progress{Percent: 100, Stage: StageDone}
+	}
+
+	job.pendingHistory = append(job.pendingHistory, r.History...)
+	job.pendingHistory = append(job.pendingHistory, JobHistoryEntry{
+		Timestamp: now,
+		Action:    "import",
+		Status:    string(r.Status),
+		Details:   map[string]interface{}{"imported_by": importedBy},
+	})
+	return job
+}
+
+// ImportOptions control a job import
+type ImportOptions struct {
+	DryRun bool // Validate without storing anything
+}
+
+// ImportLineError reports a record that was not imported
+type ImportLineError struct {
+	Line    int    `json:"line"`
+	JobID   string `json:"job_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult summarizes a job import
+type ImportResult struct {
+	Imported  int               `json:"imported"`
+	Skipped   int               `json:"skipped"` // Jobs that already exist are left alone, so imports can be rerun
+	Failed    int               `json:"failed"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+	Errors    []ImportLineError `json:"errors,omitempty"` // The first MaxImportErrors failures
+	Truncated bool              `json:"errors_truncated,omitempty"`
+}
+
+// AddError records a failed line, keeping the first MaxImportErrors
+func (r *ImportResult) AddError(line int, jobID string, err error) {
+	r.Failed++
+	if len(r.Errors) >= MaxImportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportLineError{Line: line, JobID: jobID, Message: err.Error()})
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// ObjectEvent reports a new object at a source location, such as an upload
+// to an S3 bucket
+type ObjectEvent struct {
+	Location  string // Bucket holding the object
+	Key       string // Object key within Location
+	SourceURL string // URL a job reads the object from
+	Size      int64
+	ETag      string // Identifies the object's content; an overwrite changes it
+	Time      time.Time
+}
+
+// DedupeKey identifies the object version an event reports, so the same
+// upload reported twice creates one job while an overwrite creates another
+func (e ObjectEvent) DedupeKey() string {
+	return e.SourceURL + "@" + e.ETag
+}
+
+// ObjectNotification is one message of an object event source. It is
+// delivered again until it is acknowledged.
+type ObjectNotification struct {
+	ID       string
+	Receipt  string // Handle used to acknowledge or retry this delivery
+	Receives int    // Times the message has been delivered, including this one
+	Events   []ObjectEvent
+}
+
+// IngestRule selects the objects that are encrypted automatically: those in
+// Location whose key starts with Prefix
+type IngestRule struct {
+	Location string
+	Prefix   string
+}
+
+// Matches reports whether the rule selects the event's object
+func (r IngestRule) Matches(e ObjectEvent) bool {
+	return r.Location == e.Location && strings.HasPrefix(e.Key, r.Prefix)
+}
@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidKeyPolicy is returned for malformed key access policies
+	ErrInvalidKeyPolicy = errors.New("invalid key policy")
+	// ErrKeyTokenInvalid is returned for key tokens that are malformed,
+	// forged or expired
+	ErrKeyTokenInvalid = errors.New("invalid key token")
+	// ErrKeyDenied is returned when a key's access policy refuses a delivery
+	ErrKeyDenied = errors.New("key delivery denied")
+)
+
+// Key audit actions
+const (
+	KeyAuditPolicyUpdated = "policy_updated"
+	KeyAuditTokenIssued   = "token_issued"
+	KeyAuditDelivered     = "key_delivered"
+	KeyAuditDenied        = "key_denied"
+	KeyAuditRetrieved     = "key_retrieved" // By a job owner through the API
+	KeyAuditRetired       = "key_retired"   // Replaced by a key rotation job
+)
+
+// MaxKeyClients limits how many clients a key policy may name
+const MaxKeyClients = 32
+
+// ContentKey names the key of a completed job, or of one output of a
+// multi-output job
+type ContentKey struct {
+	JobID  string `json:"job_id"`
+	Output string `json:"output,omitempty"` // Empty for the job's primary key
+}
+
+// String identifies the key in storage and audit records
+func (k ContentKey) String() string {
+	if k.Output == "" {
+		return k.JobID
+	}
+	return k.JobID + "/" + k.Output
+}
+
+// KeyID returns the 16-byte key ID players look a key up by, derived from the
+// key the same way as the key_ref of job results, which is its prefix
+func KeyID(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:16]
+}
+
+// KeyPolicy controls which players and packagers may be delivered a content
+// key. A key without a policy is delivered to any holder of a valid token.
+type KeyPolicy struct {
+	Key           ContentKey `json:"key"`
+	Clients       []string   `json:"clients,omitempty"`        // Client IDs tokens may be issued to; empty allows any
+	NotBefore     int64      `json:"not_before,omitempty"`     // Unix time before which the key is not delivered
+	NotAfter      int64      `json:"not_after,omitempty"`      // Unix time after which the key is not delivered
+	MaxDeliveries int        `json:"max_deliveries,omitempty"` // 0 for no limit
+	MaxTokenTTL   Duration   `json:"max_token_ttl,omitempty"`  // Caps the lifetime of issued tokens; 0 uses the server's limit
+	Disabled      bool       `json:"disabled,omitempty"`       // Stops every delivery, e.g. after a leak
+	RetiredAt     int64      `json:"retired_at,omitempty"`     // Unix time the key was replaced by a key rotation; stops every delivery
+	ReplacedBy    string     `json:"replaced_by,omitempty"`    // Key rotation job holding the new key
+	UpdatedBy     string     `json:"updated_by,omitempty"`
+	UpdatedAt     int64      `json:"updated_at"`
+}
+
+// KeyPolicyRequest sets a key's access policy
+type KeyPolicyRequest struct {
+	Clients         []string `json:"clients,omitempty"`
+	NotBefore       int64    `json:"not_before,omitempty"`
+	NotAfter        int64    `json:"not_after,omitempty"`
+	MaxDeliveries   int      `json:"max_deliveries,omitempty"`
+	MaxTokenTTLSecs int      `json:"max_token_ttl_seconds,omitempty"`
+	Disabled        bool     `json:"disabled,omitempty"`
+}
+
+// Validate checks the policy request
+func (r KeyPolicyRequest) Validate() error {
+	if len(r.Clients) > MaxKeyClients {
+		return fmt.Errorf("%w: at most %d clients are allowed", ErrInvalidKeyPolicy, MaxKeyClients)
+	}
+	for i, client := range r.Clients {
+		if client == "" || len(client) > 128 {
+			return fmt.Errorf("%w: clients[%d] must be 1-128 characters", ErrInvalidKeyPolicy, i)
+		}
+	}
+	if r.NotBefore < 0 || r.NotAfter < 0 || (r.NotAfter != 0 && r.NotAfter <= r.NotBefore) {
+		return fmt.Errorf("%w: not_after must be later than not_before", ErrInvalidKeyPolicy)
+	}
+	if r.MaxDeliveries < 0 || r.MaxTokenTTLSecs < 0 {
+		return fmt.Errorf("%w: max_deliveries and max_token_ttl_seconds must not be negative", ErrInvalidKeyPolicy)
+	}
+	return nil
+}
+
+// AllowsClient reports whether tokens may be issued to client
+func (p *KeyPolicy) AllowsClient(client string) bool {
+	if p == nil || len(p.Clients) == 0 {
+		return true
+	}
+	for _, c := range p.Clients {
+		if c == client {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDelivery returns an error wrapping ErrKeyDenied if the policy does not
+// allow delivering the key to client at now. Delivery counts are checked
+// separately, when the delivery is recorded.
+func (p *KeyPolicy) CheckDelivery(client string, now time.Time) error {
+	if p == nil {
+		return nil
+	}
+	switch {
+	case p.Disabled:
+		return fmt.Errorf("%w: key is disabled", ErrKeyDenied)
+	case p.RetiredAt != 0:
+		return fmt.Errorf("%w: key was retired, its replacement is held by job %s", ErrKeyDenied, p.ReplacedBy)
+	case !p.AllowsClient(client):
+		return fmt.Errorf("%w: client %q is not allowed", ErrKeyDenied, client)
+	case p.NotBefore != 0 && now.Unix() < p.NotBefore:
+		return fmt.Errorf("%w: key is not available yet", ErrKeyDenied)
+	case p.NotAfter != 0 && now.Unix() >= p.NotAfter:
+		return fmt.Errorf("%w: key is no longer available", ErrKeyDenied)
+	}
+	return nil
+}
+
+// KeyTokenRequest asks for a token a player or packager fetches a key with
+type KeyTokenRequest struct {
+	Client     string `json:"client"`                // ID of the player or packager the token is for
+	Output     string `json:"output,omitempty"`      // Output of a multi-output job; empty for the primary key
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 0 uses the server's default
+}
+
+// DefaultPlaybackClient is the client playback tokens are issued to when the
+// request names none
+const DefaultPlaybackClient = "player"
+
+// PlaybackTokenRequest asks for a key token a player fetches a job's key with
+// from the playback key endpoint
+type PlaybackTokenRequest struct {
+	JobID      string `json:"job_id"`
+	Output     string `json:"output,omitempty"`      // Output of a multi-output job; empty for the primary key
+	Client     string `json:"client,omitempty"`      // DefaultPlaybackClient when empty
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 0 uses the server's default
+}
+
+// KeyTokenRequest returns the key token request a playback token is issued by
+func (r PlaybackTokenRequest) KeyTokenRequest() KeyTokenRequest {
+	client := r.Client
+	if client == "" {
+		client = DefaultPlaybackClient
+	}
+	return KeyTokenRequest{Client: client, Output: r.Output, TTLSeconds: r.TTLSeconds}
+}
+
+// PlaybackToken is an issued key token with the URL its key is fetched from
+type PlaybackToken struct {
+	KeyToken
+	KeyURL string `json:"key_url"` // Path of the key with the token, e.g. for an HLS key URI
+}
+
+// KeyToken is an issued key token
+type KeyToken struct {
+	Token     string `json:"token"`
+	KeyID     string `json:"kid"` // Base64url key ID, as in ClearKey licenses
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// KeyClaims are the signed contents of a key token
+type KeyClaims struct {
+	Key       ContentKey
+	Client    string
+	IssuedBy  string // Principal the token was issued to
+	IssuedAt  int64
+	ExpiresAt int64
+	TokenID   string
+}
+
+// KeyAuditEvent records one access to a content key
+type KeyAuditEvent struct {
+	Time      time.Time  `json:"time"`
+	Action    string     `json:"action"`
+	Key       ContentKey `json:"key"`
+	Client    string     `json:"client,omitempty"`    // Player or packager the key was issued or delivered to
+	Principal string     `json:"principal,omitempty"` // API caller, for policy and token actions
+	TokenID   string     `json:"token_id,omitempty"`
+	Reason    string     `json:"reason,omitempty"`  // Why a delivery was denied
+	Wrapped   bool       `json:"wrapped,omitempty"` // The key was retrieved wrapped for the caller
+	RemoteIP  string     `json:"remote_ip,omitempty"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+}
+
+// KeyAccess describes where a key request came from, for the audit trail
+type KeyAccess struct {
+	RemoteIP  string
+	UserAgent string
+	RequestID string
+}
+
+// DeliveredKey is a content key handed to a player or packager
+type DeliveredKey struct {
+	Key      ContentKey
+	KeyID    []byte
+	Material []byte // The raw key
+	Client   string
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRotationMismatch is returned when the output of a key rotation does not
+// decrypt to the content of the output it replaces
+var ErrRotationMismatch = errors.New("rotated output does not match the original")
+
+// KeyRotationRequest asks for a job's output to be re-encrypted under a new
+// key
+type KeyRotationRequest struct {
+	KeyRef   string            `json:"key_ref"` // The key_ref of the job's result, confirming which key is retired
+	Metadata map[string]string `json:"metadata,omitempty"`
+	RetryOf  string            `json:"-"` // Failed key rotation job the job runs again
+}
+
+// Validate checks that the request names the key. It returns
+// ValidationErrors; metadata is checked when the job is created.
+func (r KeyRotationRequest) Validate() error {
+	if r.KeyRef == "" {
+		return ValidationErrors{{Field: "key_ref", Message: "key_ref is required"}}
+	}
+	return nil
+}
+
+// KeyRotation describes what a key rotation job re-encrypts. The old key
+// stays on the job it rotates and is read by the worker when the job runs.
+type KeyRotation struct {
+	FromJobID string `json:"from_job_id"` // Job whose output is re-encrypted and whose key is retired
+	KeyRef    string `json:"key_ref"`     // key_ref of the retired key
+}
+
+// IsRotation reports whether the job re-encrypts another job's output under
+// a new key
+func (j *EncryptionJob) IsRotation() bool {
+	return j.Kind == JobKindRotate
+}
+
+// RotationSource returns the result of the job's output that a key rotation
+// job re-encrypts, and its stored key. Only the primary output of jobs
+// without outputs can be rotated, and only once.
+func (j *EncryptionJob) RotationSource() (*JobResult, StoredKey, error) {
+	if len(j.Outputs) > 0 {
+		return nil, StoredKey{}, fmt.Errorf("%w: the keys of multi-output jobs cannot be rotated", ErrKeyUnavailable)
+	}
+	if j.RotatedTo != "" {
+		return nil, StoredKey{}, fmt.Errorf("%w: the key of job %s was rotated by job %s", ErrKeyUnavailable, j.ID, j.RotatedTo)
+	}
+	return j.DecryptionSource("")
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidKeyRequest is returned for malformed requests for a job's key
+var ErrInvalidKeyRequest = errors.New("invalid key request")
+
+// Lifetimes of wrapped keys
+const (
+	DefaultKeyWrapTTL = 5 * time.Minute
+	MaxKeyWrapTTL     = 24 * time.Hour
+)
+
+// KeyWrapAlgorithm is how keys are wrapped for the caller: RSA-OAEP with
+// SHA-256 under the caller's public key, labelled with KeyWrapLabel
+const KeyWrapAlgorithm = "RSA-OAEP-256"
+
+// StoredKey is a content key as kept on a job or output: either inline as
+// hex, or sealed by a key store, in which case only the sealed reference is
+// persisted and the key store must open it before use
+type StoredKey struct {
+	Inline string
+	Sealed string
+}
+
+// IsZero reports whether no key is stored
+func (k StoredKey) IsZero() bool {
+	return k.Inline == "" && k.Sealed == ""
+}
+
+// StoredKey returns the key of the job's primary output
+func (j *EncryptionJob) StoredKey() StoredKey {
+	return StoredKey{Inline: j.DecryptionKey, Sealed: j.SealedKey}
+}
+
+// SetStoredKey records the key of the job's primary output
+func (j *EncryptionJob) SetStoredKey(key StoredKey) {
+	j.DecryptionKey, j.SealedKey = key.Inline, key.Sealed
+}
+
+// StoredKey returns the key of the output
+func (o *JobOutput) StoredKey() StoredKey {
+	return StoredKey{Inline: o.DecryptionKey, Sealed: o.SealedKey}
+}
+
+// SetStoredKey records the key of the output
+func (o *JobOutput) SetStoredKey(key StoredKey) {
+	o.DecryptionKey, o.SealedKey = key.Inline, key.Sealed
+}
+
+// JobKeyRequest asks for the decryption key of a completed job or output.
+// With WrapKey set the key is returned wrapped for that public key instead of
+// in the clear.
+type JobKeyRequest struct {
+	JobID      string
+	Output     string
+	WrapKey    string // Base64 encoded DER (PKIX) RSA public key
+	TTLSeconds int    // Lifetime of a wrapped key; DefaultKeyWrapTTL when 0
+}
+
+// Validate checks the lifetime of a wrapped key
+func (r JobKeyRequest) Validate() error {
+	if r.TTLSeconds < 0 || time.Duration(r.TTLSeconds)*time.Second > MaxKeyWrapTTL {
+		return fmt.Errorf("%w: ttl_seconds must be between 0 and %d", ErrInvalidKeyRequest, int(MaxKeyWrapTTL.Seconds()))
+	}
+	if r.TTLSeconds > 0 && r.WrapKey == "" {
+		return fmt.Errorf("%w: ttl_seconds only applies to wrapped keys", ErrInvalidKeyRequest)
+	}
+	return nil
+}
+
+// JobKey is the decryption key of a completed job or output, handed out only
+// by its own authorized and audited call. Either Key or WrappedKey is set.
+type JobKey struct {
+	JobID         string `json:"job_id"`
+	Output        string `json:"output,omitempty"`
+	Key           string `json:"key,omitempty"`            // Hex encoded
+	WrappedKey    string `json:"wrapped_key,omitempty"`    // Base64 encoded, for the caller's public key
+	WrapAlgorithm string `json:"wrap_algorithm,omitempty"` // KeyWrapAlgorithm
+	ExpiresAt     int64  `json:"expires_at,omitempty"`     // Unix time after which a wrapped key must not be used
+	KeyRef        string `json:"key_ref"`                  // Matches the key_ref of the result
+	KeyID         string `json:"kid,omitempty"`            // Base64url key ID of DRM packaged renditions, as license servers look the key up by
+	Sealed        bool   `json:"sealed"`                   // Whether the key is kept sealed by the key store
+}
+
+// KeyWrapLabel is the OAEP label a key is wrapped with. It names the key and
+// its expiry, so the holder of the private key must state both to unwrap it
+// and the expiry cannot be changed.
+func KeyWrapLabel(key ContentKey, expiresAt int64) []byte {
+	return []byte(fmt.Sprintf("ee-key:%s:%d", key, expiresAt))
+}
+
+// WithoutKeys returns a copy of the job for API responses, without the keys
+// of the job and its outputs, which are only handed out by the key endpoint
+func (j *EncryptionJob) WithoutKeys() *EncryptionJob {
+	if j == nil {
+		return nil
+	}
+	redacted := *j
+	redacted.SetStoredKey(StoredKey{})
+	if len(j.Outputs) > 0 {
+		redacted.Outputs = make([]JobOutput, len(j.Outputs))
+		copy(redacted.Outputs, j.Outputs)
+		for i := range redacted.Outputs {
+			redacted.Outputs[i].SetStoredKey(StoredKey{})
+		}
+	}
+	return &redacted
+}
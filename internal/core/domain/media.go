@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedMedia is returned for sources whose container or codec the
+// service does not encrypt
+var ErrUnsupportedMedia = fmt.Errorf("unsupported media")
+
+// MediaInfo describes a source as reported by the media prober
+type MediaInfo struct {
+	Container  string   `json:"container"` // Demuxer names, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	Duration   Duration `json:"duration"`
+	Width      int      `json:"width,omitempty"`
+	Height     int      `json:"height,omitempty"`
+	VideoCodec string   `json:"video_codec,omitempty"`
+	AudioCodec string   `json:"audio_codec,omitempty"`
+	Bitrate    int64    `json:"bitrate_bps,omitempty"`
+}
+
+// EstimatedSize returns the source size implied by its bitrate and duration,
+// or zero when either is unknown
+func (m *MediaInfo) EstimatedSize() int64 {
+	if m == nil || m.Bitrate <= 0 || m.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(m.Bitrate) / 8 * m.Duration.Std().Seconds())
+}
+
+// Position returns the media time reached once fraction of the source is
+// processed, or zero when the duration is unknown
+func (m *MediaInfo) Position(fraction float64) Duration {
+	if m == nil || m.Duration <= 0 {
+		return 0
+	}
+	return Duration(time.Duration(math.Min(fraction, 1) * float64(m.Duration)).Round(time.Second))
+}
+
+// MediaPolicy lists the containers and video codecs the service accepts. An
+// empty list accepts anything.
+type MediaPolicy struct {
+	Containers  []string
+	VideoCodecs []string
+}
+
+// Check returns an error wrapping ErrUnsupportedMedia if info is not allowed
+func (p MediaPolicy) Check(info *MediaInfo) error {
+	if len(p.Containers) > 0 && !containsAny(p.Containers, strings.Split(info.Container, ",")) {
+		return fmt.Errorf("%w: container %q is not one of %s", ErrUnsupportedMedia, info.Container, strings.Join(p.Containers, ", "))
+	}
+	if len(p.VideoCodecs) > 0 {
+		if info.VideoCodec == "" {
+			return fmt.Errorf("%w: source has no video stream", ErrUnsupportedMedia)
+		}
+		if !containsAny(p.VideoCodecs, []string{info.VideoCodec}) {
+			return fmt.Errorf("%w: video codec %q is not one of %s", ErrUnsupportedMedia, info.VideoCodec, strings.Join(p.VideoCodecs, ", "))
+		}
+	}
+	return nil
+}
+
+func containsAny(allowed, names []string) bool {
+	for _, name := range names {
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSpace(name), a) {
+				return true
+			}
+		}
+	}
+	return false
+}
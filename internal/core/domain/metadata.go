@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits on job metadata, which is stored and listed with every job
+const (
+	MaxMetadataEntries     = 32
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 512
+)
+
+// ErrInvalidMetadata is returned for metadata that breaks the limits above
+var ErrInvalidMetadata = fmt.Errorf("invalid metadata")
+
+// JobUpdateRequest represents a partial update of a job. Metadata entries are
+// merged into the job's metadata; a null value removes the key.
+type JobUpdateRequest struct {
+	Metadata map[string]*string `json:"metadata"`
+}
+
+// ValidateMetadata checks metadata keys and values against the size limits
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: at most %d entries are allowed", ErrInvalidMetadata, MaxMetadataEntries)
+	}
+	for key, value := range metadata {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: keys must not be empty", ErrInvalidMetadata)
+		}
+		if len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("%w: key %q is longer than %d characters", ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q is longer than %d characters", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// UpdateMetadata merges changes into the job's metadata, removing keys whose
+// value is nil, and bumps UpdatedAt to now. The job is left untouched if the
+// result is invalid.
+func (j *EncryptionJob) UpdateMetadata(changes map[string]*string, now time.Time) error {
+	metadata := make(map[string]string, len(j.Metadata)+len(changes))
+	for key, value := range j.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(metadata, key)
+			continue
+		}
+		metadata[key] = *value
+	}
+	if err := ValidateMetadata(metadata); err != nil {
+		return err
+	}
+
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+	j.Metadata = metadata
+	j.UpdatedAt = now.Unix()
+	return nil
+}
+
+// MatchesMetadata reports whether the job has every key of want with the same
+// value
+func (j *EncryptionJob) MatchesMetadata(want map[string]string) bool {
+	for key, value := range want {
+		if got, ok := j.Metadata[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
@@ -6,11 +6,14 @@ import "time"
 type EncryptionStatus string
 
 const (
-	StatusPending   EncryptionStatus = "PENDING"
+	StatusPending   EncryptionStatus = "PENDING" // Known but not in the queue, e.g. interrupted by a shutdown
+	StatusScheduled EncryptionStatus = "SCHEDULED" // Waiting for its scheduled start before it is queued
+	StatusQueued    EncryptionStatus = "QUEUED"  // Accepted and waiting for a worker
 	StatusProgress  EncryptionStatus = "IN_PROGRESS"
 	StatusPaused    EncryptionStatus = "PAUSED"
 	StatusCompleted EncryptionStatus = "COMPLETED"
 	StatusFailed    EncryptionStatus = "FAILED"
+	StatusCancelled EncryptionStatus = "CANCELLED" // Deliberately stopped
 )
 
 // EncryptionJob represents an encryption task
@@ -18,22 +21,57 @@ type EncryptionJob struct {
 	ID            string           `json:"id"`
 	SourceURL     string          `json:"source_url"`
 	Status        EncryptionStatus `json:"status"`
-	Progress      float64         `json:"progress"`
+	Progress      Progress         `json:"progress"`
 	DecryptionKey string          `json:"decryption_key,omitempty"`
+	SealedKey     string          `json:"sealed_key,omitempty"` // Reference to the key sealed by the key store, in place of decryption_key
+	OutputPath    string          `json:"output_path,omitempty"`
 	Error         string          `json:"error,omitempty"`
 	CreatedAt     int64           `json:"created_at"`
 	UpdatedAt     int64           `json:"updated_at"`
+	CreatedBy     string          `json:"created_by,omitempty"` // Principal that submitted the job
+	Tenant        string          `json:"tenant,omitempty"`     // Tenant of the principal that submitted the job
+	ExpiresAt     int64           `json:"expires_at,omitempty"` // When the record is deleted; set by the repository on every write
+	Metadata      map[string]string `json:"metadata,omitempty"` // Caller-defined labels, e.g. catalog ID or owner
+	Result        *JobResult       `json:"result,omitempty"`   // Set once the job completes
+	Media         *MediaInfo       `json:"media,omitempty"`    // Set once the source is probed
+	ErrorCode     string           `json:"error_code,omitempty"` // Machine-readable cause of a failure, e.g. unsupported_media
+	Attempts      int              `json:"attempts,omitempty"`   // Runs that failed, retried by the workers while the retry policy allows
+	LastError     string           `json:"last_error,omitempty"` // Error of the last run that failed, kept when the job is retried
+	Engine        EngineParams     `json:"engine"`               // Parameters the job is encrypted with, resolved at submission; the first output's for multi-output jobs
+	Outputs       []JobOutput      `json:"outputs,omitempty"`    // Set for multi-output jobs; the first is the primary output
+	Transcode     *TranscodeParams `json:"transcode,omitempty"`  // Renditions the source is transcoded to before it is encrypted
+	TranscodeProfile string        `json:"transcode_profile,omitempty"` // Operator profile Transcode was taken from
+	Watermark     *Watermark       `json:"watermark,omitempty"`   // Overlay applied to the source before it is transcoded or encrypted
+	ImportedAt    int64            `json:"imported_at,omitempty"` // Set for jobs migrated from another system
+	Kind          string           `json:"kind,omitempty"`        // JobKindEncrypt, JobKindDecrypt or JobKindRotate; empty for encryption jobs
+	Decryption    *Decryption      `json:"decryption,omitempty"`  // Set for decryption jobs
+	Rotation      *KeyRotation     `json:"rotation,omitempty"`    // Set for key rotation jobs
+	RotatedTo     string           `json:"rotated_to,omitempty"`  // Key rotation job that retired the job's key
+	RetryOf       string           `json:"retry_of,omitempty"`    // Failed job this job runs again
+	RetriedBy     string           `json:"retried_by,omitempty"`  // Job that last ran this failed job again
+	Verification  *JobVerification `json:"verification,omitempty"` // Outcome of the last integrity check of the outputs
+	HeartbeatAt   int64            `json:"heartbeat_at,omitempty"` // When a worker last reported running the job
+	StartedAt     int64            `json:"started_at,omitempty"`   // When a worker last started running the job
+	ScheduledAt   int64            `json:"scheduled_at,omitempty"` // When a scheduled job is queued for the workers
+	SourceHash    string           `json:"source_hash,omitempty"`  // Digest of the source content, given at submission or computed by the worker
+	KeySource     string           `json:"key_source,omitempty"`   // Where the key comes from, e.g. generated; empty for jobs created before it was recorded
+	CustomerKey   *CustomerKey     `json:"customer_key,omitempty"` // The caller's own key, for customer key sources
+	Upload        string           `json:"upload,omitempty"`       // Storage path of the uploaded source, deleted once the job ends
+	Destination   *OutputDestination `json:"destination,omitempty"` // Where the outputs are written; nil for the output storage
+	CopyTo        []OutputDestination `json:"copy_to,omitempty"`    // Further destinations the outputs are copied to once written
+	Copies        []DestinationCopy   `json:"copies,omitempty"`     // Outcome of the copy to each destination in CopyTo, set once the job completes
+
+	pendingHistory []JobHistoryEntry // Recorded by Transition, persisted by the repository
 }
 
-// NewEncryptionJob creates a new encryption job
-func NewEncryptionJob(sourceURL string) *EncryptionJob {
-	now := time.Now().Unix()
+// NewEncryptionJob creates a new encryption job created at now
+func NewEncryptionJob(sourceURL string, metadata map[string]string, now time.Time) *EncryptionJob {
 	return &EncryptionJob{
 		SourceURL: sourceURL,
+		Metadata: metadata,
 		Status:   StatusPending,
-		Progress: 0.0,
-		CreatedAt: now,
-		UpdatedAt: now,
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
 	}
 }
 
@@ -44,6 +82,21 @@ type EncryptionRequest struct {
 	Action    BatchAction `json:"action,omitempty"`
 	SourceURLs []string `json:"source_urls,omitempty"`
 	JobIDs     []string `json:"job_ids,omitempty"`
+	Source     *BatchSource `json:"source,omitempty"`
+	Dedupe     bool     `json:"dedupe,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"` // Applied to every job created by the request
+	EncryptionOptions *EngineParams `json:"encryption_options,omitempty"` // Overrides the operator's default engine parameters
+	Engine     *EngineParams     `json:"engine,omitempty"`   // Older name of encryption_options
+	Outputs    []OutputProfile   `json:"outputs,omitempty"`  // Produce several outputs from one download of the source
+	Transcode  *TranscodeParams  `json:"transcode,omitempty"` // Transcode the source before encrypting it
+	TranscodeProfile string      `json:"transcode_profile,omitempty"` // Transcode with an operator profile instead of transcode
+	Watermark   *Watermark       `json:"watermark,omitempty"`    // Overlay the source's video before transcoding or encrypting it
+	ScheduledAt *time.Time       `json:"scheduled_at,omitempty"` // Queue the jobs at this time instead of at once
+	SourceHash  string           `json:"source_hash,omitempty"`  // Digest of the source content, e.g. sha256:<hex>
+	Reuse       bool             `json:"reuse,omitempty"`        // Return a completed job with the same source_hash and parameters instead of encrypting again
+	CustomerKey *CustomerKey     `json:"customer_key,omitempty"` // Encrypt with the caller's own key instead of a generated one
+	Destination *OutputDestination `json:"destination,omitempty"` // Write the outputs there instead of the output storage
+	CopyTo      []OutputDestination `json:"copy_to,omitempty"`    // Also copy the outputs to each of these, e.g. a bucket in another region
 }
 
 // EncryptionResponse represents the response after starting encryption
@@ -51,45 +104,10 @@ type EncryptionResponse struct {
 	JobID     string          `json:"job_id"`
 	Status    EncryptionStatus `json:"status"`
 	CreatedAt int64           `json:"created_at"`
-}
-
-// CanPause checks if the job can be paused
-func (j *EncryptionJob) CanPause() error {
-	switch j.Status {
-	case StatusPaused:
-		return NewJobStateError(j.ID, j.Status, "pause", "job is already paused")
-	case StatusCompleted:
-		return NewJobStateError(j.ID, j.Status, "pause", "cannot pause a completed job")
-	case StatusFailed:
-		return NewJobStateError(j.ID, j.Status, "pause", "cannot pause a failed job")
-	case StatusPending:
-		return NewJobStateError(j.ID, j.Status, "pause", "cannot pause a pending job")
-	}
-	return nil
-}
-
-// CanResume checks if the job can be resumed
-func (j *EncryptionJob) CanResume() error {
-	if j.Status != StatusPaused {
-		return NewJobStateError(j.ID, j.Status, "resume", "can only resume paused jobs")
-	}
-	return nil
-}
-
-// CanStop checks if the job can be stopped
-func (j *EncryptionJob) CanStop() error {
-	switch j.Status {
-	case StatusCompleted:
-		return NewJobStateError(j.ID, j.Status, "stop", "job is already completed")
-	case StatusFailed:
-		return NewJobStateError(j.ID, j.Status, "stop", "job is already stopped")
-	}
-	return nil
-}
-
-// IsTerminal checks if the job is in a terminal state
-func (j *EncryptionJob) IsTerminal() bool {
-	return j.Status == StatusCompleted || j.Status == StatusFailed
+	ScheduledAt int64         `json:"scheduled_at,omitempty"` // Set for jobs queued later
+	Reused      bool          `json:"reused,omitempty"`       // job_id names an existing completed job
+	Result      *JobResult    `json:"result,omitempty"`       // The reused job's result
+	RetryOf     string        `json:"retry_of,omitempty"`     // The failed job a retry job runs again
 }
 
 // JobFilter contains all possible filtering options
@@ -99,6 +117,10 @@ type JobFilter struct {
 	EndDate     int64  // Unix timestamp
 	SourceURL   string
 	MinProgress float64
+	Metadata    map[string]string // Jobs must have all of these entries
+	CreatedBy   string            // Principal that submitted the job
+	Tenant      string            // Tenant the job belongs to
+	SourceHash  string            // Digest of the source content
 }
 
 // SortField represents a single sort criterion
@@ -113,9 +135,102 @@ type JobSort struct {
 	Fields []SortField
 }
 
+// StatusCounts holds the number of jobs in each status
+type StatusCounts map[EncryptionStatus]int
+
+// NewStatusCounts returns counts with a zero entry for every status
+func NewStatusCounts() StatusCounts {
+	counts := make(StatusCounts, len(AllStatuses))
+	for _, status := range AllStatuses {
+		counts[status] = 0
+	}
+	return counts
+}
+
+// JobStatistics aggregates completion figures over all jobs
+type JobStatistics struct {
+	AvgCompletionTime float64 `json:"avg_completion_time"` // Seconds from creation to completion
+	SuccessRate       float64 `json:"success_rate"`        // Percentage of all jobs that completed
+	TotalCompleted    int     `json:"total_completed"`
+	TotalFailed       int     `json:"total_failed"`
+	AvgProgress       float64 `json:"avg_progress"`
+	JobsLast24h       int     `json:"jobs_last_24h"`
+	JobsLastWeek      int     `json:"jobs_last_week"`
+}
+
+// JobsStatusSummary summarizes all jobs by status
+type JobsStatusSummary struct {
+	Total      int              `json:"total"`
+	ByStatus   StatusCounts     `json:"by_status"`
+	Statistics JobStatistics    `json:"statistics"`
+	LatestJobs []*EncryptionJob `json:"latest_jobs"` // Most recently created first
+}
+
+// JobAggregates are the running totals a status summary is derived from,
+// maintained by the repository as jobs are written
+type JobAggregates struct {
+	ByStatus             StatusCounts
+	CreatedLast24h       int
+	CreatedLastWeek      int
+	ProgressSum          float64 // Sum of every job's progress percent
+	CompletionSecondsSum int64   // Sum of completed jobs' seconds from creation to completion
+	LatestJobs           []*EncryptionJob // Most recently created first
+}
+
+// Add counts a job into the aggregates, except for the latest jobs
+func (a *JobAggregates) Add(job *EncryptionJob, now time.Time) {
+	a.ByStatus[job.Status]++
+	a.ProgressSum += job.Progress.Percent
+	a.CompletionSecondsSum += job.CompletionSeconds()
+	if job.CreatedAt > now.Add(-24*time.Hour).Unix() {
+		a.CreatedLast24h++
+	}
+	if job.CreatedAt > now.Add(-7*24*time.Hour).Unix() {
+		a.CreatedLastWeek++
+	}
+}
+
+// Summary derives the status summary from the aggregates
+func (a JobAggregates) Summary() *JobsStatusSummary {
+	summary := &JobsStatusSummary{
+		ByStatus:   NewStatusCounts(),
+		LatestJobs: a.LatestJobs,
+	}
+	for status, count := range a.ByStatus {
+		summary.ByStatus[status] = count
+		summary.Total += count
+	}
+
+	stats := &summary.Statistics
+	stats.TotalCompleted = summary.ByStatus[StatusCompleted]
+	stats.TotalFailed = summary.ByStatus[StatusFailed]
+	stats.JobsLast24h = a.CreatedLast24h
+	stats.JobsLastWeek = a.CreatedLastWeek
+	if stats.TotalCompleted > 0 {
+		stats.AvgCompletionTime = float64(a.CompletionSecondsSum) / float64(stats.TotalCompleted)
+	}
+	if summary.Total > 0 {
+		stats.AvgProgress = a.ProgressSum / float64(summary.Total)
+		stats.SuccessRate = float64(stats.TotalCompleted) / float64(summary.Total) * 100
+	}
+	if summary.LatestJobs == nil {
+		summary.LatestJobs = []*EncryptionJob{}
+	}
+	return summary
+}
+
+// CompletionSeconds is how long a completed job took from creation to
+// completion, or zero for any other job
+func (j *EncryptionJob) CompletionSeconds() int64 {
+	if j.Status != StatusCompleted {
+		return 0
+	}
+	return j.UpdatedAt - j.CreatedAt
+}
+
 // JobStatusSummaryResponse represents the response for job status summary
 type JobStatusSummaryResponse struct {
-	Summary   map[string]interface{} `json:"summary"`
-	Timestamp int64                 `json:"timestamp"`
-	Message   string                `json:"message"`
+	Summary   *JobsStatusSummary `json:"summary"`
+	Timestamp int64              `json:"timestamp"`
+	Message   string             `json:"message"`
 }
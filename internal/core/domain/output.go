@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// MaxOutputs limits how many outputs one job may produce
+const MaxOutputs = 8
+
+var (
+	// ErrInvalidOutputs is returned for malformed output profiles
+	ErrInvalidOutputs = errors.New("invalid outputs")
+	// ErrOutputNotFound is returned for an output a job does not have
+	ErrOutputNotFound = errors.New("output not found")
+)
+
+var outputNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// OutputProfile requests one encrypted output of a job
+type OutputProfile struct {
+	Name   string        `json:"name"`             // Unique within the job, e.g. hls or cenc
+	Engine *EngineParams `json:"engine,omitempty"` // Nil uses the operator's defaults
+}
+
+// JobOutput is one encrypted output of a multi-output job. Every output is
+// encrypted from the same download of the source, with its own progress,
+// key and result.
+type JobOutput struct {
+	Name          string           `json:"name"`
+	Engine        EngineParams     `json:"engine"`
+	Status        EncryptionStatus `json:"status"`
+	Progress      Progress         `json:"progress"`
+	DecryptionKey string           `json:"decryption_key,omitempty"`
+	SealedKey     string           `json:"sealed_key,omitempty"` // Reference to the key sealed by the key store, in place of decryption_key
+	Error         string           `json:"error,omitempty"`
+	Result        *JobResult       `json:"result,omitempty"` // Set once the output is stored
+}
+
+// ValidateOutputProfiles checks that output profiles are few enough and
+// uniquely named. Engine parameters are checked against the operator's limits
+// when the job is created.
+func ValidateOutputProfiles(profiles []OutputProfile) error {
+	if len(profiles) > MaxOutputs {
+		return fmt.Errorf("%w: at most %d outputs are allowed, got %d", ErrInvalidOutputs, MaxOutputs, len(profiles))
+	}
+	seen := make(map[string]bool, len(profiles))
+	for i, profile := range profiles {
+		if !outputNamePattern.MatchString(profile.Name) {
+			return fmt.Errorf("%w: outputs[%d].name must be 1-32 lowercase letters, digits, '-' or '_'", ErrInvalidOutputs, i)
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("%w: duplicate output name %q", ErrInvalidOutputs, profile.Name)
+		}
+		seen[profile.Name] = true
+	}
+	return nil
+}
+
+// Output returns the job's output with the given name
+func (j *EncryptionJob) Output(name string) (*JobOutput, error) {
+	for i := range j.Outputs {
+		if j.Outputs[i].Name == name {
+			return &j.Outputs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: job %s has no output %q", ErrOutputNotFound, j.ID, name)
+}
+
+// OutputPaths lists every path the job has written to the output storage
+func (j *EncryptionJob) OutputPaths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	add(j.OutputPath)
+	for _, output := range j.Outputs {
+		if output.Result != nil {
+			add(output.Result.OutputPath)
+		}
+	}
+	return paths
+}
+
+// ResetOutputs returns every output to QUEUED, discarding partial progress,
+// so an interrupted job starts over
+func (j *EncryptionJob) ResetOutputs() {
+	for i := range j.Outputs {
+		j.Outputs[i] = JobOutput{
+			Name:   j.Outputs[i].Name,
+			Engine: j.Outputs[i].Engine,
+			Status: StatusQueued,
+		}
+	}
+}
+
+// FailOutputs marks every output that has not finished as failed with reason
+func (j *EncryptionJob) FailOutputs(reason string) {
+	for i := range j.Outputs {
+		if j.Outputs[i].Status != StatusCompleted && j.Outputs[i].Status != StatusFailed {
+			j.Outputs[i].Status = StatusFailed
+			j.Outputs[i].Error = reason
+		}
+	}
+}
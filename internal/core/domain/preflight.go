@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// ErrSourceRejected is returned for sources that fail pre-flight validation
+var ErrSourceRejected = errors.New("source rejected")
+
+// SourceInfo describes a source as its storage reports it, without reading
+// it
+type SourceInfo struct {
+	Size        int64  // Bytes; -1 if unknown
+	ContentType string // Empty if unknown
+}
+
+// SourcePolicy is what pre-flight validation accepts before a job is
+// created. Empty lists accept anything, and MaxSize 0 any size.
+type SourcePolicy struct {
+	Schemes      []string // Source URL schemes, e.g. https and s3; bare paths are file
+	MaxSize      int64
+	ContentTypes []string // Media types, or prefixes ending in / such as video/
+}
+
+// CheckURL returns an error wrapping ErrSourceRejected if the scheme of
+// sourceURL is not allowed
+func (p SourcePolicy) CheckURL(sourceURL string) error {
+	if len(p.Schemes) == 0 {
+		return nil
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid source URL: %v", ErrSourceRejected, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" {
+		scheme = "file"
+	}
+	for _, allowed := range p.Schemes {
+		if strings.EqualFold(scheme, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: scheme %q is not one of %s", ErrSourceRejected, scheme, strings.Join(p.Schemes, ", "))
+}
+
+// Check returns an error wrapping ErrSourceRejected if info is not allowed.
+// Sizes and content types the source's storage does not report pass.
+func (p SourcePolicy) Check(info *SourceInfo) error {
+	if p.MaxSize > 0 && info.Size > p.MaxSize {
+		return fmt.Errorf("%w: source is %d bytes, more than the %d allowed", ErrSourceRejected, info.Size, p.MaxSize)
+	}
+	if len(p.ContentTypes) > 0 && info.ContentType != "" && !p.allowsContentType(info.ContentType) {
+		return fmt.Errorf("%w: content type %q is not one of %s", ErrSourceRejected, info.ContentType, strings.Join(p.ContentTypes, ", "))
+	}
+	return nil
+}
+
+func (p SourcePolicy) allowsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range p.ContentTypes {
+		allowed = strings.ToLower(allowed)
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
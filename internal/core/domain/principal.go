@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// ErrForbidden is returned when the caller may not act on a job or batch
+var ErrForbidden = fmt.Errorf("forbidden")
+
+// Principal is the authenticated caller a request acts for
+type Principal struct {
+	ID     string   `json:"id"`               // API key owner: a tenant, team or user
+	Tenant string   `json:"tenant,omitempty"` // Tenant the owner belongs to; the owner itself when empty
+	KeyID  string   `json:"key_id,omitempty"` // API key the caller used, for keys created through the API
+	Scopes []string `json:"scopes,omitempty"`
+	Admin  bool     `json:"admin"` // May act on every job and batch
+	Keys   bool     `json:"keys"`  // May retrieve the decryption keys of the jobs it may act on
+}
+
+// NewPrincipal returns the caller an API key with scopes acts for
+func NewPrincipal(owner, keyID string, scopes []string) Principal {
+	return Principal{
+		ID:     owner,
+		KeyID:  keyID,
+		Scopes: scopes,
+		Admin:  slices.Contains(scopes, ScopeAdmin),
+		Keys:   slices.Contains(scopes, ScopeKeys),
+	}
+}
+
+// systemPrincipal acts for callers without an identity: internal work such as
+// the workers, and every request while authentication is disabled
+var systemPrincipal = Principal{Admin: true}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a context carrying the caller's identity
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller's identity, or an unrestricted
+// principal when the context carries none
+func PrincipalFromContext(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return systemPrincipal
+}
+
+// Authorize checks that the principal may act on a resource created by owner
+func (p Principal) Authorize(owner string) error {
+	if p.Admin || (owner != "" && owner == p.ID) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q may only act on its own jobs and batches", ErrForbidden, p.ID)
+}
+
+// TenantID returns the tenant the principal's jobs and batches belong to
+func (p Principal) TenantID() string {
+	if p.Tenant != "" {
+		return p.Tenant
+	}
+	return p.ID
+}
+
+// AuthorizeTenant checks that the principal may see a resource of tenant
+func (p Principal) AuthorizeTenant(tenant string) error {
+	if p.Admin || (tenant != "" && tenant == p.TenantID()) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q may only see the jobs and batches of its tenant", ErrForbidden, p.ID)
+}
+
+// TenantID returns the tenant a job belongs to. Jobs submitted before tenants
+// were recorded belong to the tenant named after their owner.
+func (j *EncryptionJob) TenantID() string {
+	if j.Tenant != "" {
+		return j.Tenant
+	}
+	return j.CreatedBy
+}
+
+// TenantID returns the tenant a batch belongs to, like EncryptionJob.TenantID
+func (b *BatchResult) TenantID() string {
+	if b.Tenant != "" {
+		return b.Tenant
+	}
+	return b.CreatedBy
+}
+
+// CanRetrieveKeys reports whether the principal may retrieve decryption keys
+func (p Principal) CanRetrieveKeys() bool {
+	return p.Admin || p.Keys
+}
+
+// HasScope reports whether the principal was granted scope. Admins have every
+// scope.
+func (p Principal) HasScope(scope string) bool {
+	return p.Admin || slices.Contains(p.Scopes, scope)
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// ProgressStage is the step of the encryption pipeline a job is in
+type ProgressStage string
+
+const (
+	StageProbing      ProgressStage = "probing"      // Inspecting the source's container and codecs
+	StageFetching     ProgressStage = "fetching"     // Opening the source
+	StageWatermarking ProgressStage = "watermarking" // Overlaying the job's watermark on the source; percent is of this stage
+	StageTranscoding  ProgressStage = "transcoding"  // Converting the source to the requested renditions; percent is of this stage
+	StageEncrypting   ProgressStage = "encrypting"   // Reading and encrypting the source
+	StageDecrypting   ProgressStage = "decrypting"   // Reading and decrypting the source, for decryption jobs
+	StageStoring      ProgressStage = "storing"      // Writing the output to storage
+	StageCopying      ProgressStage = "copying"      // Copying the stored outputs to the job's copy_to destinations
+	StageDone         ProgressStage = "done"
+)
+
+// Progress describes how far a job has come. It is maintained by the worker
+// running the job.
+type Progress struct {
+	Percent        float64       `json:"percent"`
+	Stage          ProgressStage `json:"stage,omitempty"`
+	BytesProcessed int64         `json:"bytes_processed"`
+	BytesTotal     int64         `json:"bytes_total,omitempty"`    // Zero when the source size is unknown
+	Throughput     float64       `json:"throughput_bps,omitempty"` // Recent bytes per second
+	ETA            Duration      `json:"eta,omitempty"`            // Estimated time left, zero when unknown
+	Position       Duration      `json:"position,omitempty"`       // Media time of the source processed, zero when its duration is unknown
+	Elapsed        Duration      `json:"elapsed,omitempty"`        // Time the worker has been running the job
+	Stages         StageProgress `json:"stages"`                   // Set for jobs that run several stages on their source, with the percent of each
+	Checkpoint     *Checkpoint   `json:"checkpoint,omitempty"`     // Set while part of a checkpointed job's output is stored
+}
+
+// StageProgress holds the percent done of each stage of a job that
+// watermarks or transcodes its source before encrypting it, so a stage's
+// percent is kept once the next starts over from 0. Stages the job does not
+// run, or has not started, are left out.
+type StageProgress struct {
+	Watermarking float64 `json:"watermarking,omitempty"`
+	Transcoding  float64 `json:"transcoding,omitempty"`
+	Encrypting   float64 `json:"encrypting,omitempty"`
+
+	watermarks, transcodes, encrypts bool // Stages the job runs
+}
+
+// NewStageProgress returns the stage progress of a job running the given
+// stages
+func NewStageProgress(watermarks, transcodes, encrypts bool) StageProgress {
+	return StageProgress{watermarks: watermarks, transcodes: transcodes, encrypts: encrypts}
+}
+
+// Tracked reports whether the job runs more than one stage, so stage progress
+// is worth reporting
+func (s StageProgress) Tracked() bool {
+	stages := 0
+	for _, runs := range []bool{s.watermarks, s.transcodes, s.encrypts} {
+		if runs {
+			stages++
+		}
+	}
+	return stages > 1
+}
+
+// Advance returns s updated with progress: the percent of its stage, and 100
+// for the stages the job ran before it
+func (s StageProgress) Advance(progress Progress) StageProgress {
+	switch progress.Stage {
+	case StageWatermarking:
+		s.Watermarking = progress.Percent
+	case StageTranscoding:
+		s.finish(true, false, false)
+		s.Transcoding = progress.Percent
+	case StageEncrypting:
+		s.finish(true, true, false)
+		s.Encrypting = progress.Percent
+	case StageStoring, StageCopying, StageDone:
+		s.finish(true, true, true)
+	}
+	return s
+}
+
+// finish sets the chosen stages the job runs to 100
+func (s *StageProgress) finish(watermarking, transcoding, encrypting bool) {
+	if watermarking && s.watermarks {
+		s.Watermarking = 100
+	}
+	if transcoding && s.transcodes {
+		s.Transcoding = 100
+	}
+	if encrypting && s.encrypts {
+		s.Encrypting = 100
+	}
+}
+
+// Checkpoint records how much of a checkpointed job's output is stored, so an
+// interrupted, paused or recovered job continues from there instead of
+// starting over
+type Checkpoint struct {
+	Offset    int64  `json:"offset"`               // Source bytes encrypted into the stored segments
+	Chunk     uint32 `json:"chunk"`                // Index of the next chunk to encrypt
+	Segments  int    `json:"segments"`             // Output segments stored
+	HashState []byte `json:"hash_state,omitempty"` // SHA-256 state over the first Offset source bytes, so later runs finish the source hash
+}
+
+// ResetProgress clears the progress of a job that is to run again, keeping
+// its checkpoint
+func (j *EncryptionJob) ResetProgress() {
+	j.Progress = Progress{Checkpoint: j.Progress.Checkpoint}
+}
+
+// ThroughputMbps returns the recent throughput in megabits per second, or
+// the average since the worker started running the job when no recent
+// throughput is known
+func (p Progress) ThroughputMbps() float64 {
+	throughput := p.Throughput
+	if throughput <= 0 {
+		throughput = p.averageThroughput()
+	}
+	return math.Round(throughput*8/1e4) / 100
+}
+
+// ETASeconds returns the estimated seconds left, from the worker's estimate
+// or else the bytes left at the average throughput, or zero when unknown
+func (p Progress) ETASeconds() int64 {
+	if p.ETA > 0 {
+		return int64(math.Round(p.ETA.Std().Seconds()))
+	}
+	average := p.averageThroughput()
+	if p.BytesTotal <= 0 || p.BytesProcessed >= p.BytesTotal || average <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(p.BytesTotal-p.BytesProcessed) / average))
+}
+
+// averageThroughput returns the bytes processed per second since the worker
+// started running the job
+func (p Progress) averageThroughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesProcessed) / p.Elapsed.Std().Seconds()
+}
+
+// MarshalJSON adds the throughput in megabits per second and the ETA in
+// seconds, and leaves stages out until a job reports them
+func (p Progress) MarshalJSON() ([]byte, error) {
+	type progress Progress
+	var stages *StageProgress
+	if p.Stages.Watermarking != 0 || p.Stages.Transcoding != 0 || p.Stages.Encrypting != 0 {
+		stages = &p.Stages
+	}
+	return json.Marshal(struct {
+		progress
+		Stages         *StageProgress `json:"stages,omitempty"`
+		ThroughputMbps float64        `json:"throughput_mbps,omitempty"`
+		ETASeconds     int64          `json:"eta_seconds,omitempty"`
+	}{progress(p), stages, p.ThroughputMbps(), p.ETASeconds()})
+}
+
+// UnmarshalJSON also accepts a bare percentage, the format jobs were stored in
+// before progress carried details
+func (p *Progress) UnmarshalJSON(data []byte) error {
+	var percent float64
+	if err := json.Unmarshal(data, &percent); err == nil {
+		*p = Progress{Percent: percent}
+		return nil
+	}
+
+	type progress Progress
+	var v progress
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid progress: %w", err)
+	}
+	*p = Progress(v)
+	return nil
+}
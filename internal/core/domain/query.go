@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Orders repositories can return jobs in from their indexes
+const (
+	OrderByCreatedAt = "created_at"
+	OrderByUpdatedAt = "updated_at"
+	OrderByProgress  = "progress"
+)
+
+// JobQuery selects a page of jobs matching a filter, in creation, update or
+// progress order. Jobs with equal keys are ordered by ID.
+type JobQuery struct {
+	Filter     JobFilter
+	OrderBy    string // OrderByCreatedAt (default), OrderByUpdatedAt or OrderByProgress
+	Descending bool
+	Limit      int // Zero returns every matching job
+	Offset     int
+	After      *JobCursor // Optional; only jobs after it in ascending creation order, used with OrderByCreatedAt
+}
+
+// JobCursor marks a job's position in creation order, ties broken by ID, so
+// a listing can resume after the last job it returned even while jobs are
+// added or expire
+type JobCursor struct {
+	CreatedAt int64
+	ID        string
+}
+
+// CursorAfter returns the cursor positioned at job
+func CursorAfter(job *EncryptionJob) *JobCursor {
+	return &JobCursor{CreatedAt: job.CreatedAt, ID: job.ID}
+}
+
+// Precedes reports whether job comes after the cursor
+func (c JobCursor) Precedes(job *EncryptionJob) bool {
+	if job.CreatedAt != c.CreatedAt {
+		return job.CreatedAt > c.CreatedAt
+	}
+	return job.ID > c.ID
+}
+
+// String encodes the cursor as an opaque token
+func (c JobCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.CreatedAt, 10) + ":" + c.ID))
+}
+
+// ParseJobCursor decodes a token produced by JobCursor.String
+func ParseJobCursor(token string) (*JobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	createdAt, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, errors.New("cursor is malformed")
+	}
+	seconds, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	return &JobCursor{CreatedAt: seconds, ID: id}, nil
+}
+
+// Matches reports whether a job satisfies every condition of the filter
+func (f JobFilter) Matches(job *EncryptionJob) bool {
+	if f.Status != "" && string(job.Status) != f.Status {
+		return false
+	}
+	if f.StartDate > 0 && job.CreatedAt < f.StartDate {
+		return false
+	}
+	if f.EndDate > 0 && job.CreatedAt > f.EndDate {
+		return false
+	}
+	if f.SourceURL != "" && !strings.Contains(job.SourceURL, f.SourceURL) {
+		return false
+	}
+	if f.MinProgress > 0 && job.Progress.Percent < f.MinProgress {
+		return false
+	}
+	if !job.MatchesMetadata(f.Metadata) {
+		return false
+	}
+	if f.CreatedBy != "" && job.CreatedBy != f.CreatedBy {
+		return false
+	}
+	if f.Tenant != "" && job.TenantID() != f.Tenant {
+		return false
+	}
+	if f.SourceHash != "" && job.SourceHash != f.SourceHash {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a tenant may not submit more jobs
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quotas a tenant can exceed
+const (
+	QuotaConcurrentJobs = "concurrent_jobs" // Jobs queued, running or paused at once
+	QuotaJobsPerDay     = "jobs_per_day"    // Jobs submitted per UTC day
+	QuotaBytes          = "bytes"           // Output bytes held by retained jobs
+)
+
+// Quota limits what a tenant may submit. Zero leaves a limit unset.
+type Quota struct {
+	MaxConcurrentJobs int   `json:"max_concurrent_jobs,omitempty"`
+	MaxJobsPerDay     int   `json:"max_jobs_per_day,omitempty"`
+	MaxBytes          int64 `json:"max_bytes,omitempty"`
+}
+
+// IsZero reports whether the quota sets no limit
+func (q Quota) IsZero() bool {
+	return q == Quota{}
+}
+
+// QuotaPolicy is the quota of every tenant: Default unless Tenants has one
+// for it
+type QuotaPolicy struct {
+	Default Quota
+	Tenants map[string]Quota
+}
+
+// For returns the quota of a tenant
+func (p QuotaPolicy) For(tenant string) Quota {
+	if quota, ok := p.Tenants[tenant]; ok {
+		return quota
+	}
+	return p.Default
+}
+
+// Enabled reports whether any tenant has a limit
+func (p QuotaPolicy) Enabled() bool {
+	if !p.Default.IsZero() {
+		return true
+	}
+	for _, quota := range p.Tenants {
+		if !quota.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// QuotaUsage is what a tenant's jobs count against its quota
+type QuotaUsage struct {
+	ConcurrentJobs int   `json:"concurrent_jobs"`
+	JobsToday      int   `json:"jobs_today"`
+	Bytes          int64 `json:"bytes"`
+}
+
+// QuotaRemaining is what a tenant may still use. Limits that are not set are
+// left out.
+type QuotaRemaining struct {
+	ConcurrentJobs *int64 `json:"concurrent_jobs,omitempty"`
+	JobsToday      *int64 `json:"jobs_today,omitempty"`
+	Bytes          *int64 `json:"bytes,omitempty"`
+}
+
+// QuotaStatus is a tenant's quota, what its jobs use of it and what remains
+type QuotaStatus struct {
+	Tenant    string         `json:"tenant"`
+	Limits    Quota          `json:"limits"`
+	Usage     QuotaUsage     `json:"usage"`
+	Remaining QuotaRemaining `json:"remaining"`
+	ResetsAt  int64          `json:"resets_at"` // When the daily count starts over
+}
+
+// NewQuotaStatus derives what remains of a tenant's quota from its usage
+func NewQuotaStatus(tenant string, limits Quota, usage QuotaUsage, resetsAt time.Time) *QuotaStatus {
+	remaining := func(limit, used int64) *int64 {
+		if limit == 0 {
+			return nil
+		}
+		left := max(limit-used, 0)
+		return &left
+	}
+	return &QuotaStatus{
+		Tenant: tenant,
+		Limits: limits,
+		Usage:  usage,
+		Remaining: QuotaRemaining{
+			ConcurrentJobs: remaining(int64(limits.MaxConcurrentJobs), int64(usage.ConcurrentJobs)),
+			JobsToday:      remaining(int64(limits.MaxJobsPerDay), int64(usage.JobsToday)),
+			Bytes:          remaining(limits.MaxBytes, usage.Bytes),
+		},
+		ResetsAt: resetsAt.Unix(),
+	}
+}
+
+// Admit checks that jobs more jobs fit in the quota. Stored bytes are only
+// known once jobs complete, so the byte limit rejects submissions once it is
+// reached rather than ones that would exceed it.
+func (s *QuotaStatus) Admit(jobs int, now time.Time) error {
+	exceeded := func(quota string, limit, used int64, retryAfter time.Duration) error {
+		return &QuotaExceededError{
+			Tenant:     s.Tenant,
+			Quota:      quota,
+			Limit:      limit,
+			Used:       used,
+			Requested:  int64(jobs),
+			RetryAfter: retryAfter,
+		}
+	}
+	if limit := s.Limits.MaxBytes; limit > 0 && s.Usage.Bytes >= limit {
+		return exceeded(QuotaBytes, limit, s.Usage.Bytes, 0)
+	}
+	if limit := int64(s.Limits.MaxJobsPerDay); limit > 0 && int64(s.Usage.JobsToday+jobs) > limit {
+		return exceeded(QuotaJobsPerDay, limit, int64(s.Usage.JobsToday), time.Unix(s.ResetsAt, 0).Sub(now))
+	}
+	if limit := int64(s.Limits.MaxConcurrentJobs); limit > 0 && int64(s.Usage.ConcurrentJobs+jobs) > limit {
+		return exceeded(QuotaConcurrentJobs, limit, int64(s.Usage.ConcurrentJobs), 0)
+	}
+	return nil
+}
+
+// QuotaExceededError reports the quota a submission would exceed
+type QuotaExceededError struct {
+	Tenant     string        `json:"tenant"`
+	Quota      string        `json:"quota"` // QuotaConcurrentJobs, QuotaJobsPerDay or QuotaBytes
+	Limit      int64         `json:"limit"`
+	Used       int64         `json:"used"`
+	Requested  int64         `json:"requested"` // Jobs the submission would create
+	RetryAfter time.Duration `json:"-"`         // Until the quota frees up, when that is known
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("tenant %q exceeded its %s quota: %d of %d used, %d more jobs requested",
+		e.Tenant, e.Quota, e.Used, e.Limit, e.Requested)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Temporary reports whether the quota frees up by itself, as jobs finish or
+// the day ends, rather than only when outputs are deleted
+func (e *QuotaExceededError) Temporary() bool {
+	return e.Quota != QuotaBytes
+}
+
+// StartOfDay returns the start of the UTC day of t, when daily quotas reset
+func StartOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Active reports whether a job counts against its tenant's concurrent jobs
+func (j *EncryptionJob) Active() bool {
+	return !j.Status.IsTerminal()
+}
+
+// StoredBytes is the size of the outputs a job holds in the output storage
+func (j *EncryptionJob) StoredBytes() int64 {
+	if len(j.Outputs) == 0 {
+		if j.Result == nil {
+			return 0
+		}
+		return j.Result.Size
+	}
+	var size int64
+	for _, output := range j.Outputs {
+		if output.Result != nil {
+			size += output.Result.Size
+		}
+	}
+	return size
+}
@@ -0,0 +1,56 @@
+package domain
+
+import "time"
+
+// Recovery policies: what becomes of jobs whose worker was lost
+const (
+	RecoveryRequeue = "requeue" // Queue the job again, starting it over
+	RecoveryFail    = "fail"    // Fail the job with ErrCodeWorkerLost
+	RecoveryNone    = "none"    // Leave the job as it is
+)
+
+// JobActionRecover is the history action of jobs recovered from a lost worker
+const JobActionRecover = "recover"
+
+// LastHeartbeat returns when a worker last reported running the job. Jobs
+// started before heartbeats were recorded fall back to their last update.
+func (j *EncryptionJob) LastHeartbeat() time.Time {
+	if j.HeartbeatAt != 0 {
+		return time.Unix(j.HeartbeatAt, 0)
+	}
+	return time.Unix(j.UpdatedAt, 0)
+}
+
+// Orphaned reports whether no worker holds the job any longer: it is running
+// or waiting to be queued, and nothing has reported on it for staleAfter
+func (j *EncryptionJob) Orphaned(staleAfter time.Duration, now time.Time) bool {
+	switch j.Status {
+	case StatusProgress:
+		return now.Sub(j.LastHeartbeat()) >= staleAfter
+	case StatusPending:
+		return now.Sub(time.Unix(j.UpdatedAt, 0)) >= staleAfter
+	}
+	return false
+}
+
+// Why a running job counts as stuck
+const (
+	StuckNoHeartbeat = "heartbeat" // Its worker stopped beating, e.g. because it died
+	StuckNoProgress  = "progress"  // Its worker beats but the job reported no progress
+)
+
+// Stuck reports whether the running job has gone stuckAfter without a
+// heartbeat from its worker, beat being the zero time when none was recorded,
+// or without progress, and why
+func (j *EncryptionJob) Stuck(beat time.Time, stuckAfter time.Duration, now time.Time) (string, bool) {
+	if j.Status != StatusProgress {
+		return "", false
+	}
+	if !beat.IsZero() && now.Sub(beat) >= stuckAfter {
+		return StuckNoHeartbeat, true
+	}
+	if now.Sub(j.LastHeartbeat()) >= stuckAfter {
+		return StuckNoProgress, true
+	}
+	return "", false
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidRecurring is returned for malformed recurring batches
+	ErrInvalidRecurring = errors.New("invalid recurring batch")
+	// ErrRecurringNotFound is returned for recurring batches that do not exist
+	ErrRecurringNotFound = errors.New("recurring batch not found")
+)
+
+// MaxRecurringPerTenant limits the recurring batches a tenant may register
+const MaxRecurringPerTenant = 50
+
+// MetadataRecurringBatch is the metadata key set on every job a recurring
+// batch starts, holding the recurring batch's ID
+const MetadataRecurringBatch = "recurring_batch"
+
+// RecurringBatch starts a batch from its template every time its cron
+// expression matches
+type RecurringBatch struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name,omitempty"`
+	Tenant      string            `json:"tenant,omitempty"`
+	Cron        string            `json:"cron"`               // e.g. "0 2 * * SUN" or "@weekly"
+	Timezone    string            `json:"timezone,omitempty"` // IANA time zone the cron expression is read in; UTC when empty
+	Template    RecurringTemplate `json:"template"`
+	Paused      bool              `json:"paused,omitempty"` // Skipped until resumed
+	CreatedBy   string            `json:"created_by,omitempty"`
+	CreatedAt   int64             `json:"created_at"`
+	UpdatedAt   int64             `json:"updated_at"`
+	NextRunAt   int64             `json:"next_run_at,omitempty"`
+	LastRunAt   int64             `json:"last_run_at,omitempty"`
+	LastBatchID string            `json:"last_batch_id,omitempty"`
+	LastError   string            `json:"last_error,omitempty"` // Why the last run started no batch
+}
+
+// RecurringTemplate describes the start batch each run creates. Its sources
+// are source_urls and the objects listed from source, as in a start batch,
+// and the source URLs of the tenant's existing jobs matching filter.
+type RecurringTemplate struct {
+	SourceURLs       []string          `json:"source_urls,omitempty"`
+	Source           *BatchSource      `json:"source,omitempty"`
+	Filter           *RecurringFilter  `json:"filter,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Engine           *EngineParams     `json:"engine,omitempty"`
+	Outputs          []OutputProfile   `json:"outputs,omitempty"`
+	Transcode        *TranscodeParams  `json:"transcode,omitempty"`
+	TranscodeProfile string            `json:"transcode_profile,omitempty"`
+	Watermark        *Watermark        `json:"watermark,omitempty"`
+}
+
+// RecurringFilter selects the existing encryption jobs whose sources a run
+// encrypts again
+type RecurringFilter struct {
+	Status    string            `json:"status,omitempty"`     // e.g. COMPLETED
+	SourceURL string            `json:"source_url,omitempty"` // Substring of the source URL
+	Metadata  map[string]string `json:"metadata,omitempty"`   // Jobs must have all of these entries
+}
+
+// JobFilter returns the job filter selecting a tenant's matching jobs
+func (f RecurringFilter) JobFilter(tenant string) JobFilter {
+	return JobFilter{
+		Status:    f.Status,
+		SourceURL: f.SourceURL,
+		Metadata:  f.Metadata,
+		Tenant:    tenant,
+	}
+}
+
+// BatchOperation returns the start batch of a run over sourceURLs, the
+// template's source URLs and those of the matching jobs. Duplicates are
+// merged, and every job is labelled with the recurring batch.
+func (r *RecurringBatch) BatchOperation(sourceURLs []string) BatchOperation {
+	metadata := make(map[string]string, len(r.Template.Metadata)+1)
+	for k, v := range r.Template.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataRecurringBatch] = r.ID
+
+	return BatchOperation{
+		Action:           BatchActionStart,
+		SourceURLs:       sourceURLs,
+		Source:           r.Template.Source,
+		Dedupe:           true,
+		Metadata:         metadata,
+		Engine:           r.Template.Engine,
+		Outputs:          r.Template.Outputs,
+		Transcode:        r.Template.Transcode,
+		TranscodeProfile: r.Template.TranscodeProfile,
+		Watermark:        r.Template.Watermark,
+	}
+}
+
+// Location returns the time zone the recurring batch's cron expression is
+// read in
+func (r *RecurringBatch) Location() (*time.Location, error) {
+	if r.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+// Schedule sets when the recurring batch next runs after now
+func (r *RecurringBatch) Schedule(now time.Time) error {
+	cron, err := ParseCron(r.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurring, err)
+	}
+	loc, err := r.Location()
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidRecurring, r.Timezone)
+	}
+	next := cron.Next(now.In(loc))
+	if next.IsZero() {
+		return fmt.Errorf("%w: cron expression %q never matches", ErrInvalidRecurring, r.Cron)
+	}
+	r.NextRunAt = next.Unix()
+	return nil
+}
+
+// IsDue reports whether the recurring batch should run at now
+func (r *RecurringBatch) IsDue(now time.Time) bool {
+	return !r.Paused && r.NextRunAt != 0 && r.NextRunAt <= now.Unix()
+}
+
+// RecurringRequest registers a recurring batch or replaces a registered one
+type RecurringRequest struct {
+	Name     string            `json:"name,omitempty"`
+	Cron     string            `json:"cron"`
+	Timezone string            `json:"timezone,omitempty"`
+	Template RecurringTemplate `json:"template"`
+	Paused   bool              `json:"paused,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"` // Admins only; the caller's tenant when empty
+}
+
+// Validate checks the cron expression, the time zone and the template
+func (r RecurringRequest) Validate() error {
+	if r.Cron == "" {
+		return fmt.Errorf("%w: cron is required", ErrInvalidRecurring)
+	}
+	if _, err := ParseCron(r.Cron); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurring, err)
+	}
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidRecurring, r.Timezone)
+		}
+	}
+
+	t := r.Template
+	if len(t.SourceURLs) == 0 && t.Source == nil && t.Filter == nil {
+		return fmt.Errorf("%w: template needs source_urls, a source or a filter", ErrInvalidRecurring)
+	}
+	if t.Filter != nil && t.Filter.Status != "" && !EncryptionStatus(t.Filter.Status).IsValid() {
+		return fmt.Errorf("%w: unknown filter status %q", ErrInvalidRecurring, t.Filter.Status)
+	}
+	var errs ValidationErrors
+	if t.Source != nil {
+		errs = append(errs, t.Source.validate()...)
+	}
+	errs = append(errs, validateItems("source_urls", t.SourceURLs, ValidateSourceURL)...)
+	if err := ValidateMetadata(t.Metadata); err != nil {
+		errs = append(errs, BatchValidationError{Field: "metadata", Message: err.Error()})
+	}
+	errs = append(errs, validateOutputs(t.Engine, t.Outputs)...)
+	errs = append(errs, validateTranscode(t.Transcode, t.TranscodeProfile)...)
+	errs = append(errs, validateWatermark(t.Watermark)...)
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: template: %v", ErrInvalidRecurring, errs)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package domain
+
+// JobResult describes the output of a completed job
+type JobResult struct {
+	OutputPath string       `json:"output_path"` // Path in the output storage
+	OutputURL  string       `json:"output_url"`
+	Size       int64        `json:"size"`     // Output size in bytes, encrypted or for decryption jobs decrypted
+	Checksum   string       `json:"checksum"` // Digest of the output, e.g. sha256:<hex>
+	Algorithm  string       `json:"algorithm"`
+	KeyLength  int          `json:"key_length,omitempty"`  // Key bits
+	ChunkSize  int          `json:"chunk_size,omitempty"`  // Plaintext bytes per sealed chunk
+	IVStrategy string       `json:"iv_strategy,omitempty"` // How chunk nonces were derived
+	Container  string       `json:"container,omitempty"`   // How the output is written, e.g. stream or base64
+	KeyRef     string       `json:"key_ref"`               // Fingerprint identifying the decryption key without revealing it
+	SourceHash string       `json:"source_hash,omitempty"` // Digest of the source as the worker read it, e.g. sha256:<hex>
+	DRM        string       `json:"drm,omitempty"`         // Protection scheme of DRM packaged renditions
+	KeyID      string       `json:"kid,omitempty"`         // Base64url key ID written into DRM packaged renditions
+	Timings    StageTimings `json:"timings"`
+}
+
+// StageTimings records how long each step of the encryption pipeline took
+type StageTimings struct {
+	Probe     Duration `json:"probe,omitempty"`     // Inspecting the source, when probing is enabled
+	Fetch     Duration `json:"fetch"`               // Opening the source
+	Process   Duration `json:"process,omitempty"`   // Downloading and processing the source, e.g. watermarking it, for jobs with source processors
+	Transcode Duration `json:"transcode,omitempty"` // Downloading and transcoding the source, for transcoded jobs
+	Encrypt   Duration `json:"encrypt"`             // Reading and encrypting the source
+	Decrypt   Duration `json:"decrypt,omitempty"`   // Reading and decrypting the source, for decryption jobs
+	Store     Duration `json:"store"`               // Writing the output to storage
+	Copy      Duration `json:"copy,omitempty"`      // Copying the outputs to the job's copy_to destinations
+	Total     Duration `json:"total"`
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TusVersion is the version of the tus resumable upload protocol served
+const TusVersion = "1.0.0"
+
+// TusExtensions lists the tus protocol extensions served
+var TusExtensions = []string{"creation", "termination", "expiration"}
+
+var (
+	// ErrUploadNotFound is returned for resumable uploads that do not exist,
+	// have expired or belong to another tenant
+	ErrUploadNotFound = errors.New("upload not found")
+
+	// ErrUploadOffset is returned for chunks that do not start where the
+	// upload's received bytes end
+	ErrUploadOffset = errors.New("upload offset mismatch")
+
+	// ErrUploadLocked is returned for chunks sent while another chunk of the
+	// same upload is being written
+	ErrUploadLocked = errors.New("upload is locked by another request")
+)
+
+// ResumableUpload is a source uploaded in chunks with the tus protocol. Once
+// every byte has arrived the chunks are assembled and a job started for them.
+type ResumableUpload struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`             // Total size in bytes, declared when the upload is created
+	Offset    int64             `json:"offset"`             // Bytes received so far
+	Chunks    []int64           `json:"chunks,omitempty"`   // Offsets of the stored chunks, in order
+	Filename  string            `json:"filename,omitempty"` // Name the client gave the file
+	Request   EncryptionRequest `json:"request"`            // Options of the job started once the upload completes
+	CreatedBy string            `json:"created_by,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	ExpiresAt int64             `json:"expires_at"`       // Incomplete uploads are deleted after this; extended by each chunk
+	JobID     string            `json:"job_id,omitempty"` // Set once the upload completes and its job starts
+}
+
+// Complete reports whether every byte of the upload has arrived
+func (u *ResumableUpload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// ParseTusMetadata parses an Upload-Metadata header: comma-separated pairs
+// of a key and an optional base64 encoded value, separated by a space
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Upload-Metadata has an empty key")
+		}
+		if _, seen := metadata[key]; seen {
+			return nil, fmt.Errorf("Upload-Metadata repeats key %q", key)
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata value of %q must be base64 encoded", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
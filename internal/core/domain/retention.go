@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxRetentionExtension bounds a single retention extension
+const MaxRetentionExtension = 30 * 24 * time.Hour
+
+// ErrInvalidRetention is returned for a retention extension outside the limits
+var ErrInvalidRetention = fmt.Errorf("invalid retention extension")
+
+// RetentionRequest asks to keep a job's record longer
+type RetentionRequest struct {
+	ExtendBy Duration `json:"extend_by"` // e.g. "72h"
+}
+
+// Validate checks that the extension is positive and within the limit
+func (r RetentionRequest) Validate() error {
+	if r.ExtendBy <= 0 {
+		return fmt.Errorf("%w: extend_by must be positive", ErrInvalidRetention)
+	}
+	if r.ExtendBy.Std() > MaxRetentionExtension {
+		return fmt.Errorf("%w: extend_by must be at most %s", ErrInvalidRetention, MaxRetentionExtension)
+	}
+	return nil
+}
+
+// ExpiryWarning flags a listed job whose record is about to expire
+type ExpiryWarning struct {
+	JobID     string `json:"job_id"`
+	ExpiresAt int64  `json:"expires_at"`
+	Message   string `json:"message"`
+}
+
+// ExtendRetention pushes the job's expiry back by d, counting from now if the
+// job has no expiry yet
+func (j *EncryptionJob) ExtendRetention(d time.Duration, now time.Time) {
+	base := now.Unix()
+	if j.ExpiresAt > base {
+		base = j.ExpiresAt
+	}
+	j.ExpiresAt = base + int64(d/time.Second)
+}
+
+// ExpiresWithin reports whether the job's record expires within window of now
+func (j *EncryptionJob) ExpiresWithin(window time.Duration, now time.Time) bool {
+	return j.ExpiresAt != 0 && j.ExpiresAt <= now.Add(window).Unix()
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"time"
+)
+
+// JobActionAutoRetry schedules a job whose run failed to run again
+const JobActionAutoRetry = "auto_retry"
+
+// Classes of job failures that may pass when the job runs again
+const (
+	RetryClassNetwork     = "network"     // A connection failed, was reset or timed out
+	RetryClassThrottled   = "throttled"   // A source or storage server asked for fewer requests, e.g. S3 SlowDown or HTTP 429
+	RetryClassUnavailable = "unavailable" // A source or storage server failed with a 5xx status
+	RetryClassDiskSpace   = "disk_space"  // The worker ran low on scratch space
+)
+
+// RetryClasses lists every failure class a retry policy may retry
+var RetryClasses = []string{RetryClassNetwork, RetryClassThrottled, RetryClassUnavailable, RetryClassDiskSpace}
+
+// RetryPolicy decides which failed jobs workers run again by themselves, and
+// when
+type RetryPolicy struct {
+	MaxAttempts int           // Runs a job gets in all; 1 or less never retries
+	Backoff     time.Duration // Wait before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // Longest wait between retries; unbounded when 0
+	Classes     []string      // Failure classes that are retried
+}
+
+// Retry reports whether a job whose attempts runs have failed, the last with
+// a failure of class, runs again, and how long it waits first
+func (p RetryPolicy) Retry(attempts int, class string) (time.Duration, bool) {
+	if class == "" || attempts >= p.MaxAttempts || !p.retries(class) {
+		return 0, false
+	}
+	delay := p.Backoff
+	for i := 1; i < attempts; i++ {
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay, true
+}
+
+func (p RetryPolicy) retries(class string) bool {
+	for _, c := range p.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleRetry moves a job whose run failed with err, of class, back to
+// scheduled so it runs again at at. The history entry of the change records
+// the error, the class and the attempt that failed.
+func (j *EncryptionJob) ScheduleRetry(err error, class string, at, now time.Time) error {
+	if err := j.Transition(StatusScheduled, JobActionAutoRetry, now); err != nil {
+		return err
+	}
+	j.ScheduledAt = at.Unix()
+
+	entry := &j.pendingHistory[len(j.pendingHistory)-1]
+	entry.Error = err.Error()
+	entry.Details["attempt"] = j.Attempts
+	entry.Details["class"] = class
+	entry.Details["retry_at"] = at.Unix()
+	return nil
+}
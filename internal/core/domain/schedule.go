@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Job actions of scheduled jobs
+const (
+	JobActionSchedule   = "schedule"   // A new job waits for its scheduled start
+	JobActionReschedule = "reschedule" // A scheduled job's start moved
+	JobActionDispatch   = "dispatch"   // A scheduled job's start came and it was queued
+	JobActionUnschedule = "unschedule" // A scheduled job was cancelled before its start
+)
+
+// scheduleRetention is how long past its scheduled start a job's record is
+// kept at least, so jobs scheduled beyond the retention period do not expire
+// before they run
+const scheduleRetention = 24 * time.Hour
+
+// ErrInvalidSchedule is returned for a schedule request without a start time
+var ErrInvalidSchedule = fmt.Errorf("invalid schedule")
+
+// ScheduleRequest moves the start of a scheduled job
+type ScheduleRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"` // e.g. "2024-05-01T02:00:00Z"; a past time queues the job on the next dispatch
+}
+
+// Validate checks that the request has a start time
+func (r ScheduleRequest) Validate() error {
+	if r.ScheduledAt == nil || r.ScheduledAt.IsZero() {
+		return fmt.Errorf("%w: scheduled_at is required", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// Schedule sets when the job is queued for the workers, keeping its record
+// until at least a day after
+func (j *EncryptionJob) Schedule(at time.Time) {
+	j.ScheduledAt = at.Unix()
+	if expiresAt := at.Add(scheduleRetention).Unix(); j.ExpiresAt < expiresAt {
+		j.ExpiresAt = expiresAt
+	}
+}
+
+// IsDue reports whether a scheduled job's start has come at now
+func (j *EncryptionJob) IsDue(now time.Time) bool {
+	return j.Status == StatusScheduled && j.ScheduledAt <= now.Unix()
+}
+
+// CanReschedule checks if the job's start can still be moved
+func (j *EncryptionJob) CanReschedule() error {
+	if j.Status != StatusScheduled {
+		return NewJobStateError(j.ID, j.Status, JobActionReschedule, "can only reschedule scheduled jobs")
+	}
+	return nil
+}
+
+// CanUnschedule checks if the job can be cancelled before its start
+func (j *EncryptionJob) CanUnschedule() error {
+	if j.Status != StatusScheduled {
+		return NewJobStateError(j.ID, j.Status, JobActionUnschedule, "can only unschedule scheduled jobs")
+	}
+	return j.CheckTransition(JobActionUnschedule, StatusCancelled)
+}
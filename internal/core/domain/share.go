@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrInvalidShareLink is returned for malformed share link requests
+	ErrInvalidShareLink = errors.New("invalid share link")
+	// ErrShareLinkNotFound is returned for share links that do not exist or
+	// no longer exist
+	ErrShareLinkNotFound = errors.New("share link not found")
+	// ErrShareLinkExpired is returned for share links that are forged,
+	// expired or revoked
+	ErrShareLinkExpired = errors.New("share link is invalid, expired or revoked")
+)
+
+// What a share link hands out
+const (
+	ShareOutput   = "output"   // The encrypted output
+	ShareManifest = "manifest" // The key manifest needed to decrypt it
+)
+
+// MaxShareLabelLength limits the label of a share link
+const MaxShareLabelLength = 128
+
+// ShareLink is a signed, expiring link to a completed job's output or key
+// manifest, for handing results to external partners
+type ShareLink struct {
+	ID        string `json:"id"`
+	JobID     string `json:"job_id"`
+	Output    string `json:"output,omitempty"` // Output of a multi-output job; empty for the primary output
+	Target    string `json:"target"`           // ShareOutput or ShareManifest
+	Label     string `json:"label,omitempty"`  // Who or what the link is for, e.g. a partner's name
+	URL       string `json:"url,omitempty"`    // Set when the link is returned, never stored
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	RevokedAt int64  `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the link can be opened at the unix time now
+func (l *ShareLink) Active(now int64) bool {
+	return l.RevokedAt == 0 && now < l.ExpiresAt
+}
+
+// ShareLinkRequest mints a share link
+type ShareLinkRequest struct {
+	Target     string `json:"target"`
+	Output     string `json:"output,omitempty"`
+	Label      string `json:"label,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 0 uses the server's default
+}
+
+// Validate checks the share link request
+func (r ShareLinkRequest) Validate() error {
+	if r.Target != ShareOutput && r.Target != ShareManifest {
+		return fmt.Errorf("%w: target must be %q or %q", ErrInvalidShareLink, ShareOutput, ShareManifest)
+	}
+	if len(r.Label) > MaxShareLabelLength {
+		return fmt.Errorf("%w: label must be at most %d characters", ErrInvalidShareLink, MaxShareLabelLength)
+	}
+	if r.TTLSeconds < 0 {
+		return fmt.Errorf("%w: ttl_seconds must not be negative", ErrInvalidShareLink)
+	}
+	return nil
+}
+
+// KeyManifest describes how to decrypt an encrypted output, including its key
+type KeyManifest struct {
+	JobID      string `json:"job_id"`
+	Output     string `json:"output,omitempty"`
+	OutputPath string `json:"output_path"`
+	Size       int64  `json:"size"`
+	Checksum   string `json:"checksum"`
+	Algorithm  string `json:"algorithm"`
+	ChunkSize  int    `json:"chunk_size,omitempty"`
+	IVStrategy string `json:"iv_strategy,omitempty"`
+	Key        string `json:"key"` // Hex encoded
+	KeyRef     string `json:"key_ref"`
+}
+
+// SharedContent is what an opened share link serves: a redirect to a
+// presigned storage URL, the output itself or a key manifest
+type SharedContent struct {
+	Link        *ShareLink
+	RedirectURL string
+	Body        io.ReadCloser // The output; the caller closes it
+	Name        string        // File name of the output
+	Size        int64
+	Manifest    *KeyManifest
+}
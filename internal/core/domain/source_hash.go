@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// sourceHashPrefix is the algorithm prefix of source hashes; the digest
+// follows as lowercase hex
+const sourceHashPrefix = "sha256:"
+
+// ErrSourceHashMismatch is returned when a source's content does not match
+// the source hash its job was submitted with
+var ErrSourceHashMismatch = errors.New("source does not match its source_hash")
+
+// ValidateSourceHash checks that a source hash is a SHA-256 digest written as
+// sha256:<64 hex digits>
+func ValidateSourceHash(hash string) error {
+	digest, ok := strings.CutPrefix(hash, sourceHashPrefix)
+	if !ok {
+		return fmt.Errorf("source_hash must start with %q", sourceHashPrefix)
+	}
+	if len(digest) != 64 || strings.ToLower(digest) != digest {
+		return errors.New("source_hash must have 64 lowercase hex digits")
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return errors.New("source_hash must have 64 lowercase hex digits")
+	}
+	return nil
+}
+
+// SourceHash formats a SHA-256 digest of a source as a source hash
+func SourceHash(sum []byte) string {
+	return sourceHashPrefix + hex.EncodeToString(sum)
+}
+
+// ReusableFor reports whether the job is a completed encryption of the same
+// content, for the same tenant and with the same parameters, as job would
+// be, so its result can be returned in place of running job
+func (j *EncryptionJob) ReusableFor(job *EncryptionJob) bool {
+	// Imported jobs may have been imported without their key
+	if j.Status != StatusCompleted || j.IsDecryption() || j.IsRotation() || j.ImportedAt != 0 {
+		return false
+	}
+	if j.SourceHash == "" || j.SourceHash != job.SourceHash || j.TenantID() != job.TenantID() {
+		return false
+	}
+	// Customer keys are the caller's to control, so their jobs are never shared
+	if j.CustomerKey != nil || job.CustomerKey != nil {
+		return false
+	}
+	// A watermark traces the copy of a single job, so those are never shared
+	if j.Watermark != nil || job.Watermark != nil {
+		return false
+	}
+	// Outputs written elsewhere are not where the caller asked for them
+	if !reflect.DeepEqual(j.Destination, job.Destination) || !reflect.DeepEqual(j.CopyTo, job.CopyTo) {
+		return false
+	}
+	if j.Engine.WithDefaults() != job.Engine.WithDefaults() || len(j.Outputs) != len(job.Outputs) {
+		return false
+	}
+	for i := range j.Outputs {
+		if j.Outputs[i].Name != job.Outputs[i].Name || j.Outputs[i].Engine.WithDefaults() != job.Outputs[i].Engine.WithDefaults() {
+			return false
+		}
+	}
+	return reflect.DeepEqual(j.Transcode, job.Transcode)
+}
@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Job actions that move a job between states
+const (
+	JobActionQueue     = "queue"
+	JobActionStart     = "start"
+	JobActionPause     = "pause"
+	JobActionResume    = "resume"
+	JobActionStop      = "stop"
+	JobActionRetry     = "retry"
+	JobActionComplete  = "complete"
+	JobActionFail      = "fail"
+	JobActionInterrupt = "interrupt"
+	JobActionRequeue   = "requeue"
+)
+
+// AllStatuses lists every job status in lifecycle order
+var AllStatuses = []EncryptionStatus{
+	StatusPending,
+	StatusScheduled,
+	StatusQueued,
+	StatusProgress,
+	StatusPaused,
+	StatusCompleted,
+	StatusFailed,
+	StatusCancelled,
+}
+
+// jobTransitions is the job state machine: the statuses each status may move
+// to. Every status change must be allowed here.
+var jobTransitions = map[EncryptionStatus][]EncryptionStatus{
+	StatusPending:   {StatusQueued, StatusScheduled, StatusFailed, StatusCancelled},
+	StatusScheduled: {StatusScheduled, StatusQueued, StatusFailed, StatusCancelled},
+	StatusQueued:    {StatusProgress, StatusFailed, StatusCancelled},
+	StatusProgress:  {StatusPaused, StatusCompleted, StatusFailed, StatusCancelled, StatusPending, StatusScheduled},
+	StatusPaused:    {StatusProgress, StatusQueued, StatusCancelled},
+	StatusFailed:    {StatusQueued},
+	StatusCompleted: {},
+	StatusCancelled: {},
+}
+
+// IsValid reports whether s is a known status
+func (s EncryptionStatus) IsValid() bool {
+	_, ok := jobTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to to
+func (s EncryptionStatus) CanTransitionTo(to EncryptionStatus) bool {
+	for _, allowed := range jobTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether a job in this status will not run again without
+// an explicit retry
+func (s EncryptionStatus) IsTerminal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled
+}
+
+// CheckTransition returns a JobStateError if action cannot move the job to the
+// given status
+func (j *EncryptionJob) CheckTransition(action string, to EncryptionStatus) error {
+	if j.Status.CanTransitionTo(to) {
+		return nil
+	}
+
+	status := strings.ToLower(string(j.Status))
+	if j.Status == to {
+		return NewJobStateError(j.ID, j.Status, action, fmt.Sprintf("job is already %s", status))
+	}
+	return NewJobStateError(j.ID, j.Status, action, fmt.Sprintf("cannot %s a job that is %s", action, status))
+}
+
+// Transition moves the job to status to at time now if the state machine
+// allows it. It bumps UpdatedAt and records a history entry for the change,
+// which the repository persists with the job on its next Create or Update.
+func (j *EncryptionJob) Transition(to EncryptionStatus, reason string, now time.Time) error {
+	if !j.Status.CanTransitionTo(to) {
+		return NewJobStateError(j.ID, j.Status, reason,
+			fmt.Sprintf("transition from %s to %s is not allowed", j.Status, to))
+	}
+
+	entry := JobHistoryEntry{
+		Timestamp: now,
+		Action:    reason,
+		Status:    string(to),
+		Details:   map[string]interface{}{"from": string(j.Status)},
+	}
+	if to == StatusFailed {
+		entry.Error = j.Error
+		if j.ErrorCode != "" {
+			entry.Details["error_code"] = j.ErrorCode
+		}
+		// The pipeline stage the job failed in, e.g. transcoding
+		if j.Progress.Stage != "" && j.Progress.Stage != StageDone {
+			entry.Details["stage"] = string(j.Progress.Stage)
+		}
+	}
+
+	j.Status = to
+	j.UpdatedAt = now.Unix()
+	j.pendingHistory = append(j.pendingHistory, entry)
+	return nil
+}
+
+// RecordStage records a history entry for a finished pipeline stage without
+// changing the job's status, e.g. the renditions a source was transcoded to
+func (j *EncryptionJob) RecordStage(stage ProgressStage, details map[string]interface{}, now time.Time) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["stage"] = string(stage)
+	j.pendingHistory = append(j.pendingHistory, JobHistoryEntry{
+		Timestamp: now,
+		Action:    "stage",
+		Status:    string(j.Status),
+		Details:   details,
+	})
+}
+
+// RecordRetry records on a failed job that the job retryID runs it again.
+// The failed job keeps its status and error.
+func (j *EncryptionJob) RecordRetry(retryID string, now time.Time) {
+	j.RetriedBy = retryID
+	j.UpdatedAt = now.Unix()
+	j.pendingHistory = append(j.pendingHistory, JobHistoryEntry{
+		Timestamp: now,
+		Action:    JobActionRetry,
+		Status:    string(j.Status),
+		Details:   map[string]interface{}{"retry_job_id": retryID},
+	})
+}
+
+// PendingHistory returns the history entries recorded by Transition that have
+// not been persisted yet
+func (j *EncryptionJob) PendingHistory() []JobHistoryEntry {
+	return j.pendingHistory
+}
+
+// ClearPendingHistory marks the pending history entries as persisted
+func (j *EncryptionJob) ClearPendingHistory() {
+	j.pendingHistory = nil
+}
+
+// CanPause checks if the job can be paused
+func (j *EncryptionJob) CanPause() error {
+	return j.CheckTransition(JobActionPause, StatusPaused)
+}
+
+// CanResume checks if the job can be resumed. Resumed jobs are queued again,
+// as their worker abandoned them when they were paused.
+func (j *EncryptionJob) CanResume() error {
+	if j.Status != StatusPaused {
+		return NewJobStateError(j.ID, j.Status, JobActionResume, "can only resume paused jobs")
+	}
+	return j.CheckTransition(JobActionResume, StatusQueued)
+}
+
+// CanStop checks if the job can be stopped
+func (j *EncryptionJob) CanStop() error {
+	return j.CheckTransition(JobActionStop, StatusCancelled)
+}
+
+// CanRetry checks if the job can be queued again after failing
+func (j *EncryptionJob) CanRetry() error {
+	if j.Status != StatusFailed {
+		return NewJobStateError(j.ID, j.Status, JobActionRetry, "can only retry failed jobs")
+	}
+	if j.Upload != "" {
+		return NewJobStateError(j.ID, j.Status, JobActionRetry, "its uploaded source was deleted when it ended")
+	}
+	return j.CheckTransition(JobActionRetry, StatusQueued)
+}
+
+// IsTerminal checks if the job is in a terminal state
+func (j *EncryptionJob) IsTerminal() bool {
+	return j.Status.IsTerminal()
+}
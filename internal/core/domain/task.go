@@ -0,0 +1,23 @@
+package domain
+
+// TaskPhase is the lifecycle phase of a job launched outside the service, such
+// as a Kubernetes Job
+type TaskPhase string
+
+const (
+	TaskRunning   TaskPhase = "running"   // Waiting to be scheduled or running
+	TaskSucceeded TaskPhase = "succeeded" // Exited after recording the job's outcome
+	TaskFailed    TaskPhase = "failed"    // Exited or was stopped without recording an outcome
+	TaskMissing   TaskPhase = "missing"   // No longer known to the launcher
+)
+
+// TaskState is the observed state of a launched task
+type TaskState struct {
+	Phase  TaskPhase
+	Reason string // Why a task failed, e.g. "DeadlineExceeded: Job was active longer than specified deadline"
+}
+
+// IsFinished reports whether the task will not run any further
+func (s TaskState) IsFinished() bool {
+	return s.Phase != TaskRunning
+}
@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Transcode formats
+const (
+	TranscodeMP4 = "mp4" // An MP4 file per rendition
+	TranscodeHLS = "hls" // HLS playlists and segments per rendition, with a master playlist
+)
+
+// Limits on transcode parameters
+const (
+	MaxRenditions         = 6
+	DefaultSegmentSeconds = 6
+	MaxSegmentSeconds     = 60
+	MinRenditionHeight    = 144
+	MaxRenditionHeight    = 4320
+)
+
+var (
+	// ErrInvalidTranscode is returned for malformed transcode parameters, or
+	// when transcoding is not enabled
+	ErrInvalidTranscode = errors.New("invalid transcode")
+	// ErrTranscodeFailed is returned when the transcoder cannot convert a source
+	ErrTranscodeFailed = errors.New("transcode failed")
+)
+
+// codecNamePattern matches ffmpeg encoder names
+var codecNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// Rendition is one encoding a source is transcoded to
+type Rendition struct {
+	Name         string `json:"name"`                        // Unique within the job, e.g. 720p
+	Height       int    `json:"height,omitempty"`            // Scaled keeping the aspect ratio; 0 keeps the source's
+	VideoBitrate int64  `json:"video_bitrate_bps,omitempty"` // 0 lets the encoder choose by quality
+	AudioBitrate int64  `json:"audio_bitrate_bps,omitempty"` // 0 uses the encoder's default
+}
+
+// TranscodeParams asks for a job's source to be transcoded before it is
+// encrypted. The renditions are packaged into a single file that is encrypted
+// like any source: an MP4 for a single mp4 rendition, otherwise a tar archive.
+// With drm the renditions are instead fragmented MP4s protected with that
+// scheme, and the package is stored as it is for players to decrypt.
+type TranscodeParams struct {
+	Format         string      `json:"format"`
+	Renditions     []Rendition `json:"renditions"`
+	SegmentSeconds int         `json:"segment_seconds,omitempty"` // Target HLS segment length
+	DRM            string      `json:"drm,omitempty"`             // Protection scheme, e.g. cenc; empty encrypts the package with the engine
+	VideoCodec     string      `json:"video_codec,omitempty"`     // ffmpeg video encoder, e.g. libx265; empty uses the worker's
+	AudioCodec     string      `json:"audio_codec,omitempty"`     // ffmpeg audio encoder; empty uses the worker's
+}
+
+// Packaged reports whether the transcoded renditions are packaged in a tar
+// archive rather than a single MP4
+func (p TranscodeParams) Packaged() bool {
+	return p.Format != TranscodeMP4 || len(p.Renditions) != 1
+}
+
+// Validate checks the transcode parameters and fills in defaults
+func (p *TranscodeParams) Validate() error {
+	switch p.Format {
+	case TranscodeMP4:
+		if p.SegmentSeconds != 0 {
+			return fmt.Errorf("%w: segment_seconds only applies to format %q", ErrInvalidTranscode, TranscodeHLS)
+		}
+	case TranscodeHLS:
+		if p.SegmentSeconds == 0 {
+			p.SegmentSeconds = DefaultSegmentSeconds
+		}
+		if p.SegmentSeconds < 1 || p.SegmentSeconds > MaxSegmentSeconds {
+			return fmt.Errorf("%w: segment_seconds must be between 1 and %d", ErrInvalidTranscode, MaxSegmentSeconds)
+		}
+	default:
+		return fmt.Errorf("%w: format must be %s or %s, got %q", ErrInvalidTranscode, TranscodeMP4, TranscodeHLS, p.Format)
+	}
+
+	if p.DRM != "" {
+		if _, ok := lookupFold(SupportedDRMSchemes, p.DRM); !ok {
+			return fmt.Errorf("%w: drm must be one of %s, got %q", ErrInvalidTranscode, strings.Join(SupportedDRMSchemes, ", "), p.DRM)
+		}
+		if p.Format != TranscodeMP4 {
+			return fmt.Errorf("%w: drm only applies to format %q", ErrInvalidTranscode, TranscodeMP4)
+		}
+		p.DRM, _ = lookupFold(SupportedDRMSchemes, p.DRM)
+	}
+
+	if p.VideoCodec != "" && !codecNamePattern.MatchString(p.VideoCodec) {
+		return fmt.Errorf("%w: video_codec must be an ffmpeg encoder name, got %q", ErrInvalidTranscode, p.VideoCodec)
+	}
+	if p.AudioCodec != "" && !codecNamePattern.MatchString(p.AudioCodec) {
+		return fmt.Errorf("%w: audio_codec must be an ffmpeg encoder name, got %q", ErrInvalidTranscode, p.AudioCodec)
+	}
+
+	if len(p.Renditions) == 0 || len(p.Renditions) > MaxRenditions {
+		return fmt.Errorf("%w: between 1 and %d renditions are required, got %d", ErrInvalidTranscode, MaxRenditions, len(p.Renditions))
+	}
+	seen := make(map[string]bool, len(p.Renditions))
+	for i, r := range p.Renditions {
+		if !outputNamePattern.MatchString(r.Name) {
+			return fmt.Errorf("%w: renditions[%d].name must be 1-32 lowercase letters, digits, '-' or '_'", ErrInvalidTranscode, i)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("%w: duplicate rendition name %q", ErrInvalidTranscode, r.Name)
+		}
+		seen[r.Name] = true
+		if r.Height != 0 && (r.Height < MinRenditionHeight || r.Height > MaxRenditionHeight || r.Height%2 != 0) {
+			return fmt.Errorf("%w: renditions[%d].height must be an even number between %d and %d", ErrInvalidTranscode, i, MinRenditionHeight, MaxRenditionHeight)
+		}
+		if r.VideoBitrate < 0 || r.AudioBitrate < 0 {
+			return fmt.Errorf("%w: renditions[%d] bitrates must not be negative", ErrInvalidTranscode, i)
+		}
+	}
+	return nil
+}
+
+// TranscodeProfiles are the transcode parameters the operator defines by name,
+// for jobs to ask for with transcode_profile instead of spelling them out
+type TranscodeProfiles map[string]TranscodeParams
+
+// Resolve returns a copy of the named profile's parameters
+func (p TranscodeProfiles) Resolve(name string) (*TranscodeParams, error) {
+	params, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown transcode_profile %q", ErrInvalidTranscode, name)
+	}
+	params.Renditions = append([]Rendition(nil), params.Renditions...)
+	return &params, nil
+}
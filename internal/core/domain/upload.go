@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrUploadTooLarge is returned for uploaded sources larger than the
+// configured limit
+var ErrUploadTooLarge = errors.New("upload too large")
+
+// maxUploadNameLength is the longest file name uploads are stored under
+const maxUploadNameLength = 128
+
+// UploadName returns the name an uploaded file is stored under: the base of
+// the client's file name with anything but letters, digits, dots, dashes and
+// underscores replaced, or "source" if nothing is left
+func UploadName(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if len(name) > maxUploadNameLength {
+		name = name[len(name)-maxUploadNameLength:]
+	}
+	if name == "" {
+		return "source"
+	}
+	return name
+}
+
+// ValidateUpload checks the options of a job whose source is uploaded with
+// the request: a single request without source_url. It returns
+// ValidationErrors.
+func (r EncryptionRequest) ValidateUpload() error {
+	var errs ValidationErrors
+	if r.SourceURL != "" {
+		errs = append(errs, BatchValidationError{
+			Field:   "source_url",
+			Message: "the uploaded file is the source; source_url must not be set",
+			Value:   r.SourceURL,
+		})
+	}
+	if r.Batch {
+		errs = append(errs, BatchValidationError{
+			Field:   "batch",
+			Message: "an upload creates a single job",
+		})
+	}
+	if r.EncryptionOptions != nil && r.Engine != nil {
+		errs = append(errs, BatchValidationError{
+			Field:   "encryption_options",
+			Message: "encryption_options replaces engine; set only one of them",
+		})
+	}
+	errs = append(errs, r.validateSingle()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
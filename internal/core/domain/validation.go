@@ -0,0 +1,582 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SupportedSourceSchemes lists the URL schemes a job source may use. Sources
+// without a scheme are paths in local storage.
+var SupportedSourceSchemes = []string{"s3", "http", "https", "file"}
+
+// ValidationErrors collects every problem found in a request so callers can
+// report them together
+type ValidationErrors []BatchValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i := range e {
+		messages[i] = e[i].Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// ValidateSourceURL checks that a source URL is well formed and uses a
+// supported scheme. It does not check that the source exists.
+func ValidateSourceURL(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return fmt.Errorf("source URL cannot be empty")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "s3":
+		if u.Host == "" {
+			return fmt.Errorf("s3 source URL must name a bucket, e.g. s3://bucket/key")
+		}
+		if strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("s3 source URL must name an object key, e.g. s3://bucket/key")
+		}
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("%s source URL must include a host", u.Scheme)
+		}
+	case "file", "":
+		if u.Path == "" {
+			return fmt.Errorf("source URL must include a path")
+		}
+	default:
+		return fmt.Errorf("unsupported source URL scheme %q (supported: %s, or a local path)",
+			u.Scheme, strings.Join(SupportedSourceSchemes, ", "))
+	}
+	return nil
+}
+
+// BatchOperation returns the batch operation described by a batch request
+func (r EncryptionRequest) BatchOperation() BatchOperation {
+	return BatchOperation{
+		Action:      r.Action,
+		SourceURLs:  r.SourceURLs,
+		JobIDs:      r.JobIDs,
+		Source:      r.Source,
+		Dedupe:      r.Dedupe,
+		Metadata:    r.Metadata,
+		Engine:      r.EngineParams(),
+		Outputs:     r.Outputs,
+		Transcode:   r.Transcode,
+		TranscodeProfile: r.TranscodeProfile,
+		Watermark:   r.Watermark,
+		ScheduledAt: r.ScheduledAt,
+		Destination: r.Destination,
+		CopyTo:      r.CopyTo,
+	}
+}
+
+// JobOptions returns the options of the job a single request starts
+func (r EncryptionRequest) JobOptions() JobOptions {
+	opts := JobOptions{
+		Metadata:    r.Metadata,
+		Engine:      r.EngineParams(),
+		Outputs:     r.Outputs,
+		Transcode:   r.Transcode,
+		TranscodeProfile: r.TranscodeProfile,
+		Watermark:   r.Watermark,
+		SourceHash:  r.SourceHash,
+		Reuse:       r.Reuse,
+		CustomerKey: r.CustomerKey,
+		Destination: r.Destination,
+		CopyTo:      r.CopyTo,
+	}
+	if r.ScheduledAt != nil {
+		opts.ScheduledAt = *r.ScheduledAt
+	}
+	return opts
+}
+
+// EngineParams returns the engine parameters the request asks for, given as
+// encryption_options or under their older name engine
+func (r EncryptionRequest) EngineParams() *EngineParams {
+	if r.EncryptionOptions != nil {
+		return r.EncryptionOptions
+	}
+	return r.Engine
+}
+
+// Validate checks a request before any job is created. Single requests need a
+// valid source_url and must not use batch fields; batch requests are checked
+// as a BatchOperation. It returns ValidationErrors.
+func (r EncryptionRequest) Validate() error {
+	var errs ValidationErrors
+
+	if r.EncryptionOptions != nil && r.Engine != nil {
+		errs = append(errs, BatchValidationError{
+			Field:   "encryption_options",
+			Message: "encryption_options replaces engine; set only one of them",
+		})
+	}
+
+	if r.Batch {
+		if r.SourceURL != "" {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_url",
+				Message: "source_url is only used for single requests; use source_urls with batch",
+				Value:   r.SourceURL,
+			})
+		}
+		singleOnly := []struct {
+			field string
+			set   bool
+		}{
+			{"source_hash", r.SourceHash != ""},
+			{"reuse", r.Reuse},
+			{"customer_key", r.CustomerKey != nil},
+		}
+		for _, f := range singleOnly {
+			if f.set {
+				errs = append(errs, BatchValidationError{
+					Field:   f.field,
+					Message: fmt.Sprintf("%s is only used for single requests", f.field),
+				})
+			}
+		}
+		errs = append(errs, r.BatchOperation().validate()...)
+	} else {
+		if r.SourceURL == "" {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_url",
+				Message: "source_url is required for single operations",
+			})
+		} else if err := ValidateSourceURL(r.SourceURL); err != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_url",
+				Message: err.Error(),
+				Value:   r.SourceURL,
+			})
+		}
+
+		errs = append(errs, r.validateSingle()...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateSingle checks the fields of a single request other than its source
+func (r EncryptionRequest) validateSingle() ValidationErrors {
+	var errs ValidationErrors
+
+	batchOnly := []struct {
+		field string
+		set   bool
+	}{
+		{"source_urls", len(r.SourceURLs) > 0},
+		{"job_ids", len(r.JobIDs) > 0},
+		{"source", r.Source != nil},
+		{"dedupe", r.Dedupe},
+		{"action", r.Action != "" && r.Action != BatchActionStart},
+	}
+	for _, f := range batchOnly {
+		if f.set {
+			errs = append(errs, BatchValidationError{
+				Field:   f.field,
+				Message: fmt.Sprintf("%s requires batch to be true", f.field),
+			})
+		}
+	}
+
+	if err := ValidateMetadata(r.Metadata); err != nil {
+		errs = append(errs, BatchValidationError{
+			Field:   "metadata",
+			Message: err.Error(),
+		})
+	}
+	errs = append(errs, validateOutputs(r.EngineParams(), r.Outputs)...)
+	errs = append(errs, validateTranscode(r.Transcode, r.TranscodeProfile)...)
+	errs = append(errs, validateWatermark(r.Watermark)...)
+	errs = append(errs, validateDestination(r.Destination, r.CopyTo)...)
+
+	if r.SourceHash != "" {
+		if err := ValidateSourceHash(r.SourceHash); err != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_hash",
+				Message: err.Error(),
+				Value:   r.SourceHash,
+			})
+		}
+	} else if r.Reuse {
+		errs = append(errs, BatchValidationError{
+			Field:   "reuse",
+			Message: "reuse requires source_hash",
+		})
+	}
+
+	if r.CustomerKey != nil {
+		if err := r.CustomerKey.Validate(); err != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "customer_key",
+				Message: err.Error(),
+			})
+		}
+	}
+	return errs
+}
+
+// Validate checks that a batch operation is consistent with its action. It
+// returns ValidationErrors.
+func (op BatchOperation) Validate() error {
+	if errs := op.validate(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (op BatchOperation) validate() ValidationErrors {
+	var errs ValidationErrors
+
+	// Validate action
+	if op.Action == "" {
+		errs = append(errs, BatchValidationError{
+			Field:   "action",
+			Message: "action is required",
+		})
+	} else {
+		validActions := map[BatchAction]bool{
+			BatchActionStart:  true,
+			BatchActionPause:  true,
+			BatchActionResume: true,
+			BatchActionStop:   true,
+			BatchActionRetry:  true,
+		}
+		if !validActions[op.Action] {
+			errs = append(errs, BatchValidationError{
+				Field:   "action",
+				Message: "unsupported action",
+				Value:   string(op.Action),
+			})
+		}
+	}
+
+	// Action-specific validations
+	switch op.Action {
+	case BatchActionStart:
+		if len(op.SourceURLs) == 0 && op.Source == nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_urls",
+				Message: "at least one source URL or a source is required for start action",
+			})
+		}
+		if op.Source != nil {
+			errs = append(errs, op.Source.validate()...)
+		}
+		errs = append(errs, validateItems("source_urls", op.SourceURLs, ValidateSourceURL)...)
+		if err := ValidateMetadata(op.Metadata); err != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "metadata",
+				Message: err.Error(),
+			})
+		}
+		errs = append(errs, validateOutputs(op.Engine, op.Outputs)...)
+		errs = append(errs, validateTranscode(op.Transcode, op.TranscodeProfile)...)
+		errs = append(errs, validateWatermark(op.Watermark)...)
+		errs = append(errs, validateDestination(op.Destination, op.CopyTo)...)
+		if len(op.JobIDs) > 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "job_ids",
+				Message: "job_ids should not be provided for start action",
+				Value:   fmt.Sprintf("%v", op.JobIDs),
+			})
+		}
+
+	case BatchActionPause, BatchActionResume, BatchActionStop, BatchActionRetry:
+		if len(op.JobIDs) == 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "job_ids",
+				Message: fmt.Sprintf("at least one job ID is required for %s action", op.Action),
+			})
+		}
+		errs = append(errs, validateItems("job_ids", op.JobIDs, validateJobID)...)
+		if len(op.SourceURLs) > 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "source_urls",
+				Message: fmt.Sprintf("source_urls should not be provided for %s action", op.Action),
+				Value:   fmt.Sprintf("%v", op.SourceURLs),
+			})
+		}
+		if op.Source != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "source",
+				Message: fmt.Sprintf("source should not be provided for %s action", op.Action),
+			})
+		}
+		if len(op.Metadata) > 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "metadata",
+				Message: fmt.Sprintf("metadata should not be provided for %s action", op.Action),
+			})
+		}
+		if op.Engine != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "engine",
+				Message: fmt.Sprintf("engine should not be provided for %s action", op.Action),
+			})
+		}
+		if len(op.Outputs) > 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "outputs",
+				Message: fmt.Sprintf("outputs should not be provided for %s action", op.Action),
+			})
+		}
+		if op.Transcode != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "transcode",
+				Message: fmt.Sprintf("transcode should not be provided for %s action", op.Action),
+			})
+		}
+		if op.TranscodeProfile != "" {
+			errs = append(errs, BatchValidationError{
+				Field:   "transcode_profile",
+				Message: fmt.Sprintf("transcode_profile should not be provided for %s action", op.Action),
+			})
+		}
+		if op.Watermark != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "watermark",
+				Message: fmt.Sprintf("watermark should not be provided for %s action", op.Action),
+			})
+		}
+		if op.ScheduledAt != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "scheduled_at",
+				Message: fmt.Sprintf("scheduled_at should not be provided for %s action", op.Action),
+			})
+		}
+		if op.Destination != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "destination",
+				Message: fmt.Sprintf("destination should not be provided for %s action", op.Action),
+			})
+		}
+		if len(op.CopyTo) > 0 {
+			errs = append(errs, BatchValidationError{
+				Field:   "copy_to",
+				Message: fmt.Sprintf("copy_to should not be provided for %s action", op.Action),
+			})
+		}
+	}
+
+	return errs
+}
+
+const (
+	// MaxItemValidationErrors is how many invalid source URLs or job IDs a
+	// batch reports before validation of that list stops
+	MaxItemValidationErrors = 100
+
+	// Lists at least this long are validated by several goroutines
+	parallelValidationThreshold = 256
+)
+
+func validateJobID(jobID string) error {
+	if jobID == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+	return nil
+}
+
+// validateItems checks each item of a batch list, such as source_urls, and
+// reports errors in list order. Long lists are split between goroutines. Once
+// MaxItemValidationErrors items have failed, checking stops and a final error
+// says the list was not checked completely; which items are reported then
+// depends on scheduling.
+func validateItems(field string, items []string, check func(string) error) ValidationErrors {
+	workers := min(runtime.GOMAXPROCS(0), len(items)/parallelValidationThreshold)
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		failed  atomic.Int64
+		stopped atomic.Bool
+		results = make([]ValidationErrors, workers)
+		size    = (len(items) + workers - 1) / workers
+	)
+	checkRange := func(w, start, end int) {
+		for i := start; i < end; i++ {
+			if failed.Load() >= MaxItemValidationErrors {
+				stopped.Store(true)
+				return
+			}
+			if err := check(items[i]); err != nil {
+				failed.Add(1)
+				results[w] = append(results[w], BatchValidationError{
+					Field:   fmt.Sprintf("%s[%d]", field, i),
+					Message: err.Error(),
+					Value:   items[i],
+				})
+			}
+		}
+	}
+
+	if workers == 1 {
+		checkRange(0, 0, len(items))
+	} else {
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				checkRange(w, w*size, min((w+1)*size, len(items)))
+			}(w)
+		}
+		wg.Wait()
+	}
+
+	var errs ValidationErrors
+	for _, result := range results {
+		errs = append(errs, result...)
+	}
+	if stopped.Load() || len(errs) > MaxItemValidationErrors {
+		errs = append(errs[:min(len(errs), MaxItemValidationErrors)], BatchValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("validation stopped after %d invalid entries", MaxItemValidationErrors),
+		})
+	}
+	return errs
+}
+
+// validateOutputs checks output profiles, which replace a single engine
+func validateOutputs(engine *EngineParams, outputs []OutputProfile) ValidationErrors {
+	var errs ValidationErrors
+	if engine != nil && len(outputs) > 0 {
+		errs = append(errs, BatchValidationError{
+			Field:   "engine",
+			Message: "engine cannot be combined with outputs; set engine on each output",
+		})
+	}
+	if err := ValidateOutputProfiles(outputs); err != nil {
+		errs = append(errs, BatchValidationError{
+			Field:   "outputs",
+			Message: err.Error(),
+		})
+	}
+	return errs
+}
+
+// validateDestination checks a requested output destination and those the
+// outputs are copied to; whether the operator allows them is checked when the
+// job is created
+func validateDestination(d *OutputDestination, copyTo []OutputDestination) ValidationErrors {
+	var errs ValidationErrors
+	if d != nil {
+		if err := d.Validate(); err != nil {
+			errs = append(errs, BatchValidationError{
+				Field:   "destination",
+				Message: err.Error(),
+			})
+		}
+	}
+	if err := ValidateCopyDestinations(d, copyTo); err != nil {
+		errs = append(errs, BatchValidationError{
+			Field:   "copy_to",
+			Message: err.Error(),
+		})
+	}
+	return errs
+}
+
+// validateTranscode checks requested transcoding; whether it is enabled, and
+// whether the profile exists, is checked when the job is created
+func validateTranscode(params *TranscodeParams, profile string) ValidationErrors {
+	if profile != "" {
+		if params != nil {
+			return ValidationErrors{{
+				Field:   "transcode_profile",
+				Message: "transcode_profile and transcode are exclusive",
+			}}
+		}
+		if !outputNamePattern.MatchString(profile) {
+			return ValidationErrors{{
+				Field:   "transcode_profile",
+				Message: "transcode_profile must be 1-32 lowercase letters, digits, '-' or '_'",
+			}}
+		}
+		return nil
+	}
+	if params == nil {
+		return nil
+	}
+	check := *params
+	if err := check.Validate(); err != nil {
+		return ValidationErrors{{
+			Field:   "transcode",
+			Message: err.Error(),
+		}}
+	}
+	return nil
+}
+
+// validateWatermark checks a requested watermark; whether watermarking is
+// enabled is checked when the job is created
+func validateWatermark(w *Watermark) ValidationErrors {
+	if w == nil {
+		return nil
+	}
+	check := *w
+	if err := check.Validate(); err != nil {
+		return ValidationErrors{{
+			Field:   "watermark",
+			Message: err.Error(),
+		}}
+	}
+	return nil
+}
+
+// validate checks that a bucket/prefix or directory source is well formed
+func (src *BatchSource) validate() ValidationErrors {
+	var errs ValidationErrors
+
+	if src.Bucket == "" && src.Directory == "" {
+		errs = append(errs, BatchValidationError{
+			Field:   "source",
+			Message: "either bucket or directory is required",
+		})
+	}
+	if src.Bucket != "" && src.Directory != "" {
+		errs = append(errs, BatchValidationError{
+			Field:   "source",
+			Message: "bucket and directory are mutually exclusive",
+		})
+	}
+
+	filters := []struct {
+		field    string
+		patterns []string
+	}{
+		{"source.include", src.Include},
+		{"source.exclude", src.Exclude},
+	}
+	for _, f := range filters {
+		for i, pattern := range f.patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, BatchValidationError{
+					Field:   fmt.Sprintf("%s[%d]", f.field, i),
+					Message: "invalid glob pattern",
+					Value:   pattern,
+				})
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+)
+
+// sourceURLs returns n source URLs, every step-th of them invalid
+func sourceURLs(n, step int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("s3://media/source-%d.mp4", i)
+		if step > 0 && i%step == 0 {
+			urls[i] = fmt.Sprintf("ftp://media/source-%d.mp4", i)
+		}
+	}
+	return urls
+}
+
+func TestValidateItemsReportsInListOrder(t *testing.T) {
+	urls := sourceURLs(4*parallelValidationThreshold, 50)
+
+	errs := validateItems("source_urls", urls, ValidateSourceURL)
+	if want := (len(urls) + 49) / 50; len(errs) != want {
+		t.Fatalf("got %d errors, want %d", len(errs), want)
+	}
+	for i, err := range errs {
+		if want := fmt.Sprintf("source_urls[%d]", i*50); err.Field != want {
+			t.Errorf("error %d is for %s, want %s", i, err.Field, want)
+		}
+	}
+}
+
+func TestValidateItemsStopsAtErrorCap(t *testing.T) {
+	urls := sourceURLs(4*parallelValidationThreshold, 1)
+
+	errs := validateItems("source_urls", urls, ValidateSourceURL)
+	if len(errs) != MaxItemValidationErrors+1 {
+		t.Fatalf("got %d errors, want %d", len(errs), MaxItemValidationErrors+1)
+	}
+	if last := errs[len(errs)-1]; last.Field != "source_urls" {
+		t.Errorf("last error is for %s, want the list itself", last.Field)
+	}
+}
+
+// BenchmarkValidateItems measures checking batch source lists of the sizes
+// batches are submitted with
+func BenchmarkValidateItems(b *testing.B) {
+	for _, n := range []int{100, 10000, 100000} {
+		urls := sourceURLs(n, 0)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if errs := validateItems("source_urls", urls, ValidateSourceURL); len(errs) > 0 {
+					b.Fatal(errs)
+				}
+			}
+		})
+	}
+}
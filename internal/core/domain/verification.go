@@ -0,0 +1,28 @@
+package domain
+
+import "errors"
+
+// ErrNotVerifiable is returned for jobs whose outputs cannot be verified,
+// e.g. because they were imported without them
+var ErrNotVerifiable = errors.New("job cannot be verified")
+
+// JobVerification is the outcome of reading a completed job's outputs back
+// from the output storage and checking them against the checksums recorded
+// when they were written
+type JobVerification struct {
+	JobID      string               `json:"job_id"`
+	Verified   bool                 `json:"verified"`              // Every output matched its checksum and size
+	VerifiedAt int64                `json:"verified_at"`           // Unix time of the check
+	SourceHash string               `json:"source_hash,omitempty"` // Digest of the source the outputs were encrypted from
+	Outputs    []OutputVerification `json:"outputs"`
+}
+
+// OutputVerification is the outcome of checking one output
+type OutputVerification struct {
+	Output   string `json:"output,omitempty"` // Output of a multi-output job; empty for the primary output
+	Checksum string `json:"checksum"`         // Digest recorded when the output was written
+	Actual   string `json:"actual,omitempty"` // Digest of the output as read back; empty if it could not be read
+	Size     int64  `json:"size"`             // Bytes read back
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"` // Why the output does not match or could not be read
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Watermark modes
+const (
+	WatermarkVisible  = "visible"  // A plainly visible overlay in a fixed corner
+	WatermarkForensic = "forensic" // A faint, small overlay that moves between corners, hard to crop out
+)
+
+// Watermark positions
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+	WatermarkCenter      = "center"
+)
+
+// Limits and defaults of watermark parameters
+const (
+	MaxWatermarkText          = 200
+	MaxWatermarkSize          = 1080
+	DefaultVisibleOpacity     = 0.5
+	DefaultForensicOpacity    = 0.08
+	DefaultVisibleTextSize    = 32 // Font size in pixels
+	DefaultForensicTextSize   = 14
+	DefaultVisibleImageSize   = 80 // Image height in pixels
+	DefaultForensicImageSize  = 24
+	WatermarkJobIDPlaceholder = "{job_id}"
+)
+
+var (
+	// ErrInvalidWatermark is returned for malformed watermark parameters, or
+	// when watermarking is not enabled
+	ErrInvalidWatermark = errors.New("invalid watermark")
+	// ErrWatermarkFailed is returned when a source cannot be watermarked
+	ErrWatermarkFailed = errors.New("watermark failed")
+)
+
+// Watermark asks for a text or image overlay on a job's video before it is
+// transcoded or encrypted, so leaked copies can be traced to the job they
+// came from
+type Watermark struct {
+	Mode     string  `json:"mode,omitempty"`      // visible (default) or forensic
+	Text     string  `json:"text,omitempty"`      // {job_id} is replaced with the job's ID
+	ImageURL string  `json:"image_url,omitempty"` // PNG overlay, fetched like a source
+	Position string  `json:"position,omitempty"`  // Corner or center of a visible watermark, bottom-right by default
+	Opacity  float64 `json:"opacity,omitempty"`   // 0 to 1; 0 uses the mode's default
+	Size     int     `json:"size,omitempty"`      // Font size or image height in pixels; 0 uses the mode's default
+}
+
+// Validate checks the watermark and fills in defaults
+func (w *Watermark) Validate() error {
+	if w.Mode == "" {
+		w.Mode = WatermarkVisible
+	}
+	if w.Mode != WatermarkVisible && w.Mode != WatermarkForensic {
+		return fmt.Errorf("%w: mode must be %s or %s, got %q", ErrInvalidWatermark, WatermarkVisible, WatermarkForensic, w.Mode)
+	}
+
+	if (w.Text == "") == (w.ImageURL == "") {
+		return fmt.Errorf("%w: exactly one of text and image_url is required", ErrInvalidWatermark)
+	}
+	if w.Text != "" {
+		if !utf8.ValidString(w.Text) || strings.ContainsAny(w.Text, "\r\n") {
+			return fmt.Errorf("%w: text must be a single line of UTF-8", ErrInvalidWatermark)
+		}
+		if utf8.RuneCountInString(w.Text) > MaxWatermarkText {
+			return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidWatermark, MaxWatermarkText)
+		}
+	}
+	if w.ImageURL != "" {
+		if err := ValidateSourceURL(w.ImageURL); err != nil {
+			return fmt.Errorf("%w: image_url: %v", ErrInvalidWatermark, err)
+		}
+	}
+
+	switch w.Position {
+	case "":
+		if w.Mode == WatermarkVisible {
+			w.Position = WatermarkBottomRight
+		}
+	case WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight, WatermarkCenter:
+		if w.Mode == WatermarkForensic {
+			return fmt.Errorf("%w: forensic watermarks move between corners, so position does not apply", ErrInvalidWatermark)
+		}
+	default:
+		return fmt.Errorf("%w: unknown position %q", ErrInvalidWatermark, w.Position)
+	}
+
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("%w: opacity must be between 0 and 1", ErrInvalidWatermark)
+	}
+	if w.Opacity == 0 {
+		w.Opacity = DefaultVisibleOpacity
+		if w.Mode == WatermarkForensic {
+			w.Opacity = DefaultForensicOpacity
+		}
+	}
+
+	if w.Size < 0 || w.Size > MaxWatermarkSize {
+		return fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidWatermark, MaxWatermarkSize)
+	}
+	if w.Size == 0 {
+		w.Size = w.defaultSize()
+	}
+	return nil
+}
+
+func (w *Watermark) defaultSize() int {
+	switch {
+	case w.Mode == WatermarkForensic && w.Text != "":
+		return DefaultForensicTextSize
+	case w.Mode == WatermarkForensic:
+		return DefaultForensicImageSize
+	case w.Text != "":
+		return DefaultVisibleTextSize
+	default:
+		return DefaultVisibleImageSize
+	}
+}
+
+// Render returns the watermark text of the job
+func (w *Watermark) Render(jobID string) string {
+	return strings.ReplaceAll(w.Text, WatermarkJobIDPlaceholder, jobID)
+}
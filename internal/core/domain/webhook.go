@@ -1,26 +1,226 @@
 package domain
 
-import "time"
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "time"
+)
+
+var (
+    // ErrInvalidWebhook is returned for malformed webhook registrations
+    ErrInvalidWebhook = errors.New("invalid webhook")
+    // ErrWebhookNotFound is returned for webhooks that are not registered
+    ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// MaxWebhookURLLength limits the URL of a registered webhook
+const MaxWebhookURLLength = 2048
+
+// MaxWebhooksPerTenant limits the webhooks a tenant may register, so one
+// event cannot fan out to arbitrarily many deliveries
+const MaxWebhooksPerTenant = 20
 
+// WebhookConfig is a webhook endpoint: one from the configuration, which
+// receives the events of every tenant, or one registered through the API,
+// which has an ID and receives only the events of its tenant
 type WebhookConfig struct {
+    ID         string           `json:"id,omitempty"`
+    Tenant     string           `json:"tenant,omitempty"`
     URL        string           `json:"url"`
-    Secret     string           `json:"secret"`
-    EventTypes []WebhookEvent   `json:"event_types"`
+    Secret     string           `json:"secret,omitempty"` // Stored, never returned
+    EventTypes []WebhookEvent   `json:"event_types"`      // None for every event
+    CreatedBy  string           `json:"created_by,omitempty"`
+    CreatedAt  int64            `json:"created_at,omitempty"`
+    UpdatedAt  int64            `json:"updated_at,omitempty"`
+}
+
+// Endpoint identifies the webhook in queued retries: its ID when registered,
+// its URL when configured
+func (c WebhookConfig) Endpoint() string {
+    if c.ID != "" {
+        return c.ID
+    }
+    return c.URL
+}
+
+// Receives reports whether the webhook is sent an event of a tenant
+func (c WebhookConfig) Receives(tenant string) bool {
+    return c.ID == "" || c.Tenant == tenant
+}
+
+// WebhookRequest registers a webhook or replaces a registered one
+type WebhookRequest struct {
+    URL        string         `json:"url"`
+    Secret     string         `json:"secret,omitempty"`      // Signs deliveries; kept when omitted from an update
+    EventTypes []WebhookEvent `json:"event_types,omitempty"` // None for every event
+    Tenant     string         `json:"tenant,omitempty"`      // Admins only; the caller's tenant when empty
+}
+
+// Validate checks the webhook request
+func (r WebhookRequest) Validate() error {
+    if r.URL == "" {
+        return fmt.Errorf("%w: url is required", ErrInvalidWebhook)
+    }
+    if len(r.URL) > MaxWebhookURLLength {
+        return fmt.Errorf("%w: url must be at most %d characters", ErrInvalidWebhook, MaxWebhookURLLength)
+    }
+    u, err := url.Parse(r.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidWebhook)
+    }
+    if r.Secret == "" {
+        return fmt.Errorf("%w: secret is required", ErrInvalidWebhook)
+    }
+    for _, event := range r.EventTypes {
+        if !IsWebhookEvent(event) {
+            return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+        }
+    }
+    return nil
 }
 
 type WebhookEvent string
 
 const (
-    EventJobCompleted WebhookEvent = "job.completed"
-    EventJobFailed    WebhookEvent = "job.failed"
-    EventJobPaused    WebhookEvent = "job.paused"
-    EventJobResumed   WebhookEvent = "job.resumed"
+    EventJobStarted     WebhookEvent = "job.started"     // A worker picked the job up
+    EventJobCompleted   WebhookEvent = "job.completed"
+    EventJobFailed      WebhookEvent = "job.failed"
+    EventJobPaused      WebhookEvent = "job.paused"
+    EventJobResumed     WebhookEvent = "job.resumed"     // The job was queued again
+    EventJobStuck       WebhookEvent = "job.stuck"       // A running job stopped progressing or its worker stopped beating
+    EventBatchCompleted WebhookEvent = "batch.completed" // A batch operation was applied to all its jobs
 )
 
 type WebhookPayload struct {
     EventType WebhookEvent             `json:"event_type"`
     Timestamp time.Time                `json:"timestamp"`
-    JobID     string                   `json:"job_id"`
+    JobID     string                   `json:"job_id,omitempty"`
+    BatchID   string                   `json:"batch_id,omitempty"`
+    Tenant    string                   `json:"tenant,omitempty"`
     Data      map[string]interface{}   `json:"data"`
 	Signature string                   `json:"signature"`
 }
+
+// IsWebhookEvent reports whether event is one webhooks can subscribe to
+func IsWebhookEvent(event WebhookEvent) bool {
+    switch event {
+    case EventJobStarted, EventJobCompleted, EventJobFailed, EventJobPaused, EventJobResumed, EventJobStuck, EventBatchCompleted:
+        return true
+    }
+    return false
+}
+
+// QueuedEvent is a webhook payload waiting in the event queue. A retry names
+// the one endpoint it is for, so endpoints that already received the event do
+// not get it again.
+type QueuedEvent struct {
+    Payload  WebhookPayload `json:"payload"`
+    Endpoint string         `json:"endpoint,omitempty"` // WebhookConfig.Endpoint; empty for every subscribed endpoint
+    Attempts int            `json:"attempts,omitempty"` // Deliveries to Endpoint that failed so far
+}
+
+// JobOutcomeEvent returns the event of a job that finished, or false when the
+// job has not finished in a way webhooks are told about
+func JobOutcomeEvent(job *EncryptionJob) (WebhookEvent, bool) {
+    switch job.Status {
+    case StatusCompleted:
+        return EventJobCompleted, true
+    case StatusFailed:
+        return EventJobFailed, true
+    }
+    return "", false
+}
+
+// NewJobEvent describes a job as of an event. The decryption key is never
+// included.
+func NewJobEvent(eventType WebhookEvent, job *EncryptionJob, now time.Time) WebhookPayload {
+    data := map[string]interface{}{
+        "status":     job.Status,
+        "source_url": job.SourceURL,
+    }
+    if job.OutputPath != "" {
+        data["output_path"] = job.OutputPath
+    }
+    if job.Error != "" {
+        data["error"] = job.Error
+    }
+    if job.ErrorCode != "" {
+        data["error_code"] = job.ErrorCode
+    }
+    if len(job.Metadata) > 0 {
+        data["metadata"] = job.Metadata
+    }
+    return WebhookPayload{
+        EventType: eventType,
+        Timestamp: now,
+        JobID:     job.ID,
+        Tenant:    job.TenantID(),
+        Data:      data,
+    }
+}
+
+// NewBatchEvent describes a batch operation that was applied to all its jobs.
+// Only the counts are included; the batch's jobs are listed by its result.
+func NewBatchEvent(batch *BatchResult, now time.Time) WebhookPayload {
+    data := map[string]interface{}{
+        "action":        batch.Action,
+        "total_jobs":    batch.Summary.TotalJobs,
+        "success_count": batch.Summary.SuccessCount,
+        "failure_count": batch.Summary.FailureCount,
+    }
+    if batch.ParentBatchID != "" {
+        data["parent_batch_id"] = batch.ParentBatchID
+    }
+    return WebhookPayload{
+        EventType: EventBatchCompleted,
+        Timestamp: now,
+        BatchID:   batch.BatchID,
+        Tenant:    batch.TenantID(),
+        Data:      data,
+    }
+}
+
+// WebhookDeliveryError is a delivery the endpoint did not accept
+type WebhookDeliveryError struct {
+    StatusCode int // 0 when no response was received
+    Err        error
+}
+
+func (e *WebhookDeliveryError) Error() string {
+    if e.StatusCode != 0 {
+        return fmt.Sprintf("webhook failed with status: %d", e.StatusCode)
+    }
+    return fmt.Sprintf("failed to send webhook: %v", e.Err)
+}
+
+func (e *WebhookDeliveryError) Unwrap() error {
+    return e.Err
+}
+
+// Retryable reports whether the delivery may succeed when tried again: when
+// no response was received, as on timeouts, or the endpoint answered with a
+// server error or asked to be called later. Other responses mean the endpoint
+// rejects the delivery and would again.
+func (e *WebhookDeliveryError) Retryable() bool {
+    switch {
+    case e.StatusCode == 0, e.StatusCode >= 500:
+        return true
+    case e.StatusCode == 408, e.StatusCode == 429:
+        return true
+    }
+    return false
+}
+
+// WebhookDelivery is a delivery that failed for good and was moved to its
+// webhook's dead-letter list
+type WebhookDelivery struct {
+    ID         string         `json:"id"`
+    Endpoint   string         `json:"endpoint"` // WebhookConfig.Endpoint
+    URL        string         `json:"url"`
+    Payload    WebhookPayload `json:"payload"`
+    Attempts   int            `json:"attempts"`
+    StatusCode int            `json:"status_code,omitempty"` // Of the last attempt; 0 when no response was received
+    Error      string         `json:"error"`
+    FailedAt   int64          `json:"failed_at"`
+}
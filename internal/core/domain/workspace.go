@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Workspace is the scratch directory a worker keeps one job's files in while
+// it runs the job
+type Workspace struct {
+	JobID     string
+	Bytes     int64     // Size of the files in the workspace
+	UpdatedAt time.Time // Last change to any file in the workspace
+	Held      bool      // Acquired by this process and not yet released
+}
@@ -2,17 +2,52 @@ package ports
 
 import (
 	"context"
+	"io"
+
 	"E.E/internal/core/domain"
 )
 
 // EncryptionService defines the primary port for encryption operations
 type EncryptionService interface {
 	// StartEncryption initiates the encryption process for a video
-	StartEncryption(ctx context.Context, sourceURL string) (*domain.EncryptionJob, error)
+	StartEncryption(ctx context.Context, sourceURL string, opts domain.JobOptions) (*domain.EncryptionJob, error)
+
+	// StartUpload stores an uploaded source, size limited, and starts its
+	// encryption. The upload is deleted once the job ends.
+	StartUpload(ctx context.Context, filename string, content io.Reader, opts domain.JobOptions) (*domain.EncryptionJob, error)
+
+	// StartDecryption queues the decryption of an encryption job's output, or
+	// another object it encrypted, with the job's key
+	StartDecryption(ctx context.Context, req domain.DecryptionRequest) (*domain.EncryptionJob, error)
+
+	// RotateKey queues the re-encryption of a job's output under a new key,
+	// which retires the job's key once the new output is verified
+	RotateKey(ctx context.Context, jobID string, req domain.KeyRotationRequest) (*domain.EncryptionJob, error)
+
+	// VerifyJob reads a completed job's outputs back and checks them against
+	// their checksums, recording the outcome on the job
+	VerifyJob(ctx context.Context, jobID string) (*domain.JobVerification, error)
 
 	// GetJobStatus retrieves the current status of an encryption job
 	GetJobStatus(ctx context.Context, jobID string) (*domain.EncryptionJob, error)
 
+	// GetJobResult returns the output details of a completed job
+	GetJobResult(ctx context.Context, jobID string) (*domain.JobResult, error)
+
+	// GetOutputResult returns the output details of one output of a multi-output job
+	GetOutputResult(ctx context.Context, jobID, output string) (*domain.JobResult, error)
+
+	// GetJobKey returns the decryption key of a completed job the caller
+	// owns, or of one output of it, in the clear or wrapped for the caller,
+	// and records the retrieval in the key audit trail
+	GetJobKey(ctx context.Context, req domain.JobKeyRequest, access domain.KeyAccess) (*domain.JobKey, error)
+
+	// UpdateJob applies a partial update, such as metadata changes, to a job
+	UpdateJob(ctx context.Context, jobID string, req domain.JobUpdateRequest) (*domain.EncryptionJob, error)
+
+	// ExtendJobRetention keeps a job's record longer before it expires
+	ExtendJobRetention(ctx context.Context, jobID string, req domain.RetentionRequest) (*domain.EncryptionJob, error)
+
 	// PauseJob pauses an ongoing encryption job
 	PauseJob(ctx context.Context, jobID string) error
 
@@ -22,14 +57,36 @@ type EncryptionService interface {
 	// StopJob stops a specific encryption job
 	StopJob(ctx context.Context, jobID string) error
 
+	// RetryJob runs a failed job again as a new job linked to it
+	RetryJob(ctx context.Context, jobID string) (*domain.EncryptionJob, error)
+
+	// RescheduleJob moves the start of a scheduled job
+	RescheduleJob(ctx context.Context, jobID string, req domain.ScheduleRequest) (*domain.EncryptionJob, error)
+
+	// UnscheduleJob cancels a scheduled job before its start
+	UnscheduleJob(ctx context.Context, jobID string) error
+
 	// StopEngine stops the entire encryption engine
 	StopEngine() error
 
 	// ListJobs returns a list of jobs with optional filtering and pagination
 	ListJobs(ctx context.Context, limit, offset int, filter domain.JobFilter, sort domain.JobSort) ([]*domain.EncryptionJob, error)
 
+	// ExportJobs passes the jobs matching filter to emit in creation order,
+	// starting after the cursor, and returns the cursor to resume from when it
+	// stopped at limit with jobs left
+	ExportJobs(ctx context.Context, filter domain.JobFilter, after *domain.JobCursor, limit int, emit func(*domain.EncryptionJob) error) (*domain.JobCursor, error)
+
 	// GetJobsStatusSummary returns a summary of jobs grouped by status
-	GetJobsStatusSummary(ctx context.Context) (map[string]interface{}, error)
+	GetJobsStatusSummary(ctx context.Context) (*domain.JobsStatusSummary, error)
+
+	// GetQuota returns a tenant's quota and usage, the caller's tenant's when
+	// tenant is empty
+	GetQuota(ctx context.Context, tenant string) (*domain.QuotaStatus, error)
+
+	// AdmitJobs checks that the caller's tenant may submit jobs more jobs,
+	// failing with a *domain.QuotaExceededError if not
+	AdmitJobs(ctx context.Context, jobs int) error
 
 	// Batch operations
 	ProcessBatch(ctx context.Context, op domain.BatchOperation) (*domain.BatchResult, error)
@@ -37,13 +94,134 @@ type EncryptionService interface {
 
 	// Job history operations
 	GetJobHistory(ctx context.Context, jobID string) ([]domain.JobHistoryEntry, error)
+
+	// SubscribeToProgress returns the progress updates of a running job until
+	// ctx is done. The channel is nil when progress is not published.
+	SubscribeToProgress(ctx context.Context, jobID string) (<-chan domain.Progress, error)
+}
+
+// KeyService delivers the content keys of completed jobs to authorized
+// players and packagers
+type KeyService interface {
+	// GetKeyPolicy returns a key's access policy, or nil if it has none
+	GetKeyPolicy(ctx context.Context, key domain.ContentKey) (*domain.KeyPolicy, error)
+
+	// SetKeyPolicy replaces a key's access policy
+	SetKeyPolicy(ctx context.Context, key domain.ContentKey, req domain.KeyPolicyRequest, access domain.KeyAccess) (*domain.KeyPolicy, error)
+
+	// IssueKeyToken issues a token a client fetches a job's key with
+	IssueKeyToken(ctx context.Context, jobID string, req domain.KeyTokenRequest, access domain.KeyAccess) (*domain.KeyToken, error)
+
+	// DeliverKey returns the key a token grants if its policy allows. With
+	// key IDs given, the key must have one of them.
+	DeliverKey(ctx context.Context, token string, keyIDs [][]byte, access domain.KeyAccess) (*domain.DeliveredKey, error)
+
+	// GetKeyAudit returns up to limit audit events of a job's keys, newest first
+	GetKeyAudit(ctx context.Context, jobID string, limit int) ([]domain.KeyAuditEvent, error)
+}
+
+// ShareService mints and serves expiring share links to job results
+type ShareService interface {
+	// CreateShareLink mints a link to a completed job's output or key manifest
+	CreateShareLink(ctx context.Context, jobID string, req domain.ShareLinkRequest) (*domain.ShareLink, error)
+
+	// ListShareLinks returns the unexpired links of a job, newest first
+	ListShareLinks(ctx context.Context, jobID string) ([]*domain.ShareLink, error)
+
+	// RevokeShareLink stops a link from being opened
+	RevokeShareLink(ctx context.Context, jobID, linkID string) (*domain.ShareLink, error)
+
+	// OpenShareLink returns what a link token points to
+	OpenShareLink(ctx context.Context, token string) (*domain.SharedContent, error)
+}
+
+// WebhookRegistry manages the webhooks tenants register through the API
+type WebhookRegistry interface {
+	// CreateWebhook registers a webhook for the caller's tenant, or the
+	// requested one for admins
+	CreateWebhook(ctx context.Context, req domain.WebhookRequest) (*domain.WebhookConfig, error)
+
+	// ListWebhooks returns the webhooks of the caller's tenant, newest first.
+	// Admins see those of tenant, or of every tenant if tenant is empty.
+	ListWebhooks(ctx context.Context, tenant string) ([]*domain.WebhookConfig, error)
+
+	// GetWebhook returns a webhook without its secret
+	GetWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error)
+
+	// UpdateWebhook replaces a webhook's URL, secret and events
+	UpdateWebhook(ctx context.Context, id string, req domain.WebhookRequest) (*domain.WebhookConfig, error)
+
+	// DeleteWebhook stops a webhook from receiving events
+	DeleteWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error)
+
+	// ListWebhookDeliveries returns up to limit deliveries to a webhook that
+	// failed for good, newest first
+	ListWebhookDeliveries(ctx context.Context, id string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// RecurringBatches manages the batches tenants have started on a schedule
+type RecurringBatches interface {
+	// CreateRecurring registers a recurring batch for the caller's tenant, or
+	// the requested one for admins
+	CreateRecurring(ctx context.Context, req domain.RecurringRequest) (*domain.RecurringBatch, error)
+
+	// ListRecurring returns the recurring batches of the caller's tenant,
+	// newest first. Admins see those of tenant, or of every tenant if tenant
+	// is empty.
+	ListRecurring(ctx context.Context, tenant string) ([]*domain.RecurringBatch, error)
+
+	// GetRecurring returns a recurring batch
+	GetRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error)
+
+	// UpdateRecurring replaces a recurring batch's schedule and template
+	UpdateRecurring(ctx context.Context, id string, req domain.RecurringRequest) (*domain.RecurringBatch, error)
+
+	// DeleteRecurring stops a recurring batch from running
+	DeleteRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error)
+}
+
+// ResumableUploads receives sources uploaded in chunks with the tus protocol
+// and starts a job for each once it is complete
+type ResumableUploads interface {
+	// CreateUpload announces an upload of length bytes whose job is started
+	// with the options of req
+	CreateUpload(ctx context.Context, length int64, filename string, req domain.EncryptionRequest) (*domain.ResumableUpload, error)
+
+	// GetUpload returns an upload with the bytes received so far
+	GetUpload(ctx context.Context, id string) (*domain.ResumableUpload, error)
+
+	// WriteChunk appends chunk to an upload at offset, which must be the
+	// upload's offset. The chunk that completes the upload starts its job.
+	WriteChunk(ctx context.Context, id string, offset int64, chunk io.Reader) (*domain.ResumableUpload, error)
+
+	// DeleteUpload abandons an upload and deletes the bytes received
+	DeleteUpload(ctx context.Context, id string) error
 }
 
-// EncryptionProgress represents a progress update channel
-type EncryptionProgress interface {
-	// UpdateProgress updates the progress of an encryption job
-	UpdateProgress(jobID string, progress float64) error
+// APIKeyService creates, revokes and checks the API keys callers
+// authenticate with
+type APIKeyService interface {
+	// CreateAPIKey creates a key, returning it with its secret
+	CreateAPIKey(ctx context.Context, req domain.APIKeyRequest) (*domain.APIKey, error)
 
-	// SubscribeToProgress subscribes to progress updates for a job
-	SubscribeToProgress(jobID string) (<-chan float64, error)
-}
\ No newline at end of file
+	// ListAPIKeys returns the keys of an owner, or of every owner if owner is
+	// empty, newest first
+	ListAPIKeys(ctx context.Context, owner string) ([]*domain.APIKey, error)
+
+	// GetAPIKey returns a key without its secret
+	GetAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error)
+
+	// RevokeAPIKey stops a key from being used
+	RevokeAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error)
+
+	// Authenticate returns the principal a key secret acts for
+	Authenticate(ctx context.Context, secret string) (domain.Principal, error)
+}
+
+// JobImporter stores historical jobs migrated from another encryption system
+type JobImporter interface {
+	// ImportJobs reads newline-delimited JSON job records and stores the valid
+	// ones whose IDs are not taken. The error is set only when reading fails;
+	// the result then covers the records read so far.
+	ImportJobs(ctx context.Context, records io.Reader, opts domain.ImportOptions) (*domain.ImportResult, error)
+}
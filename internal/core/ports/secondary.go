@@ -2,10 +2,18 @@ package ports
 import (
 	"context"
 	"io"
+	"time"
 
 	"E.E/internal/core/domain"
 )
 
+// Clock tells the time. Services and repositories read it instead of calling
+// time.Now, so time can be controlled in tests and simulations.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
 // FileStorage defines the interface for file storage operations
 type FileStorage interface {
 	// ReadFile reads a file from storage
@@ -19,6 +27,176 @@ type FileStorage interface {
 
 	// FileExists checks if a file exists in storage
 	FileExists(path string) bool
+
+	// URL returns the location of a stored file for callers outside the service
+	URL(path string) string
+}
+
+// OutputDestinations opens the storage of the destinations jobs name for
+// their outputs
+type OutputDestinations interface {
+	// Storage returns the storage writing to a destination. Its paths are
+	// relative to the destination's prefix.
+	Storage(destination domain.OutputDestination) (FileStorage, error)
+
+	// CheckWritable verifies that files can be written to a destination
+	CheckWritable(ctx context.Context, destination domain.OutputDestination) error
+}
+
+// SourceLister enumerates the objects stored under a location so that they can
+// be expanded into individual encryption jobs
+type SourceLister interface {
+	// ListObjects returns the source URLs of all objects under location/prefix
+	ListObjects(ctx context.Context, location, prefix string) ([]string, error)
+}
+
+// SourceFetcher opens the content of a job's source URL
+type SourceFetcher interface {
+	// Open returns a reader for the source and its size in bytes (-1 if unknown)
+	Open(ctx context.Context, sourceURL string) (io.ReadCloser, int64, error)
+}
+
+// SourceRangeFetcher is implemented by fetchers that can open a source
+// partway, so resumed jobs do not download what they already encrypted
+type SourceRangeFetcher interface {
+	// OpenAt returns a reader for the source from offset and the size of the
+	// rest in bytes (-1 if unknown)
+	OpenAt(ctx context.Context, sourceURL string, offset int64) (io.ReadCloser, int64, error)
+}
+
+// SourceInspector describes sources without reading them, for pre-flight
+// validation of jobs
+type SourceInspector interface {
+	// Inspect returns the size and content type of the source at sourceURL,
+	// or an error if it does not exist or cannot be reached
+	Inspect(ctx context.Context, sourceURL string) (*domain.SourceInfo, error)
+}
+
+// MediaProber inspects a source's container, codecs and duration
+type MediaProber interface {
+	// Probe describes the media at sourceURL
+	Probe(ctx context.Context, sourceURL string) (*domain.MediaInfo, error)
+}
+
+// Transcoder converts a source into renditions before it is encrypted
+type Transcoder interface {
+	// Transcode converts the source at sourceURL as params describe, calling
+	// progress with the fraction done, and returns a reader for the packaged
+	// renditions and its size. Scratch files are written under dir, or the
+	// transcoder's own scratch directory when dir is empty, and closing the
+	// reader removes them. A file:// source inside dir is read where it is
+	// rather than downloaded. Errors converting the source wrap
+	// domain.ErrTranscodeFailed. Renditions of params with drm are protected
+	// with key, which is nil otherwise.
+	Transcode(ctx context.Context, dir, sourceURL string, params domain.TranscodeParams, key *domain.DRMKey, progress func(float64)) (io.ReadCloser, int64, error)
+}
+
+// SourceProcessor is a step workers run on a job's source before transcoding
+// or encrypting it. Workers chain the processors that apply to a job, each
+// reading the file the previous one wrote.
+type SourceProcessor interface {
+	// Stage is the progress stage reported while the processor runs
+	Stage() domain.ProgressStage
+
+	// Applies reports whether the job asks for this processor
+	Applies(job *domain.EncryptionJob) bool
+
+	// Process reads the source at input and writes the processed source to
+	// output, both in the job's workspace, calling progress with the
+	// fraction done
+	Process(ctx context.Context, job *domain.EncryptionJob, input, output string, progress func(float64)) error
+}
+
+// Workspaces hands out the scratch directories workers keep a job's files in
+// while they run it
+type Workspaces interface {
+	// Acquire returns an empty directory for the job, replacing any left by an
+	// earlier run, or the directory this process already acquired for it. It
+	// fails with an error wrapping diskspace.ErrLow when the
+	// workspaces already use their quota.
+	Acquire(jobID string) (string, error)
+
+	// Release removes the job's workspace and the files in it
+	Release(jobID string) error
+
+	// List describes every workspace, including those left behind by
+	// processes that crashed
+	List() ([]domain.Workspace, error)
+}
+
+// JobQueue hands job IDs from the API to the encryption workers
+type JobQueue interface {
+	// Enqueue schedules a job for processing
+	Enqueue(ctx context.Context, jobID string) error
+
+	// Dequeue blocks until a job is available or ctx is done
+	Dequeue(ctx context.Context) (string, error)
+}
+
+// EventQueue holds job events until they are delivered to webhooks, so job
+// outcomes are recorded without waiting for the receivers
+type EventQueue interface {
+	// Publish queues an event for delivery
+	Publish(ctx context.Context, event domain.QueuedEvent) error
+
+	// Consume blocks until an event is available or ctx is done
+	Consume(ctx context.Context) (domain.QueuedEvent, error)
+}
+
+// EncryptionProgress carries the progress of running jobs from the workers to
+// subscribers as it is reported, alongside the progress stored with each job
+type EncryptionProgress interface {
+	// UpdateProgress publishes a job's progress to its subscribers
+	UpdateProgress(ctx context.Context, jobID string, progress domain.Progress) error
+
+	// SubscribeToProgress returns a job's progress updates until ctx is done,
+	// when the channel is closed. Updates a slow subscriber cannot take are
+	// dropped.
+	SubscribeToProgress(ctx context.Context, jobID string) (<-chan domain.Progress, error)
+}
+
+// JobLocks lease jobs to the workers running them, so each job is run by one
+// worker even when several processes take jobs from the same queue
+type JobLocks interface {
+	// Acquire leases the job to owner for ttl, returning false if another
+	// owner holds it
+	Acquire(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error)
+
+	// Renew extends owner's lease to ttl from now, returning false if owner no
+	// longer holds it
+	Renew(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error)
+
+	// Release ends owner's lease; a lease another owner took is left alone
+	Release(ctx context.Context, jobID, owner string) error
+}
+
+// LeaderLocks elect one leader among the processes for work only one of them
+// may do at a time, such as starting recurring batches. A leader holds the
+// named lock until it stops renewing it.
+type LeaderLocks interface {
+	// Acquire makes owner the leader for ttl, returning false if another
+	// owner leads
+	Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+
+	// Renew extends owner's lead to ttl from now, returning false if owner no
+	// longer leads
+	Renew(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+
+	// Release ends owner's lead; a lead another owner took is left alone
+	Release(ctx context.Context, name, owner string) error
+}
+
+// JobHeartbeats records when workers last reported holding each running job,
+// apart from the job itself so beats never race with its state changes
+type JobHeartbeats interface {
+	// Beat records that a worker holds the job at at
+	Beat(ctx context.Context, jobID string, at time.Time) error
+
+	// Clear forgets the job's heartbeat once its worker lets go of it
+	Clear(ctx context.Context, jobID string) error
+
+	// Last returns the latest heartbeat of each of the jobs that has one
+	Last(ctx context.Context, jobIDs []string) (map[string]time.Time, error)
 }
 
 // JobRepository defines the interface for job persistence operations
@@ -29,12 +207,34 @@ type JobRepository interface {
 	// Update modifies an existing encryption job
 	Update(ctx context.Context, job *domain.EncryptionJob) error
 
+	// UpdateIf modifies an existing encryption job only if its stored version
+	// satisfies cond, atomically, reporting whether it did. Writers that must
+	// not overwrite a concurrent change, such as a stop, use it.
+	UpdateIf(ctx context.Context, job *domain.EncryptionJob, cond func(current *domain.EncryptionJob) bool) (bool, error)
+
 	// Get retrieves an encryption job by ID
 	Get(ctx context.Context, jobID string) (*domain.EncryptionJob, error)
 
+	// GetMany retrieves encryption jobs by ID in as few round trips as the
+	// repository allows. The result is in the order of jobIDs, with nil for
+	// jobs that do not exist.
+	GetMany(ctx context.Context, jobIDs []string) ([]*domain.EncryptionJob, error)
+
 	// List retrieves all encryption jobs
 	List(ctx context.Context) ([]*domain.EncryptionJob, error)
 
+	// Query retrieves the jobs matching a query, reading as few records as
+	// the repository's indexes allow
+	Query(ctx context.Context, query domain.JobQuery) ([]*domain.EncryptionJob, error)
+
+	// Aggregate returns the job counts and totals behind the status summary,
+	// with the latest created jobs
+	Aggregate(ctx context.Context, now time.Time, latest int) (*domain.JobAggregates, error)
+
+	// TenantUsage returns what a tenant's jobs count against its quota: its
+	// active jobs, the jobs it created since since and its stored bytes
+	TenantUsage(ctx context.Context, tenant string, since time.Time) (*domain.QuotaUsage, error)
+
 	// Delete removes an encryption job
 	Delete(ctx context.Context, jobID string) error
 
@@ -51,14 +251,42 @@ type JobRepository interface {
 
 // EncryptionEngine defines the interface for encryption operations
 type EncryptionEngine interface {
-	// Encrypt encrypts a file
-	Encrypt(input io.Reader, output io.Writer) (string, error)
+	// Encrypt encrypts a file with the given parameters and returns the key
+	Encrypt(input io.Reader, output io.Writer, params domain.EngineParams) (string, error)
+
+	// EncryptWithKey encrypts a file with the given hex encoded key, e.g. a
+	// customer's own
+	EncryptWithKey(input io.Reader, output io.Writer, key string, params domain.EngineParams) error
 
 	// Decrypt decrypts a file
 	Decrypt(input io.Reader, output io.Writer, key string) error
 
-	// GenerateKey generates a new encryption key
-	GenerateKey() (string, error)
+	// GenerateKey generates a new key of the length params call for
+	GenerateKey(params domain.EngineParams) (string, error)
+}
+
+// StreamingEncryptionEngine is implemented by engines that can encrypt a
+// stream in pieces and continue it later, which checkpointed jobs need
+type StreamingEncryptionEngine interface {
+	// BeginStream writes the header of a stream encrypted with key to output
+	BeginStream(output io.Writer, key string, params domain.EngineParams) (EncryptionStream, error)
+
+	// ResumeStream continues the stream whose header is read from header,
+	// from the chunk with index chunk
+	ResumeStream(header io.Reader, key string, chunk uint32) (EncryptionStream, error)
+}
+
+// EncryptionStream seals a stream one chunk at a time
+type EncryptionStream interface {
+	// ChunkSize is the most plaintext a chunk holds
+	ChunkSize() int
+
+	// Chunk returns the index of the next chunk
+	Chunk() uint32
+
+	// Seal encrypts the next chunk of plaintext to output; final marks the
+	// last chunk of the stream
+	Seal(output io.Writer, plaintext []byte, final bool) error
 }
 
 // Add a new interface for batch operations persistence
@@ -77,4 +305,202 @@ type BatchRepository interface {
 
 	// Close closes the repository connection
 	Close() error
-}
\ No newline at end of file
+}
+// ObjectEventSource delivers notifications of new source objects, such as S3
+// event notifications read from SQS
+type ObjectEventSource interface {
+	// Receive blocks until notifications are available or ctx is done
+	Receive(ctx context.Context) ([]domain.ObjectNotification, error)
+
+	// Ack removes a handled notification
+	Ack(ctx context.Context, n domain.ObjectNotification) error
+
+	// Retry delivers a notification again after delay
+	Retry(ctx context.Context, n domain.ObjectNotification, delay time.Duration) error
+}
+
+// DedupeStore remembers keys for a while, so work triggered more than once is
+// done once
+type DedupeStore interface {
+	// Claim records key for ttl, returning false if it is already recorded
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Release forgets key so it can be claimed again
+	Release(ctx context.Context, key string) error
+}
+
+// TaskLauncher runs jobs outside this process, such as in Kubernetes Jobs.
+// A launched task processes its job like a worker, recording the outcome in
+// the job repository.
+type TaskLauncher interface {
+	// Launch starts a task processing the job; launching a job's task twice
+	// is not an error
+	Launch(ctx context.Context, jobID string) error
+
+	// State returns the state of a job's task
+	State(ctx context.Context, jobID string) (domain.TaskState, error)
+
+	// Remove stops a job's task and deletes it
+	Remove(ctx context.Context, jobID string) error
+
+	// List returns the IDs of the jobs whose tasks still exist
+	List(ctx context.Context) ([]string, error)
+}
+
+// KeyPolicyStore keeps the access policies of content keys and counts their
+// deliveries
+type KeyPolicyStore interface {
+	// GetPolicy returns the key's policy, or nil if it has none
+	GetPolicy(ctx context.Context, key domain.ContentKey) (*domain.KeyPolicy, error)
+
+	// SavePolicy creates or replaces a key's policy
+	SavePolicy(ctx context.Context, policy *domain.KeyPolicy) error
+
+	// CountDelivery records a delivery of the key. With limit above 0 it
+	// records nothing and returns false once limit deliveries were recorded.
+	CountDelivery(ctx context.Context, key domain.ContentKey, limit int) (bool, error)
+}
+
+// KeyStore envelope-encrypts content keys under a master key it holds, e.g. in
+// AWS KMS or HashiCorp Vault, so jobs persist only a sealed reference to their
+// key. A sealed key is bound to the content key it was sealed for and cannot
+// be opened as another.
+type KeyStore interface {
+	// Seal encrypts the hex encoded material of key and returns the
+	// reference to persist in its place
+	Seal(ctx context.Context, key domain.ContentKey, material string) (string, error)
+
+	// Open returns the hex encoded material a reference returned by Seal
+	// stands for
+	Open(ctx context.Context, key domain.ContentKey, ref string) (string, error)
+}
+
+// CustomerKeyStore is implemented by key stores that can use keys callers
+// bring: their own content keys, wrapped for the store, or their own master
+// keys to seal generated content keys under
+type CustomerKeyStore interface {
+	KeyStore
+
+	// Unwrap returns the hex encoded content key a customer wrapped for the
+	// store. The result must never be persisted.
+	Unwrap(ctx context.Context, wrapped string) (string, error)
+
+	// SealWith is Seal under the customer's master key masterKeyID. The
+	// reference is opened by Open like any other.
+	SealWith(ctx context.Context, masterKeyID string, key domain.ContentKey, material string) (string, error)
+}
+
+// KeyAuditLog keeps the audit trail of content key accesses
+type KeyAuditLog interface {
+	// Record appends an event to the trail of the event's job
+	Record(ctx context.Context, event domain.KeyAuditEvent) error
+
+	// List returns up to limit events of a job's keys, newest first
+	List(ctx context.Context, jobID string, limit int) ([]domain.KeyAuditEvent, error)
+}
+
+// ShareLinkStore keeps the share links of jobs
+type ShareLinkStore interface {
+	// SaveLink creates or replaces a link, keeping it until it expires
+	SaveLink(ctx context.Context, link *domain.ShareLink) error
+
+	// GetLink returns a link, or nil if it does not exist
+	GetLink(ctx context.Context, linkID string) (*domain.ShareLink, error)
+
+	// ListLinks returns the unexpired links of a job, newest first
+	ListLinks(ctx context.Context, jobID string) ([]*domain.ShareLink, error)
+}
+
+// APIKeyStore keeps the API keys created through the API
+type APIKeyStore interface {
+	// SaveAPIKey creates or replaces a key. Revoked and expired keys are no
+	// longer found by their secret.
+	SaveAPIKey(ctx context.Context, key *domain.APIKey) error
+
+	// GetAPIKey returns a key, or nil if it does not exist
+	GetAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error)
+
+	// FindAPIKey returns the active key with a secret hash, or nil if there
+	// is none
+	FindAPIKey(ctx context.Context, secretHash string) (*domain.APIKey, error)
+
+	// ListAPIKeys returns every stored key
+	ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error)
+}
+
+// WebhookRepository keeps the webhooks registered through the API
+type WebhookRepository interface {
+	// SaveWebhook creates or replaces a webhook
+	SaveWebhook(ctx context.Context, webhook *domain.WebhookConfig) error
+
+	// GetWebhook returns a webhook, or nil if it does not exist
+	GetWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error)
+
+	// ListWebhooks returns the webhooks of a tenant, or of every tenant if
+	// tenant is empty
+	ListWebhooks(ctx context.Context, tenant string) ([]*domain.WebhookConfig, error)
+
+	// DeleteWebhook removes a webhook and its dead-letter list; removing one
+	// that does not exist is not an error
+	DeleteWebhook(ctx context.Context, id string) error
+}
+
+// UploadStore keeps the state of resumable uploads until they are deleted
+type UploadStore interface {
+	// SaveUpload creates or replaces an upload
+	SaveUpload(ctx context.Context, upload *domain.ResumableUpload) error
+
+	// GetUpload returns an upload, or nil if it does not exist
+	GetUpload(ctx context.Context, id string) (*domain.ResumableUpload, error)
+
+	// ExpiredUploads returns the uploads that expired before now
+	ExpiredUploads(ctx context.Context, now time.Time) ([]*domain.ResumableUpload, error)
+
+	// DeleteUpload removes an upload; removing one that does not exist is not
+	// an error
+	DeleteUpload(ctx context.Context, id string) error
+}
+
+// RecurringRepository keeps the recurring batches registered through the API
+type RecurringRepository interface {
+	// SaveRecurring creates or replaces a recurring batch
+	SaveRecurring(ctx context.Context, recurring *domain.RecurringBatch) error
+
+	// GetRecurring returns a recurring batch, or nil if it does not exist
+	GetRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error)
+
+	// ListRecurring returns the recurring batches of a tenant, or of every
+	// tenant if tenant is empty
+	ListRecurring(ctx context.Context, tenant string) ([]*domain.RecurringBatch, error)
+
+	// DeleteRecurring removes a recurring batch; removing one that does not
+	// exist is not an error
+	DeleteRecurring(ctx context.Context, id string) error
+}
+
+// WebhookDeadLetters keeps the webhook deliveries that failed for good, per
+// endpoint
+type WebhookDeadLetters interface {
+	// RecordDeadLetter adds a failed delivery to its endpoint's list, dropping
+	// the oldest ones once the list is full
+	RecordDeadLetter(ctx context.Context, delivery *domain.WebhookDelivery) error
+
+	// ListDeadLetters returns up to limit failed deliveries to an endpoint,
+	// newest first
+	ListDeadLetters(ctx context.Context, endpoint string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// TokenVerifier checks bearer tokens issued by an identity provider
+type TokenVerifier interface {
+	// VerifyToken returns the principal a token acts for. Tokens that are
+	// malformed, forged, expired or meant for someone else fail with
+	// domain.ErrUnauthenticated.
+	VerifyToken(ctx context.Context, token string) (domain.Principal, error)
+}
+
+// URLPresigner is implemented by storage that can hand out time-limited URLs
+// to stored files, letting share links redirect instead of serving the file
+type URLPresigner interface {
+	// PresignURL returns a URL to path that stays valid for ttl
+	PresignURL(ctx context.Context, path string, ttl time.Duration) (string, error)
+}
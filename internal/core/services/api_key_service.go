@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// apiKeySecretBytes is the entropy of the secrets of created keys
+const apiKeySecretBytes = 32
+
+// apiKeyPrefixLength is how much of a secret is kept to recognise its key by
+const apiKeyPrefixLength = len(domain.APIKeySecretPrefix) + 8
+
+// APIKeyService authenticates callers by the API keys in the configuration
+// and those created through the API, and by JWTs from an identity provider
+// when a token verifier is set. Created keys carry scopes, are looked up by
+// the SHA-256 hash of their secret and are checked on every request, so
+// revoking a key takes effect at once.
+type APIKeyService struct {
+	store  ports.APIKeyStore
+	static map[string]domain.Principal
+	tokens ports.TokenVerifier
+	clock  ports.Clock
+	logger *zap.Logger
+}
+
+// NewAPIKeyService creates a service accepting the configured keys in static
+// and the keys in store
+func NewAPIKeyService(store ports.APIKeyStore, static map[string]domain.Principal, logger *zap.Logger) *APIKeyService {
+	return &APIKeyService{
+		store:  store,
+		static: static,
+		clock:  clock.System{},
+		logger: logger,
+	}
+}
+
+// SetClock replaces the system clock used for key lifetimes
+func (s *APIKeyService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+// SetTokenVerifier accepts JWTs checked by verifier alongside API keys
+func (s *APIKeyService) SetTokenVerifier(verifier ports.TokenVerifier) {
+	s.tokens = verifier
+}
+
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, req domain.APIKeyRequest) (*domain.APIKey, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.Admin {
+		return nil, fmt.Errorf("%w: %q may not manage API keys", domain.ErrForbidden, principal.ID)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret := domain.APIKeySecretPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	now := s.clock.Now()
+	key := &domain.APIKey{
+		ID:         uuid.New().String(),
+		Name:       req.Name,
+		Owner:      req.Owner,
+		Tenant:     req.Tenant,
+		Scopes:     req.Scopes,
+		Prefix:     secret[:apiKeyPrefixLength],
+		SecretHash: hashAPIKey(secret),
+		CreatedBy:  principal.ID,
+		CreatedAt:  now.Unix(),
+	}
+	if req.TTLSeconds > 0 {
+		key.ExpiresAt = now.Add(time.Duration(req.TTLSeconds) * time.Second).Unix()
+	}
+	if err := s.store.SaveAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("API key created",
+		zap.String("key_id", key.ID),
+		zap.String("owner", key.Owner),
+		zap.String("tenant", key.Tenant),
+		zap.Strings("scopes", key.Scopes),
+		zap.String("created_by", key.CreatedBy),
+		zap.Int64("expires_at", key.ExpiresAt))
+
+	key.Secret = secret
+	key.SecretHash = ""
+	return key, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, owner string) ([]*domain.APIKey, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.Admin {
+		return nil, fmt.Errorf("%w: %q may not manage API keys", domain.ErrForbidden, principal.ID)
+	}
+	keys, err := s.store.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make([]*domain.APIKey, 0, len(keys))
+	for _, key := range keys {
+		if owner != "" && key.Owner != owner {
+			continue
+		}
+		key.SecretHash = ""
+		listed = append(listed, key)
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].CreatedAt > listed[j].CreatedAt })
+	return listed, nil
+}
+
+func (s *APIKeyService) GetAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	key, err := s.storedKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	key.SecretHash = ""
+	return key, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	key, err := s.storedKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == 0 {
+		key.RevokedAt = s.clock.Now().Unix()
+		if err := s.store.SaveAPIKey(ctx, key); err != nil {
+			return nil, err
+		}
+		s.logger.Info("API key revoked",
+			zap.String("key_id", key.ID),
+			zap.String("owner", key.Owner),
+			zap.String("revoked_by", domain.PrincipalFromContext(ctx).ID))
+	}
+	key.SecretHash = ""
+	return key, nil
+}
+
+// Authenticate checks the configured keys first, then JWTs with the token
+// verifier and the stored keys
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (domain.Principal, error) {
+	if principal, ok := s.static[secret]; ok && secret != "" {
+		return principal, nil
+	}
+	if s.tokens != nil && strings.Count(secret, ".") == 2 {
+		return s.tokens.VerifyToken(ctx, secret)
+	}
+	if !strings.HasPrefix(secret, domain.APIKeySecretPrefix) {
+		return domain.Principal{}, domain.ErrUnauthenticated
+	}
+
+	key, err := s.store.FindAPIKey(ctx, hashAPIKey(secret))
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	if key == nil || !key.Active(s.clock.Now().Unix()) {
+		return domain.Principal{}, domain.ErrUnauthenticated
+	}
+	return key.Principal(), nil
+}
+
+// storedKey returns a stored key for an admin
+func (s *APIKeyService) storedKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.Admin {
+		return nil, fmt.Errorf("%w: %q may not manage API keys", domain.ErrForbidden, principal.ID)
+	}
+	key, err := s.store.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrAPIKeyNotFound, keyID)
+	}
+	return key, nil
+}
+
+// hashAPIKey returns the hash a key is stored and looked up by. Secrets are
+// random, so an unsalted hash is enough.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
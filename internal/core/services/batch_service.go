@@ -3,18 +3,44 @@ package services
 import (
     "context"
     "fmt"
+    "path"
+    "strings"
+    "sync"
     "time"
     "github.com/google/uuid"
 
     "go.uber.org/zap"
     "E.E/internal/core/domain"
     "E.E/internal/core/ports"
+    "E.E/pkg/clock"
+    "E.E/pkg/metrics"
 )
 
+// Source kinds a batch can be expanded from
+const (
+    SourceKindS3    = "s3"
+    SourceKindLocal = "local"
+)
+
+// batchStartWorkers is how many jobs of a batch are created at once. Creating
+// a job preflights its source over the network, so a large batch would
+// otherwise spend most of its request waiting on them one at a time.
+const batchStartWorkers = 8
+
 type BatchService struct {
     encryptionService ports.EncryptionService
     jobRepository     ports.JobRepository
     batchRepository   ports.BatchRepository
+    sourceListers     map[string]ports.SourceLister
+    outputStorage     ports.FileStorage
+    destinations      ports.OutputDestinations
+    clock             ports.Clock
+    engineLimits      domain.EngineLimits
+    transcoding       bool
+    transcodeProfiles domain.TranscodeProfiles
+    watermarking      bool
+    metrics           *metrics.Metrics
+    events            ports.EventQueue
     logger           *zap.Logger
 }
 
@@ -28,113 +54,197 @@ func NewBatchService(
         encryptionService: encryptionService,
         jobRepository:     jobRepository,
         batchRepository:   batchRepository,
+        sourceListers:     make(map[string]ports.SourceLister),
+        clock:             clock.System{},
         logger:           logger,
     }
 }
 
-// BatchValidationError represents validation errors with improved details
-type BatchValidationError struct {
-    Field   string `json:"field"`
-    Message string `json:"message"`
-    Value   string `json:"value,omitempty"`
+// RegisterSourceLister makes a source kind available for bucket/prefix expansion
+func (s *BatchService) RegisterSourceLister(kind string, lister ports.SourceLister) {
+    s.sourceListers[kind] = lister
 }
 
-func (s *BatchService) validateBatchOperation(op domain.BatchOperation) []BatchValidationError {
-    var errors []BatchValidationError
+// SetOutputStorage sets the storage that job outputs are written to, used to
+// clean up outputs when a batch is rolled back
+func (s *BatchService) SetOutputStorage(storage ports.FileStorage) {
+    s.outputStorage = storage
+}
 
-    // Validate action
-    if op.Action == "" {
-        errors = append(errors, BatchValidationError{
-            Field:   "action",
-            Message: "action is required",
-        })
-    } else {
-        // Validate action is supported
-        validActions := map[domain.BatchAction]bool{
-            domain.BatchActionStart:  true,
-            domain.BatchActionPause:  true,
-            domain.BatchActionResume: true,
-            domain.BatchActionStop:   true,
-            domain.BatchActionRetry:  true,
-        }
-        if !validActions[op.Action] {
-            errors = append(errors, BatchValidationError{
-                Field:   "action",
-                Message: "unsupported action",
-                Value:   string(op.Action),
+// SetDestinations opens the destinations jobs name for their outputs, so
+// outputs written there are cleaned up too
+func (s *BatchService) SetDestinations(destinations ports.OutputDestinations) {
+    s.destinations = destinations
+}
+
+// expandSource lists the objects under a batch source and returns the source
+// URLs that pass its include/exclude filters
+func (s *BatchService) expandSource(ctx context.Context, src *domain.BatchSource) ([]string, error) {
+    kind, location := SourceKindS3, src.Bucket
+    if src.Directory != "" {
+        kind, location = SourceKindLocal, src.Directory
+    }
+
+    lister, ok := s.sourceListers[kind]
+    if !ok {
+        return nil, fmt.Errorf("no lister registered for %s sources", kind)
+    }
+
+    objects, err := lister.ListObjects(ctx, location, src.Prefix)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list %s source %s: %w", kind, location, err)
+    }
+
+    urls := make([]string, 0, len(objects))
+    for _, object := range objects {
+        if len(src.Include) > 0 && !matchesAnyGlob(object, src.Include) {
+            continue
+        }
+        if matchesAnyGlob(object, src.Exclude) {
+            continue
+        }
+        urls = append(urls, object)
+    }
+
+    s.logger.Info("Expanded batch source",
+        zap.String("kind", kind),
+        zap.String("location", location),
+        zap.String("prefix", src.Prefix),
+        zap.Int("listed", len(objects)),
+        zap.Int("matched", len(urls)))
+
+    return urls, nil
+}
+
+// findDuplicateSources returns the source URLs with duplicates removed (first
+// occurrence wins), a summary of each merged URL, and one validation error per
+// duplicate row
+func findDuplicateSources(sourceURLs []string) ([]string, []domain.BatchDuplicate, domain.ValidationErrors) {
+    firstIndex := make(map[string]int, len(sourceURLs))
+    occurrences := make(map[string]int)
+    unique := make([]string, 0, len(sourceURLs))
+    var errors domain.ValidationErrors
+
+    for i, sourceURL := range sourceURLs {
+        key := strings.TrimSpace(sourceURL)
+        if first, seen := firstIndex[key]; seen {
+            occurrences[key]++
+            errors = append(errors, domain.BatchValidationError{
+                Field:   fmt.Sprintf("source_urls[%d]", i),
+                Message: fmt.Sprintf("duplicate of source_urls[%d]", first),
+                Value:   sourceURL,
             })
+            continue
         }
+        firstIndex[key] = i
+        occurrences[key] = 1
+        unique = append(unique, sourceURL)
     }
 
-    // Action-specific validations
-    switch op.Action {
-    case domain.BatchActionStart:
-        if len(op.SourceURLs) == 0 {
-            errors = append(errors, BatchValidationError{
-                Field:   "source_urls",
-                Message: "at least one source URL is required for start action",
+    var duplicates []domain.BatchDuplicate
+    for _, sourceURL := range unique {
+        if n := occurrences[strings.TrimSpace(sourceURL)]; n > 1 {
+            duplicates = append(duplicates, domain.BatchDuplicate{
+                SourceURL:   sourceURL,
+                Occurrences: n,
             })
         }
-        // Validate each source URL
-        for i, url := range op.SourceURLs {
-            if url == "" {
-                errors = append(errors, BatchValidationError{
-                    Field:   fmt.Sprintf("source_urls[%d]", i),
-                    Message: "source URL cannot be empty",
-                })
+    }
+
+    return unique, duplicates, errors
+}
+
+// matchesAnyGlob reports whether any pattern matches the object's path. Patterns
+// are tried against every trailing run of path segments, so "*.mp4" matches on
+// the file name and "videos/*.mp4" on the last two segments.
+func matchesAnyGlob(objectURL string, patterns []string) bool {
+    p := objectURL
+    if i := strings.Index(p, "://"); i >= 0 {
+        p = p[i+3:]
+    }
+    segments := strings.Split(p, "/")
+
+    for _, pattern := range patterns {
+        for i := range segments {
+            if ok, _ := path.Match(pattern, strings.Join(segments[i:], "/")); ok {
+                return true
             }
         }
-        // Warn if job_ids are provided for start action
-        if len(op.JobIDs) > 0 {
-            errors = append(errors, BatchValidationError{
-                Field:   "job_ids",
-                Message: "job_ids should not be provided for start action",
-                Value:   fmt.Sprintf("%v", op.JobIDs),
-            })
-        }
+    }
+    return false
+}
 
-    case domain.BatchActionPause, domain.BatchActionResume, 
-         domain.BatchActionStop, domain.BatchActionRetry:
-        if len(op.JobIDs) == 0 {
-            errors = append(errors, BatchValidationError{
-                Field:   "job_ids",
-                Message: fmt.Sprintf("at least one job ID is required for %s action", op.Action),
-            })
+func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperation) (*domain.BatchResult, error) {
+    if err := op.Validate(); err != nil {
+        return nil, err
+    }
+    if op.Action == domain.BatchActionStart {
+        // Rejected once here rather than for every job the batch would start
+        if _, err := s.engineLimits.Resolve(op.Engine); err != nil {
+            return nil, domain.ValidationErrors{{Field: "engine", Message: err.Error()}}
         }
-        // Validate each job ID
-        for i, jobID := range op.JobIDs {
-            if jobID == "" {
-                errors = append(errors, BatchValidationError{
-                    Field:   fmt.Sprintf("job_ids[%d]", i),
-                    Message: "job ID cannot be empty",
-                })
+        for i, output := range op.Outputs {
+            if _, err := s.engineLimits.Resolve(output.Engine); err != nil {
+                return nil, domain.ValidationErrors{{Field: fmt.Sprintf("outputs[%d].engine", i), Message: err.Error()}}
             }
         }
-        // Warn if source_urls are provided for non-start actions
-        if len(op.SourceURLs) > 0 {
-            errors = append(errors, BatchValidationError{
-                Field:   "source_urls",
-                Message: fmt.Sprintf("source_urls should not be provided for %s action", op.Action),
-                Value:   fmt.Sprintf("%v", op.SourceURLs),
-            })
+        if _, err := resolveTranscode(op.Transcode, op.TranscodeProfile, s.transcoding, s.transcodeProfiles); err != nil {
+            field := "transcode"
+            if op.TranscodeProfile != "" {
+                field = "transcode_profile"
+            }
+            return nil, domain.ValidationErrors{{Field: field, Message: err.Error()}}
+        }
+        if _, err := resolveWatermark(op.Watermark, s.watermarking); err != nil {
+            return nil, domain.ValidationErrors{{Field: "watermark", Message: err.Error()}}
         }
     }
 
-    return errors
-}
+    // Expand a bucket/prefix or directory source into individual source URLs
+    if op.Action == domain.BatchActionStart && op.Source != nil {
+        urls, err := s.expandSource(ctx, op.Source)
+        if err != nil {
+            return nil, err
+        }
+        if len(urls) == 0 {
+            return nil, fmt.Errorf("validation failed: source matched no objects")
+        }
+        op.SourceURLs = append(op.SourceURLs, urls...)
+    }
 
-func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperation) (*domain.BatchResult, error) {
-    // Validate batch operation
-    if errors := s.validateBatchOperation(op); len(errors) > 0 {
-        return nil, fmt.Errorf("validation failed: %v", errors)
+    // Reject or merge duplicate source URLs
+    var duplicates []domain.BatchDuplicate
+    if op.Action == domain.BatchActionStart {
+        var unique []string
+        var errors domain.ValidationErrors
+        unique, duplicates, errors = findDuplicateSources(op.SourceURLs)
+        if len(errors) > 0 && !op.Dedupe {
+            return nil, errors
+        }
+        if len(duplicates) > 0 {
+            s.logger.Info("Merged duplicate batch sources",
+                zap.Int("requested", len(op.SourceURLs)),
+                zap.Int("unique", len(unique)))
+        }
+        op.SourceURLs = unique
+
+        // A batch over its tenant's quota is rejected as a whole rather than
+        // started in part
+        if err := s.encryptionService.AdmitJobs(ctx, len(op.SourceURLs)); err != nil {
+            return nil, err
+        }
     }
 
+    principal := domain.PrincipalFromContext(ctx)
     result := &domain.BatchResult{
         BatchID:    generateBatchID(),
-        StartTime:  time.Now(),
+        CreatedBy:  principal.ID,
+        Tenant:     principal.TenantID(),
+        StartTime:  s.clock.Now(),
         Action:     op.Action,
         Successful: make([]string, 0),
         Failed:     make([]domain.BatchJobError, 0),
+        Duplicates: duplicates,
     }
 
     // Calculate total jobs based on action type
@@ -147,8 +257,9 @@ func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperatio
 
     // Process the batch operation
     if op.Action == domain.BatchActionStart {
-        for _, sourceURL := range op.SourceURLs {
-            job, err := s.encryptionService.StartEncryption(ctx, sourceURL)
+        jobs, errs := s.startJobs(ctx, op)
+        for i, sourceURL := range op.SourceURLs {
+            job, err := jobs[i], errs[i]
             if err != nil {
                 result.Failed = append(result.Failed, domain.BatchJobError{
                     JobID: "N/A",
@@ -161,7 +272,7 @@ func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperatio
             
             // Add to job history
             historyEntry := domain.JobHistoryEntry{
-                Timestamp: time.Now(),
+                Timestamp: s.clock.Now(),
                 Action:    string(op.Action),
                 BatchID:   result.BatchID,
                 Status:    "created",
@@ -194,12 +305,12 @@ func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperatio
     }
 
     // Update and store result
-    result.EndTime = time.Now()
+    result.EndTime = s.clock.Now()
     result.Summary = domain.BatchSummary{
         TotalJobs:    totalJobs,  // Use the calculated total
         SuccessCount: len(result.Successful),
         FailureCount: len(result.Failed),
-        Duration:     result.EndTime.Sub(result.StartTime),
+        Duration:     domain.Duration(result.EndTime.Sub(result.StartTime)),
     }
 
     // Store batch result
@@ -209,10 +320,43 @@ func (s *BatchService) ProcessBatch(ctx context.Context, op domain.BatchOperatio
             zap.Error(err))
         return nil, fmt.Errorf("failed to store batch result: %w", err)
     }
+    s.recordBatch(result)
+    publishEvent(ctx, s.events, domain.NewBatchEvent(result, s.clock.Now()), s.logger)
 
     return result, nil
 }
 
+// recordBatch records the outcome of a stored batch
+func (s *BatchService) recordBatch(result *domain.BatchResult) {
+    if s.metrics == nil {
+        return
+    }
+    s.metrics.RecordBatch(string(result.Action), result.Summary.SuccessCount, result.Summary.FailureCount, time.Duration(result.Summary.Duration).Seconds())
+}
+
+// startJobs creates a job for each source of a start batch, at most
+// batchStartWorkers at a time, returning the jobs and errors in source order
+func (s *BatchService) startJobs(ctx context.Context, op domain.BatchOperation) ([]*domain.EncryptionJob, []error) {
+    jobs := make([]*domain.EncryptionJob, len(op.SourceURLs))
+    errs := make([]error, len(op.SourceURLs))
+    slots := make(chan struct{}, batchStartWorkers)
+
+    var wg sync.WaitGroup
+    for i, sourceURL := range op.SourceURLs {
+        slots <- struct{}{}
+        wg.Add(1)
+        go func(i int, sourceURL string) {
+            defer func() {
+                <-slots
+                wg.Done()
+            }()
+            jobs[i], errs[i] = s.encryptionService.StartEncryption(ctx, sourceURL, startOptions(op))
+        }(i, sourceURL)
+    }
+    wg.Wait()
+    return jobs, errs
+}
+
 // Helper function to process individual job in batch
 func (s *BatchService) processJob(ctx context.Context, jobID string, op domain.BatchOperation, index int) error {
     // First verify the job exists
@@ -230,7 +374,7 @@ func (s *BatchService) processJob(ctx context.Context, jobID string, op domain.B
         if index >= len(op.SourceURLs) {
             return fmt.Errorf("source URL index out of range for job %s", jobID)
         }
-        _, err := s.encryptionService.StartEncryption(ctx, op.SourceURLs[index])
+        _, err := s.encryptionService.StartEncryption(ctx, op.SourceURLs[index], startOptions(op))
         if err != nil {
             return fmt.Errorf("failed to start encryption for job %s: %w", jobID, err)
         }
@@ -264,11 +408,13 @@ func (s *BatchService) processJob(ctx context.Context, jobID string, op domain.B
         return nil
 
     case domain.BatchActionRetry:
-        if job.Status != domain.StatusFailed {
-            return fmt.Errorf("job %s is not in failed state (current status: %s)", jobID, job.Status)
+        if err := job.CanRetry(); err != nil {
+            return fmt.Errorf("cannot retry job %s: %w", jobID, err)
         }
-        _, err = s.encryptionService.StartEncryption(ctx, job.SourceURL)
-        if err != nil {
+        if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+            return fmt.Errorf("cannot retry job %s: %w", jobID, err)
+        }
+        if _, err := s.encryptionService.RetryJob(ctx, jobID); err != nil {
             return fmt.Errorf("failed to retry job %s: %w", jobID, err)
         }
         return nil
@@ -278,14 +424,218 @@ func (s *BatchService) processJob(ctx context.Context, jobID string, op domain.B
     }
 }
 
+// startOptions returns the options of the jobs a start operation creates
+func startOptions(op domain.BatchOperation) domain.JobOptions {
+    opts := domain.JobOptions{Metadata: op.Metadata, Engine: op.Engine, Outputs: op.Outputs, Transcode: op.Transcode, TranscodeProfile: op.TranscodeProfile, Watermark: op.Watermark, Destination: op.Destination, CopyTo: op.CopyTo}
+    if op.ScheduledAt != nil {
+        opts.ScheduledAt = *op.ScheduledAt
+    }
+    return opts
+}
+
+// retryOptions returns options that recreate job with the same parameters
+func retryOptions(job *domain.EncryptionJob) domain.JobOptions {
+    opts := domain.JobOptions{Metadata: job.Metadata, Transcode: job.Transcode, TranscodeProfile: job.TranscodeProfile, Watermark: job.Watermark, CustomerKey: job.CustomerKey, Destination: job.Destination, CopyTo: job.CopyTo}
+    if job.Transcode != nil && job.Transcode.DRM != "" {
+        // DRM packaged renditions take no engine parameters
+        return opts
+    }
+    if len(job.Outputs) == 0 {
+        engine := job.Engine
+        opts.Engine = &engine
+        return opts
+    }
+    for _, output := range job.Outputs {
+        engine := output.Engine
+        opts.Outputs = append(opts.Outputs, domain.OutputProfile{Name: output.Name, Engine: &engine})
+    }
+    return opts
+}
+
+// RollbackBatch stops every job created by a start batch and, if requested,
+// deletes their outputs. The rollback is recorded as its own batch result.
+func (s *BatchService) RollbackBatch(ctx context.Context, batchID string, req domain.BatchRollbackRequest) (*domain.BatchResult, error) {
+    original, err := s.GetBatchResult(ctx, batchID)
+    if err != nil {
+        return nil, err
+    }
+    principal := domain.PrincipalFromContext(ctx)
+    if err := principal.Authorize(original.CreatedBy); err != nil {
+        return nil, err
+    }
+    if original.Action != domain.BatchActionStart {
+        return nil, fmt.Errorf("validation failed: only start batches can be rolled back (batch action: %s)", original.Action)
+    }
+    if req.DeleteOutputs && s.outputStorage == nil {
+        return nil, fmt.Errorf("validation failed: output deletion is not available")
+    }
+
+    result := &domain.BatchResult{
+        BatchID:       generateBatchID(),
+        ParentBatchID: batchID,
+        CreatedBy:     principal.ID,
+        Tenant:        principal.TenantID(),
+        StartTime:     s.clock.Now(),
+        Action:        domain.BatchActionRollback,
+        Successful:    make([]string, 0),
+        Failed:        make([]domain.BatchJobError, 0),
+    }
+
+    for _, jobID := range original.Successful {
+        if err := s.rollbackJob(ctx, jobID, result.BatchID, req); err != nil {
+            result.Failed = append(result.Failed, domain.BatchJobError{
+                JobID: jobID,
+                Error: err.Error(),
+            })
+            continue
+        }
+        result.Successful = append(result.Successful, jobID)
+    }
+
+    result.EndTime = s.clock.Now()
+    result.Summary = domain.BatchSummary{
+        TotalJobs:    len(original.Successful),
+        SuccessCount: len(result.Successful),
+        FailureCount: len(result.Failed),
+        Duration:     domain.Duration(result.EndTime.Sub(result.StartTime)),
+    }
+
+    if err := s.batchRepository.StoreBatchResult(ctx, result); err != nil {
+        s.logger.Error("Failed to store batch result",
+            zap.String("batch_id", result.BatchID),
+            zap.Error(err))
+        return nil, fmt.Errorf("failed to store batch result: %w", err)
+    }
+    s.recordBatch(result)
+    publishEvent(ctx, s.events, domain.NewBatchEvent(result, s.clock.Now()), s.logger)
+
+    s.logger.Info("Rolled back batch",
+        zap.String("batch_id", batchID),
+        zap.String("rollback_batch_id", result.BatchID),
+        zap.Int("stopped", result.Summary.SuccessCount),
+        zap.Int("failed", result.Summary.FailureCount))
+
+    return result, nil
+}
+
+// rollbackJob stops a single job created by a batch and optionally removes its output
+func (s *BatchService) rollbackJob(ctx context.Context, jobID, rollbackID string, req domain.BatchRollbackRequest) error {
+    job, err := s.encryptionService.GetJobStatus(ctx, jobID)
+    if err != nil {
+        return fmt.Errorf("job not found: %s (error: %w)", jobID, err)
+    }
+
+    stopped := false
+    status := job.Status
+    if !job.IsTerminal() {
+        if err := s.encryptionService.StopJob(ctx, jobID); err != nil {
+            return fmt.Errorf("failed to stop job %s: %w", jobID, err)
+        }
+        stopped = true
+        status = domain.StatusCancelled
+    }
+
+    outputDeleted := false
+    if req.DeleteOutputs {
+        storage, err := jobStorage(s.outputStorage, s.destinations, job)
+        if err != nil {
+            return fmt.Errorf("failed to delete output of job %s: %w", jobID, err)
+        }
+        for _, outputPath := range job.OutputPaths() {
+            if err := storage.DeleteFile(outputPath); err != nil {
+                return fmt.Errorf("failed to delete output of job %s: %w", jobID, err)
+            }
+            outputDeleted = true
+        }
+        if err := deleteCopies(s.destinations, job); err != nil {
+            return fmt.Errorf("failed to delete output copies of job %s: %w", jobID, err)
+        }
+    }
+
+    historyEntry := domain.JobHistoryEntry{
+        Timestamp: s.clock.Now(),
+        Action:    string(domain.BatchActionRollback),
+        BatchID:   rollbackID,
+        Status:    string(status),
+        Details: map[string]interface{}{
+            "stopped":        stopped,
+            "output_deleted": outputDeleted,
+        },
+    }
+    if err := s.jobRepository.AddJobHistory(ctx, jobID, historyEntry); err != nil {
+        s.logger.Error("Failed to add job history entry",
+            zap.String("job_id", jobID),
+            zap.String("batch_id", rollbackID),
+            zap.Error(err))
+    }
+
+    return nil
+}
+
+// GetBatchJobReports returns one report per job in a batch, combining the batch
+// outcome with the current state of each job
+func (s *BatchService) GetBatchJobReports(ctx context.Context, batchID string) ([]domain.BatchJobReport, error) {
+    result, err := s.GetBatchResult(ctx, batchID)
+    if err != nil {
+        return nil, err
+    }
+
+    // The batch's jobs are read together rather than one round trip each
+    jobs, err := s.jobRepository.GetMany(ctx, result.Successful)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get jobs of batch %s: %w", batchID, err)
+    }
+
+    reports := make([]domain.BatchJobReport, 0, len(result.Successful)+len(result.Failed))
+    for i, jobID := range result.Successful {
+        report := domain.BatchJobReport{
+            JobID:   jobID,
+            Outcome: domain.BatchOutcomeSuccess,
+        }
+
+        if job := jobs[i]; job != nil {
+            report.Status = job.Status
+            report.Error = job.Error
+            report.OutputPath = job.OutputPath
+            report.Duration = domain.Duration(time.Duration(job.UpdatedAt-job.CreatedAt) * time.Second)
+        }
+
+        reports = append(reports, report)
+    }
+
+    for _, failed := range result.Failed {
+        reports = append(reports, domain.BatchJobReport{
+            JobID:   failed.JobID,
+            Outcome: domain.BatchOutcomeFailed,
+            Error:   failed.Error,
+        })
+    }
+
+    return reports, nil
+}
+
+// GetBatchResult returns a batch of the caller's tenant. Batches of other
+// tenants are reported as not found rather than forbidden.
 func (s *BatchService) GetBatchResult(ctx context.Context, batchID string) (*domain.BatchResult, error) {
-    return s.batchRepository.GetBatchResult(ctx, batchID)
+    result, err := s.batchRepository.GetBatchResult(ctx, batchID)
+    if err != nil {
+        return nil, err
+    }
+    if err := domain.PrincipalFromContext(ctx).AuthorizeTenant(result.TenantID()); err != nil {
+        return nil, fmt.Errorf("%w: %s", domain.ErrBatchNotFound, batchID)
+    }
+    return result, nil
 }
 
 func generateBatchID() string {
     return fmt.Sprintf("batch_%s", uuid.New().String())
 }
 
+// ListBatchResults lists the batches of the caller's tenant, or every batch
+// for an admin
 func (s *BatchService) ListBatchResults(ctx context.Context, filter domain.BatchFilter) ([]*domain.BatchResult, error) {
+    if principal := domain.PrincipalFromContext(ctx); !principal.Admin {
+        filter.Tenant = principal.TenantID()
+    }
     return s.batchRepository.ListBatchResults(ctx, filter)
 }
\ No newline at end of file
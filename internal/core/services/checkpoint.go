@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// streamingEngine returns the engine if the job is to be checkpointed: a
+// single stream output encrypted straight from its source, with
+// checkpointing on and an engine that can encrypt in pieces. Base64 outputs
+// are not, since their segments could not be joined.
+func (p *WorkerPool) streamingEngine(job *domain.EncryptionJob) (ports.StreamingEncryptionEngine, bool) {
+	if p.config.CheckpointSize <= 0 || job.Transcode != nil || len(job.Outputs) > 0 || len(p.sourceProcessors(job)) > 0 {
+		return nil, false
+	}
+	if job.Engine.WithDefaults().Container != domain.ContainerStream {
+		return nil, false
+	}
+	streaming, ok := p.engine.(ports.StreamingEncryptionEngine)
+	return streaming, ok
+}
+
+// encryptCheckpointed encrypts the job's source into output segments of
+// about the checkpoint size, recording a checkpoint in the job's progress as
+// each is stored, and assembles them into the output once the last is. A job
+// with a checkpoint continues after its last stored segment, with the key
+// and stream header it started with. checkpoint follows the job's latest
+// checkpoint, and is cleared once the output is assembled.
+func (p *WorkerPool) encryptCheckpointed(ctx context.Context, job *domain.EncryptionJob, engine ports.StreamingEncryptionEngine, checkpoint **domain.Checkpoint, update func(domain.Progress), result *domain.JobResult, start time.Time) (*domain.JobResult, string, error) {
+	params := job.Engine.WithDefaults()
+
+	var (
+		key    string
+		stream ports.EncryptionStream
+		offset int64
+	)
+	cp := *checkpoint
+	if cp != nil {
+		var err error
+		if key, stream, err = p.resumeStream(ctx, job, engine, cp); err != nil {
+			p.logger.Warn("Cannot continue job from its checkpoint, starting over",
+				zap.String("job_id", job.ID),
+				zap.Int64("offset", cp.Offset),
+				zap.Error(err))
+			p.deleteSegments(job.ID, cp)
+			cp, *checkpoint = nil, nil
+		} else {
+			offset = cp.Offset
+			p.logger.Info("Continuing job from its checkpoint",
+				zap.String("job_id", job.ID),
+				zap.Int64("offset", offset),
+				zap.Int("segments", cp.Segments))
+		}
+	}
+
+	fetchStart := p.clock.Now()
+	src, rest, err := p.openSource(ctx, job.SourceURL, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	result.Timings.Fetch = domain.Duration(p.clock.Now().Sub(fetchStart))
+	// Sources are hashed only when read from the start
+	var hashed *hashingReader
+	if offset == 0 {
+		hashed = newHashingReader(src)
+		src = hashed
+	}
+	size := int64(-1)
+	if rest >= 0 {
+		size = offset + rest
+	}
+
+	// Sources that fit in one segment are not worth checkpointing
+	if cp == nil && size >= 0 && size <= p.config.CheckpointSize {
+		return hashed.record(p.encryptSingle(ctx, job, src, size, params, update, result, start))
+	}
+
+	// The segments read ahead of the chunks they seal, so the source is
+	// hashed as it is sealed rather than as it is read
+	digest := sourceDigest(cp)
+	chunkSize := params.ChunkSize
+	if cp == nil {
+		if key, err = p.customerKey(ctx, job); err != nil {
+			return nil, "", err
+		}
+		if key == "" {
+			if key, err = p.engine.GenerateKey(params); err != nil {
+				return nil, "", err
+			}
+		}
+		// Stored with the first checkpoint, for later runs to continue with
+		stored, err := sealJobKey(ctx, p.keys, job, key)
+		if err != nil {
+			return nil, "", err
+		}
+		job.SetStoredKey(stored)
+		cp = &domain.Checkpoint{}
+	} else {
+		chunkSize = stream.ChunkSize()
+	}
+
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, update)
+	reader.read, reader.lastRead = offset, offset
+	reader.media = job.Media
+	update(reader.snapshot(p.clock.Now()))
+	input := bufio.NewReaderSize(reader, chunkSize)
+	plaintext := make([]byte, chunkSize)
+
+	var encryptTime time.Duration
+	for final := false; !final; {
+		segment := cp.Segments
+		elapsed, err := p.streamToStorage(p.outputStorage, p.segmentPath(job.ID, segment), &domain.JobResult{}, "encryption", func() {}, func(output io.Writer) error {
+			if stream == nil {
+				var err error
+				if stream, err = engine.BeginStream(output, key, params); err != nil {
+					return err
+				}
+			}
+			for written := int64(0); written < p.config.CheckpointSize && !final; {
+				n, err := io.ReadFull(input, plaintext)
+				if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+					return fmt.Errorf("failed to read input: %w", err)
+				}
+				final = err != nil
+				if !final {
+					// A full chunk is only final if nothing follows it
+					if _, peekErr := input.Peek(1); peekErr == io.EOF {
+						final = true
+					}
+				}
+				if err := stream.Seal(output, plaintext[:n], final); err != nil {
+					return err
+				}
+				if digest != nil {
+					digest.Write(plaintext[:n])
+				}
+				written += int64(n)
+				offset += int64(n)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		encryptTime += elapsed
+		if final {
+			break
+		}
+
+		// Later runs continue after the stored segment
+		cp = &domain.Checkpoint{Offset: offset, Chunk: stream.Chunk(), Segments: segment + 1, HashState: digestState(digest)}
+		*checkpoint = cp
+		update(reader.snapshot(p.clock.Now()))
+	}
+
+	// Only the assembly of the segments is left
+	progress := reader.snapshot(p.clock.Now())
+	progress.Stage = domain.StageStoring
+	progress.ETA = 0
+	update(progress)
+
+	segments := cp.Segments + 1
+	storage, outputPath, err := p.outputLocation(job, job.ID+".enc")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := p.streamToStorage(storage, outputPath, result, "assembly", func() {}, func(output io.Writer) error {
+		for i := 0; i < segments; i++ {
+			part, err := p.outputStorage.ReadFile(p.segmentPath(job.ID, i))
+			if err != nil {
+				return fmt.Errorf("failed to read output segment %d: %w", i, err)
+			}
+			_, err = io.Copy(output, part)
+			part.Close()
+			if err != nil {
+				return fmt.Errorf("failed to copy output segment %d: %w", i, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+	p.deleteSegments(job.ID, cp)
+	*checkpoint = nil
+	job.Progress.Checkpoint = nil
+
+	result.Timings.Encrypt = domain.Duration(encryptTime)
+	result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+	result.KeyRef = keyRef(key)
+	if digest != nil {
+		result.SourceHash = domain.SourceHash(digest.Sum(nil))
+	}
+	return result, key, nil
+}
+
+// sourceDigest returns the digest a checkpointed job's source is hashed
+// with: a new one when it is read from the start, or the one saved with its
+// checkpoint. Checkpoints saved without one leave the source unhashed.
+func sourceDigest(cp *domain.Checkpoint) hash.Hash {
+	digest := sha256.New()
+	if cp == nil {
+		return digest
+	}
+	if len(cp.HashState) == 0 {
+		return nil
+	}
+	if err := digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(cp.HashState); err != nil {
+		return nil
+	}
+	return digest
+}
+
+// digestState returns the state of a source digest to save with a
+// checkpoint, or nil if the source is not hashed
+func digestState(digest hash.Hash) []byte {
+	if digest == nil {
+		return nil
+	}
+	state, err := digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return state
+}
+
+// resumeStream reopens the key and stream of a checkpointed job, checking
+// that the segments its checkpoint counts are all stored
+func (p *WorkerPool) resumeStream(ctx context.Context, job *domain.EncryptionJob, engine ports.StreamingEncryptionEngine, cp *domain.Checkpoint) (string, ports.EncryptionStream, error) {
+	if cp.Segments == 0 {
+		return "", nil, errors.New("no segment is stored")
+	}
+	for i := 0; i < cp.Segments; i++ {
+		if !p.outputStorage.FileExists(p.segmentPath(job.ID, i)) {
+			return "", nil, fmt.Errorf("output segment %d is missing", i)
+		}
+	}
+
+	key, err := openKey(ctx, p.keys, domain.ContentKey{JobID: job.ID}, job.StoredKey())
+	if err != nil {
+		return "", nil, err
+	}
+	if key == "" {
+		return "", nil, errors.New("the job's key is not stored")
+	}
+
+	header, err := p.outputStorage.ReadFile(p.segmentPath(job.ID, 0))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read output segment 0: %w", err)
+	}
+	defer header.Close()
+	stream, err := engine.ResumeStream(header, key, cp.Chunk)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, stream, nil
+}
+
+// openSource opens the job's source from offset, returning the size of the
+// rest (-1 if unknown). Fetchers that cannot open a source partway have the
+// bytes before offset read and dropped.
+func (p *WorkerPool) openSource(ctx context.Context, sourceURL string, offset int64) (io.ReadCloser, int64, error) {
+	if offset == 0 {
+		return p.fetcher.Open(ctx, sourceURL)
+	}
+	if ranged, ok := p.fetcher.(ports.SourceRangeFetcher); ok {
+		return ranged.OpenAt(ctx, sourceURL, offset)
+	}
+
+	src, size, err := p.fetcher.Open(ctx, sourceURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := io.CopyN(io.Discard, src, offset); err != nil {
+		src.Close()
+		return nil, 0, fmt.Errorf("failed to skip to offset %d of source: %w", offset, err)
+	}
+	if size >= 0 {
+		size -= offset
+	}
+	return src, size, nil
+}
+
+// segmentPath names an output segment of a checkpointed job
+func (p *WorkerPool) segmentPath(jobID string, segment int) string {
+	return path.Join(p.config.OutputPrefix, fmt.Sprintf("%s.enc.part%05d", jobID, segment))
+}
+
+// discardCheckpoint deletes the segments of a job that will not continue
+// from its checkpoint, such as one that failed or was stopped
+func (p *WorkerPool) discardCheckpoint(job *domain.EncryptionJob) {
+	if job.Progress.Checkpoint == nil {
+		return
+	}
+	p.deleteSegments(job.ID, job.Progress.Checkpoint)
+	job.Progress.Checkpoint = nil
+}
+
+// deleteSegments deletes the segments a checkpoint counts, and the one that
+// was being stored after them
+func (p *WorkerPool) deleteSegments(jobID string, cp *domain.Checkpoint) {
+	for i := 0; i <= cp.Segments; i++ {
+		if err := p.outputStorage.DeleteFile(p.segmentPath(jobID, i)); err != nil {
+			p.logger.Warn("Failed to delete output segment",
+				zap.String("job_id", jobID),
+				zap.Int("segment", i),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// sealKey returns how the material of key is stored: sealed by the key store,
+// or inline when none is configured
+func sealKey(ctx context.Context, store ports.KeyStore, key domain.ContentKey, material string) (domain.StoredKey, error) {
+	if store == nil || material == "" {
+		return domain.StoredKey{Inline: material}, nil
+	}
+	ref, err := store.Seal(ctx, key, material)
+	if err != nil {
+		return domain.StoredKey{}, fmt.Errorf("failed to seal key of %s: %w", key, err)
+	}
+	return domain.StoredKey{Sealed: ref}, nil
+}
+
+// openKey returns the hex encoded material of a stored key, asking the key
+// store to open it when it is sealed. Keys stored inline, e.g. before a key
+// store was configured, are returned as they are.
+func openKey(ctx context.Context, store ports.KeyStore, key domain.ContentKey, stored domain.StoredKey) (string, error) {
+	if stored.Sealed == "" {
+		return stored.Inline, nil
+	}
+	if store == nil {
+		return "", fmt.Errorf("%w: the key of %s is sealed but no key store is configured", domain.ErrKeyUnavailable, key)
+	}
+	material, err := store.Open(ctx, key, stored.Sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to open key of %s: %w", key, err)
+	}
+	return material, nil
+}
+
+// minWrapKeyBits is the smallest RSA key a key may be wrapped for
+const minWrapKeyBits = 2048
+
+// wrapKey encrypts the hex encoded material of a key for a base64 encoded
+// DER (PKIX) RSA public key with RSA-OAEP-256 under label
+func wrapKey(material, publicKey string, label []byte) (string, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		if der, err = base64.RawURLEncoding.DecodeString(publicKey); err != nil {
+			return "", fmt.Errorf("%w: wrap_key must be a base64 encoded public key", domain.ErrInvalidKeyRequest)
+		}
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrap_key is not a PKIX public key: %v", domain.ErrInvalidKeyRequest, err)
+	}
+	rsaKey, ok := parsed.(*rsa.PublicKey)
+	if !ok || rsaKey.N.BitLen() < minWrapKeyBits {
+		return "", fmt.Errorf("%w: wrap_key must be an RSA public key of at least %d bits", domain.ErrInvalidKeyRequest, minWrapKeyBits)
+	}
+
+	raw, err := hex.DecodeString(material)
+	if err != nil {
+		return "", fmt.Errorf("key is not hex encoded: %w", err)
+	}
+	defer clear(raw)
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, raw, label)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// checkCustomerKey checks that a job can be encrypted with a customer key.
+// A wrapped key is unwrapped once to check that it fits the job's
+// algorithm, then discarded; the workers unwrap it again when they run.
+func (s *EncryptionService) checkCustomerKey(ctx context.Context, key *domain.CustomerKey, params domain.EngineParams, outputs bool, transcode *domain.TranscodeParams) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	store, ok := s.keys.(ports.CustomerKeyStore)
+	if !ok {
+		return fmt.Errorf("%w: customer keys need a key store that accepts them, e.g. KMS", domain.ErrInvalidCustomerKey)
+	}
+	if outputs {
+		return fmt.Errorf("%w: jobs with outputs encrypt each with its own key", domain.ErrInvalidCustomerKey)
+	}
+	if transcode != nil && transcode.DRM != "" {
+		return fmt.Errorf("%w: DRM packaged renditions use generated keys", domain.ErrInvalidCustomerKey)
+	}
+	if key.WrappedKey == "" {
+		return nil
+	}
+
+	material, err := store.Unwrap(ctx, key.WrappedKey)
+	if err != nil {
+		return err
+	}
+	if want := domain.AlgorithmKeyLength(params.Algorithm) / 8; len(material) != 2*want {
+		return fmt.Errorf("%w: %s needs a %d byte key, got %d bytes", domain.ErrInvalidCustomerKey, params.Algorithm, want, len(material)/2)
+	}
+	return nil
+}
+
+// customerKey returns the hex encoded customer key a job is encrypted with,
+// or "" if the job's key is to be generated
+func (p *WorkerPool) customerKey(ctx context.Context, job *domain.EncryptionJob) (string, error) {
+	if job.CustomerKey == nil || job.CustomerKey.WrappedKey == "" {
+		return "", nil
+	}
+	store, ok := p.keys.(ports.CustomerKeyStore)
+	if !ok {
+		return "", fmt.Errorf("%w: the key store cannot unwrap customer keys", domain.ErrInvalidCustomerKey)
+	}
+	return store.Unwrap(ctx, job.CustomerKey.WrappedKey)
+}
+
+// sealJobKey is sealKey for the key of a job's one output. Jobs naming a
+// customer KMS key have it sealed under that key instead of the store's.
+func sealJobKey(ctx context.Context, store ports.KeyStore, job *domain.EncryptionJob, material string) (domain.StoredKey, error) {
+	key := domain.ContentKey{JobID: job.ID}
+	if job.CustomerKey == nil || job.CustomerKey.KMSKeyID == "" {
+		return sealKey(ctx, store, key, material)
+	}
+	customer, ok := store.(ports.CustomerKeyStore)
+	if !ok {
+		return domain.StoredKey{}, fmt.Errorf("%w: the key store cannot seal under customer keys", domain.ErrInvalidCustomerKey)
+	}
+	ref, err := customer.SealWith(ctx, job.CustomerKey.KMSKeyID, key, material)
+	if err != nil {
+		return domain.StoredKey{}, fmt.Errorf("failed to seal key of %s: %w", key, err)
+	}
+	return domain.StoredKey{Sealed: ref}, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// outputDestinations checks the destinations jobs name for their outputs
+type outputDestinations struct {
+	destinations ports.OutputDestinations
+	policy       domain.DestinationPolicy
+	checkWrite   bool
+	timeout      time.Duration
+}
+
+// SetDestinations lets jobs write their outputs to destinations policy
+// allows instead of the output storage. With checkWrite, StartEncryption
+// also writes a probe file to each destination, waiting at most timeout, so
+// jobs that could not store their outputs are rejected with the request.
+func (s *EncryptionService) SetDestinations(destinations ports.OutputDestinations, policy domain.DestinationPolicy, checkWrite bool, timeout time.Duration) {
+	s.destinations = &outputDestinations{destinations: destinations, policy: policy, checkWrite: checkWrite, timeout: timeout}
+}
+
+// checkDestinations returns an error wrapping domain.ErrInvalidDestination or
+// domain.ErrDestinationUnwritable if outputs cannot be written to primary or
+// copied to each of copyTo
+func (s *EncryptionService) checkDestinations(ctx context.Context, primary *domain.OutputDestination, copyTo []domain.OutputDestination) error {
+	if err := domain.ValidateCopyDestinations(primary, copyTo); err != nil {
+		return err
+	}
+	if err := s.checkDestination(ctx, primary); err != nil {
+		return err
+	}
+	for i := range copyTo {
+		if err := s.checkDestination(ctx, &copyTo[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDestination returns an error wrapping domain.ErrInvalidDestination or
+// domain.ErrDestinationUnwritable if outputs cannot be written to d
+func (s *EncryptionService) checkDestination(ctx context.Context, d *domain.OutputDestination) error {
+	if d == nil {
+		return nil
+	}
+	if s.destinations == nil {
+		return fmt.Errorf("%w: output destinations are not enabled", domain.ErrInvalidDestination)
+	}
+	if err := s.destinations.policy.Check(d); err != nil {
+		return err
+	}
+	if !s.destinations.checkWrite {
+		return nil
+	}
+
+	if s.destinations.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.destinations.timeout)
+		defer cancel()
+	}
+	if err := s.destinations.destinations.CheckWritable(ctx, *d); err != nil {
+		s.logger.Debug("Destination failed write check",
+			zap.String("destination", d.String()),
+			zap.Error(err))
+		return fmt.Errorf("%w: %s: %v", domain.ErrDestinationUnwritable, d, err)
+	}
+	return nil
+}
+
+// outputStorage returns the storage holding the outputs of job
+func (s *EncryptionService) outputStorage(job *domain.EncryptionJob) (ports.FileStorage, error) {
+	var destinations ports.OutputDestinations
+	if s.destinations != nil {
+		destinations = s.destinations.destinations
+	}
+	return jobStorage(s.outputs, destinations, job)
+}
+
+// SetDestinations opens the destinations jobs name for their outputs
+func (p *WorkerPool) SetDestinations(destinations ports.OutputDestinations) {
+	p.destinations = destinations
+}
+
+// outputLocation returns the storage and path an output named file of job is
+// written to. Outputs written to a destination are placed under its prefix
+// rather than the output prefix.
+func (p *WorkerPool) outputLocation(job *domain.EncryptionJob, file string) (ports.FileStorage, string, error) {
+	storage, err := jobStorage(p.outputStorage, p.destinations, job)
+	if err != nil {
+		return nil, "", err
+	}
+	if job.Destination != nil {
+		return storage, file, nil
+	}
+	return storage, path.Join(p.config.OutputPrefix, file), nil
+}
+
+// jobStorage returns the storage holding the outputs of job: that of its
+// destination, or outputs
+func jobStorage(outputs ports.FileStorage, destinations ports.OutputDestinations, job *domain.EncryptionJob) (ports.FileStorage, error) {
+	if job.Destination == nil {
+		return outputs, nil
+	}
+	if destinations == nil {
+		return nil, fmt.Errorf("%w: output destinations are not enabled", domain.ErrInvalidDestination)
+	}
+	storage, err := destinations.Storage(*job.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open destination %s: %w", job.Destination, err)
+	}
+	return storage, nil
+}
+
+// copyOutputs copies the stored outputs of job, result and those of its
+// outputs, to each of its copy_to destinations. A destination the outputs
+// cannot be copied to fails only its own copy, so the job still completes.
+func (p *WorkerPool) copyOutputs(ctx context.Context, job *domain.EncryptionJob, result *domain.JobResult) []domain.DestinationCopy {
+	results := []*domain.JobResult{result}
+	for _, output := range job.Outputs {
+		if output.Result != nil && output.Result.OutputPath != result.OutputPath {
+			results = append(results, output.Result)
+		}
+	}
+
+	copies := make([]domain.DestinationCopy, 0, len(job.CopyTo))
+	for _, destination := range job.CopyTo {
+		urls, err := p.copyOutputsTo(ctx, job, destination, results)
+		copied := domain.DestinationCopy{
+			Destination: destination,
+			Status:      domain.StatusCompleted,
+			URLs:        urls,
+			CopiedAt:    p.clock.Now().Unix(),
+		}
+		outcome := "completed"
+		if err != nil {
+			copied.Status = domain.StatusFailed
+			copied.Error = err.Error()
+			outcome = "failed"
+			p.logger.Warn("Failed to copy job outputs",
+				zap.String("job_id", job.ID),
+				zap.String("destination", destination.String()),
+				zap.Error(err))
+		}
+		if p.metrics != nil {
+			p.metrics.RecordOutputCopy(destination.Storage, outcome)
+		}
+		copies = append(copies, copied)
+	}
+	return copies
+}
+
+// copyOutputsTo copies each of results from the job's storage to the root of
+// destination, returning the locations of the copies made
+func (p *WorkerPool) copyOutputsTo(ctx context.Context, job *domain.EncryptionJob, destination domain.OutputDestination, results []*domain.JobResult) ([]string, error) {
+	if p.destinations == nil {
+		return nil, errors.New("output destinations are not enabled")
+	}
+	source, err := jobStorage(p.outputStorage, p.destinations, job)
+	if err != nil {
+		return nil, err
+	}
+	target, err := p.destinations.Storage(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, result := range results {
+		if err := ctx.Err(); err != nil {
+			return urls, err
+		}
+		name := path.Base(result.OutputPath)
+		if err := copyOutput(source, target, result, name); err != nil {
+			return urls, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+		urls = append(urls, target.URL(name))
+	}
+	return urls, nil
+}
+
+// copyOutput copies the output of result to target as name, checking the copy
+// against the output's recorded checksum and size. A copy that does not match
+// is deleted.
+func copyOutput(source, target ports.FileStorage, result *domain.JobResult, name string) error {
+	output, err := source.ReadFile(result.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	digest := sha256.New()
+	counted := &countingReader{reader: io.TeeReader(output, digest)}
+	if err := target.WriteFile(name, counted); err != nil {
+		return err
+	}
+
+	var mismatch error
+	switch size := counted.read.Load(); {
+	case result.Checksum != "" && "sha256:"+hex.EncodeToString(digest.Sum(nil)) != result.Checksum:
+		mismatch = errors.New("checksum does not match the output")
+	case size != result.Size:
+		mismatch = fmt.Errorf("copied %d bytes, %d were written", size, result.Size)
+	}
+	if mismatch != nil {
+		if err := target.DeleteFile(name); err != nil {
+			return fmt.Errorf("%w, and deleting the copy failed: %v", mismatch, err)
+		}
+		return mismatch
+	}
+	return nil
+}
+
+// deleteCopies deletes the copies of the outputs of job made to its copy_to
+// destinations
+func deleteCopies(destinations ports.OutputDestinations, job *domain.EncryptionJob) error {
+	for _, copied := range job.Copies {
+		if copied.Status != domain.StatusCompleted {
+			continue
+		}
+		if destinations == nil {
+			return errors.New("output destinations are not enabled")
+		}
+		storage, err := destinations.Storage(copied.Destination)
+		if err != nil {
+			return fmt.Errorf("failed to open destination %s: %w", copied.Destination, err)
+		}
+		for _, outputPath := range job.OutputPaths() {
+			if err := storage.DeleteFile(path.Base(outputPath)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"time"
+
+	"E.E/internal/core/domain"
+)
+
+// packageDRM transcodes the job's source into renditions protected with a new
+// DRM key and stores the package as it is, since players decrypt it. The key
+// is returned like the key of an encrypted output, so it is sealed by the key
+// store and delivered by the key server under the key ID in the renditions.
+func (p *WorkerPool) packageDRM(ctx context.Context, job *domain.EncryptionJob, sourceURL string, update func(domain.Progress), result *domain.JobResult, start time.Time) (*domain.JobResult, string, error) {
+	key, err := domain.GenerateDRMKey()
+	if err != nil {
+		return nil, "", err
+	}
+	drmKey, err := domain.NewDRMKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	src, size, err := p.transcode(ctx, job, sourceURL, drmKey, update)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	result.Timings.Transcode = domain.Duration(p.clock.Now().Sub(start) - result.Timings.Probe.Std() - result.Timings.Process.Std())
+
+	// The engine parameters do not apply to protected renditions
+	*result = domain.JobResult{
+		Algorithm: domain.DRMAlgorithm(job.Transcode.DRM),
+		KeyLength: domain.DRMKeySize * 8,
+		DRM:       job.Transcode.DRM,
+		KeyID:     base64.RawURLEncoding.EncodeToString(drmKey.KeyID),
+		Timings:   result.Timings,
+	}
+
+	ext := ".mp4"
+	if job.Transcode.Packaged() {
+		ext = ".tar"
+	}
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, update)
+	reader.stage = domain.StageStoring
+	update(reader.snapshot(p.clock.Now()))
+
+	storage, outputPath, err := p.outputLocation(job, job.ID+ext)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := p.streamToStorage(storage, outputPath, result, "packaging", func() {}, func(output io.Writer) error {
+		_, err := io.Copy(output, reader)
+		return err
+	}); err != nil {
+		return nil, "", err
+	}
+	result.KeyRef = keyRef(key)
+	result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+	return result, key, nil
+}
@@ -8,70 +8,789 @@ import (
 	"go.uber.org/zap"
 	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"E.E/internal/core/domain"
 	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+	"E.E/pkg/metrics"
 )
 
 type EncryptionService struct {
 	logger     *zap.Logger
 	repository ports.JobRepository
 	batchRepository ports.BatchRepository
+	batchService *BatchService
+	queue      ports.JobQueue
+	clock      ports.Clock
+	draining   atomic.Bool
+
+	prober        ports.MediaProber
+	mediaPolicy   domain.MediaPolicy
+	probeOnSubmit bool
+	preflight     *sourcePreflight
+
+	engineLimits      domain.EngineLimits
+	transcoding       bool
+	transcodeProfiles domain.TranscodeProfiles
+	watermarking      bool
+
+	summaries *summaryCache
+	progress  ports.EncryptionProgress
+	metrics   *metrics.Metrics
+	keys      ports.KeyStore
+	keyAudit  ports.KeyAuditLog
+	events    ports.EventQueue
+	outputs   ports.FileStorage
+	uploads   *uploadStorage
+
+	destinations *outputDestinations
+
+	quotas    domain.QuotaPolicy
+	admission sync.Mutex // Serializes quota checks with the job creations they admit
 }
 
-func NewEncryptionService(repository ports.JobRepository, batchRepository ports.BatchRepository, logger *zap.Logger) ports.EncryptionService {
-	return &EncryptionService{
+func NewEncryptionService(repository ports.JobRepository, batchRepository ports.BatchRepository, queue ports.JobQueue, logger *zap.Logger) *EncryptionService {
+	s := &EncryptionService{
 		logger:     logger,
 		repository: repository,
 		batchRepository: batchRepository,
+		queue:      queue,
+		clock:      clock.System{},
+		engineLimits: domain.EngineLimits{
+			Defaults:     domain.DefaultEngineParams,
+			Algorithms:   domain.SupportedAlgorithms,
+			IVStrategies: domain.SupportedIVStrategies,
+			Containers:   domain.SupportedContainers,
+			MinChunkSize: domain.DefaultEngineParams.ChunkSize,
+			MaxChunkSize: domain.DefaultEngineParams.ChunkSize,
+		},
+		summaries: newSummaryCache(0),
+	}
+	s.batchService = NewBatchService(s, repository, batchRepository, logger)
+	return s
+}
+
+// Batches returns the batch service used for batch operations
+func (s *EncryptionService) Batches() *BatchService {
+	return s.batchService
+}
+
+// SetClock replaces the system clock, e.g. with a manual clock in tests. It
+// applies to the batch service as well.
+func (s *EncryptionService) SetClock(c ports.Clock) {
+	s.clock = c
+	s.batchService.clock = c
+}
+
+// SetMediaProber makes StartEncryption probe each source and reject media
+// that policy does not allow, so unsupported sources fail before they are
+// queued. The workers probe sources separately.
+func (s *EncryptionService) SetMediaProber(prober ports.MediaProber, policy domain.MediaPolicy) {
+	s.prober = prober
+	s.mediaPolicy = policy
+	s.probeOnSubmit = prober != nil
+}
+
+// SetEngineLimits sets the default engine parameters and the bounds requests
+// may override them within. It applies to the batch service as well.
+func (s *EncryptionService) SetEngineLimits(limits domain.EngineLimits) {
+	s.engineLimits = limits
+	s.batchService.engineLimits = limits
+}
+
+// EnableTranscoding lets jobs ask for their source to be transcoded before it
+// is encrypted; the workers must have a transcoder. It applies to the batch
+// service as well.
+func (s *EncryptionService) EnableTranscoding() {
+	s.transcoding = true
+	s.batchService.transcoding = true
+}
+
+// SetTranscodeProfiles lets jobs ask for transcoding by the name of one of
+// profiles. It applies to the batch service as well.
+func (s *EncryptionService) SetTranscodeProfiles(profiles domain.TranscodeProfiles) {
+	s.transcodeProfiles = profiles
+	s.batchService.transcodeProfiles = profiles
+}
+
+// EnableWatermarking lets jobs ask for a watermark on their source's video;
+// the workers must have a watermarker. It applies to the batch service as
+// well.
+func (s *EncryptionService) EnableWatermarking() {
+	s.watermarking = true
+	s.batchService.watermarking = true
+}
+
+// SetSummaryCacheTTL caches each caller's status summary for ttl, or disables
+// caching when ttl is 0. Writes made through the service invalidate it.
+func (s *EncryptionService) SetSummaryCacheTTL(ttl time.Duration) {
+	s.summaries.setTTL(ttl)
+}
+
+// SetProgress makes SubscribeToProgress follow the progress the workers
+// publish to progress
+func (s *EncryptionService) SetProgress(progress ports.EncryptionProgress) {
+	s.progress = progress
+}
+
+// SetMetrics makes the service record the jobs it queues and cancels; the
+// workers record the jobs they finish. It applies to the batch service as
+// well.
+func (s *EncryptionService) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
+	s.batchService.metrics = m
+}
+
+// SetKeyStore opens the keys of jobs whose keys are sealed with store when
+// their owners ask for them
+func (s *EncryptionService) SetKeyStore(store ports.KeyStore) {
+	s.keys = store
+}
+
+// SetOutputStorage sets the storage that job outputs are written to, which
+// VerifyJob reads them back from
+func (s *EncryptionService) SetOutputStorage(storage ports.FileStorage) {
+	s.outputs = storage
+}
+
+// SetKeyAudit records every key retrieved through GetJobKey in audit. Keys are
+// then only handed out once their retrieval is recorded.
+func (s *EncryptionService) SetKeyAudit(audit ports.KeyAuditLog) {
+	s.keyAudit = audit
+}
+
+// SetEventQueue makes the service publish an event for each job paused or
+// resumed through it. It applies to the batch service as well, which
+// publishes an event for each batch operation it completes.
+func (s *EncryptionService) SetEventQueue(events ports.EventQueue) {
+	s.events = events
+	s.batchService.events = events
+}
+
+// recordJob counts a job that reached status through the service
+func (s *EncryptionService) recordJob(status domain.EncryptionStatus) {
+	if s.metrics != nil {
+		s.metrics.RecordEncryptionJob(string(status))
+	}
+}
+
+// probeMedia describes a source and checks it against policy
+func probeMedia(ctx context.Context, prober ports.MediaProber, policy domain.MediaPolicy, sourceURL string) (*domain.MediaInfo, error) {
+	info, err := prober.Probe(ctx, sourceURL)
+	if err != nil {
+		return nil, err
 	}
+	if err := policy.Check(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// StopAccepting makes StartEncryption reject new jobs, used while draining
+func (s *EncryptionService) StopAccepting() {
+	s.draining.Store(true)
+}
+
+// SetQuotas limits what each tenant may submit
+func (s *EncryptionService) SetQuotas(policy domain.QuotaPolicy) {
+	s.quotas = policy
 }
 
-// StartEncryption initiates an encryption job
-func (s *EncryptionService) StartEncryption(ctx context.Context, sourceURL string) (*domain.EncryptionJob, error) {
-	job := &domain.EncryptionJob{
-		ID:        uuid.New().String(),
-		SourceURL: sourceURL,
-		Status:    domain.StatusProgress,
-		Progress:  0.0,
-		CreatedAt: time.Now().Unix(),
-		UpdatedAt: time.Now().Unix(),
+// StartEncryption creates an encryption job and queues it for the workers, or
+// leaves it SCHEDULED for the job scheduler if it starts later
+func (s *EncryptionService) StartEncryption(ctx context.Context, sourceURL string, opts domain.JobOptions) (*domain.EncryptionJob, error) {
+	if s.draining.Load() {
+		return nil, domain.ErrNotAcceptingJobs
+	}
+	if err := domain.ValidateMetadata(opts.Metadata); err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateOutputProfiles(opts.Outputs); err != nil {
+		return nil, err
+	}
+	params, err := s.engineLimits.Resolve(opts.Engine)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := s.resolveOutputs(opts.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	transcode, err := resolveTranscode(opts.Transcode, opts.TranscodeProfile, s.transcoding, s.transcodeProfiles)
+	if err != nil {
+		return nil, err
+	}
+	if transcode != nil && transcode.DRM != "" && (opts.Engine != nil || len(outputs) > 0) {
+		return nil, fmt.Errorf("%w: DRM packaged renditions are not encrypted by the engine, so drm cannot be combined with engine parameters or outputs", domain.ErrInvalidTranscode)
+	}
+	watermark, err := resolveWatermark(opts.Watermark, s.watermarking)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CustomerKey != nil {
+		if err := s.checkCustomerKey(ctx, opts.CustomerKey, params, len(outputs) > 0, transcode); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkDestinations(ctx, opts.Destination, opts.CopyTo); err != nil {
+		return nil, err
+	}
+
+	// Uploads are stored by the service itself
+	if opts.Upload == "" {
+		if err := s.checkSource(ctx, sourceURL); err != nil {
+			return nil, err
+		}
+	}
+
+	var media *domain.MediaInfo
+	if s.probeOnSubmit {
+		info, err := probeMedia(ctx, s.prober, s.mediaPolicy, sourceURL)
+		if err != nil {
+			return nil, err
+		}
+		media = info
+	}
+
+	// The job is stored as QUEUED before it is enqueued so a worker never
+	// sees it in an earlier state
+	job := domain.NewEncryptionJob(sourceURL, opts.Metadata, s.clock.Now())
+	job.ID = uuid.New().String()
+	job.Engine = params
+	if len(outputs) > 0 {
+		job.Engine = outputs[0].Engine
+		job.Outputs = outputs
+	}
+	principal := domain.PrincipalFromContext(ctx)
+	job.CreatedBy = principal.ID
+	job.Tenant = principal.TenantID()
+	job.Media = media
+	job.Transcode = transcode
+	job.TranscodeProfile = opts.TranscodeProfile
+	job.Watermark = watermark
+	job.SourceHash = opts.SourceHash
+	job.KeySource = domain.KeySourceGenerated
+	job.Upload = opts.Upload
+	job.Destination = opts.Destination
+	job.CopyTo = opts.CopyTo
+	job.RetryOf = opts.RetryOf
+	if opts.CustomerKey != nil {
+		job.CustomerKey = opts.CustomerKey
+		job.KeySource = opts.CustomerKey.Source()
+	}
+	if opts.Reuse {
+		reused, err := s.reusableJob(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		if reused != nil {
+			s.logger.Info("Reusing completed job with the same source",
+				zap.String("job_id", reused.ID),
+				zap.String("source_url", sourceURL),
+				zap.String("source_hash", job.SourceHash))
+			return reused, nil
+		}
+	}
+	if opts.ScheduledAt.After(s.clock.Now()) {
+		// Queued by the job scheduler once its start comes
+		job.Schedule(opts.ScheduledAt)
+		if err := job.Transition(domain.StatusScheduled, domain.JobActionSchedule, s.clock.Now()); err != nil {
+			return nil, err
+		}
+	} else if err := job.Transition(domain.StatusQueued, domain.JobActionQueue, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	if s.quotas.Enabled() {
+		s.admission.Lock()
+		defer s.admission.Unlock()
+	}
+	if err := s.AdmitJobs(ctx, 1); err != nil {
+		return nil, err
 	}
 
 	if err := s.repository.Create(ctx, job); err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
+	s.summaries.invalidate()
+
+	if job.Status == domain.StatusQueued {
+		if err := s.enqueue(ctx, job); err != nil {
+			return nil, err
+		}
+	}
+	s.recordJob(job.Status)
 
 	return job, nil
 }
 
-// GetJobStatus retrieves the status of a job
+// reusableJob returns the newest completed job whose result job would
+// reproduce, or nil if there is none. Jobs are matched by their source hash,
+// so only jobs submitted with one or whose source a worker hashed are found.
+func (s *EncryptionService) reusableJob(ctx context.Context, job *domain.EncryptionJob) (*domain.EncryptionJob, error) {
+	if job.SourceHash == "" {
+		return nil, nil
+	}
+	candidates, err := s.repository.Query(ctx, domain.JobQuery{
+		Filter: domain.JobFilter{
+			Status:     string(domain.StatusCompleted),
+			Tenant:     job.TenantID(),
+			SourceHash: job.SourceHash,
+		},
+		Descending: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up jobs with the same source: %w", err)
+	}
+	for _, candidate := range candidates {
+		if candidate.ReusableFor(job) {
+			return candidate, nil
+		}
+	}
+	return nil, nil
+}
+
+// StartDecryption creates a decryption job and queues it for the workers. The
+// caller must own the encryption job whose key is used, and name the key by
+// its key_ref.
+func (s *EncryptionService) StartDecryption(ctx context.Context, req domain.DecryptionRequest) (*domain.EncryptionJob, error) {
+	if s.draining.Load() {
+		return nil, domain.ErrNotAcceptingJobs
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	keyJob, err := s.getOwnedJob(ctx, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := keyJob.DecryptionSource(req.Output)
+	if err != nil {
+		return nil, err
+	}
+	if result.KeyRef != req.KeyRef {
+		return nil, domain.ValidationErrors{{
+			Field:   "key_ref",
+			Message: fmt.Sprintf("key_ref does not match the key of job %s", keyJob.ID),
+			Value:   req.KeyRef,
+		}}
+	}
+
+	sourceURL := req.SourceURL
+	if sourceURL == "" {
+		sourceURL = result.OutputURL
+	}
+	job := domain.NewEncryptionJob(sourceURL, req.Metadata, s.clock.Now())
+	job.ID = uuid.New().String()
+	job.Kind = domain.JobKindDecrypt
+	job.Decryption = &domain.Decryption{
+		KeyJobID: keyJob.ID,
+		Output:   req.Output,
+		KeyRef:   req.KeyRef,
+	}
+	job.RetryOf = req.RetryOf
+	job.Engine = domain.EngineParams{
+		Algorithm:  result.Algorithm,
+		KeyLength:  result.KeyLength,
+		ChunkSize:  result.ChunkSize,
+		IVStrategy: result.IVStrategy,
+		Container:  result.Container,
+	}
+	principal := domain.PrincipalFromContext(ctx)
+	job.CreatedBy = principal.ID
+	job.Tenant = principal.TenantID()
+	if err := job.Transition(domain.StatusQueued, domain.JobActionQueue, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	if s.quotas.Enabled() {
+		s.admission.Lock()
+		defer s.admission.Unlock()
+	}
+	if err := s.AdmitJobs(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	s.summaries.invalidate()
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	s.recordJob(job.Status)
+
+	s.logger.Info("Queued decryption job",
+		zap.String("job_id", job.ID),
+		zap.String("key_job_id", keyJob.ID),
+	)
+	return job, nil
+}
+
+// enqueue queues a stored job for the workers, marking it failed if it
+// cannot be queued
+func (s *EncryptionService) enqueue(ctx context.Context, job *domain.EncryptionJob) error {
+	err := s.queue.Enqueue(ctx, job.ID)
+	if err == nil {
+		return nil
+	}
+
+	job.Error = "failed to queue job"
+	if transitionErr := job.Transition(domain.StatusFailed, domain.JobActionFail, s.clock.Now()); transitionErr == nil {
+		if updateErr := s.repository.Update(context.Background(), job); updateErr != nil {
+			s.logger.Error("Failed to mark unqueued job as failed",
+				zap.String("job_id", job.ID),
+				zap.Error(updateErr))
+		}
+		s.summaries.invalidate()
+	}
+	return fmt.Errorf("failed to queue job: %w", err)
+}
+
+// resolveOutputs resolves the engine parameters of each output profile
+func (s *EncryptionService) resolveOutputs(profiles []domain.OutputProfile) ([]domain.JobOutput, error) {
+	outputs := make([]domain.JobOutput, 0, len(profiles))
+	for i, profile := range profiles {
+		params, err := s.engineLimits.Resolve(profile.Engine)
+		if err != nil {
+			return nil, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		outputs = append(outputs, domain.JobOutput{
+			Name:   profile.Name,
+			Engine: params,
+			Status: domain.StatusQueued,
+		})
+	}
+	return outputs, nil
+}
+
+// resolveTranscode checks the requested transcoding, returning the
+// parameters with defaults filled in or nil if none was requested. Without
+// params, those of the named profile are used.
+func resolveTranscode(params *domain.TranscodeParams, profile string, enabled bool, profiles domain.TranscodeProfiles) (*domain.TranscodeParams, error) {
+	if params == nil && profile == "" {
+		return nil, nil
+	}
+	if !enabled {
+		return nil, fmt.Errorf("%w: transcoding is not enabled", domain.ErrInvalidTranscode)
+	}
+	if params == nil {
+		var err error
+		if params, err = profiles.Resolve(profile); err != nil {
+			return nil, err
+		}
+	}
+	resolved := *params
+	resolved.Renditions = append([]domain.Rendition(nil), params.Renditions...)
+	if err := resolved.Validate(); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// resolveWatermark checks the requested watermark, returning it with
+// defaults filled in or nil if none was requested
+func resolveWatermark(watermark *domain.Watermark, enabled bool) (*domain.Watermark, error) {
+	if watermark == nil {
+		return nil, nil
+	}
+	if !enabled {
+		return nil, fmt.Errorf("%w: watermarking is not enabled", domain.ErrInvalidWatermark)
+	}
+	resolved := *watermark
+	if err := resolved.Validate(); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// GetJobStatus retrieves the status of a job of the caller's tenant. Jobs of
+// other tenants are reported as not found rather than forbidden, so their IDs
+// cannot be probed.
 func (s *EncryptionService) GetJobStatus(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
 	job, err := s.repository.Get(ctx, jobID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
-	if job == nil {
-		return nil, fmt.Errorf("job not found: %s", jobID)
+	if job == nil || domain.PrincipalFromContext(ctx).AuthorizeTenant(job.TenantID()) != nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrJobNotFound, jobID)
+	}
+	return job, nil
+}
+
+// getOwnedJob loads a job the caller is allowed to act on
+func (s *EncryptionService) getOwnedJob(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
+	job, err := s.GetJobStatus(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+		return nil, err
 	}
 	return job, nil
 }
 
-// PauseJob simulates pausing an encryption job
+// GetJobResult returns the result recorded when a job completed
+func (s *EncryptionService) GetJobResult(ctx context.Context, jobID string) (*domain.JobResult, error) {
+	job, err := s.GetJobStatus(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != domain.StatusCompleted {
+		return nil, domain.NewJobStateError(jobID, job.Status, "get result of", "job has not completed")
+	}
+	if job.Result == nil {
+		// Jobs completed before results were recorded
+		return nil, domain.ErrJobResultNotFound
+	}
+	return job.Result, nil
+}
+
+// GetOutputResult returns the result of one output of a multi-output job.
+// Outputs complete independently, so it is available as soon as that output
+// is stored.
+func (s *EncryptionService) GetOutputResult(ctx context.Context, jobID, output string) (*domain.JobResult, error) {
+	job, err := s.GetJobStatus(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := job.Output(output)
+	if err != nil {
+		return nil, err
+	}
+	if out.Status != domain.StatusCompleted || out.Result == nil {
+		return nil, domain.NewJobStateError(jobID, out.Status, "get result of", fmt.Sprintf("output %s has not completed", output))
+	}
+	return out.Result, nil
+}
+
+// GetJobKey returns the decryption key of a completed job the caller may act
+// on, or of one output of a multi-output job, in the clear or wrapped for the
+// caller's public key. Every retrieval is audited.
+func (s *EncryptionService) GetJobKey(ctx context.Context, req domain.JobKeyRequest, access domain.KeyAccess) (*domain.JobKey, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.CanRetrieveKeys() {
+		return nil, fmt.Errorf("%w: %q may not retrieve decryption keys", domain.ErrForbidden, principal.ID)
+	}
+	job, err := s.getOwnedJob(ctx, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+
+	status, result, stored := job.Status, job.Result, job.StoredKey()
+	if req.Output != "" {
+		out, err := job.Output(req.Output)
+		if err != nil {
+			return nil, err
+		}
+		status, result, stored = out.Status, out.Result, out.StoredKey()
+	}
+	if status != domain.StatusCompleted {
+		return nil, domain.NewJobStateError(req.JobID, status, "get key of", "job has not completed")
+	}
+	if result == nil || stored.IsZero() {
+		// Imported jobs refer to keys held by another system
+		return nil, fmt.Errorf("%w: the key of job %s is not held by the service", domain.ErrKeyUnavailable, req.JobID)
+	}
+
+	contentKey := domain.ContentKey{JobID: req.JobID, Output: req.Output}
+	material, err := openKey(ctx, s.keys, contentKey, stored)
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.JobKey{
+		JobID:  req.JobID,
+		Output: req.Output,
+		KeyRef: result.KeyRef,
+		KeyID:  result.KeyID,
+		Sealed: stored.Sealed != "",
+	}
+	if req.WrapKey != "" {
+		ttl := domain.DefaultKeyWrapTTL
+		if req.TTLSeconds > 0 {
+			ttl = time.Duration(req.TTLSeconds) * time.Second
+		}
+		key.ExpiresAt = s.clock.Now().Add(ttl).Unix()
+		key.WrappedKey, err = wrapKey(material, req.WrapKey, domain.KeyWrapLabel(contentKey, key.ExpiresAt))
+		if err != nil {
+			return nil, err
+		}
+		key.WrapAlgorithm = domain.KeyWrapAlgorithm
+	} else {
+		key.Key = material
+	}
+
+	// Keys are only handed out once their retrieval is on record
+	event := domain.KeyAuditEvent{
+		Time:      s.clock.Now(),
+		Action:    domain.KeyAuditRetrieved,
+		Key:       contentKey,
+		Principal: principal.ID,
+		Wrapped:   key.WrappedKey != "",
+		RemoteIP:  access.RemoteIP,
+		UserAgent: access.UserAgent,
+		RequestID: access.RequestID,
+	}
+	s.logger.Info("Key audit",
+		zap.String("action", event.Action),
+		zap.String("key", contentKey.String()),
+		zap.String("principal", event.Principal),
+		zap.Bool("wrapped", event.Wrapped),
+		zap.String("remote_ip", event.RemoteIP),
+		zap.String("request_id", event.RequestID))
+	if s.keyAudit != nil {
+		if err := s.keyAudit.Record(context.WithoutCancel(ctx), event); err != nil {
+			return nil, fmt.Errorf("failed to record key audit event: %w", err)
+		}
+	}
+	return key, nil
+}
+
+// UpdateJob applies a partial update to a job
+func (s *EncryptionService) UpdateJob(ctx context.Context, jobID string, req domain.JobUpdateRequest) (*domain.EncryptionJob, error) {
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := job.UpdateMetadata(req.Metadata, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to update job: %w", err)
+	}
+	s.summaries.invalidate()
+	return job, nil
+}
+
+// ExtendJobRetention pushes back the expiry of a job and its history
+func (s *EncryptionService) ExtendJobRetention(ctx context.Context, jobID string, req domain.RetentionRequest) (*domain.EncryptionJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	job.ExtendRetention(req.ExtendBy.Std(), s.clock.Now())
+	if err := s.repository.Update(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to extend job retention: %w", err)
+	}
+	s.summaries.invalidate()
+	s.logger.Info("Extended job retention",
+		zap.String("job_id", jobID),
+		zap.Time("expires_at", time.Unix(job.ExpiresAt, 0)),
+	)
+	return job, nil
+}
+
+// PauseJob pauses a running job. Its worker abandons the job at its next
+// progress update, so a resumed job starts over, or continues from its
+// checkpoint if it has one.
 func (s *EncryptionService) PauseJob(ctx context.Context, jobID string) error {
-	s.logger.Info("Pausing encryption job", 
+	job, err := s.transitionJob(ctx, jobID, domain.StatusPaused, domain.JobActionPause, (*domain.EncryptionJob).CanPause)
+	if err != nil {
+		return err
+	}
+	s.summaries.invalidate()
+	publishEvent(ctx, s.events, domain.NewJobEvent(domain.EventJobPaused, job, s.clock.Now()), s.logger)
+
+	s.logger.Info("Paused encryption job",
 		zap.String("job_id", jobID),
-		zap.String("status", string(domain.StatusPaused)),
+		zap.String("status", string(job.Status)),
 	)
 	return nil
 }
 
-// ResumeJob simulates resuming an encryption job
+// ResumeJob queues a paused job again. The job starts over, as the work done
+// before it was paused was abandoned, except what its checkpoint keeps.
 func (s *EncryptionService) ResumeJob(ctx context.Context, jobID string) error {
-	s.logger.Info("Resuming encryption job", 
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := job.CanResume(); err != nil {
+		return err
+	}
+	job.ResetProgress()
+	job.ResetOutputs()
+	if err := job.Transition(domain.StatusQueued, domain.JobActionResume, s.clock.Now()); err != nil {
+		return err
+	}
+	if err := s.repository.Update(ctx, job); err != nil {
+		return fmt.Errorf("failed to resume job: %w", err)
+	}
+	s.summaries.invalidate()
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return err
+	}
+	publishEvent(ctx, s.events, domain.NewJobEvent(domain.EventJobResumed, job, s.clock.Now()), s.logger)
+
+	s.logger.Info("Resumed encryption job",
+		zap.String("job_id", jobID),
+		zap.String("status", string(job.Status)),
+	)
+	return nil
+}
+
+// RescheduleJob moves the start of a scheduled job
+func (s *EncryptionService) RescheduleJob(ctx context.Context, jobID string, req domain.ScheduleRequest) (*domain.EncryptionJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := job.CanReschedule(); err != nil {
+		return nil, err
+	}
+	job.Schedule(*req.ScheduledAt)
+	if err := job.Transition(domain.StatusScheduled, domain.JobActionReschedule, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to reschedule job: %w", err)
+	}
+	s.summaries.invalidate()
+
+	s.logger.Info("Rescheduled encryption job",
 		zap.String("job_id", jobID),
-		zap.String("status", string(domain.StatusProgress)),
+		zap.Time("scheduled_at", *req.ScheduledAt),
 	)
+	return job, nil
+}
+
+// UnscheduleJob cancels a scheduled job before its start
+func (s *EncryptionService) UnscheduleJob(ctx context.Context, jobID string) error {
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := job.CanUnschedule(); err != nil {
+		return err
+	}
+	if err := job.Transition(domain.StatusCancelled, domain.JobActionUnschedule, s.clock.Now()); err != nil {
+		return err
+	}
+	if err := s.repository.Update(ctx, job); err != nil {
+		return fmt.Errorf("failed to unschedule job: %w", err)
+	}
+	s.summaries.invalidate()
+	s.recordJob(job.Status)
+
+	s.logger.Info("Unscheduled encryption job", zap.String("job_id", jobID))
 	return nil
 }
 
@@ -81,34 +800,93 @@ func (s *EncryptionService) StopEngine() error {
 	return nil
 }
 
-// StopJob simulates stopping a specific encryption job
+// StopJob cancels a job. A queued job is never started; a running job is
+// abandoned by its worker at its next progress update.
 func (s *EncryptionService) StopJob(ctx context.Context, jobID string) error {
-	s.logger.Info("Stopping encryption job", 
+	job, err := s.transitionJob(ctx, jobID, domain.StatusCancelled, domain.JobActionStop, (*domain.EncryptionJob).CanStop)
+	if err != nil {
+		return err
+	}
+	s.summaries.invalidate()
+	s.recordJob(job.Status)
+
+	s.logger.Info("Stopped encryption job",
 		zap.String("job_id", jobID),
-		zap.String("status", string(domain.StatusFailed)),
+		zap.String("status", string(job.Status)),
 	)
 	return nil
 }
 
-// ListJobs returns a list of jobs with filtering, sorting and pagination
+// maxTransitionAttempts bounds how often transitionJob reads a job again
+// after a write that kept its status, such as a progress update, got in
+// between its read and its own write
+const maxTransitionAttempts = 3
+
+// transitionJob moves a job the caller may act on to status, once check
+// allows it, and stores it only if its status is still the one it was read
+// with, so a worker finishing or failing the job meanwhile is not overwritten.
+// A job whose status changed is a conflict.
+func (s *EncryptionService) transitionJob(ctx context.Context, jobID string, status domain.EncryptionStatus, action string, check func(*domain.EncryptionJob) error) (*domain.EncryptionJob, error) {
+	for attempt := 1; ; attempt++ {
+		job, err := s.getOwnedJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if err := check(job); err != nil {
+			return nil, err
+		}
+		read := job.Status
+		if err := job.Transition(status, action, s.clock.Now()); err != nil {
+			return nil, err
+		}
+
+		current := read
+		updated, err := s.repository.UpdateIf(ctx, job, func(stored *domain.EncryptionJob) bool {
+			current = stored.Status
+			return stored.Status == read
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to %s job: %w", action, err)
+		}
+		if updated {
+			return job, nil
+		}
+		if current != read || attempt == maxTransitionAttempts {
+			return nil, domain.NewJobStateError(jobID, current, action, fmt.Sprintf("job changed from %s while it was being updated", read))
+		}
+	}
+}
+
+// ListJobs returns a list of jobs with filtering, sorting and pagination.
+// Callers other than admins only see the jobs of their tenant.
 func (s *EncryptionService) ListJobs(ctx context.Context, limit, offset int, filter domain.JobFilter, sortOpts domain.JobSort) ([]*domain.EncryptionJob, error) {
+	filter = scopeToTenant(ctx, filter)
+
 	// Validate sort options
 	if err := validateSortOptions(sortOpts); err != nil {
 		return nil, fmt.Errorf("invalid sort options: %w", err)
 	}
 
-	jobs, err := s.repository.List(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	// Creation, update and progress order come straight from the
+	// repository's indexes, so a page only reads the jobs on it
+	if orderBy, descending, ok := indexedOrder(sortOpts); ok {
+		jobs, err := s.repository.Query(ctx, domain.JobQuery{
+			Filter:     filter,
+			OrderBy:    orderBy,
+			Descending: descending,
+			Limit:      limit,
+			Offset:     offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		return jobs, nil
 	}
 
-	// Apply filters
-	filtered := make([]*domain.EncryptionJob, 0)
-	for _, job := range jobs {
-		if !matchesFilter(job, filter) {
-			continue
-		}
-		filtered = append(filtered, job)
+	// Other orders need every matching job
+	filtered, err := s.repository.Query(ctx, domain.JobQuery{Filter: filter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
 	}
 
 	// Apply sorting with enhanced options
@@ -129,97 +907,172 @@ func (s *EncryptionService) ListJobs(ctx context.Context, limit, offset int, fil
 	return filtered[start:end], nil
 }
 
-// GetJobsStatusSummary returns detailed statistics about jobs
-func (s *EncryptionService) GetJobsStatusSummary(ctx context.Context) (map[string]interface{}, error) {
-	jobs, err := s.repository.List(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %w", err)
+// scopeToTenant restricts a filter to the caller's tenant unless the caller
+// is an admin
+func scopeToTenant(ctx context.Context, filter domain.JobFilter) domain.JobFilter {
+	if principal := domain.PrincipalFromContext(ctx); !principal.Admin {
+		filter.Tenant = principal.TenantID()
 	}
+	return filter
+}
 
-	summary := map[string]interface{}{
-		"total": len(jobs),
-		"by_status": map[string]int{
-			string(domain.StatusPending):   0,
-			string(domain.StatusProgress):  0,
-			string(domain.StatusPaused):    0,
-			string(domain.StatusCompleted): 0,
-			string(domain.StatusFailed):    0,
-		},
-		"statistics": map[string]interface{}{
-			"avg_completion_time": 0.0,
-			"success_rate": 0.0,
-			"total_completed": 0,
-			"total_failed": 0,
-			"avg_progress": 0.0,
-			"jobs_last_24h": 0,
-			"jobs_last_week": 0,
-		},
-		"latest_jobs": jobs[:min(5, len(jobs))],
+// indexedOrder reports whether jobs sorted by sortOpts can be read in index
+// order: the default order, or a single creation time, update time or
+// progress field
+func indexedOrder(sortOpts domain.JobSort) (orderBy string, descending bool, ok bool) {
+	if len(sortOpts.Fields) == 0 {
+		return domain.OrderByCreatedAt, true, true
 	}
+	if len(sortOpts.Fields) > 1 {
+		return "", false, false
+	}
+	field := sortOpts.Fields[0]
+	descending = strings.EqualFold(field.Order, SortOrderDesc)
+	switch strings.ToLower(field.Field) {
+	case SortFieldCreatedAt:
+		return domain.OrderByCreatedAt, descending, true
+	case SortFieldUpdatedAt:
+		return domain.OrderByUpdatedAt, descending, true
+	case SortFieldProgress:
+		return domain.OrderByProgress, descending, true
+	}
+	return "", false, false
+}
 
-	var totalProgress float64
-	var totalCompletionTime int64
-	completedJobs := 0
-	now := time.Now().Unix()
-	dayAgo := now - 86400
-	weekAgo := now - 604800
+// exportPageSize is how many jobs an export reads from the repository at a
+// time, bounding its memory however many jobs it returns
+const exportPageSize = 100
 
-	for _, job := range jobs {
-		// Count by status
-		summary["by_status"].(map[string]int)[string(job.Status)]++
-		
-		// Calculate statistics
-		totalProgress += job.Progress
-		
-		if job.Status == domain.StatusCompleted {
-			completedJobs++
-			totalCompletionTime += job.UpdatedAt - job.CreatedAt
+// ExportJobs reads the matching jobs a page at a time and hands each one to
+// emit, which applies backpressure by blocking until the job is written. It
+// returns a cursor to resume from if limit jobs were emitted and more remain.
+func (s *EncryptionService) ExportJobs(ctx context.Context, filter domain.JobFilter, after *domain.JobCursor, limit int, emit func(*domain.EncryptionJob) error) (*domain.JobCursor, error) {
+	filter = scopeToTenant(ctx, filter)
+	emitted := 0
+	for {
+		// One job past the limit tells whether the export is complete
+		pageSize := min(exportPageSize, limit-emitted+1)
+		jobs, err := s.repository.Query(ctx, domain.JobQuery{
+			Filter:  filter,
+			OrderBy: domain.OrderByCreatedAt,
+			Limit:   pageSize,
+			After:   after,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export jobs: %w", err)
 		}
 
-		// Count recent jobs
-		if job.CreatedAt > dayAgo {
-			summary["statistics"].(map[string]interface{})["jobs_last_24h"] = 
-				summary["statistics"].(map[string]interface{})["jobs_last_24h"].(int) + 1
+		for _, job := range jobs {
+			if emitted == limit {
+				return after, nil
+			}
+			if err := emit(job); err != nil {
+				return nil, err
+			}
+			emitted++
+			after = domain.CursorAfter(job)
 		}
-		if job.CreatedAt > weekAgo {
-			summary["statistics"].(map[string]interface{})["jobs_last_week"] = 
-				summary["statistics"].(map[string]interface{})["jobs_last_week"].(int) + 1
+		if len(jobs) < pageSize {
+			return nil, nil
 		}
 	}
+}
 
-	stats := summary["statistics"].(map[string]interface{})
-	stats["avg_progress"] = totalProgress / float64(len(jobs))
-	stats["total_completed"] = completedJobs
-	stats["total_failed"] = summary["by_status"].(map[string]int)[string(domain.StatusFailed)]
-	
-	if completedJobs > 0 {
-		stats["avg_completion_time"] = float64(totalCompletionTime) / float64(completedJobs)
+// latestJobsCount is the number of most recent jobs included in the summary
+const latestJobsCount = 5
+
+// GetJobsStatusSummary returns detailed statistics about jobs. Admins get
+// the totals the repository maintains, without reading every job; other
+// callers get totals over the jobs of their tenant. Summaries are cached per
+// principal, or per tenant, for the summary cache TTL.
+func (s *EncryptionService) GetJobsStatusSummary(ctx context.Context) (*domain.JobsStatusSummary, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	key := principal.ID
+	if !principal.Admin {
+		key = "tenant:" + principal.TenantID()
 	}
-	if len(jobs) > 0 {
-		stats["success_rate"] = float64(completedJobs) / float64(len(jobs)) * 100
+	now := s.clock.Now()
+	summary, generation := s.summaries.get(key, now)
+	if summary != nil {
+		return summary, nil
 	}
 
+	var aggregates *domain.JobAggregates
+	var err error
+	if principal.Admin {
+		aggregates, err = s.repository.Aggregate(ctx, now, latestJobsCount)
+	} else {
+		aggregates, err = s.tenantAggregates(ctx, principal.TenantID(), now)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate jobs: %w", err)
+	}
+	summary = aggregates.Summary()
+	s.summaries.put(key, generation, summary, now)
 	return summary, nil
 }
 
-// Helper functions for filtering and sorting
-func matchesFilter(job *domain.EncryptionJob, filter domain.JobFilter) bool {
-	if filter.Status != "" && string(job.Status) != filter.Status {
-		return false
+// GetQuota returns a tenant's quota and usage. Only admins may read the
+// quota of a tenant other than their own.
+func (s *EncryptionService) GetQuota(ctx context.Context, tenant string) (*domain.QuotaStatus, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if tenant == "" {
+		tenant = principal.TenantID()
+	} else if err := principal.AuthorizeTenant(tenant); err != nil {
+		return nil, err
 	}
-	if filter.StartDate > 0 && job.CreatedAt < filter.StartDate {
-		return false
+	return s.quotaStatus(ctx, tenant)
+}
+
+// AdmitJobs checks the quota of the caller's tenant. Callers without a
+// tenant, as while authentication is disabled, have no quota.
+func (s *EncryptionService) AdmitJobs(ctx context.Context, jobs int) error {
+	tenant := domain.PrincipalFromContext(ctx).TenantID()
+	if tenant == "" || s.quotas.For(tenant).IsZero() {
+		return nil
 	}
-	if filter.EndDate > 0 && job.CreatedAt > filter.EndDate {
-		return false
+	status, err := s.quotaStatus(ctx, tenant)
+	if err != nil {
+		return err
 	}
-	if filter.SourceURL != "" && !strings.Contains(job.SourceURL, filter.SourceURL) {
-		return false
+	if err := status.Admit(jobs, s.clock.Now()); err != nil {
+		s.logger.Info("Rejected jobs over quota",
+			zap.String("tenant", tenant),
+			zap.Int("jobs", jobs),
+			zap.Error(err))
+		return err
 	}
-	if filter.MinProgress > 0 && job.Progress < filter.MinProgress {
-		return false
+	return nil
+}
+
+// quotaStatus reads what a tenant's jobs use of its quota
+func (s *EncryptionService) quotaStatus(ctx context.Context, tenant string) (*domain.QuotaStatus, error) {
+	now := s.clock.Now()
+	day := domain.StartOfDay(now)
+	usage, err := s.repository.TenantUsage(ctx, tenant, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	return domain.NewQuotaStatus(tenant, s.quotas.For(tenant), *usage, day.Add(24*time.Hour)), nil
+}
+
+// tenantAggregates totals the jobs of one tenant, newest first
+func (s *EncryptionService) tenantAggregates(ctx context.Context, tenant string, now time.Time) (*domain.JobAggregates, error) {
+	jobs, err := s.repository.Query(ctx, domain.JobQuery{
+		Filter:     domain.JobFilter{Tenant: tenant},
+		OrderBy:    domain.OrderByCreatedAt,
+		Descending: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := &domain.JobAggregates{ByStatus: domain.NewStatusCounts()}
+	for _, job := range jobs {
+		aggregates.Add(job, now)
 	}
-	return true
+	aggregates.LatestJobs = jobs[:min(latestJobsCount, len(jobs))]
+	return aggregates, nil
 }
 
 // Constants for sorting
@@ -240,8 +1093,18 @@ const (
 	MaxSortFields = 3
 )
 
-// GetAvailableSortOptions returns all available sorting options and their descriptions
+// availableSortOptions never changes, so it is built once rather than for
+// every listing
+var availableSortOptions = buildSortOptions()
+
+// GetAvailableSortOptions returns all available sorting options and their
+// descriptions. The result is shared and must not be modified.
 func GetAvailableSortOptions() map[string]interface{} {
+	return availableSortOptions
+}
+
+// buildSortOptions describes the sorting options
+func buildSortOptions() map[string]interface{} {
 	return map[string]interface{}{
 		"fields": map[string]string{
 			SortFieldCreatedAt: "Timestamp when the job was created",
@@ -388,7 +1251,7 @@ func compareValues(a, b *domain.EncryptionJob, field string) int {
 	case SortFieldUpdatedAt:
 		return compareInt64(a.UpdatedAt, b.UpdatedAt)
 	case SortFieldProgress:
-		return compareFloat64(a.Progress, b.Progress)
+		return compareFloat64(a.Progress.Percent, b.Progress.Percent)
 	default:
 		return 0
 	}
@@ -428,16 +1291,30 @@ func (s *EncryptionService) ProcessBatch(ctx context.Context, op domain.BatchOpe
 		zap.String("action", string(op.Action)),
 		zap.Int("job_count", len(op.JobIDs)))
 	
-	batchService := NewBatchService(s, s.repository, s.batchRepository, s.logger)
-	return batchService.ProcessBatch(ctx, op)
+	return s.batchService.ProcessBatch(ctx, op)
 }
 
-// GetBatchResult retrieves a batch operation result
+// GetBatchResult retrieves a batch operation result of the caller's tenant
 func (s *EncryptionService) GetBatchResult(ctx context.Context, batchID string) (*domain.BatchResult, error) {
-	return s.batchRepository.GetBatchResult(ctx, batchID)
+	return s.batchService.GetBatchResult(ctx, batchID)
 }
 
-// GetJobHistory retrieves job history
+// GetJobHistory retrieves the history of a job of the caller's tenant
 func (s *EncryptionService) GetJobHistory(ctx context.Context, jobID string) ([]domain.JobHistoryEntry, error) {
+	if _, err := s.GetJobStatus(ctx, jobID); err != nil {
+		return nil, err
+	}
 	return s.repository.GetJobHistory(ctx, jobID)
+}
+
+// SubscribeToProgress returns the progress updates of a job the caller owns
+// until ctx is done, or a nil channel when no progress broker is set
+func (s *EncryptionService) SubscribeToProgress(ctx context.Context, jobID string) (<-chan domain.Progress, error) {
+	if _, err := s.getOwnedJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	if s.progress == nil {
+		return nil, nil
+	}
+	return s.progress.SubscribeToProgress(ctx, jobID)
 }
\ No newline at end of file
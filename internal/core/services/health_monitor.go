@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/pkg/metrics"
+)
+
+// DependencyCheck reports whether a dependency is usable
+type DependencyCheck func(context.Context) error
+
+// Dependency health states
+const (
+	HealthOK       = "ok"       // Last check passed in time
+	HealthDegraded = "degraded" // Last check passed but slower than the degraded latency
+	HealthDown     = "down"     // Last check failed
+)
+
+// DependencyStatus is the latest health check result of a dependency
+type DependencyStatus struct {
+	Name        string        `json:"name"`
+	State       string        `json:"state"`
+	Healthy     bool          `json:"healthy"`
+	Error       string        `json:"error,omitempty"`
+	Latency     time.Duration `json:"-"`
+	LastChecked time.Time     `json:"last_checked"`
+	LastSuccess time.Time     `json:"last_success,omitempty"`
+}
+
+type dependency struct {
+	name  string
+	check DependencyCheck
+}
+
+// HealthMonitor periodically checks the service's dependencies so job intake
+// can be refused while any of them is down
+type HealthMonitor struct {
+	interval        time.Duration
+	timeout         time.Duration
+	degradedLatency time.Duration
+	dependencies    []dependency
+	metrics         *metrics.Metrics
+	logger          *zap.Logger
+
+	mu     sync.RWMutex
+	status map[string]*DependencyStatus
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewHealthMonitor creates a monitor that runs every check once per interval.
+// Checks that pass slower than degradedLatency report the dependency as
+// degraded. metrics may be nil.
+func NewHealthMonitor(interval, timeout, degradedLatency time.Duration, metrics *metrics.Metrics, logger *zap.Logger) *HealthMonitor {
+	return &HealthMonitor{
+		interval:        interval,
+		timeout:         timeout,
+		degradedLatency: degradedLatency,
+		metrics:         metrics,
+		logger:          logger,
+		status:          make(map[string]*DependencyStatus),
+	}
+}
+
+// AddDependency registers a dependency check. It must be called before Start.
+func (m *HealthMonitor) AddDependency(name string, check DependencyCheck) {
+	m.dependencies = append(m.dependencies, dependency{name: name, check: check})
+}
+
+// Start checks every dependency once, then keeps checking in the background
+func (m *HealthMonitor) Start() {
+	m.checkAll()
+
+	ctx, stop := context.WithCancel(context.Background())
+	m.stop = stop
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.checkAll()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends background checking
+func (m *HealthMonitor) Stop() {
+	if m.stop == nil {
+		return
+	}
+	m.stop()
+	<-m.done
+}
+
+// Ready reports whether every dependency passed its last check, and lists the
+// ones that did not
+func (m *HealthMonitor) Ready() (bool, []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var down []string
+	for _, dep := range m.dependencies {
+		if status, ok := m.status[dep.name]; ok && !status.Healthy {
+			down = append(down, dep.name)
+		}
+	}
+	return len(down) == 0, down
+}
+
+// RetryAfter is how long clients should wait before retrying refused work,
+// i.e. until the next round of checks
+func (m *HealthMonitor) RetryAfter() time.Duration {
+	return m.interval
+}
+
+// Statuses returns the latest result of every dependency check
+func (m *HealthMonitor) Statuses() []DependencyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]DependencyStatus, 0, len(m.dependencies))
+	for _, dep := range m.dependencies {
+		if status, ok := m.status[dep.name]; ok {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses
+}
+
+// State summarises all dependencies: down if any is down, degraded if any is
+// degraded, ok otherwise
+func (m *HealthMonitor) State() string {
+	state := HealthOK
+	for _, status := range m.Statuses() {
+		switch status.State {
+		case HealthDown:
+			return HealthDown
+		case HealthDegraded:
+			state = HealthDegraded
+		}
+	}
+	return state
+}
+
+func (m *HealthMonitor) checkAll() {
+	for _, dep := range m.dependencies {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		start := time.Now()
+		err := dep.check(ctx)
+		latency := time.Since(start)
+		cancel()
+		m.record(dep.name, latency, err)
+	}
+}
+
+// record stores a check result, logging and counting health transitions
+func (m *HealthMonitor) record(name string, latency time.Duration, err error) {
+	healthy := err == nil
+	now := time.Now()
+
+	m.mu.Lock()
+	previous, seen := m.status[name]
+	status := &DependencyStatus{
+		Name:        name,
+		State:       HealthOK,
+		Healthy:     healthy,
+		Latency:     latency,
+		LastChecked: now,
+	}
+	switch {
+	case err != nil:
+		status.State = HealthDown
+		status.Error = err.Error()
+		if seen {
+			status.LastSuccess = previous.LastSuccess
+		}
+	case m.degradedLatency > 0 && latency > m.degradedLatency:
+		status.State = HealthDegraded
+		status.LastSuccess = now
+	default:
+		status.LastSuccess = now
+	}
+	m.status[name] = status
+	m.mu.Unlock()
+
+	if m.metrics != nil {
+		m.metrics.SetDependencyUp(name, healthy)
+	}
+
+	if seen && healthy && previous.Healthy && previous.State != status.State {
+		m.logger.Info("Dependency latency changed",
+			zap.String("dependency", name),
+			zap.String("state", status.State),
+			zap.Duration("latency", latency))
+	}
+
+	// The first result only counts as a transition when it is a failure
+	if seen && previous.Healthy == healthy || !seen && healthy {
+		return
+	}
+
+	if healthy {
+		m.logger.Info("Dependency recovered", zap.String("dependency", name))
+		if m.metrics != nil {
+			m.metrics.RecordDependencyTransition(name, "up")
+		}
+		return
+	}
+
+	m.logger.Warn("Dependency unhealthy", zap.String("dependency", name), zap.Error(err))
+	if m.metrics != nil {
+		m.metrics.RecordDependencyTransition(name, "down")
+	}
+}
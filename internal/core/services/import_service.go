@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// ImportService stores historical jobs migrated from another encryption
+// system, keeping their IDs, timestamps, statuses and histories
+type ImportService struct {
+	jobs   ports.JobRepository
+	clock  ports.Clock
+	logger *zap.Logger
+}
+
+func NewImportService(jobs ports.JobRepository, logger *zap.Logger) *ImportService {
+	return &ImportService{
+		jobs:   jobs,
+		clock:  clock.System{},
+		logger: logger,
+	}
+}
+
+// SetClock replaces the system clock used to timestamp imports
+func (s *ImportService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+func (s *ImportService) ImportJobs(ctx context.Context, records io.Reader, opts domain.ImportOptions) (*domain.ImportResult, error) {
+	importedBy := domain.PrincipalFromContext(ctx).ID
+	result := &domain.ImportResult{DryRun: opts.DryRun}
+
+	reader := bufio.NewReader(records)
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return result, fmt.Errorf("failed to read job records: %w", readErr)
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if err := s.importRecord(ctx, line, data, importedBy, opts, result); err != nil {
+				return result, err
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	s.logger.Info("Imported jobs",
+		zap.String("imported_by", importedBy),
+		zap.Bool("dry_run", opts.DryRun),
+		zap.Int("imported", result.Imported),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// importRecord imports the record on one line, adding its outcome to result.
+// Only a failing repository or a cancelled request stops the import.
+func (s *ImportService) importRecord(ctx context.Context, line int, data []byte, importedBy string, opts domain.ImportOptions, result *domain.ImportResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Unknown fields are rejected, so key material exported by the legacy
+	// system is never stored by accident
+	var record domain.JobImportRecord
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&record); err != nil {
+		result.AddError(line, "", fmt.Errorf("%w: %v", domain.ErrInvalidImport, err))
+		return nil
+	}
+	if err := record.Validate(); err != nil {
+		result.AddError(line, record.ID, err)
+		return nil
+	}
+
+	existing, err := s.jobs.Get(ctx, record.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check job %s: %w", record.ID, err)
+	}
+	if existing != nil {
+		result.Skipped++
+		return nil
+	}
+	if opts.DryRun {
+		result.Imported++
+		return nil
+	}
+
+	if err := s.jobs.Create(ctx, record.Job(importedBy, s.clock.Now())); err != nil {
+		return fmt.Errorf("failed to store job %s: %w", record.ID, err)
+	}
+	result.Imported++
+	return nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/metrics"
+)
+
+// Outcomes of an object event, as counted in ingest_events_total
+const (
+	IngestCreated   = "created"   // A job was created for the object
+	IngestDuplicate = "duplicate" // The object version already has a job
+	IngestIgnored   = "ignored"   // No rule selects the object
+	IngestRejected  = "rejected"  // The object can never be encrypted, e.g. unsupported media
+	IngestFailed    = "failed"    // Creating the job failed; the notification is retried
+)
+
+// maxIngestRetryDelay caps the backoff of failed notifications; SQS does not
+// hide a message for longer
+const maxIngestRetryDelay = 12 * time.Hour
+
+// IngestConfig selects the objects an IngestService creates jobs for
+type IngestConfig struct {
+	Source     string // Names the event source in logs and metrics, e.g. "s3"
+	Rules      []domain.IngestRule
+	Owner      string        // Recorded as the jobs' created_by
+	DedupeTTL  time.Duration // How long an object version is remembered
+	RetryDelay time.Duration // Wait before a failed notification is redelivered, doubled for each delivery
+}
+
+// IngestService creates encryption jobs for new objects reported by an event
+// source. Each object version gets one job however often it is reported, and
+// notifications whose jobs could not be created are delivered again.
+type IngestService struct {
+	jobs    ports.EncryptionService
+	events  ports.ObjectEventSource
+	dedupe  ports.DedupeStore
+	config  IngestConfig
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewIngestService creates an ingest service. metrics may be nil.
+func NewIngestService(jobs ports.EncryptionService, events ports.ObjectEventSource, dedupe ports.DedupeStore, config IngestConfig, metrics *metrics.Metrics, logger *zap.Logger) *IngestService {
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Second
+	}
+	return &IngestService{
+		jobs:    jobs,
+		events:  events,
+		dedupe:  dedupe,
+		config:  config,
+		metrics: metrics,
+		logger:  logger.With(zap.String("ingest_source", config.Source)),
+	}
+}
+
+// Start receives notifications in the background until Stop is called
+func (s *IngestService) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.done = make(chan struct{})
+	go s.run(ctx)
+
+	s.logger.Info("Started job ingestion", zap.Int("rules", len(s.config.Rules)))
+}
+
+// Stop stops receiving and waits for the notifications in hand to be handled
+func (s *IngestService) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	s.stop()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *IngestService) run(ctx context.Context) {
+	defer close(s.done)
+
+	failures := 0
+	for {
+		notifications, err := s.events.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			delay := backoff(s.config.RetryDelay, failures, time.Minute)
+			s.logger.Warn("Failed to receive object events",
+				zap.Duration("retry_in", delay),
+				zap.Error(err))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		failures = 0
+
+		// Notifications in hand are finished even when stopping, so none is
+		// left half handled
+		for _, n := range notifications {
+			s.handle(context.Background(), n)
+		}
+	}
+}
+
+// handle creates the jobs of one notification, then acknowledges it, or
+// retries it if any job could not be created
+func (s *IngestService) handle(ctx context.Context, n domain.ObjectNotification) {
+	if s.config.Owner != "" {
+		ctx = domain.ContextWithPrincipal(ctx, domain.Principal{ID: s.config.Owner})
+	}
+
+	retry := false
+	for _, event := range n.Events {
+		outcome := s.ingest(ctx, event)
+		if s.metrics != nil {
+			s.metrics.RecordIngestEvent(s.config.Source, outcome)
+		}
+		if outcome == IngestFailed {
+			retry = true
+		}
+	}
+
+	if retry {
+		// Jobs already created are recognized as duplicates on redelivery
+		delay := backoff(s.config.RetryDelay, n.Receives, maxIngestRetryDelay)
+		if err := s.events.Retry(ctx, n, delay); err != nil {
+			s.logger.Warn("Failed to schedule notification retry; it is redelivered once its lease expires",
+				zap.String("notification_id", n.ID),
+				zap.Error(err))
+		}
+		return
+	}
+	if err := s.events.Ack(ctx, n); err != nil {
+		s.logger.Warn("Failed to acknowledge notification; it is redelivered and deduplicated",
+			zap.String("notification_id", n.ID),
+			zap.Error(err))
+	}
+}
+
+// ingest creates the job for one object event unless it is not selected or
+// already has one
+func (s *IngestService) ingest(ctx context.Context, event domain.ObjectEvent) string {
+	if !s.selects(event) {
+		return IngestIgnored
+	}
+
+	key := event.DedupeKey()
+	claimed, err := s.dedupe.Claim(ctx, key, s.config.DedupeTTL)
+	if err != nil {
+		s.logger.Error("Failed to check object for an existing job",
+			zap.String("source_url", event.SourceURL),
+			zap.Error(err))
+		return IngestFailed
+	}
+	if !claimed {
+		s.logger.Debug("Skipping object that already has a job",
+			zap.String("source_url", event.SourceURL),
+			zap.String("etag", event.ETag))
+		return IngestDuplicate
+	}
+
+	job, err := s.jobs.StartEncryption(ctx, event.SourceURL, domain.JobOptions{})
+	if err != nil {
+		if errors.Is(err, domain.ErrUnsupportedMedia) || errors.Is(err, domain.ErrSourceRejected) {
+			// Retrying cannot help; the claim keeps redeliveries from trying
+			s.logger.Warn("Rejected new object",
+				zap.String("source_url", event.SourceURL),
+				zap.Error(err))
+			return IngestRejected
+		}
+		if releaseErr := s.dedupe.Release(ctx, key); releaseErr != nil {
+			s.logger.Error("Failed to release object claim; it is skipped until the claim expires",
+				zap.String("source_url", event.SourceURL),
+				zap.Error(releaseErr))
+		}
+		s.logger.Error("Failed to create job for new object",
+			zap.String("source_url", event.SourceURL),
+			zap.Error(err))
+		return IngestFailed
+	}
+
+	s.logger.Info("Created job for new object",
+		zap.String("job_id", job.ID),
+		zap.String("source_url", event.SourceURL),
+		zap.Int64("size", event.Size))
+	return IngestCreated
+}
+
+func (s *IngestService) selects(event domain.ObjectEvent) bool {
+	for _, rule := range s.config.Rules {
+		if rule.Matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns base doubled for each attempt after the first, capped at limit
+func backoff(base time.Duration, attempt int, limit time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		return limit
+	}
+	return delay
+}
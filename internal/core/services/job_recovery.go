@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// RecoveryConfig configures the reconciliation of jobs left behind by workers
+// that died
+type RecoveryConfig struct {
+	Policy     string        // domain.RecoveryRequeue, RecoveryFail or RecoveryNone
+	StaleAfter time.Duration // Silence after which a running job's worker counts as lost
+	QueueLost  bool          // The job queue does not survive restarts, so queued jobs are enqueued again
+}
+
+// RecoveryResult counts the jobs a reconciliation recovered
+type RecoveryResult struct {
+	Requeued int
+	Failed   int
+	Enqueued int // Queued jobs enqueued again because the queue was lost
+}
+
+// JobRecovery finds the jobs no worker holds any longer after a crash, such
+// as jobs left IN_PROGRESS by a worker that died, and requeues or fails them
+type JobRecovery struct {
+	repository ports.JobRepository
+	queue      ports.JobQueue
+	config     RecoveryConfig
+	clock      ports.Clock
+	events     ports.EventQueue
+	logger     *zap.Logger
+}
+
+func NewJobRecovery(repository ports.JobRepository, queue ports.JobQueue, config RecoveryConfig, logger *zap.Logger) *JobRecovery {
+	return &JobRecovery{
+		repository: repository,
+		queue:      queue,
+		config:     config,
+		clock:      clock.System{},
+		logger:     logger,
+	}
+}
+
+// SetEventQueue makes the recovery publish an event for each job it fails
+func (r *JobRecovery) SetEventQueue(events ports.EventQueue) {
+	r.events = events
+}
+
+// SetClock replaces the system clock used to judge heartbeats and for job
+// timestamps
+func (r *JobRecovery) SetClock(c ports.Clock) {
+	r.clock = c
+}
+
+// Reconcile recovers orphaned jobs according to the policy. It is meant to
+// run at startup, before the workers take jobs. A job that cannot be
+// recovered is logged and left for the next run.
+func (r *JobRecovery) Reconcile(ctx context.Context) (RecoveryResult, error) {
+	var result RecoveryResult
+	if r.config.Policy == domain.RecoveryNone && !r.config.QueueLost {
+		return result, nil
+	}
+	now := r.clock.Now()
+
+	// Before requeueing, so requeued jobs are not enqueued twice
+	if r.config.QueueLost {
+		jobs, err := r.repository.Query(ctx, domain.JobQuery{Filter: domain.JobFilter{Status: string(domain.StatusQueued)}})
+		if err != nil {
+			return result, fmt.Errorf("failed to list queued jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if err := r.queue.Enqueue(ctx, job.ID); err != nil {
+				r.logger.Error("Failed to enqueue queued job again", zap.String("job_id", job.ID), zap.Error(err))
+				continue
+			}
+			result.Enqueued++
+		}
+	}
+
+	if r.config.Policy != domain.RecoveryNone {
+		for _, status := range []domain.EncryptionStatus{domain.StatusProgress, domain.StatusPending} {
+			jobs, err := r.repository.Query(ctx, domain.JobQuery{Filter: domain.JobFilter{Status: string(status)}})
+			if err != nil {
+				return result, fmt.Errorf("failed to list %s jobs: %w", status, err)
+			}
+			for _, job := range jobs {
+				if !job.Orphaned(r.config.StaleAfter, now) {
+					continue
+				}
+				if r.config.Policy == domain.RecoveryFail {
+					if r.fail(ctx, job, now) {
+						result.Failed++
+					}
+				} else if r.requeue(ctx, job, now) {
+					result.Requeued++
+				}
+			}
+		}
+	}
+
+	r.logger.Info("Reconciled jobs of lost workers",
+		zap.String("policy", r.config.Policy),
+		zap.Int("requeued", result.Requeued),
+		zap.Int("failed", result.Failed),
+		zap.Int("enqueued", result.Enqueued))
+	return result, nil
+}
+
+// requeue starts an orphaned job over
+func (r *JobRecovery) requeue(ctx context.Context, job *domain.EncryptionJob, now time.Time) bool {
+	if err := requeueJob(ctx, r.repository, r.queue, job, now); err != nil {
+		r.logger.Error("Failed to requeue job of lost worker", zap.String("job_id", job.ID), zap.Error(err))
+		return false
+	}
+	r.logger.Warn("Requeued job of lost worker",
+		zap.String("job_id", job.ID),
+		zap.Time("last_heartbeat", job.LastHeartbeat()))
+	return true
+}
+
+// fail records that an orphaned job's worker was lost
+func (r *JobRecovery) fail(ctx context.Context, job *domain.EncryptionJob, now time.Time) bool {
+	if err := failJob(ctx, r.repository, job, "worker lost", domain.ErrCodeWorkerLost, now); err != nil {
+		r.logger.Error("Failed to fail job of lost worker", zap.String("job_id", job.ID), zap.Error(err))
+		return false
+	}
+	publishJobOutcome(ctx, r.events, job, now, r.logger)
+	r.logger.Warn("Failed job of lost worker",
+		zap.String("job_id", job.ID),
+		zap.Time("last_heartbeat", job.LastHeartbeat()))
+	return true
+}
+
+// requeueJob starts a job no worker runs any longer over, or from its
+// checkpoint: a running job is returned to PENDING, then queued again
+func requeueJob(ctx context.Context, repository ports.JobRepository, queue ports.JobQueue, job *domain.EncryptionJob, now time.Time) error {
+	if job.Status == domain.StatusProgress {
+		job.ResetProgress()
+		job.ResetOutputs()
+		if err := job.Transition(domain.StatusPending, domain.JobActionRecover, now); err != nil {
+			return err
+		}
+	}
+	if err := job.Transition(domain.StatusQueued, domain.JobActionRequeue, now); err != nil {
+		return err
+	}
+	if err := repository.Update(ctx, job); err != nil {
+		return err
+	}
+	return queue.Enqueue(ctx, job.ID)
+}
+
+// failJob fails a job no worker runs any longer with message and code
+func failJob(ctx context.Context, repository ports.JobRepository, job *domain.EncryptionJob, message, code string, now time.Time) error {
+	job.Error = message
+	job.ErrorCode = code
+	job.FailOutputs(job.Error)
+	if err := job.Transition(domain.StatusFailed, domain.JobActionRecover, now); err != nil {
+		return err
+	}
+	return repository.Update(ctx, job)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/pkg/diskspace"
+)
+
+// throttlingCodes are the error codes AWS services answer with when they ask
+// for fewer requests
+var throttlingCodes = map[string]bool{
+	"SlowDown":             true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestLimitExceeded": true,
+	"TooManyRequests":      true,
+	"RequestThrottled":     true,
+}
+
+// retryClass returns the class of a failed run that may pass when the job
+// runs again, or "" for failures that would recur. Status and error codes
+// are read through the methods the AWS SDK's errors share with the source
+// fetcher's and the GCS client's, so no adapter's types are needed here.
+func retryClass(err error) string {
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) && throttlingCodes[coded.ErrorCode()] {
+		return domain.RetryClassThrottled
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		switch code := status.HTTPStatusCode(); {
+		case code == http.StatusTooManyRequests:
+			return domain.RetryClassThrottled
+		case code >= 500:
+			return domain.RetryClassUnavailable
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, diskspace.ErrLow):
+		return domain.RetryClassDiskSpace
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return domain.RetryClassNetwork
+	}
+	return ""
+}
+
+// SetRetryPolicy makes workers run jobs whose run failed again as policy
+// allows, instead of failing them. Retried jobs are scheduled, so a job
+// scheduler must be dispatching.
+func (p *WorkerPool) SetRetryPolicy(policy domain.RetryPolicy) {
+	p.retryPolicy = policy
+}
+
+// RetryJob runs a failed job again as a new job with the same source and
+// parameters. The new job names the failed one in retry_of; the failed job
+// keeps its status and error, and records the retry in retried_by and its
+// history.
+func (s *EncryptionService) RetryJob(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
+	job, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := job.CanRetry(); err != nil {
+		return nil, err
+	}
+
+	var retry *domain.EncryptionJob
+	switch {
+	case job.IsRotation() && job.Rotation != nil:
+		// Rotations are retried for the same key
+		retry, err = s.RotateKey(ctx, job.Rotation.FromJobID, domain.KeyRotationRequest{
+			KeyRef:   job.Rotation.KeyRef,
+			Metadata: job.Metadata,
+			RetryOf:  job.ID,
+		})
+	case job.IsDecryption() && job.Decryption != nil:
+		// Decryptions are retried with the key of the same encryption job
+		retry, err = s.StartDecryption(ctx, domain.DecryptionRequest{
+			JobID:     job.Decryption.KeyJobID,
+			Output:    job.Decryption.Output,
+			SourceURL: job.SourceURL,
+			KeyRef:    job.Decryption.KeyRef,
+			Metadata:  job.Metadata,
+			RetryOf:   job.ID,
+		})
+	default:
+		// Retries reproduce the original job's engine parameters and outputs
+		opts := retryOptions(job)
+		opts.RetryOf = job.ID
+		retry, err = s.StartEncryption(ctx, job.SourceURL, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.RecordRetry(retry.ID, s.clock.Now())
+	if err := s.repository.Update(ctx, job); err != nil {
+		// The retry is queued all the same; only the failed job misses the link
+		s.logger.Error("Failed to record job retry",
+			zap.String("job_id", job.ID),
+			zap.String("retry_job_id", retry.ID),
+			zap.Error(err))
+	}
+
+	s.logger.Info("Retried failed job",
+		zap.String("job_id", job.ID),
+		zap.String("retry_job_id", retry.ID),
+	)
+	return retry, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// JobScheduler periodically queues the scheduled jobs whose start has come.
+// Every process running one dispatches; a job queued twice by processes
+// dispatching at the same time is still run once, as workers skip jobs that
+// are no longer queued or that another worker holds.
+type JobScheduler struct {
+	repository ports.JobRepository
+	queue      ports.JobQueue
+	interval   time.Duration
+	clock      ports.Clock
+	logger     *zap.Logger
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewJobScheduler(repository ports.JobRepository, queue ports.JobQueue, interval time.Duration, logger *zap.Logger) *JobScheduler {
+	return &JobScheduler{
+		repository: repository,
+		queue:      queue,
+		interval:   interval,
+		clock:      clock.System{},
+		logger:     logger,
+	}
+}
+
+// SetClock replaces the system clock used to judge scheduled starts and for
+// job timestamps
+func (s *JobScheduler) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+// Start dispatches the due jobs every interval in the background
+func (s *JobScheduler) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Dispatch(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Failed to dispatch scheduled jobs", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Started job scheduler", zap.Duration("interval", s.interval))
+}
+
+// Stop ends background dispatching
+func (s *JobScheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+}
+
+// Dispatch queues the scheduled jobs whose start has come, returning how many
+// were queued. A job that cannot be queued is failed, like one that cannot be
+// queued on submission.
+func (s *JobScheduler) Dispatch(ctx context.Context) (int, error) {
+	jobs, err := s.repository.Query(ctx, domain.JobQuery{Filter: domain.JobFilter{Status: string(domain.StatusScheduled)}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list scheduled jobs: %w", err)
+	}
+
+	now := s.clock.Now()
+	dispatched := 0
+	for _, job := range jobs {
+		if !job.IsDue(now) {
+			continue
+		}
+		// Stored as QUEUED before it is enqueued so a worker never sees it
+		// scheduled
+		if err := job.Transition(domain.StatusQueued, domain.JobActionDispatch, now); err != nil {
+			s.logger.Error("Failed to dispatch scheduled job", zap.String("job_id", job.ID), zap.Error(err))
+			continue
+		}
+		if err := s.repository.Update(ctx, job); err != nil {
+			s.logger.Error("Failed to dispatch scheduled job", zap.String("job_id", job.ID), zap.Error(err))
+			continue
+		}
+		if err := s.queue.Enqueue(ctx, job.ID); err != nil {
+			s.logger.Error("Failed to queue scheduled job", zap.String("job_id", job.ID), zap.Error(err))
+			job.Error = "failed to queue job"
+			if err := job.Transition(domain.StatusFailed, domain.JobActionFail, now); err == nil {
+				if err := s.repository.Update(context.Background(), job); err != nil {
+					s.logger.Error("Failed to mark unqueued job as failed", zap.String("job_id", job.ID), zap.Error(err))
+				}
+			}
+			continue
+		}
+		dispatched++
+
+		s.logger.Info("Dispatched scheduled job",
+			zap.String("job_id", job.ID),
+			zap.Time("scheduled_at", time.Unix(job.ScheduledAt, 0)))
+	}
+	return dispatched, nil
+}
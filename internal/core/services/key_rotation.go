@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// RotateKey creates a key rotation job and queues it for the workers. The job
+// re-encrypts the job's output with the same engine parameters under a new
+// key; the old key is retired by the worker once the new output is verified.
+func (s *EncryptionService) RotateKey(ctx context.Context, jobID string, req domain.KeyRotationRequest) (*domain.EncryptionJob, error) {
+	if s.draining.Load() {
+		return nil, domain.ErrNotAcceptingJobs
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	from, err := s.getOwnedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := from.RotationSource()
+	if err != nil {
+		return nil, err
+	}
+	if result.KeyRef != req.KeyRef {
+		return nil, domain.ValidationErrors{{
+			Field:   "key_ref",
+			Message: fmt.Sprintf("key_ref does not match the key of job %s", from.ID),
+			Value:   req.KeyRef,
+		}}
+	}
+
+	metadata := req.Metadata
+	if metadata == nil {
+		metadata = from.Metadata
+	}
+	job := domain.NewEncryptionJob(result.OutputURL, metadata, s.clock.Now())
+	job.ID = uuid.New().String()
+	job.Kind = domain.JobKindRotate
+	job.Rotation = &domain.KeyRotation{
+		FromJobID: from.ID,
+		KeyRef:    req.KeyRef,
+	}
+	job.RetryOf = req.RetryOf
+	job.Engine = domain.EngineParams{
+		Algorithm:  result.Algorithm,
+		KeyLength:  result.KeyLength,
+		ChunkSize:  result.ChunkSize,
+		IVStrategy: result.IVStrategy,
+		Container:  result.Container,
+	}
+	job.KeySource = domain.KeySourceGenerated
+	// The new output is written next to the old one
+	job.Destination = from.Destination
+	job.CopyTo = from.CopyTo
+	principal := domain.PrincipalFromContext(ctx)
+	job.CreatedBy = principal.ID
+	job.Tenant = principal.TenantID()
+	if err := job.Transition(domain.StatusQueued, domain.JobActionQueue, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	if s.quotas.Enabled() {
+		s.admission.Lock()
+		defer s.admission.Unlock()
+	}
+	if err := s.AdmitJobs(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	s.summaries.invalidate()
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	s.recordJob(job.Status)
+
+	s.logger.Info("Queued key rotation job",
+		zap.String("job_id", job.ID),
+		zap.String("from_job_id", from.ID),
+	)
+	return job, nil
+}
+
+// rotate re-encrypts the output of the job a key rotation job names under a
+// new key, then reads the new output back to verify that it decrypts to the
+// same content. It returns the new key; the old one is retired by retireKey
+// once the job is recorded as completed.
+func (p *WorkerPool) rotate(ctx context.Context, abort context.CancelFunc, job *domain.EncryptionJob) (*domain.JobResult, string, error) {
+	if job.Rotation == nil {
+		return nil, "", fmt.Errorf("%w: key rotation job %s names no key", domain.ErrKeyUnavailable, job.ID)
+	}
+	start := p.clock.Now()
+	update := p.progressUpdater(job, abort)
+
+	from, err := p.repository.Get(ctx, job.Rotation.FromJobID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load job %s: %w", job.Rotation.FromJobID, err)
+	}
+	if from == nil {
+		return nil, "", fmt.Errorf("%w: job %s no longer exists", domain.ErrKeyUnavailable, job.Rotation.FromJobID)
+	}
+	_, stored, err := from.RotationSource()
+	if err != nil {
+		return nil, "", err
+	}
+	oldKey, err := openKey(ctx, p.keys, domain.ContentKey{JobID: from.ID}, stored)
+	if err != nil {
+		return nil, "", err
+	}
+	if keyRef(oldKey) != job.Rotation.KeyRef {
+		return nil, "", fmt.Errorf("%w: the key of job %s no longer matches key_ref", domain.ErrKeyUnavailable, from.ID)
+	}
+
+	params := job.Engine.WithDefaults()
+	key, err := p.engine.GenerateKey(params)
+	if err != nil {
+		return nil, "", err
+	}
+	result := &domain.JobResult{
+		Algorithm:  params.Algorithm,
+		KeyLength:  params.KeyLength,
+		ChunkSize:  params.ChunkSize,
+		IVStrategy: params.IVStrategy,
+		Container:  params.Container,
+	}
+
+	fetchStart := p.clock.Now()
+	src, size, err := p.fetcher.Open(ctx, job.SourceURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	result.Timings.Fetch = domain.Duration(p.clock.Now().Sub(fetchStart))
+
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, update)
+	update(reader.snapshot(p.clock.Now()))
+
+	// The plaintext is decrypted with the old key straight into the
+	// encryption with the new one, and hashed on the way to verify the
+	// new output against
+	plaintext, decrypted := io.Pipe()
+	go func() {
+		decrypted.CloseWithError(p.engine.Decrypt(reader, decrypted, oldKey))
+	}()
+	defer plaintext.Close()
+	digest := sha256.New()
+
+	encrypted := func() {
+		progress := reader.snapshot(p.clock.Now())
+		progress.Stage = domain.StageStoring
+		progress.ETA = 0
+		update(progress)
+	}
+	storage, outputPath, err := p.outputLocation(job, job.ID+".enc")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := p.streamOutput(storage, outputPath, io.TeeReader(plaintext, digest), key, params, result, encrypted); err != nil {
+		return nil, "", err
+	}
+
+	if err := p.verifyOutput(ctx, result.OutputURL, key, digest.Sum(nil)); err != nil {
+		return nil, "", err
+	}
+	result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+	return result, key, nil
+}
+
+// verifyOutput reads an encrypted output back and checks that it decrypts
+// with key to content with the SHA-256 digest want
+func (p *WorkerPool) verifyOutput(ctx context.Context, outputURL, key string, want []byte) error {
+	src, _, err := p.fetcher.Open(ctx, outputURL)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", outputURL, err)
+	}
+	defer src.Close()
+
+	digest := sha256.New()
+	if err := p.engine.Decrypt(src, digest, key); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrRotationMismatch, err)
+	}
+	if !bytes.Equal(digest.Sum(nil), want) {
+		return domain.ErrRotationMismatch
+	}
+	return nil
+}
+
+// retireKey retires the key a completed key rotation job replaced: the job it
+// rotated records the rotation, so its key is not rotated again, and the
+// key's policy stops every delivery of it. The old key stays with its job,
+// so the old output can still be decrypted until it is deleted.
+func (p *WorkerPool) retireKey(ctx context.Context, job *domain.EncryptionJob) {
+	from, err := p.repository.Get(ctx, job.Rotation.FromJobID)
+	if err != nil || from == nil {
+		p.logger.Error("Failed to load job of retired key",
+			zap.String("job_id", job.ID),
+			zap.String("from_job_id", job.Rotation.FromJobID),
+			zap.Error(err))
+		return
+	}
+	from.RotatedTo = job.ID
+	from.UpdatedAt = p.clock.Now().Unix()
+	if err := p.repository.Update(ctx, from); err != nil {
+		p.logger.Error("Failed to record key rotation",
+			zap.String("job_id", job.ID),
+			zap.String("from_job_id", from.ID),
+			zap.Error(err))
+	}
+
+	key := domain.ContentKey{JobID: from.ID}
+	if p.keyPolicies != nil {
+		policy, err := p.keyPolicies.GetPolicy(ctx, key)
+		if err == nil {
+			if policy == nil {
+				policy = &domain.KeyPolicy{Key: key}
+			}
+			policy.RetiredAt = p.clock.Now().Unix()
+			policy.ReplacedBy = job.ID
+			policy.UpdatedBy = job.CreatedBy
+			policy.UpdatedAt = policy.RetiredAt
+			err = p.keyPolicies.SavePolicy(ctx, policy)
+		}
+		if err != nil {
+			p.logger.Error("Failed to retire key", zap.String("key", key.String()), zap.Error(err))
+		}
+	}
+	if p.keyAudit != nil {
+		event := domain.KeyAuditEvent{
+			Time:      p.clock.Now(),
+			Action:    domain.KeyAuditRetired,
+			Key:       key,
+			Principal: job.CreatedBy,
+		}
+		if err := p.keyAudit.Record(ctx, event); err != nil {
+			p.logger.Error("Failed to record key audit event",
+				zap.String("action", event.Action),
+				zap.String("key", key.String()),
+				zap.Error(err))
+		}
+	}
+
+	p.logger.Info("Retired rotated key",
+		zap.String("key", key.String()),
+		zap.String("replaced_by", job.ID))
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+	"E.E/pkg/metrics"
+)
+
+// Outcomes of key requests, as recorded in metrics
+const (
+	KeyDelivered    = "delivered"
+	KeyDenied       = "denied"
+	KeyInvalidToken = "invalid_token"
+	KeyFailed       = "failed"
+)
+
+// KeyServiceConfig configures a KeyService
+type KeyServiceConfig struct {
+	TokenSecret     []byte        // Signs key tokens
+	DefaultTokenTTL time.Duration // Lifetime of tokens requested without one
+	MaxTokenTTL     time.Duration // Longest lifetime a token may be issued with
+}
+
+// KeyService is the key server: job owners set per-key access policies and
+// issue short-lived tokens, which players and packagers exchange for the
+// content keys of completed jobs. Every policy change, issued token and
+// delivery attempt is recorded in the audit log; keys are only delivered
+// once their delivery is recorded.
+type KeyService struct {
+	jobs     ports.JobRepository
+	policies ports.KeyPolicyStore
+	audit    ports.KeyAuditLog
+	config   KeyServiceConfig
+	clock    ports.Clock
+	keys     ports.KeyStore
+	metrics  *metrics.Metrics
+	logger   *zap.Logger
+}
+
+func NewKeyService(
+	jobs ports.JobRepository,
+	policies ports.KeyPolicyStore,
+	audit ports.KeyAuditLog,
+	config KeyServiceConfig,
+	metrics *metrics.Metrics,
+	logger *zap.Logger,
+) *KeyService {
+	if config.MaxTokenTTL <= 0 {
+		config.MaxTokenTTL = time.Hour
+	}
+	if config.DefaultTokenTTL <= 0 || config.DefaultTokenTTL > config.MaxTokenTTL {
+		config.DefaultTokenTTL = config.MaxTokenTTL
+	}
+
+	return &KeyService{
+		jobs:     jobs,
+		policies: policies,
+		audit:    audit,
+		config:   config,
+		clock:    clock.System{},
+		metrics:  metrics,
+		logger:   logger,
+	}
+}
+
+// SetClock replaces the system clock used for token lifetimes and policy windows
+func (s *KeyService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+// SetKeyStore opens the keys of jobs whose keys are sealed with store
+func (s *KeyService) SetKeyStore(store ports.KeyStore) {
+	s.keys = store
+}
+
+func (s *KeyService) GetKeyPolicy(ctx context.Context, key domain.ContentKey) (*domain.KeyPolicy, error) {
+	if _, _, err := s.ownedKey(ctx, key); err != nil {
+		return nil, err
+	}
+	return s.policies.GetPolicy(ctx, key)
+}
+
+func (s *KeyService) SetKeyPolicy(ctx context.Context, key domain.ContentKey, req domain.KeyPolicyRequest, access domain.KeyAccess) (*domain.KeyPolicy, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := requireKeys(ctx); err != nil {
+		return nil, err
+	}
+	if _, _, err := s.ownedKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	principal := domain.PrincipalFromContext(ctx)
+	policy := &domain.KeyPolicy{
+		Key:           key,
+		Clients:       req.Clients,
+		NotBefore:     req.NotBefore,
+		NotAfter:      req.NotAfter,
+		MaxDeliveries: req.MaxDeliveries,
+		MaxTokenTTL:   domain.Duration(time.Duration(req.MaxTokenTTLSecs) * time.Second),
+		Disabled:      req.Disabled,
+		UpdatedBy:     principal.ID,
+		UpdatedAt:     s.clock.Now().Unix(),
+	}
+	// Retired keys stay retired whatever else their policy allows
+	current, err := s.policies.GetPolicy(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		policy.RetiredAt, policy.ReplacedBy = current.RetiredAt, current.ReplacedBy
+	}
+	if err := s.policies.SavePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, domain.KeyAuditEvent{
+		Action:    domain.KeyAuditPolicyUpdated,
+		Key:       key,
+		Principal: principal.ID,
+	}, access)
+	return policy, nil
+}
+
+func (s *KeyService) IssueKeyToken(ctx context.Context, jobID string, req domain.KeyTokenRequest, access domain.KeyAccess) (*domain.KeyToken, error) {
+	if req.Client == "" || len(req.Client) > 128 {
+		return nil, fmt.Errorf("%w: client must be 1-128 characters", domain.ErrInvalidKeyPolicy)
+	}
+	if req.TTLSeconds < 0 {
+		return nil, fmt.Errorf("%w: ttl_seconds must not be negative", domain.ErrInvalidKeyPolicy)
+	}
+	if err := requireKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	key := domain.ContentKey{JobID: jobID, Output: req.Output}
+	material, _, err := s.ownedKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.policies.GetPolicy(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.AllowsClient(req.Client) {
+		return nil, fmt.Errorf("%w: client %q is not allowed by the key's policy", domain.ErrKeyDenied, req.Client)
+	}
+
+	ttl := s.config.DefaultTokenTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > s.config.MaxTokenTTL {
+		ttl = s.config.MaxTokenTTL
+	}
+	if policy != nil && policy.MaxTokenTTL > 0 && ttl > policy.MaxTokenTTL.Std() {
+		ttl = policy.MaxTokenTTL.Std()
+	}
+
+	now := s.clock.Now()
+	claims := domain.KeyClaims{
+		Key:       key,
+		Client:    req.Client,
+		IssuedBy:  domain.PrincipalFromContext(ctx).ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		TokenID:   uuid.New().String(),
+	}
+	token, err := signKeyToken(claims, s.config.TokenSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens that cannot be audited are not handed out
+	if err := s.record(ctx, domain.KeyAuditEvent{
+		Action:    domain.KeyAuditTokenIssued,
+		Key:       key,
+		Client:    req.Client,
+		Principal: claims.IssuedBy,
+		TokenID:   claims.TokenID,
+	}, access); err != nil {
+		return nil, err
+	}
+
+	return &domain.KeyToken{
+		Token:     token,
+		KeyID:     base64.RawURLEncoding.EncodeToString(domain.KeyID(material)),
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+func (s *KeyService) DeliverKey(ctx context.Context, token string, keyIDs [][]byte, access domain.KeyAccess) (*domain.DeliveredKey, error) {
+	now := s.clock.Now()
+	claims, err := verifyKeyToken(token, s.config.TokenSecret, now)
+	if err != nil {
+		s.recordOutcome(KeyInvalidToken)
+		s.logger.Info("Rejected key token",
+			zap.String("remote_ip", access.RemoteIP),
+			zap.String("request_id", access.RequestID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	// deny records why the delivery was refused
+	deny := func(err error) (*domain.DeliveredKey, error) {
+		s.recordOutcome(KeyDenied)
+		s.record(ctx, domain.KeyAuditEvent{
+			Action:  domain.KeyAuditDenied,
+			Key:     claims.Key,
+			Client:  claims.Client,
+			TokenID: claims.TokenID,
+			Reason:  err.Error(),
+		}, access)
+		return nil, err
+	}
+
+	material, err := s.keyMaterial(ctx, claims.Key)
+	if err != nil {
+		if errors.Is(err, domain.ErrJobNotFound) || errors.Is(err, domain.ErrOutputNotFound) {
+			return deny(fmt.Errorf("%w: key no longer exists", domain.ErrKeyDenied))
+		}
+		s.recordOutcome(KeyFailed)
+		return nil, err
+	}
+	keyID := domain.KeyID(material)
+	if len(keyIDs) > 0 && !containsKeyID(keyIDs, keyID) {
+		return deny(fmt.Errorf("%w: the token does not grant the requested key IDs", domain.ErrKeyDenied))
+	}
+
+	policy, err := s.policies.GetPolicy(ctx, claims.Key)
+	if err != nil {
+		s.recordOutcome(KeyFailed)
+		return nil, err
+	}
+	if err := policy.CheckDelivery(claims.Client, now); err != nil {
+		return deny(err)
+	}
+	limit := 0
+	if policy != nil {
+		limit = policy.MaxDeliveries
+	}
+	counted, err := s.policies.CountDelivery(ctx, claims.Key, limit)
+	if err != nil {
+		s.recordOutcome(KeyFailed)
+		return nil, err
+	}
+	if !counted {
+		return deny(fmt.Errorf("%w: key was delivered the maximum of %d times", domain.ErrKeyDenied, limit))
+	}
+
+	// Keys are only handed out once the delivery is on record
+	if err := s.record(ctx, domain.KeyAuditEvent{
+		Action:  domain.KeyAuditDelivered,
+		Key:     claims.Key,
+		Client:  claims.Client,
+		TokenID: claims.TokenID,
+	}, access); err != nil {
+		s.recordOutcome(KeyFailed)
+		return nil, err
+	}
+	s.recordOutcome(KeyDelivered)
+
+	raw, err := hex.DecodeString(material)
+	if err != nil {
+		return nil, fmt.Errorf("key of %s is not hex encoded: %w", claims.Key, err)
+	}
+	return &domain.DeliveredKey{
+		Key:      claims.Key,
+		KeyID:    keyID,
+		Material: raw,
+		Client:   claims.Client,
+	}, nil
+}
+
+func (s *KeyService) GetKeyAudit(ctx context.Context, jobID string, limit int) ([]domain.KeyAuditEvent, error) {
+	job, err := s.jobs.Get(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	// The trail outlives a deleted job; only admins may still read it then
+	if job == nil {
+		if !domain.PrincipalFromContext(ctx).Admin {
+			return nil, domain.ErrJobNotFound
+		}
+	} else if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+		return nil, err
+	}
+	return s.audit.List(ctx, jobID, limit)
+}
+
+// ownedKey returns the material of a key of a job the caller may act on
+func (s *KeyService) ownedKey(ctx context.Context, key domain.ContentKey) (string, *domain.EncryptionJob, error) {
+	job, err := s.jobs.Get(ctx, key.JobID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return "", nil, domain.ErrJobNotFound
+	}
+	if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+		return "", nil, err
+	}
+	material, err := s.jobKey(ctx, job, key.Output)
+	if err != nil {
+		return "", nil, err
+	}
+	return material, job, nil
+}
+
+// requireKeys checks that the caller may hand out access to decryption keys,
+// which takes the keys scope like retrieving them does
+func requireKeys(ctx context.Context) error {
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.CanRetrieveKeys() {
+		return fmt.Errorf("%w: %q may not grant access to decryption keys", domain.ErrForbidden, principal.ID)
+	}
+	return nil
+}
+
+// keyMaterial returns the material of a key without checking the caller
+func (s *KeyService) keyMaterial(ctx context.Context, key domain.ContentKey) (string, error) {
+	job, err := s.jobs.Get(ctx, key.JobID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return "", domain.ErrJobNotFound
+	}
+	return s.jobKey(ctx, job, key.Output)
+}
+
+// jobKey returns the hex encoded key of a job, or of one of its outputs, once
+// it has been stored, opening it with the key store if it is sealed
+func (s *KeyService) jobKey(ctx context.Context, job *domain.EncryptionJob, output string) (string, error) {
+	key := domain.ContentKey{JobID: job.ID, Output: output}
+	if output != "" {
+		out, err := job.Output(output)
+		if err != nil {
+			return "", err
+		}
+		if out.Status != domain.StatusCompleted || out.StoredKey().IsZero() {
+			return "", domain.NewJobStateError(job.ID, out.Status, "deliver key of", fmt.Sprintf("output %s has not completed", output))
+		}
+		return openKey(ctx, s.keys, key, out.StoredKey())
+	}
+	if job.Status != domain.StatusCompleted || job.StoredKey().IsZero() {
+		return "", domain.NewJobStateError(job.ID, job.Status, "deliver key of", "job has not completed")
+	}
+	return openKey(ctx, s.keys, key, job.StoredKey())
+}
+
+func containsKeyID(keyIDs [][]byte, keyID []byte) bool {
+	for _, id := range keyIDs {
+		if bytes.Equal(id, keyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// record writes an event to the audit log and the structured log
+func (s *KeyService) record(ctx context.Context, event domain.KeyAuditEvent, access domain.KeyAccess) error {
+	event.Time = s.clock.Now()
+	event.RemoteIP = access.RemoteIP
+	event.UserAgent = access.UserAgent
+	event.RequestID = access.RequestID
+
+	s.logger.Info("Key audit",
+		zap.String("action", event.Action),
+		zap.String("key", event.Key.String()),
+		zap.String("client", event.Client),
+		zap.String("principal", event.Principal),
+		zap.String("token_id", event.TokenID),
+		zap.String("reason", event.Reason),
+		zap.String("remote_ip", event.RemoteIP),
+		zap.String("request_id", event.RequestID))
+
+	// Recorded even if the caller gave up, so no access goes unaudited
+	if err := s.audit.Record(context.WithoutCancel(ctx), event); err != nil {
+		s.logger.Error("Failed to record key audit event",
+			zap.String("action", event.Action),
+			zap.String("key", event.Key.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to record key audit event: %w", err)
+	}
+	return nil
+}
+
+func (s *KeyService) recordOutcome(outcome string) {
+	if s.metrics != nil {
+		s.metrics.RecordKeyDelivery(outcome)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"E.E/internal/core/domain"
+)
+
+// keyTokenHeader is the JOSE header of every key token: they are JWTs signed
+// with HMAC-SHA256, so packagers can inspect them with standard tooling
+var keyTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// keyTokenClaims is the JWT payload of a key token
+type keyTokenClaims struct {
+	Subject   string `json:"sub"` // Client
+	JobID     string `json:"jid"`
+	Output    string `json:"out,omitempty"`
+	IssuedBy  string `json:"iby,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	TokenID   string `json:"jti"`
+}
+
+// signKeyToken encodes and signs claims as a JWT
+func signKeyToken(claims domain.KeyClaims, secret []byte) (string, error) {
+	payload, err := json.Marshal(keyTokenClaims{
+		Subject:   claims.Client,
+		JobID:     claims.Key.JobID,
+		Output:    claims.Key.Output,
+		IssuedBy:  claims.IssuedBy,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+		TokenID:   claims.TokenID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key token: %w", err)
+	}
+
+	signed := keyTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + keyTokenSignature(signed, secret), nil
+}
+
+// verifyKeyToken checks a token's signature and expiry at now and returns its
+// claims. Errors wrap domain.ErrKeyTokenInvalid.
+func verifyKeyToken(token string, secret []byte, now time.Time) (domain.KeyClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != keyTokenHeader {
+		return domain.KeyClaims{}, fmt.Errorf("%w: malformed token", domain.ErrKeyTokenInvalid)
+	}
+	expected := keyTokenSignature(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return domain.KeyClaims{}, fmt.Errorf("%w: bad signature", domain.ErrKeyTokenInvalid)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return domain.KeyClaims{}, fmt.Errorf("%w: malformed payload", domain.ErrKeyTokenInvalid)
+	}
+	var claims keyTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.JobID == "" || claims.Subject == "" {
+		return domain.KeyClaims{}, fmt.Errorf("%w: malformed payload", domain.ErrKeyTokenInvalid)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return domain.KeyClaims{}, fmt.Errorf("%w: token expired", domain.ErrKeyTokenInvalid)
+	}
+
+	return domain.KeyClaims{
+		Key:       domain.ContentKey{JobID: claims.JobID, Output: claims.Output},
+		Client:    claims.Subject,
+		IssuedBy:  claims.IssuedBy,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+		TokenID:   claims.TokenID,
+	}, nil
+}
+
+func keyTokenSignature(signed string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// sourcePreflight validates sources before jobs are created for them
+type sourcePreflight struct {
+	inspector ports.SourceInspector // Nil to check only the URL
+	policy    domain.SourcePolicy
+	timeout   time.Duration
+}
+
+// SetSourcePreflight makes StartEncryption validate each source URL against
+// policy before creating its job, so unusable sources are rejected with the
+// request instead of failing in the worker. With an inspector, sources are
+// also checked to exist and their size and content type checked, waiting at
+// most timeout.
+func (s *EncryptionService) SetSourcePreflight(inspector ports.SourceInspector, policy domain.SourcePolicy, timeout time.Duration) {
+	s.preflight = &sourcePreflight{inspector: inspector, policy: policy, timeout: timeout}
+}
+
+// checkSource runs pre-flight validation of sourceURL, returning an error
+// wrapping domain.ErrSourceRejected if it fails
+func (s *EncryptionService) checkSource(ctx context.Context, sourceURL string) error {
+	if s.preflight == nil {
+		return nil
+	}
+	if err := s.preflight.policy.CheckURL(sourceURL); err != nil {
+		return err
+	}
+	if s.preflight.inspector == nil {
+		return nil
+	}
+
+	if s.preflight.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.preflight.timeout)
+		defer cancel()
+	}
+	info, err := s.preflight.inspector.Inspect(ctx, sourceURL)
+	if err != nil {
+		s.logger.Debug("Source failed pre-flight check",
+			zap.String("source_url", sourceURL),
+			zap.Error(err))
+		return fmt.Errorf("%w: %v", domain.ErrSourceRejected, err)
+	}
+	return s.preflight.policy.Check(info)
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// recurringLeaderLock names the leader lock of the processes running
+// recurring batches
+const recurringLeaderLock = "recurring"
+
+// RecurringService manages the recurring batches tenants register and starts
+// each one's batch whenever its cron expression matches. Every process may
+// run it, but only the one holding the leader lock starts batches, so each
+// run starts one batch.
+type RecurringService struct {
+	repository ports.RecurringRepository
+	jobs       ports.JobRepository
+	batches    ports.EncryptionService
+	interval   time.Duration
+	locks      ports.LeaderLocks
+	clock      ports.Clock
+	logger     *zap.Logger
+
+	registration sync.Mutex // Serializes the per-tenant limit check with the save
+
+	owner   string // Identifies this process in the leader lock
+	leading bool
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewRecurringService(repository ports.RecurringRepository, jobs ports.JobRepository, batches ports.EncryptionService, interval time.Duration, logger *zap.Logger) *RecurringService {
+	return &RecurringService{
+		repository: repository,
+		jobs:       jobs,
+		batches:    batches,
+		interval:   interval,
+		clock:      clock.System{},
+		logger:     logger,
+		owner:      uuid.New().String(),
+	}
+}
+
+// SetLeaderLocks makes the service start batches only while it leads the
+// processes sharing locks. Without it every process running the service
+// starts them.
+func (s *RecurringService) SetLeaderLocks(locks ports.LeaderLocks) {
+	s.locks = locks
+}
+
+// SetClock replaces the system clock used to judge schedules and for
+// timestamps
+func (s *RecurringService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+func (s *RecurringService) CreateRecurring(ctx context.Context, req domain.RecurringRequest) (*domain.RecurringBatch, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	tenant := req.Tenant
+	if tenant == "" {
+		tenant = principal.TenantID()
+	}
+	if err := principal.AuthorizeTenant(tenant); err != nil {
+		return nil, fmt.Errorf("%w: %q may only register recurring batches for its tenant", domain.ErrForbidden, principal.ID)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.registration.Lock()
+	defer s.registration.Unlock()
+
+	registered, err := s.repository.ListRecurring(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if len(registered) >= domain.MaxRecurringPerTenant {
+		return nil, fmt.Errorf("%w: tenant %q already has %d recurring batches", domain.ErrInvalidRecurring, tenant, domain.MaxRecurringPerTenant)
+	}
+
+	now := s.clock.Now()
+	recurring := &domain.RecurringBatch{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Tenant:    tenant,
+		Cron:      req.Cron,
+		Timezone:  req.Timezone,
+		Template:  req.Template,
+		Paused:    req.Paused,
+		CreatedBy: principal.ID,
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
+	}
+	if err := recurring.Schedule(now); err != nil {
+		return nil, err
+	}
+	if err := s.repository.SaveRecurring(ctx, recurring); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Recurring batch created",
+		zap.String("recurring_id", recurring.ID),
+		zap.String("tenant", recurring.Tenant),
+		zap.String("cron", recurring.Cron),
+		zap.String("created_by", principal.ID))
+	return recurring, nil
+}
+
+func (s *RecurringService) ListRecurring(ctx context.Context, tenant string) ([]*domain.RecurringBatch, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if !principal.Admin {
+		tenant = principal.TenantID()
+	}
+	batches, err := s.repository.ListRecurring(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt > batches[j].CreatedAt })
+	return batches, nil
+}
+
+func (s *RecurringService) GetRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error) {
+	return s.registeredRecurring(ctx, id)
+}
+
+func (s *RecurringService) UpdateRecurring(ctx context.Context, id string, req domain.RecurringRequest) (*domain.RecurringBatch, error) {
+	recurring, err := s.registeredRecurring(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Tenant != "" && req.Tenant != recurring.Tenant {
+		return nil, fmt.Errorf("%w: the tenant of a recurring batch cannot be changed", domain.ErrInvalidRecurring)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	recurring.Name = req.Name
+	recurring.Cron = req.Cron
+	recurring.Timezone = req.Timezone
+	recurring.Template = req.Template
+	recurring.Paused = req.Paused
+	recurring.UpdatedAt = now.Unix()
+	if err := recurring.Schedule(now); err != nil {
+		return nil, err
+	}
+	if err := s.repository.SaveRecurring(ctx, recurring); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Recurring batch updated",
+		zap.String("recurring_id", recurring.ID),
+		zap.String("tenant", recurring.Tenant),
+		zap.String("cron", recurring.Cron),
+		zap.String("updated_by", domain.PrincipalFromContext(ctx).ID))
+	return recurring, nil
+}
+
+func (s *RecurringService) DeleteRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error) {
+	recurring, err := s.registeredRecurring(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.DeleteRecurring(ctx, id); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Recurring batch deleted",
+		zap.String("recurring_id", recurring.ID),
+		zap.String("tenant", recurring.Tenant),
+		zap.String("deleted_by", domain.PrincipalFromContext(ctx).ID))
+	return recurring, nil
+}
+
+// registeredRecurring returns a recurring batch the caller may see. Those of
+// other tenants are reported as not found, like their jobs.
+func (s *RecurringService) registeredRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error) {
+	recurring, err := s.repository.GetRecurring(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if recurring == nil || domain.PrincipalFromContext(ctx).AuthorizeTenant(recurring.Tenant) != nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRecurringNotFound, id)
+	}
+	return recurring, nil
+}
+
+// Start checks for due recurring batches every interval in the background
+func (s *RecurringService) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if !s.lead(ctx) {
+					continue
+				}
+				if _, err := s.RunDue(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Failed to run recurring batches", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Started recurring batches", zap.Duration("interval", s.interval))
+}
+
+// Stop ends background runs and hands the lead over
+func (s *RecurringService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+
+	if s.locks != nil && s.leading {
+		if err := s.locks.Release(context.Background(), recurringLeaderLock, s.owner); err != nil {
+			s.logger.Warn("Failed to release recurring batch leadership", zap.Error(err))
+		}
+		s.leading = false
+	}
+}
+
+// lead reports whether this process leads the ones running recurring
+// batches, renewing or taking the lead. The lead lasts three intervals, so a
+// leader that dies is replaced within as long.
+func (s *RecurringService) lead(ctx context.Context) bool {
+	if s.locks == nil {
+		return true
+	}
+	ttl := 3 * s.interval
+
+	if s.leading {
+		held, err := s.locks.Renew(ctx, recurringLeaderLock, s.owner, ttl)
+		if err != nil {
+			// The lead may still be renewed in time
+			s.logger.Warn("Failed to renew recurring batch leadership", zap.Error(err))
+			return false
+		}
+		if held {
+			return true
+		}
+		s.leading = false
+		s.logger.Warn("Lost recurring batch leadership")
+	}
+
+	acquired, err := s.locks.Acquire(ctx, recurringLeaderLock, s.owner, ttl)
+	if err != nil {
+		s.logger.Warn("Failed to acquire recurring batch leadership", zap.Error(err))
+		return false
+	}
+	if acquired {
+		s.leading = true
+		s.logger.Info("Leading recurring batches")
+	}
+	return acquired
+}
+
+// RunDue starts the batch of every recurring batch that is due, returning how
+// many were run
+func (s *RecurringService) RunDue(ctx context.Context) (int, error) {
+	batches, err := s.repository.ListRecurring(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	ran := 0
+	for _, recurring := range batches {
+		if !recurring.IsDue(now) {
+			continue
+		}
+		s.run(ctx, recurring, now)
+		ran++
+	}
+	return ran, nil
+}
+
+// run starts a recurring batch's batch as the principal that registered it,
+// then records the run and schedules the next one. Runs missed while no
+// process was leading are skipped.
+func (s *RecurringService) run(ctx context.Context, recurring *domain.RecurringBatch, now time.Time) {
+	batchID, runErr := s.startBatch(ctx, recurring)
+	if runErr != nil {
+		s.logger.Error("Recurring batch started no batch",
+			zap.String("recurring_id", recurring.ID),
+			zap.Error(runErr))
+	} else {
+		s.logger.Info("Recurring batch started batch",
+			zap.String("recurring_id", recurring.ID),
+			zap.String("batch_id", batchID))
+	}
+
+	// The recurring batch may have been changed or deleted meanwhile
+	current, err := s.repository.GetRecurring(ctx, recurring.ID)
+	if err != nil || current == nil {
+		return
+	}
+	current.LastRunAt = now.Unix()
+	current.LastBatchID = batchID
+	current.LastError = ""
+	if runErr != nil {
+		current.LastError = runErr.Error()
+	}
+	if err := current.Schedule(now); err != nil {
+		current.Paused = true
+		current.LastError = err.Error()
+	}
+	if err := s.repository.SaveRecurring(ctx, current); err != nil {
+		s.logger.Error("Failed to record recurring batch run",
+			zap.String("recurring_id", recurring.ID),
+			zap.Error(err))
+	}
+}
+
+// startBatch starts the batch of a run and returns its ID
+func (s *RecurringService) startBatch(ctx context.Context, recurring *domain.RecurringBatch) (string, error) {
+	sourceURLs := append([]string(nil), recurring.Template.SourceURLs...)
+	if recurring.Template.Filter != nil {
+		jobs, err := s.jobs.Query(ctx, domain.JobQuery{Filter: recurring.Template.Filter.JobFilter(recurring.Tenant)})
+		if err != nil {
+			return "", fmt.Errorf("failed to list matching jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if !job.IsDecryption() && !job.IsRotation() {
+				sourceURLs = append(sourceURLs, job.SourceURL)
+			}
+		}
+	}
+	if len(sourceURLs) == 0 && recurring.Template.Source == nil {
+		return "", errors.New("no jobs matched the filter")
+	}
+
+	runCtx := ctx
+	if recurring.CreatedBy != "" {
+		runCtx = domain.ContextWithPrincipal(ctx, domain.Principal{ID: recurring.CreatedBy, Tenant: recurring.Tenant})
+	}
+	result, err := s.batches.ProcessBatch(runCtx, recurring.BatchOperation(sourceURLs))
+	if err != nil {
+		return "", err
+	}
+	return result.BatchID, nil
+}
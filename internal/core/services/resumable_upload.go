@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// ResumableUploadConfig holds the settings of resumable uploads
+type ResumableUploadConfig struct {
+	Prefix        string        // Storage prefix of uploads; chunks are kept under its tus directory
+	MaxSize       int64         // Largest upload accepted, in bytes
+	Expiry        time.Duration // How long an incomplete upload is kept after its last chunk
+	SweepInterval time.Duration // How often expired uploads are deleted
+	LockTTL       time.Duration // How long one chunk may take to arrive
+}
+
+// ResumableUploadService receives sources uploaded in chunks with the tus
+// protocol. Each chunk is stored as it arrives, so an interrupted upload
+// resumes where it stopped. The chunk completing an upload assembles the
+// chunks into one upload and starts its job, which deletes it once it ends.
+type ResumableUploadService struct {
+	uploads ports.UploadStore
+	storage ports.FileStorage
+	jobs    *EncryptionService
+	locks   ports.JobLocks
+	config  ResumableUploadConfig
+	clock   ports.Clock
+	logger  *zap.Logger
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewResumableUploadService stores chunks in storage, which must be the
+// upload storage of jobs. Locks keep two requests from writing to the same
+// upload at once, also across processes.
+func NewResumableUploadService(uploads ports.UploadStore, storage ports.FileStorage, jobs *EncryptionService, locks ports.JobLocks, config ResumableUploadConfig, logger *zap.Logger) *ResumableUploadService {
+	return &ResumableUploadService{
+		uploads: uploads,
+		storage: storage,
+		jobs:    jobs,
+		locks:   locks,
+		config:  config,
+		clock:   clock.System{},
+		logger:  logger,
+	}
+}
+
+// SetClock replaces the system clock used to expire uploads
+func (s *ResumableUploadService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+func (s *ResumableUploadService) CreateUpload(ctx context.Context, length int64, filename string, req domain.EncryptionRequest) (*domain.ResumableUpload, error) {
+	if s.jobs.draining.Load() {
+		return nil, domain.ErrNotAcceptingJobs
+	}
+	if length > s.config.MaxSize {
+		return nil, fmt.Errorf("%w: uploads must not exceed %d bytes", domain.ErrUploadTooLarge, s.config.MaxSize)
+	}
+	if err := req.ValidateUpload(); err != nil {
+		return nil, err
+	}
+
+	principal := domain.PrincipalFromContext(ctx)
+	now := s.clock.Now()
+	upload := &domain.ResumableUpload{
+		ID:        uuid.New().String(),
+		Length:    length,
+		Filename:  filename,
+		Request:   req,
+		CreatedBy: principal.ID,
+		Tenant:    principal.TenantID(),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(s.config.Expiry).Unix(),
+	}
+	if err := s.uploads.SaveUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Resumable upload created",
+		zap.String("upload_id", upload.ID),
+		zap.Int64("length", upload.Length),
+		zap.String("created_by", principal.ID))
+	return upload, nil
+}
+
+// GetUpload returns an upload the caller may see. Those of other tenants
+// are reported as not found, like their jobs.
+func (s *ResumableUploadService) GetUpload(ctx context.Context, id string) (*domain.ResumableUpload, error) {
+	upload, err := s.uploads.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if upload == nil || upload.ExpiresAt <= s.clock.Now().Unix() ||
+		domain.PrincipalFromContext(ctx).AuthorizeTenant(upload.Tenant) != nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUploadNotFound, id)
+	}
+	return upload, nil
+}
+
+func (s *ResumableUploadService) WriteChunk(ctx context.Context, id string, offset int64, chunk io.Reader) (*domain.ResumableUpload, error) {
+	if _, err := s.GetUpload(ctx, id); err != nil {
+		return nil, err
+	}
+	release, err := s.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Another request may have written a chunk before the lock was taken
+	upload, err := s.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return upload, fmt.Errorf("%w: upload %s has %d bytes, not %d", domain.ErrUploadOffset, id, upload.Offset, offset)
+	}
+
+	if !upload.Complete() {
+		if err := s.storeChunk(upload, chunk); err != nil {
+			return upload, err
+		}
+		upload.ExpiresAt = s.clock.Now().Add(s.config.Expiry).Unix()
+		if err := s.uploads.SaveUpload(ctx, upload); err != nil {
+			return upload, err
+		}
+	}
+
+	// An upload whose job failed to start is started again by an empty
+	// chunk at its end
+	if upload.Complete() && upload.JobID == "" {
+		if err := s.startJob(ctx, upload); err != nil {
+			return upload, err
+		}
+	}
+	return upload, nil
+}
+
+// storeChunk stores the bytes of a chunk after those received so far. A chunk
+// cut short, as by a dropped connection, keeps the bytes that arrived.
+func (s *ResumableUploadService) storeChunk(upload *domain.ResumableUpload, chunk io.Reader) error {
+	chunkPath := s.chunkPath(upload.ID, upload.Offset)
+	counted := &chunkReader{r: chunk, remaining: upload.Length - upload.Offset}
+	err := s.storage.WriteFile(chunkPath, counted)
+	if err != nil || counted.read == 0 {
+		s.deleteChunk(chunkPath)
+	}
+	if counted.exceeded {
+		return fmt.Errorf("%w: upload %s is %d bytes long", domain.ErrUploadTooLarge, upload.ID, upload.Length)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	if counted.read > 0 {
+		upload.Chunks = append(upload.Chunks, upload.Offset)
+		upload.Offset += counted.read
+	}
+	return nil
+}
+
+// startJob assembles the chunks of a complete upload and starts its job. The
+// chunks are kept until the job has started, so a failed start can be
+// retried.
+func (s *ResumableUploadService) startJob(ctx context.Context, upload *domain.ResumableUpload) error {
+	uploadPath := path.Join(s.config.Prefix, upload.ID, domain.UploadName(upload.Filename))
+	chunks := s.assemble(upload)
+	job, err := s.jobs.startUploadAt(ctx, uploadPath, chunks, upload.Request.JobOptions())
+	chunks.Close()
+	if err != nil {
+		return err
+	}
+
+	s.deleteChunks(upload)
+	upload.Chunks = nil
+	upload.JobID = job.ID
+	if err := s.uploads.SaveUpload(ctx, upload); err != nil {
+		// The job runs regardless; only HEAD requests miss its ID
+		s.logger.Warn("Failed to record job of resumable upload",
+			zap.String("upload_id", upload.ID),
+			zap.String("job_id", job.ID),
+			zap.Error(err))
+	}
+
+	s.logger.Info("Resumable upload completed",
+		zap.String("upload_id", upload.ID),
+		zap.String("job_id", job.ID),
+		zap.Int64("length", upload.Length))
+	return nil
+}
+
+func (s *ResumableUploadService) DeleteUpload(ctx context.Context, id string) error {
+	if _, err := s.GetUpload(ctx, id); err != nil {
+		return err
+	}
+	release, err := s.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	upload, err := s.GetUpload(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.delete(ctx, upload); err != nil {
+		return err
+	}
+
+	s.logger.Info("Resumable upload deleted",
+		zap.String("upload_id", id),
+		zap.String("deleted_by", domain.PrincipalFromContext(ctx).ID))
+	return nil
+}
+
+// Start deletes expired uploads every sweep interval in the background
+func (s *ResumableUploadService) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.SweepExpired(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Failed to delete expired uploads", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Started resumable upload sweeper", zap.Duration("interval", s.config.SweepInterval))
+}
+
+// Stop ends the background sweeps
+func (s *ResumableUploadService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+}
+
+// SweepExpired deletes the uploads that expired, with the chunks they kept,
+// returning how many were deleted. Uploads receiving a chunk are left for the
+// next sweep.
+func (s *ResumableUploadService) SweepExpired(ctx context.Context) (int, error) {
+	expired, err := s.uploads.ExpiredUploads(ctx, s.clock.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, upload := range expired {
+		release, err := s.lock(ctx, upload.ID)
+		if err != nil {
+			continue
+		}
+		err = s.delete(ctx, upload)
+		release()
+		if err != nil {
+			s.logger.Warn("Failed to delete expired upload",
+				zap.String("upload_id", upload.ID),
+				zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		s.logger.Info("Deleted expired uploads", zap.Int("count", deleted))
+	}
+	return deleted, nil
+}
+
+// delete removes an upload and its chunks. The upload a started job reads is
+// left to the job.
+func (s *ResumableUploadService) delete(ctx context.Context, upload *domain.ResumableUpload) error {
+	s.deleteChunks(upload)
+	return s.uploads.DeleteUpload(ctx, upload.ID)
+}
+
+// lock takes the lock of an upload, returning the function releasing it
+func (s *ResumableUploadService) lock(ctx context.Context, id string) (func(), error) {
+	name := "upload:" + id
+	owner := uuid.New().String()
+	acquired, err := s.locks.Acquire(ctx, name, owner, s.config.LockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock upload: %w", err)
+	}
+	if !acquired {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUploadLocked, id)
+	}
+	return func() {
+		if err := s.locks.Release(context.Background(), name, owner); err != nil {
+			s.logger.Warn("Failed to unlock upload",
+				zap.String("upload_id", id),
+				zap.Error(err))
+		}
+	}, nil
+}
+
+// chunkPath returns the storage path of the chunk starting at offset
+func (s *ResumableUploadService) chunkPath(id string, offset int64) string {
+	return path.Join(s.config.Prefix, "tus", id, fmt.Sprintf("%020d", offset))
+}
+
+func (s *ResumableUploadService) deleteChunks(upload *domain.ResumableUpload) {
+	for _, offset := range upload.Chunks {
+		s.deleteChunk(s.chunkPath(upload.ID, offset))
+	}
+}
+
+func (s *ResumableUploadService) deleteChunk(chunkPath string) {
+	if err := s.storage.DeleteFile(chunkPath); err != nil && s.storage.FileExists(chunkPath) {
+		s.logger.Warn("Failed to delete upload chunk",
+			zap.String("chunk", chunkPath),
+			zap.Error(err))
+	}
+}
+
+// assemble returns a reader of the chunks of an upload in order, opening each
+// only once the previous one has been read
+func (s *ResumableUploadService) assemble(upload *domain.ResumableUpload) *chunkAssembler {
+	paths := make([]string, len(upload.Chunks))
+	for i, offset := range upload.Chunks {
+		paths[i] = s.chunkPath(upload.ID, offset)
+	}
+	return &chunkAssembler{storage: s.storage, paths: paths}
+}
+
+// chunkReader reads one chunk of an upload, counting its bytes. It fails
+// reads past the bytes the upload still lacks, and ends the chunk at the
+// first read error so the bytes before it are kept.
+type chunkReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if c.exceeded {
+		return 0, domain.ErrUploadTooLarge
+	}
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	if int64(n) > c.remaining {
+		c.exceeded = true
+		return 0, domain.ErrUploadTooLarge
+	}
+	c.remaining -= int64(n)
+	c.read += int64(n)
+	if err != nil {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunkAssembler reads stored chunks one after another
+type chunkAssembler struct {
+	storage ports.FileStorage
+	paths   []string
+	current io.ReadCloser
+}
+
+func (a *chunkAssembler) Read(p []byte) (int, error) {
+	for {
+		if a.current == nil {
+			if len(a.paths) == 0 {
+				return 0, io.EOF
+			}
+			chunk, err := a.storage.ReadFile(a.paths[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to read upload chunk: %w", err)
+			}
+			a.current = chunk
+			a.paths = a.paths[1:]
+		}
+
+		n, err := a.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			a.current.Close()
+			a.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the chunk being read, if any
+func (a *chunkAssembler) Close() {
+	if a.current != nil {
+		a.current.Close()
+		a.current = nil
+	}
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// ShareServiceConfig configures a ShareService
+type ShareServiceConfig struct {
+	Secret     []byte        // Signs link tokens
+	BaseURL    string        // Public address of the API that links point to
+	DefaultTTL time.Duration // Lifetime of links requested without one
+	MaxTTL     time.Duration // Longest lifetime a link may be minted with
+	PresignTTL time.Duration // Lifetime of the presigned URLs links redirect to
+}
+
+// ShareService mints signed, expiring links to the outputs and key manifests
+// of completed jobs. Links are checked against the store on every use, so
+// they can be revoked before they expire; storage that can presign URLs is
+// redirected to, anything else is served through the API.
+type ShareService struct {
+	jobs    ports.JobRepository
+	links   ports.ShareLinkStore
+	storage ports.FileStorage
+	config  ShareServiceConfig
+	clock   ports.Clock
+	keys    ports.KeyStore
+	logger  *zap.Logger
+
+	destinations ports.OutputDestinations
+}
+
+func NewShareService(
+	jobs ports.JobRepository,
+	links ports.ShareLinkStore,
+	storage ports.FileStorage,
+	config ShareServiceConfig,
+	logger *zap.Logger,
+) *ShareService {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = 7 * 24 * time.Hour
+	}
+	if config.DefaultTTL <= 0 || config.DefaultTTL > config.MaxTTL {
+		config.DefaultTTL = config.MaxTTL
+	}
+	if config.PresignTTL <= 0 {
+		config.PresignTTL = 5 * time.Minute
+	}
+
+	return &ShareService{
+		jobs:    jobs,
+		links:   links,
+		storage: storage,
+		config:  config,
+		clock:   clock.System{},
+		logger:  logger,
+	}
+}
+
+// SetClock replaces the system clock used for link lifetimes
+func (s *ShareService) SetClock(c ports.Clock) {
+	s.clock = c
+}
+
+// SetKeyStore opens the keys of jobs whose keys are sealed with store, for
+// key manifests
+func (s *ShareService) SetKeyStore(store ports.KeyStore) {
+	s.keys = store
+}
+
+// SetDestinations opens the destinations jobs name for their outputs, so
+// links to those outputs can be served
+func (s *ShareService) SetDestinations(destinations ports.OutputDestinations) {
+	s.destinations = destinations
+}
+
+func (s *ShareService) CreateShareLink(ctx context.Context, jobID string, req domain.ShareLinkRequest) (*domain.ShareLink, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	// A manifest hands out the job's key, which takes the keys scope
+	if req.Target == domain.ShareManifest {
+		if err := requireKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	job, err := s.ownedJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	// Only results that exist can be shared
+	if _, _, err := sharedResult(job, req.Output); err != nil {
+		return nil, err
+	}
+
+	ttl := s.config.DefaultTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > s.config.MaxTTL {
+		ttl = s.config.MaxTTL
+	}
+	now := s.clock.Now()
+	expiresAt := now.Add(ttl).Unix()
+	// The output is deleted with the job, so a link cannot outlive it
+	if job.ExpiresAt != 0 && job.ExpiresAt < expiresAt {
+		expiresAt = job.ExpiresAt
+	}
+	if expiresAt <= now.Unix() {
+		return nil, fmt.Errorf("%w: job %s expires before the link could be used", domain.ErrInvalidShareLink, jobID)
+	}
+
+	link := &domain.ShareLink{
+		ID:        uuid.New().String(),
+		JobID:     jobID,
+		Output:    req.Output,
+		Target:    req.Target,
+		Label:     req.Label,
+		CreatedBy: domain.PrincipalFromContext(ctx).ID,
+		CreatedAt: now.Unix(),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.links.SaveLink(ctx, link); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Share link created",
+		zap.String("link_id", link.ID),
+		zap.String("job_id", jobID),
+		zap.String("target", link.Target),
+		zap.String("label", link.Label),
+		zap.Int64("expires_at", link.ExpiresAt))
+
+	link.URL = s.linkURL(link)
+	return link, nil
+}
+
+func (s *ShareService) ListShareLinks(ctx context.Context, jobID string) ([]*domain.ShareLink, error) {
+	if _, err := s.ownedJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	links, err := s.links.ListLinks(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		if link.RevokedAt == 0 {
+			link.URL = s.linkURL(link)
+		}
+	}
+	return links, nil
+}
+
+func (s *ShareService) RevokeShareLink(ctx context.Context, jobID, linkID string) (*domain.ShareLink, error) {
+	if _, err := s.ownedJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	link, err := s.links.GetLink(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now().Unix()
+	if link == nil || link.JobID != jobID || now >= link.ExpiresAt {
+		return nil, domain.ErrShareLinkNotFound
+	}
+	if link.RevokedAt != 0 {
+		return link, nil
+	}
+
+	link.RevokedAt = now
+	if err := s.links.SaveLink(ctx, link); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Share link revoked",
+		zap.String("link_id", link.ID),
+		zap.String("job_id", jobID),
+		zap.String("principal", domain.PrincipalFromContext(ctx).ID))
+	return link, nil
+}
+
+func (s *ShareService) OpenShareLink(ctx context.Context, token string) (*domain.SharedContent, error) {
+	now := s.clock.Now()
+	linkID, err := s.verifyToken(token, now)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.links.GetLink(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || !link.Active(now.Unix()) {
+		return nil, domain.ErrShareLinkExpired
+	}
+	job, err := s.jobs.Get(ctx, link.JobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return nil, domain.ErrShareLinkExpired
+	}
+	result, stored, err := sharedResult(job, link.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Share link opened",
+		zap.String("link_id", link.ID),
+		zap.String("job_id", link.JobID),
+		zap.String("target", link.Target),
+		zap.String("label", link.Label))
+
+	content := &domain.SharedContent{Link: link}
+	if link.Target == domain.ShareManifest {
+		key, err := openKey(ctx, s.keys, domain.ContentKey{JobID: job.ID, Output: link.Output}, stored)
+		if err != nil {
+			return nil, err
+		}
+		content.Manifest = &domain.KeyManifest{
+			JobID:      job.ID,
+			Output:     link.Output,
+			OutputPath: result.OutputPath,
+			Size:       result.Size,
+			Checksum:   result.Checksum,
+			Algorithm:  result.Algorithm,
+			ChunkSize:  result.ChunkSize,
+			IVStrategy: result.IVStrategy,
+			Key:        key,
+			KeyRef:     result.KeyRef,
+		}
+		return content, nil
+	}
+
+	storage, err := jobStorage(s.storage, s.destinations, job)
+	if err != nil {
+		return nil, err
+	}
+	if presigner, ok := storage.(ports.URLPresigner); ok {
+		ttl := time.Unix(link.ExpiresAt, 0).Sub(now)
+		if ttl > s.config.PresignTTL {
+			ttl = s.config.PresignTTL
+		}
+		url, err := presigner.PresignURL(ctx, result.OutputPath, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign output of job %s: %w", job.ID, err)
+		}
+		content.RedirectURL = url
+		return content, nil
+	}
+
+	body, err := storage.ReadFile(result.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output of job %s: %w", job.ID, err)
+	}
+	content.Body = body
+	content.Name = path.Base(result.OutputPath)
+	content.Size = result.Size
+	return content, nil
+}
+
+// ownedJob returns a job the caller may act on
+func (s *ShareService) ownedJob(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
+	job, err := s.jobs.Get(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return nil, domain.ErrJobNotFound
+	}
+	if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// sharedResult returns the result and stored key of a job's output, or of the
+// job itself, once it has completed
+func sharedResult(job *domain.EncryptionJob, output string) (*domain.JobResult, domain.StoredKey, error) {
+	if output != "" {
+		out, err := job.Output(output)
+		if err != nil {
+			return nil, domain.StoredKey{}, err
+		}
+		if out.Status != domain.StatusCompleted || out.Result == nil {
+			return nil, domain.StoredKey{}, domain.NewJobStateError(job.ID, out.Status, "share", fmt.Sprintf("output %s has not completed", output))
+		}
+		return out.Result, out.StoredKey(), nil
+	}
+	if job.Status != domain.StatusCompleted || job.Result == nil {
+		return nil, domain.StoredKey{}, domain.NewJobStateError(job.ID, job.Status, "share", "job has not completed")
+	}
+	return job.Result, job.StoredKey(), nil
+}
+
+// linkURL returns the URL a link is opened at. Its token is the link ID and
+// expiry, signed so forged and expired tokens are turned away before the
+// store is read.
+func (s *ShareService) linkURL(link *domain.ShareLink) string {
+	payload := link.ID + "." + strconv.FormatInt(link.ExpiresAt, 10)
+	return s.config.BaseURL + "/share/v1/" + payload + "." + s.tokenSignature(payload)
+}
+
+// verifyToken checks a link token's signature and expiry at now and returns
+// the link ID
+func (s *ShareService) verifyToken(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", domain.ErrShareLinkExpired
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.tokenSignature(payload))) {
+		return "", domain.ErrShareLinkExpired
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return "", domain.ErrShareLinkExpired
+	}
+	return parts[0], nil
+}
+
+func (s *ShareService) tokenSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/secondary/repository"
+)
+
+// testShareLinks keeps share links in memory
+type testShareLinks map[string]*domain.ShareLink
+
+func (l testShareLinks) SaveLink(ctx context.Context, link *domain.ShareLink) error {
+	l[link.ID] = link
+	return nil
+}
+
+func (l testShareLinks) GetLink(ctx context.Context, linkID string) (*domain.ShareLink, error) {
+	return l[linkID], nil
+}
+
+func (l testShareLinks) ListLinks(ctx context.Context, jobID string) ([]*domain.ShareLink, error) {
+	var links []*domain.ShareLink
+	for _, link := range l {
+		if link.JobID == jobID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func TestCreateShareLinkManifestRequiresKeysScope(t *testing.T) {
+	jobs := repository.NewMemoryRepository()
+	if err := jobs.Create(context.Background(), &domain.EncryptionJob{
+		ID:            "job-1",
+		Status:        domain.StatusCompleted,
+		CreatedBy:     "studio",
+		Result:        &domain.JobResult{},
+		DecryptionKey: strings.Repeat("ab", 32),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewShareService(jobs, testShareLinks{}, nil, ShareServiceConfig{Secret: []byte("test-secret")}, zap.NewNop())
+
+	writer := domain.ContextWithPrincipal(context.Background(),
+		domain.NewPrincipal("studio", "", []string{domain.ScopeJobsRead, domain.ScopeJobsWrite}))
+	keys := domain.ContextWithPrincipal(context.Background(),
+		domain.NewPrincipal("studio", "", []string{domain.ScopeJobsRead, domain.ScopeJobsWrite, domain.ScopeKeys}))
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		target string
+		denied bool
+	}{
+		{"output without keys", writer, domain.ShareOutput, false},
+		{"manifest without keys", writer, domain.ShareManifest, true},
+		{"manifest with keys", keys, domain.ShareManifest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateShareLink(tt.ctx, "job-1", domain.ShareLinkRequest{Target: tt.target})
+			if denied := errors.Is(err, domain.ErrForbidden); denied != tt.denied {
+				t.Fatalf("CreateShareLink(%s) = %v, want forbidden %v", tt.target, err, tt.denied)
+			}
+			if !tt.denied && err != nil {
+				t.Fatalf("CreateShareLink(%s) = %v", tt.target, err)
+			}
+		})
+	}
+}
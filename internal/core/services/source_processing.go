@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// sourceProcessors returns the processors that apply to the job, in the
+// order they run
+func (p *WorkerPool) sourceProcessors(job *domain.EncryptionJob) []ports.SourceProcessor {
+	var processors []ports.SourceProcessor
+	for _, processor := range p.processors {
+		if processor.Applies(job) {
+			processors = append(processors, processor)
+		}
+	}
+	return processors
+}
+
+// processSource downloads the job's source into its workspace and runs the
+// processors on it in turn, each reading the file the one before wrote. It
+// returns the path of the processed source, and the reader the source was
+// hashed through as it was downloaded, so the job records the hash of the
+// source it was given rather than of the processed one.
+func (p *WorkerPool) processSource(ctx context.Context, job *domain.EncryptionJob, processors []ports.SourceProcessor, update func(domain.Progress)) (string, *hashingReader, error) {
+	if p.workspaces == nil {
+		return "", nil, errors.New("processing the source needs job workspaces")
+	}
+	workspace, err := p.workspaces.Acquire(job.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	update(domain.Progress{Stage: domain.StageFetching})
+	src, _, err := p.fetcher.Open(ctx, job.SourceURL)
+	if err != nil {
+		return "", nil, err
+	}
+	hashed := newHashingReader(src)
+	input := filepath.Join(workspace, "source")
+	err = copyToFile(input, hashed)
+	hashed.Close()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for i, processor := range processors {
+		start := p.clock.Now()
+		output := filepath.Join(workspace, fmt.Sprintf("source-%d", i+1))
+		update(domain.Progress{Stage: processor.Stage()})
+		if err := processor.Process(ctx, job, input, output, p.stageReporter(job, processor.Stage(), start, update)); err != nil {
+			return "", nil, err
+		}
+		// Only the latest file is read again
+		os.Remove(input)
+		input = output
+
+		job.RecordStage(processor.Stage(), map[string]interface{}{
+			"duration": p.clock.Now().Sub(start).String(),
+		}, p.clock.Now())
+	}
+	return input, hashed, nil
+}
+
+// copyToFile writes everything src holds to a new file at path
+func copyToFile(path string, src io.Reader) error {
+	dst, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create source file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to download source: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write source file: %w", err)
+	}
+	return nil
+}
+
+// openFile opens a processed source for encryption, returning its size
+func openFile(path string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open processed source: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to open processed source: %w", err)
+	}
+	return file, info.Size(), nil
+}
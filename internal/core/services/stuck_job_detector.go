@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+	"E.E/pkg/metrics"
+)
+
+// StuckJobConfig configures the detection of running jobs that stopped
+// moving
+type StuckJobConfig struct {
+	StuckAfter    time.Duration // Silence after which a running job counts as stuck
+	SweepInterval time.Duration // Time between sweeps
+	Action        string        // domain.RecoveryRequeue, RecoveryFail or RecoveryNone
+}
+
+// StuckJobDetector periodically sweeps the running jobs for those whose worker
+// stopped beating or that reported no progress for a while. Each stuck job is
+// reported once, with a metric and a job.stuck event, and then requeued or
+// failed as configured.
+type StuckJobDetector struct {
+	repository ports.JobRepository
+	queue      ports.JobQueue
+	config     StuckJobConfig
+	heartbeats ports.JobHeartbeats
+	clock      ports.Clock
+	events     ports.EventQueue
+	metrics    *metrics.Metrics
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	reported map[string]struct{} // Jobs still stuck after the last sweep, so they are reported once
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewStuckJobDetector(repository ports.JobRepository, queue ports.JobQueue, config StuckJobConfig, logger *zap.Logger) *StuckJobDetector {
+	return &StuckJobDetector{
+		repository: repository,
+		queue:      queue,
+		config:     config,
+		clock:      clock.System{},
+		logger:     logger,
+		reported:   make(map[string]struct{}),
+	}
+}
+
+// SetHeartbeats makes the detector tell jobs whose worker stopped beating
+// from jobs that stopped progressing. Without it only progress is judged.
+func (d *StuckJobDetector) SetHeartbeats(heartbeats ports.JobHeartbeats) {
+	d.heartbeats = heartbeats
+}
+
+// SetEventQueue makes the detector publish an event for each stuck job, and
+// for each job it fails
+func (d *StuckJobDetector) SetEventQueue(events ports.EventQueue) {
+	d.events = events
+}
+
+// SetMetrics makes the detector record the stuck jobs it finds
+func (d *StuckJobDetector) SetMetrics(m *metrics.Metrics) {
+	d.metrics = m
+}
+
+// SetClock replaces the system clock used to judge heartbeats and for job
+// timestamps
+func (d *StuckJobDetector) SetClock(c ports.Clock) {
+	d.clock = c
+}
+
+// Start sweeps the running jobs every sweep interval in the background
+func (d *StuckJobDetector) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	d.stop = stop
+	d.done = make(chan struct{})
+
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := d.Sweep(ctx); err != nil && ctx.Err() == nil {
+					d.logger.Error("Failed to sweep for stuck jobs", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	d.logger.Info("Started stuck job detector",
+		zap.Duration("stuck_after", d.config.StuckAfter),
+		zap.String("action", d.config.Action))
+}
+
+// Stop ends background sweeping
+func (d *StuckJobDetector) Stop() {
+	if d.stop == nil {
+		return
+	}
+	d.stop()
+	<-d.done
+}
+
+// Sweep finds the stuck running jobs and acts on them, returning how many were
+// stuck
+func (d *StuckJobDetector) Sweep(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	jobs, err := d.repository.Query(ctx, domain.JobQuery{Filter: domain.JobFilter{Status: string(domain.StatusProgress)}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list running jobs: %w", err)
+	}
+
+	beats := map[string]time.Time{}
+	if d.heartbeats != nil && len(jobs) > 0 {
+		jobIDs := make([]string, len(jobs))
+		for i, job := range jobs {
+			jobIDs[i] = job.ID
+		}
+		if beats, err = d.heartbeats.Last(ctx, jobIDs); err != nil {
+			return 0, fmt.Errorf("failed to load job heartbeats: %w", err)
+		}
+	}
+
+	now := d.clock.Now()
+	stuck := 0
+	reported := make(map[string]struct{})
+	for _, job := range jobs {
+		reason, ok := job.Stuck(beats[job.ID], d.config.StuckAfter, now)
+		if !ok {
+			continue
+		}
+		stuck++
+		if _, ok := d.reported[job.ID]; !ok {
+			d.report(ctx, job, reason, beats[job.ID], now)
+		}
+		if !d.act(ctx, job, reason, now) {
+			reported[job.ID] = struct{}{}
+		}
+	}
+	d.reported = reported
+
+	if d.metrics != nil {
+		d.metrics.SetStuckJobs(stuck)
+	}
+	return stuck, nil
+}
+
+// report records a newly stuck job and publishes its job.stuck event
+func (d *StuckJobDetector) report(ctx context.Context, job *domain.EncryptionJob, reason string, beat time.Time, now time.Time) {
+	if d.metrics != nil {
+		d.metrics.RecordStuckJob(reason, d.config.Action)
+	}
+
+	payload := domain.NewJobEvent(domain.EventJobStuck, job, now)
+	payload.Data["reason"] = reason
+	payload.Data["action"] = d.config.Action
+	payload.Data["last_progress"] = job.LastHeartbeat()
+	if !beat.IsZero() {
+		payload.Data["last_heartbeat"] = beat
+	}
+	publishEvent(ctx, d.events, payload, d.logger)
+
+	d.logger.Warn("Detected stuck job",
+		zap.String("job_id", job.ID),
+		zap.String("reason", reason),
+		zap.Time("last_progress", job.LastHeartbeat()),
+		zap.Time("last_heartbeat", beat))
+}
+
+// act requeues or fails a stuck job, reporting whether it did. Jobs it does
+// not act on stay stuck and are acted on again on the next sweep.
+func (d *StuckJobDetector) act(ctx context.Context, job *domain.EncryptionJob, reason string, now time.Time) bool {
+	switch d.config.Action {
+	case domain.RecoveryRequeue:
+		if err := requeueJob(ctx, d.repository, d.queue, job, now); err != nil {
+			d.logger.Error("Failed to requeue stuck job", zap.String("job_id", job.ID), zap.Error(err))
+			return false
+		}
+		d.logger.Warn("Requeued stuck job", zap.String("job_id", job.ID))
+	case domain.RecoveryFail:
+		message := "job stuck: no progress"
+		if reason == domain.StuckNoHeartbeat {
+			message = "job stuck: worker stopped beating"
+		}
+		if err := failJob(ctx, d.repository, job, message, domain.ErrCodeJobStuck, now); err != nil {
+			d.logger.Error("Failed to fail stuck job", zap.String("job_id", job.ID), zap.Error(err))
+			return false
+		}
+		publishJobOutcome(ctx, d.events, job, now, d.logger)
+		d.logger.Warn("Failed stuck job", zap.String("job_id", job.ID))
+	default:
+		return false
+	}
+	return true
+}
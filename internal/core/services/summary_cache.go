@@ -0,0 +1,79 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"E.E/internal/core/domain"
+)
+
+// summaryCache keeps each principal's status summary for a short time, so a
+// dashboard refreshed from many tabs does not aggregate on every request.
+// Writes through the service invalidate it; writes by workers in other
+// processes show up once the entry expires.
+type summaryCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	entries    map[string]summaryEntry
+	generation uint64
+}
+
+type summaryEntry struct {
+	summary   *domain.JobsStatusSummary
+	expiresAt time.Time
+}
+
+func newSummaryCache(ttl time.Duration) *summaryCache {
+	return &summaryCache{
+		ttl:     ttl,
+		entries: make(map[string]summaryEntry),
+	}
+}
+
+// get returns the cached summary for key if it has not expired, and the
+// generation a freshly computed summary must be stored under
+func (c *summaryCache) get(key string, now time.Time) (*domain.JobsStatusSummary, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return nil, c.generation
+	}
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, c.generation
+	}
+	return entry.summary, c.generation
+}
+
+// put stores a summary computed during generation. A summary computed before
+// the latest invalidation may miss the write that caused it and is dropped.
+func (c *summaryCache) put(key string, generation uint64, summary *domain.JobsStatusSummary, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || generation != c.generation {
+		return
+	}
+	c.entries[key] = summaryEntry{summary: summary, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidate drops every cached summary
+func (c *summaryCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
+
+// setTTL changes how long summaries are kept; 0 disables caching
+func (c *summaryCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.generation++
+	clear(c.entries)
+}
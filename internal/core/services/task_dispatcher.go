@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+)
+
+// TaskDispatcherConfig configures a TaskDispatcher
+type TaskDispatcherConfig struct {
+	Concurrency  int           // Tasks running at once
+	PollInterval time.Duration // Time between checks of the running tasks
+}
+
+// TaskDispatcher is the worker backend that launches a task per queued job,
+// e.g. a Kubernetes Job, instead of encrypting in process. The task records
+// the job's progress and outcome itself; the dispatcher follows each task
+// until it finishes and fails jobs whose tasks ended without an outcome.
+type TaskDispatcher struct {
+	repository ports.JobRepository
+	queue      ports.JobQueue
+	launcher   ports.TaskLauncher
+	config     TaskDispatcherConfig
+	clock      ports.Clock
+	events     ports.EventQueue
+	logger     *zap.Logger
+
+	slots chan struct{} // Holds a token per running task
+
+	mu    sync.Mutex
+	tasks map[string]bool // Followed tasks by job ID, true if holding a slot
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func NewTaskDispatcher(
+	repository ports.JobRepository,
+	queue ports.JobQueue,
+	launcher ports.TaskLauncher,
+	config TaskDispatcherConfig,
+	logger *zap.Logger,
+) *TaskDispatcher {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5 * time.Second
+	}
+
+	return &TaskDispatcher{
+		repository: repository,
+		queue:      queue,
+		launcher:   launcher,
+		config:     config,
+		clock:      clock.System{},
+		logger:     logger,
+		slots:      make(chan struct{}, config.Concurrency),
+		tasks:      make(map[string]bool),
+	}
+}
+
+// SetEventQueue makes the dispatcher publish an event for each job it fails
+// because its task ended without an outcome
+func (d *TaskDispatcher) SetEventQueue(events ports.EventQueue) {
+	d.events = events
+}
+
+// SetClock replaces the system clock used for job timestamps
+func (d *TaskDispatcher) SetClock(c ports.Clock) {
+	d.clock = c
+}
+
+// Start follows the tasks left by an earlier run, then launches tasks for
+// queued jobs as slots free up
+func (d *TaskDispatcher) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	d.stop = stop
+
+	jobIDs, err := d.launcher.List(ctx)
+	if err != nil {
+		d.logger.Warn("Failed to list running tasks; they are not followed until relaunched", zap.Error(err))
+	}
+	for _, jobID := range jobIDs {
+		// Tasks beyond the concurrency are followed without holding a slot
+		select {
+		case d.slots <- struct{}{}:
+			d.tasks[jobID] = true
+		default:
+			d.tasks[jobID] = false
+		}
+	}
+
+	d.wg.Add(2)
+	go d.dispatch(ctx)
+	go d.follow(ctx)
+
+	d.logger.Info("Started task dispatcher",
+		zap.Int("concurrency", d.config.Concurrency),
+		zap.Int("recovered_tasks", len(jobIDs)))
+}
+
+// Shutdown stops launching tasks. Running tasks are left to finish on their
+// own and are followed again by the next dispatcher to start.
+func (d *TaskDispatcher) Shutdown(ctx context.Context) error {
+	d.stop()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("task dispatcher did not stop: %w", ctx.Err())
+	}
+}
+
+// dispatch launches a task for each dequeued job while a slot is free
+func (d *TaskDispatcher) dispatch(ctx context.Context) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case d.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		jobID, err := d.queue.Dequeue(ctx)
+		if err != nil {
+			<-d.slots
+			if ctx.Err() != nil {
+				return
+			}
+			d.logger.Error("Failed to dequeue job", zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if !d.launch(ctx, jobID) {
+			<-d.slots
+		}
+	}
+}
+
+// launch starts the task of a queued job, reporting whether it is now followed
+func (d *TaskDispatcher) launch(ctx context.Context, jobID string) bool {
+	job, err := d.repository.Get(ctx, jobID)
+	if err != nil {
+		d.logger.Error("Failed to load job", zap.String("job_id", jobID), zap.Error(err))
+		return false
+	}
+	if job == nil {
+		d.logger.Warn("Dequeued job no longer exists", zap.String("job_id", jobID))
+		return false
+	}
+	if job.Status != domain.StatusQueued {
+		d.logger.Info("Skipping job that is not queued",
+			zap.String("job_id", jobID),
+			zap.String("status", string(job.Status)))
+		return false
+	}
+
+	if err := d.launcher.Launch(ctx, jobID); err != nil {
+		d.logger.Error("Failed to launch task", zap.String("job_id", jobID), zap.Error(err))
+		d.failJob(context.Background(), jobID, fmt.Sprintf("failed to launch worker task: %v", err))
+		return false
+	}
+
+	d.mu.Lock()
+	d.tasks[jobID] = true
+	d.mu.Unlock()
+
+	d.logger.Info("Launched task", zap.String("job_id", jobID))
+	return true
+}
+
+// follow checks the running tasks every PollInterval
+func (d *TaskDispatcher) follow(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.mu.Lock()
+			jobIDs := make([]string, 0, len(d.tasks))
+			for jobID := range d.tasks {
+				jobIDs = append(jobIDs, jobID)
+			}
+			d.mu.Unlock()
+
+			for _, jobID := range jobIDs {
+				if d.check(ctx, jobID) {
+					d.release(jobID)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check reconciles a task with its job, reporting whether the task is done
+// with. A finished task whose job was left unfinished fails the job, or
+// requeues it if the task was interrupted; a running task whose job was
+// cancelled is removed.
+func (d *TaskDispatcher) check(ctx context.Context, jobID string) bool {
+	state, err := d.launcher.State(ctx, jobID)
+	if err != nil {
+		d.logger.Warn("Failed to check task", zap.String("job_id", jobID), zap.Error(err))
+		return false
+	}
+
+	job, err := d.repository.Get(ctx, jobID)
+	if err != nil {
+		d.logger.Warn("Failed to load job of task", zap.String("job_id", jobID), zap.Error(err))
+		return false
+	}
+
+	if !state.IsFinished() {
+		if job == nil || job.Status == domain.StatusCancelled {
+			d.logger.Info("Removing task of cancelled job", zap.String("job_id", jobID))
+			if err := d.launcher.Remove(ctx, jobID); err != nil {
+				d.logger.Warn("Failed to remove task", zap.String("job_id", jobID), zap.Error(err))
+				return false
+			}
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case job == nil || job.IsTerminal() || job.Status == domain.StatusPaused:
+		// The task recorded the outcome, or the job was stopped, paused or
+		// deleted while it ran
+	case job.Status == domain.StatusPending:
+		d.requeue(ctx, job)
+	default:
+		reason := "worker task ended without recording an outcome"
+		if state.Reason != "" {
+			reason = fmt.Sprintf("worker task %s: %s", state.Phase, state.Reason)
+		}
+		d.failJob(ctx, jobID, reason)
+	}
+	return true
+}
+
+// release stops following a task, freeing its slot
+func (d *TaskDispatcher) release(jobID string) {
+	d.mu.Lock()
+	held, ok := d.tasks[jobID]
+	delete(d.tasks, jobID)
+	d.mu.Unlock()
+
+	if ok && held {
+		<-d.slots
+	}
+}
+
+// requeue queues a job whose task was interrupted, e.g. by a pod eviction
+func (d *TaskDispatcher) requeue(ctx context.Context, job *domain.EncryptionJob) {
+	if err := job.Transition(domain.StatusQueued, domain.JobActionRequeue, d.clock.Now()); err != nil {
+		d.logger.Error("Failed to requeue interrupted job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if err := d.repository.Update(ctx, job); err != nil {
+		d.logger.Error("Failed to requeue interrupted job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if err := d.queue.Enqueue(ctx, job.ID); err != nil {
+		d.logger.Error("Failed to requeue interrupted job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	d.logger.Info("Requeued job of interrupted task", zap.String("job_id", job.ID))
+}
+
+// failJob records that a job's task ended without an outcome
+func (d *TaskDispatcher) failJob(ctx context.Context, jobID, reason string) {
+	job, err := d.repository.Get(ctx, jobID)
+	if err != nil || job == nil {
+		d.logger.Error("Failed to load job to fail it", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+
+	job.Error = reason
+	job.FailOutputs(reason)
+	if err := job.Transition(domain.StatusFailed, domain.JobActionFail, d.clock.Now()); err != nil {
+		d.logger.Error("Failed to fail job", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	if err := d.repository.Update(ctx, job); err != nil {
+		d.logger.Error("Failed to fail job", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	publishJobOutcome(ctx, d.events, job, d.clock.Now(), d.logger)
+
+	d.logger.Warn("Failed job of worker task",
+		zap.String("job_id", jobID),
+		zap.String("reason", reason))
+}
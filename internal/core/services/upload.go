@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// uploadStorage is where uploaded sources are kept until their jobs end
+type uploadStorage struct {
+	storage ports.FileStorage
+	prefix  string
+	maxSize int64
+}
+
+// SetUploadStorage lets StartUpload store uploaded sources of at most maxSize
+// bytes in storage under prefix. Workers must read the storage's URLs as
+// sources and delete uploads from the same storage.
+func (s *EncryptionService) SetUploadStorage(storage ports.FileStorage, prefix string, maxSize int64) {
+	s.uploads = &uploadStorage{storage: storage, prefix: prefix, maxSize: maxSize}
+}
+
+func (s *EncryptionService) StartUpload(ctx context.Context, filename string, content io.Reader, opts domain.JobOptions) (*domain.EncryptionJob, error) {
+	if s.uploads == nil {
+		return nil, fmt.Errorf("%w: uploads are not enabled", domain.ErrNotAcceptingJobs)
+	}
+	return s.startUploadAt(ctx, path.Join(s.uploads.prefix, uuid.New().String(), domain.UploadName(filename)), content, opts)
+}
+
+// startUploadAt stores an uploaded source at uploadPath in the upload storage
+// and starts its encryption
+func (s *EncryptionService) startUploadAt(ctx context.Context, uploadPath string, content io.Reader, opts domain.JobOptions) (*domain.EncryptionJob, error) {
+	if s.uploads == nil {
+		return nil, fmt.Errorf("%w: uploads are not enabled", domain.ErrNotAcceptingJobs)
+	}
+	if s.draining.Load() {
+		return nil, domain.ErrNotAcceptingJobs
+	}
+
+	limited := &limitedReader{r: content, remaining: s.uploads.maxSize}
+	if err := s.uploads.storage.WriteFile(uploadPath, limited); err != nil {
+		s.discardUpload(uploadPath)
+		if limited.exceeded {
+			return nil, fmt.Errorf("%w: uploads must not exceed %d bytes", domain.ErrUploadTooLarge, s.uploads.maxSize)
+		}
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	opts.Upload = uploadPath
+	job, err := s.StartEncryption(ctx, s.uploads.storage.URL(uploadPath), opts)
+	if err != nil {
+		s.discardUpload(uploadPath)
+		return nil, err
+	}
+	if job.Upload != uploadPath {
+		// A completed job with the same content was reused
+		s.discardUpload(uploadPath)
+	}
+	return job, nil
+}
+
+// discardUpload deletes an uploaded source no job will read
+func (s *EncryptionService) discardUpload(uploadPath string) {
+	if err := s.uploads.storage.DeleteFile(uploadPath); err != nil {
+		s.logger.Warn("Failed to delete upload",
+			zap.String("upload", uploadPath),
+			zap.Error(err))
+	}
+}
+
+// deleteUpload deletes the uploaded source of a job that ended
+func (p *WorkerPool) deleteUpload(job *domain.EncryptionJob) {
+	if job.Upload == "" {
+		return
+	}
+	if err := p.outputStorage.DeleteFile(job.Upload); err != nil {
+		p.logger.Warn("Failed to delete uploaded source",
+			zap.String("job_id", job.ID),
+			zap.String("upload", job.Upload),
+			zap.Error(err))
+	}
+}
+
+// limitedReader fails reads past a size limit instead of ending the stream,
+// so a truncated upload is never stored as complete
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, domain.ErrUploadTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return n, domain.ErrUploadTooLarge
+	}
+	return n, err
+}
@@ -2,22 +2,52 @@ package services
 
 import (
     "bytes"
+    "context"
     "crypto/hmac"
     "crypto/sha256"
     "encoding/hex"
     "encoding/json"
+    "errors"
     "fmt"
+    "math/rand/v2"
     "net/http"
+    "sort"
+    "sync"
     "time"
 
+    "github.com/google/uuid"
     "go.uber.org/zap"
     "E.E/internal/core/domain"
+    "E.E/internal/core/ports"
+    "E.E/pkg/clock"
 )
 
+// DispatchConfig sizes the goroutine pool that delivers queued events
+type DispatchConfig struct {
+    Workers       int           // Events delivered concurrently
+    MaxAttempts   int           // Deliveries tried per event and endpoint
+    RetryDelay    time.Duration // Wait before the first retry, doubled for each further one
+    MaxRetryDelay time.Duration // Longest wait between retries; unbounded when 0
+}
+
+// WebhookService delivers job events to the webhooks in the configuration,
+// which receive the events of every tenant, and to those tenants register
+// through the API, which receive only their own tenant's events
 type WebhookService struct {
     logger     *zap.Logger
     httpClient *http.Client
     configs    map[string]domain.WebhookConfig
+    inflight   sync.WaitGroup
+
+    repository   ports.WebhookRepository
+    deadLetters  ports.WebhookDeadLetters
+    registration sync.Mutex // Serializes registrations, for the per-tenant limit
+    clock        ports.Clock
+
+    queue        ports.EventQueue
+    dispatch     DispatchConfig
+    stopDispatch context.CancelFunc
+    dispatchers  sync.WaitGroup
 }
 
 func NewWebhookService(logger *zap.Logger) *WebhookService {
@@ -25,9 +55,34 @@ func NewWebhookService(logger *zap.Logger) *WebhookService {
         logger:     logger,
         httpClient: &http.Client{Timeout: 10 * time.Second},
         configs:    make(map[string]domain.WebhookConfig),
+        clock:      clock.System{},
     }
 }
 
+// SetRepository lets tenants register webhooks through the API. Registered
+// webhooks are kept in repository and looked up for every event delivered,
+// so every dispatcher sees changes at once.
+func (s *WebhookService) SetRepository(repository ports.WebhookRepository) {
+    s.repository = repository
+}
+
+// SetDeadLetters keeps the deliveries that failed for good in deadLetters, where
+// tenants can inspect those to their webhooks
+func (s *WebhookService) SetDeadLetters(deadLetters ports.WebhookDeadLetters) {
+    s.deadLetters = deadLetters
+}
+
+// SetClock replaces the system clock used to timestamp registrations
+func (s *WebhookService) SetClock(c ports.Clock) {
+    s.clock = c
+}
+
+// SetHTTPClient replaces the default client, e.g. with one on a shared
+// connection pool
+func (s *WebhookService) SetHTTPClient(client *http.Client) {
+    s.httpClient = client
+}
+
 func (s *WebhookService) RegisterWebhook(config domain.WebhookConfig) error {
     if config.URL == "" {
         return fmt.Errorf("webhook URL is required")
@@ -35,12 +90,20 @@ func (s *WebhookService) RegisterWebhook(config domain.WebhookConfig) error {
     if config.Secret == "" {
         return fmt.Errorf("webhook secret is required")
     }
+    for _, event := range config.EventTypes {
+        if !domain.IsWebhookEvent(event) {
+            return fmt.Errorf("unknown webhook event %q", event)
+        }
+    }
     
     s.configs[config.URL] = config
     return nil
 }
 
 func (s *WebhookService) SendWebhook(payload domain.WebhookPayload, config domain.WebhookConfig) error {
+    s.inflight.Add(1)
+    defer s.inflight.Done()
+
     // Sign payload
     payload.Signature = s.signPayload(payload, config.Secret)
 
@@ -62,17 +125,378 @@ func (s *WebhookService) SendWebhook(payload domain.WebhookPayload, config domai
     // Send request
     resp, err := s.httpClient.Do(req)
     if err != nil {
-        return fmt.Errorf("failed to send webhook: %w", err)
+        return &domain.WebhookDeliveryError{Err: err}
     }
     defer resp.Body.Close()
 
     if resp.StatusCode >= 300 {
-        return fmt.Errorf("webhook failed with status: %d", resp.StatusCode)
+        return &domain.WebhookDeliveryError{StatusCode: resp.StatusCode}
     }
 
     return nil
 }
 
+// StartDispatch delivers the events published to queue to the registered
+// webhooks from a pool of config.Workers goroutines. Deliveries that time out
+// or meet a server error are retried with exponential backoff and jitter; a
+// retry still waiting when Flush is called goes back to the queue. Deliveries
+// that are rejected, or still fail after config.MaxAttempts, are moved to the
+// dead-letter list of their webhook.
+func (s *WebhookService) StartDispatch(queue ports.EventQueue, config DispatchConfig) {
+    if config.Workers <= 0 {
+        config.Workers = 1
+    }
+    if config.MaxAttempts <= 0 {
+        config.MaxAttempts = 1
+    }
+    s.queue = queue
+    s.dispatch = config
+
+    ctx, stop := context.WithCancel(context.Background())
+    s.stopDispatch = stop
+    for i := 0; i < config.Workers; i++ {
+        s.dispatchers.Add(1)
+        go s.runDispatcher(ctx)
+    }
+
+    s.logger.Info("Started webhook dispatch",
+        zap.Int("workers", config.Workers),
+        zap.Int("endpoints", len(s.configs)))
+}
+
+func (s *WebhookService) runDispatcher(ctx context.Context) {
+    defer s.dispatchers.Done()
+
+    for {
+        event, err := s.queue.Consume(ctx)
+        if err != nil {
+            if ctx.Err() != nil {
+                return
+            }
+            s.logger.Error("Failed to consume webhook event", zap.Error(err))
+            time.Sleep(time.Second)
+            continue
+        }
+        s.deliver(ctx, event)
+    }
+}
+
+// deliver sends an event to every endpoint subscribed to it, or only to the
+// endpoint a retry is for
+func (s *WebhookService) deliver(ctx context.Context, event domain.QueuedEvent) {
+    for _, config := range s.endpoints(ctx, event.Payload.Tenant) {
+        if event.Endpoint != "" && event.Endpoint != config.Endpoint() {
+            continue
+        }
+        if !subscribed(config, event.Payload.EventType) {
+            continue
+        }
+        s.deliverTo(ctx, event.Payload, config, event.Attempts)
+    }
+}
+
+// endpoints returns the configured webhooks and those registered for tenant.
+// Configured webhooks are still delivered to when the registered ones cannot
+// be listed.
+func (s *WebhookService) endpoints(ctx context.Context, tenant string) []domain.WebhookConfig {
+    endpoints := make([]domain.WebhookConfig, 0, len(s.configs))
+    for _, config := range s.configs {
+        endpoints = append(endpoints, config)
+    }
+    if s.repository == nil || tenant == "" {
+        return endpoints
+    }
+
+    registered, err := s.repository.ListWebhooks(ctx, tenant)
+    if err != nil {
+        s.logger.Error("Failed to list registered webhooks",
+            zap.String("tenant", tenant),
+            zap.Error(err))
+        return endpoints
+    }
+    for _, webhook := range registered {
+        if webhook.Receives(tenant) {
+            endpoints = append(endpoints, *webhook)
+        }
+    }
+    return endpoints
+}
+
+// deliverTo sends payload to one endpoint until it succeeds, is rejected or
+// runs out of attempts. attempts counts earlier failed deliveries.
+func (s *WebhookService) deliverTo(ctx context.Context, payload domain.WebhookPayload, config domain.WebhookConfig, attempts int) {
+    for {
+        err := s.SendWebhook(payload, config)
+        if err == nil {
+            return
+        }
+        attempts++
+        var deliveryErr *domain.WebhookDeliveryError
+        retryable := !errors.As(err, &deliveryErr) || deliveryErr.Retryable()
+        if !retryable || attempts >= s.dispatch.MaxAttempts {
+            s.logger.Error("Giving up on webhook delivery",
+                zap.String("url", config.URL),
+                zap.String("event", string(payload.EventType)),
+                zap.String("job_id", payload.JobID),
+                zap.Int("attempts", attempts),
+                zap.Bool("retryable", retryable),
+                zap.Error(err))
+            s.deadLetter(payload, config, attempts, err)
+            return
+        }
+        delay := s.retryDelay(attempts)
+        s.logger.Warn("Webhook delivery failed, retrying",
+            zap.String("url", config.URL),
+            zap.String("event", string(payload.EventType)),
+            zap.String("job_id", payload.JobID),
+            zap.Duration("retry_in", delay),
+            zap.Error(err))
+
+        select {
+        case <-time.After(delay):
+        case <-ctx.Done():
+            // Shutting down; the queue keeps the retry for the next dispatcher
+            retry := domain.QueuedEvent{Payload: payload, Endpoint: config.Endpoint(), Attempts: attempts}
+            if err := s.queue.Publish(context.Background(), retry); err != nil {
+                s.logger.Error("Failed to requeue webhook delivery",
+                    zap.String("url", config.URL),
+                    zap.String("job_id", payload.JobID),
+                    zap.Error(err))
+            }
+            return
+        }
+    }
+}
+
+// retryDelay returns the wait before the given retry, counting from 1:
+// RetryDelay doubled for each earlier retry up to MaxRetryDelay, of which a
+// random part up to half is taken off so endpoints that failed together are
+// not retried in lockstep
+func (s *WebhookService) retryDelay(retry int) time.Duration {
+    delay := s.dispatch.RetryDelay << min(retry-1, 16)
+    if limit := s.dispatch.MaxRetryDelay; limit > 0 && delay > limit {
+        delay = limit
+    }
+    if delay < 2 {
+        return delay
+    }
+    return delay - rand.N(delay/2)
+}
+
+// deadLetter keeps a delivery that failed for good
+func (s *WebhookService) deadLetter(payload domain.WebhookPayload, config domain.WebhookConfig, attempts int, err error) {
+    if s.deadLetters == nil {
+        return
+    }
+    delivery := &domain.WebhookDelivery{
+        ID:       uuid.New().String(),
+        Endpoint: config.Endpoint(),
+        URL:      config.URL,
+        Payload:  payload,
+        Attempts: attempts,
+        Error:    err.Error(),
+        FailedAt: s.clock.Now().Unix(),
+    }
+    var deliveryErr *domain.WebhookDeliveryError
+    if errors.As(err, &deliveryErr) {
+        delivery.StatusCode = deliveryErr.StatusCode
+    }
+    if err := s.deadLetters.RecordDeadLetter(context.Background(), delivery); err != nil {
+        s.logger.Error("Failed to record failed webhook delivery",
+            zap.String("url", config.URL),
+            zap.String("job_id", payload.JobID),
+            zap.Error(err))
+    }
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, req domain.WebhookRequest) (*domain.WebhookConfig, error) {
+    principal := domain.PrincipalFromContext(ctx)
+    tenant := req.Tenant
+    if tenant == "" {
+        tenant = principal.TenantID()
+    }
+    if err := principal.AuthorizeTenant(tenant); err != nil {
+        return nil, fmt.Errorf("%w: %q may only register webhooks for its tenant", domain.ErrForbidden, principal.ID)
+    }
+    if tenant == "" {
+        return nil, fmt.Errorf("%w: tenant is required", domain.ErrInvalidWebhook)
+    }
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+
+    s.registration.Lock()
+    defer s.registration.Unlock()
+
+    registered, err := s.repository.ListWebhooks(ctx, tenant)
+    if err != nil {
+        return nil, err
+    }
+    if len(registered) >= domain.MaxWebhooksPerTenant {
+        return nil, fmt.Errorf("%w: tenant %q already has %d webhooks", domain.ErrInvalidWebhook, tenant, domain.MaxWebhooksPerTenant)
+    }
+
+    now := s.clock.Now().Unix()
+    webhook := &domain.WebhookConfig{
+        ID:         uuid.New().String(),
+        Tenant:     tenant,
+        URL:        req.URL,
+        Secret:     req.Secret,
+        EventTypes: req.EventTypes,
+        CreatedBy:  principal.ID,
+        CreatedAt:  now,
+        UpdatedAt:  now,
+    }
+    if err := s.repository.SaveWebhook(ctx, webhook); err != nil {
+        return nil, err
+    }
+
+    s.logger.Info("Webhook registered",
+        zap.String("webhook_id", webhook.ID),
+        zap.String("tenant", webhook.Tenant),
+        zap.String("url", webhook.URL),
+        zap.String("created_by", webhook.CreatedBy))
+
+    webhook.Secret = ""
+    return webhook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, tenant string) ([]*domain.WebhookConfig, error) {
+    principal := domain.PrincipalFromContext(ctx)
+    if !principal.Admin {
+        tenant = principal.TenantID()
+    }
+    webhooks, err := s.repository.ListWebhooks(ctx, tenant)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, webhook := range webhooks {
+        webhook.Secret = ""
+    }
+    sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt > webhooks[j].CreatedAt })
+    return webhooks, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error) {
+    webhook, err := s.registeredWebhook(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    webhook.Secret = ""
+    return webhook, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id string, req domain.WebhookRequest) (*domain.WebhookConfig, error) {
+    webhook, err := s.registeredWebhook(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if req.Tenant != "" && req.Tenant != webhook.Tenant {
+        return nil, fmt.Errorf("%w: the tenant of a webhook cannot be changed", domain.ErrInvalidWebhook)
+    }
+    if req.Secret == "" {
+        req.Secret = webhook.Secret
+    }
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+
+    webhook.URL = req.URL
+    webhook.Secret = req.Secret
+    webhook.EventTypes = req.EventTypes
+    webhook.UpdatedAt = s.clock.Now().Unix()
+    if err := s.repository.SaveWebhook(ctx, webhook); err != nil {
+        return nil, err
+    }
+
+    s.logger.Info("Webhook updated",
+        zap.String("webhook_id", webhook.ID),
+        zap.String("tenant", webhook.Tenant),
+        zap.String("url", webhook.URL),
+        zap.String("updated_by", domain.PrincipalFromContext(ctx).ID))
+
+    webhook.Secret = ""
+    return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error) {
+    webhook, err := s.registeredWebhook(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.repository.DeleteWebhook(ctx, id); err != nil {
+        return nil, err
+    }
+
+    s.logger.Info("Webhook deleted",
+        zap.String("webhook_id", webhook.ID),
+        zap.String("tenant", webhook.Tenant),
+        zap.String("deleted_by", domain.PrincipalFromContext(ctx).ID))
+
+    webhook.Secret = ""
+    return webhook, nil
+}
+
+func (s *WebhookService) ListWebhookDeliveries(ctx context.Context, id string, limit int) ([]*domain.WebhookDelivery, error) {
+    if _, err := s.registeredWebhook(ctx, id); err != nil {
+        return nil, err
+    }
+    if s.deadLetters == nil {
+        return []*domain.WebhookDelivery{}, nil
+    }
+    return s.deadLetters.ListDeadLetters(ctx, id, limit)
+}
+
+// registeredWebhook returns a registered webhook the caller may see. Webhooks
+// of other tenants are reported as not found, like their jobs.
+func (s *WebhookService) registeredWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error) {
+    webhook, err := s.repository.GetWebhook(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if webhook == nil || domain.PrincipalFromContext(ctx).AuthorizeTenant(webhook.Tenant) != nil {
+        return nil, fmt.Errorf("%w: %s", domain.ErrWebhookNotFound, id)
+    }
+    return webhook, nil
+}
+
+// subscribed reports whether a webhook receives an event type. A webhook
+// without event types receives every event.
+func subscribed(config domain.WebhookConfig, event domain.WebhookEvent) bool {
+    if len(config.EventTypes) == 0 {
+        return true
+    }
+    for _, e := range config.EventTypes {
+        if e == event {
+            return true
+        }
+    }
+    return false
+}
+
+// Flush stops taking events from the queue and waits for in-flight webhook
+// deliveries to finish or ctx to expire. Events still queued stay there.
+func (s *WebhookService) Flush(ctx context.Context) error {
+    if s.stopDispatch != nil {
+        s.stopDispatch()
+    }
+
+    done := make(chan struct{})
+    go func() {
+        s.dispatchers.Wait()
+        s.inflight.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("webhook deliveries still in flight: %w", ctx.Err())
+    }
+}
+
 // signPayload creates an HMAC SHA256 signature of the payload
 func (s *WebhookService) signPayload(payload domain.WebhookPayload, secret string) string {
     // Create a copy of payload without the signature
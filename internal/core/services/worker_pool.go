@@ -0,0 +1,1367 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/clock"
+	"E.E/pkg/diskspace"
+	"E.E/pkg/metrics"
+)
+
+// interruptGrace bounds how long Shutdown waits for interrupted jobs to record
+// their state once the drain window has expired
+const interruptGrace = 5 * time.Second
+
+// WorkerConfig configures the encryption worker pool
+type WorkerConfig struct {
+	Concurrency      int           // Number of jobs processed in parallel
+	OutputPrefix     string        // Path prefix for outputs in the output storage
+	ProgressInterval time.Duration // Minimum time between persisted progress updates
+	CheckpointSize   int64         // Source bytes between checkpoints of larger jobs; 0 disables checkpointing
+}
+
+// WorkerPool pulls jobs from the queue and runs them through the encryption engine
+type WorkerPool struct {
+	repository    ports.JobRepository
+	queue         ports.JobQueue
+	engine        ports.EncryptionEngine
+	fetcher       ports.SourceFetcher
+	outputStorage ports.FileStorage
+	destinations  ports.OutputDestinations
+	config        WorkerConfig
+	clock         ports.Clock
+	prober        ports.MediaProber
+	mediaPolicy   domain.MediaPolicy
+	transcoder    ports.Transcoder
+	processors    []ports.SourceProcessor
+	retryPolicy   domain.RetryPolicy
+	workspaces    ports.Workspaces
+	events        ports.EventQueue
+	progress      ports.EncryptionProgress
+	metrics       *metrics.Metrics
+	keys          ports.KeyStore
+	keyPolicies   ports.KeyPolicyStore
+	keyAudit      ports.KeyAuditLog
+	heartbeats    ports.JobHeartbeats
+	beatInterval  time.Duration
+	locks         ports.JobLocks
+	leaseTTL      time.Duration
+	logger        *zap.Logger
+
+	stopDequeue context.CancelFunc
+	jobCtx      context.Context
+	cancelJobs  context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func NewWorkerPool(
+	repository ports.JobRepository,
+	queue ports.JobQueue,
+	engine ports.EncryptionEngine,
+	fetcher ports.SourceFetcher,
+	outputStorage ports.FileStorage,
+	config WorkerConfig,
+	logger *zap.Logger,
+) *WorkerPool {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.ProgressInterval <= 0 {
+		config.ProgressInterval = time.Second
+	}
+
+	return &WorkerPool{
+		repository:    repository,
+		queue:         queue,
+		engine:        engine,
+		fetcher:       fetcher,
+		outputStorage: outputStorage,
+		config:        config,
+		clock:         clock.System{},
+		logger:        logger,
+	}
+}
+
+// SetMediaProber makes workers probe each source that was not probed on
+// submission, failing jobs whose media policy does not allow before they are
+// encrypted
+func (p *WorkerPool) SetMediaProber(prober ports.MediaProber, policy domain.MediaPolicy) {
+	p.prober = prober
+	p.mediaPolicy = policy
+}
+
+// SetTranscoder makes workers transcode the sources of jobs that ask for it
+// before encrypting them. Without a transcoder such jobs fail.
+func (p *WorkerPool) SetTranscoder(transcoder ports.Transcoder) {
+	p.transcoder = transcoder
+}
+
+// AddSourceProcessor makes workers run processor on the source of every job
+// it applies to, after the processors added before it. Processors need
+// workspaces to keep the processed source in.
+func (p *WorkerPool) AddSourceProcessor(processor ports.SourceProcessor) {
+	p.processors = append(p.processors, processor)
+}
+
+// SetWorkspaces makes workers keep the scratch files of each job in a
+// workspace from workspaces, removed once the job ends however it ends
+func (p *WorkerPool) SetWorkspaces(workspaces ports.Workspaces) {
+	p.workspaces = workspaces
+}
+
+// SetEventQueue makes workers publish an event for each job they start, and
+// for each that completes or fails once its outcome is stored. Delivering the events is left to the
+// webhook dispatchers, so slow receivers never hold up a worker.
+func (p *WorkerPool) SetEventQueue(events ports.EventQueue) {
+	p.events = events
+}
+
+// SetProgress makes workers publish each job's progress to progress as they
+// store it, so subscribers see it without polling the job
+func (p *WorkerPool) SetProgress(progress ports.EncryptionProgress) {
+	p.progress = progress
+}
+
+// SetMetrics makes workers record the outcome and duration of each job they
+// finish and the number of jobs they are running
+func (p *WorkerPool) SetMetrics(m *metrics.Metrics) {
+	p.metrics = m
+}
+
+// SetKeyStore makes workers seal the key of every job and output they encrypt
+// with store, so only the sealed reference is persisted, and open sealed keys
+// to decrypt with
+func (p *WorkerPool) SetKeyStore(store ports.KeyStore) {
+	p.keys = store
+}
+
+// SetKeyPolicies makes workers retire the keys key rotation jobs replace in
+// policies, so they are no longer delivered, and record it in audit
+func (p *WorkerPool) SetKeyPolicies(policies ports.KeyPolicyStore, audit ports.KeyAuditLog) {
+	p.keyPolicies = policies
+	p.keyAudit = audit
+}
+
+// SetHeartbeats makes workers record a heartbeat in heartbeats every interval
+// while they run a job, so jobs whose worker hangs or dies can be told apart
+// from slow ones
+func (p *WorkerPool) SetHeartbeats(heartbeats ports.JobHeartbeats, interval time.Duration) {
+	p.heartbeats = heartbeats
+	p.beatInterval = interval
+}
+
+// SetLocks makes workers lease each job they dequeue from locks for ttl,
+// renewing the lease while they run it, and skip jobs another worker holds.
+// Processes sharing a queue must share locks for each job to run once.
+func (p *WorkerPool) SetLocks(locks ports.JobLocks, ttl time.Duration) {
+	p.locks = locks
+	p.leaseTTL = ttl
+}
+
+// SetClock replaces the system clock used for job timestamps, timings and
+// progress reporting
+func (p *WorkerPool) SetClock(c ports.Clock) {
+	p.clock = c
+}
+
+// Start launches the workers
+func (p *WorkerPool) Start() {
+	dequeueCtx, stop := context.WithCancel(context.Background())
+	p.stopDequeue = stop
+	p.jobCtx, p.cancelJobs = context.WithCancel(context.Background())
+
+	for i := 0; i < p.config.Concurrency; i++ {
+		p.wg.Add(1)
+		go p.run(dequeueCtx, i)
+	}
+
+	p.logger.Info("Started encryption workers", zap.Int("concurrency", p.config.Concurrency))
+}
+
+// Shutdown stops taking jobs from the queue and waits for in-flight jobs to
+// finish. Jobs still running when ctx expires are interrupted and returned to
+// PENDING so they can be picked up again.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.stopDequeue()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.logger.Info("Encryption workers drained")
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.logger.Warn("Drain window expired, interrupting in-flight jobs")
+	p.cancelJobs()
+
+	select {
+	case <-done:
+		return fmt.Errorf("drain timed out: in-flight jobs were interrupted")
+	case <-time.After(interruptGrace):
+		return fmt.Errorf("drain timed out: workers did not stop after interruption")
+	}
+}
+
+// RunJob processes a single job in the calling goroutine, as the pods of the
+// Kubernetes worker backend do. It returns an error if no outcome was
+// recorded, e.g. because ctx was cancelled and the job was interrupted.
+func (p *WorkerPool) RunJob(ctx context.Context, jobID string) error {
+	p.jobCtx = ctx
+	p.process(jobID)
+
+	job, err := p.repository.Get(context.Background(), jobID)
+	if err != nil {
+		return fmt.Errorf("failed to load job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %s does not exist", jobID)
+	}
+	if !job.IsTerminal() {
+		return fmt.Errorf("job %s was left %s", jobID, job.Status)
+	}
+	return nil
+}
+
+func (p *WorkerPool) run(ctx context.Context, worker int) {
+	defer p.wg.Done()
+
+	for {
+		jobID, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.logger.Error("Failed to dequeue job", zap.Int("worker", worker), zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+
+		p.process(jobID)
+	}
+}
+
+// process runs a single job and records its outcome
+func (p *WorkerPool) process(jobID string) {
+	ctx, cancel := context.WithCancel(p.jobCtx)
+	defer cancel()
+
+	// Job state is persisted with a context that outlives interruption so the
+	// outcome is always recorded
+	storeCtx := context.Background()
+
+	var (
+		lease *jobLease
+		err   error
+	)
+	if p.locks != nil {
+		if lease, err = p.lease(jobID, cancel); err != nil {
+			p.logger.Error("Failed to lease job", zap.String("job_id", jobID), zap.Error(err))
+			return
+		}
+		if lease == nil {
+			p.leasedElsewhere(storeCtx, jobID)
+			return
+		}
+		defer lease.release()
+	}
+
+	job, err := p.repository.Get(storeCtx, jobID)
+	if err != nil {
+		p.logger.Error("Failed to load job", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	if job == nil {
+		p.logger.Warn("Dequeued job no longer exists", zap.String("job_id", jobID))
+		return
+	}
+	if job.Status != domain.StatusQueued {
+		p.logger.Info("Skipping job that is not queued",
+			zap.String("job_id", jobID),
+			zap.String("status", string(job.Status)))
+		// Such as a job stopped while it was queued
+		if job.IsTerminal() {
+			p.deleteUpload(job)
+		}
+		return
+	}
+
+	job.Progress = domain.Progress{Stage: domain.StageFetching, Checkpoint: job.Progress.Checkpoint}
+	if p.prober != nil && job.Media == nil && !job.IsDecryption() && !job.IsRotation() {
+		job.Progress.Stage = domain.StageProbing
+	}
+	if err := job.Transition(domain.StatusProgress, domain.JobActionStart, p.clock.Now()); err != nil {
+		p.logger.Error("Failed to start job", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	job.HeartbeatAt = job.UpdatedAt
+	job.StartedAt = job.UpdatedAt
+	started, err := p.repository.UpdateIf(storeCtx, job, func(current *domain.EncryptionJob) bool {
+		return current.Status == domain.StatusQueued
+	})
+	if err != nil {
+		p.logger.Error("Failed to mark job in progress", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	if !started {
+		p.logger.Info("Job changed state before it started, skipping it", zap.String("job_id", jobID))
+		return
+	}
+	publishEvent(storeCtx, p.events, domain.NewJobEvent(domain.EventJobStarted, job, p.clock.Now()), p.logger)
+	if p.heartbeats != nil {
+		defer p.beat(jobID)()
+	}
+	if p.workspaces != nil {
+		defer p.releaseWorkspace(jobID)
+	}
+
+	if p.metrics != nil {
+		p.metrics.IncrementActiveEncryptionJobs()
+		defer p.metrics.DecrementActiveEncryptionJobs()
+	}
+
+	start := p.clock.Now()
+	var (
+		result *domain.JobResult
+		key    string
+	)
+	if job.IsDecryption() {
+		result, err = p.decrypt(ctx, cancel, job)
+	} else if job.IsRotation() {
+		result, key, err = p.rotate(ctx, cancel, job)
+	} else {
+		result, key, err = p.encrypt(ctx, cancel, job)
+	}
+	if err == nil && result.SourceHash != "" {
+		// Jobs are matched by the hash of the content actually read
+		if job.SourceHash != "" && job.SourceHash != result.SourceHash {
+			err = fmt.Errorf("%w: submitted as %s but read as %s", domain.ErrSourceHashMismatch, job.SourceHash, result.SourceHash)
+		} else {
+			job.SourceHash = result.SourceHash
+		}
+	}
+	var stored domain.StoredKey
+	if err == nil {
+		stored, err = sealJobKey(storeCtx, p.keys, job, key)
+	}
+	if err == nil && len(job.CopyTo) > 0 {
+		copyStart := p.clock.Now()
+		progress := job.Progress
+		progress.Stage = domain.StageCopying
+		progress.ETA = 0
+		p.progressUpdater(job, cancel)(progress)
+		job.Copies = p.copyOutputs(ctx, job, result)
+		result.Timings.Copy = domain.Duration(p.clock.Now().Sub(copyStart))
+		result.Timings.Total += result.Timings.Copy
+	}
+
+	// Another worker may have taken the job over; its run wins
+	if lease != nil && lease.lost.Load() {
+		p.logger.Warn("Lost the job's lease while running, discarding result", zap.String("job_id", jobID))
+		return
+	}
+
+	// The job may have been stopped while it ran; its new state wins
+	if current, getErr := p.repository.Get(storeCtx, jobID); getErr == nil && current != nil {
+		if !isRunOf(job)(current) {
+			p.discardResult(current)
+			return
+		}
+		job.Metadata = current.Metadata
+	}
+
+	switch {
+	case err == nil:
+		job.Progress.Percent = 100
+		job.Progress.Stage = domain.StageDone
+		job.Progress.ETA = 0
+		job.Progress.Elapsed = domain.Duration(p.clock.Now().Sub(start).Round(time.Second))
+		if job.Progress.Stages.Tracked() {
+			job.Progress.Stages = job.Progress.Stages.Advance(job.Progress)
+		}
+		job.SetStoredKey(stored)
+		job.OutputPath = result.OutputPath
+		job.Result = result
+		err = job.Transition(domain.StatusCompleted, domain.JobActionComplete, p.clock.Now())
+	case p.jobCtx.Err() != nil:
+		// Checkpointed jobs continue from their checkpoint
+		job.ResetProgress()
+		job.ResetOutputs()
+		err = job.Transition(domain.StatusPending, domain.JobActionInterrupt, p.clock.Now())
+	default:
+		job.Attempts++
+		job.LastError = err.Error()
+		// Failures that may pass run again later, from their checkpoint
+		if class := retryClass(err); class != "" {
+			if delay, ok := p.retryPolicy.Retry(job.Attempts, class); ok {
+				now := p.clock.Now()
+				job.ResetProgress()
+				job.ResetOutputs()
+				err = job.ScheduleRetry(err, class, now.Add(delay), now)
+				if p.metrics != nil {
+					p.metrics.RecordJobRetry(class)
+				}
+				p.logger.Warn("Retrying failed job",
+					zap.String("job_id", jobID),
+					zap.String("class", class),
+					zap.Int("attempt", job.Attempts),
+					zap.Duration("retry_in", delay),
+					zap.String("error", job.LastError))
+				break
+			}
+		}
+		job.Error = err.Error()
+		switch {
+		case errors.Is(err, diskspace.ErrLow):
+			job.ErrorCode = domain.ErrCodeInsufficientSpace
+		case errors.Is(err, domain.ErrUnsupportedMedia):
+			job.ErrorCode = domain.ErrCodeUnsupportedMedia
+		case errors.Is(err, domain.ErrTranscodeFailed):
+			job.ErrorCode = domain.ErrCodeTranscodeFailed
+		case errors.Is(err, domain.ErrWatermarkFailed):
+			job.ErrorCode = domain.ErrCodeWatermarkFailed
+		case errors.Is(err, domain.ErrSourceHashMismatch):
+			job.ErrorCode = domain.ErrCodeSourceHashMismatch
+		case errors.Is(err, domain.ErrRotationMismatch):
+			job.ErrorCode = domain.ErrCodeRotationMismatch
+		}
+		job.FailOutputs(job.Error)
+		p.discardCheckpoint(job)
+		err = job.Transition(domain.StatusFailed, domain.JobActionFail, p.clock.Now())
+	}
+	if err != nil {
+		p.logger.Error("Failed to record job outcome", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+
+	// Written only if the job was not stopped or paused since it was checked
+	recorded, err := p.repository.UpdateIf(storeCtx, job, isRunOf(job))
+	if err == nil && !recorded {
+		if current, getErr := p.repository.Get(storeCtx, jobID); getErr == nil && current != nil {
+			p.discardResult(current)
+		}
+		return
+	}
+	if err != nil {
+		p.logger.Error("Failed to record job outcome", zap.String("job_id", jobID), zap.Error(err))
+	} else {
+		p.publishProgress(job)
+		p.publishOutcome(storeCtx, job)
+		// Only once the new key is stored with its job
+		if job.IsRotation() && job.Status == domain.StatusCompleted {
+			p.retireKey(storeCtx, job)
+		}
+		if job.IsTerminal() {
+			p.deleteUpload(job)
+		}
+	}
+	if p.metrics != nil && job.IsTerminal() {
+		p.metrics.RecordEncryptionJob(string(job.Status))
+		p.metrics.ObserveEncryptionJobDuration(string(job.Status), p.clock.Now().Sub(start).Seconds())
+	}
+
+	p.logger.Info("Encryption job finished",
+		zap.String("job_id", jobID),
+		zap.String("kind", job.Kind),
+		zap.String("status", string(job.Status)),
+		zap.Duration("duration", p.clock.Now().Sub(start)),
+		zap.String("error", job.Error))
+}
+
+// isRunOf returns a condition matching the stored job while it is still in
+// the run of job this worker started: IN_PROGRESS, and not requeued and
+// started again since
+func isRunOf(job *domain.EncryptionJob) func(*domain.EncryptionJob) bool {
+	startedAt := job.StartedAt
+	return func(current *domain.EncryptionJob) bool {
+		return current.Status == domain.StatusProgress && current.StartedAt == startedAt
+	}
+}
+
+// discardResult drops the outcome of a run whose job was moved out of it
+// while it ran, cleaning up after the job if it has ended
+func (p *WorkerPool) discardResult(current *domain.EncryptionJob) {
+	p.logger.Info("Job changed state while running, discarding result",
+		zap.String("job_id", current.ID),
+		zap.String("status", string(current.Status)))
+	if current.IsTerminal() {
+		p.discardCheckpoint(current)
+		p.deleteUpload(current)
+	}
+}
+
+// releaseWorkspace removes the job's workspace. One that cannot be removed is
+// left to the workspace janitor.
+func (p *WorkerPool) releaseWorkspace(jobID string) {
+	if err := p.workspaces.Release(jobID); err != nil {
+		p.logger.Warn("Failed to remove job workspace", zap.String("job_id", jobID), zap.Error(err))
+	}
+}
+
+// beat records heartbeats of a job in the background until the returned
+// function is called, which clears them
+func (p *WorkerPool) beat(jobID string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	record := func() {
+		if err := p.heartbeats.Beat(context.Background(), jobID, p.clock.Now()); err != nil {
+			p.logger.Warn("Failed to record job heartbeat", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}
+
+	record()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.beatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				record()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		if err := p.heartbeats.Clear(context.Background(), jobID); err != nil {
+			p.logger.Warn("Failed to clear job heartbeat", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}
+}
+
+// jobLease is a worker's lease on the job it runs
+type jobLease struct {
+	release func()      // Stops renewing and releases the lease
+	lost    atomic.Bool // The lease expired or was taken over while the job ran
+}
+
+// lease acquires the lease on a job and renews it in the background until it
+// is released, calling abort if it is lost. It returns nil if another worker
+// holds the job.
+func (p *WorkerPool) lease(jobID string, abort context.CancelFunc) (*jobLease, error) {
+	owner := uuid.New().String()
+	acquired, err := p.locks.Acquire(context.Background(), jobID, owner, p.leaseTTL)
+	if err != nil || !acquired {
+		return nil, err
+	}
+
+	lease := &jobLease{}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.leaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				held, err := p.locks.Renew(context.Background(), jobID, owner, p.leaseTTL)
+				if err != nil {
+					// The lease may still be renewed in time
+					p.logger.Warn("Failed to renew job lease", zap.String("job_id", jobID), zap.Error(err))
+					continue
+				}
+				if !held {
+					p.logger.Warn("Lost job lease, aborting job", zap.String("job_id", jobID))
+					lease.lost.Store(true)
+					abort()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	lease.release = func() {
+		close(done)
+		<-stopped
+		if err := p.locks.Release(context.Background(), jobID, owner); err != nil {
+			p.logger.Warn("Failed to release job lease", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}
+	return lease, nil
+}
+
+// leasedElsewhere handles a dequeued job another worker holds. A job still
+// queued, such as one requeued while its old worker has yet to let go of it,
+// is enqueued again once the lease could have run out; any other job is
+// running elsewhere and skipped.
+func (p *WorkerPool) leasedElsewhere(ctx context.Context, jobID string) {
+	job, err := p.repository.Get(ctx, jobID)
+	if err != nil || job == nil || job.Status != domain.StatusQueued {
+		p.logger.Info("Skipping job leased by another worker", zap.String("job_id", jobID))
+		return
+	}
+
+	p.logger.Info("Queued job is leased by another worker, enqueueing it again later", zap.String("job_id", jobID))
+	time.AfterFunc(p.leaseTTL, func() {
+		if err := p.queue.Enqueue(context.Background(), jobID); err != nil {
+			p.logger.Error("Failed to enqueue leased job again", zap.String("job_id", jobID), zap.Error(err))
+		}
+	})
+}
+
+// publishProgress passes a job's stored progress to its subscribers
+func (p *WorkerPool) publishProgress(job *domain.EncryptionJob) {
+	if p.progress == nil {
+		return
+	}
+	if err := p.progress.UpdateProgress(context.Background(), job.ID, job.Progress); err != nil {
+		p.logger.Warn("Failed to publish job progress", zap.String("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// publishOutcome queues the webhook event for a finished job
+func (p *WorkerPool) publishOutcome(ctx context.Context, job *domain.EncryptionJob) {
+	publishJobOutcome(ctx, p.events, job, p.clock.Now(), p.logger)
+}
+
+// publishJobOutcome queues the webhook event for a finished job if events is set
+func publishJobOutcome(ctx context.Context, events ports.EventQueue, job *domain.EncryptionJob, now time.Time, logger *zap.Logger) {
+	if eventType, ok := domain.JobOutcomeEvent(job); ok {
+		publishEvent(ctx, events, domain.NewJobEvent(eventType, job, now), logger)
+	}
+}
+
+// publishEvent queues a webhook event if events is set. Events are published
+// once the change they describe is stored, and failing to publish one does
+// not undo the change.
+func publishEvent(ctx context.Context, events ports.EventQueue, payload domain.WebhookPayload, logger *zap.Logger) {
+	if events == nil {
+		return
+	}
+	if err := events.Publish(ctx, domain.QueuedEvent{Payload: payload}); err != nil {
+		logger.Warn("Failed to publish webhook event",
+			zap.String("event", string(payload.EventType)),
+			zap.String("job_id", payload.JobID),
+			zap.String("batch_id", payload.BatchID),
+			zap.Error(err))
+	}
+}
+
+// encrypt fetches the job source, encrypts it to a scratch file and stores the
+// output in the output storage, returning the job result and decryption key.
+// Progress updates check that the job is still in progress and call abort if
+// it was moved to another state.
+func (p *WorkerPool) encrypt(ctx context.Context, abort context.CancelFunc, job *domain.EncryptionJob) (*domain.JobResult, string, error) {
+	// Jobs submitted before engine parameters were recorded use the defaults
+	params := job.Engine.WithDefaults()
+	result := &domain.JobResult{
+		Algorithm:  params.Algorithm,
+		KeyLength:  params.KeyLength,
+		ChunkSize:  params.ChunkSize,
+		IVStrategy: params.IVStrategy,
+		Container:  params.Container,
+	}
+	start := p.clock.Now()
+
+	// The checkpoint is kept with every update until the job moves past it.
+	// Jobs watermarking or transcoding before they encrypt also report each
+	// stage's percent.
+	checkpoint := job.Progress.Checkpoint
+	persist := p.progressUpdater(job, abort)
+	processors := p.sourceProcessors(job)
+	watermarks := false
+	for _, processor := range processors {
+		watermarks = watermarks || processor.Stage() == domain.StageWatermarking
+	}
+	drm := job.Transcode != nil && job.Transcode.DRM != ""
+	var (
+		stageMu       sync.Mutex
+		stageProgress = domain.NewStageProgress(watermarks, job.Transcode != nil, !drm)
+	)
+	update := func(progress domain.Progress) {
+		progress.Checkpoint = checkpoint
+		if stageProgress.Tracked() {
+			stageMu.Lock()
+			stageProgress = stageProgress.Advance(progress)
+			progress.Stages = stageProgress
+			stageMu.Unlock()
+		}
+		persist(progress)
+	}
+
+	// Unsupported sources fail before anything is fetched or written
+	if p.prober != nil && job.Media == nil {
+		media, err := probeMedia(ctx, p.prober, p.mediaPolicy, job.SourceURL)
+		if err != nil {
+			return nil, "", err
+		}
+		job.Media = media
+		result.Timings.Probe = domain.Duration(p.clock.Now().Sub(start))
+		update(domain.Progress{Stage: domain.StageFetching})
+	}
+
+	var src io.ReadCloser
+	var size int64
+	var err error
+	var hashed *hashingReader
+
+	// Processed sources are read from the job's workspace in place of the
+	// original
+	sourceURL, processed := job.SourceURL, ""
+	if len(processors) > 0 {
+		processStart := p.clock.Now()
+		if processed, hashed, err = p.processSource(ctx, job, processors, update); err != nil {
+			return nil, "", err
+		}
+		sourceURL = "file://" + processed
+		result.Timings.Process = domain.Duration(p.clock.Now().Sub(processStart))
+	} else if job.Watermark != nil {
+		return nil, "", fmt.Errorf("%w: watermarking is not enabled on this worker", domain.ErrWatermarkFailed)
+	}
+
+	if drm {
+		return p.packageDRM(ctx, job, sourceURL, update, result, start)
+	} else if job.Transcode != nil {
+		// The renditions are encrypted in place of the source
+		src, size, err = p.transcode(ctx, job, sourceURL, nil, update)
+		if err != nil {
+			return nil, "", err
+		}
+		result.Timings.Transcode = domain.Duration(p.clock.Now().Sub(start) - result.Timings.Probe.Std() - result.Timings.Process.Std())
+	} else if processed != "" {
+		if src, size, err = openFile(processed); err != nil {
+			return nil, "", err
+		}
+	} else if streaming, ok := p.streamingEngine(job); ok {
+		return p.encryptCheckpointed(ctx, job, streaming, &checkpoint, update, result, start)
+	} else {
+		fetchStart := p.clock.Now()
+		src, size, err = p.fetcher.Open(ctx, job.SourceURL)
+		if err != nil {
+			return nil, "", err
+		}
+		result.Timings.Fetch = domain.Duration(p.clock.Now().Sub(fetchStart))
+		hashed = newHashingReader(src)
+		src = hashed
+	}
+	defer src.Close()
+
+	// Checkpoints of a job that is no longer checkpointed, e.g. because
+	// checkpointing was turned off, are of no use
+	if checkpoint != nil {
+		p.deleteSegments(job.ID, checkpoint)
+		checkpoint = nil
+	}
+
+	if len(job.Outputs) > 0 {
+		return hashed.record(p.encryptOutputs(ctx, job, src, size, update, result.Timings, start))
+	}
+	return hashed.record(p.encryptSingle(ctx, job, src, size, params, update, result, start))
+}
+
+// encryptSingle encrypts an opened source into the job's one output
+func (p *WorkerPool) encryptSingle(ctx context.Context, job *domain.EncryptionJob, src io.Reader, size int64, params domain.EngineParams, update func(domain.Progress), result *domain.JobResult, start time.Time) (*domain.JobResult, string, error) {
+	customerKey, err := p.customerKey(ctx, job)
+	if err != nil {
+		return nil, "", err
+	}
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, update)
+	reader.media = job.Media
+	update(reader.snapshot(p.clock.Now()))
+
+	// Once the engine has sealed the last chunk only the storage is left to
+	// finish
+	encrypted := func() {
+		progress := reader.snapshot(p.clock.Now())
+		progress.Stage = domain.StageStoring
+		progress.ETA = 0
+		update(progress)
+	}
+	storage, outputPath, err := p.outputLocation(job, job.ID+".enc")
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := p.streamOutput(storage, outputPath, reader, customerKey, params, result, encrypted)
+	if err != nil {
+		return nil, "", err
+	}
+	result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+
+	return result, key, nil
+}
+
+// decrypt fetches the encrypted source of a decryption job and streams its
+// plaintext into the output storage. The key is read from the encryption job
+// the decryption job names, and must still be the key it was created with.
+func (p *WorkerPool) decrypt(ctx context.Context, abort context.CancelFunc, job *domain.EncryptionJob) (*domain.JobResult, error) {
+	if job.Decryption == nil {
+		return nil, fmt.Errorf("%w: decryption job %s names no key", domain.ErrKeyUnavailable, job.ID)
+	}
+	start := p.clock.Now()
+	update := p.progressUpdater(job, abort)
+
+	keyJob, err := p.repository.Get(ctx, job.Decryption.KeyJobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job %s: %w", job.Decryption.KeyJobID, err)
+	}
+	if keyJob == nil {
+		return nil, fmt.Errorf("%w: job %s no longer exists", domain.ErrKeyUnavailable, job.Decryption.KeyJobID)
+	}
+	_, stored, err := keyJob.DecryptionSource(job.Decryption.Output)
+	if err != nil {
+		return nil, err
+	}
+	key, err := openKey(ctx, p.keys, domain.ContentKey{JobID: keyJob.ID, Output: job.Decryption.Output}, stored)
+	if err != nil {
+		return nil, err
+	}
+	if keyRef(key) != job.Decryption.KeyRef {
+		return nil, fmt.Errorf("%w: the key of job %s no longer matches key_ref", domain.ErrKeyUnavailable, keyJob.ID)
+	}
+
+	result := &domain.JobResult{
+		Algorithm:  job.Engine.Algorithm,
+		KeyLength:  job.Engine.KeyLength,
+		ChunkSize:  job.Engine.ChunkSize,
+		IVStrategy: job.Engine.IVStrategy,
+		Container:  job.Engine.Container,
+		KeyRef:     job.Decryption.KeyRef,
+	}
+
+	fetchStart := p.clock.Now()
+	src, size, err := p.fetcher.Open(ctx, job.SourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	result.Timings.Fetch = domain.Duration(p.clock.Now().Sub(fetchStart))
+
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, update)
+	reader.stage = domain.StageDecrypting
+	update(reader.snapshot(p.clock.Now()))
+
+	decrypted := func() {
+		progress := reader.snapshot(p.clock.Now())
+		progress.Stage = domain.StageStoring
+		progress.ETA = 0
+		update(progress)
+	}
+	storage, outputPath, err := p.outputLocation(job, job.ID+".dec")
+	if err != nil {
+		return nil, err
+	}
+	elapsed, err := p.streamToStorage(storage, outputPath, result, "decryption", decrypted, func(output io.Writer) error {
+		return p.engine.Decrypt(reader, output, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Timings.Decrypt = domain.Duration(elapsed)
+	result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+
+	return result, nil
+}
+
+// progressUpdater returns a function that persists the job's progress,
+// aborting the job instead if it was moved out of IN_PROGRESS
+func (p *WorkerPool) progressUpdater(job *domain.EncryptionJob, abort context.CancelFunc) func(domain.Progress) {
+	return func(progress domain.Progress) {
+		current, err := p.repository.Get(context.Background(), job.ID)
+		if err == nil && current != nil {
+			if !isRunOf(job)(current) {
+				abort()
+				return
+			}
+			// Keep metadata changes made while the job runs
+			job.Metadata = current.Metadata
+		}
+
+		if job.StartedAt != 0 {
+			progress.Elapsed = domain.Duration(p.clock.Now().Sub(time.Unix(job.StartedAt, 0)).Round(time.Second))
+		}
+		job.Progress = progress
+		job.UpdatedAt = p.clock.Now().Unix()
+		job.HeartbeatAt = job.UpdatedAt // Progress doubles as the worker's heartbeat
+		// A stop or pause since the check above wins over the progress
+		updated, err := p.repository.UpdateIf(context.Background(), job, isRunOf(job))
+		if err != nil {
+			p.logger.Warn("Failed to update job progress", zap.String("job_id", job.ID), zap.Error(err))
+			return
+		}
+		if !updated {
+			abort()
+			return
+		}
+		p.publishProgress(job)
+	}
+}
+
+// stageReporter returns a function reporting the fraction done of a stage
+// that began at start. Progress is persisted at most once per interval, like
+// the encryption stage's; ffmpeg reports several times a second.
+func (p *WorkerPool) stageReporter(job *domain.EncryptionJob, stage domain.ProgressStage, start time.Time, update func(domain.Progress)) func(float64) {
+	var mu sync.Mutex
+	last := start
+	return func(done float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := p.clock.Now()
+		if now.Sub(last) < p.config.ProgressInterval {
+			return
+		}
+		last = now
+		progress := domain.Progress{Stage: stage, Percent: math.Min(done*100, 99), Position: job.Media.Position(done)}
+		if done > 0 {
+			elapsed := now.Sub(start)
+			progress.ETA = domain.Duration(time.Duration(float64(elapsed) / done * (1 - done)).Round(time.Second))
+		}
+		update(progress)
+	}
+}
+
+// transcode converts the source at sourceURL, the job's or its processed
+// copy, to the job's renditions, protected with key for DRM packaged jobs,
+// reporting the transcoding stage's progress, and records the finished stage
+// in the job's history
+func (p *WorkerPool) transcode(ctx context.Context, job *domain.EncryptionJob, sourceURL string, key *domain.DRMKey, update func(domain.Progress)) (io.ReadCloser, int64, error) {
+	if p.transcoder == nil {
+		return nil, 0, fmt.Errorf("%w: transcoding is not enabled on this worker", domain.ErrTranscodeFailed)
+	}
+
+	start := p.clock.Now()
+	update(domain.Progress{Stage: domain.StageTranscoding})
+	report := p.stageReporter(job, domain.StageTranscoding, start, update)
+
+	var workspace string
+	if p.workspaces != nil {
+		var err error
+		if workspace, err = p.workspaces.Acquire(job.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+	src, size, err := p.transcoder.Transcode(ctx, workspace, sourceURL, *job.Transcode, key, report)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	renditions := make([]string, len(job.Transcode.Renditions))
+	for i, r := range job.Transcode.Renditions {
+		renditions[i] = r.Name
+	}
+	job.RecordStage(domain.StageTranscoding, map[string]interface{}{
+		"format":     job.Transcode.Format,
+		"renditions": renditions,
+		"size":       size,
+		"duration":   p.clock.Now().Sub(start).String(),
+	}, p.clock.Now())
+	return src, size, nil
+}
+
+// encryptOutputs encrypts one download of the source for every output of a
+// multi-output job. The outputs read the source through pipes fed from a
+// single reader and are encrypted concurrently, each streaming into storage,
+// so a failing output does not stop the others. It returns the
+// primary output's result and key, or an error naming the outputs that failed.
+func (p *WorkerPool) encryptOutputs(ctx context.Context, job *domain.EncryptionJob, src io.Reader, size int64, update func(domain.Progress), timings domain.StageTimings, start time.Time) (*domain.JobResult, string, error) {
+	// Outputs finish on their own goroutines; mu serializes changes to the job
+	var mu sync.Mutex
+	counters := make([]*countingReader, len(job.Outputs))
+	for i := range job.Outputs {
+		counters[i] = &countingReader{}
+		job.Outputs[i].Status = domain.StatusProgress
+		job.Outputs[i].Progress = domain.Progress{Stage: domain.StageEncrypting, BytesTotal: size}
+	}
+
+	// Sources of unknown size are measured against their size estimate, as
+	// for the job's progress
+	total := size
+	if total <= 0 {
+		total = job.Media.EstimatedSize()
+	}
+
+	// report refreshes the progress of every output still encrypting along
+	// with the job's progress
+	report := func(progress domain.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range job.Outputs {
+			out := &job.Outputs[i]
+			if out.Status == domain.StatusProgress && out.Progress.Stage == domain.StageEncrypting {
+				out.Progress.BytesProcessed = counters[i].read.Load()
+				if total > 0 {
+					fraction := float64(out.Progress.BytesProcessed) / float64(total)
+					out.Progress.Percent = math.Min(fraction*100, 99)
+					out.Progress.Position = job.Media.Position(fraction)
+				}
+			}
+		}
+		update(progress)
+	}
+
+	reader := newProgressReader(ctx, src, size, p.config.ProgressInterval, p.clock, report)
+	reader.media = job.Media
+	report(reader.snapshot(p.clock.Now()))
+
+	var wg sync.WaitGroup
+	pipes := make([]*io.PipeWriter, len(job.Outputs))
+	keys := make([]string, len(job.Outputs))
+	for i, out := range job.Outputs {
+		pr, pw := io.Pipe()
+		pipes[i] = pw
+		counters[i].reader = pr
+
+		wg.Add(1)
+		go func(i int, name string, params domain.EngineParams) {
+			defer wg.Done()
+
+			storing := func() {
+				mu.Lock()
+				defer mu.Unlock()
+				job.Outputs[i].Progress.Stage = domain.StageStoring
+				job.Outputs[i].Progress.BytesProcessed = counters[i].read.Load()
+				update(job.Progress)
+			}
+			result, key, err := p.encryptOutput(job, name, params, counters[i], storing)
+			if err != nil {
+				// Unblocks the fan-out so the remaining outputs keep going
+				pr.CloseWithError(err)
+			}
+			// Sealed before the output is recorded, so its key is never
+			// persisted in the clear
+			var stored domain.StoredKey
+			if err == nil {
+				keys[i] = key
+				stored, err = sealKey(ctx, p.keys, domain.ContentKey{JobID: job.ID, Output: name}, key)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			output := &job.Outputs[i]
+			if err != nil {
+				output.Status = domain.StatusFailed
+				output.Error = err.Error()
+			} else {
+				result.Timings.Probe = timings.Probe
+				result.Timings.Fetch = timings.Fetch
+				result.Timings.Transcode = timings.Transcode
+				result.Timings.Total = domain.Duration(p.clock.Now().Sub(start))
+				output.Status = domain.StatusCompleted
+				output.Result = result
+				output.SetStoredKey(stored)
+				output.Progress = domain.Progress{
+					Stage:          domain.StageDone,
+					Percent:        100,
+					BytesProcessed: counters[i].read.Load(),
+					BytesTotal:     size,
+				}
+			}
+			update(job.Progress)
+		}(i, out.Name, out.Engine.WithDefaults())
+	}
+
+	// Fan the source out to every output that is still reading
+	buf := make([]byte, 32<<10)
+	var readErr error
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			live := 0
+			for i, pw := range pipes {
+				if pw == nil {
+					continue
+				}
+				if _, err := pw.Write(buf[:n]); err != nil {
+					pipes[i] = nil
+					continue
+				}
+				live++
+			}
+			if live == 0 {
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	for _, pw := range pipes {
+		if pw != nil {
+			pw.CloseWithError(readErr) // A nil error ends the output's input cleanly
+		}
+	}
+	wg.Wait()
+
+	if readErr != nil {
+		return nil, "", readErr
+	}
+	var failed []string
+	for _, out := range job.Outputs {
+		if out.Status == domain.StatusFailed {
+			failed = append(failed, out.Name+": "+out.Error)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, "", fmt.Errorf("%d of %d outputs failed: %s", len(failed), len(job.Outputs), strings.Join(failed, "; "))
+	}
+	return job.Outputs[0].Result, keys[0], nil
+}
+
+// encryptOutput encrypts input for one output of a multi-output job and stores
+// it, calling storing once encryption has finished
+func (p *WorkerPool) encryptOutput(job *domain.EncryptionJob, name string, params domain.EngineParams, input io.Reader, storing func()) (*domain.JobResult, string, error) {
+	result := &domain.JobResult{
+		Algorithm:  params.Algorithm,
+		KeyLength:  params.KeyLength,
+		ChunkSize:  params.ChunkSize,
+		IVStrategy: params.IVStrategy,
+		Container:  params.Container,
+	}
+
+	storage, outputPath, err := p.outputLocation(job, job.ID+"."+name+".enc")
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := p.streamOutput(storage, outputPath, input, "", params, result, storing)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, key, nil
+}
+
+// streamOutput encrypts input straight into storage at outputPath, with key or a generated key when key is "". encrypted is
+// called once the engine is done and only the storage write is left to
+// finish.
+func (p *WorkerPool) streamOutput(storage ports.FileStorage, outputPath string, input io.Reader, key string, params domain.EngineParams, result *domain.JobResult, encrypted func()) (string, error) {
+	elapsed, err := p.streamToStorage(storage, outputPath, result, "encryption", encrypted, func(output io.Writer) error {
+		if key != "" {
+			return p.engine.EncryptWithKey(input, output, key, params)
+		}
+		var err error
+		key, err = p.engine.Encrypt(input, output, params)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	result.Timings.Encrypt = domain.Duration(elapsed)
+	result.KeyRef = keyRef(key)
+	return key, nil
+}
+
+// streamToStorage runs the engine through a pipe straight into storage at
+// outputPath, so the output is never buffered or copied to a
+// scratch file. The output is hashed and measured as it passes through, and
+// recorded in result; done is called once run has returned and only the
+// storage write is left to finish. It returns how long run took.
+func (p *WorkerPool) streamToStorage(storage ports.FileStorage, outputPath string, result *domain.JobResult, operation string, done func(), run func(output io.Writer) error) (time.Duration, error) {
+	pr, pw := io.Pipe()
+	stored := make(chan error, 1)
+	go func() {
+		err := storage.WriteFile(outputPath, pr)
+		// Unblocks the engine if the storage stopped reading early
+		pr.CloseWithError(err)
+		stored <- err
+	}()
+
+	runStart := p.clock.Now()
+	digest := sha256.New()
+	output := &countingWriter{writer: io.MultiWriter(pw, digest)}
+	if err := run(output); err != nil {
+		pw.CloseWithError(err)
+		if storeErr := <-stored; storeErr != nil && errors.Is(err, io.ErrClosedPipe) {
+			return 0, fmt.Errorf("failed to store output: %w", storeErr)
+		}
+		return 0, fmt.Errorf("%s failed: %w", operation, err)
+	}
+	pw.Close()
+	elapsed := p.clock.Now().Sub(runStart)
+	done()
+
+	storeStart := p.clock.Now()
+	if err := <-stored; err != nil {
+		return 0, fmt.Errorf("failed to store output: %w", err)
+	}
+	result.Timings.Store = domain.Duration(p.clock.Now().Sub(storeStart))
+	result.Size = output.written
+	result.Checksum = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+	result.OutputPath = outputPath
+	result.OutputURL = storage.URL(outputPath)
+	return elapsed, nil
+}
+
+// keyRef identifies a key by a short fingerprint, so results can refer to it
+// without revealing it
+func keyRef(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.writer.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// hashingReader digests the source bytes read through it, so a source is
+// hashed as it is encrypted rather than read twice
+type hashingReader struct {
+	io.ReadCloser
+	digest hash.Hash
+}
+
+func newHashingReader(src io.ReadCloser) *hashingReader {
+	return &hashingReader{ReadCloser: src, digest: sha256.New()}
+}
+
+func (r *hashingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.digest.Write(b[:n])
+	return n, err
+}
+
+// record sets the source hash of a successful encryption's result, once its
+// source has been read whole. A nil reader, for sources that were not hashed,
+// leaves the result alone.
+func (r *hashingReader) record(result *domain.JobResult, key string, err error) (*domain.JobResult, string, error) {
+	if r != nil && err == nil {
+		result.SourceHash = domain.SourceHash(r.digest.Sum(nil))
+	}
+	return result, key, err
+}
+
+// countingReader counts the bytes read through it; the count may be read
+// concurrently
+type countingReader struct {
+	reader io.Reader
+	read   atomic.Int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+// throughputSmoothing weighs the latest interval's rate against the running
+// throughput, so the ETA follows speed changes without jumping around
+const throughputSmoothing = 0.3
+
+// progressReader counts bytes read from the source, reports progress at most
+// once per interval and stops reading when its context is cancelled
+type progressReader struct {
+	ctx        context.Context
+	clock      ports.Clock
+	reader     io.Reader
+	total      int64
+	read       int64
+	media      *domain.MediaInfo // Probed source, whose size estimate stands in for an unknown total
+	interval   time.Duration
+	lastReport time.Time
+	lastRead   int64
+	throughput float64
+	report     func(progress domain.Progress)
+	stage      domain.ProgressStage // Reported stage; StageEncrypting when empty
+}
+
+func newProgressReader(ctx context.Context, reader io.Reader, total int64, interval time.Duration, clock ports.Clock, report func(domain.Progress)) *progressReader {
+	return &progressReader{
+		ctx:        ctx,
+		clock:      clock,
+		reader:     reader,
+		total:      total,
+		interval:   interval,
+		lastReport: clock.Now(),
+		report:     report,
+	}
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.reader.Read(b)
+	r.read += int64(n)
+
+	if now := r.clock.Now(); now.Sub(r.lastReport) >= r.interval {
+		r.measure(now)
+		r.report(r.snapshot(now))
+	}
+
+	return n, err
+}
+
+// measure folds the rate since the last report into the throughput
+func (r *progressReader) measure(now time.Time) {
+	elapsed := now.Sub(r.lastReport).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	rate := float64(r.read-r.lastRead) / elapsed
+	if r.throughput == 0 {
+		r.throughput = rate
+	} else {
+		r.throughput = throughputSmoothing*rate + (1-throughputSmoothing)*r.throughput
+	}
+	r.lastReport, r.lastRead = now, r.read
+}
+
+// snapshot returns the progress of the encryption or decryption stage
+func (r *progressReader) snapshot(now time.Time) domain.Progress {
+	stage := r.stage
+	if stage == "" {
+		stage = domain.StageEncrypting
+	}
+	progress := domain.Progress{
+		Stage:          stage,
+		BytesProcessed: r.read,
+		Throughput:     r.throughput,
+	}
+	total := r.total
+	if total > 0 {
+		progress.BytesTotal = total
+	} else {
+		// Streamed sources of unknown size are measured against what their
+		// bitrate and duration imply
+		total = r.media.EstimatedSize()
+	}
+	if total > 0 {
+		fraction := float64(r.read) / float64(total)
+		progress.Percent = math.Min(fraction*100, 99) // 100 is reserved for a stored output
+		progress.Position = r.media.Position(fraction)
+		if r.throughput > 0 && r.read < total {
+			progress.ETA = domain.Duration(math.Ceil(float64(total-r.read)/r.throughput) * float64(time.Second))
+		}
+	}
+	return progress
+}
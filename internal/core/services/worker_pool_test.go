@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/secondary/storage"
+	"E.E/pkg/clock"
+)
+
+// benchmarkOutputSizes are the sizes of engine output the storage benchmarks
+// store
+var benchmarkOutputSizes = []int{1 << 20, 16 << 20}
+
+// engineChunk is how much a benchmark engine writes at a time, as the AEAD
+// engine writes one sealed chunk at a time
+const engineChunk = 64 << 10
+
+// BenchmarkStreamToStorage measures storing engine output through the pipe
+// straight into storage, as workers do
+func BenchmarkStreamToStorage(b *testing.B) {
+	p, store := benchmarkWorker(b)
+	for _, size := range benchmarkOutputSizes {
+		payload := benchmarkPayload(b, size)
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result := &domain.JobResult{}
+				_, err := p.streamToStorage(store, "bench.enc", result, "encryption", func() {}, func(output io.Writer) error {
+					return writeChunks(output, payload)
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScratchToStorage measures storing engine output by way of a
+// scratch file, as workers did before output was streamed
+func BenchmarkScratchToStorage(b *testing.B) {
+	_, store := benchmarkWorker(b)
+	scratch := b.TempDir()
+	for _, size := range benchmarkOutputSizes {
+		payload := benchmarkPayload(b, size)
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result := &domain.JobResult{}
+				err := scratchToStorage(store, scratch, "bench.enc", result, func(output io.Writer) error {
+					return writeChunks(output, payload)
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// scratchToStorage writes run's output to a scratch file, hashing and
+// measuring it, then copies the file into storage
+func scratchToStorage(store ports.FileStorage, scratch, outputPath string, result *domain.JobResult, run func(output io.Writer) error) error {
+	tmp, err := os.CreateTemp(scratch, "bench-*.enc")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digest := sha256.New()
+	output := &countingWriter{writer: io.MultiWriter(tmp, digest)}
+	if err := run(output); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := store.WriteFile(outputPath, tmp); err != nil {
+		return err
+	}
+	result.Size = output.written
+	result.Checksum = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+	result.OutputPath = outputPath
+	return nil
+}
+
+func benchmarkWorker(b *testing.B) (*WorkerPool, ports.FileStorage) {
+	b.Helper()
+	store, err := storage.NewLocalStorage(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &WorkerPool{clock: clock.System{}, logger: zap.NewNop()}, store
+}
+
+func benchmarkPayload(b *testing.B, size int) []byte {
+	b.Helper()
+	payload := make([]byte, size)
+	if _, err := rand.Read(payload); err != nil {
+		b.Fatal(err)
+	}
+	return payload
+}
+
+// writeChunks writes payload to output engineChunk bytes at a time
+func writeChunks(output io.Writer, payload []byte) error {
+	for len(payload) > 0 {
+		n := min(engineChunk, len(payload))
+		if _, err := output.Write(payload[:n]); err != nil {
+			return err
+		}
+		payload = payload[n:]
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/metrics"
+)
+
+// WorkspaceJanitor periodically removes the job workspaces no worker is using
+// any more, such as those of jobs whose process crashed mid-run. Workspaces
+// this process holds, and those of jobs still running, are kept.
+type WorkspaceJanitor struct {
+	workspaces ports.Workspaces
+	repository ports.JobRepository
+	interval   time.Duration
+	metrics    *metrics.Metrics
+	logger     *zap.Logger
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewWorkspaceJanitor(workspaces ports.Workspaces, repository ports.JobRepository, interval time.Duration, logger *zap.Logger) *WorkspaceJanitor {
+	return &WorkspaceJanitor{
+		workspaces: workspaces,
+		repository: repository,
+		interval:   interval,
+		logger:     logger,
+	}
+}
+
+// SetMetrics makes the janitor record the disk usage of the workspaces it
+// keeps
+func (j *WorkspaceJanitor) SetMetrics(m *metrics.Metrics) {
+	j.metrics = m
+}
+
+// Start sweeps the workspaces every interval in the background
+func (j *WorkspaceJanitor) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	j.stop = stop
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := j.Sweep(ctx); err != nil && ctx.Err() == nil {
+					j.logger.Error("Failed to sweep job workspaces", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	j.logger.Info("Started workspace janitor", zap.Duration("interval", j.interval))
+}
+
+// Stop ends background sweeping
+func (j *WorkspaceJanitor) Stop() {
+	if j.stop == nil {
+		return
+	}
+	j.stop()
+	<-j.done
+}
+
+// Sweep removes the orphaned workspaces, those whose job is gone or no longer
+// running, returning how many were removed
+func (j *WorkspaceJanitor) Sweep(ctx context.Context) (int, error) {
+	workspaces, err := j.workspaces.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var kept int64
+	for _, workspace := range workspaces {
+		if !workspace.Held {
+			job, err := j.repository.Get(ctx, workspace.JobID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return removed, ctx.Err()
+				}
+				j.logger.Warn("Failed to load job of workspace",
+					zap.String("job_id", workspace.JobID),
+					zap.Error(err))
+			} else if job == nil || job.Status != domain.StatusProgress {
+				if err := j.workspaces.Release(workspace.JobID); err != nil {
+					j.logger.Warn("Failed to remove orphaned workspace",
+						zap.String("job_id", workspace.JobID),
+						zap.Error(err))
+				} else {
+					removed++
+					continue
+				}
+			}
+		}
+		kept += workspace.Bytes
+	}
+
+	if j.metrics != nil {
+		j.metrics.SetWorkspaceBytes(kept)
+	}
+	if removed > 0 {
+		j.logger.Info("Removed orphaned job workspaces", zap.Int("count", removed))
+	}
+	return removed, nil
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/primary/grpc/eev1"
+)
+
+// invalidArguments are the errors of requests the caller must change
+var invalidArguments = []error{
+	domain.ErrInvalidMetadata,
+	domain.ErrInvalidOutputs,
+	domain.ErrInvalidTranscode,
+	domain.ErrInvalidWatermark,
+	domain.ErrInvalidEngineParams,
+	domain.ErrInvalidCustomerKey,
+	domain.ErrInvalidDestination,
+	domain.ErrUnsupportedMedia,
+	domain.ErrSourceRejected,
+}
+
+// statusError returns the gRPC status of an error of the service layer,
+// using the same classes as the HTTP API's status codes
+func statusError(err error) error {
+	var (
+		validationErrs domain.ValidationErrors
+		stateErr       *domain.JobStateError
+		quotaErr       *domain.QuotaExceededError
+	)
+	switch {
+	case errors.Is(err, domain.ErrJobNotFound), errors.Is(err, domain.ErrBatchNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &validationErrs):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &stateErr), errors.Is(err, domain.ErrKeyUnavailable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &quotaErr):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, domain.ErrNotAcceptingJobs):
+		return status.Error(codes.Unavailable, err.Error())
+	case strings.Contains(err.Error(), "invalid sort"):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	for _, invalid := range invalidArguments {
+		if errors.Is(err, invalid) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// engineParams returns the engine parameters of a request, nil for the
+// server's defaults
+func engineParams(in *eev1.EngineParams) *domain.EngineParams {
+	if in == nil {
+		return nil
+	}
+	return &domain.EngineParams{
+		Algorithm:  in.GetAlgorithm(),
+		ChunkSize:  int(in.GetChunkSize()),
+		IVStrategy: in.GetIvStrategy(),
+	}
+}
+
+// toJob returns the gRPC form of a job, which never carries its key
+func toJob(job *domain.EncryptionJob) *eev1.Job {
+	kind := job.Kind
+	if kind == "" {
+		kind = domain.JobKindEncrypt
+	}
+	return &eev1.Job{
+		Id:         job.ID,
+		Kind:       kind,
+		SourceUrl:  job.SourceURL,
+		Status:     string(job.Status),
+		Progress:   toProgress(job.Progress),
+		OutputPath: job.OutputPath,
+		Error:      job.Error,
+		ErrorCode:  job.ErrorCode,
+		CreatedBy:  job.CreatedBy,
+		Tenant:     job.Tenant,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		ExpiresAt:  job.ExpiresAt,
+		Metadata:   job.Metadata,
+		RetryOf:    job.RetryOf,
+		RetriedBy:  job.RetriedBy,
+	}
+}
+
+func toProgress(p domain.Progress) *eev1.Progress {
+	progress := &eev1.Progress{
+		Percent:         p.Percent,
+		Stage:           string(p.Stage),
+		BytesProcessed:  p.BytesProcessed,
+		BytesTotal:      p.BytesTotal,
+		ThroughputBps:   p.Throughput,
+		EtaSeconds:      p.ETASeconds(),
+		PositionSeconds: p.Position.Std().Seconds(),
+		ElapsedSeconds:  int64(p.Elapsed.Std().Seconds()),
+		ThroughputMbps:  p.ThroughputMbps(),
+	}
+	// Stored jobs keep only the percents of the stages they have started
+	if s := p.Stages; s.Tracked() || s.Watermarking > 0 || s.Transcoding > 0 || s.Encrypting > 0 {
+		progress.Stages = &eev1.StageProgress{
+			Transcoding:  p.Stages.Transcoding,
+			Encrypting:   p.Stages.Encrypting,
+			Watermarking: p.Stages.Watermarking,
+		}
+	}
+	return progress
+}
+
+func toBatchResult(r *domain.BatchResult) *eev1.BatchResult {
+	result := &eev1.BatchResult{
+		BatchId:       r.BatchID,
+		ParentBatchId: r.ParentBatchID,
+		Action:        string(r.Action),
+		CreatedBy:     r.CreatedBy,
+		Tenant:        r.Tenant,
+		StartTime:     r.StartTime.Unix(),
+		EndTime:       r.EndTime.Unix(),
+		Successful:    r.Successful,
+		TotalJobs:     int32(r.Summary.TotalJobs),
+		SuccessCount:  int32(r.Summary.SuccessCount),
+		FailureCount:  int32(r.Summary.FailureCount),
+	}
+	for _, failed := range r.Failed {
+		result.Failed = append(result.Failed, &eev1.BatchJobError{JobId: failed.JobID, Error: failed.Error})
+	}
+	return result
+}
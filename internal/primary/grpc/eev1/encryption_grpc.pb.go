@@ -0,0 +1,386 @@
+// gRPC contract of the encryption service, for internal callers that would
+// rather not speak JSON over HTTP. It mirrors /api/v1 and is served alongside
+// it at server.grpc_address from the same service layer, with the same API
+// keys (sent as "authorization: Bearer <key>" or "x-api-key" metadata),
+// scopes and tenants.
+//
+// The stubs in internal/primary/grpc/eev1 are generated from this file with
+// protoc-gen-go and protoc-gen-go-grpc (go generate ./internal/primary/grpc).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: ee/v1/encryption.proto
+
+package eev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EncryptionService_StartEncryption_FullMethodName = "/ee.v1.EncryptionService/StartEncryption"
+	EncryptionService_GetStatus_FullMethodName       = "/ee.v1.EncryptionService/GetStatus"
+	EncryptionService_ListJobs_FullMethodName        = "/ee.v1.EncryptionService/ListJobs"
+	EncryptionService_ProcessBatch_FullMethodName    = "/ee.v1.EncryptionService/ProcessBatch"
+	EncryptionService_GetBatch_FullMethodName        = "/ee.v1.EncryptionService/GetBatch"
+	EncryptionService_WatchJob_FullMethodName        = "/ee.v1.EncryptionService/WatchJob"
+	EncryptionService_RetryJob_FullMethodName        = "/ee.v1.EncryptionService/RetryJob"
+)
+
+// EncryptionServiceClient is the client API for EncryptionService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EncryptionServiceClient interface {
+	// StartEncryption queues a job encrypting a source, like POST /api/v1/encrypt
+	StartEncryption(ctx context.Context, in *StartEncryptionRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetStatus returns a job, like GET /api/v1/status/:jobId
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Job, error)
+	// ListJobs returns a page of the caller's tenant's jobs, like GET /api/v1/jobs
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// ProcessBatch starts, pauses, resumes or stops jobs in bulk, recording the
+	// operation as a batch. It also stands in for POST /api/v1/job/:jobId/pause,
+	// /resume, /stop and /cancel, an alias of /stop, with a single job ID.
+	ProcessBatch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResult, error)
+	// GetBatch returns the result of a batch operation, like
+	// GET /api/v1/batch/:batchId
+	GetBatch(ctx context.Context, in *GetBatchRequest, opts ...grpc.CallOption) (*BatchResult, error)
+	// WatchJob streams a job's state each time its progress or status changes,
+	// like GET /api/v1/status/:jobId/events, and ends once the job finishes
+	WatchJob(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error)
+	// RetryJob queues a failed job again as a new job, like
+	// POST /api/v1/job/:jobId/retry, and returns the new job
+	RetryJob(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type encryptionServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEncryptionServiceClient(cc grpc.ClientConnInterface) EncryptionServiceClient {
+	return &encryptionServiceClient{cc}
+}
+
+func (c *encryptionServiceClient) StartEncryption(ctx context.Context, in *StartEncryptionRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, EncryptionService_StartEncryption_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *encryptionServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, EncryptionService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *encryptionServiceClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, EncryptionService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *encryptionServiceClient) ProcessBatch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResult)
+	err := c.cc.Invoke(ctx, EncryptionService_ProcessBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *encryptionServiceClient) GetBatch(ctx context.Context, in *GetBatchRequest, opts ...grpc.CallOption) (*BatchResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResult)
+	err := c.cc.Invoke(ctx, EncryptionService_GetBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *encryptionServiceClient) WatchJob(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EncryptionService_ServiceDesc.Streams[0], EncryptionService_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetStatusRequest, Job]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EncryptionService_WatchJobClient = grpc.ServerStreamingClient[Job]
+
+func (c *encryptionServiceClient) RetryJob(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, EncryptionService_RetryJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EncryptionServiceServer is the server API for EncryptionService service.
+// All implementations must embed UnimplementedEncryptionServiceServer
+// for forward compatibility.
+type EncryptionServiceServer interface {
+	// StartEncryption queues a job encrypting a source, like POST /api/v1/encrypt
+	StartEncryption(context.Context, *StartEncryptionRequest) (*Job, error)
+	// GetStatus returns a job, like GET /api/v1/status/:jobId
+	GetStatus(context.Context, *GetStatusRequest) (*Job, error)
+	// ListJobs returns a page of the caller's tenant's jobs, like GET /api/v1/jobs
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// ProcessBatch starts, pauses, resumes or stops jobs in bulk, recording the
+	// operation as a batch. It also stands in for POST /api/v1/job/:jobId/pause,
+	// /resume, /stop and /cancel, an alias of /stop, with a single job ID.
+	ProcessBatch(context.Context, *BatchRequest) (*BatchResult, error)
+	// GetBatch returns the result of a batch operation, like
+	// GET /api/v1/batch/:batchId
+	GetBatch(context.Context, *GetBatchRequest) (*BatchResult, error)
+	// WatchJob streams a job's state each time its progress or status changes,
+	// like GET /api/v1/status/:jobId/events, and ends once the job finishes
+	WatchJob(*GetStatusRequest, grpc.ServerStreamingServer[Job]) error
+	// RetryJob queues a failed job again as a new job, like
+	// POST /api/v1/job/:jobId/retry, and returns the new job
+	RetryJob(context.Context, *GetStatusRequest) (*Job, error)
+	mustEmbedUnimplementedEncryptionServiceServer()
+}
+
+// UnimplementedEncryptionServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEncryptionServiceServer struct{}
+
+func (UnimplementedEncryptionServiceServer) StartEncryption(context.Context, *StartEncryptionRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartEncryption not implemented")
+}
+func (UnimplementedEncryptionServiceServer) GetStatus(context.Context, *GetStatusRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedEncryptionServiceServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedEncryptionServiceServer) ProcessBatch(context.Context, *BatchRequest) (*BatchResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessBatch not implemented")
+}
+func (UnimplementedEncryptionServiceServer) GetBatch(context.Context, *GetBatchRequest) (*BatchResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBatch not implemented")
+}
+func (UnimplementedEncryptionServiceServer) WatchJob(*GetStatusRequest, grpc.ServerStreamingServer[Job]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedEncryptionServiceServer) RetryJob(context.Context, *GetStatusRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetryJob not implemented")
+}
+func (UnimplementedEncryptionServiceServer) mustEmbedUnimplementedEncryptionServiceServer() {}
+func (UnimplementedEncryptionServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeEncryptionServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EncryptionServiceServer will
+// result in compilation errors.
+type UnsafeEncryptionServiceServer interface {
+	mustEmbedUnimplementedEncryptionServiceServer()
+}
+
+func RegisterEncryptionServiceServer(s grpc.ServiceRegistrar, srv EncryptionServiceServer) {
+	// If the following call pancis, it indicates UnimplementedEncryptionServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EncryptionService_ServiceDesc, srv)
+}
+
+func _EncryptionService_StartEncryption_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartEncryptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).StartEncryption(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_StartEncryption_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).StartEncryption(ctx, req.(*StartEncryptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EncryptionService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EncryptionService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EncryptionService_ProcessBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).ProcessBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_ProcessBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).ProcessBatch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EncryptionService_GetBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).GetBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_GetBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).GetBatch(ctx, req.(*GetBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EncryptionService_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EncryptionServiceServer).WatchJob(m, &grpc.GenericServerStream[GetStatusRequest, Job]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EncryptionService_WatchJobServer = grpc.ServerStreamingServer[Job]
+
+func _EncryptionService_RetryJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EncryptionServiceServer).RetryJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EncryptionService_RetryJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EncryptionServiceServer).RetryJob(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EncryptionService_ServiceDesc is the grpc.ServiceDesc for EncryptionService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EncryptionService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ee.v1.EncryptionService",
+	HandlerType: (*EncryptionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartEncryption",
+			Handler:    _EncryptionService_StartEncryption_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _EncryptionService_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _EncryptionService_ListJobs_Handler,
+		},
+		{
+			MethodName: "ProcessBatch",
+			Handler:    _EncryptionService_ProcessBatch_Handler,
+		},
+		{
+			MethodName: "GetBatch",
+			Handler:    _EncryptionService_GetBatch_Handler,
+		},
+		{
+			MethodName: "RetryJob",
+			Handler:    _EncryptionService_RetryJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchJob",
+			Handler:       _EncryptionService_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ee/v1/encryption.proto",
+}
@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/primary/grpc/eev1"
+)
+
+// Authenticator resolves API keys to the principals they act for
+type Authenticator interface {
+	Authenticate(ctx context.Context, key string) (domain.Principal, error)
+}
+
+// ReadinessChecker reports whether the service's dependencies are healthy
+type ReadinessChecker interface {
+	Ready() (bool, []string)
+	RetryAfter() time.Duration
+}
+
+// reads are the methods that only read jobs and batches; every other method
+// needs the jobs:write scope and is rejected by read-only servers
+var reads = map[string]bool{
+	eev1.EncryptionService_GetStatus_FullMethodName: true,
+	eev1.EncryptionService_ListJobs_FullMethodName:  true,
+	eev1.EncryptionService_GetBatch_FullMethodName:  true,
+	eev1.EncryptionService_WatchJob_FullMethodName:  true,
+}
+
+// guard authenticates calls and rejects those their caller, or the server's
+// state, does not allow, as the HTTP API's middleware does for /api/v1
+type guard struct {
+	keys      Authenticator
+	readiness ReadinessChecker
+	readOnly  bool
+	logger    *zap.Logger
+}
+
+func (g *guard) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := g.admit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *guard) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := g.admit(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &principalStream{ServerStream: ss, ctx: ctx})
+}
+
+// admit returns the context a call of method runs with, carrying the
+// caller's principal, or the status the call is rejected with
+func (g *guard) admit(ctx context.Context, method string) (context.Context, error) {
+	read := reads[method]
+	if g.keys != nil {
+		key := apiKey(ctx)
+		principal, err := g.keys.Authenticate(ctx, key)
+		if err != nil && !errors.Is(err, domain.ErrUnauthenticated) {
+			g.logger.Error("Failed to authenticate API key", zap.String("method", method), zap.Error(err))
+			return nil, status.Error(codes.Unavailable, "API keys cannot be checked right now")
+		}
+		if key == "" || err != nil {
+			return nil, status.Error(codes.Unauthenticated, "a valid API key is required")
+		}
+
+		scope := domain.ScopeJobsWrite
+		if read {
+			scope = domain.ScopeJobsRead
+		}
+		if !principal.HasScope(scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "this method requires an API key with the %s scope", scope)
+		}
+		ctx = domain.ContextWithPrincipal(ctx, principal)
+	}
+
+	if !read && g.readOnly {
+		return nil, status.Error(codes.Unavailable, "this instance is read-only")
+	}
+	// Job intake fails fast while a dependency is down
+	if !read && g.readiness != nil {
+		if ready, down := g.readiness.Ready(); !ready {
+			return nil, status.Errorf(codes.Unavailable, "dependencies are unavailable: %s", strings.Join(down, ", "))
+		}
+	}
+	return ctx, nil
+}
+
+// apiKey returns the API key of a call, from its x-api-key or bearer
+// authorization metadata
+func apiKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("x-api-key"); len(keys) > 0 && keys[0] != "" {
+		return keys[0]
+	}
+	for _, auth := range md.Get("authorization") {
+		if strings.HasPrefix(auth, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+	return ""
+}
+
+// principalStream is a server stream whose context carries the caller's
+// principal
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
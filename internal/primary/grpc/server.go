@@ -0,0 +1,217 @@
+// Package grpc serves the gRPC API of api/proto/ee/v1/encryption.proto from
+// the same service layer as the HTTP API.
+package grpc
+
+//go:generate protoc -I ../../../api/proto --go_out=../../.. --go_opt=module=E.E --go-grpc_out=../../.. --go-grpc_opt=module=E.E ee/v1/encryption.proto
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/primary/grpc/eev1"
+)
+
+// Config configures the gRPC server
+type Config struct {
+	Authenticator  Authenticator    // Optional; requires an API key on every call
+	Readiness      ReadinessChecker // Optional; gates job intake on dependency health
+	ReadOnly       bool             // Rejects calls that change state, for failover to a replica
+	StatusInterval time.Duration    // How often WatchJob checks its job for status changes
+	Logger         *zap.Logger
+}
+
+// Server implements the EncryptionService of the gRPC API
+type Server struct {
+	eev1.UnimplementedEncryptionServiceServer
+
+	jobs           ports.EncryptionService
+	statusInterval time.Duration
+	logger         *zap.Logger
+}
+
+// NewServer creates a gRPC server serving jobs, authenticating and gating
+// calls as the HTTP API's /api/v1 routes are
+func NewServer(jobs ports.EncryptionService, cfg Config) *grpc.Server {
+	guard := &guard{
+		keys:      cfg.Authenticator,
+		readiness: cfg.Readiness,
+		readOnly:  cfg.ReadOnly,
+		logger:    cfg.Logger,
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(guard.unary),
+		grpc.StreamInterceptor(guard.stream),
+	)
+	eev1.RegisterEncryptionServiceServer(server, &Server{
+		jobs:           jobs,
+		statusInterval: cfg.StatusInterval,
+		logger:         cfg.Logger,
+	})
+	return server
+}
+
+func (s *Server) StartEncryption(ctx context.Context, in *eev1.StartEncryptionRequest) (*eev1.Job, error) {
+	req := domain.EncryptionRequest{
+		SourceURL:         in.GetSourceUrl(),
+		Metadata:          in.GetMetadata(),
+		EncryptionOptions: engineParams(in.GetEngine()),
+	}
+	if err := req.Validate(); err != nil {
+		return nil, statusError(err)
+	}
+
+	job, err := s.jobs.StartEncryption(ctx, req.SourceURL, domain.JobOptions{
+		Metadata: req.Metadata,
+		Engine:   req.EngineParams(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toJob(job), nil
+}
+
+func (s *Server) GetStatus(ctx context.Context, in *eev1.GetStatusRequest) (*eev1.Job, error) {
+	job, err := s.jobs.GetJobStatus(ctx, in.GetJobId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toJob(job), nil
+}
+
+func (s *Server) ListJobs(ctx context.Context, in *eev1.ListJobsRequest) (*eev1.ListJobsResponse, error) {
+	limit := int(in.GetLimit())
+	if limit <= 0 {
+		limit = 10
+	}
+	offset := int(in.GetOffset())
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := domain.JobFilter{
+		Status:    in.GetStatus(),
+		SourceURL: in.GetSourceUrl(),
+		StartDate: in.GetStartDate(),
+		EndDate:   in.GetEndDate(),
+		Metadata:  in.GetMetadata(),
+	}
+	var sort domain.JobSort
+	if in.GetSortBy() != "" {
+		order := "asc"
+		if in.GetDescending() {
+			order = "desc"
+		}
+		sort.Fields = []domain.SortField{{Field: in.GetSortBy(), Order: order}}
+	}
+
+	jobs, err := s.jobs.ListJobs(ctx, limit, offset, filter, sort)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &eev1.ListJobsResponse{Jobs: make([]*eev1.Job, 0, len(jobs))}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, toJob(job))
+	}
+	return resp, nil
+}
+
+func (s *Server) ProcessBatch(ctx context.Context, in *eev1.BatchRequest) (*eev1.BatchResult, error) {
+	req := domain.EncryptionRequest{
+		Batch:             true,
+		Action:            domain.BatchAction(in.GetAction()),
+		JobIDs:            in.GetJobIds(),
+		SourceURLs:        in.GetSourceUrls(),
+		Dedupe:            in.GetDedupe(),
+		Metadata:          in.GetMetadata(),
+		EncryptionOptions: engineParams(in.GetEngine()),
+	}
+	if err := req.Validate(); err != nil {
+		return nil, statusError(err)
+	}
+
+	result, err := s.jobs.ProcessBatch(ctx, req.BatchOperation())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toBatchResult(result), nil
+}
+
+func (s *Server) GetBatch(ctx context.Context, in *eev1.GetBatchRequest) (*eev1.BatchResult, error) {
+	result, err := s.jobs.GetBatchResult(ctx, in.GetBatchId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toBatchResult(result), nil
+}
+
+func (s *Server) RetryJob(ctx context.Context, in *eev1.GetStatusRequest) (*eev1.Job, error) {
+	job, err := s.jobs.RetryJob(ctx, in.GetJobId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toJob(job), nil
+}
+
+// WatchJob sends the job, then the job again each time its progress or
+// status changes, until it finishes. Progress comes from the progress
+// broker; without one, or for status changes, the job is checked every
+// status interval.
+func (s *Server) WatchJob(in *eev1.GetStatusRequest, stream eev1.EncryptionService_WatchJobServer) error {
+	ctx := stream.Context()
+	jobID := in.GetJobId()
+
+	job, err := s.jobs.GetJobStatus(ctx, jobID)
+	if err != nil {
+		return statusError(err)
+	}
+
+	// Without a progress broker the channel is nil and the job is polled
+	progress, err := s.jobs.SubscribeToProgress(ctx, jobID)
+	if err != nil {
+		s.logger.Warn("Failed to subscribe to job progress", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	if err := stream.Send(toJob(job)); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.statusInterval)
+	defer ticker.Stop()
+
+	for !job.IsTerminal() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-progress:
+			if !ok {
+				progress = nil
+				continue
+			}
+			if update != job.Progress {
+				job.Progress = update
+				if err := stream.Send(toJob(job)); err != nil {
+					return err
+				}
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		current, err := s.jobs.GetJobStatus(ctx, jobID)
+		if err != nil {
+			return statusError(err)
+		}
+		if current.Status != job.Status || current.Progress != job.Progress {
+			if err := stream.Send(toJob(current)); err != nil {
+				return err
+			}
+		}
+		job = current
+	}
+	return nil
+}
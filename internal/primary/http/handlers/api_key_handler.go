@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// APIKeyHandler lets admins create, list and revoke API keys
+type APIKeyHandler struct {
+	apiKeyService ports.APIKeyService
+	logger        *zap.Logger
+	errorHandler  *ErrorHandler
+}
+
+func NewAPIKeyHandler(service ports.APIKeyService, logger *zap.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: service,
+		logger:        logger,
+		errorHandler:  NewErrorHandler(logger),
+	}
+}
+
+// CreateKey creates an API key and returns it with its secret, which is not
+// shown again
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req domain.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	key, err := h.apiKeyService.CreateAPIKey(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListKeys lists API keys, optionally of one owner, including revoked ones
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListAPIKeys(c.Request.Context(), c.Query("owner"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{"keys": keys})
+}
+
+// GetKey returns an API key without its secret
+func (h *APIKeyHandler) GetKey(c *gin.Context) {
+	key, err := h.apiKeyService.GetAPIKey(c.Request.Context(), c.Param("keyId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, key)
+}
+
+// RevokeKey stops an API key from being used
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	key, err := h.apiKeyService.RevokeAPIKey(c.Request.Context(), c.Param("keyId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, key)
+}
+
+// handleError maps errors of the API key endpoints to responses
+func (h *APIKeyHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		h.errorHandler.HandleForbidden(c, "API key", c.Param("keyId"))
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		h.errorHandler.HandleNotFound(c, "API key", c.Param("keyId"))
+	case errors.Is(err, domain.ErrInvalidAPIKey):
+		h.errorHandler.HandleValidationError(c, "request", err.Error())
+	default:
+		h.errorHandler.HandleInternalError(c, err)
+	}
+}
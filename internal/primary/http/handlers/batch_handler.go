@@ -1,8 +1,11 @@
 package handlers
 
 import (
+    "encoding/csv"
+    "errors"
     "net/http"
     "fmt"
+    "strconv"
     "strings"
 
     "github.com/gin-gonic/gin"
@@ -27,6 +30,11 @@ func (h *BatchHandler) ProcessBatch(c *gin.Context) {
     var op domain.BatchOperation
     if err := c.ShouldBindJSON(&op); err != nil {
         h.logger.Error("Invalid batch operation request", zap.Error(err))
+        if limit, ok := bodyLimitExceeded(err); ok {
+            c.Header("Connection", "close")
+            c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body must not exceed %d bytes", limit)})
+            return
+        }
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
         return
     }
@@ -53,6 +61,16 @@ func (h *BatchHandler) GetBatchOperation(c *gin.Context) {
         return
     }
 
+    switch format := c.DefaultQuery("format", "json"); format {
+    case "json":
+    case "csv":
+        h.exportBatchCSV(c, batchID)
+        return
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format: %s", format)})
+        return
+    }
+
     result, err := h.batchService.GetBatchResult(c.Request.Context(), batchID)
     if err != nil {
         if strings.Contains(err.Error(), "not found") {
@@ -69,9 +87,92 @@ func (h *BatchHandler) GetBatchOperation(c *gin.Context) {
     c.JSON(http.StatusOK, result)
 }
 
+// exportBatchCSV writes one CSV row per job in the batch
+func (h *BatchHandler) exportBatchCSV(c *gin.Context, batchID string) {
+    reports, err := h.batchService.GetBatchJobReports(c.Request.Context(), batchID)
+    if err != nil {
+        if strings.Contains(err.Error(), "not found") {
+            c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("batch operation %s not found", batchID)})
+            return
+        }
+        h.logger.Error("Failed to export batch operation",
+            zap.String("batch_id", batchID),
+            zap.Error(err))
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export batch operation"})
+        return
+    }
+
+    c.Header("Content-Type", "text/csv; charset=utf-8")
+    c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", batchID+".csv"))
+    c.Status(http.StatusOK)
+
+    w := csv.NewWriter(c.Writer)
+    w.Write([]string{"job_id", "outcome", "status", "error", "duration_ms", "output_path"})
+    for _, r := range reports {
+        w.Write([]string{
+            r.JobID,
+            r.Outcome,
+            string(r.Status),
+            r.Error,
+            strconv.FormatInt(r.Duration.Std().Milliseconds(), 10),
+            r.OutputPath,
+        })
+    }
+    w.Flush()
+    if err := w.Error(); err != nil {
+        h.logger.Error("Failed to write batch CSV",
+            zap.String("batch_id", batchID),
+            zap.Error(err))
+    }
+}
+
+// RollbackBatch stops every job started by a batch and optionally deletes their outputs
+func (h *BatchHandler) RollbackBatch(c *gin.Context) {
+    batchID := c.Param("batchId")
+    if batchID == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "batch ID is required"})
+        return
+    }
+
+    var req domain.BatchRollbackRequest
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            h.logger.Error("Invalid batch rollback request", zap.Error(err))
+            if limit, ok := bodyLimitExceeded(err); ok {
+                c.Header("Connection", "close")
+                c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body must not exceed %d bytes", limit)})
+                return
+            }
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+            return
+        }
+    }
+
+    result, err := h.batchService.RollbackBatch(c.Request.Context(), batchID, req)
+    if err != nil {
+        switch {
+        case errors.Is(err, domain.ErrForbidden):
+            c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        case strings.Contains(err.Error(), "not found"):
+            c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("batch operation %s not found", batchID)})
+        case strings.Contains(err.Error(), "validation failed"):
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        default:
+            h.logger.Error("Failed to roll back batch",
+                zap.String("batch_id", batchID),
+                zap.Error(err))
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back batch"})
+        }
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
 func (h *BatchHandler) ListBatchResults(c *gin.Context) {
     filter := domain.BatchFilter{
-        Status: c.Query("status"),
+        Status:    c.Query("status"),
+        CreatedBy: c.Query("created_by"),
     }
     
     if jobIDs := c.Query("job_ids"); jobIDs != "" {
@@ -7,8 +7,10 @@ import (
 	"strconv"
 	"time"
 	"errors"
+	"fmt"
 	"strings"
 	"net/http"
+	"encoding/json"
 	
 	"E.E/internal/core/domain"
 	"E.E/internal/core/ports"
@@ -19,6 +21,13 @@ type EncryptionHandler struct {
 	encryptionService ports.EncryptionService
 	logger           *zap.Logger
 	errorHandler     *ErrorHandler
+	expiryWarning    time.Duration
+	exportLimit      int
+	quotaHeaders     bool
+	statusInterval   time.Duration
+	maxUploadBytes   int64
+	uploadTimeout    time.Duration
+	resumableUploads ports.ResumableUploads
 }
 
 func NewEncryptionHandler(service ports.EncryptionService, logger *zap.Logger) *EncryptionHandler {
@@ -26,21 +35,55 @@ func NewEncryptionHandler(service ports.EncryptionService, logger *zap.Logger) *
 		encryptionService: service,
 		logger:           logger,
 		errorHandler:     NewErrorHandler(logger),
+		exportLimit:      DefaultExportLimit,
+		statusInterval:   statusStreamInterval,
 	}
 }
 
+// DefaultExportLimit is the most jobs one export request returns unless
+// configured otherwise
+const DefaultExportLimit = 10000
+
+// SetExpiryWarning makes ListJobs warn about jobs expiring within window;
+// zero disables the warnings
+func (h *EncryptionHandler) SetExpiryWarning(window time.Duration) {
+	h.expiryWarning = window
+}
+
+// SetExportLimit caps the jobs a single export request returns
+func (h *EncryptionHandler) SetExportLimit(limit int) {
+	h.exportLimit = limit
+}
+
+// SetQuotaHeaders makes job submissions report the remaining quota of the
+// caller's tenant in response headers
+func (h *EncryptionHandler) SetQuotaHeaders(enabled bool) {
+	h.quotaHeaders = enabled
+}
+
+// SetStatusInterval sets how often StreamStatus checks a job for changes
+func (h *EncryptionHandler) SetStatusInterval(interval time.Duration) {
+	h.statusInterval = interval
+}
+
 // StartEncryption handles the request to start video encryption
 func (h *EncryptionHandler) StartEncryption(c *gin.Context) {
 	var req domain.EncryptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			h.handleValidationErrors(c, req, validationErrs)
+			return
+		}
 		h.errorHandler.HandleError(c,
 			domain.StatusBadRequest,
-			"Invalid request format",
-			[]domain.BatchError{{
-				Field:   "request",
-				Message: err.Error(),
-				Code:    domain.ErrCodeInvalidFormat,
-			}},
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("request", err.Error(), "")},
 		)
 		return
 	}
@@ -53,21 +96,60 @@ func (h *EncryptionHandler) StartEncryption(c *gin.Context) {
 	h.handleSingleEncryption(c, req)
 }
 
-func (h *EncryptionHandler) handleBatchEncryption(c *gin.Context, req domain.EncryptionRequest) {
-	op := domain.BatchOperation{
-		Action:     req.Action,
-		SourceURLs: req.SourceURLs,
-		JobIDs:     req.JobIDs,
+// handleValidationErrors reports every validation problem of a request
+func (h *EncryptionHandler) handleValidationErrors(c *gin.Context, req domain.EncryptionRequest, errs domain.ValidationErrors) {
+	if !req.Batch {
+		batchErrors := make([]domain.BatchError, 0, len(errs))
+		for _, e := range errs {
+			batchErrors = append(batchErrors, e.ToBatchError(""))
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			batchErrors,
+		)
+		return
 	}
-	
+
+	batchErrors := make([]domain.BatchError, 0, len(errs))
+	for _, e := range errs {
+		batchErrors = append(batchErrors, e.ToBatchError(string(req.Action)))
+	}
+	h.errorHandler.HandleBatchError(c,
+		domain.StatusBadRequest,
+		"Batch validation failed",
+		batchErrors,
+		&domain.BatchDetails{
+			Action:     string(req.Action),
+			JobIDs:     req.JobIDs,
+			SourceURLs: req.SourceURLs,
+		},
+	)
+}
+
+func (h *EncryptionHandler) handleBatchEncryption(c *gin.Context, req domain.EncryptionRequest) {
+	op := req.BatchOperation()
+
 	result, err := h.encryptionService.ProcessBatch(c.Request.Context(), op)
 	if err != nil {
+		var quotaErr *domain.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.errorHandler.HandleQuotaExceeded(c, quotaErr)
+			return
+		}
+
 		var jobStateErr *domain.JobStateError
 		if errors.As(err, &jobStateErr) {
 			h.errorHandler.HandleStateError(c, jobStateErr)
 			return
 		}
-		
+
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			h.handleValidationErrors(c, req, validationErrs)
+			return
+		}
+
 		h.errorHandler.HandleBatchError(c,
 			domain.StatusBadRequest,
 			"Failed to process batch operation",
@@ -85,27 +167,741 @@ func (h *EncryptionHandler) handleBatchEncryption(c *gin.Context, req domain.Enc
 		)
 		return
 	}
-	
+
+	h.setQuotaHeaders(c)
 	c.JSON(domain.StatusAccepted, result)
 }
 
 func (h *EncryptionHandler) handleSingleEncryption(c *gin.Context, req domain.EncryptionRequest) {
-	if req.SourceURL == "" {
+	job, err := h.encryptionService.StartEncryption(c.Request.Context(), req.SourceURL, domain.JobOptions{
+		Metadata: req.Metadata,
+		Engine:   req.EngineParams(),
+		Outputs:  req.Outputs,
+		Transcode: req.Transcode,
+		TranscodeProfile: req.TranscodeProfile,
+		Watermark: req.Watermark,
+		ScheduledAt: scheduledAt(req.ScheduledAt),
+		SourceHash: req.SourceHash,
+		Reuse:      req.Reuse,
+		CustomerKey: req.CustomerKey,
+		Destination: req.Destination,
+		CopyTo:      req.CopyTo,
+	})
+	if err != nil {
+		h.handleStartError(c, "source_url", req.SourceURL, err)
+		return
+	}
+
+	h.setQuotaHeaders(c)
+	h.respondStarted(c, job, req.Reuse)
+}
+
+// handleStartError reports why a job could not be started. Problems with
+// the source are reported on sourceField, with the value sourceURL.
+func (h *EncryptionHandler) handleStartError(c *gin.Context, sourceField, sourceURL string, err error) {
+	if errors.Is(err, domain.ErrInvalidMetadata) {
 		h.errorHandler.HandleError(c,
 			domain.StatusBadRequest,
 			"Validation error",
-			[]domain.BatchError{
-				domain.NewValidationError("source_url", "source_url is required for single operations", ""),
-			},
+			[]domain.BatchError{domain.NewValidationError("metadata", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidOutputs) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("outputs", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidTranscode) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("transcode", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidWatermark) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("watermark", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidEngineParams) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("engine", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidCustomerKey) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("customer_key", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidDestination) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("destination", err.Error(), "")},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrDestinationUnwritable) {
+		h.errorHandler.HandleError(c,
+			domain.StatusUnprocessableEntity,
+			"Destination not writable",
+			[]domain.BatchError{{
+				Field:   "destination",
+				Message: err.Error(),
+				Code:    domain.ErrCodeDestinationUnwritable,
+			}},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrUnsupportedMedia) {
+		h.errorHandler.HandleError(c,
+			domain.StatusUnprocessableEntity,
+			"Unsupported media",
+			[]domain.BatchError{{
+				Field:   sourceField,
+				Message: err.Error(),
+				Value:   sourceURL,
+				Code:    domain.ErrCodeUnsupportedMedia,
+			}},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrSourceRejected) {
+		h.errorHandler.HandleError(c,
+			domain.StatusUnprocessableEntity,
+			"Source rejected",
+			[]domain.BatchError{{
+				Field:   sourceField,
+				Message: err.Error(),
+				Value:   sourceURL,
+				Code:    domain.ErrCodeSourceRejected,
+			}},
+		)
+		return
+	}
+	var quotaErr *domain.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		h.errorHandler.HandleQuotaExceeded(c, quotaErr)
+		return
+	}
+	if errors.Is(err, domain.ErrNotAcceptingJobs) {
+		h.errorHandler.HandleError(c,
+			domain.StatusServiceUnavailable,
+			"Service unavailable",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeUnavailable,
+			}},
+		)
+		return
+	}
+	h.errorHandler.HandleError(c,
+		domain.StatusInternalServerError,
+		"Failed to start encryption",
+		[]domain.BatchError{{
+			Field:   "general",
+			Message: err.Error(),
+			Code:    domain.ErrCodeEncryptionFailed,
+		}},
+	)
+}
+
+// respondStarted answers a request that started job. A new job is never
+// completed, so with reuse a completed one is the reused job.
+func (h *EncryptionHandler) respondStarted(c *gin.Context, job *domain.EncryptionJob, reuse bool) {
+	if reuse && job.Status == domain.StatusCompleted {
+		c.JSON(domain.StatusOK, domain.EncryptionResponse{
+			JobID:     job.ID,
+			Status:    job.Status,
+			CreatedAt: job.CreatedAt,
+			Reused:    true,
+			Result:    job.Result,
+		})
+		return
+	}
+	c.JSON(domain.StatusAccepted, domain.EncryptionResponse{
+		JobID:       job.ID,
+		Status:      job.Status,
+		CreatedAt:   job.CreatedAt,
+		ScheduledAt: job.ScheduledAt,
+	})
+}
+
+// scheduledAt returns the requested start of a job, zero to queue it at once
+func scheduledAt(at *time.Time) time.Time {
+	if at == nil {
+		return time.Time{}
+	}
+	return *at
+}
+
+// StartDecryption handles the request to decrypt the output of an encryption
+// job with its key. The decryption runs as a job of its own, whose status and
+// result are read like those of encryption jobs.
+func (h *EncryptionHandler) StartDecryption(c *gin.Context) {
+	var req domain.DecryptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	job, err := h.encryptionService.StartDecryption(c.Request.Context(), req)
+	if err != nil {
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			batchErrors := make([]domain.BatchError, 0, len(validationErrs))
+			for _, e := range validationErrs {
+				batchErrors = append(batchErrors, e.ToBatchError(""))
+			}
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				batchErrors,
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidMetadata) {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("metadata", err.Error(), "")},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", req.JobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", req.JobID)
+			return
+		}
+		if errors.Is(err, domain.ErrOutputNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Output not found",
+				[]domain.BatchError{domain.NewNotFoundError("output", req.Output)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrKeyUnavailable) {
+			h.errorHandler.HandleError(c,
+				domain.StatusConflict,
+				"Key unavailable",
+				[]domain.BatchError{{
+					Field:   "job_id",
+					Message: err.Error(),
+					Value:   req.JobID,
+					Code:    domain.ErrCodeKeyUnavailable,
+				}},
+			)
+			return
+		}
+		var quotaErr *domain.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.errorHandler.HandleQuotaExceeded(c, quotaErr)
+			return
+		}
+		if errors.Is(err, domain.ErrNotAcceptingJobs) {
+			h.errorHandler.HandleError(c,
+				domain.StatusServiceUnavailable,
+				"Service unavailable",
+				[]domain.BatchError{{
+					Field:   "general",
+					Message: err.Error(),
+					Code:    domain.ErrCodeUnavailable,
+				}},
+			)
+			return
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to start decryption",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
 		)
 		return
 	}
 
-	job, err := h.encryptionService.StartEncryption(c.Request.Context(), req.SourceURL)
-	if err != nil {
+	h.setQuotaHeaders(c)
+	c.JSON(domain.StatusAccepted, domain.EncryptionResponse{
+		JobID:     job.ID,
+		Status:    job.Status,
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// RotateKey handles the request to re-encrypt a job's output under a new
+// key. The re-encryption runs as a job of its own, which retires the job's
+// key once it completes.
+func (h *EncryptionHandler) RotateKey(c *gin.Context) {
+	jobID := c.Param("jobId")
+	var req domain.KeyRotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	job, err := h.encryptionService.RotateKey(c.Request.Context(), jobID, req)
+	if err != nil {
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			batchErrors := make([]domain.BatchError, 0, len(validationErrs))
+			for _, e := range validationErrs {
+				batchErrors = append(batchErrors, e.ToBatchError(""))
+			}
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				batchErrors,
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidMetadata) {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("metadata", err.Error(), "")},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		if errors.Is(err, domain.ErrKeyUnavailable) {
+			h.errorHandler.HandleError(c,
+				domain.StatusConflict,
+				"Key unavailable",
+				[]domain.BatchError{{
+					Field:   "job_id",
+					Message: err.Error(),
+					Value:   jobID,
+					Code:    domain.ErrCodeKeyUnavailable,
+				}},
+			)
+			return
+		}
+		var quotaErr *domain.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.errorHandler.HandleQuotaExceeded(c, quotaErr)
+			return
+		}
+		if errors.Is(err, domain.ErrNotAcceptingJobs) {
+			h.errorHandler.HandleError(c,
+				domain.StatusServiceUnavailable,
+				"Service unavailable",
+				[]domain.BatchError{{
+					Field:   "general",
+					Message: err.Error(),
+					Code:    domain.ErrCodeUnavailable,
+				}},
+			)
+			return
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to start key rotation",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	h.setQuotaHeaders(c)
+	c.JSON(domain.StatusAccepted, domain.EncryptionResponse{
+		JobID:     job.ID,
+		Status:    job.Status,
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// GetStatus handles the request to check encryption status
+func (h *EncryptionHandler) GetStatus(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+
+	job, err := h.encryptionService.GetJobStatus(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to get job status",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	c.JSON(domain.StatusOK, job.WithoutKeys())
+}
+
+// GetJobResult handles the request to retrieve a completed job's result, or
+// with ?output=name the result of one output of a multi-output job
+func (h *EncryptionHandler) GetJobResult(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+
+	var (
+		result *domain.JobResult
+		err    error
+	)
+	if output := c.Query("output"); output != "" {
+		result, err = h.encryptionService.GetOutputResult(c.Request.Context(), jobID, output)
+	} else {
+		result, err = h.encryptionService.GetJobResult(c.Request.Context(), jobID)
+	}
+	if err != nil {
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrOutputNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Output not found",
+				[]domain.BatchError{domain.NewNotFoundError("output", c.Query("output"))},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrJobResultNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job result not found",
+				[]domain.BatchError{domain.NewNotFoundError("result", jobID)},
+			)
+			return
+		}
+
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to get job result",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	c.JSON(domain.StatusOK, result)
+}
+
+// VerifyJob handles the request to read a completed job's outputs back and
+// check them against their checksums. A mismatch is reported in the body,
+// with verified false, not as an error.
+func (h *EncryptionHandler) VerifyJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+	verification, err := h.encryptionService.VerifyJob(c.Request.Context(), jobID)
+	if err != nil {
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		if errors.Is(err, domain.ErrNotVerifiable) {
+			h.errorHandler.HandleError(c,
+				domain.StatusServiceUnavailable,
+				"Service unavailable",
+				[]domain.BatchError{{
+					Field:   "general",
+					Message: err.Error(),
+					Code:    domain.ErrCodeUnavailable,
+				}},
+			)
+			return
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to verify job",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	c.JSON(domain.StatusOK, verification)
+}
+
+// GetJobKey handles the request for the decryption key of a completed job,
+// or with ?output=name of one output of a multi-output job. With ?wrap_key= the
+// key is wrapped for that RSA public key and expires after ?ttl_seconds=.
+func (h *EncryptionHandler) GetJobKey(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+	req := domain.JobKeyRequest{
+		JobID:   jobID,
+		Output:  c.Query("output"),
+		WrapKey: c.Query("wrap_key"),
+	}
+	if ttl := c.Query("ttl_seconds"); ttl != "" {
+		seconds, err := strconv.Atoi(ttl)
+		if err != nil {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("ttl_seconds", "ttl_seconds must be a number of seconds", ttl)},
+			)
+			return
+		}
+		req.TTLSeconds = seconds
+	}
+
+	key, err := h.encryptionService.GetJobKey(c.Request.Context(), req, keyAccess(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidKeyRequest) {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("request", err.Error(), "")},
+			)
+			return
+		}
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		if errors.Is(err, domain.ErrOutputNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Output not found",
+				[]domain.BatchError{domain.NewNotFoundError("output", c.Query("output"))},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrKeyUnavailable) {
+			h.errorHandler.HandleError(c,
+				domain.StatusConflict,
+				"Key unavailable",
+				[]domain.BatchError{{
+					Field:   "job_id",
+					Message: err.Error(),
+					Value:   jobID,
+					Code:    domain.ErrCodeKeyUnavailable,
+				}},
+			)
+			return
+		}
+
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to get job key",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(domain.StatusOK, key)
+}
+
+// UpdateJob handles the request to partially update a job's metadata
+func (h *EncryptionHandler) UpdateJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+
+	var req domain.JobUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	job, err := h.encryptionService.UpdateJob(c.Request.Context(), jobID, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidMetadata) {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("metadata", err.Error(), "")},
+			)
+			return
+		}
+
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to update job",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	c.JSON(domain.StatusOK, job.WithoutKeys())
+}
+
+// ExtendRetention handles the request to keep a job's record longer
+func (h *EncryptionHandler) ExtendRetention(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+
+	var req domain.RetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	job, err := h.encryptionService.ExtendJobRetention(c.Request.Context(), jobID, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidRetention) {
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				[]domain.BatchError{domain.NewValidationError("extend_by", err.Error(), req.ExtendBy.String())},
+			)
+			return
+		}
+
 		h.errorHandler.HandleError(c,
 			domain.StatusInternalServerError,
-			"Failed to start encryption",
+			"Failed to extend job retention",
 			[]domain.BatchError{{
 				Field:   "general",
 				Message: err.Error(),
@@ -115,26 +911,22 @@ func (h *EncryptionHandler) handleSingleEncryption(c *gin.Context, req domain.En
 		return
 	}
 
-	c.JSON(domain.StatusAccepted, domain.EncryptionResponse{
-		JobID:     job.ID,
-		Status:    job.Status,
-		CreatedAt: job.CreatedAt,
-	})
+	c.JSON(domain.StatusOK, job.WithoutKeys())
 }
 
-// GetStatus handles the request to check encryption status
-func (h *EncryptionHandler) GetStatus(c *gin.Context) {
+// statusStreamInterval is how often StreamStatus checks a job for changes
+// unless configured otherwise
+const statusStreamInterval = time.Second
+
+// StreamStatus streams a job's status and progress as server-sent events until
+// the job finishes or the client disconnects. Progress published by the
+// workers is sent as it arrives; the job is also checked every status
+// interval for status changes.
+func (h *EncryptionHandler) StreamStatus(c *gin.Context) {
 	jobID := c.Param("jobId")
-	if jobID == "" {
-		h.errorHandler.HandleError(c,
-			domain.StatusBadRequest,
-			"Validation error",
-			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
-		)
-		return
-	}
+	ctx := c.Request.Context()
 
-	job, err := h.encryptionService.GetJobStatus(c.Request.Context(), jobID)
+	job, err := h.encryptionService.GetJobStatus(ctx, jobID)
 	if err != nil {
 		if errors.Is(err, domain.ErrJobNotFound) {
 			h.errorHandler.HandleError(c,
@@ -157,7 +949,55 @@ func (h *EncryptionHandler) GetStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(domain.StatusOK, job)
+	// The stream may run far longer than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("Failed to lift write deadline for status stream", zap.Error(err))
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Without a progress broker the channel is nil and the job is polled
+	progress, err := h.encryptionService.SubscribeToProgress(ctx, jobID)
+	if err != nil {
+		h.logger.Warn("Failed to subscribe to job progress", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	c.SSEvent("status", job.WithoutKeys())
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(h.statusInterval)
+	defer ticker.Stop()
+
+	for !job.IsTerminal() {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-progress:
+			if !ok {
+				progress = nil
+				continue
+			}
+			if update != job.Progress {
+				job.Progress = update
+				c.SSEvent("status", job.WithoutKeys())
+				c.Writer.Flush()
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		current, err := h.encryptionService.GetJobStatus(ctx, jobID)
+		if err != nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+			return
+		}
+		if current.Status != job.Status || current.Progress != job.Progress {
+			c.SSEvent("status", current.WithoutKeys())
+			c.Writer.Flush()
+		}
+		job = current
+	}
 }
 
 // ListJobs handles the request to list all jobs
@@ -177,17 +1017,7 @@ func (h *EncryptionHandler) ListJobs(c *gin.Context) {
 	}
 
 	// Filtering
-	filter := domain.JobFilter{
-		Status:      c.Query("status"),
-		SourceURL:   c.Query("source_url"),
-		MinProgress: parseFloat(c.Query("min_progress"), 0),
-	}
-	if startDate := c.Query("start_date"); startDate != "" {
-		filter.StartDate = parseTimestamp(startDate)
-	}
-	if endDate := c.Query("end_date"); endDate != "" {
-		filter.EndDate = parseTimestamp(endDate)
-	}
+	filter := parseJobFilter(c)
 
 	// Parse sort fields with case-insensitive as default
 	var sortFields []domain.SortField
@@ -240,8 +1070,12 @@ func (h *EncryptionHandler) ListJobs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(domain.StatusOK, gin.H{
-		"jobs":       jobs,
+	redacted := make([]*domain.EncryptionJob, len(jobs))
+	for i, job := range jobs {
+		redacted[i] = job.WithoutKeys()
+	}
+	response := gin.H{
+		"jobs":       redacted,
 		"pagination": gin.H{
 			"limit":  limit,
 			"offset": offset,
@@ -250,7 +1084,131 @@ func (h *EncryptionHandler) ListJobs(c *gin.Context) {
 		"filter":       filter,
 		"sort":        sort,
 		"sort_options": services.GetAvailableSortOptions(),
-	})
+	}
+	if warnings := h.expiryWarnings(jobs); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(domain.StatusOK, response)
+}
+
+// parseJobFilter reads the job filter from the query string
+func parseJobFilter(c *gin.Context) domain.JobFilter {
+	filter := domain.JobFilter{
+		Status:      c.Query("status"),
+		SourceURL:   c.Query("source_url"),
+		MinProgress: parseFloat(c.Query("min_progress"), 0),
+		CreatedBy:   c.Query("created_by"),
+		SourceHash:  c.Query("source_hash"),
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		filter.StartDate = parseTimestamp(startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		filter.EndDate = parseTimestamp(endDate)
+	}
+	filter.Metadata = parseMetadataFilter(c)
+	return filter
+}
+
+// ExportJobs streams the jobs matching the listing filters as NDJSON, one job
+// per line in creation order. At most the export limit is returned per
+// request. The last line is {"complete": true}, or {"next_cursor": ...} to
+// pass back as ?cursor= to resume after the last job written; a stream
+// without either was cut short.
+func (h *EncryptionHandler) ExportJobs(c *gin.Context) {
+	limit := h.exportLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			h.errorHandler.HandleError(c, domain.StatusBadRequest, "Invalid export limit",
+				[]domain.BatchError{domain.NewValidationError("limit", "limit must be a positive integer", limitStr)})
+			return
+		}
+		limit = min(l, h.exportLimit)
+	}
+
+	var after *domain.JobCursor
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := domain.ParseJobCursor(token)
+		if err != nil {
+			h.errorHandler.HandleError(c, domain.StatusBadRequest, "Invalid export cursor",
+				[]domain.BatchError{domain.NewValidationError("cursor", err.Error(), token)})
+			return
+		}
+		after = cursor
+	}
+
+	// Large exports may run far longer than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("Failed to lift write deadline for job export", zap.Error(err))
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// Each line is written and flushed before the next job is read, so a slow
+	// client slows the export down instead of making it buffer
+	encoder := json.NewEncoder(c.Writer)
+	last := after
+	emit := func(job *domain.EncryptionJob) error {
+		if err := encoder.Encode(job.WithoutKeys()); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		last = domain.CursorAfter(job)
+		return nil
+	}
+
+	next, err := h.encryptionService.ExportJobs(c.Request.Context(), parseJobFilter(c), after, limit, emit)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			return // The client went away
+		}
+		h.logger.Error("Failed to export jobs", zap.Error(err))
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			errResp, status := domain.GetBatchErrorResponse(err, "list")
+			c.JSON(status, errResp)
+			return
+		}
+		// Too late for an error status; the last line says where to resume
+		encoder.Encode(gin.H{"error": "export failed", "next_cursor": cursorToken(last)})
+		return
+	}
+	if next != nil {
+		encoder.Encode(gin.H{"next_cursor": next.String()})
+		return
+	}
+	encoder.Encode(gin.H{"complete": true})
+}
+
+// cursorToken encodes an optional cursor, empty for the start of the listing
+func cursorToken(cursor *domain.JobCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	return cursor.String()
+}
+
+// expiryWarnings lists the jobs whose records expire within the warning window
+func (h *EncryptionHandler) expiryWarnings(jobs []*domain.EncryptionJob) []domain.ExpiryWarning {
+	if h.expiryWarning <= 0 {
+		return nil
+	}
+	now := time.Now()
+	var warnings []domain.ExpiryWarning
+	for _, job := range jobs {
+		if !job.ExpiresWithin(h.expiryWarning, now) {
+			continue
+		}
+		remaining := time.Unix(job.ExpiresAt, 0).Sub(now).Round(time.Second)
+		warnings = append(warnings, domain.ExpiryWarning{
+			JobID:     job.ID,
+			ExpiresAt: job.ExpiresAt,
+			Message:   fmt.Sprintf("job expires in %s; extend it with POST /api/v1/job/%s/retention", remaining, job.ID),
+		})
+	}
+	return warnings
 }
 
 // JobsStatus returns a summary of all jobs grouped by status
@@ -273,19 +1231,61 @@ func (h *EncryptionHandler) JobsStatus(c *gin.Context) {
 	c.JSON(domain.StatusOK, response)
 }
 
+// GetQuota returns the quota and usage of the caller's tenant, or for admins
+// of the tenant named by ?tenant=
+func (h *EncryptionHandler) GetQuota(c *gin.Context) {
+	tenant := c.Query("tenant")
+	status, err := h.encryptionService.GetQuota(c.Request.Context(), tenant)
+	if err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "tenant", tenant)
+			return
+		}
+		h.errorHandler.HandleInternalError(c, err)
+		return
+	}
+
+	writeQuotaHeaders(c, status)
+	c.JSON(domain.StatusOK, status)
+}
+
+// setQuotaHeaders reports the remaining quota of the caller's tenant after a
+// submission. A failure to read it does not fail the submission.
+func (h *EncryptionHandler) setQuotaHeaders(c *gin.Context) {
+	if !h.quotaHeaders {
+		return
+	}
+	status, err := h.encryptionService.GetQuota(c.Request.Context(), "")
+	if err != nil {
+		h.logger.Warn("Failed to read quota for response headers", zap.Error(err))
+		return
+	}
+	writeQuotaHeaders(c, status)
+}
+
+// writeQuotaHeaders sets X-Quota-Limit-* and X-Quota-Remaining-* for each
+// limit the tenant has, and X-Quota-Reset to when the daily count starts over
+func writeQuotaHeaders(c *gin.Context, status *domain.QuotaStatus) {
+	set := func(name string, limit int64, remaining *int64) {
+		if remaining == nil {
+			return
+		}
+		c.Header("X-Quota-Limit-"+name, strconv.FormatInt(limit, 10))
+		c.Header("X-Quota-Remaining-"+name, strconv.FormatInt(*remaining, 10))
+	}
+	set("Concurrent-Jobs", int64(status.Limits.MaxConcurrentJobs), status.Remaining.ConcurrentJobs)
+	set("Jobs-Per-Day", int64(status.Limits.MaxJobsPerDay), status.Remaining.JobsToday)
+	set("Bytes", status.Limits.MaxBytes, status.Remaining.Bytes)
+	if status.Limits.MaxJobsPerDay > 0 {
+		c.Header("X-Quota-Reset", strconv.FormatInt(status.ResetsAt, 10))
+	}
+}
+
 // ProcessBatch handles the request to process a batch of encryption jobs
 func (h *EncryptionHandler) ProcessBatch(c *gin.Context) {
 	var op domain.BatchOperation
 	if err := c.ShouldBindJSON(&op); err != nil {
-		h.errorHandler.HandleError(c,
-			domain.StatusBadRequest,
-			"Invalid request format",
-			[]domain.BatchError{{
-				Field:   "request",
-				Message: err.Error(),
-				Code:    domain.ErrCodeInvalidFormat,
-			}},
-		)
+		h.errorHandler.HandleBindError(c, err)
 		return
 	}
 
@@ -434,6 +1434,16 @@ func (h *EncryptionHandler) PauseJob(c *gin.Context) {
 	}
 
 	if err := h.encryptionService.PauseJob(c.Request.Context(), jobID); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		// The job may have changed state since it was checked
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
 		h.errorHandler.HandleError(c,
 			domain.StatusInternalServerError,
 			"Failed to pause job",
@@ -496,6 +1506,16 @@ func (h *EncryptionHandler) ResumeJob(c *gin.Context) {
 	}
 
 	if err := h.encryptionService.ResumeJob(c.Request.Context(), jobID); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		// The job may have changed state since it was checked
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
 		h.errorHandler.HandleError(c,
 			domain.StatusInternalServerError,
 			"Failed to resume job",
@@ -510,7 +1530,7 @@ func (h *EncryptionHandler) ResumeJob(c *gin.Context) {
 
 	c.JSON(domain.StatusOK, gin.H{
 		"job_id":  jobID,
-		"status":  domain.StatusProgress,
+		"status":  domain.StatusQueued,
 		"message": "Job resumed successfully",
 	})
 }
@@ -558,6 +1578,16 @@ func (h *EncryptionHandler) StopJob(c *gin.Context) {
 	}
 
 	if err := h.encryptionService.StopJob(c.Request.Context(), jobID); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		// The job may have changed state since it was checked
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
 		h.errorHandler.HandleError(c,
 			domain.StatusInternalServerError,
 			"Failed to stop job",
@@ -572,11 +1602,181 @@ func (h *EncryptionHandler) StopJob(c *gin.Context) {
 
 	c.JSON(domain.StatusOK, gin.H{
 		"job_id":  jobID,
-		"status":  domain.StatusFailed,
+		"status":  domain.StatusCancelled,
 		"message": "Job stopped successfully",
 	})
 }
 
+// RetryJob handles the request to run a failed job again. The retry is a
+// new job with the failed job's source and parameters, linked to it both
+// ways.
+func (h *EncryptionHandler) RetryJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("job_id", "job_id is required", "")},
+		)
+		return
+	}
+
+	job, err := h.encryptionService.GetJobStatus(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, domain.ErrJobNotFound) {
+			h.errorHandler.HandleError(c,
+				domain.StatusNotFound,
+				"Job not found",
+				[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+			)
+			return
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to get job status",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: err.Error(),
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		return
+	}
+
+	if err := job.CanRetry(); err != nil {
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
+	}
+
+	retry, err := h.encryptionService.RetryJob(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			h.errorHandler.HandleForbidden(c, "job", jobID)
+			return
+		}
+		// The job may have changed state since it was checked
+		var stateErr *domain.JobStateError
+		if errors.As(err, &stateErr) {
+			h.errorHandler.HandleStateError(c, stateErr)
+			return
+		}
+		// Decryptions and rotations need the key they were given
+		if errors.Is(err, domain.ErrKeyUnavailable) {
+			h.errorHandler.HandleError(c,
+				domain.StatusConflict,
+				"Key unavailable",
+				[]domain.BatchError{{
+					Field:   "job_id",
+					Message: err.Error(),
+					Value:   jobID,
+					Code:    domain.ErrCodeKeyUnavailable,
+				}},
+			)
+			return
+		}
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			batchErrors := make([]domain.BatchError, 0, len(validationErrs))
+			for _, e := range validationErrs {
+				batchErrors = append(batchErrors, e.ToBatchError(""))
+			}
+			h.errorHandler.HandleError(c,
+				domain.StatusBadRequest,
+				"Validation error",
+				batchErrors,
+			)
+			return
+		}
+		h.handleStartError(c, "source_url", job.SourceURL, err)
+		return
+	}
+
+	h.setQuotaHeaders(c)
+	c.JSON(domain.StatusAccepted, domain.EncryptionResponse{
+		JobID:     retry.ID,
+		Status:    retry.Status,
+		CreatedAt: retry.CreatedAt,
+		RetryOf:   jobID,
+	})
+}
+
+// RescheduleJob handles the request to move the start of a scheduled job
+func (h *EncryptionHandler) RescheduleJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	var req domain.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	job, err := h.encryptionService.RescheduleJob(c.Request.Context(), jobID, req)
+	if err != nil {
+		h.handleScheduleError(c, jobID, "Failed to reschedule job", err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, job.WithoutKeys())
+}
+
+// UnscheduleJob handles the request to cancel a scheduled job before its start
+func (h *EncryptionHandler) UnscheduleJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	if err := h.encryptionService.UnscheduleJob(c.Request.Context(), jobID); err != nil {
+		h.handleScheduleError(c, jobID, "Failed to unschedule job", err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{
+		"job_id":  jobID,
+		"status":  domain.StatusCancelled,
+		"message": "Job unscheduled successfully",
+	})
+}
+
+// handleScheduleError reports an error rescheduling or unscheduling a job
+func (h *EncryptionHandler) handleScheduleError(c *gin.Context, jobID, message string, err error) {
+	if errors.Is(err, domain.ErrForbidden) {
+		h.errorHandler.HandleForbidden(c, "job", jobID)
+		return
+	}
+	if errors.Is(err, domain.ErrJobNotFound) {
+		h.errorHandler.HandleError(c,
+			domain.StatusNotFound,
+			"Job not found",
+			[]domain.BatchError{domain.NewNotFoundError("job", jobID)},
+		)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidSchedule) {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("scheduled_at", err.Error(), "")},
+		)
+		return
+	}
+	var stateErr *domain.JobStateError
+	if errors.As(err, &stateErr) {
+		h.errorHandler.HandleStateError(c, stateErr)
+		return
+	}
+
+	h.errorHandler.HandleError(c,
+		domain.StatusInternalServerError,
+		message,
+		[]domain.BatchError{{
+			Field:   "general",
+			Message: err.Error(),
+			Code:    domain.ErrCodeEncryptionFailed,
+		}},
+	)
+}
+
 // StopEngine handles the request to stop the encryption engine
 func (h *EncryptionHandler) StopEngine(c *gin.Context) {
 	if err := h.encryptionService.StopEngine(); err != nil {
@@ -613,6 +1813,22 @@ func parseTimestamp(s string) int64 {
 	return 0
 }
 
+// parseMetadataFilter collects metadata.<key>=<value> query parameters
+func parseMetadataFilter(c *gin.Context) map[string]string {
+	var metadata map[string]string
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok || key == "" || len(values) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = values[0]
+	}
+	return metadata
+}
+
 func parseFloat(s string, defaultVal float64) float64 {
 	if s == "" {
 		return defaultVal
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+    "errors"
+    "math"
+    "net/http"
+    "strconv"
+
     "github.com/gin-gonic/gin"
     "E.E/internal/core/domain"
     "E.E/internal/primary/http/middleware"
@@ -85,6 +90,14 @@ func (h *ErrorHandler) HandleNotFound(c *gin.Context, resourceType, identifier s
     )
 }
 
+func (h *ErrorHandler) HandleForbidden(c *gin.Context, resourceType, identifier string) {
+    h.HandleError(c,
+        domain.StatusForbidden,
+        "Forbidden",
+        []domain.BatchError{domain.NewForbiddenError(resourceType, identifier)},
+    )
+}
+
 func (h *ErrorHandler) HandleValidationError(c *gin.Context, field, message string) {
     h.HandleError(c,
         domain.StatusBadRequest,
@@ -103,4 +116,70 @@ func (h *ErrorHandler) HandleInternalError(c *gin.Context, err error) {
             Code:    domain.ErrCodeEncryptionFailed,
         }},
     )
-}
\ No newline at end of file
+}
+// HandleQuotaExceeded reports a submission over its tenant's quota: 429 for
+// quotas that free up by themselves, with Retry-After when it is known how
+// soon, and 403 for the byte quota, which only frees up as outputs are deleted
+func (h *ErrorHandler) HandleQuotaExceeded(c *gin.Context, err *domain.QuotaExceededError) {
+    status := domain.StatusForbidden
+    if err.Temporary() {
+        status = domain.StatusTooManyRequests
+    }
+    if err.RetryAfter > 0 {
+        c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+    }
+
+    requestID := middleware.GetRequestID(c)
+    h.logger.Info("Quota exceeded",
+        zap.String("request_id", requestID),
+        zap.String("tenant", err.Tenant),
+        zap.String("quota", err.Quota),
+    )
+
+    c.JSON(status, struct {
+        domain.BatchErrorResponse
+        Quota *domain.QuotaExceededError `json:"quota"`
+    }{
+        BatchErrorResponse: domain.NewBatchErrorResponse(
+            "Quota exceeded",
+            []domain.BatchError{{
+                Field:   "quota",
+                Message: err.Error(),
+                Value:   err.Quota,
+                Code:    domain.ErrCodeQuotaExceeded,
+            }},
+            nil,
+            requestID,
+        ),
+        Quota: err,
+    })
+}
+
+// HandleBindError reports a request body that could not be decoded: 413 when
+// it exceeded the body size limit, otherwise 400
+func (h *ErrorHandler) HandleBindError(c *gin.Context, err error) {
+    if limit, ok := bodyLimitExceeded(err); ok {
+        c.Header("Connection", "close")
+        c.JSON(domain.StatusRequestTooLarge, middleware.BodyTooLargeResponse(c, limit))
+        return
+    }
+    h.HandleError(c,
+        domain.StatusBadRequest,
+        "Invalid request format",
+        []domain.BatchError{{
+            Field:   "request",
+            Message: err.Error(),
+            Code:    domain.ErrCodeInvalidFormat,
+        }},
+    )
+}
+
+// bodyLimitExceeded reports whether reading a request body failed on the body
+// size limit, and the limit
+func bodyLimitExceeded(err error) (int64, bool) {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        return tooLarge.Limit, true
+    }
+    return 0, false
+}
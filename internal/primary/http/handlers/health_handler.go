@@ -1,56 +1,76 @@
 package handlers
 
 import (
-	"context"
-	"fmt"
 	"net/http"
 	"runtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-)
 
-type HealthCheck func(context.Context) error
+	"E.E/internal/core/domain"
+	"E.E/internal/core/services"
+)
 
 type HealthHandler struct {
 	startTime time.Time
-	checks    map[string]HealthCheck
+	monitor   *services.HealthMonitor
 	logger    *zap.Logger
 }
 
-func NewHealthHandler(logger *zap.Logger) *HealthHandler {
+type DependencyHealth struct {
+	State       string          `json:"state"`
+	Latency     domain.Duration `json:"latency"`
+	LastChecked int64           `json:"last_checked"`
+	LastSuccess int64           `json:"last_success,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+func NewHealthHandler(monitor *services.HealthMonitor, logger *zap.Logger) *HealthHandler {
 	return &HealthHandler{
 		startTime: time.Now(),
-		checks:    make(map[string]HealthCheck),
+		monitor:   monitor,
 		logger:    logger,
 	}
 }
 
-func (h *HealthHandler) AddCheck(name string, check HealthCheck) {
-	h.checks[name] = check
-}
-
+// Check reports the service state from the latest dependency checks. The
+// terse default suits orchestration probes; ?verbose=true adds per-dependency
+// latency, timestamps and runtime details for debugging. A down dependency
+// answers 503 so probes fail.
 func (h *HealthHandler) Check(c *gin.Context) {
-	ctx := c.Request.Context()
-	status := "ok"
-	checks := make(map[string]string)
-
-	for name, check := range h.checks {
-		if err := check(ctx); err != nil {
-			status = "error"
-			checks[name] = fmt.Sprintf("error: %v", err)
-		} else {
-			checks[name] = "ok"
+	state := h.monitor.State()
+
+	status := http.StatusOK
+	if state == services.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	if c.Query("verbose") != "true" {
+		c.JSON(status, gin.H{"status": state})
+		return
+	}
+
+	checks := make(map[string]DependencyHealth)
+	for _, dep := range h.monitor.Statuses() {
+		health := DependencyHealth{
+			State:       dep.State,
+			Latency:     domain.Duration(dep.Latency),
+			LastChecked: dep.LastChecked.Unix(),
+			Error:       dep.Error,
+		}
+		if !dep.LastSuccess.IsZero() {
+			health.LastSuccess = dep.LastSuccess.Unix()
 		}
+		checks[dep.Name] = health
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":     status,
+	c.JSON(status, gin.H{
+		"status":     state,
 		"time":       time.Now().Unix(),
-		"uptime":     time.Since(h.startTime).String(),
+		"uptime":     domain.Duration(time.Since(h.startTime)),
 		"checks":     checks,
 		"go_version": runtime.Version(),
 		"goroutines": runtime.NumGoroutine(),
 	})
-}
\ No newline at end of file
+}
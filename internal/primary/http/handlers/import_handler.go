@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// ImportHandler imports historical jobs for migrations from other systems
+type ImportHandler struct {
+	importer     ports.JobImporter
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+func NewImportHandler(importer ports.JobImporter, logger *zap.Logger) *ImportHandler {
+	return &ImportHandler{
+		importer:     importer,
+		logger:       logger,
+		errorHandler: NewErrorHandler(logger),
+	}
+}
+
+// ImportJobs stores the jobs of an NDJSON body, one record per line.
+// ?dry_run=true validates the records without storing them.
+func (h *ImportHandler) ImportJobs(c *gin.Context) {
+	var opts domain.ImportOptions
+	if v := c.Query("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			h.errorHandler.HandleValidationError(c, "dry_run", "dry_run must be true or false")
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	result, err := h.importer.ImportJobs(c.Request.Context(), c.Request.Body, opts)
+	if err != nil {
+		h.logger.Error("Job import stopped",
+			zap.Int("imported", result.Imported),
+			zap.Int("skipped", result.Skipped),
+			zap.Int("failed", result.Failed),
+			zap.Error(err))
+		if _, ok := bodyLimitExceeded(err); ok {
+			h.errorHandler.HandleBindError(c, err)
+			return
+		}
+		h.errorHandler.HandleInternalError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, result)
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/primary/http/middleware"
+)
+
+// Limits of the key audit endpoint
+const (
+	DefaultKeyAuditLimit = 100
+	MaxKeyAuditLimit     = 1000
+)
+
+// KeyHandler serves key policies, tokens and audit trails to job owners, and
+// the keys themselves to players and packagers holding a token
+type KeyHandler struct {
+	keyService   ports.KeyService
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+func NewKeyHandler(service ports.KeyService, logger *zap.Logger) *KeyHandler {
+	return &KeyHandler{
+		keyService:   service,
+		logger:       logger,
+		errorHandler: NewErrorHandler(logger),
+	}
+}
+
+// GetPolicy returns the access policy of a job's key, or of one of its
+// outputs' keys with ?output=
+func (h *KeyHandler) GetPolicy(c *gin.Context) {
+	key := domain.ContentKey{JobID: c.Param("jobId"), Output: c.Query("output")}
+
+	policy, err := h.keyService.GetKeyPolicy(c.Request.Context(), key)
+	if err != nil {
+		h.handleError(c, key.JobID, err)
+		return
+	}
+	if policy == nil {
+		policy = &domain.KeyPolicy{Key: key}
+	}
+
+	c.JSON(domain.StatusOK, policy)
+}
+
+// SetPolicy replaces the access policy of a job's key
+func (h *KeyHandler) SetPolicy(c *gin.Context) {
+	key := domain.ContentKey{JobID: c.Param("jobId"), Output: c.Query("output")}
+
+	var req domain.KeyPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	policy, err := h.keyService.SetKeyPolicy(c.Request.Context(), key, req, keyAccess(c))
+	if err != nil {
+		h.handleError(c, key.JobID, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, policy)
+}
+
+// IssueToken issues a token a player or packager fetches a job's key with
+func (h *KeyHandler) IssueToken(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	var req domain.KeyTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	token, err := h.keyService.IssueKeyToken(c.Request.Context(), jobID, req, keyAccess(c))
+	if err != nil {
+		h.handleError(c, jobID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(domain.StatusOK, token)
+}
+
+// IssuePlaybackToken issues a token a player fetches a job's key with from
+// GetPlaybackKey, and the key's URL with the token
+func (h *KeyHandler) IssuePlaybackToken(c *gin.Context) {
+	var req domain.PlaybackTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+	if req.JobID == "" {
+		h.errorHandler.HandleValidationError(c, "job_id", "job_id is required")
+		return
+	}
+
+	token, err := h.keyService.IssueKeyToken(c.Request.Context(), req.JobID, req.KeyTokenRequest(), keyAccess(c))
+	if err != nil {
+		h.handleError(c, req.JobID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(domain.StatusOK, domain.PlaybackToken{
+		KeyToken: *token,
+		KeyURL:   "/api/v1/keys/" + url.PathEscape(token.KeyID) + "?token=" + url.QueryEscape(token.Token),
+	})
+}
+
+// GetAudit returns the audit trail of a job's keys, newest first
+func (h *KeyHandler) GetAudit(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	limit := DefaultKeyAuditLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxKeyAuditLimit {
+			h.errorHandler.HandleValidationError(c, "limit", fmt.Sprintf("limit must be between 1 and %d", MaxKeyAuditLimit))
+			return
+		}
+		limit = n
+	}
+
+	events, err := h.keyService.GetKeyAudit(c.Request.Context(), jobID, limit)
+	if err != nil {
+		h.handleError(c, jobID, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{
+		"job_id": jobID,
+		"events": events,
+	})
+}
+
+// clearKeyRequest is a W3C ClearKey license request
+type clearKeyRequest struct {
+	KIDs []string `json:"kids"`
+	Type string   `json:"type"`
+}
+
+// clearKeyJWK is one key of a ClearKey license
+type clearKeyJWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	K   string `json:"k"`
+}
+
+// License answers a ClearKey license request from a player with the key its
+// token grants
+func (h *KeyHandler) License(c *gin.Context) {
+	token, ok := h.bearerToken(c)
+	if !ok {
+		return
+	}
+
+	var req clearKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+	keyIDs := make([][]byte, 0, len(req.KIDs))
+	for i, kid := range req.KIDs {
+		id, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(kid, "="))
+		if err != nil {
+			h.errorHandler.HandleValidationError(c, fmt.Sprintf("kids[%d]", i), "key IDs must be base64url encoded")
+			return
+		}
+		keyIDs = append(keyIDs, id)
+	}
+
+	key, err := h.keyService.DeliverKey(c.Request.Context(), token, keyIDs, keyAccess(c))
+	if err != nil {
+		h.handleDeliveryError(c, err)
+		return
+	}
+
+	licenseType := req.Type
+	if licenseType == "" {
+		licenseType = "temporary"
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(domain.StatusOK, gin.H{
+		"keys": []clearKeyJWK{{
+			KTY: "oct",
+			KID: base64.RawURLEncoding.EncodeToString(key.KeyID),
+			K:   base64.RawURLEncoding.EncodeToString(key.Material),
+		}},
+		"type": licenseType,
+	})
+}
+
+// Key returns the raw key a token grants, for packagers and HLS key URIs.
+// Clients that cannot set headers may pass the token as ?token=.
+func (h *KeyHandler) Key(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var ok bool
+		if token, ok = h.bearerToken(c); !ok {
+			return
+		}
+	}
+
+	key, err := h.keyService.DeliverKey(c.Request.Context(), token, nil, keyAccess(c))
+	if err != nil {
+		h.handleDeliveryError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Key-ID", base64.RawURLEncoding.EncodeToString(key.KeyID))
+	c.Data(domain.StatusOK, "application/octet-stream", key.Material)
+}
+
+// GetPlaybackKey returns the raw key with the key ID in the path to a player
+// holding a playback token, e.g. as an HLS key URI. The token is taken from
+// ?token= or the Authorization header, and must grant that key.
+func (h *KeyHandler) GetPlaybackKey(c *gin.Context) {
+	keyID, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(c.Param("keyId"), "="))
+	if err != nil {
+		h.errorHandler.HandleValidationError(c, "key_id", "key IDs must be base64url encoded")
+		return
+	}
+	token := c.Query("token")
+	if token == "" {
+		var ok bool
+		if token, ok = h.bearerToken(c); !ok {
+			return
+		}
+	}
+
+	key, err := h.keyService.DeliverKey(c.Request.Context(), token, [][]byte{keyID}, keyAccess(c))
+	if err != nil {
+		h.handleDeliveryError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(domain.StatusOK, "application/octet-stream", key.Material)
+}
+
+// bearerToken returns the request's bearer token, answering 401 if it has none
+func (h *KeyHandler) bearerToken(c *gin.Context) (string, bool) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="keys"`)
+		h.errorHandler.HandleError(c,
+			domain.StatusUnauthorized,
+			"Key token required",
+			[]domain.BatchError{{
+				Field:   "authorization",
+				Message: "a bearer key token is required",
+				Code:    domain.ErrCodeUnauthorized,
+			}},
+		)
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// handleDeliveryError maps key delivery errors to responses
+func (h *KeyHandler) handleDeliveryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrKeyTokenInvalid):
+		c.Header("WWW-Authenticate", `Bearer realm="keys", error="invalid_token"`)
+		h.errorHandler.HandleError(c,
+			domain.StatusUnauthorized,
+			"Invalid key token",
+			[]domain.BatchError{{
+				Field:   "authorization",
+				Message: err.Error(),
+				Code:    domain.ErrCodeUnauthorized,
+			}},
+		)
+	case errors.Is(err, domain.ErrKeyDenied):
+		h.errorHandler.HandleError(c,
+			domain.StatusForbidden,
+			"Key delivery denied",
+			[]domain.BatchError{{
+				Field:   "key",
+				Message: err.Error(),
+				Code:    domain.ErrCodeForbidden,
+			}},
+		)
+	default:
+		h.errorHandler.HandleError(c,
+			domain.StatusInternalServerError,
+			"Failed to deliver key",
+			[]domain.BatchError{{
+				Field:   "general",
+				Message: "the key could not be delivered",
+				Code:    domain.ErrCodeEncryptionFailed,
+			}},
+		)
+		h.logger.Error("Failed to deliver key",
+			zap.String("request_id", middleware.GetRequestID(c)),
+			zap.Error(err))
+	}
+}
+
+// handleError maps errors of the key management endpoints to responses
+func (h *KeyHandler) handleError(c *gin.Context, jobID string, err error) {
+	var stateErr *domain.JobStateError
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		h.errorHandler.HandleForbidden(c, "job", jobID)
+	case errors.Is(err, domain.ErrJobNotFound):
+		h.errorHandler.HandleNotFound(c, "job", jobID)
+	case errors.Is(err, domain.ErrOutputNotFound):
+		h.errorHandler.HandleError(c,
+			domain.StatusNotFound,
+			"Output not found",
+			[]domain.BatchError{{Field: "output", Message: err.Error(), Code: domain.ErrCodeNotFound}},
+		)
+	case errors.As(err, &stateErr):
+		h.errorHandler.HandleStateError(c, stateErr)
+	case errors.Is(err, domain.ErrInvalidKeyPolicy):
+		h.errorHandler.HandleValidationError(c, "request", err.Error())
+	case errors.Is(err, domain.ErrKeyDenied):
+		h.errorHandler.HandleError(c,
+			domain.StatusForbidden,
+			"Key access denied",
+			[]domain.BatchError{{
+				Field:   "client",
+				Message: err.Error(),
+				Code:    domain.ErrCodeForbidden,
+			}},
+		)
+	default:
+		h.errorHandler.HandleInternalError(c, err)
+	}
+}
+
+// keyAccess describes the request for the key audit trail
+func keyAccess(c *gin.Context) domain.KeyAccess {
+	return domain.KeyAccess{
+		RemoteIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: middleware.GetRequestID(c),
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// RecurringHandler lets tenants register batches that start on a cron
+// schedule
+type RecurringHandler struct {
+	recurring    ports.RecurringBatches
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+func NewRecurringHandler(recurring ports.RecurringBatches, logger *zap.Logger) *RecurringHandler {
+	return &RecurringHandler{
+		recurring:    recurring,
+		logger:       logger,
+		errorHandler: NewErrorHandler(logger),
+	}
+}
+
+// CreateRecurring registers a recurring batch for the caller's tenant
+func (h *RecurringHandler) CreateRecurring(c *gin.Context) {
+	var req domain.RecurringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	recurring, err := h.recurring.CreateRecurring(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, recurring)
+}
+
+// ListRecurring lists the recurring batches of the caller's tenant. Admins
+// may pass ?tenant= to list another tenant's, or leave it out to list every
+// tenant's.
+func (h *RecurringHandler) ListRecurring(c *gin.Context) {
+	batches, err := h.recurring.ListRecurring(c.Request.Context(), c.Query("tenant"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{"recurring_batches": batches})
+}
+
+// GetRecurring returns a recurring batch with its next and last runs
+func (h *RecurringHandler) GetRecurring(c *gin.Context) {
+	recurring, err := h.recurring.GetRecurring(c.Request.Context(), c.Param("recurringId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, recurring)
+}
+
+// UpdateRecurring replaces a recurring batch's schedule and template
+func (h *RecurringHandler) UpdateRecurring(c *gin.Context) {
+	var req domain.RecurringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	recurring, err := h.recurring.UpdateRecurring(c.Request.Context(), c.Param("recurringId"), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, recurring)
+}
+
+// DeleteRecurring stops a recurring batch from starting more batches.
+// Batches it already started are left alone.
+func (h *RecurringHandler) DeleteRecurring(c *gin.Context) {
+	recurring, err := h.recurring.DeleteRecurring(c.Request.Context(), c.Param("recurringId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, recurring)
+}
+
+// handleError maps errors of the recurring batch endpoints to responses
+func (h *RecurringHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		h.errorHandler.HandleForbidden(c, "recurring batch", c.Param("recurringId"))
+	case errors.Is(err, domain.ErrRecurringNotFound):
+		h.errorHandler.HandleNotFound(c, "recurring batch", c.Param("recurringId"))
+	case errors.Is(err, domain.ErrInvalidRecurring):
+		h.errorHandler.HandleValidationError(c, "request", err.Error())
+	default:
+		h.errorHandler.HandleInternalError(c, err)
+	}
+}
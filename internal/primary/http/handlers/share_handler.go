@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/primary/http/middleware"
+)
+
+// ShareHandler mints and revokes share links for job owners, and serves the
+// links to whoever holds them
+type ShareHandler struct {
+	shareService ports.ShareService
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+func NewShareHandler(service ports.ShareService, logger *zap.Logger) *ShareHandler {
+	return &ShareHandler{
+		shareService: service,
+		logger:       logger,
+		errorHandler: NewErrorHandler(logger),
+	}
+}
+
+// CreateLink mints a share link to a job's output or key manifest
+func (h *ShareHandler) CreateLink(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	var req domain.ShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	link, err := h.shareService.CreateShareLink(c.Request.Context(), jobID, req)
+	if err != nil {
+		h.handleError(c, jobID, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, link)
+}
+
+// ListLinks lists a job's unexpired share links, including revoked ones
+func (h *ShareHandler) ListLinks(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	links, err := h.shareService.ListShareLinks(c.Request.Context(), jobID)
+	if err != nil {
+		h.handleError(c, jobID, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{
+		"job_id": jobID,
+		"links":  links,
+	})
+}
+
+// RevokeLink stops a share link from being opened
+func (h *ShareHandler) RevokeLink(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	link, err := h.shareService.RevokeShareLink(c.Request.Context(), jobID, c.Param("linkId"))
+	if err != nil {
+		h.handleError(c, jobID, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, link)
+}
+
+// Open serves what a share link points to: a key manifest as JSON, or the
+// output as a download or a redirect to a presigned storage URL
+func (h *ShareHandler) Open(c *gin.Context) {
+	content, err := h.shareService.OpenShareLink(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrShareLinkExpired):
+			h.errorHandler.HandleError(c,
+				http.StatusGone,
+				"Share link is invalid, expired or revoked",
+				[]domain.BatchError{{
+					Field:   "token",
+					Message: err.Error(),
+					Code:    domain.ErrCodeNotFound,
+				}},
+			)
+		default:
+			h.logger.Error("Failed to open share link",
+				zap.String("request_id", middleware.GetRequestID(c)),
+				zap.Error(err))
+			h.errorHandler.HandleError(c,
+				domain.StatusInternalServerError,
+				"Failed to open share link",
+				[]domain.BatchError{{
+					Field:   "general",
+					Message: "the shared content could not be served",
+					Code:    domain.ErrCodeEncryptionFailed,
+				}},
+			)
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	switch {
+	case content.Manifest != nil:
+		c.JSON(domain.StatusOK, content.Manifest)
+	case content.RedirectURL != "":
+		c.Redirect(http.StatusFound, content.RedirectURL)
+	default:
+		defer content.Body.Close()
+		c.DataFromReader(domain.StatusOK, content.Size, "application/octet-stream", content.Body, map[string]string{
+			"Content-Disposition": fmt.Sprintf("attachment; filename=%q", content.Name),
+		})
+	}
+}
+
+// handleError maps errors of the share link management endpoints to responses
+func (h *ShareHandler) handleError(c *gin.Context, jobID string, err error) {
+	var stateErr *domain.JobStateError
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		h.errorHandler.HandleForbidden(c, "job", jobID)
+	case errors.Is(err, domain.ErrJobNotFound):
+		h.errorHandler.HandleNotFound(c, "job", jobID)
+	case errors.Is(err, domain.ErrShareLinkNotFound):
+		h.errorHandler.HandleNotFound(c, "share link", c.Param("linkId"))
+	case errors.Is(err, domain.ErrOutputNotFound):
+		h.errorHandler.HandleError(c,
+			domain.StatusNotFound,
+			"Output not found",
+			[]domain.BatchError{{Field: "output", Message: err.Error(), Code: domain.ErrCodeNotFound}},
+		)
+	case errors.As(err, &stateErr):
+		h.errorHandler.HandleStateError(c, stateErr)
+	case errors.Is(err, domain.ErrInvalidShareLink):
+		h.errorHandler.HandleValidationError(c, "request", err.Error())
+	default:
+		h.errorHandler.HandleInternalError(c, err)
+	}
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// Kinds of status events
+const (
+	StatusEventStatus   = "status"   // The job's status changed; carries the job
+	StatusEventProgress = "progress" // The job's progress changed
+	StatusEventError    = "error"    // The job can no longer be followed, or a request failed
+)
+
+// statusEventBuffer is how many events a subscriber may fall behind by before
+// further events are dropped
+const statusEventBuffer = 64
+
+// StatusEvent is pushed to the subscribers of a job
+type StatusEvent struct {
+	Type     string                `json:"type"`
+	JobID    string                `json:"job_id,omitempty"`
+	Job      *domain.EncryptionJob `json:"job,omitempty"`
+	Progress *domain.Progress      `json:"progress,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// StatusSubscriber receives the events of the jobs it is subscribed to
+type StatusSubscriber struct {
+	events chan StatusEvent
+}
+
+// Events returns the subscriber's events
+func (s *StatusSubscriber) Events() <-chan StatusEvent {
+	return s.events
+}
+
+// send delivers an event without blocking, dropping it if the subscriber is
+// behind
+func (s *StatusSubscriber) send(event StatusEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatusHub follows jobs for any number of subscribers. Each job with
+// subscribers is followed once, by its progress updates and by checking its
+// status every interval, and the changes are fanned out to its subscribers
+// until the job finishes. Subscribers are checked against the job's owner
+// before they are added.
+type StatusHub struct {
+	jobs     ports.EncryptionService
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	watches map[string]*jobWatch
+}
+
+// jobWatch is a job being followed and its subscribers
+type jobWatch struct {
+	subscribers map[*StatusSubscriber]struct{}
+	cancel      context.CancelFunc
+}
+
+func NewStatusHub(jobs ports.EncryptionService, interval time.Duration, logger *zap.Logger) *StatusHub {
+	if interval <= 0 {
+		interval = statusStreamInterval
+	}
+	return &StatusHub{
+		jobs:     jobs,
+		interval: interval,
+		logger:   logger,
+		watches:  make(map[string]*jobWatch),
+	}
+}
+
+// NewSubscriber returns a subscriber without subscriptions
+func (h *StatusHub) NewSubscriber() *StatusSubscriber {
+	return &StatusSubscriber{events: make(chan StatusEvent, statusEventBuffer)}
+}
+
+// Subscribe adds sub to the subscribers of a job, sending it the job's
+// current status first. ctx carries the caller, who must own the job.
+// Finished jobs are sent once and not followed.
+func (h *StatusHub) Subscribe(ctx context.Context, sub *StatusSubscriber, jobID string) error {
+	job, err := h.jobs.GetJobStatus(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := domain.PrincipalFromContext(ctx).Authorize(job.CreatedBy); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub.send(StatusEvent{Type: StatusEventStatus, JobID: jobID, Job: job.WithoutKeys()})
+	if job.IsTerminal() {
+		return nil
+	}
+
+	watch, ok := h.watches[jobID]
+	if !ok {
+		watchCtx, cancel := context.WithCancel(context.Background())
+		watch = &jobWatch{
+			subscribers: make(map[*StatusSubscriber]struct{}),
+			cancel:      cancel,
+		}
+		h.watches[jobID] = watch
+		go h.follow(watchCtx, watch, job)
+	}
+	watch.subscribers[sub] = struct{}{}
+	return nil
+}
+
+// Unsubscribe removes sub from the subscribers of a job
+func (h *StatusHub) Unsubscribe(sub *StatusSubscriber, jobID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribe(sub, jobID)
+}
+
+// Close removes sub from the subscribers of every job
+func (h *StatusHub) Close(sub *StatusSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for jobID := range h.watches {
+		h.unsubscribe(sub, jobID)
+	}
+}
+
+// unsubscribe stops following a job once its last subscriber is gone. h.mu
+// must be held.
+func (h *StatusHub) unsubscribe(sub *StatusSubscriber, jobID string) {
+	watch, ok := h.watches[jobID]
+	if !ok {
+		return
+	}
+	delete(watch.subscribers, sub)
+	if len(watch.subscribers) == 0 {
+		watch.cancel()
+		delete(h.watches, jobID)
+	}
+}
+
+// follow pushes a job's changes to its subscribers until it finishes or has
+// none left. It acts for the service itself, as subscribers were authorized
+// when they subscribed.
+func (h *StatusHub) follow(ctx context.Context, watch *jobWatch, job *domain.EncryptionJob) {
+	jobID, status, last := job.ID, job.Status, job.Progress
+
+	// Without a progress broker the channel is nil and the job is polled
+	progress, err := h.jobs.SubscribeToProgress(ctx, jobID)
+	if err != nil {
+		h.logger.Warn("Failed to subscribe to job progress", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-progress:
+			if !ok {
+				progress = nil
+				continue
+			}
+			if update != last {
+				last = update
+				h.broadcast(watch, jobID, StatusEvent{Type: StatusEventProgress, JobID: jobID, Progress: &update}, false)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		current, err := h.jobs.GetJobStatus(ctx, jobID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.broadcast(watch, jobID, StatusEvent{Type: StatusEventError, JobID: jobID, Error: err.Error()}, true)
+			return
+		}
+		switch {
+		case current.IsTerminal():
+			h.broadcast(watch, jobID, StatusEvent{Type: StatusEventStatus, JobID: jobID, Job: current.WithoutKeys()}, true)
+			return
+		case current.Status != status:
+			h.broadcast(watch, jobID, StatusEvent{Type: StatusEventStatus, JobID: jobID, Job: current.WithoutKeys()}, false)
+		case current.Progress != last:
+			update := current.Progress
+			h.broadcast(watch, jobID, StatusEvent{Type: StatusEventProgress, JobID: jobID, Progress: &update}, false)
+		}
+		status, last = current.Status, current.Progress
+	}
+}
+
+// broadcast sends an event to the subscribers of a watch, and stops following
+// the job if last is set. Watches that were replaced send nothing.
+func (h *StatusHub) broadcast(watch *jobWatch, jobID string, event StatusEvent, last bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watches[jobID] != watch {
+		return
+	}
+	for sub := range watch.subscribers {
+		if !sub.send(event) {
+			h.logger.Debug("Dropped status event for slow subscriber",
+				zap.String("job_id", jobID),
+				zap.String("type", event.Type))
+		}
+	}
+	if last {
+		watch.cancel()
+		delete(h.watches, jobID)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/primary/http/middleware"
+)
+
+// Limits of status sockets
+const (
+	MaxStatusSubscriptions = 1000     // Jobs one connection may follow at once
+	maxStatusSocketMessage = 64 << 10 // Largest message a client may send, in bytes
+)
+
+// Actions of status socket requests
+const (
+	StatusSocketSubscribe   = "subscribe"
+	StatusSocketUnsubscribe = "unsubscribe"
+)
+
+// statusSocketRequest is a message from a status socket client. A batch
+// stands for the jobs it created or acted on.
+type statusSocketRequest struct {
+	Action  string   `json:"action"`
+	JobIDs  []string `json:"job_ids,omitempty"`
+	BatchID string   `json:"batch_id,omitempty"`
+}
+
+// StatusSocketHandler pushes the status and progress of any number of jobs
+// over a WebSocket
+type StatusSocketHandler struct {
+	encryptionService ports.EncryptionService
+	hub               *StatusHub
+	logger            *zap.Logger
+}
+
+func NewStatusSocketHandler(service ports.EncryptionService, hub *StatusHub, logger *zap.Logger) *StatusSocketHandler {
+	return &StatusSocketHandler{
+		encryptionService: service,
+		hub:               hub,
+		logger:            logger,
+	}
+}
+
+// Serve upgrades the request to a WebSocket. Clients send
+// {"action": "subscribe", "job_ids": [...]} or {"action": "subscribe",
+// "batch_id": "..."}, and "unsubscribe" likewise, and receive the current
+// status of each job followed by its status, progress and error events.
+func (h *StatusSocketHandler) Serve(c *gin.Context) {
+	// The socket acts for the caller the request was authenticated as
+	ctx := c.Request.Context()
+	requestID := middleware.GetRequestID(c)
+
+	server := websocket.Server{
+		// Clients authenticate with an API key, so any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			h.serve(ctx, conn, requestID)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *StatusSocketHandler) serve(ctx context.Context, conn *websocket.Conn, requestID string) {
+	defer conn.Close()
+
+	// The socket may stay open far longer than the server's timeouts
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		h.logger.Warn("Failed to lift deadlines for status socket", zap.Error(err))
+	}
+	conn.MaxPayloadBytes = maxStatusSocketMessage
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub := h.hub.NewSubscriber()
+	defer h.hub.Close(sub)
+
+	// Events are written by one goroutine while requests are read here
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-sub.Events():
+				if err := websocket.JSON.Send(conn, event); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	subscribed := make(map[string]struct{})
+	for ctx.Err() == nil {
+		var req statusSocketRequest
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				sub.send(StatusEvent{Type: StatusEventError, Error: "messages must be JSON objects"})
+				continue
+			}
+			return // Closed by the client or too large
+		}
+
+		jobIDs, err := h.requestedJobs(ctx, req)
+		if err != nil {
+			sub.send(StatusEvent{Type: StatusEventError, Error: err.Error()})
+			continue
+		}
+
+		switch req.Action {
+		case StatusSocketSubscribe:
+			for _, jobID := range jobIDs {
+				if _, ok := subscribed[jobID]; ok {
+					continue
+				}
+				if len(subscribed) >= MaxStatusSubscriptions {
+					sub.send(StatusEvent{Type: StatusEventError, JobID: jobID, Error: fmt.Sprintf("at most %d jobs may be followed at once", MaxStatusSubscriptions)})
+					break
+				}
+				if err := h.hub.Subscribe(ctx, sub, jobID); err != nil {
+					sub.send(StatusEvent{Type: StatusEventError, JobID: jobID, Error: subscribeError(err)})
+					if !errors.Is(err, domain.ErrJobNotFound) && !errors.Is(err, domain.ErrForbidden) {
+						h.logger.Warn("Failed to subscribe to job status",
+							zap.String("request_id", requestID),
+							zap.String("job_id", jobID),
+							zap.Error(err))
+					}
+					continue
+				}
+				subscribed[jobID] = struct{}{}
+			}
+		case StatusSocketUnsubscribe:
+			for _, jobID := range jobIDs {
+				h.hub.Unsubscribe(sub, jobID)
+				delete(subscribed, jobID)
+			}
+		}
+	}
+}
+
+// requestedJobs validates a request and returns the jobs it names
+func (h *StatusSocketHandler) requestedJobs(ctx context.Context, req statusSocketRequest) ([]string, error) {
+	if req.Action != StatusSocketSubscribe && req.Action != StatusSocketUnsubscribe {
+		return nil, fmt.Errorf("action must be %q or %q", StatusSocketSubscribe, StatusSocketUnsubscribe)
+	}
+	if len(req.JobIDs) == 0 && req.BatchID == "" {
+		return nil, errors.New("job_ids or batch_id is required")
+	}
+
+	jobIDs := req.JobIDs
+	if req.BatchID != "" {
+		batch, err := h.encryptionService.GetBatchResult(ctx, req.BatchID)
+		if err != nil || batch == nil {
+			return nil, fmt.Errorf("batch %s not found", req.BatchID)
+		}
+		if err := domain.PrincipalFromContext(ctx).Authorize(batch.CreatedBy); err != nil {
+			return nil, fmt.Errorf("batch %s not found", req.BatchID)
+		}
+		jobIDs = append(jobIDs, batch.Successful...)
+	}
+	return jobIDs, nil
+}
+
+// subscribeError describes why a job cannot be followed
+func subscribeError(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrJobNotFound):
+		return "job not found"
+	case errors.Is(err, domain.ErrForbidden):
+		return "job belongs to another owner"
+	default:
+		return "job status is unavailable"
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// tusContentType is the content type of the chunks of resumable uploads
+const tusContentType = "application/offset+octet-stream"
+
+// SetResumableUploads serves resumable uploads with uploads
+func (h *EncryptionHandler) SetResumableUploads(uploads ports.ResumableUploads) {
+	h.resumableUploads = uploads
+}
+
+// TusOptions describes the tus protocol served, for clients discovering it
+func (h *EncryptionHandler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", domain.TusVersion)
+	c.Header("Tus-Version", domain.TusVersion)
+	c.Header("Tus-Extension", strings.Join(domain.TusExtensions, ","))
+	if h.maxUploadBytes > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.maxUploadBytes, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateResumableUpload announces a resumable upload of Upload-Length bytes.
+// Upload-Metadata may name the file with "filename" and give the options of
+// POST /encrypt as JSON with "request"; the job starts with them once the
+// upload is complete.
+func (h *EncryptionHandler) CreateResumableUpload(c *gin.Context) {
+	if !h.tusResumable(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		h.errorHandler.HandleValidationError(c, "Upload-Length", "Upload-Length must be the size of the upload in bytes")
+		return
+	}
+	metadata, err := domain.ParseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		h.errorHandler.HandleValidationError(c, "Upload-Metadata", err.Error())
+		return
+	}
+	var req domain.EncryptionRequest
+	if encoded, ok := metadata["request"]; ok {
+		if err := json.Unmarshal([]byte(encoded), &req); err != nil {
+			h.errorHandler.HandleBindError(c, fmt.Errorf("invalid request metadata: %w", err))
+			return
+		}
+	}
+
+	upload, err := h.resumableUploads.CreateUpload(c.Request.Context(), length, metadata["filename"], req)
+	if err != nil {
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			h.handleValidationErrors(c, req, validationErrs)
+			return
+		}
+		h.handleResumableError(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	h.setUploadHeaders(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+// ResumableUploadOffset reports how many bytes of an upload have arrived, so
+// an interrupted upload resumes from there
+func (h *EncryptionHandler) ResumableUploadOffset(c *gin.Context) {
+	if !h.tusResumable(c) {
+		return
+	}
+
+	upload, err := h.resumableUploads.GetUpload(c.Request.Context(), c.Param("uploadId"))
+	if err != nil {
+		h.handleResumableError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	h.setUploadHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// WriteResumableUpload appends the request body to an upload at
+// Upload-Offset. The chunk completing the upload starts its job, whose ID is
+// returned in X-Job-ID.
+func (h *EncryptionHandler) WriteResumableUpload(c *gin.Context) {
+	if !h.tusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		h.errorHandler.HandleError(c,
+			domain.StatusUnsupportedMediaType,
+			"Unsupported content type",
+			[]domain.BatchError{{
+				Field:   "Content-Type",
+				Message: "chunks must be sent as " + tusContentType,
+				Code:    domain.ErrCodeUnsupportedType,
+			}},
+		)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.errorHandler.HandleValidationError(c, "Upload-Offset", "Upload-Offset must be the number of bytes already uploaded")
+		return
+	}
+
+	h.extendUploadDeadlines(c)
+	upload, err := h.resumableUploads.WriteChunk(c.Request.Context(), c.Param("uploadId"), offset, c.Request.Body)
+	if err != nil {
+		if errors.Is(err, domain.ErrUploadOffset) || errors.Is(err, domain.ErrUploadTooLarge) {
+			// The connection cannot be reused without draining the body
+			c.Header("Connection", "close")
+		}
+		h.handleResumableError(c, err)
+		return
+	}
+
+	h.setUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteResumableUpload abandons an upload. A job it already started keeps
+// running.
+func (h *EncryptionHandler) DeleteResumableUpload(c *gin.Context) {
+	if !h.tusResumable(c) {
+		return
+	}
+
+	if err := h.resumableUploads.DeleteUpload(c.Request.Context(), c.Param("uploadId")); err != nil {
+		h.handleResumableError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// tusResumable checks that the client speaks the served version of the tus
+// protocol, answering with it either way
+func (h *EncryptionHandler) tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", domain.TusVersion)
+	if c.GetHeader("Tus-Resumable") == domain.TusVersion {
+		return true
+	}
+
+	c.Header("Tus-Version", domain.TusVersion)
+	h.errorHandler.HandleError(c,
+		domain.StatusPreconditionFailed,
+		"Unsupported tus version",
+		[]domain.BatchError{{
+			Field:   "Tus-Resumable",
+			Message: "Tus-Resumable must be " + domain.TusVersion,
+			Value:   c.GetHeader("Tus-Resumable"),
+			Code:    domain.ErrCodePrecondition,
+		}},
+	)
+	return false
+}
+
+// setUploadHeaders describes the progress of an upload
+func (h *EncryptionHandler) setUploadHeaders(c *gin.Context, upload *domain.ResumableUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.JobID != "" {
+		c.Header("X-Job-ID", upload.JobID)
+		return
+	}
+	c.Header("Upload-Expires", time.Unix(upload.ExpiresAt, 0).UTC().Format(http.TimeFormat))
+}
+
+// handleResumableError maps errors of the resumable upload endpoints to
+// responses. Errors starting the job of a complete upload are answered like
+// those of POST /encrypt/upload.
+func (h *EncryptionHandler) handleResumableError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUploadNotFound):
+		h.errorHandler.HandleNotFound(c, "upload", c.Param("uploadId"))
+	case errors.Is(err, domain.ErrUploadOffset):
+		h.errorHandler.HandleError(c,
+			domain.StatusConflict,
+			"Upload offset mismatch",
+			[]domain.BatchError{{
+				Field:   "Upload-Offset",
+				Message: err.Error(),
+				Value:   c.GetHeader("Upload-Offset"),
+				Code:    domain.ErrCodeUploadOffset,
+			}},
+		)
+	case errors.Is(err, domain.ErrUploadLocked):
+		h.errorHandler.HandleError(c,
+			domain.StatusLocked,
+			"Upload locked",
+			[]domain.BatchError{{
+				Field:   "upload",
+				Message: err.Error(),
+				Code:    domain.ErrCodeUploadLocked,
+			}},
+		)
+	case errors.Is(err, domain.ErrUploadTooLarge):
+		h.errorHandler.HandleError(c,
+			domain.StatusRequestTooLarge,
+			"Upload too large",
+			[]domain.BatchError{{
+				Field:   "Upload-Length",
+				Message: err.Error(),
+				Code:    domain.ErrCodeRequestTooLarge,
+			}},
+		)
+	default:
+		h.handleStartError(c, "file", "", err)
+	}
+}
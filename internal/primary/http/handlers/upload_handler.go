@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// uploadRequestLimit caps the request part of an upload
+const uploadRequestLimit = 1 << 20
+
+// SetUploadLimits caps the size of uploaded sources and the time one upload
+// may take, which replaces the server's read and write timeouts for it
+func (h *EncryptionHandler) SetUploadLimits(maxBytes int64, timeout time.Duration) {
+	h.maxUploadBytes = maxBytes
+	h.uploadTimeout = timeout
+}
+
+// StartUpload handles multipart uploads of sources that are not reachable by
+// URL. An optional "request" part holds the options of POST /encrypt as JSON
+// and must come before the "file" part, which is streamed to storage as it
+// arrives and becomes the source of a new job.
+func (h *EncryptionHandler) StartUpload(c *gin.Context) {
+	h.extendUploadDeadlines(c)
+	if h.maxUploadBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+uploadRequestLimit)
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Invalid request format",
+			[]domain.BatchError{{
+				Field:   "request",
+				Message: "uploads must be multipart/form-data",
+				Code:    domain.ErrCodeInvalidFormat,
+			}},
+		)
+		return
+	}
+
+	var req domain.EncryptionRequest
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.errorHandler.HandleBindError(c, err)
+			return
+		}
+
+		switch part.FormName() {
+		case "request":
+			if err := json.NewDecoder(io.LimitReader(part, uploadRequestLimit)).Decode(&req); err != nil {
+				h.errorHandler.HandleBindError(c, fmt.Errorf("invalid request part: %w", err))
+				return
+			}
+		case "file":
+			h.startUpload(c, req, part.FileName(), part)
+			return
+		}
+		part.Close()
+	}
+
+	h.errorHandler.HandleError(c,
+		domain.StatusBadRequest,
+		"Validation error",
+		[]domain.BatchError{domain.NewValidationError("file", "a file part is required", "")},
+	)
+}
+
+// startUpload starts the job of an upload whose file is content
+func (h *EncryptionHandler) startUpload(c *gin.Context, req domain.EncryptionRequest, filename string, content io.Reader) {
+	if err := req.ValidateUpload(); err != nil {
+		var validationErrs domain.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			h.handleValidationErrors(c, req, validationErrs)
+			return
+		}
+		h.errorHandler.HandleError(c,
+			domain.StatusBadRequest,
+			"Validation error",
+			[]domain.BatchError{domain.NewValidationError("request", err.Error(), "")},
+		)
+		return
+	}
+
+	job, err := h.encryptionService.StartUpload(c.Request.Context(), filename, content, req.JobOptions())
+	if err != nil {
+		if _, ok := bodyLimitExceeded(err); ok || errors.Is(err, domain.ErrUploadTooLarge) {
+			// The connection cannot be reused without draining the body
+			c.Header("Connection", "close")
+			h.errorHandler.HandleError(c,
+				domain.StatusRequestTooLarge,
+				"Upload too large",
+				[]domain.BatchError{{
+					Field:   "file",
+					Message: fmt.Sprintf("uploads must not exceed %d bytes", h.maxUploadBytes),
+					Code:    domain.ErrCodeRequestTooLarge,
+				}},
+			)
+			return
+		}
+		h.handleStartError(c, "file", filename, err)
+		return
+	}
+
+	h.setQuotaHeaders(c)
+	h.respondStarted(c, job, req.Reuse)
+}
+
+// extendUploadDeadlines gives the request the upload timeout to be read and
+// answered instead of the server's timeouts
+func (h *EncryptionHandler) extendUploadDeadlines(c *gin.Context) {
+	if h.uploadTimeout <= 0 {
+		return
+	}
+	rc := http.NewResponseController(c.Writer)
+	deadline := time.Now().Add(h.uploadTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		h.logger.Debug("Failed to extend upload read deadline", zap.Error(err))
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		h.logger.Debug("Failed to extend upload write deadline", zap.Error(err))
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// Limits of the webhook deliveries endpoint
+const (
+	DefaultWebhookDeliveryLimit = 100
+	MaxWebhookDeliveryLimit     = 1000
+)
+
+// WebhookHandler lets tenants register the webhooks their job events are
+// delivered to
+type WebhookHandler struct {
+	webhooks     ports.WebhookRegistry
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+func NewWebhookHandler(webhooks ports.WebhookRegistry, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks:     webhooks,
+		logger:       logger,
+		errorHandler: NewErrorHandler(logger),
+	}
+}
+
+// CreateWebhook registers a webhook for the caller's tenant
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req domain.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	webhook, err := h.webhooks.CreateWebhook(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks lists the webhooks of the caller's tenant. Admins may pass
+// ?tenant= to list another tenant's, or leave it out to list every tenant's.
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.ListWebhooks(c.Request.Context(), c.Query("tenant"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// GetWebhook returns a webhook without its secret
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, err := h.webhooks.GetWebhook(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, webhook)
+}
+
+// UpdateWebhook replaces a webhook's URL, events and, when one is given, its
+// secret
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req domain.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorHandler.HandleBindError(c, err)
+		return
+	}
+
+	webhook, err := h.webhooks.UpdateWebhook(c.Request.Context(), c.Param("webhookId"), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, webhook)
+}
+
+// DeleteWebhook stops a webhook from receiving events
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, err := h.webhooks.DeleteWebhook(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, webhook)
+}
+
+// ListDeliveries returns the deliveries to a webhook that failed for good,
+// newest first
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	webhookID := c.Param("webhookId")
+
+	limit := DefaultWebhookDeliveryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxWebhookDeliveryLimit {
+			h.errorHandler.HandleValidationError(c, "limit", fmt.Sprintf("limit must be between 1 and %d", MaxWebhookDeliveryLimit))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhooks.ListWebhookDeliveries(c.Request.Context(), webhookID, limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(domain.StatusOK, gin.H{
+		"webhook_id": webhookID,
+		"deliveries": deliveries,
+	})
+}
+
+// handleError maps errors of the webhook endpoints to responses
+func (h *WebhookHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		h.errorHandler.HandleForbidden(c, "webhook", c.Param("webhookId"))
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		h.errorHandler.HandleNotFound(c, "webhook", c.Param("webhookId"))
+	case errors.Is(err, domain.ErrInvalidWebhook):
+		h.errorHandler.HandleValidationError(c, "request", err.Error())
+	default:
+		h.errorHandler.HandleInternalError(c, err)
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// APIKeyHeader carries the caller's API key; "Authorization: Bearer <key>" is
+// accepted as well
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves API keys to the principals they act for
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (domain.Principal, error)
+}
+
+// Authenticate resolves the caller's API key to a principal and stores it in
+// the request context, rejecting requests without a known key
+func Authenticate(keys APIKeyAuthenticator, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				key = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			}
+		}
+
+		principal, err := keys.Authenticate(c.Request.Context(), key)
+		if err != nil && !errors.Is(err, domain.ErrUnauthenticated) {
+			logger.Error("Failed to authenticate API key",
+				zap.String("request_id", GetRequestID(c)),
+				zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, domain.NewBatchErrorResponse(
+				"Service temporarily unavailable",
+				[]domain.BatchError{{
+					Field:   "api_key",
+					Message: "API keys cannot be checked right now",
+					Code:    domain.ErrCodeUnavailable,
+				}},
+				nil,
+				GetRequestID(c),
+			))
+			return
+		}
+		if key == "" || err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, domain.NewBatchErrorResponse(
+				"Unauthorized",
+				[]domain.BatchError{{
+					Field:   "api_key",
+					Message: "a valid API key is required",
+					Code:    domain.ErrCodeUnauthorized,
+				}},
+				nil,
+				GetRequestID(c),
+			))
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// RequireJobScopes rejects callers without the jobs:read scope for reads, or
+// the jobs:write scope for anything else
+func RequireJobScopes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := domain.ScopeJobsWrite
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scope = domain.ScopeJobsRead
+		}
+		requireScope(c, scope)
+	}
+}
+
+// RequireScope rejects callers without scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireScope(c, scope)
+	}
+}
+
+func requireScope(c *gin.Context, scope string) {
+	if domain.PrincipalFromContext(c.Request.Context()).HasScope(scope) {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, domain.NewBatchErrorResponse(
+		"Forbidden",
+		[]domain.BatchError{{
+			Field:   "principal",
+			Message: fmt.Sprintf("this endpoint requires an API key with the %s scope", scope),
+			Value:   scope,
+			Code:    domain.ErrCodeForbidden,
+		}},
+		nil,
+		GetRequestID(c),
+	))
+}
+
+// RequireAdmin rejects callers that are not admins
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if domain.PrincipalFromContext(c.Request.Context()).Admin {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, domain.NewBatchErrorResponse(
+			"Forbidden",
+			[]domain.BatchError{{
+				Field:   "principal",
+				Message: "this endpoint requires an admin API key",
+				Code:    domain.ErrCodeForbidden,
+			}},
+			nil,
+			GetRequestID(c),
+		))
+	}
+}
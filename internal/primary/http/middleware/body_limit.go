@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/internal/core/domain"
+)
+
+// BodyLimit rejects requests whose declared Content-Length exceeds maxBytes
+// with 413 before anything reads the body, and caps bodies of unknown length
+// so reading past maxBytes fails. A maxBytes of 0 or less disables the limit.
+// Routes in exempt, such as uploads, are left to cap their own bodies.
+func BodyLimit(maxBytes int64, exempt ...string) gin.HandlerFunc {
+	exemptRoutes := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		exemptRoutes[route] = true
+	}
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody || exemptRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > maxBytes {
+			// The connection cannot be reused without draining the body
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, BodyTooLargeResponse(c, maxBytes))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// BodyTooLargeResponse describes a request rejected for its body size
+func BodyTooLargeResponse(c *gin.Context, maxBytes int64) domain.BatchErrorResponse {
+	return domain.NewBatchErrorResponse(
+		"Request body too large",
+		[]domain.BatchError{{
+			Field:   "request",
+			Message: fmt.Sprintf("request body must not exceed %d bytes", maxBytes),
+			Code:    domain.ErrCodeRequestTooLarge,
+		}},
+		nil,
+		GetRequestID(c),
+	)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -20,9 +21,10 @@ func CORS(config ...CORSConfig) gin.HandlerFunc {
 		header := c.Writer.Header()
 		
 		// Check allowed origins
-		if cfg.AllowOrigins[0] == "*" {
+		if len(cfg.AllowOrigins) > 0 && cfg.AllowOrigins[0] == "*" {
 			header.Set("Access-Control-Allow-Origin", "*")
 		} else if origin != "" {
+			header.Add("Vary", "Origin")
 			for _, allowedOrigin := range cfg.AllowOrigins {
 				if allowedOrigin == origin {
 					header.Set("Access-Control-Allow-Origin", origin)
@@ -46,7 +48,7 @@ func CORS(config ...CORSConfig) gin.HandlerFunc {
 				header.Set("Access-Control-Allow-Credentials", "true")
 			}
 			if cfg.MaxAge > 0 {
-				header.Set("Access-Control-Max-Age", cfg.MaxAge.String())
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
 			}
 			c.AbortWithStatus(204)
 			return
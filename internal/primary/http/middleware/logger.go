@@ -1,23 +1,13 @@
 package middleware
 
 import (
-	"bytes"
-	"io"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-)
-
-type bodyLogWriter struct {
-	gin.ResponseWriter
-	body *bytes.Buffer
-}
 
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
-}
+	"E.E/internal/core/domain"
+)
 
 // Logger middleware with configurable options
 func Logger(log *zap.Logger, config ...LogConfig) gin.HandlerFunc {
@@ -42,17 +32,6 @@ func Logger(log *zap.Logger, config ...LogConfig) gin.HandlerFunc {
 		query := c.Request.URL.RawQuery
 		requestID := GetRequestID(c)
 
-		// Read request body
-		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-		}
-
-		// Create custom response writer to capture response
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
-		c.Writer = blw
-
 		// Process request
 		c.Next()
 
@@ -71,6 +50,12 @@ func Logger(log *zap.Logger, config ...LogConfig) gin.HandlerFunc {
 			zap.Int("size", c.Writer.Size()),
 			zap.String("user_agent", c.Request.UserAgent()),
 		}
+		if principal := domain.PrincipalFromContext(c.Request.Context()); principal.ID != "" {
+			fields = append(fields, zap.String("principal", principal.ID))
+			if principal.KeyID != "" {
+				fields = append(fields, zap.String("key_id", principal.KeyID))
+			}
+		}
 
 		// Add custom fields if configured
 		if cfg.CustomFields != nil {
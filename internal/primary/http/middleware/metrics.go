@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/pkg/metrics"
+)
+
+// unmatchedPath labels requests that matched no route, so probes of arbitrary
+// paths do not add a series each
+const unmatchedPath = "unmatched"
+
+// Metrics records the count and duration of requests, labelled by the route
+// that served them rather than the request path
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = unmatchedPath
+		}
+		method := c.Request.Method
+		m.RecordHTTPRequest(method, path, c.Writer.Status())
+		m.ObserveHTTPRequestDuration(method, path, time.Since(start).Seconds())
+	}
+}
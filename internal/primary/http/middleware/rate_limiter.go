@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 
@@ -8,55 +11,149 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// RateLimitStore decides whether a client may make another request and, if
+// not, how long it should wait before retrying
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration)
+}
+
+// rateLimitEntry is a client's token bucket and when it was last used
+type rateLimitEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter is an in-process token bucket per client. Buckets idle for
+// longer than IdleTimeout are evicted and at most MaxClients are kept, so
+// memory stays bounded however many clients are seen.
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.RWMutex
-	config   RateLimitConfig
+	limiters  map[string]*rateLimitEntry
+	mu        sync.Mutex
+	config    RateLimitConfig
+	lastSweep time.Time
 }
 
 func NewRateLimiter(config RateLimitConfig) *RateLimiter {
 	if config.KeyFunc == nil {
 		config.KeyFunc = func(c *gin.Context) string { return c.ClientIP() }
 	}
-	
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultRateLimitConfig.IdleTimeout
+	}
+	if config.MaxClients <= 0 {
+		config.MaxClients = DefaultRateLimitConfig.MaxClients
+	}
+
 	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		config:   config,
+		limiters:  make(map[string]*rateLimitEntry),
+		config:    config,
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the client's bucket
+func (rl *RateLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	now := time.Now()
+	reservation := rl.getLimiter(key, now).ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, rl.config.TimeWindow
 	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Len returns the number of clients currently tracked
+func (rl *RateLimiter) Len() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.limiters)
 }
 
-func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
+func (rl *RateLimiter) getLimiter(key string, now time.Time) *rate.Limiter {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	limiter, exists := rl.limiters[key]
+	if now.Sub(rl.lastSweep) >= rl.config.IdleTimeout {
+		rl.evictIdle(now)
+		rl.lastSweep = now
+	}
+
+	entry, exists := rl.limiters[key]
 	if !exists {
-		limiter = rate.NewLimiter(rate.Every(rl.config.TimeWindow/time.Duration(rl.config.Requests)), rl.config.Requests)
-		rl.limiters[key] = limiter
+		if len(rl.limiters) >= rl.config.MaxClients {
+			rl.evictIdle(now)
+		}
+		if len(rl.limiters) >= rl.config.MaxClients {
+			rl.evictOldest()
+		}
+		entry = &rateLimitEntry{
+			limiter: rate.NewLimiter(rate.Every(rl.config.TimeWindow/time.Duration(rl.config.Requests)), rl.config.Requests),
+		}
+		rl.limiters[key] = entry
 	}
+	entry.lastSeen = now
 
-	return limiter
+	return entry.limiter
+}
+
+// evictIdle drops buckets unused for longer than the idle timeout. A bucket
+// idle that long has refilled, so dropping it does not change any decision.
+func (rl *RateLimiter) evictIdle(now time.Time) {
+	for key, entry := range rl.limiters {
+		if now.Sub(entry.lastSeen) >= rl.config.IdleTimeout {
+			delete(rl.limiters, key)
+		}
+	}
 }
 
-// RateLimit middleware with configurable options
+// evictOldest drops the least recently used bucket to make room for a new client
+func (rl *RateLimiter) evictOldest() {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+	for key, entry := range rl.limiters {
+		if oldestKey == "" || entry.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, entry.lastSeen
+		}
+	}
+	delete(rl.limiters, oldestKey)
+}
+
+// RateLimit middleware with configurable options. Clients are limited in
+// process unless config.Store shares the limit between instances.
 func RateLimit(config ...RateLimitConfig) gin.HandlerFunc {
 	cfg := DefaultRateLimitConfig
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = DefaultRateLimitConfig.KeyFunc
+	}
 
-	limiter := NewRateLimiter(cfg)
+	store := cfg.Store
+	if store == nil {
+		store = NewRateLimiter(cfg)
+	}
 
 	return func(c *gin.Context) {
 		key := cfg.KeyFunc(c)
-		if !limiter.getLimiter(key).Allow() {
+		allowed, retryAfter := store.Allow(c.Request.Context(), key)
+		if !allowed {
+			if retryAfter <= 0 {
+				retryAfter = cfg.TimeWindow
+			}
+			c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
 			c.JSON(429, gin.H{
 				"error": "Too many requests",
-				"retry_after": cfg.TimeWindow.Seconds(),
+				"retry_after": retryAfter.Seconds(),
 			})
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
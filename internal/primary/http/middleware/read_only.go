@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/internal/core/domain"
+)
+
+// ReadOnly rejects every request that could change state with 503, for an
+// API serving a replica during a regional failover
+func ReadOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, domain.NewBatchErrorResponse(
+			"Service is read-only",
+			[]domain.BatchError{{
+				Field:   "method",
+				Message: "this instance serves a replica and accepts no changes",
+				Value:   c.Request.Method,
+				Code:    domain.ErrCodeUnavailable,
+			}},
+			nil,
+			GetRequestID(c),
+		))
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/internal/core/domain"
+)
+
+// ReadinessChecker reports whether the service's dependencies are healthy
+type ReadinessChecker interface {
+	Ready() (bool, []string)
+	RetryAfter() time.Duration
+}
+
+// RequireReady rejects requests with 503 and a Retry-After header while any
+// dependency is unhealthy, so work is not accepted only to be lost
+func RequireReady(checker ReadinessChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ready, down := checker.Ready()
+		if ready {
+			c.Next()
+			return
+		}
+
+		errs := make([]domain.BatchError, 0, len(down))
+		for _, name := range down {
+			errs = append(errs, domain.BatchError{
+				Field:   "dependency",
+				Message: "dependency is unavailable",
+				Value:   name,
+				Code:    domain.ErrCodeUnavailable,
+			})
+		}
+
+		retryAfter := int(math.Ceil(checker.RetryAfter().Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, domain.NewBatchErrorResponse(
+			"Service temporarily unavailable",
+			errs,
+			nil,
+			GetRequestID(c),
+		))
+	}
+}
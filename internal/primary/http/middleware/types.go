@@ -22,6 +22,10 @@ type RateLimitConfig struct {
 	TimeWindow time.Duration
 	// Key function to identify clients (e.g., by IP, by API key)
 	KeyFunc    func(c *gin.Context) string
+	// Optional shared store; defaults to an in-process RateLimiter
+	Store       RateLimitStore
+	IdleTimeout time.Duration // In-process buckets unused this long are evicted
+	MaxClients  int           // In-process buckets kept at most
 }
 
 type LogConfig struct {
@@ -35,7 +39,7 @@ var (
 	DefaultCORSConfig = CORSConfig{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:          12 * time.Hour,
@@ -45,5 +49,7 @@ var (
 		Requests:   100,
 		TimeWindow: time.Minute,
 		KeyFunc:    func(c *gin.Context) string { return c.ClientIP() },
+		IdleTimeout: 10 * time.Minute,
+		MaxClients:  100000,
 	}
 )
\ No newline at end of file
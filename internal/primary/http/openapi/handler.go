@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SwaggerUIVersion is the swagger-ui-dist release the docs page loads
+const SwaggerUIVersion = "5.17.14"
+
+// Handler serves the OpenAPI document of a router and a Swagger UI for it
+type Handler struct {
+	router *gin.Engine
+
+	once sync.Once
+	doc  *Document
+}
+
+// NewHandler documents the routes of router. The document is built on the
+// first request, once every route is registered.
+func NewHandler(router *gin.Engine) *Handler {
+	return &Handler{router: router}
+}
+
+// Spec serves the OpenAPI document
+func (h *Handler) Spec(c *gin.Context) {
+	h.once.Do(func() {
+		h.doc = Build(h.router.Routes())
+	})
+	c.JSON(http.StatusOK, h.doc)
+}
+
+// UI serves a Swagger UI page for the document at /openapi.json
+func (h *Handler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>E.E API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + SwaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + SwaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,474 @@
+package openapi
+
+import (
+	"E.E/internal/core/domain"
+)
+
+// operation documents one route. Routes without one are still listed in the
+// document, with a generic response, so it never misses a route.
+type operation struct {
+	summary     string
+	description string // Notes beyond the summary, such as the route it is an alias of
+	tag         string
+	query       []query
+	request     interface{} // Zero value of the JSON body, if any
+	upload      bool        // The body is multipart: request as a "request" part, then a "file" part
+	status      int         // Success status; 200 when zero
+	response    interface{} // Zero value of the JSON success response, if any
+	produces    string      // Media type of a non-JSON success response
+	errors      []int       // Error statuses worth listing; every operation has a default error response
+}
+
+// query is a query string parameter of an operation
+type query struct {
+	name        string
+	kind        string // Schema type; string when empty
+	description string
+}
+
+// Response bodies the handlers build with gin.H
+
+type JobList struct {
+	Jobs       []*domain.EncryptionJob `json:"jobs"`
+	Pagination struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+		Total  int `json:"total"`
+	} `json:"pagination"`
+	Filter      domain.JobFilter       `json:"filter"`
+	Sort        domain.JobSort         `json:"sort"`
+	SortOptions map[string]interface{} `json:"sort_options"`
+	Warnings    []domain.ExpiryWarning `json:"warnings,omitempty"`
+}
+
+type JobAction struct {
+	JobID   string                  `json:"job_id"`
+	Status  domain.EncryptionStatus `json:"status"`
+	Message string                  `json:"message"`
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type BatchList struct {
+	Results []*domain.BatchResult `json:"results"`
+}
+
+type KeyAudit struct {
+	JobID  string                 `json:"job_id"`
+	Events []domain.KeyAuditEvent `json:"events"`
+}
+
+type ShareLinkList struct {
+	JobID string              `json:"job_id"`
+	Links []*domain.ShareLink `json:"links"`
+}
+
+type WebhookList struct {
+	Webhooks []*domain.WebhookConfig `json:"webhooks"`
+}
+
+type WebhookDeliveryList struct {
+	WebhookID  string                    `json:"webhook_id"`
+	Deliveries []*domain.WebhookDelivery `json:"deliveries"`
+}
+
+type RecurringBatchList struct {
+	RecurringBatches []*domain.RecurringBatch `json:"recurring_batches"`
+}
+
+type APIKeyList struct {
+	Keys []*domain.APIKey `json:"keys"`
+}
+
+// Query parameters shared by the job listing and export
+var jobFilterQuery = []query{
+	{name: "status", description: "Job status"},
+	{name: "source_url", description: "Exact source URL"},
+	{name: "min_progress", kind: "number", description: "Minimum progress percent"},
+	{name: "created_by", description: "Creator's API key owner"},
+	{name: "source_hash", description: "Source content digest, sha256:<hex>"},
+	{name: "start_date", description: "Created at or after, RFC 3339 or Unix seconds"},
+	{name: "end_date", description: "Created at or before, RFC 3339 or Unix seconds"},
+}
+
+// operations documents the routes by "METHOD /path", as gin registers them
+var operations = map[string]operation{
+	"GET /health": {
+		summary: "Report the service's health",
+		tag:     "health",
+		query:   []query{{name: "verbose", kind: "boolean", description: "Include each dependency's check"}},
+	},
+	"GET /metrics": {
+		summary:  "Prometheus metrics",
+		tag:      "health",
+		produces: "text/plain",
+	},
+
+	"POST /api/v1/encrypt": {
+		summary:  "Queue an encryption job, or one per source URL",
+		tag:      "jobs",
+		request:  domain.EncryptionRequest{},
+		status:   domain.StatusAccepted,
+		response: domain.EncryptionResponse{},
+	},
+	"POST /api/v1/encrypt/upload": {
+		summary:  "Upload a source and queue its encryption job",
+		tag:      "jobs",
+		request:  domain.EncryptionRequest{},
+		upload:   true,
+		status:   domain.StatusAccepted,
+		response: domain.EncryptionResponse{},
+	},
+	"OPTIONS /api/v1/uploads": {
+		summary: "Describe the tus protocol served for resumable uploads",
+		tag:     "uploads",
+		status:  204,
+	},
+	"POST /api/v1/uploads": {
+		summary: "Create a resumable upload of Upload-Length bytes; Upload-Metadata may carry filename and request",
+		tag:     "uploads",
+		status:  201,
+	},
+	"HEAD /api/v1/uploads/:uploadId": {
+		summary: "Get the Upload-Offset of a resumable upload",
+		tag:     "uploads",
+	},
+	"PATCH /api/v1/uploads/:uploadId": {
+		summary: "Append a chunk at Upload-Offset; the last one queues the job",
+		tag:     "uploads",
+		status:  204,
+	},
+	"DELETE /api/v1/uploads/:uploadId": {
+		summary: "Abandon a resumable upload",
+		tag:     "uploads",
+		status:  204,
+	},
+	"POST /api/v1/decrypt": {
+		summary:  "Queue a decryption job",
+		tag:      "jobs",
+		request:  domain.DecryptionRequest{},
+		status:   domain.StatusAccepted,
+		response: domain.EncryptionResponse{},
+	},
+	"GET /api/v1/status/:jobId": {
+		summary:  "Get a job",
+		tag:      "jobs",
+		response: domain.EncryptionJob{},
+	},
+	"GET /api/v1/status/:jobId/events": {
+		summary:  "Stream a job's status as server-sent events",
+		tag:      "jobs",
+		produces: "text/event-stream",
+	},
+	"GET /api/v1/ws": {
+		summary: "Subscribe to job status over a WebSocket",
+		tag:     "jobs",
+	},
+	"PATCH /api/v1/job/:jobId": {
+		summary:  "Update a job's metadata",
+		tag:      "jobs",
+		request:  domain.JobUpdateRequest{},
+		response: domain.EncryptionJob{},
+	},
+	"GET /api/v1/job/:jobId/result": {
+		summary:  "Get a completed job's result",
+		tag:      "jobs",
+		query:    []query{{name: "output", description: "Output of a multi-output job"}},
+		response: domain.JobResult{},
+	},
+	"POST /api/v1/job/:jobId/retention": {
+		summary:  "Extend how long a job's output is kept",
+		tag:      "jobs",
+		request:  domain.RetentionRequest{},
+		response: domain.EncryptionJob{},
+	},
+	"POST /api/v1/job/:jobId/pause": {
+		summary:  "Pause a job",
+		tag:      "jobs",
+		response: JobAction{},
+	},
+	"POST /api/v1/job/:jobId/resume": {
+		summary:  "Resume a paused job",
+		tag:      "jobs",
+		response: JobAction{},
+	},
+	"POST /api/v1/job/:jobId/stop": {
+		summary:  "Stop a job",
+		tag:      "jobs",
+		response: JobAction{},
+	},
+	"POST /api/v1/job/:jobId/cancel": {
+		summary:     "Stop a job",
+		description: "Alias of POST /api/v1/job/{jobId}/stop.",
+		tag:         "jobs",
+		response:    JobAction{},
+	},
+	"POST /api/v1/job/:jobId/retry": {
+		summary:  "Run a failed job again as a new job",
+		tag:      "jobs",
+		status:   domain.StatusAccepted,
+		response: domain.EncryptionResponse{},
+		errors:   []int{domain.StatusNotFound, domain.StatusConflict, domain.StatusUnprocessableEntity},
+	},
+	"PUT /api/v1/job/:jobId/schedule": {
+		summary:  "Move the start of a scheduled job",
+		tag:      "jobs",
+		request:  domain.ScheduleRequest{},
+		response: domain.EncryptionJob{},
+	},
+	"DELETE /api/v1/job/:jobId/schedule": {
+		summary:  "Cancel a scheduled job before its start",
+		tag:      "jobs",
+		response: JobAction{},
+	},
+	"POST /api/v1/engine/stop": {
+		summary:  "Stop the encryption engine (admin)",
+		tag:      "jobs",
+		response: Message{},
+	},
+	"GET /api/v1/jobs": {
+		summary: "List jobs",
+		tag:     "jobs",
+		query: append([]query{
+			{name: "limit", kind: "integer"},
+			{name: "offset", kind: "integer"},
+			{name: "sort_by", description: "Field to sort by; repeatable"},
+			{name: "order", description: "asc or desc, one per sort_by"},
+			{name: "case_sensitive", kind: "boolean", description: "One per sort_by"},
+		}, jobFilterQuery...),
+		response: JobList{},
+	},
+	"GET /api/v1/jobs/status": {
+		summary:  "Count jobs by status",
+		tag:      "jobs",
+		response: domain.JobStatusSummaryResponse{},
+	},
+	"GET /api/v1/jobs/export": {
+		summary: "Export jobs as NDJSON",
+		tag:     "jobs",
+		query: append([]query{
+			{name: "limit", kind: "integer"},
+			{name: "cursor", description: "next_cursor of the previous export"},
+		}, jobFilterQuery...),
+		produces: "application/x-ndjson",
+	},
+	"GET /api/v1/quota": {
+		summary:  "Get a tenant's quota and usage",
+		tag:      "jobs",
+		query:    []query{{name: "tenant", description: "Another tenant (admin)"}},
+		response: domain.QuotaStatus{},
+	},
+	"GET /api/v1/jobs/:jobId/key": {
+		summary: "Get a completed job's decryption key",
+		tag:     "keys",
+		query: []query{
+			{name: "output", description: "Output of a multi-output job"},
+			{name: "wrap_key", description: "RSA public key to wrap the key for"},
+			{name: "ttl_seconds", kind: "integer", description: "Lifetime of a wrapped key"},
+		},
+		response: domain.JobKey{},
+	},
+	"POST /api/v1/jobs/:jobId/verify": {
+		summary:  "Read a completed job's outputs back and check their checksums",
+		tag:      "jobs",
+		response: domain.JobVerification{},
+	},
+	"POST /api/v1/jobs/:jobId/rotate-key": {
+		summary:  "Re-encrypt a job's output under a new key and retire the old one",
+		tag:      "keys",
+		request:  domain.KeyRotationRequest{},
+		status:   domain.StatusAccepted,
+		response: domain.EncryptionResponse{},
+	},
+
+	"GET /api/v1/batch/:batchId": {
+		summary:  "Get the result of a batch operation",
+		tag:      "batches",
+		query:    []query{{name: "format", description: "json or csv"}},
+		response: domain.BatchResult{},
+	},
+	"POST /api/v1/batch/:batchId/rollback": {
+		summary:  "Stop the jobs a batch started",
+		tag:      "batches",
+		request:  domain.BatchRollbackRequest{},
+		response: domain.BatchResult{},
+	},
+	"GET /api/v1/batch": {
+		summary: "List batch results",
+		tag:     "batches",
+		query: []query{
+			{name: "status"},
+			{name: "created_by"},
+			{name: "job_ids", description: "Comma-separated job IDs"},
+		},
+		response: BatchList{},
+	},
+
+	"GET /api/v1/job/:jobId/keys/policy": {
+		summary:  "Get the access policy of a job's key",
+		tag:      "keys",
+		query:    []query{{name: "output", description: "Output of a multi-output job"}},
+		response: domain.KeyPolicy{},
+	},
+	"PUT /api/v1/job/:jobId/keys/policy": {
+		summary:  "Replace the access policy of a job's key",
+		tag:      "keys",
+		query:    []query{{name: "output", description: "Output of a multi-output job"}},
+		request:  domain.KeyPolicyRequest{},
+		response: domain.KeyPolicy{},
+	},
+	"POST /api/v1/job/:jobId/keys/token": {
+		summary:  "Issue a key delivery token",
+		tag:      "keys",
+		request:  domain.KeyTokenRequest{},
+		response: domain.KeyToken{},
+	},
+	"GET /api/v1/job/:jobId/keys/audit": {
+		summary:  "List the audit trail of a job's keys",
+		tag:      "keys",
+		query:    []query{{name: "limit", kind: "integer"}},
+		response: KeyAudit{},
+	},
+	"POST /api/v1/playback-tokens": {
+		summary:  "Issue a playback token and the URL of its key",
+		tag:      "keys",
+		request:  domain.PlaybackTokenRequest{},
+		response: domain.PlaybackToken{},
+	},
+
+	"POST /api/v1/job/:jobId/share": {
+		summary:  "Create a share link",
+		tag:      "sharing",
+		request:  domain.ShareLinkRequest{},
+		response: domain.ShareLink{},
+	},
+	"GET /api/v1/job/:jobId/share": {
+		summary:  "List a job's share links",
+		tag:      "sharing",
+		response: ShareLinkList{},
+	},
+	"DELETE /api/v1/job/:jobId/share/:linkId": {
+		summary:  "Revoke a share link",
+		tag:      "sharing",
+		response: domain.ShareLink{},
+	},
+
+	"POST /api/v1/webhooks": {
+		summary:  "Register a webhook",
+		tag:      "webhooks",
+		request:  domain.WebhookRequest{},
+		status:   201,
+		response: domain.WebhookConfig{},
+	},
+	"GET /api/v1/webhooks": {
+		summary:  "List webhooks",
+		tag:      "webhooks",
+		query:    []query{{name: "tenant", description: "Another tenant (admin)"}},
+		response: WebhookList{},
+	},
+	"GET /api/v1/webhooks/:webhookId": {
+		summary:  "Get a webhook",
+		tag:      "webhooks",
+		response: domain.WebhookConfig{},
+	},
+	"PUT /api/v1/webhooks/:webhookId": {
+		summary:  "Update a webhook",
+		tag:      "webhooks",
+		request:  domain.WebhookRequest{},
+		response: domain.WebhookConfig{},
+	},
+	"DELETE /api/v1/webhooks/:webhookId": {
+		summary:  "Delete a webhook",
+		tag:      "webhooks",
+		response: domain.WebhookConfig{},
+	},
+	"GET /api/v1/webhooks/:webhookId/deliveries": {
+		summary:  "List a webhook's failed deliveries",
+		tag:      "webhooks",
+		query:    []query{{name: "limit", kind: "integer"}},
+		response: WebhookDeliveryList{},
+	},
+
+	"POST /api/v1/recurring-batches": {
+		summary:  "Register a recurring batch",
+		tag:      "recurring batches",
+		request:  domain.RecurringRequest{},
+		status:   201,
+		response: domain.RecurringBatch{},
+	},
+	"GET /api/v1/recurring-batches": {
+		summary:  "List recurring batches",
+		tag:      "recurring batches",
+		query:    []query{{name: "tenant", description: "Another tenant (admin)"}},
+		response: RecurringBatchList{},
+	},
+	"GET /api/v1/recurring-batches/:recurringId": {
+		summary:  "Get a recurring batch",
+		tag:      "recurring batches",
+		response: domain.RecurringBatch{},
+	},
+	"PUT /api/v1/recurring-batches/:recurringId": {
+		summary:  "Update a recurring batch",
+		tag:      "recurring batches",
+		request:  domain.RecurringRequest{},
+		response: domain.RecurringBatch{},
+	},
+	"DELETE /api/v1/recurring-batches/:recurringId": {
+		summary:  "Delete a recurring batch",
+		tag:      "recurring batches",
+		response: domain.RecurringBatch{},
+	},
+
+	"POST /admin/jobs/import": {
+		summary:  "Import jobs from an NDJSON body",
+		tag:      "admin",
+		query:    []query{{name: "dry_run", kind: "boolean"}},
+		response: domain.ImportResult{},
+	},
+	"POST /admin/api-keys": {
+		summary:  "Create an API key",
+		tag:      "admin",
+		request:  domain.APIKeyRequest{},
+		status:   201,
+		response: domain.APIKey{},
+	},
+	"GET /admin/api-keys": {
+		summary:  "List API keys",
+		tag:      "admin",
+		query:    []query{{name: "owner"}},
+		response: APIKeyList{},
+	},
+	"GET /admin/api-keys/:keyId": {
+		summary:  "Get an API key",
+		tag:      "admin",
+		response: domain.APIKey{},
+	},
+	"DELETE /admin/api-keys/:keyId": {
+		summary:  "Revoke an API key",
+		tag:      "admin",
+		response: domain.APIKey{},
+	},
+
+	"POST /keys/v1/license": {
+		summary: "Get a ClearKey license with a key token",
+		tag:     "key delivery",
+	},
+	"GET /keys/v1/key": {
+		summary:  "Get a raw key with a key token",
+		tag:      "key delivery",
+		query:    []query{{name: "token"}},
+		produces: "application/octet-stream",
+	},
+	"GET /api/v1/keys/:keyId": {
+		summary:  "Get the raw key with a key ID for playback, with a playback token",
+		tag:      "key delivery",
+		query:    []query{{name: "token"}},
+		produces: "application/octet-stream",
+	},
+	"GET /share/v1/:token": {
+		summary: "Open a share link",
+		tag:     "sharing",
+	},
+}
@@ -0,0 +1,241 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document, built
+// from the routes registered on the router and the domain types the handlers
+// bind and return, so it cannot drift from either.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"E.E/internal/core/domain"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+	Security   []map[string][]string           `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	OperationID string                 `json:"operationId"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"` // Set to override the document's
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// APIVersion is the version of the documented API
+const APIVersion = "v1"
+
+// Prefixes of the routes that take an API key
+var authenticated = []string{"/api/v1/", "/admin/"}
+
+// Build describes routes, typically router.Routes() once every route is
+// registered. Routes under /openapi.json and /docs are left out.
+func Build(routes gin.RoutesInfo) *Document {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "E.E encryption service",
+			Version:     APIVersion,
+			Description: "Queues and tracks encryption jobs. Errors are returned as BatchErrorResponse.",
+		},
+		Paths: make(map[string]map[string]Operation),
+		Security: []map[string][]string{
+			{"apiKey": {}},
+			{"bearer": {}},
+		},
+	}
+
+	errorResponse := Response{
+		Description: "Error",
+		Content:     jsonContent(s.of(domain.BatchErrorResponse{})),
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	for _, route := range routes {
+		if route.Path == "/openapi.json" || route.Path == "/docs" {
+			continue
+		}
+		op := operations[route.Method+" "+route.Path]
+		path, params := pathTemplate(route.Path)
+
+		operation := Operation{
+			Summary:     op.summary,
+			Description: op.description,
+			OperationID: operationID(route.Method, route.Path),
+			Responses:   make(map[string]Response),
+		}
+		if op.tag != "" {
+			operation.Tags = []string{op.tag}
+		}
+		if !isAuthenticated(route.Path) {
+			operation.Security = &[]map[string][]string{} // Open to all
+		}
+		for _, name := range params {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, q := range op.query {
+			kind := q.kind
+			if kind == "" {
+				kind = "string"
+			}
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        q.name,
+				In:          "query",
+				Description: q.description,
+				Schema:      &Schema{Type: kind},
+			})
+		}
+		switch {
+		case op.upload:
+			operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"multipart/form-data": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"request": s.of(op.request),
+						"file":    {Type: "string", Format: "binary"},
+					},
+					Required: []string{"file"},
+				}},
+			}}
+		case op.request != nil:
+			operation.RequestBody = &RequestBody{Required: true, Content: jsonContent(s.of(op.request))}
+		}
+
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(status)}
+		switch {
+		case op.response != nil:
+			success.Content = jsonContent(s.of(op.response))
+		case op.produces != "":
+			success.Content = map[string]MediaType{op.produces: {}}
+		}
+		operation.Responses[fmt.Sprint(status)] = success
+		for _, code := range op.errors {
+			operation.Responses[fmt.Sprint(code)] = Response{
+				Description: http.StatusText(code),
+				Content:     errorResponse.Content,
+			}
+		}
+		operation.Responses["default"] = errorResponse
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	doc.Components = Components{
+		Schemas: s.components,
+		SecuritySchemes: map[string]SecurityScheme{
+			"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			"bearer": {Type: "http", Scheme: "bearer"},
+		},
+	}
+	return doc
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func isAuthenticated(path string) bool {
+	for _, prefix := range authenticated {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTemplate turns a gin path into an OpenAPI one, returning the names of
+// its parameters
+func pathTemplate(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID names an operation after its method and path, e.g.
+// getApiV1StatusJobId for GET /api/v1/status/:jobId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
@@ -7,20 +7,45 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"E.E/internal/core/domain"
 	"E.E/internal/primary/http/handlers"
 	"E.E/internal/primary/http/middleware"
+	"E.E/internal/primary/http/openapi"
 )
 
+// UploadRoute takes multipart uploads of sources. Its bodies are capped by
+// the upload size limit instead of the server's body limit, so servers must
+// exempt it.
+const UploadRoute = "/api/v1/encrypt/upload"
+
+// ResumableUploadRoute takes the chunks of resumable uploads, which are
+// capped by the upload size like UploadRoute
+const ResumableUploadRoute = "/api/v1/uploads/:uploadId"
 
 type RouterConfig struct {
 	EncryptionHandler *handlers.EncryptionHandler
 	BatchHandler      *handlers.BatchHandler
 	HealthHandler     *handlers.HealthHandler
+	KeyHandler        *handlers.KeyHandler          // Optional; serves key policies, tokens and deliveries
+	ShareHandler      *handlers.ShareHandler        // Optional; mints and serves share links
+	ImportHandler     *handlers.ImportHandler       // Optional; imports jobs migrated from other systems
+	StatusSocketHandler *handlers.StatusSocketHandler // Optional; pushes job status over WebSockets
+	Readiness         middleware.ReadinessChecker // Optional; gates job intake on dependency health
+	Authenticator     middleware.APIKeyAuthenticator // Optional; requires an API key on /api/v1
+	APIKeyHandler     *handlers.APIKeyHandler        // Optional; manages the API keys created through the API
+	WebhookHandler    *handlers.WebhookHandler       // Optional; manages the webhooks registered through the API
+	RecurringHandler  *handlers.RecurringHandler     // Optional; manages the batches started on a cron schedule
+	ReadOnly          bool                        // Rejects changes on /api/v1, for failover to a replica
+	Uploads           bool                        // Serves UploadRoute, creating jobs for uploaded sources
+	ResumableUploads  bool                        // Serves tus resumable uploads at /api/v1/uploads
 	Logger           *zap.Logger
 	RateLimit        struct {
 		Enabled    bool
-		Requests   int
-		TimeWindow time.Duration
+		Requests    int
+		TimeWindow  time.Duration
+		IdleTimeout time.Duration
+		MaxClients  int
+		Store       middleware.RateLimitStore // Optional; shares the limit between instances
 	}
 }
 
@@ -32,6 +57,9 @@ func SetupRouter(router *gin.Engine, cfg RouterConfig) {
 			Requests:   cfg.RateLimit.Requests,
 			TimeWindow: cfg.RateLimit.TimeWindow,
 			KeyFunc:    func(c *gin.Context) string { return c.ClientIP() }, // Default to IP-based rate limiting
+			Store:       cfg.RateLimit.Store,
+			IdleTimeout: cfg.RateLimit.IdleTimeout,
+			MaxClients:  cfg.RateLimit.MaxClients,
 		}
 		apiLimiter = middleware.RateLimit(rateLimitConfig)
 	}
@@ -42,25 +70,155 @@ func SetupRouter(router *gin.Engine, cfg RouterConfig) {
 	// Metrics endpoint (no rate limit)
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// OpenAPI document of every route and a Swagger UI for it (no rate limit)
+	docs := openapi.NewHandler(router)
+	router.GET("/openapi.json", docs.Spec)
+	router.GET("/docs", docs.UI)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	if apiLimiter != nil {
 		v1.Use(apiLimiter)
 	}
+	if cfg.Authenticator != nil {
+		v1.Use(middleware.Authenticate(cfg.Authenticator, cfg.Logger), middleware.RequireJobScopes())
+	}
+	if cfg.ReadOnly {
+		v1.Use(middleware.ReadOnly())
+	}
 	{
+		// Job intake fails fast while a dependency is down
+		intake := v1.Group("")
+		if cfg.Readiness != nil {
+			intake.Use(middleware.RequireReady(cfg.Readiness))
+		}
+
 		// Encryption endpoints
-		v1.POST("/encrypt", cfg.EncryptionHandler.StartEncryption)
+		intake.POST("/encrypt", cfg.EncryptionHandler.StartEncryption)
+		if cfg.Uploads {
+			intake.POST("/encrypt/upload", cfg.EncryptionHandler.StartUpload)
+		}
+		if cfg.ResumableUploads {
+			v1.OPTIONS("/uploads", cfg.EncryptionHandler.TusOptions)
+			intake.POST("/uploads", cfg.EncryptionHandler.CreateResumableUpload)
+			v1.HEAD("/uploads/:uploadId", cfg.EncryptionHandler.ResumableUploadOffset)
+			intake.PATCH("/uploads/:uploadId", cfg.EncryptionHandler.WriteResumableUpload)
+			v1.DELETE("/uploads/:uploadId", cfg.EncryptionHandler.DeleteResumableUpload)
+		}
+		intake.POST("/decrypt", cfg.EncryptionHandler.StartDecryption)
 		v1.GET("/status/:jobId", cfg.EncryptionHandler.GetStatus)
+		v1.GET("/status/:jobId/events", cfg.EncryptionHandler.StreamStatus)
+		if cfg.StatusSocketHandler != nil {
+			v1.GET("/ws", cfg.StatusSocketHandler.Serve)
+		}
+		v1.PATCH("/job/:jobId", cfg.EncryptionHandler.UpdateJob)
+		v1.GET("/job/:jobId/result", cfg.EncryptionHandler.GetJobResult)
+		v1.POST("/job/:jobId/retention", cfg.EncryptionHandler.ExtendRetention)
 		v1.POST("/job/:jobId/pause", cfg.EncryptionHandler.PauseJob)
 		v1.POST("/job/:jobId/resume", cfg.EncryptionHandler.ResumeJob)
 		v1.POST("/job/:jobId/stop", cfg.EncryptionHandler.StopJob)
-		v1.POST("/engine/stop", cfg.EncryptionHandler.StopEngine)
+		v1.POST("/job/:jobId/cancel", cfg.EncryptionHandler.StopJob)
+		intake.POST("/job/:jobId/retry", cfg.EncryptionHandler.RetryJob)
+		v1.PUT("/job/:jobId/schedule", cfg.EncryptionHandler.RescheduleJob)
+		v1.DELETE("/job/:jobId/schedule", cfg.EncryptionHandler.UnscheduleJob)
+		v1.POST("/engine/stop", middleware.RequireAdmin(), cfg.EncryptionHandler.StopEngine)
 		v1.GET("/jobs", cfg.EncryptionHandler.ListJobs)
 		v1.GET("/jobs/status", cfg.EncryptionHandler.JobsStatus)
+		v1.GET("/jobs/export", cfg.EncryptionHandler.ExportJobs)
+		v1.GET("/quota", cfg.EncryptionHandler.GetQuota)
+		v1.GET("/jobs/:jobId/key", middleware.RequireScope(domain.ScopeKeys), cfg.EncryptionHandler.GetJobKey)
+		v1.POST("/jobs/:jobId/verify", cfg.EncryptionHandler.VerifyJob)
+		intake.POST("/jobs/:jobId/rotate-key", middleware.RequireScope(domain.ScopeKeys), cfg.EncryptionHandler.RotateKey)
 
 		// Add batch endpoints
 		v1.GET("/batch/:batchId", cfg.BatchHandler.GetBatchOperation)
+		intake.POST("/batch/:batchId/rollback", cfg.BatchHandler.RollbackBatch)
 		v1.GET("/batch", cfg.BatchHandler.ListBatchResults)
+
+		// Key management endpoints
+		if cfg.KeyHandler != nil {
+			v1.GET("/job/:jobId/keys/policy", cfg.KeyHandler.GetPolicy)
+			v1.PUT("/job/:jobId/keys/policy", middleware.RequireScope(domain.ScopeKeys), cfg.KeyHandler.SetPolicy)
+			v1.POST("/job/:jobId/keys/token", middleware.RequireScope(domain.ScopeKeys), cfg.KeyHandler.IssueToken)
+			v1.GET("/job/:jobId/keys/audit", cfg.KeyHandler.GetAudit)
+			v1.POST("/playback-tokens", middleware.RequireScope(domain.ScopeKeys), cfg.KeyHandler.IssuePlaybackToken)
+		}
+
+		// Share link endpoints
+		if cfg.ShareHandler != nil {
+			v1.POST("/job/:jobId/share", cfg.ShareHandler.CreateLink)
+			v1.GET("/job/:jobId/share", cfg.ShareHandler.ListLinks)
+			v1.DELETE("/job/:jobId/share/:linkId", cfg.ShareHandler.RevokeLink)
+		}
+
+		// Webhook registration endpoints
+		if cfg.WebhookHandler != nil {
+			v1.POST("/webhooks", cfg.WebhookHandler.CreateWebhook)
+			v1.GET("/webhooks", cfg.WebhookHandler.ListWebhooks)
+			v1.GET("/webhooks/:webhookId", cfg.WebhookHandler.GetWebhook)
+			v1.PUT("/webhooks/:webhookId", cfg.WebhookHandler.UpdateWebhook)
+			v1.DELETE("/webhooks/:webhookId", cfg.WebhookHandler.DeleteWebhook)
+			v1.GET("/webhooks/:webhookId/deliveries", cfg.WebhookHandler.ListDeliveries)
+		}
+
+		// Recurring batch endpoints
+		if cfg.RecurringHandler != nil {
+			v1.POST("/recurring-batches", cfg.RecurringHandler.CreateRecurring)
+			v1.GET("/recurring-batches", cfg.RecurringHandler.ListRecurring)
+			v1.GET("/recurring-batches/:recurringId", cfg.RecurringHandler.GetRecurring)
+			v1.PUT("/recurring-batches/:recurringId", cfg.RecurringHandler.UpdateRecurring)
+			v1.DELETE("/recurring-batches/:recurringId", cfg.RecurringHandler.DeleteRecurring)
+		}
+	}
+
+	// Admin endpoints
+	if cfg.ImportHandler != nil || cfg.APIKeyHandler != nil {
+		admin := router.Group("/admin")
+		if apiLimiter != nil {
+			admin.Use(apiLimiter)
+		}
+		if cfg.Authenticator != nil {
+			admin.Use(middleware.Authenticate(cfg.Authenticator, cfg.Logger))
+		}
+		if cfg.ReadOnly {
+			admin.Use(middleware.ReadOnly())
+		}
+		admin.Use(middleware.RequireAdmin())
+		if cfg.ImportHandler != nil {
+			admin.POST("/jobs/import", cfg.ImportHandler.ImportJobs)
+		}
+		if cfg.APIKeyHandler != nil {
+			admin.POST("/api-keys", cfg.APIKeyHandler.CreateKey)
+			admin.GET("/api-keys", cfg.APIKeyHandler.ListKeys)
+			admin.GET("/api-keys/:keyId", cfg.APIKeyHandler.GetKey)
+			admin.DELETE("/api-keys/:keyId", cfg.APIKeyHandler.RevokeKey)
+		}
+	}
+
+	// Key delivery authenticates players and packagers by key token, not API key
+	if cfg.KeyHandler != nil {
+		keys := router.Group("/keys/v1")
+		if apiLimiter != nil {
+			keys.Use(apiLimiter)
+		}
+		keys.POST("/license", cfg.KeyHandler.License)
+		keys.GET("/key", cfg.KeyHandler.Key)
+
+		// Players fetch playback keys under /api/v1 with their playback token
+		playback := []gin.HandlerFunc{cfg.KeyHandler.GetPlaybackKey}
+		if apiLimiter != nil {
+			playback = append([]gin.HandlerFunc{apiLimiter}, playback...)
+		}
+		router.GET("/api/v1/keys/:keyId", playback...)
+	}
+
+	// Share links are opened by partners without an API key
+	if cfg.ShareHandler != nil {
+		share := router.Group("/share/v1")
+		if apiLimiter != nil {
+			share.Use(apiLimiter)
+		}
+		share.GET("/:token", cfg.ShareHandler.Open)
 	}
 
 	// Not found handler
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/services"
+	"E.E/internal/primary/http/handlers"
+	"E.E/internal/secondary/repository"
+)
+
+// testKeys maps the API keys of router tests to the principals they act for
+type testKeys map[string]domain.Principal
+
+func (k testKeys) Authenticate(ctx context.Context, key string) (domain.Principal, error) {
+	if p, ok := k[key]; ok {
+		return p, nil
+	}
+	return domain.Principal{}, domain.ErrUnauthenticated
+}
+
+// testPolicies is a key policy store without policies
+type testPolicies struct{}
+
+func (testPolicies) GetPolicy(ctx context.Context, key domain.ContentKey) (*domain.KeyPolicy, error) {
+	return nil, nil
+}
+
+func (testPolicies) SavePolicy(ctx context.Context, policy *domain.KeyPolicy) error {
+	return nil
+}
+
+func (testPolicies) CountDelivery(ctx context.Context, key domain.ContentKey, limit int) (bool, error) {
+	return true, nil
+}
+
+// testAudit is a key audit log that keeps nothing
+type testAudit struct{}
+
+func (testAudit) Record(ctx context.Context, event domain.KeyAuditEvent) error {
+	return nil
+}
+
+func (testAudit) List(ctx context.Context, jobID string, limit int) ([]domain.KeyAuditEvent, error) {
+	return nil, nil
+}
+
+// keyRouter returns a router serving the key endpoints for a completed job
+// of "studio", whose "writer" key has jobs:read and jobs:write and whose
+// "keys" key also has keys
+func keyRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	jobs := repository.NewMemoryRepository()
+	if err := jobs.Create(context.Background(), &domain.EncryptionJob{
+		ID:            "job-1",
+		Status:        domain.StatusCompleted,
+		CreatedBy:     "studio",
+		DecryptionKey: strings.Repeat("ab", 32),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	keyService := services.NewKeyService(jobs, testPolicies{}, testAudit{}, services.KeyServiceConfig{
+		TokenSecret: []byte("test-secret"),
+	}, nil, zap.NewNop())
+
+	router := gin.New()
+	SetupRouter(router, RouterConfig{
+		KeyHandler: handlers.NewKeyHandler(keyService, zap.NewNop()),
+		Authenticator: testKeys{
+			"writer": domain.NewPrincipal("studio", "", []string{domain.ScopeJobsRead, domain.ScopeJobsWrite}),
+			"keys":   domain.NewPrincipal("studio", "", []string{domain.ScopeJobsRead, domain.ScopeJobsWrite, domain.ScopeKeys}),
+		},
+		Logger: zap.NewNop(),
+	})
+	return router
+}
+
+func TestKeyRoutesRequireKeysScope(t *testing.T) {
+	router := keyRouter(t)
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/api/v1/job/job-1/keys/token", `{"client": "player-1"}`},
+		{http.MethodPut, "/api/v1/job/job-1/keys/policy", `{"clients": ["player-1"]}`},
+		{http.MethodPost, "/api/v1/playback-tokens", `{"job_id": "job-1"}`},
+	}
+	for _, r := range requests {
+		for key, want := range map[string]int{"writer": http.StatusForbidden, "keys": http.StatusOK} {
+			req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != want {
+				t.Errorf("%s %s with the %s key = %d, want %d: %s", r.method, r.path, key, w.Code, want, w.Body)
+			}
+		}
+	}
+}
@@ -3,47 +3,134 @@ package http
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 
 	"E.E/internal/primary/http/middleware"  // Import middleware from correct package
+	"E.E/pkg/metrics"
 )
 
+// ServerConfig holds the HTTP server tuning options
+type ServerConfig struct {
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	MaxBodyBytes int64 // Largest request body accepted, 0 for no limit
+	UploadRoutes []string // Routes exempt from MaxBodyBytes that cap their own bodies
+	CORS         middleware.CORSConfig
+
+	ReadHeaderTimeout time.Duration // Time allowed to read request headers, 0 to use ReadTimeout
+	MaxHeaderBytes    int           // Largest request header block accepted
+	KeepAlives        bool          // Keep HTTP/1.1 connections open between requests
+	MaxConnections    int           // Connections served at once, 0 for no limit
+
+	// HTTP/2 without TLS (h2c), by prior knowledge or upgrade, lets clients
+	// polling often multiplex their requests over one connection
+	H2C                  bool
+	MaxConcurrentStreams uint32 // Streams per HTTP/2 connection
+
+	Metrics *metrics.Metrics // Records request counts and durations; nil to record none
+}
+
+// DefaultServerConfig returns the server options used when none are configured
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+		MaxBodyBytes: 4 << 20,
+		CORS:         middleware.DefaultCORSConfig,
+
+		ReadHeaderTimeout:    5 * time.Second,
+		MaxHeaderBytes:       http.DefaultMaxHeaderBytes,
+		KeepAlives:           true,
+		MaxConcurrentStreams: 250,
+	}
+}
+
 type Server struct {
 	router *gin.Engine
 	logger *zap.Logger
+	config ServerConfig
 	srv    *http.Server
 }
 
-func NewServer(logger *zap.Logger) *Server {
+func NewServer(logger *zap.Logger, config ServerConfig) *Server {
 	router := gin.New()
 
 	// Add base middleware
 	router.Use(middleware.RequestID())
+	if config.Metrics != nil {
+		router.Use(middleware.Metrics(config.Metrics))
+	}
 	router.Use(middleware.Logger(logger))
 	router.Use(middleware.Recovery(logger))
-	router.Use(middleware.CORS())
+	router.Use(middleware.CORS(config.CORS))
+	router.Use(middleware.BodyLimit(config.MaxBodyBytes, config.UploadRoutes...))
 
 	return &Server{
 		router: router,
 		logger: logger,
+		config: config,
 	}
 }
 
 func (s *Server) Start(port int) error {
+	var handler http.Handler = s.router
+	h2s := &http2.Server{
+		MaxConcurrentStreams: s.config.MaxConcurrentStreams,
+		IdleTimeout:          s.config.IdleTimeout,
+	}
+	if s.config.H2C {
+		handler = h2c.NewHandler(handler, h2s)
+	}
+
 	s.srv = &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      s.router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadTimeout:       s.config.ReadTimeout,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+		MaxHeaderBytes:    s.config.MaxHeaderBytes,
+	}
+	s.srv.SetKeepAlivesEnabled(s.config.KeepAlives)
+	if s.config.H2C {
+		// Lets Shutdown close HTTP/2 connections gracefully as well
+		if err := http2.ConfigureServer(s.srv, h2s); err != nil {
+			return fmt.Errorf("failed to configure HTTP/2: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	if s.config.MaxConnections > 0 {
+		listener = netutil.LimitListener(listener, s.config.MaxConnections)
 	}
 
-	s.logger.Info("Starting HTTP server", zap.Int("port", port))
-	return s.srv.ListenAndServe()
+	s.logger.Info("Starting HTTP server",
+		zap.Int("port", port),
+		zap.Duration("read_timeout", s.config.ReadTimeout),
+		zap.Duration("read_header_timeout", s.config.ReadHeaderTimeout),
+		zap.Duration("write_timeout", s.config.WriteTimeout),
+		zap.Duration("idle_timeout", s.config.IdleTimeout),
+		zap.Bool("keep_alives", s.config.KeepAlives),
+		zap.Int("max_connections", s.config.MaxConnections),
+		zap.Bool("h2c", s.config.H2C),
+		zap.Uint32("max_concurrent_streams", s.config.MaxConcurrentStreams))
+	if err := s.srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
@@ -0,0 +1,395 @@
+// Package watch starts encryption jobs for files dropped into a folder
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/core/services"
+	"E.E/pkg/metrics"
+)
+
+// Subfolders of the watched folder. A picked up file waits in its own
+// processing/<claim> folder while its job runs, then moves to processed or
+// failed depending on the job's outcome.
+const (
+	ProcessingDir = "processing"
+	ProcessedDir  = "processed"
+	FailedDir     = "failed"
+)
+
+// Job metadata recorded for files picked up from the folder
+const (
+	metadataClaim = "watch_claim" // Names the processing folder, to find the job again after a restart
+	metadataFile  = "watch_file"  // Original file name
+)
+
+// Config sets how files are picked up and followed
+type Config struct {
+	Settle       time.Duration // A file is picked up once unchanged for this long
+	PollInterval time.Duration // Time between checks of the files and their jobs
+	RetryDelay   time.Duration // Wait before retrying a job that could not be created
+	Owner        string        // Recorded as the jobs' created_by
+}
+
+// pendingFile is a file seen in the folder that is not picked up yet
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	stable  time.Time // When the size and modification time were last seen changing
+}
+
+// claimedFile is a file moved to its processing folder
+type claimedFile struct {
+	name    string // File name within the processing folder
+	known   bool   // Whether jobID is known; false for files left by an earlier run until their job is looked up
+	jobID   string // Empty until the job is created
+	retryAt time.Time
+}
+
+// FolderWatcher starts an encryption job for every file dropped into a folder
+// and moves the file to processed or failed once the job finishes. The folder
+// must lie inside the storage root so that workers can read the files.
+type FolderWatcher struct {
+	dir     string
+	jobs    ports.EncryptionService
+	config  Config
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	watcher *fsnotify.Watcher
+	pending map[string]*pendingFile // By file name
+	claimed map[string]*claimedFile // By claim
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewFolderWatcher creates a watcher for dir, creating its subfolders.
+// metrics may be nil.
+func NewFolderWatcher(dir string, jobs ports.EncryptionService, config Config, metrics *metrics.Metrics, logger *zap.Logger) (*FolderWatcher, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve watch folder: %w", err)
+	}
+	for _, sub := range []string{ProcessingDir, ProcessedDir, FailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create watch folder: %w", err)
+		}
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+
+	return &FolderWatcher{
+		dir:     dir,
+		jobs:    jobs,
+		config:  config,
+		metrics: metrics,
+		logger:  logger.With(zap.String("watch_dir", dir)),
+		pending: make(map[string]*pendingFile),
+		claimed: make(map[string]*claimedFile),
+	}, nil
+}
+
+// Start picks up the files already in the folder and watches it for new ones
+// until Stop is called. Files left in processing by an earlier run are
+// followed again.
+func (w *FolderWatcher) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create folder watcher: %w", err)
+	}
+	if err := watcher.Add(w.dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", w.dir, err)
+	}
+	w.watcher = watcher
+
+	ctx, stop := context.WithCancel(context.Background())
+	w.stop = stop
+	w.done = make(chan struct{})
+
+	w.recover(ctx)
+	w.scan()
+	go w.run(ctx)
+
+	w.logger.Info("Watching folder for new files",
+		zap.Duration("settle", w.config.Settle),
+		zap.Int("recovered", len(w.claimed)))
+	return nil
+}
+
+// Stop stops watching. Files being processed stay in their processing
+// folders and are followed again on the next start.
+func (w *FolderWatcher) Stop(ctx context.Context) error {
+	if w.stop == nil {
+		return nil
+	}
+	w.stop()
+	select {
+	case <-w.done:
+		return w.watcher.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *FolderWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				w.track(filepath.Base(event.Name))
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been lost; a scan finds any file they announced
+			w.logger.Warn("Folder watch error", zap.Error(err))
+			w.scan()
+		case <-ticker.C:
+			w.pickUp(ctx)
+			w.follow(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scan tracks every file in the folder
+func (w *FolderWatcher) scan() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		w.logger.Error("Failed to read watch folder", zap.Error(err))
+		return
+	}
+	for _, entry := range entries {
+		w.track(entry.Name())
+	}
+}
+
+// track starts waiting for a file to settle. Folders and hidden files, such
+// as partial uploads, are ignored.
+func (w *FolderWatcher) track(name string) {
+	if strings.HasPrefix(name, ".") {
+		return
+	}
+	if _, ok := w.pending[name]; !ok {
+		w.pending[name] = &pendingFile{size: -1}
+	}
+}
+
+// pickUp claims the files that stopped changing
+func (w *FolderWatcher) pickUp(ctx context.Context) {
+	now := time.Now()
+	for name, file := range w.pending {
+		info, err := os.Stat(filepath.Join(w.dir, name))
+		if err != nil || !info.Mode().IsRegular() {
+			delete(w.pending, name)
+			continue
+		}
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size, file.modTime, file.stable = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(file.stable) < w.config.Settle {
+			continue
+		}
+
+		delete(w.pending, name)
+		w.claim(ctx, name)
+	}
+}
+
+// claim moves a file to its own processing folder and creates its job
+func (w *FolderWatcher) claim(ctx context.Context, name string) {
+	claim := uuid.New().String()
+	if err := os.Mkdir(filepath.Join(w.dir, ProcessingDir, claim), 0755); err != nil {
+		w.logger.Error("Failed to create processing folder", zap.String("file", name), zap.Error(err))
+		return
+	}
+	if err := os.Rename(filepath.Join(w.dir, name), w.processingPath(claim, name)); err != nil {
+		os.Remove(filepath.Join(w.dir, ProcessingDir, claim))
+		w.logger.Error("Failed to move file to processing", zap.String("file", name), zap.Error(err))
+		return
+	}
+
+	w.claimed[claim] = &claimedFile{name: name, known: true}
+	w.createJob(ctx, claim)
+}
+
+// createJob creates the job of a claimed file. A file whose job can never be
+// created moves to failed; other errors are retried after RetryDelay.
+func (w *FolderWatcher) createJob(ctx context.Context, claim string) {
+	file := w.claimed[claim]
+	if w.config.Owner != "" {
+		ctx = domain.ContextWithPrincipal(ctx, domain.Principal{ID: w.config.Owner})
+	}
+
+	sourceURL := (&url.URL{Scheme: "file", Path: w.processingPath(claim, file.name)}).String()
+	job, err := w.jobs.StartEncryption(ctx, sourceURL, domain.JobOptions{
+		Metadata: map[string]string{metadataClaim: claim, metadataFile: file.name},
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrUnsupportedMedia) || errors.Is(err, domain.ErrSourceRejected) {
+			w.record(services.IngestRejected)
+			w.logger.Warn("Rejected dropped file", zap.String("file", file.name), zap.Error(err))
+			w.finish(claim, FailedDir)
+			return
+		}
+		w.record(services.IngestFailed)
+		file.retryAt = time.Now().Add(w.config.RetryDelay)
+		w.logger.Error("Failed to create job for dropped file",
+			zap.String("file", file.name),
+			zap.Duration("retry_in", w.config.RetryDelay),
+			zap.Error(err))
+		return
+	}
+
+	w.record(services.IngestCreated)
+	file.jobID = job.ID
+	w.logger.Info("Created job for dropped file",
+		zap.String("job_id", job.ID),
+		zap.String("file", file.name))
+}
+
+// follow moves the files whose jobs finished and retries failed creations
+func (w *FolderWatcher) follow(ctx context.Context) {
+	now := time.Now()
+	for claim, file := range w.claimed {
+		if !file.known {
+			w.lookup(ctx, claim)
+		}
+		if !file.known {
+			continue
+		}
+		if file.jobID == "" {
+			if now.After(file.retryAt) {
+				w.createJob(ctx, claim)
+			}
+			continue
+		}
+
+		job, err := w.jobs.GetJobStatus(ctx, file.jobID)
+		if errors.Is(err, domain.ErrJobNotFound) {
+			w.logger.Warn("Job of dropped file no longer exists",
+				zap.String("job_id", file.jobID),
+				zap.String("file", file.name))
+			w.finish(claim, FailedDir)
+			continue
+		}
+		if err != nil {
+			w.logger.Warn("Failed to check job of dropped file",
+				zap.String("job_id", file.jobID),
+				zap.Error(err))
+			continue
+		}
+
+		switch job.Status {
+		case domain.StatusCompleted:
+			w.finish(claim, ProcessedDir)
+		case domain.StatusFailed, domain.StatusCancelled:
+			w.logger.Warn("Job of dropped file did not complete",
+				zap.String("job_id", job.ID),
+				zap.String("file", file.name),
+				zap.String("status", string(job.Status)))
+			w.finish(claim, FailedDir)
+		}
+	}
+}
+
+// finish moves a claimed file to the processed or failed folder, keeping its
+// name unless a file of that name is already there
+func (w *FolderWatcher) finish(claim, dest string) {
+	file := w.claimed[claim]
+	delete(w.claimed, claim)
+
+	target := filepath.Join(w.dir, dest, file.name)
+	if _, err := os.Lstat(target); err == nil {
+		ext := filepath.Ext(file.name)
+		target = filepath.Join(w.dir, dest, strings.TrimSuffix(file.name, ext)+"."+claim[:8]+ext)
+	}
+	if err := os.Rename(w.processingPath(claim, file.name), target); err != nil {
+		w.logger.Error("Failed to move dropped file",
+			zap.String("file", file.name),
+			zap.String("to", dest),
+			zap.Error(err))
+		return
+	}
+	os.Remove(filepath.Join(w.dir, ProcessingDir, claim))
+
+	w.logger.Info("Moved dropped file",
+		zap.String("file", file.name),
+		zap.String("job_id", file.jobID),
+		zap.String("to", dest))
+}
+
+// recover follows the files an earlier run left in processing, finding their
+// jobs by claim
+func (w *FolderWatcher) recover(ctx context.Context) {
+	entries, err := os.ReadDir(filepath.Join(w.dir, ProcessingDir))
+	if err != nil {
+		w.logger.Error("Failed to read processing folder", zap.Error(err))
+		return
+	}
+	for _, entry := range entries {
+		claim := entry.Name()
+		files, err := os.ReadDir(filepath.Join(w.dir, ProcessingDir, claim))
+		if !entry.IsDir() || err != nil || len(files) != 1 {
+			w.logger.Warn("Ignoring unexpected entry in processing folder", zap.String("entry", claim))
+			continue
+		}
+
+		w.claimed[claim] = &claimedFile{name: files[0].Name()}
+		w.lookup(ctx, claim)
+	}
+}
+
+// lookup finds the job of a file left in processing by an earlier run. A file
+// without one had not got its job created yet.
+func (w *FolderWatcher) lookup(ctx context.Context, claim string) {
+	file := w.claimed[claim]
+	jobs, err := w.jobs.ListJobs(ctx, 1, 0, domain.JobFilter{
+		Metadata: map[string]string{metadataClaim: claim},
+	}, domain.JobSort{})
+	if err != nil {
+		w.logger.Warn("Failed to find job of dropped file", zap.String("file", file.name), zap.Error(err))
+		return
+	}
+	file.known = true
+	if len(jobs) > 0 {
+		file.jobID = jobs[0].ID
+	}
+}
+
+func (w *FolderWatcher) processingPath(claim, name string) string {
+	return filepath.Join(w.dir, ProcessingDir, claim, name)
+}
+
+func (w *FolderWatcher) record(outcome string) {
+	if w.metrics != nil {
+		w.metrics.RecordIngestEvent("watch", outcome)
+	}
+}
@@ -0,0 +1,56 @@
+package chaos
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// EncryptionEngine delays encryptions to simulate slow or overloaded workers
+type EncryptionEngine struct {
+	ports.EncryptionEngine
+	injector *Injector
+}
+
+func NewEncryptionEngine(engine ports.EncryptionEngine, injector *Injector) *EncryptionEngine {
+	return &EncryptionEngine{EncryptionEngine: engine, injector: injector}
+}
+
+func (e *EncryptionEngine) Encrypt(input io.Reader, output io.Writer, params domain.EngineParams) (string, error) {
+	e.slow()
+	return e.EncryptionEngine.Encrypt(input, output, params)
+}
+
+func (e *EncryptionEngine) EncryptWithKey(input io.Reader, output io.Writer, key string, params domain.EngineParams) error {
+	e.slow()
+	return e.EncryptionEngine.EncryptWithKey(input, output, key, params)
+}
+
+// slow delays an encryption if a slow encryption is injected
+func (e *EncryptionEngine) slow() {
+	if e.injector.roll(e.injector.config.SlowEncryptionRate) {
+		e.injector.record(FaultSlowEncryption, "engine.encrypt")
+		time.Sleep(e.injector.jitter(e.injector.config.SlowEncryptionDelay))
+	}
+}
+
+// BeginStream and ResumeStream pass checkpointed encryptions through to the
+// engine, if it can encrypt in pieces
+func (e *EncryptionEngine) BeginStream(output io.Writer, key string, params domain.EngineParams) (ports.EncryptionStream, error) {
+	streaming, ok := e.EncryptionEngine.(ports.StreamingEncryptionEngine)
+	if !ok {
+		return nil, errors.New("engine cannot encrypt in pieces")
+	}
+	return streaming.BeginStream(output, key, params)
+}
+
+func (e *EncryptionEngine) ResumeStream(header io.Reader, key string, chunk uint32) (ports.EncryptionStream, error) {
+	streaming, ok := e.EncryptionEngine.(ports.StreamingEncryptionEngine)
+	if !ok {
+		return nil, errors.New("engine cannot encrypt in pieces")
+	}
+	return streaming.ResumeStream(header, key, chunk)
+}
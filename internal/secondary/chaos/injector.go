@@ -0,0 +1,94 @@
+// Package chaos wraps the service's adapters to inject faults at configured
+// probabilities, so retry, dead-letter and alerting paths can be exercised
+// outside of production.
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/pkg/metrics"
+)
+
+// Fault kinds
+const (
+	FaultStorage        = "storage_failure"
+	FaultRedisTimeout   = "redis_timeout"
+	FaultSlowEncryption = "slow_encryption"
+)
+
+// Config sets how often each fault is injected. Rates are probabilities
+// between 0 and 1.
+type Config struct {
+	StorageFailureRate  float64
+	RedisTimeoutRate    float64
+	RedisTimeoutDelay   time.Duration // How long an injected timeout blocks before failing
+	SlowEncryptionRate  float64
+	SlowEncryptionDelay time.Duration // Maximum delay added to a slow encryption
+	Seed                int64         // Random seed; 0 seeds from the clock
+}
+
+// Injector decides when to inject faults
+type Injector struct {
+	config  Config
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewInjector creates an injector. metrics may be nil.
+func NewInjector(config Config, metrics *metrics.Metrics, logger *zap.Logger) *Injector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		config:  config,
+		metrics: metrics,
+		logger:  logger,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// roll reports whether a fault with the given rate should be injected now
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < rate
+}
+
+// jitter returns a random duration up to max
+func (i *Injector) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Duration(i.rng.Int63n(int64(max)))
+}
+
+func (i *Injector) record(fault, operation string) {
+	i.logger.Warn("Chaos fault injected", zap.String("fault", fault), zap.String("operation", operation))
+	if i.metrics != nil {
+		i.metrics.RecordChaosFault(fault)
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
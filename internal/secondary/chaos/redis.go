@@ -0,0 +1,170 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// redisTimeout blocks for the configured delay and returns a timeout error
+// when a Redis timeout is rolled
+func (i *Injector) redisTimeout(ctx context.Context, operation string) error {
+	if !i.roll(i.config.RedisTimeoutRate) {
+		return nil
+	}
+	i.record(FaultRedisTimeout, operation)
+	sleep(ctx, i.config.RedisTimeoutDelay)
+	return fmt.Errorf("chaos: injected redis timeout in %s: %w", operation, context.DeadlineExceeded)
+}
+
+// JobRepository injects Redis timeouts into a job repository
+type JobRepository struct {
+	ports.JobRepository
+	injector *Injector
+}
+
+func NewJobRepository(repository ports.JobRepository, injector *Injector) *JobRepository {
+	return &JobRepository{JobRepository: repository, injector: injector}
+}
+
+func (r *JobRepository) Create(ctx context.Context, job *domain.EncryptionJob) error {
+	if err := r.injector.redisTimeout(ctx, "job.create"); err != nil {
+		return err
+	}
+	return r.JobRepository.Create(ctx, job)
+}
+
+func (r *JobRepository) Update(ctx context.Context, job *domain.EncryptionJob) error {
+	if err := r.injector.redisTimeout(ctx, "job.update"); err != nil {
+		return err
+	}
+	return r.JobRepository.Update(ctx, job)
+}
+
+func (r *JobRepository) UpdateIf(ctx context.Context, job *domain.EncryptionJob, cond func(current *domain.EncryptionJob) bool) (bool, error) {
+	if err := r.injector.redisTimeout(ctx, "job.update"); err != nil {
+		return false, err
+	}
+	return r.JobRepository.UpdateIf(ctx, job, cond)
+}
+
+func (r *JobRepository) Get(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
+	if err := r.injector.redisTimeout(ctx, "job.get"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.Get(ctx, jobID)
+}
+
+func (r *JobRepository) GetMany(ctx context.Context, jobIDs []string) ([]*domain.EncryptionJob, error) {
+	if err := r.injector.redisTimeout(ctx, "job.get_many"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.GetMany(ctx, jobIDs)
+}
+
+func (r *JobRepository) List(ctx context.Context) ([]*domain.EncryptionJob, error) {
+	if err := r.injector.redisTimeout(ctx, "job.list"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.List(ctx)
+}
+
+func (r *JobRepository) Query(ctx context.Context, query domain.JobQuery) ([]*domain.EncryptionJob, error) {
+	if err := r.injector.redisTimeout(ctx, "job.query"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.Query(ctx, query)
+}
+
+func (r *JobRepository) Aggregate(ctx context.Context, now time.Time, latest int) (*domain.JobAggregates, error) {
+	if err := r.injector.redisTimeout(ctx, "job.aggregate"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.Aggregate(ctx, now, latest)
+}
+
+func (r *JobRepository) TenantUsage(ctx context.Context, tenant string, since time.Time) (*domain.QuotaUsage, error) {
+	if err := r.injector.redisTimeout(ctx, "job.tenant_usage"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.TenantUsage(ctx, tenant, since)
+}
+
+func (r *JobRepository) Delete(ctx context.Context, jobID string) error {
+	if err := r.injector.redisTimeout(ctx, "job.delete"); err != nil {
+		return err
+	}
+	return r.JobRepository.Delete(ctx, jobID)
+}
+
+func (r *JobRepository) HealthCheck(ctx context.Context) error {
+	if err := r.injector.redisTimeout(ctx, "job.health_check"); err != nil {
+		return err
+	}
+	return r.JobRepository.HealthCheck(ctx)
+}
+
+func (r *JobRepository) AddJobHistory(ctx context.Context, jobID string, entry domain.JobHistoryEntry) error {
+	if err := r.injector.redisTimeout(ctx, "job.add_history"); err != nil {
+		return err
+	}
+	return r.JobRepository.AddJobHistory(ctx, jobID, entry)
+}
+
+func (r *JobRepository) GetJobHistory(ctx context.Context, jobID string) ([]domain.JobHistoryEntry, error) {
+	if err := r.injector.redisTimeout(ctx, "job.get_history"); err != nil {
+		return nil, err
+	}
+	return r.JobRepository.GetJobHistory(ctx, jobID)
+}
+
+// BatchRepository injects Redis timeouts into a batch repository
+type BatchRepository struct {
+	ports.BatchRepository
+	injector *Injector
+}
+
+func NewBatchRepository(repository ports.BatchRepository, injector *Injector) *BatchRepository {
+	return &BatchRepository{BatchRepository: repository, injector: injector}
+}
+
+func (r *BatchRepository) StoreBatchResult(ctx context.Context, result *domain.BatchResult) error {
+	if err := r.injector.redisTimeout(ctx, "batch.store"); err != nil {
+		return err
+	}
+	return r.BatchRepository.StoreBatchResult(ctx, result)
+}
+
+func (r *BatchRepository) GetBatchResult(ctx context.Context, batchID string) (*domain.BatchResult, error) {
+	if err := r.injector.redisTimeout(ctx, "batch.get"); err != nil {
+		return nil, err
+	}
+	return r.BatchRepository.GetBatchResult(ctx, batchID)
+}
+
+func (r *BatchRepository) ListBatchResults(ctx context.Context, filter domain.BatchFilter) ([]*domain.BatchResult, error) {
+	if err := r.injector.redisTimeout(ctx, "batch.list"); err != nil {
+		return nil, err
+	}
+	return r.BatchRepository.ListBatchResults(ctx, filter)
+}
+
+// JobQueue injects Redis timeouts into job queue operations
+type JobQueue struct {
+	ports.JobQueue
+	injector *Injector
+}
+
+func NewJobQueue(queue ports.JobQueue, injector *Injector) *JobQueue {
+	return &JobQueue{JobQueue: queue, injector: injector}
+}
+
+func (q *JobQueue) Enqueue(ctx context.Context, jobID string) error {
+	if err := q.injector.redisTimeout(ctx, "queue.enqueue"); err != nil {
+		return err
+	}
+	return q.JobQueue.Enqueue(ctx, jobID)
+}
@@ -0,0 +1,79 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"E.E/internal/core/ports"
+)
+
+// storageFailure returns an error when a storage failure is rolled
+func (i *Injector) storageFailure(operation, path string) error {
+	if !i.roll(i.config.StorageFailureRate) {
+		return nil
+	}
+	i.record(FaultStorage, operation)
+	return fmt.Errorf("chaos: injected storage failure in %s for %s", operation, path)
+}
+
+// FileStorage injects failures into file storage operations
+type FileStorage struct {
+	ports.FileStorage
+	injector *Injector
+}
+
+func NewFileStorage(storage ports.FileStorage, injector *Injector) *FileStorage {
+	return &FileStorage{FileStorage: storage, injector: injector}
+}
+
+func (s *FileStorage) ReadFile(path string) (io.ReadCloser, error) {
+	if err := s.injector.storageFailure("storage.read", path); err != nil {
+		return nil, err
+	}
+	return s.FileStorage.ReadFile(path)
+}
+
+func (s *FileStorage) WriteFile(path string, content io.Reader) error {
+	if err := s.injector.storageFailure("storage.write", path); err != nil {
+		return err
+	}
+	return s.FileStorage.WriteFile(path, content)
+}
+
+func (s *FileStorage) DeleteFile(path string) error {
+	if err := s.injector.storageFailure("storage.delete", path); err != nil {
+		return err
+	}
+	return s.FileStorage.DeleteFile(path)
+}
+
+// SourceFetcher injects failures when opening job sources
+type SourceFetcher struct {
+	ports.SourceFetcher
+	injector *Injector
+}
+
+func NewSourceFetcher(fetcher ports.SourceFetcher, injector *Injector) *SourceFetcher {
+	return &SourceFetcher{SourceFetcher: fetcher, injector: injector}
+}
+
+func (f *SourceFetcher) Open(ctx context.Context, sourceURL string) (io.ReadCloser, int64, error) {
+	if err := f.injector.storageFailure("source.open", sourceURL); err != nil {
+		return nil, 0, err
+	}
+	return f.SourceFetcher.Open(ctx, sourceURL)
+}
+
+// OpenAt opens sources partway through the fetcher, if it can, so resumed jobs
+// do not download what they already encrypted
+func (f *SourceFetcher) OpenAt(ctx context.Context, sourceURL string, offset int64) (io.ReadCloser, int64, error) {
+	if err := f.injector.storageFailure("source.open", sourceURL); err != nil {
+		return nil, 0, err
+	}
+	ranged, ok := f.SourceFetcher.(ports.SourceRangeFetcher)
+	if !ok {
+		return nil, 0, fmt.Errorf("source fetcher cannot open %s partway", sourceURL)
+	}
+	return ranged.OpenAt(ctx, sourceURL, offset)
+}
@@ -0,0 +1,448 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+const (
+	nonceSize       = 12
+	noncePrefixSize = 8
+	chunkHeaderSize = 5 // final flag + ciphertext length
+)
+
+var (
+	magicV1 = [4]byte{'E', 'E', 'G', '1'}
+	magicV2 = [4]byte{'E', 'E', 'G', '2'}
+)
+
+// base64Magic starts the base64 container of v1 and v2 streams, the
+// encoding of "EEG"
+var base64Magic = []byte("RUVH")
+
+// Identifiers of the algorithm and IV strategy in v2 stream headers
+var (
+	algorithmIDs = map[string]byte{
+		domain.AlgorithmAES256GCM:        1,
+		domain.AlgorithmChaCha20Poly1305: 2,
+		domain.AlgorithmAES128GCM:        3,
+	}
+	ivStrategyIDs = map[string]byte{
+		domain.IVStrategyCounter: 1,
+		domain.IVStrategyRandom:  2,
+	}
+)
+
+// AEADEngine encrypts streams as a sequence of independently sealed chunks,
+// using AES-GCM with 256- or 128-bit keys or ChaCha20-Poly1305. With the counter IV strategy each
+// chunk nonce is a random per-stream prefix followed by the chunk counter;
+// with the random strategy every chunk gets a fresh random nonce stored in
+// front of it. The chunk counter and a final flag are authenticated with each
+// chunk, so reordered or truncated outputs fail to decrypt.
+//
+// Layout: magic(4) | algorithm(1) | iv strategy(1) | chunk size(4) | nonce prefix(8) | chunks...
+// Chunk:  final flag(1) | ciphertext length(4) | [nonce(12)] | ciphertext+tag
+//
+// Streams written before algorithms were selectable use the v1 layout
+// (AES-256-GCM, counter nonces, final flag only as additional data) and can
+// still be decrypted:
+//
+// Layout: magic(4) | chunk size(4) | nonce prefix(8) | chunks...
+//
+// Streams in the base64 container are the same stream base64 encoded, and
+// are told apart from binary ones by their first bytes.
+type AEADEngine struct{}
+
+// NewAEADEngine creates an encryption engine
+func NewAEADEngine() *AEADEngine {
+	return &AEADEngine{}
+}
+
+// GenerateKey returns a new random key for the algorithm of params, hex
+// encoded
+func (e *AEADEngine) GenerateKey(params domain.EngineParams) (string, error) {
+	keyLength := domain.AlgorithmKeyLength(params.WithDefaults().Algorithm)
+	if keyLength == 0 {
+		return "", fmt.Errorf("unsupported algorithm %q", params.Algorithm)
+	}
+	key := make([]byte, keyLength/8)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Encrypt encrypts input to output with a freshly generated key and returns the key
+func (e *AEADEngine) Encrypt(input io.Reader, output io.Writer, params domain.EngineParams) (string, error) {
+	key, err := e.GenerateKey(params)
+	if err != nil {
+		return "", err
+	}
+	if err := e.EncryptWithKey(input, output, key, params); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// EncryptWithKey encrypts input to output using the given hex encoded key
+func (e *AEADEngine) EncryptWithKey(input io.Reader, output io.Writer, key string, params domain.EngineParams) error {
+	switch params.WithDefaults().Container {
+	case domain.ContainerStream:
+	case domain.ContainerBase64:
+		encoder := base64.NewEncoder(base64.StdEncoding, output)
+		if err := e.encryptStream(input, encoder, key, params); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported container %q", params.Container)
+	}
+	return e.encryptStream(input, output, key, params)
+}
+
+// encryptStream encrypts input to output as a binary stream
+func (e *AEADEngine) encryptStream(input io.Reader, output io.Writer, key string, params domain.EngineParams) error {
+	stream, err := e.beginStream(output, key, params)
+	if err != nil {
+		return err
+	}
+
+	reader := getReader(input, stream.chunkSize)
+	defer putReader(reader)
+	plaintextBuf := getBuffer(stream.chunkSize)
+	defer putBuffer(plaintextBuf)
+	sealedBuf := getBuffer(stream.chunkSize + stream.aead.Overhead())
+	defer putBuffer(sealedBuf)
+	plaintext := *plaintextBuf
+	stream.sealed = (*sealedBuf)[:0]
+
+	for {
+		n, err := io.ReadFull(reader, plaintext)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		final := err != nil
+		if !final {
+			// A full chunk is only final if nothing follows it
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				final = true
+			}
+		}
+
+		if err := stream.Seal(output, plaintext[:n], final); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// BeginStream writes the header of a v2 stream to output and returns the
+// stream, for callers that encrypt it in pieces. Only binary streams can be.
+func (e *AEADEngine) BeginStream(output io.Writer, key string, params domain.EngineParams) (ports.EncryptionStream, error) {
+	if container := params.WithDefaults().Container; container != domain.ContainerStream {
+		return nil, fmt.Errorf("%s streams cannot be encrypted in pieces", container)
+	}
+	return e.beginStream(output, key, params)
+}
+
+func (e *AEADEngine) beginStream(output io.Writer, key string, params domain.EngineParams) (*aeadStream, error) {
+	params = params.WithDefaults()
+	algorithmID, ok := algorithmIDs[params.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", params.Algorithm)
+	}
+	strategyID, ok := ivStrategyIDs[params.IVStrategy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported IV strategy %q", params.IVStrategy)
+	}
+	if params.ChunkSize <= 0 || params.ChunkSize > domain.MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d bytes", domain.MaxChunkSize)
+	}
+
+	var header [4 + 1 + 1 + 4 + noncePrefixSize]byte
+	copy(header[:4], magicV2[:])
+	header[4] = algorithmID
+	header[5] = strategyID
+	binary.BigEndian.PutUint32(header[6:10], uint32(params.ChunkSize))
+	if _, err := rand.Read(header[10:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	stream, err := newStream(key, params, header[10:], 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := output.Write(header[:]); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return stream, nil
+}
+
+// ResumeStream continues the v2 stream whose header is read from header,
+// from the chunk with index chunk
+func (e *AEADEngine) ResumeStream(header io.Reader, key string, chunk uint32) (ports.EncryptionStream, error) {
+	params, noncePrefix, legacy, err := readHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if legacy {
+		return nil, errors.New("v1 streams cannot be resumed")
+	}
+	return newStream(key, params, noncePrefix[:], chunk)
+}
+
+// Decrypt decrypts input produced by Encrypt to output. The container is
+// told by the first bytes, and the algorithm, IV strategy and chunk size are
+// read from the stream header.
+func (e *AEADEngine) Decrypt(input io.Reader, output io.Writer, key string) error {
+	buffered := bufio.NewReader(input)
+	if start, _ := buffered.Peek(len(base64Magic)); bytes.Equal(start, base64Magic) {
+		input = base64.NewDecoder(base64.StdEncoding, buffered)
+	} else {
+		input = buffered
+	}
+
+	params, noncePrefix, legacy, err := readHeader(input)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(params.Algorithm, key)
+	if err != nil {
+		return err
+	}
+	randomNonces := params.IVStrategy == domain.IVStrategyRandom
+	maxSealed := params.ChunkSize + aead.Overhead()
+
+	nonce := make([]byte, nonceSize)
+	copy(nonce, noncePrefix[:])
+	sealedBuf := getBuffer(maxSealed)
+	defer putBuffer(sealedBuf)
+	plaintextBuf := getBuffer(params.ChunkSize)
+	defer putBuffer(plaintextBuf)
+	sealed, plaintext := *sealedBuf, (*plaintextBuf)[:0]
+
+	for counter := uint32(0); ; counter++ {
+		var chunkHeader [chunkHeaderSize]byte
+		if _, err := io.ReadFull(input, chunkHeader[:]); err != nil {
+			if err == io.EOF {
+				return errors.New("encrypted stream is truncated")
+			}
+			return fmt.Errorf("failed to read chunk header: %w", err)
+		}
+
+		final := chunkHeader[0] == 1
+		length := int(binary.BigEndian.Uint32(chunkHeader[1:]))
+		if length > maxSealed {
+			return errors.New("encrypted chunk exceeds chunk size")
+		}
+		if randomNonces {
+			if _, err := io.ReadFull(input, nonce); err != nil {
+				return fmt.Errorf("failed to read chunk nonce: %w", err)
+			}
+		} else {
+			binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+		}
+		if _, err := io.ReadFull(input, sealed[:length]); err != nil {
+			return fmt.Errorf("failed to read chunk: %w", err)
+		}
+
+		aad := chunkHeader[:1]
+		if !legacy {
+			aad = chunkAAD(final, counter)
+		}
+		plaintext, err = aead.Open(plaintext[:0], nonce, sealed[:length], aad)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt chunk %d: %w", counter, err)
+		}
+		if _, err := output.Write(plaintext); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
+
+// readHeader reads the header of a v1 or v2 stream
+func readHeader(input io.Reader) (params domain.EngineParams, noncePrefix [noncePrefixSize]byte, legacy bool, err error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(input, magic[:]); err != nil {
+		return params, noncePrefix, false, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	switch magic {
+	case magicV1:
+		var header [4 + noncePrefixSize]byte
+		if _, err := io.ReadFull(input, header[:]); err != nil {
+			return params, noncePrefix, false, fmt.Errorf("failed to read header: %w", err)
+		}
+		params = domain.EngineParams{
+			Algorithm:  domain.AlgorithmAES256GCM,
+			ChunkSize:  int(binary.BigEndian.Uint32(header[:4])),
+			IVStrategy: domain.IVStrategyCounter,
+		}
+		copy(noncePrefix[:], header[4:])
+		legacy = true
+	case magicV2:
+		var header [1 + 1 + 4 + noncePrefixSize]byte
+		if _, err := io.ReadFull(input, header[:]); err != nil {
+			return params, noncePrefix, false, fmt.Errorf("failed to read header: %w", err)
+		}
+		params.Algorithm = lookupID(algorithmIDs, header[0])
+		params.IVStrategy = lookupID(ivStrategyIDs, header[1])
+		if params.Algorithm == "" || params.IVStrategy == "" {
+			return params, noncePrefix, false, errors.New("encrypted stream uses an unknown algorithm or IV strategy")
+		}
+		params.ChunkSize = int(binary.BigEndian.Uint32(header[2:6]))
+		copy(noncePrefix[:], header[6:])
+	default:
+		return params, noncePrefix, false, errors.New("input is not an encrypted stream")
+	}
+	if params.ChunkSize <= 0 || params.ChunkSize > domain.MaxChunkSize {
+		return params, noncePrefix, false, errors.New("encrypted stream has an invalid chunk size")
+	}
+	return params, noncePrefix, legacy, nil
+}
+
+// aeadStream seals the chunks of a v2 stream in order
+type aeadStream struct {
+	aead         cipher.AEAD
+	chunkSize    int
+	randomNonces bool
+	nonce        []byte
+	counter      uint32
+	sealed       []byte
+	done         bool
+}
+
+func newStream(key string, params domain.EngineParams, noncePrefix []byte, chunk uint32) (*aeadStream, error) {
+	aead, err := newAEAD(params.Algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	copy(nonce, noncePrefix)
+	return &aeadStream{
+		aead:         aead,
+		chunkSize:    params.ChunkSize,
+		randomNonces: params.IVStrategy == domain.IVStrategyRandom,
+		nonce:        nonce,
+		counter:      chunk,
+	}, nil
+}
+
+func (s *aeadStream) ChunkSize() int {
+	return s.chunkSize
+}
+
+func (s *aeadStream) Chunk() uint32 {
+	return s.counter
+}
+
+func (s *aeadStream) Seal(output io.Writer, plaintext []byte, final bool) error {
+	if s.done {
+		return errors.New("stream is already finished")
+	}
+	if len(plaintext) > s.chunkSize {
+		return fmt.Errorf("chunk exceeds chunk size of %d bytes", s.chunkSize)
+	}
+
+	if s.randomNonces {
+		if _, err := rand.Read(s.nonce); err != nil {
+			return fmt.Errorf("failed to generate nonce: %w", err)
+		}
+	} else {
+		binary.BigEndian.PutUint32(s.nonce[noncePrefixSize:], s.counter)
+	}
+	aad := chunkAAD(final, s.counter)
+	s.sealed = s.aead.Seal(s.sealed[:0], s.nonce, plaintext, aad)
+
+	var chunkHeader [chunkHeaderSize]byte
+	chunkHeader[0] = aad[0]
+	binary.BigEndian.PutUint32(chunkHeader[1:], uint32(len(s.sealed)))
+	if _, err := output.Write(chunkHeader[:]); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if s.randomNonces {
+		if _, err := output.Write(s.nonce); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	if _, err := output.Write(s.sealed); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	if final {
+		s.done = true
+		return nil
+	}
+	if s.counter == ^uint32(0) {
+		return errors.New("input too large for chunk counter")
+	}
+	s.counter++
+	return nil
+}
+
+// chunkAAD returns the additional data authenticated with a v2 chunk
+func chunkAAD(final bool, counter uint32) []byte {
+	aad := make([]byte, 5)
+	if final {
+		aad[0] = 1
+	}
+	binary.BigEndian.PutUint32(aad[1:], counter)
+	return aad
+}
+
+func lookupID(ids map[string]byte, id byte) string {
+	for name, v := range ids {
+		if v == id {
+			return name
+		}
+	}
+	return ""
+}
+
+func newAEAD(algorithm, key string) (cipher.AEAD, error) {
+	keySize := domain.AlgorithmKeyLength(algorithm) / 8
+	if keySize == 0 {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	raw, err := hex.DecodeString(key)
+	if err != nil || len(raw) != keySize {
+		return nil, fmt.Errorf("key must be %d hex encoded bytes for %s", keySize, algorithm)
+	}
+	switch algorithm {
+	case domain.AlgorithmAES256GCM, domain.AlgorithmAES128GCM:
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		return cipher.NewGCM(block)
+	case domain.AlgorithmChaCha20Poly1305:
+		return chacha20poly1305.New(raw)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+
+	"E.E/internal/core/domain"
+)
+
+// benchmarkChunkSizes are the chunk sizes the encryption benchmarks use,
+// from the default up to one of the largest jobs may ask for
+var benchmarkChunkSizes = []int{64 << 10, 1 << 20, 4 << 20}
+
+// benchmarkInputSize is how much each benchmark operation encrypts
+const benchmarkInputSize = 8 << 20
+
+// BenchmarkEncryptPooled encrypts with the chunk buffers taken from their
+// pools, as the engine does, by many goroutines at once, as when many jobs
+// encrypt concurrently
+func BenchmarkEncryptPooled(b *testing.B) {
+	benchmarkEncrypt(b, NewAEADEngine().encryptStream)
+}
+
+// BenchmarkEncryptUnpooled encrypts with chunk buffers allocated for each
+// stream, as the engine did before they were pooled
+func BenchmarkEncryptUnpooled(b *testing.B) {
+	benchmarkEncrypt(b, encryptStreamUnpooled)
+}
+
+func benchmarkEncrypt(b *testing.B, encrypt func(input io.Reader, output io.Writer, key string, params domain.EngineParams) error) {
+	input := make([]byte, benchmarkInputSize)
+	if _, err := rand.Read(input); err != nil {
+		b.Fatal(err)
+	}
+	engine := NewAEADEngine()
+
+	for _, chunkSize := range benchmarkChunkSizes {
+		params := domain.EngineParams{ChunkSize: chunkSize}.WithDefaults()
+		key, err := engine.GenerateKey(params)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("chunk=%dKiB", chunkSize>>10), func(b *testing.B) {
+			b.SetBytes(benchmarkInputSize)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := encrypt(bytes.NewReader(input), io.Discard, key, params); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// encryptStreamUnpooled is encryptStream with its reader and chunk buffers
+// allocated rather than pooled
+func encryptStreamUnpooled(input io.Reader, output io.Writer, key string, params domain.EngineParams) error {
+	stream, err := NewAEADEngine().beginStream(output, key, params)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(input, stream.chunkSize)
+	plaintext := make([]byte, stream.chunkSize)
+	stream.sealed = make([]byte, 0, stream.chunkSize+stream.aead.Overhead())
+
+	for {
+		n, err := io.ReadFull(reader, plaintext)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		final := err != nil
+		if !final {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				final = true
+			}
+		}
+
+		if err := stream.Seal(output, plaintext[:n], final); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// Chunk buffers are as large as a job's chunk size, up to 16 MiB, so
+// allocating them per stream puts heavy pressure on the garbage collector when
+// many jobs encrypt at once. They are pooled by size instead; jobs share a
+// handful of chunk sizes, so each pool stays warm.
+var (
+	chunkBuffers sizedPools
+	chunkReaders sizedPools
+)
+
+// sizedPools keeps one sync.Pool per buffer size
+type sizedPools struct {
+	pools sync.Map // int -> *sync.Pool
+}
+
+func (s *sizedPools) pool(size int) *sync.Pool {
+	if pool, ok := s.pools.Load(size); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := s.pools.LoadOrStore(size, &sync.Pool{})
+	return pool.(*sync.Pool)
+}
+
+// getBuffer returns a buffer of length size. The pointer is handed back to
+// putBuffer so pooling it does not allocate.
+func getBuffer(size int) *[]byte {
+	if buf, ok := chunkBuffers.pool(size).Get().(*[]byte); ok {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+// putBuffer returns a buffer obtained from getBuffer to its pool
+func putBuffer(buf *[]byte) {
+	*buf = (*buf)[:cap(*buf)]
+	chunkBuffers.pool(len(*buf)).Put(buf)
+}
+
+// getReader returns a buffered reader of input with a buffer of size bytes
+func getReader(input io.Reader, size int) *bufio.Reader {
+	if reader, ok := chunkReaders.pool(size).Get().(*bufio.Reader); ok {
+		reader.Reset(input)
+		return reader
+	}
+	return bufio.NewReaderSize(input, size)
+}
+
+// putReader returns a reader obtained from getReader to its pool, dropping its
+// reference to the input
+func putReader(reader *bufio.Reader) {
+	size := reader.Size()
+	reader.Reset(nil)
+	chunkReaders.pool(size).Put(reader)
+}
@@ -0,0 +1,447 @@
+// Package gcs stores files in Google Cloud Storage through its JSON API
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultEndpoint is the JSON API of Google Cloud Storage
+	defaultEndpoint = "https://storage.googleapis.com"
+
+	// chunkAlignment is the size resumable upload chunks are a multiple of
+	chunkAlignment = 256 << 10
+
+	// retryDelay is the backoff before the second attempt, doubled for
+	// each further one
+	retryDelay = 200 * time.Millisecond
+)
+
+// Config locates the JSON API and sets how requests are sent
+type Config struct {
+	Endpoint        string        // e.g. for an emulator; Google Cloud Storage when empty
+	CredentialsFile string        // Service account key; GOOGLE_APPLICATION_CREDENTIALS, then the metadata server, when empty
+	ChunkSize       int           // Bytes per request of an upload, a multiple of 256 KiB
+	MaxAttempts     int           // Attempts per request, retried with backoff
+	Timeout         time.Duration // Per request, other than those sending or receiving object contents
+}
+
+// Client calls the Cloud Storage JSON API
+type Client struct {
+	config      Config
+	endpoint    string
+	credentials credentials // Nil sends requests unauthenticated, as emulators take them
+	httpClient  *http.Client
+	logger      *zap.Logger
+}
+
+// statusError is an unexpected status answered by the API
+type statusError struct {
+	operation string
+	status    int
+	message   string
+}
+
+func (e *statusError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("GCS %s failed with status %d: %s", e.operation, e.status, e.message)
+	}
+	return fmt.Sprintf("GCS %s failed with status %d", e.operation, e.status)
+}
+
+// HTTPStatusCode returns the status the API answered with, so throttled and
+// failing requests can be retried
+func (e *statusError) HTTPStatusCode() int {
+	return e.status
+}
+
+// NewClient creates a client. With an endpoint and no credentials file,
+// requests are sent unauthenticated, as emulators such as fake-gcs-server
+// take them.
+func NewClient(config Config, logger *zap.Logger) (*Client, error) {
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if u, err := url.Parse(endpoint); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid GCS endpoint %q", config.Endpoint)
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = 16 << 20
+	}
+	if config.ChunkSize%chunkAlignment != 0 {
+		return nil, fmt.Errorf("GCS chunk size %d is not a multiple of 256 KiB", config.ChunkSize)
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	// Object contents may take longer than any timeout; their requests are
+	// bounded by their contexts
+	httpClient := &http.Client{}
+	creds, err := loadCredentials(config.CredentialsFile, config.Endpoint != "", &http.Client{Timeout: config.Timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		config:      config,
+		endpoint:    endpoint,
+		credentials: creds,
+		httpClient:  httpClient,
+		logger:      logger,
+	}, nil
+}
+
+// objectURL returns the JSON API URL of an object's metadata
+func (c *Client) objectURL(bucket, name string) string {
+	return c.endpoint + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(name)
+}
+
+// Upload writes content to bucket/name with a resumable upload, sending
+// ChunkSize bytes at a time. A chunk that fails is resumed from what the API
+// reports it received.
+func (c *Client) Upload(ctx context.Context, bucket, name string, content io.Reader) error {
+	session, err := c.startUpload(ctx, bucket, name)
+	if err != nil {
+		return err
+	}
+
+	chunk := make([]byte, c.config.ChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(content, chunk)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			c.cancelUpload(session)
+			return fmt.Errorf("failed to read upload of gs://%s/%s: %w", bucket, name, err)
+		}
+		if err := c.sendChunk(ctx, session, chunk[:n], offset, last); err != nil {
+			c.cancelUpload(session)
+			return fmt.Errorf("failed to upload gs://%s/%s: %w", bucket, name, err)
+		}
+		offset += int64(n)
+		if last {
+			return nil
+		}
+	}
+}
+
+// startUpload opens a resumable upload session and returns its URL
+func (c *Client) startUpload(ctx context.Context, bucket, name string) (string, error) {
+	target := c.endpoint + "/upload/storage/v1/b/" + url.PathEscape(bucket) + "/o?uploadType=resumable&name=" + url.QueryEscape(name)
+	var session string
+	err := c.retry(ctx, "upload", func(ctx context.Context) error {
+		resp, err := c.do(ctx, http.MethodPost, target, bytes.NewReader([]byte("{}")), func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+			req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+		})
+		if err != nil {
+			return err
+		}
+		defer drain(resp)
+		if resp.StatusCode != http.StatusOK {
+			return apiError("upload", resp)
+		}
+		session = resp.Header.Get("Location")
+		if session == "" {
+			return errors.New("GCS upload session has no location")
+		}
+		return nil
+	})
+	return session, err
+}
+
+// sendChunk sends the bytes of an upload at offset, the last chunk
+// completing it. After a failed attempt, the chunk is resumed from the
+// offset the session reports.
+func (c *Client) sendChunk(ctx context.Context, session string, chunk []byte, offset int64, last bool) error {
+	for attempt := 1; ; attempt++ {
+		complete, err := c.putChunk(ctx, session, chunk, offset, last)
+		if err == nil {
+			if last && !complete {
+				return errors.New("GCS did not complete the upload")
+			}
+			return nil
+		}
+		if attempt >= c.config.MaxAttempts || !retryable(ctx, err) {
+			return err
+		}
+		c.logger.Debug("Retrying GCS upload chunk", zap.Int64("offset", offset), zap.Int("attempt", attempt), zap.Error(err))
+		if err := sleep(ctx, attempt); err != nil {
+			return err
+		}
+
+		received, complete, err := c.uploadStatus(ctx, session)
+		if err != nil {
+			continue
+		}
+		if complete {
+			return nil
+		}
+		if received < offset || received > offset+int64(len(chunk)) {
+			return fmt.Errorf("GCS reports %d bytes received, expected between %d and %d", received, offset, offset+int64(len(chunk)))
+		}
+		chunk = chunk[received-offset:]
+		offset = received
+		if len(chunk) == 0 && !last {
+			return nil
+		}
+	}
+}
+
+// putChunk sends one request of an upload, reporting whether it completed
+// the object
+func (c *Client) putChunk(ctx context.Context, session string, chunk []byte, offset int64, last bool) (bool, error) {
+	end := offset + int64(len(chunk))
+	total := "*"
+	if last {
+		total = strconv.FormatInt(end, 10)
+	}
+	contentRange := fmt.Sprintf("bytes %d-%d/%s", offset, end-1, total)
+	if len(chunk) == 0 {
+		contentRange = "bytes */" + total
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, session, bytes.NewReader(chunk), func(req *http.Request) {
+		req.ContentLength = int64(len(chunk))
+		req.Header.Set("Content-Range", contentRange)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer drain(resp)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return true, nil
+	case http.StatusPermanentRedirect:
+		return false, nil
+	default:
+		return false, apiError("upload", resp)
+	}
+}
+
+// uploadStatus asks a session how many bytes it received, or whether the
+// upload is complete
+func (c *Client) uploadStatus(ctx context.Context, session string) (int64, bool, error) {
+	resp, err := c.do(ctx, http.MethodPut, session, http.NoBody, func(req *http.Request) {
+		req.Header.Set("Content-Range", "bytes */*")
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	defer drain(resp)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+		// Range: bytes=0-N, absent when nothing was received
+		received := resp.Header.Get("Range")
+		if received == "" {
+			return 0, false, nil
+		}
+		_, last, ok := strings.Cut(received, "-")
+		n, err := strconv.ParseInt(last, 10, 64)
+		if !ok || err != nil {
+			return 0, false, fmt.Errorf("malformed GCS upload range %q", received)
+		}
+		return n + 1, false, nil
+	default:
+		return 0, false, apiError("upload status", resp)
+	}
+}
+
+// cancelUpload abandons a session, so the parts it received are discarded
+func (c *Client) cancelUpload(session string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodDelete, session, nil, nil)
+	if err != nil {
+		c.logger.Warn("Failed to cancel GCS upload", zap.Error(err))
+		return
+	}
+	drain(resp)
+}
+
+// Download opens the contents of bucket/name. A missing object is reported
+// as os.ErrNotExist.
+func (c *Client) Download(ctx context.Context, bucket, name string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.retry(ctx, "download", func(ctx context.Context) error {
+		resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, name)+"?alt=media", nil, nil)
+		if err != nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			body = resp.Body
+			return nil
+		case http.StatusNotFound:
+			drain(resp)
+			return fmt.Errorf("gs://%s/%s: %w", bucket, name, os.ErrNotExist)
+		default:
+			defer drain(resp)
+			return apiError("download", resp)
+		}
+	})
+	return body, err
+}
+
+// Exists reports whether bucket/name exists
+func (c *Client) Exists(ctx context.Context, bucket, name string) (bool, error) {
+	var exists bool
+	err := c.retry(ctx, "get", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+		resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, name)+"?fields=name", nil, nil)
+		if err != nil {
+			return err
+		}
+		defer drain(resp)
+		switch resp.StatusCode {
+		case http.StatusOK:
+			exists = true
+			return nil
+		case http.StatusNotFound:
+			exists = false
+			return nil
+		default:
+			return apiError("get", resp)
+		}
+	})
+	return exists, err
+}
+
+// Delete removes bucket/name; a missing object is not an error
+func (c *Client) Delete(ctx context.Context, bucket, name string) error {
+	return c.retry(ctx, "delete", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+		resp, err := c.do(ctx, http.MethodDelete, c.objectURL(bucket, name), nil, nil)
+		if err != nil {
+			return err
+		}
+		defer drain(resp)
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+			return nil
+		default:
+			return apiError("delete", resp)
+		}
+	})
+}
+
+// CheckBucket verifies that bucket exists and the credentials may read it
+func (c *Client) CheckBucket(ctx context.Context, bucket string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"?fields=name", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer drain(resp)
+	if resp.StatusCode != http.StatusOK {
+		return apiError("bucket check", resp)
+	}
+	return nil
+}
+
+// do sends an authorized request, letting prepare set its headers
+func (c *Client) do(ctx context.Context, method, target string, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	if c.credentials != nil {
+		token, err := c.credentials.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(req)
+}
+
+// retry runs attempt until it succeeds, fails for good, or MaxAttempts are
+// used, with exponential backoff between attempts
+func (c *Client) retry(ctx context.Context, operation string, attempt func(context.Context) error) error {
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= c.config.MaxAttempts || !retryable(ctx, err) {
+			return err
+		}
+		c.logger.Debug("Retrying GCS request", zap.String("operation", operation), zap.Int("attempt", n), zap.Error(err))
+		if err := sleep(ctx, n); err != nil {
+			return err
+		}
+	}
+}
+
+// retryable reports whether a failed request may succeed if sent again:
+// throttling, server errors and network failures
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.status == http.StatusTooManyRequests || status.status == http.StatusRequestTimeout || status.status >= 500
+	}
+	return true
+}
+
+// sleep waits out the backoff after attempt
+func sleep(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(retryDelay << (attempt - 1))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// apiError reads the error of an unexpected response
+func apiError(operation string, resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Description string `json:"error_description"` // Token endpoints
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	message := ""
+	if json.Unmarshal(data, &body) == nil {
+		message = body.Error.Message
+		if message == "" {
+			message = body.Description
+		}
+	}
+	return &statusError{operation: operation, status: resp.StatusCode, message: message}
+}
+
+// drain reads what is left of a response so its connection is reused
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,289 @@
+package gcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// storageScope lets tokens read and write objects
+	storageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	// metadataURL serves the tokens of the service account attached to the
+	// instance or pod
+	metadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/"
+
+	// signBlobURL signs on behalf of service accounts without their key
+	signBlobURL = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:signBlob"
+
+	// tokenSlack renews tokens this long before they expire
+	tokenSlack = time.Minute
+)
+
+// credentials authorize requests and sign URLs as a service account
+type credentials interface {
+	// token returns an OAuth 2.0 access token for storageScope
+	token(ctx context.Context) (string, error)
+
+	// email returns the service account's address
+	email(ctx context.Context) (string, error)
+
+	// sign returns the RSA SHA-256 signature of data
+	sign(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// loadCredentials reads the service account key at path, or at
+// GOOGLE_APPLICATION_CREDENTIALS when path is empty. Without either, the
+// metadata server's service account is used, unless anonymous is set.
+func loadCredentials(path string, anonymous bool, httpClient *http.Client) (credentials, error) {
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path == "" {
+		if anonymous {
+			return nil, nil
+		}
+		return &metadataCredentials{httpClient: httpClient}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS credentials: %w", err)
+	}
+	var key struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse GCS credentials %s: %w", path, err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("GCS credentials %s are of type %q; only service account keys are supported", path, key.Type)
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("GCS credentials %s hold no PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key of GCS credentials %s: %w", path, err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of GCS credentials %s is not an RSA key", path)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &serviceAccountKey{
+		clientEmail: key.ClientEmail,
+		keyID:       key.PrivateKeyID,
+		key:         private,
+		tokenURI:    key.TokenURI,
+		httpClient:  httpClient,
+	}, nil
+}
+
+// cachedToken holds an access token until shortly before it expires
+type cachedToken struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// get returns the cached token, or one from fetch once it has expired
+func (t *cachedToken) get(fetch func() (string, time.Duration, error)) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.value != "" && time.Now().Before(t.expires) {
+		return t.value, nil
+	}
+	value, lifetime, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	t.value = value
+	t.expires = time.Now().Add(lifetime - tokenSlack)
+	return value, nil
+}
+
+// tokenResponse is the OAuth 2.0 token endpoints' answer
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"` // Seconds
+}
+
+// serviceAccountKey exchanges self-signed JWTs for access tokens and signs
+// URLs with the service account's private key
+type serviceAccountKey struct {
+	clientEmail string
+	keyID       string
+	key         *rsa.PrivateKey
+	tokenURI    string
+	httpClient  *http.Client
+	cached      cachedToken
+}
+
+func (k *serviceAccountKey) token(ctx context.Context) (string, error) {
+	return k.cached.get(func() (string, time.Duration, error) {
+		now := time.Now()
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": k.keyID})
+		claims, _ := json.Marshal(map[string]any{
+			"iss":   k.clientEmail,
+			"scope": storageScope,
+			"aud":   k.tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+		signature, err := k.sign(ctx, []byte(unsigned))
+		if err != nil {
+			return "", 0, err
+		}
+
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.tokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return fetchToken(k.httpClient, req)
+	})
+}
+
+func (k *serviceAccountKey) email(context.Context) (string, error) {
+	return k.clientEmail, nil
+}
+
+func (k *serviceAccountKey) sign(_ context.Context, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+}
+
+// metadataCredentials act as the service account attached to the instance
+// or pod, signing through the IAM Credentials API since its key never leaves
+// Google. The account needs roles/iam.serviceAccountTokenCreator on itself to
+// sign URLs.
+type metadataCredentials struct {
+	httpClient *http.Client
+	cached     cachedToken
+
+	mu           sync.Mutex
+	accountEmail string
+}
+
+func (m *metadataCredentials) token(ctx context.Context) (string, error) {
+	return m.cached.get(func() (string, time.Duration, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL+"token", nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return fetchToken(m.httpClient, req)
+	})
+}
+
+func (m *metadataCredentials) email(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.accountEmail != "" {
+		return m.accountEmail, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL+"email", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the service account from the metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server answered %d for the service account", resp.StatusCode)
+	}
+	m.accountEmail = strings.TrimSpace(string(data))
+	return m.accountEmail, nil
+}
+
+func (m *metadataCredentials) sign(ctx context.Context, data []byte) ([]byte, error) {
+	account, err := m.email(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := m.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(map[string]string{"payload": base64.StdEncoding.EncodeToString(data)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(signBlobURL, url.PathEscape(account)), strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with the IAM Credentials API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("signBlob", resp)
+	}
+	var out struct {
+		SignedBlob string `json:"signedBlob"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode signBlob response: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(out.SignedBlob)
+}
+
+// fetchToken sends a token request and decodes the token it returns
+func fetchToken(httpClient *http.Client, req *http.Request) (string, time.Duration, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get a GCS access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, apiError("token", resp)
+	}
+	var out tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", 0, fmt.Errorf("failed to decode GCS access token: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", 0, errors.New("no GCS access token was returned")
+	}
+	return out.AccessToken, time.Duration(out.ExpiresIn) * time.Second, nil
+}
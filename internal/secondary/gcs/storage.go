@@ -0,0 +1,64 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage stores files as the objects of one bucket, under an optional
+// object name prefix, so job outputs can be written to Cloud Storage in place
+// of local storage
+type Storage struct {
+	client *Client
+	bucket string
+	prefix string
+}
+
+func NewStorage(client *Client, bucket, prefix string) *Storage {
+	return &Storage{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// name maps a storage path to its object name
+func (s *Storage) name(p string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, filepath.ToSlash(p)), "/")
+}
+
+func (s *Storage) ReadFile(p string) (io.ReadCloser, error) {
+	return s.client.Download(context.Background(), s.bucket, s.name(p))
+}
+
+func (s *Storage) WriteFile(p string, content io.Reader) error {
+	return s.client.Upload(context.Background(), s.bucket, s.name(p), content)
+}
+
+func (s *Storage) DeleteFile(p string) error {
+	return s.client.Delete(context.Background(), s.bucket, s.name(p))
+}
+
+func (s *Storage) FileExists(p string) bool {
+	exists, err := s.client.Exists(context.Background(), s.bucket, s.name(p))
+	return err == nil && exists
+}
+
+// URL returns the gs:// URL of the stored file
+func (s *Storage) URL(p string) string {
+	return "gs://" + s.bucket + "/" + s.name(p)
+}
+
+// PresignURL returns an HTTPS URL that downloads the file for ttl
+func (s *Storage) PresignURL(ctx context.Context, p string, ttl time.Duration) (string, error) {
+	return s.client.SignedURL(ctx, s.bucket, s.name(p), ttl)
+}
+
+// HealthCheck verifies that the bucket is accessible
+func (s *Storage) HealthCheck(ctx context.Context) error {
+	return s.client.CheckBucket(ctx, s.bucket)
+}
@@ -0,0 +1,185 @@
+// Package keystore seals content keys with an external key management
+// service, so only envelope-encrypted keys are persisted with jobs
+package keystore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+
+	"E.E/internal/core/domain"
+)
+
+// kmsPrefix marks references sealed by AWS KMS, and kmsCustomerPrefix those
+// sealed under a customer's KMS key
+const (
+	kmsPrefix         = "kms:"
+	kmsCustomerPrefix = "kms-customer:"
+)
+
+// KMSConfig selects the KMS key content keys are sealed under
+type KMSConfig struct {
+	KeyID    string // Key ID, ARN or alias, e.g. alias/ee-content-keys
+	Region   string
+	Endpoint string        // e.g. LocalStack; AWS KMS when empty
+	Timeout  time.Duration // Per request, including retries
+}
+
+// KMS seals content keys with AWS KMS. Each key is encrypted under the
+// configured KMS key with the job and output as encryption context, so KMS
+// refuses to open it for any other key and records both in CloudTrail.
+// Credentials come from the default AWS chain.
+type KMS struct {
+	client  *kms.Client
+	keyID   string
+	timeout time.Duration
+}
+
+func NewKMS(ctx context.Context, config KMSConfig) (*KMS, error) {
+	if config.KeyID == "" {
+		return nil, fmt.Errorf("a KMS key ID is required")
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(config.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := kms.NewFromConfig(awsConfig, func(o *kms.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+	})
+
+	return &KMS{
+		client:  client,
+		keyID:   config.KeyID,
+		timeout: config.Timeout,
+	}, nil
+}
+
+func (k *KMS) Seal(ctx context.Context, key domain.ContentKey, material string) (string, error) {
+	plaintext, err := hex.DecodeString(material)
+	if err != nil {
+		return "", fmt.Errorf("key of %s is not hex encoded: %w", key, err)
+	}
+	defer clear(plaintext)
+
+	ctx, cancel := k.withTimeout(ctx)
+	defer cancel()
+	out, err := k.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(k.keyID),
+		Plaintext:         plaintext,
+		EncryptionContext: encryptionContext(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("KMS encrypt failed: %w", err)
+	}
+	return kmsPrefix + base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// SealWith seals a key under a customer's KMS key, which must allow this
+// service to encrypt and decrypt with it
+func (k *KMS) SealWith(ctx context.Context, masterKeyID string, key domain.ContentKey, material string) (string, error) {
+	plaintext, err := hex.DecodeString(material)
+	if err != nil {
+		return "", fmt.Errorf("key of %s is not hex encoded: %w", key, err)
+	}
+	defer clear(plaintext)
+
+	ctx, cancel := k.withTimeout(ctx)
+	defer cancel()
+	out, err := k.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(masterKeyID),
+		Plaintext:         plaintext,
+		EncryptionContext: encryptionContext(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("KMS encrypt with customer key failed: %w", err)
+	}
+	return kmsCustomerPrefix + base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// Unwrap decrypts a customer's content key. It is encrypted without
+// encryption context, under any KMS key this service may decrypt with; KMS
+// finds the key from the ciphertext.
+func (k *KMS) Unwrap(ctx context.Context, wrapped string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrapped_key must be base64 encoded", domain.ErrInvalidCustomerKey)
+	}
+
+	ctx, cancel := k.withTimeout(ctx)
+	defer cancel()
+	out, err := k.client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: blob})
+	if err != nil {
+		return "", fmt.Errorf("%w: KMS could not unwrap it: %v", domain.ErrInvalidCustomerKey, err)
+	}
+	defer clear(out.Plaintext)
+	return hex.EncodeToString(out.Plaintext), nil
+}
+
+func (k *KMS) Open(ctx context.Context, key domain.ContentKey, ref string) (string, error) {
+	// Keys sealed under customer keys name no key: KMS finds it from the
+	// ciphertext
+	keyID := aws.String(k.keyID)
+	encoded, ok := strings.CutPrefix(ref, kmsPrefix)
+	if !ok {
+		if encoded, ok = strings.CutPrefix(ref, kmsCustomerPrefix); !ok {
+			return "", fmt.Errorf("key of %s was not sealed by KMS", key)
+		}
+		keyID = nil
+	}
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("sealed key of %s is malformed: %w", key, err)
+	}
+
+	ctx, cancel := k.withTimeout(ctx)
+	defer cancel()
+	out, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             keyID,
+		CiphertextBlob:    blob,
+		EncryptionContext: encryptionContext(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("KMS decrypt failed: %w", err)
+	}
+	defer clear(out.Plaintext)
+	return hex.EncodeToString(out.Plaintext), nil
+}
+
+// HealthCheck verifies that the KMS key exists and is enabled
+func (k *KMS) HealthCheck(ctx context.Context) error {
+	out, err := k.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(k.keyID)})
+	if err != nil {
+		return fmt.Errorf("KMS key %s is not accessible: %w", k.keyID, err)
+	}
+	if state := out.KeyMetadata.KeyState; state != types.KeyStateEnabled {
+		return fmt.Errorf("KMS key %s is %s", k.keyID, state)
+	}
+	return nil
+}
+
+// withTimeout bounds one request to KMS
+func (k *KMS) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if k.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, k.timeout)
+}
+
+// encryptionContext binds a sealed key to the content key it belongs to
+func encryptionContext(key domain.ContentKey) map[string]string {
+	ctx := map[string]string{"job_id": key.JobID}
+	if key.Output != "" {
+		ctx["output"] = key.Output
+	}
+	return ctx
+}
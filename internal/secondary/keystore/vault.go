@@ -0,0 +1,173 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"E.E/internal/core/domain"
+)
+
+// VaultConfig selects the transit key content keys are sealed under
+type VaultConfig struct {
+	Address   string // e.g. https://vault.internal:8200
+	Token     string
+	Namespace string // Vault Enterprise namespace; none when empty
+	Mount     string // Path the transit engine is mounted at
+	KeyName   string
+	Timeout   time.Duration // Per request
+}
+
+// Vault seals content keys with the transit secrets engine of HashiCorp
+// Vault, which keeps the master key and returns vault:v1:... ciphertexts.
+// The job and output are sealed along with each key and checked when it is
+// opened, so a sealed key copied to another job cannot be opened as its key.
+type Vault struct {
+	config     VaultConfig
+	httpClient *http.Client
+}
+
+// vaultPayload is what is sealed for each content key
+type vaultPayload struct {
+	Key      string `json:"key"` // ContentKey.String()
+	Material string `json:"material"`
+}
+
+func NewVault(config VaultConfig) (*Vault, error) {
+	u, err := url.Parse(config.Address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Vault address %q", config.Address)
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("a Vault token is required")
+	}
+	if config.KeyName == "" {
+		return nil, fmt.Errorf("a Vault transit key name is required")
+	}
+	config.Address = strings.TrimRight(config.Address, "/")
+	config.Mount = strings.Trim(config.Mount, "/")
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &Vault{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+func (v *Vault) Seal(ctx context.Context, key domain.ContentKey, material string) (string, error) {
+	payload, err := json.Marshal(vaultPayload{Key: key.String(), Material: material})
+	if err != nil {
+		return "", err
+	}
+	defer clear(payload)
+
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err = v.call(ctx, http.MethodPost, "encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(payload),
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.Ciphertext, nil
+}
+
+func (v *Vault) Open(ctx context.Context, key domain.ContentKey, ref string) (string, error) {
+	if !strings.HasPrefix(ref, "vault:") {
+		return "", fmt.Errorf("key of %s was not sealed by Vault", key)
+	}
+
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := v.call(ctx, http.MethodPost, "decrypt", map[string]string{"ciphertext": ref}, &out); err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("Vault returned a malformed plaintext: %w", err)
+	}
+	defer clear(raw)
+
+	var payload vaultPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", fmt.Errorf("sealed key of %s is malformed: %w", key, err)
+	}
+	if payload.Key != key.String() {
+		return "", fmt.Errorf("sealed key belongs to %s, not %s", payload.Key, key)
+	}
+	return payload.Material, nil
+}
+
+// HealthCheck verifies that Vault is reachable, initialized and unsealed
+func (v *Vault) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.Address+"/v1/sys/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Vault is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	// Standbys (429) forward requests to the active node
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("Vault is unhealthy: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// call sends a request to the transit key's endpoint for op and decodes the
+// data of the response into out
+func (v *Vault) call(ctx context.Context, method, op string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", v.config.Address, v.config.Mount, op, url.PathEscape(v.config.KeyName))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", v.config.Token)
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Vault %s failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil && resp.StatusCode < 300 {
+		return fmt.Errorf("failed to decode Vault %s response: %w", op, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Vault %s failed with status %d: %s", op, resp.StatusCode, strings.Join(envelope.Errors, "; "))
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("failed to decode Vault %s response: %w", op, err)
+	}
+	return nil
+}
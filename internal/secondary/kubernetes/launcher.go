@@ -0,0 +1,274 @@
+// Package kubernetes runs encryption jobs as Kubernetes Jobs
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// Paths of the service account credentials mounted into every pod
+const (
+	InClusterTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	InClusterCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	InClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// Labels identifying the Kubernetes Jobs the launcher creates
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "ee-encryption-service"
+	jobIDLabel     = "ee.encryption/job-id"
+)
+
+// Config describes the cluster and the pods launched for jobs
+type Config struct {
+	APIServer string // Empty uses the in-cluster address
+	Namespace string // Empty uses the namespace of the running pod
+	TokenFile string
+	CAFile    string
+
+	Image           string
+	ImagePullPolicy string
+	ServiceAccount  string
+	Env             map[string]string
+	EnvFrom         []EnvSource
+	Resources       Resources
+
+	// Persistent volume claim mounted at StorageMountPath, so pods read and
+	// write the same storage as the service
+	StorageClaim     string
+	StorageMountPath string
+
+	ActiveDeadline   time.Duration // Longest a job's pod may run, 0 for no limit
+	TTLAfterFinished time.Duration // How long finished Kubernetes Jobs are kept
+}
+
+// EnvSource names a Secret or ConfigMap whose entries become environment
+// variables of the pods
+type EnvSource struct {
+	Kind string // "secret" or "configmap"
+	Name string
+}
+
+// Resources are the CPU and memory requests and limits of a pod, in
+// Kubernetes quantities such as "500m" or "2Gi". Empty values are left out.
+type Resources struct {
+	CPURequest    string
+	CPULimit      string
+	MemoryRequest string
+	MemoryLimit   string
+}
+
+// Launcher creates a Kubernetes Job per encryption job through the API
+// server. Each Job's pod runs the service with worker.job_id set, processing
+// that one job.
+type Launcher struct {
+	config     Config
+	server     string
+	token      string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewLauncher creates a launcher, reading the in-cluster service account for
+// whatever config leaves empty
+func NewLauncher(config Config, logger *zap.Logger) (*Launcher, error) {
+	if config.Image == "" {
+		return nil, errors.New("a worker image is required")
+	}
+	if config.TokenFile == "" {
+		config.TokenFile = InClusterTokenFile
+	}
+	if config.CAFile == "" {
+		config.CAFile = InClusterCAFile
+	}
+
+	server := strings.TrimRight(config.APIServer, "/")
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("not running in a cluster; set the API server address")
+		}
+		server = "https://" + host + ":" + port
+	}
+	if config.Namespace == "" {
+		namespace, err := os.ReadFile(InClusterNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the pod namespace; set it explicitly: %w", err)
+		}
+		config.Namespace = strings.TrimSpace(string(namespace))
+	}
+
+	token, err := os.ReadFile(config.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca, err := os.ReadFile(config.CAFile); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+
+	return &Launcher{
+		config:     config,
+		server:     server,
+		token:      strings.TrimSpace(string(token)),
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		logger:     logger,
+	}, nil
+}
+
+func (l *Launcher) Launch(ctx context.Context, jobID string) error {
+	status, body, err := l.do(ctx, http.MethodPost, l.jobsPath(), l.manifest(jobID))
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusCreated, http.StatusOK, http.StatusAccepted:
+		return nil
+	case http.StatusConflict:
+		// Launched before, e.g. by a dispatcher that stopped before following it
+		l.logger.Debug("Kubernetes Job already exists", zap.String("job_id", jobID))
+		return nil
+	}
+	return apiError("create Job", status, body)
+}
+
+func (l *Launcher) State(ctx context.Context, jobID string) (domain.TaskState, error) {
+	status, body, err := l.do(ctx, http.MethodGet, l.jobsPath()+"/"+jobName(jobID), nil)
+	if err != nil {
+		return domain.TaskState{}, err
+	}
+	if status == http.StatusNotFound {
+		return domain.TaskState{Phase: domain.TaskMissing}, nil
+	}
+	if status != http.StatusOK {
+		return domain.TaskState{}, apiError("get Job", status, body)
+	}
+
+	var job jobObject
+	if err := json.Unmarshal(body, &job); err != nil {
+		return domain.TaskState{}, fmt.Errorf("failed to decode Job: %w", err)
+	}
+	return job.state(), nil
+}
+
+func (l *Launcher) Remove(ctx context.Context, jobID string) error {
+	// Background propagation deletes the Job's pods too
+	status, body, err := l.do(ctx, http.MethodDelete, l.jobsPath()+"/"+jobName(jobID), map[string]string{
+		"kind":              "DeleteOptions",
+		"apiVersion":        "v1",
+		"propagationPolicy": "Background",
+	})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNotFound {
+		return apiError("delete Job", status, body)
+	}
+	return nil
+}
+
+func (l *Launcher) List(ctx context.Context) ([]string, error) {
+	query := url.Values{"labelSelector": {managedByLabel + "=" + managedByValue}}
+	status, body, err := l.do(ctx, http.MethodGet, l.jobsPath()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, apiError("list Jobs", status, body)
+	}
+
+	var list struct {
+		Items []jobObject `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode Job list: %w", err)
+	}
+	jobIDs := make([]string, 0, len(list.Items))
+	for _, job := range list.Items {
+		if id := job.Metadata.Labels[jobIDLabel]; id != "" {
+			jobIDs = append(jobIDs, id)
+		}
+	}
+	return jobIDs, nil
+}
+
+func (l *Launcher) jobsPath() string {
+	return "/apis/batch/v1/namespaces/" + url.PathEscape(l.config.Namespace) + "/jobs"
+}
+
+// do sends a request to the API server, returning the status and body of the
+// response
+func (l *Launcher) do(ctx context.Context, method, path string, in any) (int, []byte, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.server+path, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Kubernetes API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read Kubernetes API response: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// apiError describes a failed API call using the Status object the API
+// server returns
+func apiError(action string, status int, body []byte) error {
+	var s struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &s) == nil && s.Message != "" {
+		return fmt.Errorf("failed to %s: status %d: %s: %s", action, status, s.Reason, s.Message)
+	}
+	return fmt.Errorf("failed to %s: status %d", action, status)
+}
+
+// jobName is the name of a job's Kubernetes Job; job IDs are UUIDs, which are
+// valid in names
+func jobName(jobID string) string {
+	return "ee-encrypt-" + strings.ToLower(jobID)
+}
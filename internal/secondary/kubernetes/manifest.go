@@ -0,0 +1,193 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"E.E/internal/core/domain"
+)
+
+// Environment variables that make a pod process exactly one job. They take
+// precedence over Config.Env, so a pod never dispatches further pods itself.
+const (
+	envMode    = "EE_MODE"
+	envBackend = "EE_WORKER_BACKEND"
+	envJobID   = "EE_WORKER_JOB_ID"
+)
+
+const storageVolume = "storage"
+
+// manifest is the Kubernetes Job running jobID
+func (l *Launcher) manifest(jobID string) map[string]any {
+	c := l.config
+
+	env := []map[string]any{}
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		if name != envMode && name != envBackend && name != envJobID {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, map[string]any{"name": name, "value": c.Env[name]})
+	}
+	env = append(env,
+		map[string]any{"name": envMode, "value": "worker"},
+		map[string]any{"name": envBackend, "value": "local"},
+		map[string]any{"name": envJobID, "value": jobID},
+	)
+
+	container := map[string]any{
+		"name":  "worker",
+		"image": c.Image,
+		"env":   env,
+	}
+	if c.ImagePullPolicy != "" {
+		container["imagePullPolicy"] = c.ImagePullPolicy
+	}
+	if len(c.EnvFrom) > 0 {
+		envFrom := make([]map[string]any, 0, len(c.EnvFrom))
+		for _, source := range c.EnvFrom {
+			ref := map[string]any{"name": source.Name}
+			if source.Kind == "configmap" {
+				envFrom = append(envFrom, map[string]any{"configMapRef": ref})
+			} else {
+				envFrom = append(envFrom, map[string]any{"secretRef": ref})
+			}
+		}
+		container["envFrom"] = envFrom
+	}
+	if resources := c.Resources.manifest(); resources != nil {
+		container["resources"] = resources
+	}
+
+	pod := map[string]any{
+		"restartPolicy": "Never",
+		"containers":    []any{container},
+	}
+	if c.ServiceAccount != "" {
+		pod["serviceAccountName"] = c.ServiceAccount
+	}
+	if c.StorageClaim != "" {
+		container["volumeMounts"] = []any{map[string]any{
+			"name":      storageVolume,
+			"mountPath": c.StorageMountPath,
+		}}
+		pod["volumes"] = []any{map[string]any{
+			"name":                  storageVolume,
+			"persistentVolumeClaim": map[string]any{"claimName": c.StorageClaim},
+		}}
+	}
+
+	labels := map[string]string{
+		managedByLabel: managedByValue,
+		jobIDLabel:     jobID,
+	}
+
+	// A failed pod is not retried: the job may have partly written its
+	// outputs, so the dispatcher fails it and retries go through the API
+	spec := map[string]any{
+		"backoffLimit": 0,
+		"template": map[string]any{
+			"metadata": map[string]any{"labels": labels},
+			"spec":     pod,
+		},
+	}
+	if c.ActiveDeadline > 0 {
+		spec["activeDeadlineSeconds"] = int64(c.ActiveDeadline.Seconds())
+	}
+	if c.TTLAfterFinished > 0 {
+		spec["ttlSecondsAfterFinished"] = int64(c.TTLAfterFinished.Seconds())
+	}
+
+	return map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]any{
+			"name":   jobName(jobID),
+			"labels": labels,
+		},
+		"spec": spec,
+	}
+}
+
+// manifest returns the resources of a container, or nil if none are set
+func (r Resources) manifest() map[string]any {
+	quantities := func(cpu, memory string) map[string]string {
+		q := map[string]string{}
+		if cpu != "" {
+			q["cpu"] = cpu
+		}
+		if memory != "" {
+			q["memory"] = memory
+		}
+		return q
+	}
+
+	resources := map[string]any{}
+	if q := quantities(r.CPURequest, r.MemoryRequest); len(q) > 0 {
+		resources["requests"] = q
+	}
+	if q := quantities(r.CPULimit, r.MemoryLimit); len(q) > 0 {
+		resources["limits"] = q
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	return resources
+}
+
+// jobObject is the part of a Kubernetes Job the launcher reads back
+type jobObject struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Status struct {
+		Active     int `json:"active"`
+		Succeeded  int `json:"succeeded"`
+		Failed     int `json:"failed"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// state maps the Job's status to the task's phase
+func (j jobObject) state() domain.TaskState {
+	for _, c := range j.Status.Conditions {
+		if c.Status != "True" {
+			continue
+		}
+		switch c.Type {
+		case "Complete":
+			return domain.TaskState{Phase: domain.TaskSucceeded}
+		case "Failed":
+			return domain.TaskState{Phase: domain.TaskFailed, Reason: conditionReason(c.Reason, c.Message)}
+		}
+	}
+
+	// Conditions lag the pod counts; with backoffLimit 0 a single pod decides
+	switch {
+	case j.Status.Succeeded > 0:
+		return domain.TaskState{Phase: domain.TaskSucceeded}
+	case j.Status.Failed > 0 && j.Status.Active == 0:
+		return domain.TaskState{Phase: domain.TaskFailed, Reason: "pod failed"}
+	}
+	return domain.TaskState{Phase: domain.TaskRunning}
+}
+
+func conditionReason(reason, message string) string {
+	message = strings.TrimSpace(message)
+	switch {
+	case reason == "":
+		return message
+	case message == "":
+		return reason
+	}
+	return fmt.Sprintf("%s: %s", reason, message)
+}
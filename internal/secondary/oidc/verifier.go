@@ -0,0 +1,366 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Registers SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// Config selects the provider tokens are accepted from and how their claims
+// map to principals
+type Config struct {
+	Issuer        string        // Expected iss claim; signing keys are discovered from it
+	Audience      string        // Expected aud claim
+	JWKSURL       string        // Signing keys; discovered from the issuer when empty
+	OwnerClaim    string        // Claim naming the owner a token acts for
+	TenantClaim   string        // Claim naming the owner's tenant; the owner is its own tenant when empty
+	ScopesClaim   string        // Claim listing scopes, space-separated or as an array
+	DefaultScopes []string      // Scopes of tokens that carry none of the service's scopes
+	Leeway        time.Duration // Clock skew allowed for exp and nbf
+	Refresh       time.Duration // How often signing keys are refetched
+	Timeout       time.Duration // Per request to the provider
+}
+
+// minRefetchInterval limits how often a token signed by an unknown key
+// triggers a refetch, so forged key IDs cannot hammer the provider
+const minRefetchInterval = time.Minute
+
+// algorithm is a supported JWS signing algorithm
+type algorithm struct {
+	family string // RS, PS or ES
+	hash   crypto.Hash
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {"RS", crypto.SHA256},
+	"RS384": {"RS", crypto.SHA384},
+	"RS512": {"RS", crypto.SHA512},
+	"PS256": {"PS", crypto.SHA256},
+	"PS384": {"PS", crypto.SHA384},
+	"PS512": {"PS", crypto.SHA512},
+	"ES256": {"ES", crypto.SHA256},
+	"ES384": {"ES", crypto.SHA384},
+	"ES512": {"ES", crypto.SHA512},
+}
+
+// Verifier checks JWTs issued by an OpenID Connect provider: their signature
+// against the provider's published keys, their issuer and audience, and
+// their lifetime. Keys are cached and refetched periodically, or early when
+// a token names a key that is not cached yet, as after a key rotation.
+// Symmetric algorithms and unsigned tokens are rejected.
+type Verifier struct {
+	config     Config
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	mu        sync.Mutex
+	jwksURL   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewVerifier(config Config, logger *zap.Logger) (*Verifier, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("an issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("an audience is required")
+	}
+	for _, scope := range config.DefaultScopes {
+		if !slices.Contains(domain.Scopes, scope) {
+			return nil, fmt.Errorf("unknown default scope %q, expected one of %v", scope, domain.Scopes)
+		}
+	}
+	if config.OwnerClaim == "" {
+		config.OwnerClaim = "sub"
+	}
+	if config.ScopesClaim == "" {
+		config.ScopesClaim = "scope"
+	}
+	if config.Refresh <= 0 {
+		config.Refresh = time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &Verifier{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		logger:     logger,
+		jwksURL:    config.JWKSURL,
+		keys:       make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+// VerifyToken returns the principal a token acts for. Tokens that are
+// malformed, forged, expired or meant for another audience fail with
+// domain.ErrUnauthenticated; other errors mean the provider's keys could not
+// be fetched.
+func (v *Verifier) VerifyToken(ctx context.Context, token string) (domain.Principal, error) {
+	principal, err := v.verify(ctx, token)
+	if errors.Is(err, domain.ErrUnauthenticated) {
+		v.logger.Debug("Rejected JWT", zap.Error(err))
+	}
+	return principal, err
+}
+
+func (v *Verifier) verify(ctx context.Context, token string) (domain.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return domain.Principal{}, rejected("token is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return domain.Principal{}, rejected("malformed header: %v", err)
+	}
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return domain.Principal{}, rejected("unsupported algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return domain.Principal{}, rejected("malformed signature: %v", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	if err := verifySignature(alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return domain.Principal{}, rejected("%v", err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return domain.Principal{}, rejected("malformed claims: %v", err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return domain.Principal{}, err
+	}
+
+	owner, _ := claims[v.config.OwnerClaim].(string)
+	if owner == "" {
+		return domain.Principal{}, rejected("claim %q naming the owner is missing", v.config.OwnerClaim)
+	}
+	principal := domain.NewPrincipal(owner, "", v.scopes(claims))
+	if v.config.TenantClaim != "" {
+		tenant, _ := claims[v.config.TenantClaim].(string)
+		if tenant == "" {
+			return domain.Principal{}, rejected("claim %q naming the tenant is missing", v.config.TenantClaim)
+		}
+		principal.Tenant = tenant
+	}
+	return principal, nil
+}
+
+// checkClaims checks the issuer, audience and lifetime of a token
+func (v *Verifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return rejected("issuer %q is not %q", iss, v.config.Issuer)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.config.Audience) {
+		return rejected("token is not meant for audience %q", v.config.Audience)
+	}
+
+	exp, ok, err := numericClaim(claims, "exp")
+	if err != nil || !ok {
+		return rejected("exp claim is missing or malformed")
+	}
+	if now.After(time.Unix(exp, 0).Add(v.config.Leeway)) {
+		return rejected("token expired at %d", exp)
+	}
+	nbf, ok, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return rejected("nbf claim is malformed")
+	}
+	if ok && now.Add(v.config.Leeway).Before(time.Unix(nbf, 0)) {
+		return rejected("token is not valid before %d", nbf)
+	}
+	return nil
+}
+
+// scopes returns the service's scopes among a token's scopes, or the default
+// scopes if it has none of them
+func (v *Verifier) scopes(claims map[string]interface{}) []string {
+	var granted []string
+	switch claim := claims[v.config.ScopesClaim].(type) {
+	case string:
+		granted = strings.Fields(claim)
+	case []interface{}:
+		for _, s := range claim {
+			if scope, ok := s.(string); ok {
+				granted = append(granted, scope)
+			}
+		}
+	}
+
+	var scopes []string
+	for _, scope := range granted {
+		if slices.Contains(domain.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return v.config.DefaultScopes
+	}
+	return scopes
+}
+
+// HealthCheck verifies that the provider's signing keys can be fetched,
+// refetching them only when they are due
+func (v *Verifier) HealthCheck(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.keys) > 0 && time.Since(v.fetchedAt) < v.config.Refresh {
+		return nil
+	}
+	return v.refresh(ctx)
+}
+
+// key returns the signing key with ID kid. Tokens without a key ID are
+// accepted when the provider publishes a single key.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.lookup(kid)
+	since := time.Since(v.fetchedAt)
+	if ok && since < v.config.Refresh {
+		return key, nil
+	}
+	if since >= v.config.Refresh || since >= minRefetchInterval {
+		if err := v.refresh(ctx); err != nil {
+			if ok {
+				// A stale key beats failing every request while the provider is down
+				v.logger.Warn("Failed to refresh JWT signing keys", zap.Error(err))
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok = v.lookup(kid)
+	}
+	if !ok {
+		return nil, rejected("signing key %q is unknown", kid)
+	}
+	return key, nil
+}
+
+// lookup returns a cached key. v.mu must be held.
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// verifySignature checks a JWS signature over signed
+func verifySignature(alg algorithm, key crypto.PublicKey, signed string, signature []byte) error {
+	h := alg.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg.family {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("signing key is not an RSA key")
+		}
+		var err error
+		if alg.family == "RS" {
+			err = rsa.VerifyPKCS1v15(pub, alg.hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, alg.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return errors.New("signature is invalid")
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("signing key is not an EC key")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("signature is invalid")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature is invalid")
+		}
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token, keeping numbers
+// as json.Number
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numericClaim returns a NumericDate claim in unix seconds, and whether the
+// token has it
+func numericClaim(claims map[string]interface{}, name string) (int64, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, true, fmt.Errorf("%s is not a number", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, true, err
+	}
+	return int64(f), true, nil
+}
+
+// rejected returns an authentication failure
+func rejected(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{domain.ErrUnauthenticated}, args...)...)
+}
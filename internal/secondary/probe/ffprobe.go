@@ -0,0 +1,149 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// FFProbe describes sources by streaming them through ffprobe. Sources are
+// opened with the job source fetcher, so the same schemes and local root
+// restrictions apply as for encryption.
+type FFProbe struct {
+	path    string
+	fetcher ports.SourceFetcher
+	timeout time.Duration
+	logger  *zap.Logger
+}
+
+// NewFFProbe creates a prober running the ffprobe binary at path (looked up
+// in PATH if it has no directory), giving up on a source after timeout
+func NewFFProbe(path string, fetcher ports.SourceFetcher, timeout time.Duration, logger *zap.Logger) (*FFProbe, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe not found: %w", err)
+	}
+
+	return &FFProbe{
+		path:    resolved,
+		fetcher: fetcher,
+		timeout: timeout,
+		logger:  logger,
+	}, nil
+}
+
+// ffprobeOutput is the part of ffprobe's JSON output the service uses
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		BitRate   string `json:"bit_rate"`
+	} `json:"streams"`
+}
+
+// Probe describes the media at sourceURL. Sources ffprobe cannot parse are
+// reported as domain.ErrUnsupportedMedia.
+func (p *FFProbe) Probe(ctx context.Context, sourceURL string) (*domain.MediaInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	src, _, err := p.fetcher.Open(ctx, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		"-i", "pipe:0",
+	)
+	cmd.Stdin = src
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("probing %s timed out: %w", sourceURL, ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedMedia, firstLine(stderr.String(), "ffprobe could not read the source"))
+		}
+		return nil, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+
+	var out ffprobeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if out.Format.FormatName == "" {
+		return nil, fmt.Errorf("%w: no container detected", domain.ErrUnsupportedMedia)
+	}
+
+	info := &domain.MediaInfo{Container: out.Format.FormatName}
+	if seconds, err := strconv.ParseFloat(out.Format.Duration, 64); err == nil {
+		info.Duration = domain.Duration(time.Duration(seconds * float64(time.Second)))
+	}
+	if bitrate, err := strconv.ParseInt(out.Format.BitRate, 10, 64); err == nil {
+		info.Bitrate = bitrate
+	}
+	var streamBitrate int64
+	for _, stream := range out.Streams {
+		if bitrate, err := strconv.ParseInt(stream.BitRate, 10, 64); err == nil {
+			streamBitrate += bitrate
+		}
+		switch stream.CodecType {
+		case "video":
+			if info.VideoCodec == "" {
+				info.VideoCodec = stream.CodecName
+				info.Width, info.Height = stream.Width, stream.Height
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = stream.CodecName
+			}
+		}
+	}
+	// Containers read from a pipe may only carry the bitrate per stream
+	if info.Bitrate == 0 {
+		info.Bitrate = streamBitrate
+	}
+	p.logger.Debug("Probed source",
+		zap.String("source_url", sourceURL),
+		zap.String("container", info.Container),
+		zap.String("video_codec", info.VideoCodec),
+		zap.Duration("duration", info.Duration.Std()),
+		zap.Int64("bitrate", info.Bitrate))
+	return info, nil
+}
+
+// firstLine returns the first non-empty line of s, or fallback
+func firstLine(s, fallback string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return fallback
+}
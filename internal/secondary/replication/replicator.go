@@ -0,0 +1,179 @@
+// Package replication mirrors job and batch records to a replica store in
+// another region, so the status API can fail over to it read-only
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/ports"
+	"E.E/pkg/metrics"
+)
+
+// Kinds of mirrored writes, as recorded in metrics
+const (
+	KindJob        = "job"
+	KindJobDelete  = "job_delete"
+	KindJobHistory = "job_history"
+	KindBatch      = "batch"
+)
+
+// Config configures a Replicator
+type Config struct {
+	QueueSize     int           // Writes waiting to be mirrored before new ones are dropped
+	MaxRetryDelay time.Duration // Longest wait between attempts while the replica is unreachable
+}
+
+// write is one write waiting to be mirrored
+type write struct {
+	kind     string
+	queuedAt time.Time
+	apply    func(ctx context.Context) error
+}
+
+// Replicator mirrors writes to the replica asynchronously, in the order they
+// were made on the primary. A write that fails is retried with backoff until
+// it succeeds, holding back the writes behind it; while the replica stays
+// unreachable the queue fills and further writes are dropped. Records whose
+// writes were dropped catch up with their next write.
+type Replicator struct {
+	jobs    ports.JobRepository
+	batches ports.BatchRepository
+	config  Config
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	queue   chan write
+	pending atomic.Int64 // Queued and in-flight writes
+	ctx     context.Context
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+// NewReplicator creates a replicator writing to the replica's repositories.
+// Jobs are mirrored with Update, which must create jobs the replica does not
+// have yet, as the Redis repository does.
+func NewReplicator(jobs ports.JobRepository, batches ports.BatchRepository, config Config, metrics *metrics.Metrics, logger *zap.Logger) *Replicator {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxRetryDelay <= 0 {
+		config.MaxRetryDelay = 30 * time.Second
+	}
+	ctx, stop := context.WithCancel(context.Background())
+
+	return &Replicator{
+		jobs:    jobs,
+		batches: batches,
+		config:  config,
+		metrics: metrics,
+		logger:  logger,
+		queue:   make(chan write, config.QueueSize),
+		ctx:     ctx,
+		stop:    stop,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start begins mirroring queued writes
+func (r *Replicator) Start() {
+	go r.run()
+}
+
+// Flush waits until every queued write is mirrored or ctx is done, then
+// stops the replicator
+func (r *Replicator) Flush(ctx context.Context) error {
+	defer func() {
+		r.stop()
+		<-r.done
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for r.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d writes not mirrored: %w", r.pending.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// enqueue queues a write for mirroring, dropping it if the queue is full so
+// the primary never waits for the replica
+func (r *Replicator) enqueue(kind string, apply func(ctx context.Context) error) {
+	r.pending.Add(1)
+	select {
+	case r.queue <- write{kind: kind, queuedAt: time.Now(), apply: apply}:
+		r.setQueueDepth()
+	default:
+		r.pending.Add(-1)
+		r.recordWrite(kind, "dropped")
+		r.logger.Warn("Replication queue full, dropping write", zap.String("kind", kind))
+	}
+}
+
+func (r *Replicator) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case w := <-r.queue:
+			r.apply(w)
+			r.pending.Add(-1)
+			r.setQueueDepth()
+		}
+	}
+}
+
+// apply mirrors a write, retrying until it succeeds or the replicator stops
+func (r *Replicator) apply(w write) {
+	delay := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := w.apply(r.ctx)
+		if err == nil {
+			r.recordWrite(w.kind, "mirrored")
+			if r.metrics != nil {
+				r.metrics.SetReplicationLag(time.Since(w.queuedAt).Seconds())
+			}
+			return
+		}
+		if r.ctx.Err() != nil {
+			r.recordWrite(w.kind, "abandoned")
+			return
+		}
+
+		r.recordWrite(w.kind, "retried")
+		r.logger.Warn("Failed to mirror write to replica",
+			zap.String("kind", w.kind),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay),
+			zap.Error(err))
+
+		select {
+		case <-r.ctx.Done():
+			r.recordWrite(w.kind, "abandoned")
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, r.config.MaxRetryDelay)
+	}
+}
+
+func (r *Replicator) recordWrite(kind, outcome string) {
+	if r.metrics != nil {
+		r.metrics.RecordReplicationWrite(kind, outcome)
+	}
+}
+
+func (r *Replicator) setQueueDepth() {
+	if r.metrics != nil {
+		r.metrics.SetReplicationQueueDepth(len(r.queue))
+	}
+}
@@ -0,0 +1,135 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+)
+
+// JobRepository mirrors the writes of a job repository to the replica once
+// they succeed on the primary. Reads are served by the primary.
+type JobRepository struct {
+	ports.JobRepository
+	replicator *Replicator
+}
+
+func NewJobRepository(repository ports.JobRepository, replicator *Replicator) *JobRepository {
+	return &JobRepository{JobRepository: repository, replicator: replicator}
+}
+
+func (r *JobRepository) Create(ctx context.Context, job *domain.EncryptionJob) error {
+	history := slices.Clone(job.PendingHistory())
+	if err := r.JobRepository.Create(ctx, job); err != nil {
+		return err
+	}
+	r.mirrorJob(job, history)
+	return nil
+}
+
+func (r *JobRepository) Update(ctx context.Context, job *domain.EncryptionJob) error {
+	history := slices.Clone(job.PendingHistory())
+	if err := r.JobRepository.Update(ctx, job); err != nil {
+		return err
+	}
+	r.mirrorJob(job, history)
+	return nil
+}
+
+func (r *JobRepository) UpdateIf(ctx context.Context, job *domain.EncryptionJob, cond func(current *domain.EncryptionJob) bool) (bool, error) {
+	history := slices.Clone(job.PendingHistory())
+	updated, err := r.JobRepository.UpdateIf(ctx, job, cond)
+	if err != nil || !updated {
+		return updated, err
+	}
+	r.mirrorJob(job, history)
+	return true, nil
+}
+
+func (r *JobRepository) Delete(ctx context.Context, jobID string) error {
+	if err := r.JobRepository.Delete(ctx, jobID); err != nil {
+		return err
+	}
+	r.replicator.enqueue(KindJobDelete, func(ctx context.Context) error {
+		return r.replicator.jobs.Delete(ctx, jobID)
+	})
+	return nil
+}
+
+func (r *JobRepository) AddJobHistory(ctx context.Context, jobID string, entry domain.JobHistoryEntry) error {
+	if err := r.JobRepository.AddJobHistory(ctx, jobID, entry); err != nil {
+		return err
+	}
+	r.replicator.enqueue(KindJobHistory, func(ctx context.Context) error {
+		return r.replicator.jobs.AddJobHistory(ctx, jobID, entry)
+	})
+	return nil
+}
+
+// mirrorJob queues a snapshot of the job as written, followed by the history
+// entries the write persisted
+func (r *JobRepository) mirrorJob(job *domain.EncryptionJob, history []domain.JobHistoryEntry) {
+	snapshot, err := clone(job)
+	if err != nil {
+		r.replicator.recordWrite(KindJob, "failed")
+		r.replicator.logger.Error("Failed to snapshot job for replication", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	r.replicator.enqueue(KindJob, func(ctx context.Context) error {
+		if err := r.replicator.jobs.Update(ctx, snapshot); err != nil {
+			return err
+		}
+		for len(history) > 0 {
+			if err := r.replicator.jobs.AddJobHistory(ctx, snapshot.ID, history[0]); err != nil {
+				return err
+			}
+			// Entries already mirrored are not repeated when the write is retried
+			history = history[1:]
+		}
+		return nil
+	})
+}
+
+// BatchRepository mirrors stored batch results to the replica
+type BatchRepository struct {
+	ports.BatchRepository
+	replicator *Replicator
+}
+
+func NewBatchRepository(repository ports.BatchRepository, replicator *Replicator) *BatchRepository {
+	return &BatchRepository{BatchRepository: repository, replicator: replicator}
+}
+
+func (r *BatchRepository) StoreBatchResult(ctx context.Context, result *domain.BatchResult) error {
+	if err := r.BatchRepository.StoreBatchResult(ctx, result); err != nil {
+		return err
+	}
+	snapshot, err := clone(result)
+	if err != nil {
+		r.replicator.recordWrite(KindBatch, "failed")
+		r.replicator.logger.Error("Failed to snapshot batch for replication", zap.String("batch_id", result.BatchID), zap.Error(err))
+		return nil
+	}
+	r.replicator.enqueue(KindBatch, func(ctx context.Context) error {
+		return r.replicator.batches.StoreBatchResult(ctx, snapshot)
+	})
+	return nil
+}
+
+// clone deep-copies a record through its JSON form, as the repositories store
+// it, so later changes by the caller do not race with its mirroring
+func clone[T any](record *T) (*T, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var copied T
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
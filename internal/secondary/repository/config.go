@@ -1,6 +1,10 @@
 package repository
 
-import "time"
+import (
+    "time"
+
+    "E.E/internal/core/ports"
+)
 
 type RedisConfig struct {
     URL            string
@@ -15,6 +19,7 @@ type RedisConfig struct {
     ReadTimeout    time.Duration
     WriteTimeout   time.Duration
     JobTTL         time.Duration
+    Clock          ports.Clock // Optional; defaults to the system clock
 }
 
 func DefaultRedisConfig() RedisConfig {
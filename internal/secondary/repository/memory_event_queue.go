@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"E.E/internal/core/domain"
+)
+
+// MemoryEventQueue is an in-process event queue backed by a buffered channel.
+// Events still queued when the process exits are lost.
+type MemoryEventQueue struct {
+	events chan domain.QueuedEvent
+}
+
+func NewMemoryEventQueue(size int) *MemoryEventQueue {
+	return &MemoryEventQueue{
+		events: make(chan domain.QueuedEvent, size),
+	}
+}
+
+// Publish never blocks, so a backlog of slow deliveries cannot hold up the
+// workers; it fails when the queue is full
+func (q *MemoryEventQueue) Publish(ctx context.Context, event domain.QueuedEvent) error {
+	select {
+	case q.events <- event:
+		return nil
+	default:
+		return fmt.Errorf("failed to publish %s event for job %s: event queue is full", event.Payload.EventType, event.Payload.JobID)
+	}
+}
+
+func (q *MemoryEventQueue) Consume(ctx context.Context) (domain.QueuedEvent, error) {
+	select {
+	case event := <-q.events:
+		return event, nil
+	case <-ctx.Done():
+		return domain.QueuedEvent{}, ctx.Err()
+	}
+}
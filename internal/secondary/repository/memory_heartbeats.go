@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryJobHeartbeats keeps job heartbeats for workers in the same process
+type MemoryJobHeartbeats struct {
+	mu    sync.Mutex
+	beats map[string]time.Time
+}
+
+func NewMemoryJobHeartbeats() *MemoryJobHeartbeats {
+	return &MemoryJobHeartbeats{beats: make(map[string]time.Time)}
+}
+
+func (h *MemoryJobHeartbeats) Beat(ctx context.Context, jobID string, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.beats[jobID] = at
+	return nil
+}
+
+func (h *MemoryJobHeartbeats) Clear(ctx context.Context, jobID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.beats, jobID)
+	return nil
+}
+
+func (h *MemoryJobHeartbeats) Last(ctx context.Context, jobIDs []string) (map[string]time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	beats := make(map[string]time.Time, len(jobIDs))
+	for _, jobID := range jobIDs {
+		if at, ok := h.beats[jobID]; ok {
+			beats[jobID] = at
+		}
+	}
+	return beats, nil
+}
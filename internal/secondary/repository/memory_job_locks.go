@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type jobLease struct {
+	owner   string
+	expires time.Time
+}
+
+// MemoryJobLocks leases jobs to workers in the same process
+type MemoryJobLocks struct {
+	mu     sync.Mutex
+	leases map[string]jobLease
+}
+
+func NewMemoryJobLocks() *MemoryJobLocks {
+	return &MemoryJobLocks{leases: make(map[string]jobLease)}
+}
+
+func (l *MemoryJobLocks) Acquire(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := l.leases[jobID]; ok && now.Before(lease.expires) {
+		return false, nil
+	}
+	l.leases[jobID] = jobLease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *MemoryJobLocks) Renew(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	lease, ok := l.leases[jobID]
+	if !ok || lease.owner != owner || !now.Before(lease.expires) {
+		return false, nil
+	}
+	l.leases[jobID] = jobLease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *MemoryJobLocks) Release(ctx context.Context, jobID, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lease, ok := l.leases[jobID]; ok && lease.owner == owner {
+		delete(l.leases, jobID)
+	}
+	return nil
+}
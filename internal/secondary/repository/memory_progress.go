@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"E.E/internal/core/domain"
+)
+
+// progressBuffer is how many updates a subscriber may fall behind by before
+// further updates are dropped
+const progressBuffer = 16
+
+// MemoryProgressBroker passes job progress from workers to subscribers in the
+// same process
+type MemoryProgressBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan domain.Progress]struct{}
+}
+
+func NewMemoryProgressBroker() *MemoryProgressBroker {
+	return &MemoryProgressBroker{
+		subscribers: make(map[string]map[chan domain.Progress]struct{}),
+	}
+}
+
+// UpdateProgress never blocks: subscribers that are behind miss the update
+func (b *MemoryProgressBroker) UpdateProgress(ctx context.Context, jobID string, progress domain.Progress) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[jobID] {
+		select {
+		case ch <- progress:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *MemoryProgressBroker) SubscribeToProgress(ctx context.Context, jobID string) (<-chan domain.Progress, error) {
+	ch := make(chan domain.Progress, progressBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[jobID] == nil {
+		b.subscribers[jobID] = make(map[chan domain.Progress]struct{})
+	}
+	b.subscribers[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[jobID], ch)
+		if len(b.subscribers[jobID]) == 0 {
+			delete(b.subscribers, jobID)
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// MemoryJobQueue is an in-process job queue backed by a buffered channel
+type MemoryJobQueue struct {
+	jobs chan string
+}
+
+func NewMemoryJobQueue(size int) *MemoryJobQueue {
+	return &MemoryJobQueue{
+		jobs: make(chan string, size),
+	}
+}
+
+func (q *MemoryJobQueue) Enqueue(ctx context.Context, jobID string) error {
+	select {
+	case q.jobs <- jobID:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to enqueue job %s: %w", jobID, ctx.Err())
+	}
+}
+
+func (q *MemoryJobQueue) Dequeue(ctx context.Context) (string, error) {
+	select {
+	case jobID := <-q.jobs:
+		return jobID, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
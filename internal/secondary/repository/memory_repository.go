@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"E.E/internal/core/domain"
 )
@@ -30,6 +32,7 @@ func (r *MemoryRepository) Create(ctx context.Context, job *domain.EncryptionJob
 	}
 
 	r.jobs[job.ID] = job
+	r.appendPendingHistory(job)
 	return nil
 }
 
@@ -42,9 +45,31 @@ func (r *MemoryRepository) Update(ctx context.Context, job *domain.EncryptionJob
 	}
 
 	r.jobs[job.ID] = job
+	r.appendPendingHistory(job)
 	return nil
 }
 
+func (r *MemoryRepository) UpdateIf(ctx context.Context, job *domain.EncryptionJob, cond func(current *domain.EncryptionJob) bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.jobs[job.ID]
+	if !exists || !cond(current) {
+		return false, nil
+	}
+
+	r.jobs[job.ID] = job
+	r.appendPendingHistory(job)
+	return true, nil
+}
+
+// appendPendingHistory moves the job's unpersisted status changes into its
+// history. The caller must hold the lock.
+func (r *MemoryRepository) appendPendingHistory(job *domain.EncryptionJob) {
+	r.history[job.ID] = append(r.history[job.ID], job.PendingHistory()...)
+	job.ClearPendingHistory()
+}
+
 func (r *MemoryRepository) Get(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -57,6 +82,17 @@ func (r *MemoryRepository) Get(ctx context.Context, jobID string) (*domain.Encry
 	return job, nil
 }
 
+func (r *MemoryRepository) GetMany(ctx context.Context, jobIDs []string) ([]*domain.EncryptionJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make([]*domain.EncryptionJob, len(jobIDs))
+	for i, jobID := range jobIDs {
+		jobs[i] = r.jobs[jobID]
+	}
+	return jobs, nil
+}
+
 func (r *MemoryRepository) List(ctx context.Context) ([]*domain.EncryptionJob, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -68,6 +104,82 @@ func (r *MemoryRepository) List(ctx context.Context) ([]*domain.EncryptionJob, e
 	return jobs, nil
 }
 
+// Query filters and orders every job in memory
+func (r *MemoryRepository) Query(ctx context.Context, query domain.JobQuery) ([]*domain.EncryptionJob, error) {
+	r.mu.RLock()
+	jobs := make([]*domain.EncryptionJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		if query.Filter.Matches(job) && (query.After == nil || query.After.Precedes(job)) {
+			jobs = append(jobs, job)
+		}
+	}
+	r.mu.RUnlock()
+
+	key := func(job *domain.EncryptionJob) float64 {
+		switch query.OrderBy {
+		case domain.OrderByUpdatedAt:
+			return float64(job.UpdatedAt)
+		case domain.OrderByProgress:
+			return job.Progress.Percent
+		}
+		return float64(job.CreatedAt)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		a, b := key(jobs[i]), key(jobs[j])
+		if a == b {
+			return (jobs[i].ID < jobs[j].ID) != query.Descending
+		}
+		return (a < b) != query.Descending
+	})
+
+	if query.Offset >= len(jobs) {
+		return []*domain.EncryptionJob{}, nil
+	}
+	jobs = jobs[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(jobs) {
+		jobs = jobs[:query.Limit]
+	}
+	return jobs, nil
+}
+
+// Aggregate totals every job in memory
+func (r *MemoryRepository) Aggregate(ctx context.Context, now time.Time, latest int) (*domain.JobAggregates, error) {
+	r.mu.RLock()
+	aggregates := &domain.JobAggregates{ByStatus: domain.NewStatusCounts()}
+	for _, job := range r.jobs {
+		aggregates.Add(job, now)
+	}
+	r.mu.RUnlock()
+
+	jobs, err := r.Query(ctx, domain.JobQuery{Descending: true, Limit: latest})
+	if err != nil {
+		return nil, err
+	}
+	aggregates.LatestJobs = jobs
+	return aggregates, nil
+}
+
+// TenantUsage totals the tenant's jobs in memory
+func (r *MemoryRepository) TenantUsage(ctx context.Context, tenant string, since time.Time) (*domain.QuotaUsage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usage := &domain.QuotaUsage{}
+	for _, job := range r.jobs {
+		if job.TenantID() != tenant {
+			continue
+		}
+		if job.Active() {
+			usage.ConcurrentJobs++
+		}
+		if job.CreatedAt >= since.Unix() {
+			usage.JobsToday++
+		}
+		usage.Bytes += job.StoredBytes()
+	}
+	return usage, nil
+}
+
 func (r *MemoryRepository) Delete(ctx context.Context, jobID string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
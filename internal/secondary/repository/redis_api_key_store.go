@@ -0,0 +1,141 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    apiKeyPrefix       = "apikey:"
+    apiKeySecretPrefix = "apikey:secret:"
+    apiKeyIndexKey     = "apikeys"
+
+    // revokedAPIKeyRetention is how long revoked keys stay listed
+    revokedAPIKeyRetention = 30 * 24 * time.Hour
+)
+
+// RedisAPIKeyStore keeps API keys in Redis, each reachable by ID and, while
+// it is active, by the hash of its secret. Keys are listed through a set of
+// their IDs, which drops keys that expired.
+type RedisAPIKeyStore struct {
+    *RedisBase
+}
+
+func NewRedisAPIKeyStore(config RedisConfig, logger *zap.Logger) (*RedisAPIKeyStore, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisAPIKeyStore{RedisBase: base}, nil
+}
+
+func (s *RedisAPIKeyStore) SaveAPIKey(ctx context.Context, key *domain.APIKey) error {
+    stored := *key
+    stored.Secret = ""
+    data, err := json.Marshal(stored)
+    if err != nil {
+        return fmt.Errorf("failed to marshal API key: %w", err)
+    }
+
+    // Keys without an expiry are kept until they are revoked; revoked keys
+    // are kept a while longer so they can still be listed
+    var ttl time.Duration
+    if key.ExpiresAt != 0 {
+        ttl = time.Until(time.Unix(key.ExpiresAt, 0))
+        if ttl <= 0 {
+            return fmt.Errorf("API key %s has already expired", key.ID)
+        }
+    }
+    if key.RevokedAt != 0 && (ttl == 0 || ttl > revokedAPIKeyRetention) {
+        ttl = revokedAPIKeyRetention
+    }
+
+    pipe := s.client.TxPipeline()
+    pipe.Set(ctx, apiKeyPrefix+key.ID, data, ttl)
+    if key.RevokedAt != 0 {
+        pipe.Del(ctx, apiKeySecretPrefix+key.SecretHash)
+    } else {
+        pipe.Set(ctx, apiKeySecretPrefix+key.SecretHash, key.ID, ttl)
+    }
+    pipe.SAdd(ctx, apiKeyIndexKey, key.ID)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to save API key %s: %w", key.ID, err)
+    }
+    return nil
+}
+
+func (s *RedisAPIKeyStore) GetAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+    data, err := s.client.Get(ctx, apiKeyPrefix+keyID).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get API key %s: %w", keyID, err)
+    }
+
+    var key domain.APIKey
+    if err := json.Unmarshal(data, &key); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal API key %s: %w", keyID, err)
+    }
+    return &key, nil
+}
+
+func (s *RedisAPIKeyStore) FindAPIKey(ctx context.Context, secretHash string) (*domain.APIKey, error) {
+    keyID, err := s.client.Get(ctx, apiKeySecretPrefix+secretHash).Result()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to find API key: %w", err)
+    }
+    return s.GetAPIKey(ctx, keyID)
+}
+
+func (s *RedisAPIKeyStore) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+    ids, err := s.client.SMembers(ctx, apiKeyIndexKey).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list API keys: %w", err)
+    }
+    if len(ids) == 0 {
+        return []*domain.APIKey{}, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = apiKeyPrefix + id
+    }
+    values, err := s.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get API keys: %w", err)
+    }
+
+    apiKeys := make([]*domain.APIKey, 0, len(values))
+    var gone []interface{}
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            gone = append(gone, ids[i]) // Expired
+            continue
+        }
+        var key domain.APIKey
+        if err := json.Unmarshal([]byte(data), &key); err != nil {
+            s.logger.Warn("Skipping unreadable API key", zap.String("key_id", ids[i]), zap.Error(err))
+            continue
+        }
+        apiKeys = append(apiKeys, &key)
+    }
+    if len(gone) > 0 {
+        if err := s.client.SRem(ctx, apiKeyIndexKey, gone...).Err(); err != nil {
+            s.logger.Warn("Failed to drop expired API keys from the index", zap.Error(err))
+        }
+    }
+    return apiKeys, nil
+}
@@ -5,6 +5,8 @@ import (
     "github.com/redis/go-redis/v9"
     "go.uber.org/zap"
 	"fmt"
+
+    "E.E/pkg/clock"
 )
 
 type RedisBase struct {
@@ -27,6 +29,10 @@ func newRedisBase(config RedisConfig, logger *zap.Logger) (*RedisBase, error) {
         WriteTimeout: config.WriteTimeout,
     }
 
+    if config.Clock == nil {
+        config.Clock = clock.System{}
+    }
+
     client := redis.NewClient(opts)
 
     ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
@@ -12,6 +12,10 @@ import (
     "E.E/internal/core/ports"
 )
 
+// batchesByTenantPrefix names the sets of the batch IDs of each tenant, so a
+// tenant's batches are listed without scanning every batch
+const batchesByTenantPrefix = "batches:tenant:"
+
 type RedisBatchRepository struct {
     *RedisBase
 }
@@ -31,8 +35,16 @@ func (r *RedisBatchRepository) StoreBatchResult(ctx context.Context, result *dom
         return fmt.Errorf("failed to marshal batch result: %w", err)
     }
 
-    // Use JobTTL from config
-    if err := r.client.Set(ctx, key, data, r.config.JobTTL).Err(); err != nil {
+    // Use JobTTL from config; the tenant's set lives as long as its newest batch
+    pipe := r.client.TxPipeline()
+    pipe.Set(ctx, key, data, r.config.JobTTL)
+    if tenant := result.TenantID(); tenant != "" {
+        pipe.SAdd(ctx, batchesByTenantPrefix+tenant, result.BatchID)
+        if r.config.JobTTL > 0 {
+            pipe.Expire(ctx, batchesByTenantPrefix+tenant, r.config.JobTTL)
+        }
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
         return fmt.Errorf("failed to store batch result: %w", err)
     }
 
@@ -58,45 +70,146 @@ func (r *RedisBatchRepository) GetBatchResult(ctx context.Context, batchID strin
 }
 
 func (r *RedisBatchRepository) ListBatchResults(ctx context.Context, filter domain.BatchFilter) ([]*domain.BatchResult, error) {
-    // Get all batch keys
-    pattern := "batch:*"
-    keys, err := r.client.Keys(ctx, pattern).Result()
-    if err != nil {
-        return nil, fmt.Errorf("failed to list batch keys: %w", err)
+    if filter.Tenant != "" {
+        return r.listTenantBatchResults(ctx, filter)
     }
 
+    // Batch keys are walked with SCAN rather than KEYS, so Redis is never
+    // blocked, and each page of keys is read with pipelined MGETs
     var results []*domain.BatchResult
-    for _, key := range keys {
-        data, err := r.client.Get(ctx, key).Bytes()
+    seen := make(map[string]struct{}) // SCAN may return a key more than once
+
+    var cursor uint64
+    for {
+        keys, next, err := r.client.Scan(ctx, cursor, "batch:*", listScanCount).Result()
         if err != nil {
-            r.logger.Error("Failed to get batch result",
-                zap.String("key", key),
-                zap.Error(err))
-            continue
+            return nil, fmt.Errorf("failed to list batch keys: %w", err)
+        }
+
+        page := make([]string, 0, len(keys))
+        for _, key := range keys {
+            if _, ok := seen[key]; ok {
+                continue
+            }
+            seen[key] = struct{}{}
+            page = append(page, key)
+        }
+        found, err := r.getBatchResults(ctx, page)
+        if err != nil {
+            return nil, err
+        }
+        for _, result := range found {
+            if result != nil && matchesBatchFilter(result, filter) {
+                results = append(results, result)
+            }
+        }
+
+        if next == 0 {
+            return results, nil
+        }
+        cursor = next
+    }
+}
+
+// getBatchResults reads the batches stored at keys with pipelined MGETs of at
+// most mgetBatchSize keys. Batches that expired meanwhile or cannot be decoded
+// are left nil.
+func (r *RedisBatchRepository) getBatchResults(ctx context.Context, keys []string) ([]*domain.BatchResult, error) {
+    results := make([]*domain.BatchResult, len(keys))
+    if len(keys) == 0 {
+        return results, nil
+    }
+
+    pipe := r.client.Pipeline()
+    cmds := make([]*redis.SliceCmd, 0, (len(keys)+mgetBatchSize-1)/mgetBatchSize)
+    for start := 0; start < len(keys); start += mgetBatchSize {
+        cmds = append(cmds, pipe.MGet(ctx, keys[start:min(start+mgetBatchSize, len(keys))]...))
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return nil, fmt.Errorf("failed to get batch results: %w", err)
+    }
+
+    for batch, cmd := range cmds {
+        for i, value := range cmd.Val() {
+            data, ok := value.(string)
+            if !ok {
+                continue // Expired
+            }
+            index := batch*mgetBatchSize + i
+            var result domain.BatchResult
+            if err := json.Unmarshal([]byte(data), &result); err != nil {
+                r.logger.Error("Failed to unmarshal batch result",
+                    zap.String("key", keys[index]),
+                    zap.Error(err))
+                continue
+            }
+            results[index] = &result
         }
+    }
+    return results, nil
+}
+
+// listTenantBatchResults reads the batches in a tenant's set, dropping those
+// that expired from it
+func (r *RedisBatchRepository) listTenantBatchResults(ctx context.Context, filter domain.BatchFilter) ([]*domain.BatchResult, error) {
+    setKey := batchesByTenantPrefix + filter.Tenant
+    ids, err := r.client.SMembers(ctx, setKey).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list batches of tenant %s: %w", filter.Tenant, err)
+    }
+    if len(ids) == 0 {
+        return nil, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = fmt.Sprintf("batch:%s", id)
+    }
+    values, err := r.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get batch results: %w", err)
+    }
 
+    var results []*domain.BatchResult
+    var gone []interface{}
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            gone = append(gone, ids[i]) // Expired
+            continue
+        }
         var result domain.BatchResult
-        if err := json.Unmarshal(data, &result); err != nil {
+        if err := json.Unmarshal([]byte(data), &result); err != nil {
             r.logger.Error("Failed to unmarshal batch result",
-                zap.String("key", key),
+                zap.String("key", keys[i]),
                 zap.Error(err))
             continue
         }
-
         if matchesBatchFilter(&result, filter) {
             results = append(results, &result)
         }
     }
-
+    if len(gone) > 0 {
+        if err := r.client.SRem(ctx, setKey, gone...).Err(); err != nil {
+            r.logger.Warn("Failed to drop expired batches from the tenant index", zap.Error(err))
+        }
+    }
     return results, nil
 }
 
 func matchesBatchFilter(result *domain.BatchResult, filter domain.BatchFilter) bool {
     // If no filter is specified, include all results
-    if filter.Status == "" && len(filter.JobIDs) == 0 {
+    if filter.Status == "" && len(filter.JobIDs) == 0 && filter.CreatedBy == "" && filter.Tenant == "" {
         return true
     }
 
+    if filter.CreatedBy != "" && result.CreatedBy != filter.CreatedBy {
+        return false
+    }
+    if filter.Tenant != "" && result.TenantID() != filter.Tenant {
+        return false
+    }
+
     // Check status if specified
     if filter.Status != "" {
         // Use Summary to determine status
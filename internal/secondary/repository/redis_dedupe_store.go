@@ -0,0 +1,40 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "go.uber.org/zap"
+)
+
+// RedisDedupeStore keeps claimed keys in Redis, so every process sharing it
+// sees the same claims
+type RedisDedupeStore struct {
+    *RedisBase
+    prefix string
+}
+
+// NewRedisDedupeStore creates a store whose keys live under prefix
+func NewRedisDedupeStore(config RedisConfig, prefix string, logger *zap.Logger) (*RedisDedupeStore, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisDedupeStore{RedisBase: base, prefix: prefix}, nil
+}
+
+func (s *RedisDedupeStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+    claimed, err := s.client.SetNX(ctx, s.prefix+key, s.config.Clock.Now().Unix(), ttl).Result()
+    if err != nil {
+        return false, fmt.Errorf("failed to claim %s: %w", key, err)
+    }
+    return claimed, nil
+}
+
+func (s *RedisDedupeStore) Release(ctx context.Context, key string) error {
+    if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+        return fmt.Errorf("failed to release %s: %w", key, err)
+    }
+    return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const eventQueueKey = "queue:events"
+
+// RedisEventQueue keeps job events in a Redis list until a webhook dispatcher
+// in any process takes them, so events survive restarts
+type RedisEventQueue struct {
+    *RedisBase
+}
+
+func NewRedisEventQueue(config RedisConfig, logger *zap.Logger) (*RedisEventQueue, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisEventQueue{RedisBase: base}, nil
+}
+
+func (q *RedisEventQueue) Publish(ctx context.Context, event domain.QueuedEvent) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("failed to marshal event: %w", err)
+    }
+    if err := q.client.LPush(ctx, eventQueueKey, data).Err(); err != nil {
+        return fmt.Errorf("failed to publish %s event for job %s: %w", event.Payload.EventType, event.Payload.JobID, err)
+    }
+    return nil
+}
+
+func (q *RedisEventQueue) Consume(ctx context.Context) (domain.QueuedEvent, error) {
+    for {
+        if err := ctx.Err(); err != nil {
+            return domain.QueuedEvent{}, err
+        }
+
+        result, err := q.client.BRPop(ctx, dequeuePollTimeout, eventQueueKey).Result()
+        if err != nil {
+            if errors.Is(err, redis.Nil) {
+                continue // Nothing queued within the poll timeout
+            }
+            if ctx.Err() != nil {
+                return domain.QueuedEvent{}, ctx.Err()
+            }
+            return domain.QueuedEvent{}, fmt.Errorf("failed to consume event: %w", err)
+        }
+
+        // BRPOP returns the list name followed by the popped value
+        var event domain.QueuedEvent
+        if err := json.Unmarshal([]byte(result[1]), &event); err != nil {
+            q.logger.Error("Dropping unreadable event", zap.Error(err))
+            continue
+        }
+        return event, nil
+    }
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "time"
+
+    "go.uber.org/zap"
+)
+
+const heartbeatsKey = "jobs:heartbeats"
+
+// RedisJobHeartbeats keeps job heartbeats in a Redis hash of job ID to Unix
+// time, so workers in any process can be followed
+type RedisJobHeartbeats struct {
+    *RedisBase
+}
+
+func NewRedisJobHeartbeats(config RedisConfig, logger *zap.Logger) (*RedisJobHeartbeats, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisJobHeartbeats{RedisBase: base}, nil
+}
+
+func (h *RedisJobHeartbeats) Beat(ctx context.Context, jobID string, at time.Time) error {
+    if err := h.client.HSet(ctx, heartbeatsKey, jobID, at.Unix()).Err(); err != nil {
+        return fmt.Errorf("failed to record heartbeat of job %s: %w", jobID, err)
+    }
+    return nil
+}
+
+func (h *RedisJobHeartbeats) Clear(ctx context.Context, jobID string) error {
+    if err := h.client.HDel(ctx, heartbeatsKey, jobID).Err(); err != nil {
+        return fmt.Errorf("failed to clear heartbeat of job %s: %w", jobID, err)
+    }
+    return nil
+}
+
+func (h *RedisJobHeartbeats) Last(ctx context.Context, jobIDs []string) (map[string]time.Time, error) {
+    beats := make(map[string]time.Time, len(jobIDs))
+    if len(jobIDs) == 0 {
+        return beats, nil
+    }
+
+    values, err := h.client.HMGet(ctx, heartbeatsKey, jobIDs...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to load job heartbeats: %w", err)
+    }
+    for i, value := range values {
+        str, ok := value.(string)
+        if !ok {
+            continue // No heartbeat
+        }
+        unix, err := strconv.ParseInt(str, 10, 64)
+        if err != nil {
+            h.logger.Warn("Skipping unreadable job heartbeat", zap.String("job_id", jobIDs[i]), zap.Error(err))
+            continue
+        }
+        beats[jobIDs[i]] = time.Unix(unix, 0)
+    }
+    return beats, nil
+}
@@ -0,0 +1,539 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// Sorted sets indexing the jobs, so listings read only the jobs on a page.
+// Members are job IDs; the status, owner, tenant and source hash indexes are
+// scored by creation time like jobsByCreatedKey.
+const (
+	jobsByCreatedKey   = "jobs:by_created"
+	jobsByUpdatedKey   = "jobs:by_updated"
+	jobsByProgressKey  = "jobs:by_progress" // Scored by progress percent
+	jobsByExpiryKey    = "jobs:by_expiry" // Used to drop expired jobs from the other indexes
+	jobsByStatusPrefix = "jobs:status:"
+	jobsByOwnerPrefix  = "jobs:owner:"
+	jobOwnersKey       = "jobs:owners" // Hash of job ID to owner, to find a removed job's owner index
+	jobsByTenantPrefix = "jobs:tenant:"
+	jobTenantsKey      = "jobs:tenants" // Hash of job ID to tenant, like jobOwnersKey
+	jobsBySourceHashPrefix = "jobs:source_hash:"
+	jobSourceHashesKey     = "jobs:source_hashes" // Hash of job ID to source hash, like jobOwnersKey
+	jobIndexVersionKey = "jobs:index_version"
+
+	// Running totals for the status summary, kept in step with the per-job
+	// values they add up so each write applies only its difference
+	jobStatsKey      = "jobs:stats"      // Hash of progress_sum and completion_sum
+	jobProgressKey   = "jobs:progress"   // Hash of job ID to progress percent
+	jobCompletionKey = "jobs:completion" // Hash of job ID to seconds taken by a completed job
+
+	// Running totals for tenant quotas, kept like the status summary totals
+	jobActiveKey      = "jobs:active"        // Hash of the IDs of jobs that are not finished
+	jobBytesKey       = "jobs:bytes"         // Hash of job ID to stored output bytes
+	tenantUsagePrefix = "jobs:tenant_usage:" // Hash of active and bytes per tenant
+
+	jobIndexVersion = "6"
+
+	// queryScanBatch is how many index entries a query reads at a time when
+	// it has to filter jobs the indexes cannot
+	queryScanBatch = 100
+)
+
+// updateJobStats records a job's progress and completion time and moves the
+// running totals by the difference from its previous values
+var updateJobStats = redis.NewScript(`
+local id = ARGV[1]
+local progress = tonumber(ARGV[2])
+local completion = tonumber(ARGV[3])
+local oldProgress = tonumber(redis.call('HGET', KEYS[1], id) or '0')
+local oldCompletion = tonumber(redis.call('HGET', KEYS[2], id) or '0')
+redis.call('HSET', KEYS[1], id, ARGV[2])
+if completion > 0 then
+	redis.call('HSET', KEYS[2], id, ARGV[3])
+else
+	redis.call('HDEL', KEYS[2], id)
+end
+redis.call('HINCRBYFLOAT', KEYS[3], 'progress_sum', progress - oldProgress)
+redis.call('HINCRBY', KEYS[3], 'completion_sum', completion - oldCompletion)
+return 0
+`)
+
+// removeJobStats takes removed jobs out of the running totals
+var removeJobStats = redis.NewScript(`
+for _, id in ipairs(ARGV) do
+	local progress = tonumber(redis.call('HGET', KEYS[1], id) or '0')
+	local completion = tonumber(redis.call('HGET', KEYS[2], id) or '0')
+	redis.call('HDEL', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+	redis.call('HINCRBYFLOAT', KEYS[3], 'progress_sum', -progress)
+	redis.call('HINCRBY', KEYS[3], 'completion_sum', -completion)
+end
+return 0
+`)
+
+var jobStatsKeys = []string{jobProgressKey, jobCompletionKey, jobStatsKey}
+
+// updateTenantUsage records whether a job is active and the bytes it stores,
+// and moves its tenant's totals by the difference from its previous values
+var updateTenantUsage = redis.NewScript(`
+local id = ARGV[1]
+local active = tonumber(ARGV[2])
+local bytes = tonumber(ARGV[3])
+local wasActive = redis.call('HEXISTS', KEYS[1], id)
+local oldBytes = tonumber(redis.call('HGET', KEYS[2], id) or '0')
+if active == 1 then
+	redis.call('HSET', KEYS[1], id, 1)
+else
+	redis.call('HDEL', KEYS[1], id)
+end
+if bytes > 0 then
+	redis.call('HSET', KEYS[2], id, ARGV[3])
+else
+	redis.call('HDEL', KEYS[2], id)
+end
+redis.call('HINCRBY', KEYS[3], 'active', active - wasActive)
+redis.call('HINCRBY', KEYS[3], 'bytes', bytes - oldBytes)
+return 0
+`)
+
+// removeTenantUsage takes removed jobs of one tenant out of its totals
+var removeTenantUsage = redis.NewScript(`
+for _, id in ipairs(ARGV) do
+	local wasActive = redis.call('HEXISTS', KEYS[1], id)
+	local bytes = tonumber(redis.call('HGET', KEYS[2], id) or '0')
+	redis.call('HDEL', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+	redis.call('HINCRBY', KEYS[3], 'active', -wasActive)
+	redis.call('HINCRBY', KEYS[3], 'bytes', -bytes)
+end
+return 0
+`)
+
+func tenantUsageKeys(tenant string) []string {
+	return []string{jobActiveKey, jobBytesKey, tenantUsagePrefix + tenant}
+}
+
+func statusIndexKey(status domain.EncryptionStatus) string {
+	return jobsByStatusPrefix + string(status)
+}
+
+func ownerIndexKey(owner string) string {
+	return jobsByOwnerPrefix + owner
+}
+
+func tenantIndexKey(tenant string) string {
+	return jobsByTenantPrefix + tenant
+}
+
+func sourceHashIndexKey(hash string) string {
+	return jobsBySourceHashPrefix + hash
+}
+
+// indexJob adds the commands that index job to pipe
+func indexJob(ctx context.Context, pipe redis.Pipeliner, job *domain.EncryptionJob) {
+	created := float64(job.CreatedAt)
+	pipe.ZAdd(ctx, jobsByCreatedKey, redis.Z{Score: created, Member: job.ID})
+	pipe.ZAdd(ctx, jobsByUpdatedKey, redis.Z{Score: float64(job.UpdatedAt), Member: job.ID})
+	pipe.ZAdd(ctx, jobsByProgressKey, redis.Z{Score: job.Progress.Percent, Member: job.ID})
+	pipe.ZAdd(ctx, jobsByExpiryKey, redis.Z{Score: float64(job.ExpiresAt), Member: job.ID})
+	for _, status := range domain.AllStatuses {
+		if status != job.Status {
+			pipe.ZRem(ctx, statusIndexKey(status), job.ID)
+		}
+	}
+	pipe.ZAdd(ctx, statusIndexKey(job.Status), redis.Z{Score: created, Member: job.ID})
+	if job.CreatedBy != "" {
+		pipe.ZAdd(ctx, ownerIndexKey(job.CreatedBy), redis.Z{Score: created, Member: job.ID})
+		pipe.HSet(ctx, jobOwnersKey, job.ID, job.CreatedBy)
+	}
+	if tenant := job.TenantID(); tenant != "" {
+		pipe.ZAdd(ctx, tenantIndexKey(tenant), redis.Z{Score: created, Member: job.ID})
+		pipe.HSet(ctx, jobTenantsKey, job.ID, tenant)
+		active := 0
+		if job.Active() {
+			active = 1
+		}
+		updateTenantUsage.Eval(ctx, pipe, tenantUsageKeys(tenant), job.ID, active, job.StoredBytes())
+	}
+	if job.SourceHash != "" {
+		pipe.ZAdd(ctx, sourceHashIndexKey(job.SourceHash), redis.Z{Score: created, Member: job.ID})
+		pipe.HSet(ctx, jobSourceHashesKey, job.ID, job.SourceHash)
+	}
+	updateJobStats.Eval(ctx, pipe, jobStatsKeys,
+		job.ID,
+		strconv.FormatFloat(job.Progress.Percent, 'f', -1, 64),
+		job.CompletionSeconds())
+}
+
+// unindexJobs removes jobs from every index
+func (r *RedisJobRepository) unindexJobs(ctx context.Context, jobIDs ...string) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+	owners, err := r.RedisBase.client.HMGet(ctx, jobOwnersKey, jobIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to look up job owners: %w", err)
+	}
+	tenants, err := r.RedisBase.client.HMGet(ctx, jobTenantsKey, jobIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to look up job tenants: %w", err)
+	}
+	hashes, err := r.RedisBase.client.HMGet(ctx, jobSourceHashesKey, jobIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to look up job source hashes: %w", err)
+	}
+
+	members := make([]interface{}, len(jobIDs))
+	for i, id := range jobIDs {
+		members[i] = id
+	}
+	pipe := r.RedisBase.client.TxPipeline()
+	pipe.ZRem(ctx, jobsByCreatedKey, members...)
+	pipe.ZRem(ctx, jobsByUpdatedKey, members...)
+	pipe.ZRem(ctx, jobsByProgressKey, members...)
+	pipe.ZRem(ctx, jobsByExpiryKey, members...)
+	for _, status := range domain.AllStatuses {
+		pipe.ZRem(ctx, statusIndexKey(status), members...)
+	}
+	for i, owner := range owners {
+		if owner, ok := owner.(string); ok && owner != "" {
+			pipe.ZRem(ctx, ownerIndexKey(owner), jobIDs[i])
+		}
+	}
+	pipe.HDel(ctx, jobOwnersKey, jobIDs...)
+	byTenant := make(map[string][]interface{})
+	for i, tenant := range tenants {
+		if tenant, ok := tenant.(string); ok && tenant != "" {
+			pipe.ZRem(ctx, tenantIndexKey(tenant), jobIDs[i])
+			byTenant[tenant] = append(byTenant[tenant], jobIDs[i])
+		}
+	}
+	for tenant, ids := range byTenant {
+		removeTenantUsage.Eval(ctx, pipe, tenantUsageKeys(tenant), ids...)
+	}
+	pipe.HDel(ctx, jobTenantsKey, jobIDs...)
+	for i, hash := range hashes {
+		if hash, ok := hash.(string); ok && hash != "" {
+			pipe.ZRem(ctx, sourceHashIndexKey(hash), jobIDs[i])
+		}
+	}
+	pipe.HDel(ctx, jobSourceHashesKey, jobIDs...)
+	removeJobStats.Eval(ctx, pipe, jobStatsKeys, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove jobs from indexes: %w", err)
+	}
+	return nil
+}
+
+// purgeExpired drops jobs whose records have expired from the indexes. Jobs
+// whose records are still there, such as ones written before expiry times were
+// recorded, are rescored with their actual expiry instead.
+func (r *RedisJobRepository) purgeExpired(ctx context.Context) error {
+	now := r.RedisBase.config.Clock.Now()
+	expired, err := r.RedisBase.client.ZRangeByScore(ctx, jobsByExpiryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to find expired jobs: %w", err)
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	pipe := r.RedisBase.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(expired))
+	for i, id := range expired {
+		ttls[i] = pipe.PTTL(ctx, jobKeyPrefix+id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to check expired jobs: %w", err)
+	}
+
+	var gone []string
+	var rescored []redis.Z
+	for i, id := range expired {
+		switch ttl := ttls[i].Val(); {
+		case ttl > 0:
+			rescored = append(rescored, redis.Z{Score: float64(now.Add(ttl).Unix() + 1), Member: id})
+		case ttl == -1: // Kept without expiry
+			rescored = append(rescored, redis.Z{Score: math.Inf(1), Member: id})
+		default:
+			gone = append(gone, id)
+		}
+	}
+	if len(rescored) > 0 {
+		if err := r.RedisBase.client.ZAdd(ctx, jobsByExpiryKey, rescored...).Err(); err != nil {
+			return fmt.Errorf("failed to rescore job expiry: %w", err)
+		}
+	}
+	return r.unindexJobs(ctx, gone...)
+}
+
+// ensureIndexes indexes jobs stored before the indexes existed
+func (r *RedisJobRepository) ensureIndexes(ctx context.Context) error {
+	version, err := r.RedisBase.client.Get(ctx, jobIndexVersionKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to read job index version: %w", err)
+	}
+	if version == jobIndexVersion {
+		return nil
+	}
+
+	indexed := 0
+	iter := r.RedisBase.client.Scan(ctx, 0, jobKeyPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		data, err := r.RedisBase.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue // Expired since the scan saw it
+		}
+		var job domain.EncryptionJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			r.RedisBase.logger.Warn("Skipping unreadable job while indexing",
+				zap.String("key", iter.Val()),
+				zap.Error(err))
+			continue
+		}
+		pipe := r.RedisBase.client.TxPipeline()
+		indexJob(ctx, pipe, &job)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to index job %s: %w", job.ID, err)
+		}
+		indexed++
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan jobs: %w", err)
+	}
+
+	if err := r.RedisBase.client.Set(ctx, jobIndexVersionKey, jobIndexVersion, 0).Err(); err != nil {
+		return fmt.Errorf("failed to record job index version: %w", err)
+	}
+	r.RedisBase.logger.Info("Indexed existing jobs", zap.Int("jobs", indexed))
+	return nil
+}
+
+// Query reads jobs in index order. The index is picked from the order and
+// filter; when the filter has conditions the index does not cover, index
+// entries are read in batches and filtered until the page is full.
+func (r *RedisJobRepository) Query(ctx context.Context, query domain.JobQuery) ([]*domain.EncryptionJob, error) {
+	if err := r.purgeExpired(ctx); err != nil {
+		r.RedisBase.logger.Warn("Failed to purge expired jobs from indexes", zap.Error(err))
+	}
+
+	filter := query.Filter
+	args := redis.ZRangeArgs{
+		Key:     jobsByCreatedKey,
+		Start:   "-inf",
+		Stop:    "+inf",
+		ByScore: true,
+		Rev:     query.Descending,
+	}
+	// exact is whether the index alone selects the matching jobs
+	exact := filter.SourceURL == "" && len(filter.Metadata) == 0
+	switch query.OrderBy {
+	case domain.OrderByUpdatedAt:
+		args.Key = jobsByUpdatedKey
+		exact = exact && filter.MinProgress == 0 && filter.Status == "" && filter.CreatedBy == "" && filter.Tenant == "" && filter.SourceHash == "" && filter.StartDate == 0 && filter.EndDate == 0
+	case domain.OrderByProgress:
+		// The minimum progress is a score range of this index
+		args.Key = jobsByProgressKey
+		if filter.MinProgress > 0 {
+			args.Start = strconv.FormatFloat(filter.MinProgress, 'f', -1, 64)
+		}
+		exact = exact && filter.Status == "" && filter.CreatedBy == "" && filter.Tenant == "" && filter.SourceHash == "" && filter.StartDate == 0 && filter.EndDate == 0
+	default:
+		exact = exact && filter.MinProgress == 0
+		switch {
+		case filter.SourceHash != "":
+			// Few jobs share a source, so this index is the narrowest
+			args.Key = sourceHashIndexKey(filter.SourceHash)
+			exact = exact && filter.Status == "" && filter.CreatedBy == "" && filter.Tenant == ""
+		case filter.Status != "":
+			args.Key = statusIndexKey(domain.EncryptionStatus(filter.Status))
+			exact = exact && filter.CreatedBy == "" && filter.Tenant == ""
+		case filter.CreatedBy != "":
+			args.Key = ownerIndexKey(filter.CreatedBy)
+			exact = exact && filter.Tenant == ""
+		case filter.Tenant != "":
+			args.Key = tenantIndexKey(filter.Tenant)
+		}
+		if filter.StartDate > 0 {
+			args.Start = strconv.FormatInt(filter.StartDate, 10)
+		}
+		if filter.EndDate > 0 {
+			args.Stop = strconv.FormatInt(filter.EndDate, 10)
+		}
+		// Jobs created in the cursor's second are read and skipped up to the
+		// cursor; members with equal scores are ordered by ID
+		if after := query.After; after != nil {
+			exact = false
+			if after.CreatedAt > filter.StartDate {
+				args.Start = strconv.FormatInt(after.CreatedAt, 10)
+			}
+		}
+	}
+
+	// With an exact index the offset is applied by Redis and only the page is
+	// read; otherwise matches are counted off as they are found
+	skip := query.Offset
+	if exact {
+		args.Offset = int64(query.Offset)
+		skip = 0
+	}
+	args.Count = queryScanBatch
+	if exact && query.Limit > 0 {
+		args.Count = int64(query.Limit)
+	}
+
+	jobs := make([]*domain.EncryptionJob, 0, query.Limit)
+	for {
+		ids, err := r.RedisBase.client.ZRangeArgs(ctx, args).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query job index: %w", err)
+		}
+		if len(ids) == 0 {
+			return jobs, nil
+		}
+
+		found, missing, err := r.getJobs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			// Expired between the purge and the read; removing them shifts
+			// the following entries back
+			if err := r.unindexJobs(ctx, missing...); err != nil {
+				return nil, err
+			}
+			args.Offset -= int64(len(missing))
+		}
+		args.Offset += int64(len(ids))
+
+		for _, job := range found {
+			if !filter.Matches(job) || (query.After != nil && !query.After.Precedes(job)) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			jobs = append(jobs, job)
+			if query.Limit > 0 && len(jobs) == query.Limit {
+				return jobs, nil
+			}
+		}
+		if int64(len(ids)) < args.Count {
+			return jobs, nil
+		}
+	}
+}
+
+// getJobs reads jobs by ID in order, returning the IDs of jobs that no longer
+// exist separately
+func (r *RedisJobRepository) getJobs(ctx context.Context, ids []string) ([]*domain.EncryptionJob, []string, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = jobKeyPrefix + id
+	}
+	values, err := r.RedisBase.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get jobs from Redis: %w", err)
+	}
+
+	jobs := make([]*domain.EncryptionJob, 0, len(ids))
+	var missing []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+		var job domain.EncryptionJob
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			r.RedisBase.logger.Error("Failed to unmarshal job data",
+				zap.String("job_id", ids[i]),
+				zap.Error(err))
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, missing, nil
+}
+
+// Aggregate reads the status summary totals from the indexes and running
+// totals, so its cost does not grow with the number of jobs
+func (r *RedisJobRepository) Aggregate(ctx context.Context, now time.Time, latest int) (*domain.JobAggregates, error) {
+	if err := r.purgeExpired(ctx); err != nil {
+		r.RedisBase.logger.Warn("Failed to purge expired jobs from indexes", zap.Error(err))
+	}
+
+	pipe := r.RedisBase.client.Pipeline()
+	counts := make(map[domain.EncryptionStatus]*redis.IntCmd, len(domain.AllStatuses))
+	for _, status := range domain.AllStatuses {
+		counts[status] = pipe.ZCard(ctx, statusIndexKey(status))
+	}
+	since := func(d time.Duration) string {
+		return "(" + strconv.FormatInt(now.Add(-d).Unix(), 10)
+	}
+	lastDay := pipe.ZCount(ctx, jobsByCreatedKey, since(24*time.Hour), "+inf")
+	lastWeek := pipe.ZCount(ctx, jobsByCreatedKey, since(7*24*time.Hour), "+inf")
+	sums := pipe.HMGet(ctx, jobStatsKey, "progress_sum", "completion_sum")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read job totals: %w", err)
+	}
+
+	aggregates := &domain.JobAggregates{
+		ByStatus:        domain.NewStatusCounts(),
+		CreatedLast24h:  int(lastDay.Val()),
+		CreatedLastWeek: int(lastWeek.Val()),
+	}
+	for status, count := range counts {
+		aggregates.ByStatus[status] = int(count.Val())
+	}
+	if v, ok := sums.Val()[0].(string); ok {
+		aggregates.ProgressSum, _ = strconv.ParseFloat(v, 64)
+	}
+	if v, ok := sums.Val()[1].(string); ok {
+		aggregates.CompletionSecondsSum, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	jobs, err := r.Query(ctx, domain.JobQuery{Descending: true, Limit: latest})
+	if err != nil {
+		return nil, err
+	}
+	aggregates.LatestJobs = jobs
+	return aggregates, nil
+}
+
+// TenantUsage reads a tenant's quota usage from its index and running totals
+func (r *RedisJobRepository) TenantUsage(ctx context.Context, tenant string, since time.Time) (*domain.QuotaUsage, error) {
+	if err := r.purgeExpired(ctx); err != nil {
+		r.RedisBase.logger.Warn("Failed to purge expired jobs from indexes", zap.Error(err))
+	}
+
+	pipe := r.RedisBase.client.Pipeline()
+	totals := pipe.HMGet(ctx, tenantUsagePrefix+tenant, "active", "bytes")
+	created := pipe.ZCount(ctx, tenantIndexKey(tenant), strconv.FormatInt(since.Unix(), 10), "+inf")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read usage of tenant %s: %w", tenant, err)
+	}
+
+	usage := &domain.QuotaUsage{JobsToday: int(created.Val())}
+	if v, ok := totals.Val()[0].(string); ok {
+		usage.ConcurrentJobs, _ = strconv.Atoi(v)
+	}
+	if v, ok := totals.Val()[1].(string); ok {
+		usage.Bytes, _ = strconv.ParseInt(v, 10, 64)
+	}
+	return usage, nil
+}
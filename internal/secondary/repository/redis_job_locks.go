@@ -0,0 +1,67 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+)
+
+const jobLockPrefix = "lock:job:"
+
+// renewLockScript extends the lock KEYS[1] to ARGV[2] milliseconds if ARGV[1]
+// still holds it
+var renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript deletes the lock KEYS[1] if ARGV[1] still holds it
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisJobLocks keeps job leases as Redis keys holding their owner, which
+// expire unless renewed, so a job held by a worker that died is free again
+// once its lease runs out
+type RedisJobLocks struct {
+    *RedisBase
+}
+
+func NewRedisJobLocks(config RedisConfig, logger *zap.Logger) (*RedisJobLocks, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisJobLocks{RedisBase: base}, nil
+}
+
+func (l *RedisJobLocks) Acquire(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error) {
+    acquired, err := l.client.SetNX(ctx, jobLockPrefix+jobID, owner, ttl).Result()
+    if err != nil {
+        return false, fmt.Errorf("failed to lock job %s: %w", jobID, err)
+    }
+    return acquired, nil
+}
+
+func (l *RedisJobLocks) Renew(ctx context.Context, jobID, owner string, ttl time.Duration) (bool, error) {
+    renewed, err := renewLockScript.Run(ctx, l.client, []string{jobLockPrefix + jobID}, owner, ttl.Milliseconds()).Int()
+    if err != nil {
+        return false, fmt.Errorf("failed to renew lock of job %s: %w", jobID, err)
+    }
+    return renewed == 1, nil
+}
+
+func (l *RedisJobLocks) Release(ctx context.Context, jobID, owner string) error {
+    if err := releaseLockScript.Run(ctx, l.client, []string{jobLockPrefix + jobID}, owner).Err(); err != nil {
+        return fmt.Errorf("failed to release lock of job %s: %w", jobID, err)
+    }
+    return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    keyPolicyPrefix     = "key:policy:"
+    keyDeliveriesPrefix = "key:deliveries:"
+    keyAuditPrefix      = "key:audit:"
+)
+
+// maxKeyAuditEvents bounds the audit trail kept per job; the structured log
+// keeps every event
+const maxKeyAuditEvents = 10000
+
+// countDeliveryScript increments a key's delivery count unless it reached
+// ARGV[1] (0 for no limit), refreshing the count's expiry to ARGV[2] seconds
+var countDeliveryScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if limit > 0 and count >= limit then
+    return 0
+end
+redis.call("INCR", KEYS[1])
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// RedisKeyStore keeps key access policies, delivery counts and the key audit
+// trail in Redis. Records expire retention after their last write.
+type RedisKeyStore struct {
+    *RedisBase
+    retention time.Duration
+}
+
+func NewRedisKeyStore(config RedisConfig, retention time.Duration, logger *zap.Logger) (*RedisKeyStore, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisKeyStore{RedisBase: base, retention: retention}, nil
+}
+
+func (s *RedisKeyStore) GetPolicy(ctx context.Context, key domain.ContentKey) (*domain.KeyPolicy, error) {
+    data, err := s.client.Get(ctx, keyPolicyPrefix+key.String()).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get policy of key %s: %w", key, err)
+    }
+
+    var policy domain.KeyPolicy
+    if err := json.Unmarshal(data, &policy); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal policy of key %s: %w", key, err)
+    }
+    return &policy, nil
+}
+
+func (s *RedisKeyStore) SavePolicy(ctx context.Context, policy *domain.KeyPolicy) error {
+    data, err := json.Marshal(policy)
+    if err != nil {
+        return fmt.Errorf("failed to marshal key policy: %w", err)
+    }
+    if err := s.client.Set(ctx, keyPolicyPrefix+policy.Key.String(), data, s.retention).Err(); err != nil {
+        return fmt.Errorf("failed to save policy of key %s: %w", policy.Key, err)
+    }
+    return nil
+}
+
+func (s *RedisKeyStore) CountDelivery(ctx context.Context, key domain.ContentKey, limit int) (bool, error) {
+    counted, err := countDeliveryScript.Run(ctx, s.client,
+        []string{keyDeliveriesPrefix + key.String()},
+        limit, int64(s.retention.Seconds()),
+    ).Int()
+    if err != nil {
+        return false, fmt.Errorf("failed to count delivery of key %s: %w", key, err)
+    }
+    return counted == 1, nil
+}
+
+func (s *RedisKeyStore) Record(ctx context.Context, event domain.KeyAuditEvent) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("failed to marshal key audit event: %w", err)
+    }
+
+    listKey := keyAuditPrefix + event.Key.JobID
+    pipe := s.client.TxPipeline()
+    pipe.LPush(ctx, listKey, data)
+    pipe.LTrim(ctx, listKey, 0, maxKeyAuditEvents-1)
+    pipe.Expire(ctx, listKey, s.retention)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to record key audit event: %w", err)
+    }
+    return nil
+}
+
+func (s *RedisKeyStore) List(ctx context.Context, jobID string, limit int) ([]domain.KeyAuditEvent, error) {
+    values, err := s.client.LRange(ctx, keyAuditPrefix+jobID, 0, int64(limit)-1).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list key audit events of job %s: %w", jobID, err)
+    }
+
+    events := make([]domain.KeyAuditEvent, 0, len(values))
+    for _, value := range values {
+        var event domain.KeyAuditEvent
+        if err := json.Unmarshal([]byte(value), &event); err != nil {
+            s.logger.Warn("Skipping unreadable key audit event", zap.String("job_id", jobID), zap.Error(err))
+            continue
+        }
+        events = append(events, event)
+    }
+    return events, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "go.uber.org/zap"
+)
+
+const leaderLockPrefix = "lock:leader:"
+
+// RedisLeaderLocks elects leaders among the processes sharing a Redis with
+// keys holding the leader, which expire unless renewed, so another process
+// takes over once a leader dies
+type RedisLeaderLocks struct {
+    *RedisBase
+}
+
+func NewRedisLeaderLocks(config RedisConfig, logger *zap.Logger) (*RedisLeaderLocks, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisLeaderLocks{RedisBase: base}, nil
+}
+
+func (l *RedisLeaderLocks) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+    acquired, err := l.client.SetNX(ctx, leaderLockPrefix+name, owner, ttl).Result()
+    if err != nil {
+        return false, fmt.Errorf("failed to acquire leader lock %s: %w", name, err)
+    }
+    return acquired, nil
+}
+
+func (l *RedisLeaderLocks) Renew(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+    renewed, err := renewLockScript.Run(ctx, l.client, []string{leaderLockPrefix + name}, owner, ttl.Milliseconds()).Int()
+    if err != nil {
+        return false, fmt.Errorf("failed to renew leader lock %s: %w", name, err)
+    }
+    return renewed == 1, nil
+}
+
+func (l *RedisLeaderLocks) Release(ctx context.Context, name, owner string) error {
+    if err := releaseLockScript.Run(ctx, l.client, []string{leaderLockPrefix + name}, owner).Err(); err != nil {
+        return fmt.Errorf("failed to release leader lock %s: %w", name, err)
+    }
+    return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const progressChannelPrefix = "progress:"
+
+// RedisProgressBroker passes job progress from workers to subscribers in any
+// process over Redis pub/sub. Updates are not stored: subscribers only see
+// those published while they are subscribed.
+type RedisProgressBroker struct {
+    *RedisBase
+}
+
+func NewRedisProgressBroker(config RedisConfig, logger *zap.Logger) (*RedisProgressBroker, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisProgressBroker{RedisBase: base}, nil
+}
+
+func (b *RedisProgressBroker) UpdateProgress(ctx context.Context, jobID string, progress domain.Progress) error {
+    data, err := json.Marshal(progress)
+    if err != nil {
+        return fmt.Errorf("failed to marshal progress: %w", err)
+    }
+    if err := b.client.Publish(ctx, progressChannelPrefix+jobID, data).Err(); err != nil {
+        return fmt.Errorf("failed to publish progress of job %s: %w", jobID, err)
+    }
+    return nil
+}
+
+func (b *RedisProgressBroker) SubscribeToProgress(ctx context.Context, jobID string) (<-chan domain.Progress, error) {
+    pubsub := b.client.Subscribe(ctx, progressChannelPrefix+jobID)
+    // Wait for the subscription, so updates published after this returns
+    // are not missed
+    if _, err := pubsub.Receive(ctx); err != nil {
+        pubsub.Close()
+        return nil, fmt.Errorf("failed to subscribe to progress of job %s: %w", jobID, err)
+    }
+
+    ch := make(chan domain.Progress, progressBuffer)
+    go func() {
+        defer close(ch)
+        defer pubsub.Close()
+
+        messages := pubsub.Channel()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case msg, ok := <-messages:
+                if !ok {
+                    return
+                }
+                var progress domain.Progress
+                if err := json.Unmarshal([]byte(msg.Payload), &progress); err != nil {
+                    b.logger.Warn("Skipping unreadable progress update",
+                        zap.String("job_id", jobID),
+                        zap.Error(err))
+                    continue
+                }
+                select {
+                case ch <- progress:
+                default: // The subscriber is behind
+                }
+            }
+        }
+    }()
+
+    return ch, nil
+}
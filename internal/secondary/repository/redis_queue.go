@@ -0,0 +1,62 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+)
+
+const (
+    jobQueueKey = "queue:jobs"
+
+    // dequeuePollTimeout bounds each blocking pop so cancellation of the
+    // dequeue context is noticed promptly
+    dequeuePollTimeout = time.Second
+)
+
+// RedisJobQueue is a job queue stored in a Redis list, shared by every API and
+// worker process connected to the same Redis
+type RedisJobQueue struct {
+    *RedisBase
+}
+
+func NewRedisJobQueue(config RedisConfig, logger *zap.Logger) (*RedisJobQueue, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisJobQueue{RedisBase: base}, nil
+}
+
+func (q *RedisJobQueue) Enqueue(ctx context.Context, jobID string) error {
+    if err := q.client.LPush(ctx, jobQueueKey, jobID).Err(); err != nil {
+        return fmt.Errorf("failed to enqueue job %s: %w", jobID, err)
+    }
+    return nil
+}
+
+func (q *RedisJobQueue) Dequeue(ctx context.Context) (string, error) {
+    for {
+        if err := ctx.Err(); err != nil {
+            return "", err
+        }
+
+        result, err := q.client.BRPop(ctx, dequeuePollTimeout, jobQueueKey).Result()
+        if err != nil {
+            if errors.Is(err, redis.Nil) {
+                continue // Nothing queued within the poll timeout
+            }
+            if ctx.Err() != nil {
+                return "", ctx.Err()
+            }
+            return "", fmt.Errorf("failed to dequeue job: %w", err)
+        }
+
+        // BRPOP returns the list name followed by the popped value
+        return result[1], nil
+    }
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// slidingWindowScript admits a request when fewer than ARGV[3] requests were
+// admitted in the window ending now, recording it under a unique member. It
+// returns 0 when admitted, otherwise the milliseconds until the oldest request
+// in the window expires.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < limit then
+    redis.call('ZADD', KEYS[1], now, ARGV[4])
+    redis.call('PEXPIRE', KEYS[1], window)
+    return 0
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local wait = window - (now - tonumber(oldest[2]))
+if wait < 1 then wait = 1 end
+return wait
+`)
+
+// RedisRateLimiter is a sliding-window rate limiter kept in Redis, so every API
+// process connected to the same Redis enforces one shared limit per client.
+// Each client's window expires with its last request, bounding memory.
+type RedisRateLimiter struct {
+    *RedisBase
+    requests int
+    window   time.Duration
+}
+
+func NewRedisRateLimiter(config RedisConfig, requests int, window time.Duration, logger *zap.Logger) (*RedisRateLimiter, error) {
+    if requests <= 0 || window <= 0 {
+        return nil, fmt.Errorf("rate limit needs positive requests and window, got %d per %s", requests, window)
+    }
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisRateLimiter{RedisBase: base, requests: requests, window: window}, nil
+}
+
+// Allow records a request from key if the window has room for it. Requests are
+// let through when Redis cannot be reached, so an outage of the limiter does
+// not take the API down with it.
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+    now := l.config.Clock.Now().UnixMilli()
+    wait, err := slidingWindowScript.Run(ctx, l.client,
+        []string{rateLimitKeyPrefix + key},
+        now, l.window.Milliseconds(), l.requests, uuid.NewString(),
+    ).Int64()
+    if err != nil {
+        l.logger.Warn("Rate limiter unavailable, allowing request",
+            zap.String("key", key),
+            zap.Error(err))
+        return true, 0
+    }
+    if wait > 0 {
+        return false, time.Duration(wait) * time.Millisecond
+    }
+    return true, 0
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    recurringPrefix         = "recurring:"
+    recurringIndexKey       = "recurring"
+    recurringTenantIndexKey = "recurring:tenant:"
+)
+
+// RedisRecurringRepository keeps the recurring batches registered through the
+// API in Redis, listed through a set of all their IDs and one per tenant.
+// Recurring batches are kept until they are deleted.
+type RedisRecurringRepository struct {
+    *RedisBase
+}
+
+func NewRedisRecurringRepository(config RedisConfig, logger *zap.Logger) (*RedisRecurringRepository, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisRecurringRepository{RedisBase: base}, nil
+}
+
+func (r *RedisRecurringRepository) SaveRecurring(ctx context.Context, recurring *domain.RecurringBatch) error {
+    data, err := json.Marshal(recurring)
+    if err != nil {
+        return fmt.Errorf("failed to marshal recurring batch: %w", err)
+    }
+
+    pipe := r.client.TxPipeline()
+    pipe.Set(ctx, recurringPrefix+recurring.ID, data, 0)
+    pipe.SAdd(ctx, recurringIndexKey, recurring.ID)
+    pipe.SAdd(ctx, recurringTenantIndexKey+recurring.Tenant, recurring.ID)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to save recurring batch %s: %w", recurring.ID, err)
+    }
+    return nil
+}
+
+func (r *RedisRecurringRepository) GetRecurring(ctx context.Context, id string) (*domain.RecurringBatch, error) {
+    data, err := r.client.Get(ctx, recurringPrefix+id).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get recurring batch %s: %w", id, err)
+    }
+
+    var recurring domain.RecurringBatch
+    if err := json.Unmarshal(data, &recurring); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal recurring batch %s: %w", id, err)
+    }
+    return &recurring, nil
+}
+
+func (r *RedisRecurringRepository) ListRecurring(ctx context.Context, tenant string) ([]*domain.RecurringBatch, error) {
+    index := recurringIndexKey
+    if tenant != "" {
+        index = recurringTenantIndexKey + tenant
+    }
+    ids, err := r.client.SMembers(ctx, index).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list recurring batches: %w", err)
+    }
+    if len(ids) == 0 {
+        return []*domain.RecurringBatch{}, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = recurringPrefix + id
+    }
+    values, err := r.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get recurring batches: %w", err)
+    }
+
+    batches := make([]*domain.RecurringBatch, 0, len(values))
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            continue // Deleted since the index was read
+        }
+        var recurring domain.RecurringBatch
+        if err := json.Unmarshal([]byte(data), &recurring); err != nil {
+            r.logger.Warn("Skipping unreadable recurring batch", zap.String("recurring_id", ids[i]), zap.Error(err))
+            continue
+        }
+        batches = append(batches, &recurring)
+    }
+    return batches, nil
+}
+
+func (r *RedisRecurringRepository) DeleteRecurring(ctx context.Context, id string) error {
+    recurring, err := r.GetRecurring(ctx, id)
+    if err != nil || recurring == nil {
+        return err
+    }
+
+    pipe := r.client.TxPipeline()
+    pipe.Del(ctx, recurringPrefix+id)
+    pipe.SRem(ctx, recurringIndexKey, id)
+    pipe.SRem(ctx, recurringTenantIndexKey+recurring.Tenant, id)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to delete recurring batch %s: %w", id, err)
+    }
+    return nil
+}
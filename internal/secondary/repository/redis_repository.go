@@ -3,7 +3,10 @@ package repository
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "strings"
+    "time"
 
     "github.com/redis/go-redis/v9"
     "go.uber.org/zap"
@@ -14,6 +17,13 @@ import (
 
 const (
     jobKeyPrefix = "job:"
+
+    // mgetBatchSize bounds the keys read by one MGET, so hydrating a large
+    // batch does not stall Redis on a single huge command
+    mgetBatchSize = 500
+
+    // listScanCount is the COUNT hint of the SCAN calls listing jobs
+    listScanCount = 500
 )
 
 type RedisJobRepository struct {
@@ -25,19 +35,25 @@ func NewRedisJobRepository(config RedisConfig, logger *zap.Logger) (ports.JobRep
     if err != nil {
         return nil, err
     }
-    return &RedisJobRepository{RedisBase: base}, nil
+    repo := &RedisJobRepository{RedisBase: base}
+
+    ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+    defer cancel()
+    if err := repo.ensureIndexes(ctx); err != nil {
+        return nil, err
+    }
+    return repo, nil
 }
 
 func (r *RedisJobRepository) Create(ctx context.Context, job *domain.EncryptionJob) error {
-    data, err := json.Marshal(job)
-    if err != nil {
-        return fmt.Errorf("failed to marshal job: %w", err)
+    pipe := r.RedisBase.client.TxPipeline()
+    if err := r.writeJob(ctx, pipe, job); err != nil {
+        return err
     }
-
-    key := fmt.Sprintf("%s%s", jobKeyPrefix, job.ID)
-    if err := r.RedisBase.client.Set(ctx, key, data, r.RedisBase.config.JobTTL).Err(); err != nil {
+    if _, err := pipe.Exec(ctx); err != nil {
         return fmt.Errorf("failed to save job to Redis: %w", err)
     }
+    job.ClearPendingHistory()
 
     return nil
 }
@@ -46,6 +62,77 @@ func (r *RedisJobRepository) Update(ctx context.Context, job *domain.EncryptionJ
     return r.Create(ctx, job) // Same operation for Redis
 }
 
+// UpdateIf watches the job's key while it checks the stored job, so a write
+// made between the check and its own fails it instead of being overwritten
+func (r *RedisJobRepository) UpdateIf(ctx context.Context, job *domain.EncryptionJob, cond func(current *domain.EncryptionJob) bool) (bool, error) {
+    key := fmt.Sprintf("%s%s", jobKeyPrefix, job.ID)
+    updated := false
+    err := r.RedisBase.client.Watch(ctx, func(tx *redis.Tx) error {
+        data, err := tx.Get(ctx, key).Bytes()
+        if err == redis.Nil {
+            return nil // Job not found
+        }
+        if err != nil {
+            return err
+        }
+        var current domain.EncryptionJob
+        if err := json.Unmarshal(data, &current); err != nil {
+            return fmt.Errorf("failed to unmarshal job: %w", err)
+        }
+        if !cond(&current) {
+            return nil
+        }
+
+        _, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+            return r.writeJob(ctx, pipe, job)
+        })
+        updated = err == nil
+        return err
+    }, key)
+    if errors.Is(err, redis.TxFailedErr) {
+        return false, nil // Changed since it was checked
+    }
+    if err != nil {
+        return false, fmt.Errorf("failed to save job to Redis: %w", err)
+    }
+    if updated {
+        job.ClearPendingHistory()
+    }
+    return updated, nil
+}
+
+// writeJob queues the writes storing a job on pipe
+func (r *RedisJobRepository) writeJob(ctx context.Context, pipe redis.Pipeliner, job *domain.EncryptionJob) error {
+    // Every write keeps the job for at least JobTTL; a longer retention set
+    // through an extension is preserved
+    now := r.RedisBase.config.Clock.Now()
+    if expiresAt := now.Add(r.RedisBase.config.JobTTL).Unix(); job.ExpiresAt < expiresAt {
+        job.ExpiresAt = expiresAt
+    }
+    ttl := time.Unix(job.ExpiresAt, 0).Sub(now)
+
+    data, err := json.Marshal(job)
+    if err != nil {
+        return fmt.Errorf("failed to marshal job: %w", err)
+    }
+
+    // Store the job together with the history of its status changes, which
+    // expires with it
+    key := fmt.Sprintf("%s%s", jobKeyPrefix, job.ID)
+    historyKey := fmt.Sprintf("job_history:%s", job.ID)
+    pipe.Set(ctx, key, data, ttl)
+    for _, entry := range job.PendingHistory() {
+        entryData, err := json.Marshal(entry)
+        if err != nil {
+            return fmt.Errorf("failed to marshal job history entry: %w", err)
+        }
+        pipe.RPush(ctx, historyKey, entryData)
+    }
+    pipe.Expire(ctx, historyKey, ttl)
+    indexJob(ctx, pipe, job)
+    return nil
+}
+
 func (r *RedisJobRepository) Get(ctx context.Context, jobID string) (*domain.EncryptionJob, error) {
     key := fmt.Sprintf("%s%s", jobKeyPrefix, jobID)
     data, err := r.RedisBase.client.Get(ctx, key).Bytes()
@@ -70,41 +157,93 @@ func (r *RedisJobRepository) Delete(ctx context.Context, jobID string) error {
         return fmt.Errorf("failed to delete job from Redis: %w", err)
     }
 
-    return nil
+    return r.unindexJobs(ctx, jobID)
 }
 
-func (r *RedisJobRepository) List(ctx context.Context) ([]*domain.EncryptionJob, error) {
-    keys, err := r.RedisBase.client.Keys(ctx, jobKeyPrefix+"*").Result()
-    if err != nil {
-        return nil, fmt.Errorf("failed to list jobs from Redis: %w", err)
+// GetMany reads jobs with MGET calls of at most mgetBatchSize keys, all sent
+// in one pipeline
+func (r *RedisJobRepository) GetMany(ctx context.Context, jobIDs []string) ([]*domain.EncryptionJob, error) {
+    jobs := make([]*domain.EncryptionJob, len(jobIDs))
+    if len(jobIDs) == 0 {
+        return jobs, nil
     }
 
-    jobs := make([]*domain.EncryptionJob, 0, len(keys))
-    for _, key := range keys {
-        data, err := r.RedisBase.client.Get(ctx, key).Bytes()
-        if err != nil {
-            r.RedisBase.logger.Error("Failed to get job data", 
-                zap.String("key", key),
-                zap.Error(err),
-            )
-            continue
+    pipe := r.RedisBase.client.Pipeline()
+    cmds := make([]*redis.SliceCmd, 0, (len(jobIDs)+mgetBatchSize-1)/mgetBatchSize)
+    for start := 0; start < len(jobIDs); start += mgetBatchSize {
+        end := min(start+mgetBatchSize, len(jobIDs))
+        keys := make([]string, 0, end-start)
+        for _, jobID := range jobIDs[start:end] {
+            keys = append(keys, jobKeyPrefix+jobID)
         }
+        cmds = append(cmds, pipe.MGet(ctx, keys...))
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return nil, fmt.Errorf("failed to get jobs from Redis: %w", err)
+    }
 
-        var job domain.EncryptionJob
-        if err := json.Unmarshal(data, &job); err != nil {
-            r.RedisBase.logger.Error("Failed to unmarshal job data",
-                zap.String("key", key),
-                zap.Error(err),
-            )
-            continue
+    for batch, cmd := range cmds {
+        for i, value := range cmd.Val() {
+            data, ok := value.(string)
+            if !ok {
+                continue // Job not found
+            }
+            index := batch*mgetBatchSize + i
+            var job domain.EncryptionJob
+            if err := json.Unmarshal([]byte(data), &job); err != nil {
+                r.RedisBase.logger.Error("Failed to unmarshal job data",
+                    zap.String("job_id", jobIDs[index]),
+                    zap.Error(err),
+                )
+                continue
+            }
+            jobs[index] = &job
         }
-
-        jobs = append(jobs, &job)
     }
 
     return jobs, nil
 }
 
+// List reads every job. Job keys are walked with SCAN rather than KEYS, so
+// Redis is never blocked, and each page of keys is read with GetMany.
+// Listings that can be served from the indexes should use Query instead.
+func (r *RedisJobRepository) List(ctx context.Context) ([]*domain.EncryptionJob, error) {
+    var jobs []*domain.EncryptionJob
+    seen := make(map[string]struct{}) // SCAN may return a key more than once
+
+    var cursor uint64
+    for {
+        keys, next, err := r.RedisBase.client.Scan(ctx, cursor, jobKeyPrefix+"*", listScanCount).Result()
+        if err != nil {
+            return nil, fmt.Errorf("failed to list jobs from Redis: %w", err)
+        }
+
+        ids := make([]string, 0, len(keys))
+        for _, key := range keys {
+            id := strings.TrimPrefix(key, jobKeyPrefix)
+            if _, ok := seen[id]; ok {
+                continue
+            }
+            seen[id] = struct{}{}
+            ids = append(ids, id)
+        }
+        found, err := r.GetMany(ctx, ids)
+        if err != nil {
+            return nil, err
+        }
+        for _, job := range found {
+            if job != nil {
+                jobs = append(jobs, job)
+            }
+        }
+
+        if next == 0 {
+            return jobs, nil
+        }
+        cursor = next
+    }
+}
+
 func (r *RedisJobRepository) AddJobHistory(ctx context.Context, jobID string, entry domain.JobHistoryEntry) error {
     key := fmt.Sprintf("job_history:%s", jobID)
     data, err := json.Marshal(entry)
@@ -116,8 +255,12 @@ func (r *RedisJobRepository) AddJobHistory(ctx context.Context, jobID string, en
         return fmt.Errorf("failed to add job history entry: %w", err)
     }
 
-    // Set expiration if not already set
-    r.RedisBase.client.Expire(ctx, key, r.RedisBase.config.JobTTL)
+    // Expire with the job, which may have been kept longer than JobTTL
+    ttl := r.RedisBase.config.JobTTL
+    if jobTTL, err := r.RedisBase.client.PTTL(ctx, jobKeyPrefix+jobID).Result(); err == nil && jobTTL > ttl {
+        ttl = jobTTL
+    }
+    r.RedisBase.client.Expire(ctx, key, ttl)
     return nil
 }
 
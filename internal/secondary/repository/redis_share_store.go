@@ -0,0 +1,125 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    shareLinkPrefix = "share:link:"
+    shareJobPrefix  = "share:job:"
+)
+
+// indexShareLinkScript adds link ARGV[2] expiring at ARGV[1] to the job's
+// index, drops links that expired by ARGV[3] and keeps the index until its
+// last link expires
+var indexShareLinkScript = redis.NewScript(`
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[3])
+local last = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+if last[2] then
+    redis.call("EXPIREAT", KEYS[1], last[2])
+end
+return 1
+`)
+
+// RedisShareStore keeps share links in Redis until they expire, indexed by
+// job in a sorted set scored by expiry
+type RedisShareStore struct {
+    *RedisBase
+}
+
+func NewRedisShareStore(config RedisConfig, logger *zap.Logger) (*RedisShareStore, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisShareStore{RedisBase: base}, nil
+}
+
+func (s *RedisShareStore) SaveLink(ctx context.Context, link *domain.ShareLink) error {
+    stored := *link
+    stored.URL = ""
+    data, err := json.Marshal(stored)
+    if err != nil {
+        return fmt.Errorf("failed to marshal share link: %w", err)
+    }
+
+    ttl := time.Until(time.Unix(link.ExpiresAt, 0))
+    if ttl <= 0 {
+        return fmt.Errorf("share link %s has already expired", link.ID)
+    }
+    if err := s.client.Set(ctx, shareLinkPrefix+link.ID, data, ttl).Err(); err != nil {
+        return fmt.Errorf("failed to save share link %s: %w", link.ID, err)
+    }
+    if err := indexShareLinkScript.Run(ctx, s.client,
+        []string{shareJobPrefix + link.JobID},
+        link.ExpiresAt, link.ID, time.Now().Unix(),
+    ).Err(); err != nil {
+        return fmt.Errorf("failed to index share link %s: %w", link.ID, err)
+    }
+    return nil
+}
+
+func (s *RedisShareStore) GetLink(ctx context.Context, linkID string) (*domain.ShareLink, error) {
+    data, err := s.client.Get(ctx, shareLinkPrefix+linkID).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get share link %s: %w", linkID, err)
+    }
+
+    var link domain.ShareLink
+    if err := json.Unmarshal(data, &link); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal share link %s: %w", linkID, err)
+    }
+    return &link, nil
+}
+
+func (s *RedisShareStore) ListLinks(ctx context.Context, jobID string) ([]*domain.ShareLink, error) {
+    ids, err := s.client.ZRangeByScore(ctx, shareJobPrefix+jobID, &redis.ZRangeBy{
+        Min: fmt.Sprintf("(%d", time.Now().Unix()),
+        Max: "+inf",
+    }).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list share links of job %s: %w", jobID, err)
+    }
+    if len(ids) == 0 {
+        return []*domain.ShareLink{}, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = shareLinkPrefix + id
+    }
+    values, err := s.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get share links of job %s: %w", jobID, err)
+    }
+
+    links := make([]*domain.ShareLink, 0, len(values))
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            continue // Expired between the two reads
+        }
+        var link domain.ShareLink
+        if err := json.Unmarshal([]byte(data), &link); err != nil {
+            s.logger.Warn("Skipping unreadable share link", zap.String("link_id", ids[i]), zap.Error(err))
+            continue
+        }
+        links = append(links, &link)
+    }
+    sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt > links[j].CreatedAt })
+    return links, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    uploadPrefix       = "upload:"
+    uploadsByExpiryKey = "uploads:expiry"
+)
+
+// RedisUploadStore keeps the state of resumable uploads in Redis, indexed in
+// a sorted set scored by expiry. Uploads are kept past their expiry until
+// they are deleted, so whoever sweeps them can still find their chunks.
+type RedisUploadStore struct {
+    *RedisBase
+}
+
+func NewRedisUploadStore(config RedisConfig, logger *zap.Logger) (*RedisUploadStore, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisUploadStore{RedisBase: base}, nil
+}
+
+func (s *RedisUploadStore) SaveUpload(ctx context.Context, upload *domain.ResumableUpload) error {
+    data, err := json.Marshal(upload)
+    if err != nil {
+        return fmt.Errorf("failed to marshal upload: %w", err)
+    }
+
+    pipe := s.client.TxPipeline()
+    pipe.Set(ctx, uploadPrefix+upload.ID, data, 0)
+    pipe.ZAdd(ctx, uploadsByExpiryKey, redis.Z{Score: float64(upload.ExpiresAt), Member: upload.ID})
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to save upload %s: %w", upload.ID, err)
+    }
+    return nil
+}
+
+func (s *RedisUploadStore) GetUpload(ctx context.Context, id string) (*domain.ResumableUpload, error) {
+    data, err := s.client.Get(ctx, uploadPrefix+id).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get upload %s: %w", id, err)
+    }
+
+    var upload domain.ResumableUpload
+    if err := json.Unmarshal(data, &upload); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal upload %s: %w", id, err)
+    }
+    return &upload, nil
+}
+
+func (s *RedisUploadStore) ExpiredUploads(ctx context.Context, now time.Time) ([]*domain.ResumableUpload, error) {
+    ids, err := s.client.ZRangeByScore(ctx, uploadsByExpiryKey, &redis.ZRangeBy{
+        Min: "-inf",
+        Max: "(" + strconv.FormatInt(now.Unix(), 10),
+    }).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to find expired uploads: %w", err)
+    }
+    if len(ids) == 0 {
+        return nil, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = uploadPrefix + id
+    }
+    values, err := s.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get expired uploads: %w", err)
+    }
+
+    uploads := make([]*domain.ResumableUpload, 0, len(values))
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            // Deleted since the index was read, or never saved whole
+            s.client.ZRem(ctx, uploadsByExpiryKey, ids[i])
+            continue
+        }
+        var upload domain.ResumableUpload
+        if err := json.Unmarshal([]byte(data), &upload); err != nil {
+            s.logger.Warn("Skipping unreadable upload", zap.String("upload_id", ids[i]), zap.Error(err))
+            continue
+        }
+        uploads = append(uploads, &upload)
+    }
+    return uploads, nil
+}
+
+func (s *RedisUploadStore) DeleteUpload(ctx context.Context, id string) error {
+    pipe := s.client.TxPipeline()
+    pipe.Del(ctx, uploadPrefix+id)
+    pipe.ZRem(ctx, uploadsByExpiryKey, id)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to delete upload %s: %w", id, err)
+    }
+    return nil
+}
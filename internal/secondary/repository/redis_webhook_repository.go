@@ -0,0 +1,161 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "go.uber.org/zap"
+
+    "E.E/internal/core/domain"
+)
+
+const (
+    webhookPrefix            = "webhook:"
+    webhookIndexKey          = "webhooks"
+    webhookTenantIndexKey    = "webhooks:tenant:"
+    webhookDeadLettersPrefix = "webhook:dead:"
+)
+
+// RedisWebhookRepository keeps the webhooks registered through the API in
+// Redis, listed through a set of all their IDs and one per tenant. Webhooks
+// are kept until they are deleted. The deliveries that failed for good are
+// kept in a capped list per endpoint, which expires retention after the last
+// failure.
+type RedisWebhookRepository struct {
+    *RedisBase
+    deadLetters int
+    retention   time.Duration
+}
+
+func NewRedisWebhookRepository(config RedisConfig, deadLetters int, retention time.Duration, logger *zap.Logger) (*RedisWebhookRepository, error) {
+    base, err := newRedisBase(config, logger)
+    if err != nil {
+        return nil, err
+    }
+    return &RedisWebhookRepository{RedisBase: base, deadLetters: deadLetters, retention: retention}, nil
+}
+
+func (r *RedisWebhookRepository) SaveWebhook(ctx context.Context, webhook *domain.WebhookConfig) error {
+    data, err := json.Marshal(webhook)
+    if err != nil {
+        return fmt.Errorf("failed to marshal webhook: %w", err)
+    }
+
+    pipe := r.client.TxPipeline()
+    pipe.Set(ctx, webhookPrefix+webhook.ID, data, 0)
+    pipe.SAdd(ctx, webhookIndexKey, webhook.ID)
+    pipe.SAdd(ctx, webhookTenantIndexKey+webhook.Tenant, webhook.ID)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to save webhook %s: %w", webhook.ID, err)
+    }
+    return nil
+}
+
+func (r *RedisWebhookRepository) GetWebhook(ctx context.Context, id string) (*domain.WebhookConfig, error) {
+    data, err := r.client.Get(ctx, webhookPrefix+id).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get webhook %s: %w", id, err)
+    }
+
+    var webhook domain.WebhookConfig
+    if err := json.Unmarshal(data, &webhook); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal webhook %s: %w", id, err)
+    }
+    return &webhook, nil
+}
+
+func (r *RedisWebhookRepository) ListWebhooks(ctx context.Context, tenant string) ([]*domain.WebhookConfig, error) {
+    index := webhookIndexKey
+    if tenant != "" {
+        index = webhookTenantIndexKey + tenant
+    }
+    ids, err := r.client.SMembers(ctx, index).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list webhooks: %w", err)
+    }
+    if len(ids) == 0 {
+        return []*domain.WebhookConfig{}, nil
+    }
+
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = webhookPrefix + id
+    }
+    values, err := r.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get webhooks: %w", err)
+    }
+
+    webhooks := make([]*domain.WebhookConfig, 0, len(values))
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            continue // Deleted since the index was read
+        }
+        var webhook domain.WebhookConfig
+        if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+            r.logger.Warn("Skipping unreadable webhook", zap.String("webhook_id", ids[i]), zap.Error(err))
+            continue
+        }
+        webhooks = append(webhooks, &webhook)
+    }
+    return webhooks, nil
+}
+
+func (r *RedisWebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+    webhook, err := r.GetWebhook(ctx, id)
+    if err != nil || webhook == nil {
+        return err
+    }
+
+    pipe := r.client.TxPipeline()
+    pipe.Del(ctx, webhookPrefix+id, webhookDeadLettersPrefix+id)
+    pipe.SRem(ctx, webhookIndexKey, id)
+    pipe.SRem(ctx, webhookTenantIndexKey+webhook.Tenant, id)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to delete webhook %s: %w", id, err)
+    }
+    return nil
+}
+
+func (r *RedisWebhookRepository) RecordDeadLetter(ctx context.Context, delivery *domain.WebhookDelivery) error {
+    data, err := json.Marshal(delivery)
+    if err != nil {
+        return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+    }
+
+    listKey := webhookDeadLettersPrefix + delivery.Endpoint
+    pipe := r.client.TxPipeline()
+    pipe.LPush(ctx, listKey, data)
+    pipe.LTrim(ctx, listKey, 0, int64(r.deadLetters)-1)
+    pipe.Expire(ctx, listKey, r.retention)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to record failed webhook delivery: %w", err)
+    }
+    return nil
+}
+
+func (r *RedisWebhookRepository) ListDeadLetters(ctx context.Context, endpoint string, limit int) ([]*domain.WebhookDelivery, error) {
+    values, err := r.client.LRange(ctx, webhookDeadLettersPrefix+endpoint, 0, int64(limit)-1).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list failed webhook deliveries: %w", err)
+    }
+
+    deliveries := make([]*domain.WebhookDelivery, 0, len(values))
+    for _, value := range values {
+        var delivery domain.WebhookDelivery
+        if err := json.Unmarshal([]byte(value), &delivery); err != nil {
+            r.logger.Warn("Skipping unreadable webhook delivery", zap.String("endpoint", endpoint), zap.Error(err))
+            continue
+        }
+        deliveries = append(deliveries, &delivery)
+    }
+    return deliveries, nil
+}
@@ -3,65 +3,244 @@ package s3
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"time"
-	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"go.uber.org/zap"
 )
 
-// S3Client represents a simple S3 client interface
+// MinPartSize is the smallest part S3 accepts in a multipart upload
+const MinPartSize = manager.MinUploadPartSize
+
+// Credentials sign requests with a static access key
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Config configures access to Amazon S3 or an S3-compatible store
+type Config struct {
+	Region      string
+	Endpoint    string      // e.g. MinIO or LocalStack; Amazon S3 when empty
+	PathStyle   bool        // Address buckets as endpoint/bucket rather than bucket.endpoint
+	Credentials Credentials // The default AWS chain is used without an access key
+	MaxAttempts int         // Attempts per request, including the first
+	PartSize    int64       // Uploads larger than this are sent in parts of this size
+	Concurrency int         // Parts of one upload sent at once
+}
+
+// S3Client reads and writes objects with the AWS SDK. Requests that fail
+// with throttling or server errors are retried with backoff, and large
+// uploads are split into parts that are retried on their own.
 type S3Client struct {
-	logger *zap.Logger
+	client    *awss3.Client
+	uploader  *manager.Uploader
+	presigner *awss3.PresignClient
+	logger    *zap.Logger
 }
 
-// NewS3Client creates a new S3 client instance
-func NewS3Client(logger *zap.Logger) *S3Client {
-	return &S3Client{
-		logger: logger,
+// NewS3Client creates a new S3 client instance. Without a static access key,
+// credentials are read from the environment, the shared AWS config files or
+// the instance or pod role.
+func NewS3Client(ctx context.Context, config Config, logger *zap.Logger) (*S3Client, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(config.Region),
 	}
+	if config.MaxAttempts > 0 {
+		opts = append(opts, awsconfig.WithRetryMaxAttempts(config.MaxAttempts))
+	}
+	if config.Credentials.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			config.Credentials.AccessKeyID,
+			config.Credentials.SecretAccessKey,
+			config.Credentials.SessionToken,
+		)))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := awss3.NewFromConfig(awsConfig, func(o *awss3.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+		o.UsePathStyle = config.PathStyle
+	})
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		if config.PartSize > 0 {
+			u.PartSize = config.PartSize
+		}
+		if config.Concurrency > 0 {
+			u.Concurrency = config.Concurrency
+		}
+	})
+
+	return &S3Client{
+		client:    client,
+		uploader:  uploader,
+		presigner: awss3.NewPresignClient(client),
+		logger:    logger,
+	}, nil
 }
 
-// UploadFile is a placeholder for file upload functionality
+// UploadFile streams content to bucket/key. Content larger than the part size
+// is sent as a multipart upload, which is aborted if any part fails.
 func (c *S3Client) UploadFile(ctx context.Context, bucket, key string, content io.Reader) error {
-	c.logger.Info("Simulating S3 upload",
+	out, err := c.uploader.Upload(ctx, &awss3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", bucket, key, err)
+	}
+
+	c.logger.Debug("Uploaded S3 object",
 		zap.String("bucket", bucket),
 		zap.String("key", key),
-		zap.String("operation", "upload"),
-		zap.String("timestamp", time.Now().String()),
+		zap.Bool("multipart", out.UploadID != ""),
 	)
 	return nil
 }
 
-// DownloadFile is a placeholder for file download functionality
-func (c *S3Client) DownloadFile(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
-	c.logger.Info("Simulating S3 download",
-		zap.String("bucket", bucket),
-		zap.String("key", key),
-		zap.String("operation", "download"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return io.NopCloser(strings.NewReader("simulated file content")), nil
+// DownloadFile opens bucket/key and returns its content and size in bytes (-1
+// if unknown)
+func (c *S3Client) DownloadFile(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	out, err := c.client.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	return out.Body, aws.ToInt64(out.ContentLength), nil
+}
+
+// DownloadFileFrom returns a reader for bucket/key from offset on and the
+// number of bytes left
+func (c *S3Client) DownloadFileFrom(ctx context.Context, bucket, key string, offset int64) (io.ReadCloser, int64, error) {
+	out, err := c.client.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-", offset)),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download s3://%s/%s from %d: %w", bucket, key, offset, err)
+	}
+	return out.Body, aws.ToInt64(out.ContentLength), nil
+}
+
+// StatObject returns the size in bytes and content type of bucket/key
+// without downloading it
+func (c *S3Client) StatObject(ctx context.Context, bucket, key string) (int64, string, error) {
+	out, err := c.client.HeadObject(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, "", fmt.Errorf("s3://%s/%s does not exist", bucket, key)
+		}
+		return 0, "", fmt.Errorf("failed to check s3://%s/%s: %w", bucket, key, err)
+	}
+	return aws.ToInt64(out.ContentLength), aws.ToString(out.ContentType), nil
 }
 
-// DeleteFile is a placeholder for file deletion functionality
+// DeleteFile removes bucket/key. Deleting a missing object succeeds.
 func (c *S3Client) DeleteFile(ctx context.Context, bucket, key string) error {
-	c.logger.Info("Simulating S3 delete",
-		zap.String("bucket", bucket),
-		zap.String("key", key),
-		zap.String("operation", "delete"),
-		zap.String("timestamp", time.Now().String()),
-	)
+	_, err := c.client.DeleteObject(ctx, &awss3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete s3://%s/%s: %w", bucket, key, err)
+	}
 	return nil
 }
 
-// FileExists is a placeholder for checking if a file exists
+// ListObjects returns an s3:// URL for every object under a bucket prefix,
+// skipping folder placeholders
+func (c *S3Client) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var urls []string
+	pages := awss3.NewListObjectsV2Paginator(c.client, &awss3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if key == "" || key[len(key)-1] == '/' {
+				continue
+			}
+			urls = append(urls, "s3://"+bucket+"/"+key)
+		}
+	}
+	return urls, nil
+}
+
+// FileExists checks if bucket/key exists. Errors other than a missing object
+// are logged and reported as missing.
 func (c *S3Client) FileExists(ctx context.Context, bucket, key string) bool {
-	c.logger.Info("Simulating S3 exists check",
-		zap.String("bucket", bucket),
-		zap.String("key", key),
-		zap.String("operation", "exists"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return true
-}
\ No newline at end of file
+	_, err := c.client.HeadObject(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true
+	}
+	if !isNotFound(err) {
+		c.logger.Warn("Failed to check S3 object",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.Error(err),
+		)
+	}
+	return false
+}
+
+// PresignDownload returns a URL that downloads bucket/key without credentials
+// for ttl
+func (c *S3Client) PresignDownload(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	req, err := c.presigner.PresignGetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, awss3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign s3://%s/%s: %w", bucket, key, err)
+	}
+	return req.URL, nil
+}
+
+// CheckBucket verifies that bucket exists and the credentials may access it
+func (c *S3Client) CheckBucket(ctx context.Context, bucket string) error {
+	if _, err := c.client.HeadBucket(ctx, &awss3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		return fmt.Errorf("bucket %s is not accessible: %w", bucket, err)
+	}
+	return nil
+}
+
+// isNotFound reports whether err is S3 saying an object does not exist
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound"
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage stores files as the objects of one bucket, under an optional key
+// prefix, so job outputs can be written to S3 in place of local storage
+type Storage struct {
+	client *S3Client
+	bucket string
+	prefix string
+}
+
+func NewStorage(client *S3Client, bucket, prefix string) *Storage {
+	return &Storage{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// key maps a storage path to its object key
+func (s *Storage) key(p string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, filepath.ToSlash(p)), "/")
+}
+
+func (s *Storage) ReadFile(p string) (io.ReadCloser, error) {
+	body, _, err := s.client.DownloadFile(context.Background(), s.bucket, s.key(p))
+	return body, err
+}
+
+func (s *Storage) WriteFile(p string, content io.Reader) error {
+	return s.client.UploadFile(context.Background(), s.bucket, s.key(p), content)
+}
+
+func (s *Storage) DeleteFile(p string) error {
+	return s.client.DeleteFile(context.Background(), s.bucket, s.key(p))
+}
+
+func (s *Storage) FileExists(p string) bool {
+	return s.client.FileExists(context.Background(), s.bucket, s.key(p))
+}
+
+// URL returns the s3:// URL of the stored file
+func (s *Storage) URL(p string) string {
+	return "s3://" + s.bucket + "/" + s.key(p)
+}
+
+// PresignURL returns an HTTPS URL that downloads the file for ttl
+func (s *Storage) PresignURL(ctx context.Context, p string, ttl time.Duration) (string, error) {
+	return s.client.PresignDownload(ctx, s.bucket, s.key(p), ttl)
+}
+
+// HealthCheck verifies that the bucket is accessible
+func (s *Storage) HealthCheck(ctx context.Context) error {
+	return s.client.CheckBucket(ctx, s.bucket)
+}
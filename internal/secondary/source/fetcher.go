@@ -0,0 +1,262 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/secondary/s3"
+)
+
+// Fetcher opens job sources by URL scheme: s3://bucket/key, http(s)://,
+// file:// and bare paths. Local paths are confined to the configured root so
+// the API cannot be used to read arbitrary files on the host.
+type Fetcher struct {
+	localRoot  string
+	s3Client   *s3.S3Client
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// StatusError is returned when an http(s) source answers with a status other
+// than success
+type StatusError struct {
+	Op   string // What failed, e.g. "source download failed"
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s with status: %d", e.Op, e.Code)
+}
+
+// HTTPStatusCode returns the status the source answered with, named like the
+// AWS SDK's response errors so the two are told apart alike
+func (e *StatusError) HTTPStatusCode() int {
+	return e.Code
+}
+
+// NewFetcher creates a source fetcher rooted at localRoot
+func NewFetcher(localRoot string, s3Client *s3.S3Client, logger *zap.Logger) (*Fetcher, error) {
+	root, err := filepath.Abs(localRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve local root: %w", err)
+	}
+
+	return &Fetcher{
+		localRoot:  root,
+		s3Client:   s3Client,
+		httpClient: &http.Client{Timeout: 30 * time.Minute},
+		logger:     logger,
+	}, nil
+}
+
+// SetHTTPClient replaces the default client used for http(s) sources, e.g.
+// with one on a shared connection pool
+func (f *Fetcher) SetHTTPClient(client *http.Client) {
+	f.httpClient = client
+}
+
+// Open returns a reader for the source and its size in bytes (-1 if unknown)
+func (f *Fetcher) Open(ctx context.Context, sourceURL string) (io.ReadCloser, int64, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid source URL %q: %w", sourceURL, err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		body, size, err := f.s3Client.DownloadFile(ctx, u.Host, key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to download s3 source: %w", err)
+		}
+		return body, size, nil
+
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create source request: %w", err)
+		}
+		resp, err := f.httpClient.Do(req)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to download source: %w", err)
+		}
+		if resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, 0, &StatusError{Op: "source download failed", Code: resp.StatusCode}
+		}
+		return resp.Body, resp.ContentLength, nil
+
+	case "file", "":
+		path, err := f.localPath(u)
+		if err != nil {
+			return nil, 0, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open source file: %w", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("failed to stat source file: %w", err)
+		}
+		return file, info.Size(), nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported source scheme: %s", u.Scheme)
+	}
+}
+
+// OpenAt returns a reader for the source from offset and the size of the rest
+// in bytes (-1 if unknown). HTTP servers that ignore the range are read past
+// offset instead.
+func (f *Fetcher) OpenAt(ctx context.Context, sourceURL string, offset int64) (io.ReadCloser, int64, error) {
+	if offset == 0 {
+		return f.Open(ctx, sourceURL)
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid source URL %q: %w", sourceURL, err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		body, size, err := f.s3Client.DownloadFileFrom(ctx, u.Host, key, offset)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to download s3 source: %w", err)
+		}
+		return body, size, nil
+
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create source request: %w", err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		resp, err := f.httpClient.Do(req)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to download source: %w", err)
+		}
+		switch {
+		case resp.StatusCode == http.StatusPartialContent:
+			return resp.Body, resp.ContentLength, nil
+		case resp.StatusCode >= 300:
+			resp.Body.Close()
+			return nil, 0, &StatusError{Op: "source download failed", Code: resp.StatusCode}
+		}
+		f.logger.Debug("Source ignored the range, skipping to offset", zap.String("source_url", sourceURL))
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("failed to skip to offset %d of source: %w", offset, err)
+		}
+		size := resp.ContentLength
+		if size >= 0 {
+			size -= offset
+		}
+		return resp.Body, size, nil
+
+	case "file", "":
+		path, err := f.localPath(u)
+		if err != nil {
+			return nil, 0, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open source file: %w", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("failed to stat source file: %w", err)
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("failed to seek source file: %w", err)
+		}
+		return file, info.Size() - offset, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported source scheme: %s", u.Scheme)
+	}
+}
+
+// Inspect returns the size and content type of the source without reading
+// it: a HEAD request for http(s), the object's metadata for s3 and the file's
+// size and extension for local paths
+func (f *Fetcher) Inspect(ctx context.Context, sourceURL string) (*domain.SourceInfo, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL %q: %w", sourceURL, err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		size, contentType, err := f.s3Client.StatObject(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+		if err != nil {
+			return nil, err
+		}
+		return &domain.SourceInfo{Size: size, ContentType: contentType}, nil
+
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, sourceURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source request: %w", err)
+		}
+		resp, err := f.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach source: %w", err)
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			return nil, fmt.Errorf("source does not exist (status %d)", resp.StatusCode)
+		case resp.StatusCode >= 300:
+			return nil, &StatusError{Op: "source check failed", Code: resp.StatusCode}
+		}
+		return &domain.SourceInfo{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}, nil
+
+	case "file", "":
+		path, err := f.localPath(u)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat source file: %w", err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("source path %s is a directory", u.Path)
+		}
+		return &domain.SourceInfo{Size: info.Size(), ContentType: mime.TypeByExtension(filepath.Ext(path))}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported source scheme: %s", u.Scheme)
+	}
+}
+
+// localPath resolves a file:// URL or bare path inside the local root
+func (f *Fetcher) localPath(u *url.URL) (string, error) {
+	path := u.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.localRoot, path)
+	}
+	path = filepath.Clean(path)
+
+	rel, err := filepath.Rel(f.localRoot, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("source path %s is outside the storage root", u.Path)
+	}
+	return path, nil
+}
@@ -0,0 +1,194 @@
+// Package sqs reads S3 event notifications from an Amazon SQS queue
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxVisibilityTimeout is the longest SQS hides a received message
+const maxVisibilityTimeout = 12 * time.Hour
+
+// Credentials sign requests with AWS Signature Version 4
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Config locates the queue and sets how messages are received
+type Config struct {
+	QueueURL    string
+	Endpoint    string        // API endpoint, e.g. for LocalStack; the queue URL's host when empty
+	Region      string        // Taken from the queue URL's host when empty
+	WaitTime    time.Duration // Long poll per receive, at most 20s
+	MaxMessages int           // Messages per receive, at most 10
+	Credentials Credentials   // Requests are unsigned without an access key
+}
+
+// Message is a received SQS message
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+	ReceiveCount  int
+}
+
+// Client calls the SQS JSON API for one queue
+type Client struct {
+	config     Config
+	endpoint   string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewClient creates a client for config.QueueURL
+func NewClient(config Config, logger *zap.Logger) (*Client, error) {
+	u, err := url.Parse(config.QueueURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid SQS queue URL %q", config.QueueURL)
+	}
+
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = u.Scheme + "://" + u.Host
+	}
+	if config.Region == "" {
+		// Queue hosts look like sqs.us-east-1.amazonaws.com
+		labels := strings.Split(u.Hostname(), ".")
+		if len(labels) < 3 || labels[0] != "sqs" {
+			return nil, fmt.Errorf("cannot tell the region of SQS queue %q; set it explicitly", config.QueueURL)
+		}
+		config.Region = labels[1]
+	}
+	config.WaitTime = min(max(config.WaitTime, 0), 20*time.Second)
+	if config.MaxMessages < 1 || config.MaxMessages > 10 {
+		config.MaxMessages = 10
+	}
+
+	return &Client{
+		config:   config,
+		endpoint: endpoint,
+		// Long polls hold the request open for up to WaitTime
+		httpClient: &http.Client{Timeout: config.WaitTime + 30*time.Second},
+		logger:     logger,
+	}, nil
+}
+
+// Receive long-polls the queue for messages. Received messages stay hidden
+// from other consumers for the queue's visibility timeout.
+func (c *Client) Receive(ctx context.Context) ([]Message, error) {
+	var out struct {
+		Messages []struct {
+			MessageID     string            `json:"MessageId"`
+			ReceiptHandle string            `json:"ReceiptHandle"`
+			Body          string            `json:"Body"`
+			Attributes    map[string]string `json:"Attributes"`
+		} `json:"Messages"`
+	}
+	err := c.call(ctx, "ReceiveMessage", map[string]any{
+		"QueueUrl":            c.config.QueueURL,
+		"MaxNumberOfMessages": c.config.MaxMessages,
+		"WaitTimeSeconds":     int(c.config.WaitTime / time.Second),
+		"AttributeNames":      []string{"ApproximateReceiveCount"},
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(out.Messages))
+	for i, m := range out.Messages {
+		count, _ := strconv.Atoi(m.Attributes["ApproximateReceiveCount"])
+		messages[i] = Message{
+			ID:            m.MessageID,
+			ReceiptHandle: m.ReceiptHandle,
+			Body:          m.Body,
+			ReceiveCount:  max(count, 1),
+		}
+	}
+	return messages, nil
+}
+
+// Delete removes a handled message from the queue
+func (c *Client) Delete(ctx context.Context, receiptHandle string) error {
+	return c.call(ctx, "DeleteMessage", map[string]any{
+		"QueueUrl":      c.config.QueueURL,
+		"ReceiptHandle": receiptHandle,
+	}, nil)
+}
+
+// ChangeVisibility makes a received message visible again after timeout
+func (c *Client) ChangeVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	timeout = min(max(timeout, 0), maxVisibilityTimeout)
+	return c.call(ctx, "ChangeMessageVisibility", map[string]any{
+		"QueueUrl":          c.config.QueueURL,
+		"ReceiptHandle":     receiptHandle,
+		"VisibilityTimeout": int(timeout / time.Second),
+	}, nil)
+}
+
+// call sends one action of the SQS JSON protocol, decoding the response into
+// out if set
+func (c *Client) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", action, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	if c.config.Credentials.AccessKeyID != "" {
+		signV4(req, body, c.config.Credentials, c.config.Region, "sqs", time.Now())
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("SQS %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Type != "" {
+			return fmt.Errorf("SQS %s failed with status %d: %s: %s", action, resp.StatusCode, apiErr.Type, apiErr.Message)
+		}
+		return fmt.Errorf("SQS %s failed with status %d", action, resp.StatusCode)
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode SQS %s response: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+)
+
+// S3EventSource reads S3 ObjectCreated event notifications, sent to the queue
+// directly or through SNS, as object events
+type S3EventSource struct {
+	client *Client
+	logger *zap.Logger
+}
+
+// NewS3EventSource creates an event source reading from client's queue
+func NewS3EventSource(client *Client, logger *zap.Logger) *S3EventSource {
+	return &S3EventSource{client: client, logger: logger}
+}
+
+func (s *S3EventSource) Receive(ctx context.Context) ([]domain.ObjectNotification, error) {
+	messages, err := s.client.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]domain.ObjectNotification, len(messages))
+	for i, m := range messages {
+		events, err := parseS3Events(m.Body)
+		if err != nil {
+			// A notification without events is acknowledged, so an unreadable
+			// message is dropped instead of redelivered forever
+			s.logger.Warn("Dropping unreadable S3 event notification",
+				zap.String("message_id", m.ID),
+				zap.Error(err))
+		}
+		notifications[i] = domain.ObjectNotification{
+			ID:       m.ID,
+			Receipt:  m.ReceiptHandle,
+			Receives: m.ReceiveCount,
+			Events:   events,
+		}
+	}
+	return notifications, nil
+}
+
+func (s *S3EventSource) Ack(ctx context.Context, n domain.ObjectNotification) error {
+	return s.client.Delete(ctx, n.Receipt)
+}
+
+func (s *S3EventSource) Retry(ctx context.Context, n domain.ObjectNotification, delay time.Duration) error {
+	return s.client.ChangeVisibility(ctx, n.Receipt, delay)
+}
+
+// s3Notification is an S3 event notification, or an SNS message wrapping one
+type s3Notification struct {
+	Records []struct {
+		EventName string    `json:"eventName"`
+		EventTime time.Time `json:"eventTime"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+				ETag string `json:"eTag"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// SNS envelope
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// parseS3Events returns the ObjectCreated events of a notification. Other
+// events, and the s3:TestEvent sent when notifications are configured, yield
+// none.
+func parseS3Events(body string) ([]domain.ObjectEvent, error) {
+	var n s3Notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, fmt.Errorf("invalid S3 event notification: %w", err)
+	}
+	if n.Type == "Notification" && n.Message != "" {
+		if err := json.Unmarshal([]byte(n.Message), &n); err != nil {
+			return nil, fmt.Errorf("invalid S3 event notification in SNS message: %w", err)
+		}
+	}
+
+	var events []domain.ObjectEvent
+	for _, record := range n.Records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+		// Keys arrive URL-encoded, with spaces as '+'
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key %q: %w", record.S3.Object.Key, err)
+		}
+		bucket := record.S3.Bucket.Name
+		events = append(events, domain.ObjectEvent{
+			Location:  bucket,
+			Key:       key,
+			SourceURL: (&url.URL{Scheme: "s3", Host: bucket, Path: "/" + key}).String(),
+			Size:      record.S3.Object.Size,
+			ETag:      record.S3.Object.ETag,
+			Time:      record.EventTime,
+		})
+	}
+	return events, nil
+}
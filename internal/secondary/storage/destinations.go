@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/internal/secondary/s3"
+)
+
+// writeCheckName prefixes the probe files written to check destinations
+const writeCheckName = ".ee-write-check-"
+
+// Destinations opens the output destinations jobs name: prefixes of S3
+// buckets reached with one client, and directories inside the working
+// storage
+type Destinations struct {
+	client  *s3.S3Client
+	baseDir string
+	minFree int64
+}
+
+func NewDestinations(client *s3.S3Client, baseDir string) *Destinations {
+	return &Destinations{client: client, baseDir: baseDir}
+}
+
+// SetMinFree keeps bytes free on the disk of local destinations, like
+// LocalStorage.SetMinFree
+func (d *Destinations) SetMinFree(bytes int64) {
+	d.minFree = bytes
+}
+
+func (d *Destinations) Storage(destination domain.OutputDestination) (ports.FileStorage, error) {
+	if err := destination.Validate(); err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(destination.Prefix, "/")
+	if destination.Storage == domain.DestinationS3 {
+		return s3.NewStorage(d.client, destination.Bucket, prefix), nil
+	}
+	local, err := NewLocalStorage(filepath.Join(d.baseDir, filepath.FromSlash(path.Clean("/"+prefix))))
+	if err != nil {
+		return nil, err
+	}
+	local.SetMinFree(d.minFree)
+	return local, nil
+}
+
+// CheckWritable writes an empty probe file to the destination and deletes it
+func (d *Destinations) CheckWritable(ctx context.Context, destination domain.OutputDestination) error {
+	probe := writeCheckName + uuid.New().String()
+	if destination.Storage == domain.DestinationS3 {
+		key := strings.TrimPrefix(path.Join(strings.Trim(destination.Prefix, "/"), probe), "/")
+		if err := d.client.UploadFile(ctx, destination.Bucket, key, strings.NewReader("")); err != nil {
+			return err
+		}
+		if err := d.client.DeleteFile(ctx, destination.Bucket, key); err != nil {
+			return fmt.Errorf("failed to delete write check: %w", err)
+		}
+		return nil
+	}
+
+	storage, err := d.Storage(destination)
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(probe, strings.NewReader("")); err != nil {
+		return err
+	}
+	if err := storage.DeleteFile(probe); err != nil {
+		return fmt.Errorf("failed to delete write check: %w", err)
+	}
+	return nil
+}
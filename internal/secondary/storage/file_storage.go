@@ -1,77 +0,0 @@
-// storage/file_storage.go
-package storage
-
-import (
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"time"
-	"strings"
-
-	"go.uber.org/zap"
-)
-
-// FileStorage handles local file operations
-type FileStorage struct {
-	baseDir string
-	logger  *zap.Logger
-}
-
-// NewFileStorage creates a new FileStorage instance
-func NewFileStorage(baseDir string, logger *zap.Logger) (*FileStorage, error) {
-	// Create base directory if it doesn't exist
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create base directory: %w", err)
-	}
-
-	return &FileStorage{
-		baseDir: baseDir,
-		logger:  logger,
-	}, nil
-}
-
-// WriteFile simulates writing a file to storage
-func (s *FileStorage) WriteFile(path string, content io.Reader) error {
-	s.logger.Info("Simulating file write",
-		zap.String("path", path),
-		zap.String("operation", "write"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return nil
-}
-
-// ReadFile simulates reading a file from storage
-func (s *FileStorage) ReadFile(path string) (io.ReadCloser, error) {
-	s.logger.Info("Simulating file read",
-		zap.String("path", path),
-		zap.String("operation", "read"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return io.NopCloser(strings.NewReader("simulated file content")), nil
-}
-
-// DeleteFile simulates deleting a file from storage
-func (s *FileStorage) DeleteFile(path string) error {
-	s.logger.Info("Simulating file deletion",
-		zap.String("path", path),
-		zap.String("operation", "delete"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return nil
-}
-
-// FileExists simulates checking if a file exists
-func (s *FileStorage) FileExists(path string) bool {
-	s.logger.Info("Simulating file existence check",
-		zap.String("path", path),
-		zap.String("operation", "exists"),
-		zap.String("timestamp", time.Now().String()),
-	)
-	return true
-}
-
-// GetFullPath returns the full path for a given file
-func (s *FileStorage) GetFullPath(path string) string {
-	return filepath.Join(s.baseDir, path)
-}
\ No newline at end of file
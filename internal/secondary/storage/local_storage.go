@@ -1,14 +1,21 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"E.E/pkg/diskspace"
 )
 
 type LocalStorage struct {
 	baseDir string
+	minFree int64 // Bytes writes leave free on the disk; 0 does not check
 }
 
 func NewLocalStorage(baseDir string) (*LocalStorage, error) {
@@ -22,6 +29,13 @@ func NewLocalStorage(baseDir string) (*LocalStorage, error) {
 	}, nil
 }
 
+// SetMinFree refuses writes, and fails health checks, while fewer than bytes
+// are free on the disk holding the storage. Writes in progress stop once they
+// bring it below bytes.
+func (s *LocalStorage) SetMinFree(bytes int64) {
+	s.minFree = bytes
+}
+
 func (s *LocalStorage) ReadFile(path string) (io.ReadCloser, error) {
 	fullPath := filepath.Join(s.baseDir, path)
 	file, err := os.Open(fullPath)
@@ -31,6 +45,9 @@ func (s *LocalStorage) ReadFile(path string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// WriteFile writes content to path atomically: it is copied to a temporary
+// file next to the destination and renamed into place only once complete, so
+// a failed or interrupted stream never leaves a partial file behind
 func (s *LocalStorage) WriteFile(path string, content io.Reader) error {
 	fullPath := filepath.Join(s.baseDir, path)
 
@@ -39,21 +56,46 @@ func (s *LocalStorage) WriteFile(path string, content io.Reader) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
+	if err := diskspace.Check(dir, s.minFree); err != nil {
+		return err
+	}
 
-	file, err := os.Create(fullPath)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+"-*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
-	_, err = io.Copy(file, content)
-	if err != nil {
+	var dst io.Writer = file
+	if s.minFree > 0 {
+		dst = diskspace.NewWriter(file, dir, s.minFree)
+	}
+	if _, err := io.Copy(dst, content); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := file.Close(); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	if err := os.Rename(file.Name(), fullPath); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
 
 	return nil
 }
 
+// URL returns a file:// URL for the stored file
+func (s *LocalStorage) URL(path string) string {
+	fullPath := filepath.Join(s.baseDir, path)
+	if abs, err := filepath.Abs(fullPath); err == nil {
+		fullPath = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(fullPath)}).String()
+}
+
 func (s *LocalStorage) DeleteFile(path string) error {
 	fullPath := filepath.Join(s.baseDir, path)
 	err := os.Remove(fullPath)
@@ -67,4 +109,59 @@ func (s *LocalStorage) FileExists(path string) bool {
 	fullPath := filepath.Join(s.baseDir, path)
 	_, err := os.Stat(fullPath)
 	return err == nil
-}
\ No newline at end of file
+}
+// ListObjects walks directory (relative to the base directory) and returns a
+// file:// URL for every regular file whose relative path starts with prefix.
+// Directories outside the base directory are refused.
+func (s *LocalStorage) ListObjects(ctx context.Context, directory, prefix string) ([]string, error) {
+	absBase, err := filepath.Abs(s.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve base directory: %w", err)
+	}
+	absRoot := filepath.Join(absBase, directory)
+	rel, err := filepath.Rel(absBase, absRoot)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("directory %s is outside the storage root", directory)
+	}
+
+	var urls []string
+	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return err
+		}
+		if prefix != "" && !strings.HasPrefix(filepath.ToSlash(rel), prefix) {
+			return nil
+		}
+		urls = append(urls, "file://"+filepath.ToSlash(path))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	return urls, nil
+}
+
+// HealthCheck verifies that the base directory is writable and has the free
+// space writes need
+func (s *LocalStorage) HealthCheck(ctx context.Context) error {
+	if err := diskspace.Check(s.baseDir, s.minFree); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(s.baseDir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("storage is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListObjectsStaysInsideBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "outside.mp4"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewLocalStorage(filepath.Join(dir, "base"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteFile("media/inside.mp4", strings.NewReader("inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, directory := range []string{"..", "../", "media/../..", "../base/../.."} {
+		if urls, err := s.ListObjects(context.Background(), directory, ""); err == nil {
+			t.Errorf("ListObjects(%q) = %v, want an error", directory, urls)
+		}
+	}
+
+	for _, directory := range []string{"media", "media/../media", "/media", ""} {
+		urls, err := s.ListObjects(context.Background(), directory, "")
+		if err != nil {
+			t.Fatalf("ListObjects(%q): %v", directory, err)
+		}
+		if len(urls) != 1 || filepath.Base(urls[0]) != "inside.mp4" {
+			t.Errorf("ListObjects(%q) = %v, want only inside.mp4", directory, urls)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"E.E/internal/core/domain"
+	"E.E/pkg/diskspace"
+)
+
+// Workspaces keeps a directory per running job inside one base directory,
+// refusing new ones once together they use their quota
+type Workspaces struct {
+	baseDir  string
+	maxBytes int64 // Quota of all workspaces; 0 is unlimited
+
+	mu   sync.Mutex
+	held map[string]struct{} // Jobs whose workspace this process acquired
+}
+
+func NewWorkspaces(baseDir string, maxBytes int64) (*Workspaces, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	return &Workspaces{
+		baseDir:  baseDir,
+		maxBytes: maxBytes,
+		held:     make(map[string]struct{}),
+	}, nil
+}
+
+func (w *Workspaces) Acquire(jobID string) (string, error) {
+	path, err := w.path(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Already acquired for this run, so the files in it are still in use
+	if _, ok := w.held[jobID]; ok {
+		return path, nil
+	}
+	// Left by an earlier run of the job that did not finish
+	if err := os.RemoveAll(path); err != nil {
+		return "", fmt.Errorf("failed to clear workspace of job %s: %w", jobID, err)
+	}
+	if w.maxBytes > 0 {
+		used, _, err := measure(w.baseDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to measure workspaces: %w", err)
+		}
+		if used >= w.maxBytes {
+			return "", fmt.Errorf("%w: job workspaces use %d of their %d bytes", diskspace.ErrLow, used, w.maxBytes)
+		}
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create workspace of job %s: %w", jobID, err)
+	}
+	w.held[jobID] = struct{}{}
+	return path, nil
+}
+
+func (w *Workspaces) Release(jobID string) error {
+	path, err := w.path(jobID)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	delete(w.held, jobID)
+	w.mu.Unlock()
+
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove workspace of job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+func (w *Workspaces) List() ([]domain.Workspace, error) {
+	entries, err := os.ReadDir(w.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	workspaces := make([]domain.Workspace, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		workspace := domain.Workspace{JobID: entry.Name()}
+		_, workspace.Held = w.held[entry.Name()]
+		workspace.Bytes, workspace.UpdatedAt, err = measure(filepath.Join(w.baseDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure workspace of job %s: %w", entry.Name(), err)
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, nil
+}
+
+// path returns the workspace directory of the job, refusing IDs that are not
+// a single path element
+func (w *Workspaces) path(jobID string) (string, error) {
+	if jobID == "" || jobID == "." || jobID == ".." || strings.ContainsAny(jobID, `/\`) {
+		return "", fmt.Errorf("invalid workspace job ID %q", jobID)
+	}
+	return filepath.Join(w.baseDir, jobID), nil
+}
+
+// measure returns the size of the files under dir and when the last of them
+// changed. Files removed while it walks are skipped.
+func measure(dir string) (int64, time.Time, error) {
+	var (
+		size    int64
+		updated time.Time
+	)
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(updated) {
+			updated = info.ModTime()
+		}
+		return nil
+	})
+	return size, updated, err
+}
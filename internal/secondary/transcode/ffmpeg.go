@@ -0,0 +1,468 @@
+// Package transcode converts sources with ffmpeg before they are encrypted
+package transcode
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"E.E/internal/core/domain"
+	"E.E/internal/core/ports"
+	"E.E/pkg/diskspace"
+)
+
+// ffmpegEncryptionSchemes are ffmpeg's names of the DRM schemes its MP4
+// muxer protects renditions with
+var ffmpegEncryptionSchemes = map[string]string{
+	domain.DRMSchemeCENC: "cenc-aes-ctr",
+}
+
+// MasterPlaylist is the name of the HLS master playlist in a packaged output
+const MasterPlaylist = "master.m3u8"
+
+// Config configures the ffmpeg transcoder
+type Config struct {
+	Path       string        // ffmpeg binary, looked up in PATH if it has no directory
+	ScratchDir string        // Where sources and renditions are written while a job runs without a workspace; empty uses the system temp dir
+	MinFree    int64         // Bytes left free in ScratchDir; sources are not downloaded past it
+	Timeout    time.Duration // Time allowed to transcode one source
+	VideoCodec string        // e.g. libx264, unless a job names its own
+	AudioCodec string        // e.g. aac, unless a job names its own
+	Preset     string        // Speed preset of VideoCodec, e.g. veryfast; empty uses the encoder's default
+}
+
+// FFmpeg transcodes sources with the ffmpeg binary. Sources are downloaded
+// with the job source fetcher into a scratch directory first, since MP4
+// sources can only be read seeking.
+type FFmpeg struct {
+	config  Config
+	fetcher ports.SourceFetcher
+	logger  *zap.Logger
+}
+
+// NewFFmpeg creates a transcoder running the ffmpeg binary at config.Path
+func NewFFmpeg(config Config, fetcher ports.SourceFetcher, logger *zap.Logger) (*FFmpeg, error) {
+	resolved, err := exec.LookPath(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	config.Path = resolved
+
+	if config.ScratchDir == "" {
+		config.ScratchDir = os.TempDir()
+	}
+	if err := os.MkdirAll(config.ScratchDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create transcode scratch dir: %w", err)
+	}
+
+	return &FFmpeg{config: config, fetcher: fetcher, logger: logger}, nil
+}
+
+func (f *FFmpeg) Transcode(ctx context.Context, workspace, sourceURL string, params domain.TranscodeParams, key *domain.DRMKey, progress func(float64)) (io.ReadCloser, int64, error) {
+	if params.DRM != "" {
+		if _, ok := ffmpegEncryptionSchemes[params.DRM]; !ok || key == nil {
+			return nil, 0, fmt.Errorf("%w: cannot protect renditions with %q", domain.ErrTranscodeFailed, params.DRM)
+		}
+	}
+	if f.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.config.Timeout)
+		defer cancel()
+	}
+
+	if workspace == "" {
+		workspace = f.config.ScratchDir
+	}
+	if err := diskspace.Check(workspace, f.config.MinFree); err != nil {
+		return nil, 0, err
+	}
+	dir, err := os.MkdirTemp(workspace, "transcode-")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create transcode scratch dir: %w", err)
+	}
+	// The scratch dir is handed to the returned reader on success
+	ok := false
+	defer func() {
+		if !ok {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	// Sources a worker already processed in the workspace are read in place
+	source, local := localSource(sourceURL, workspace)
+	if !local {
+		source = filepath.Join(dir, "source")
+		if err := f.download(ctx, sourceURL, source); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0o755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create transcode output dir: %w", err)
+	}
+	if err := f.run(ctx, source, out, params, key, progress); err != nil {
+		return nil, 0, err
+	}
+
+	var packaged string
+	if params.Packaged() {
+		if params.Format == domain.TranscodeHLS {
+			if err := writeMasterPlaylist(out, params); err != nil {
+				return nil, 0, err
+			}
+		}
+		packaged = filepath.Join(dir, "package.tar")
+		if err := pack(out, packaged); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		packaged = filepath.Join(out, params.Renditions[0].Name+".mp4")
+	}
+
+	file, err := os.Open(packaged)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open transcoded output: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to open transcoded output: %w", err)
+	}
+
+	ok = true
+	return &scratchFile{File: file, dir: dir}, info.Size(), nil
+}
+
+// download copies the source into path
+func (f *FFmpeg) download(ctx context.Context, sourceURL, path string) error {
+	src, _, err := f.fetcher.Open(ctx, sourceURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create transcode source file: %w", err)
+	}
+	var w io.Writer = dst
+	if f.config.MinFree > 0 {
+		w = diskspace.NewWriter(dst, filepath.Dir(path), f.config.MinFree)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to download source for transcoding: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write transcode source file: %w", err)
+	}
+	return nil
+}
+
+// localSource returns the path of a file:// source inside dir
+func localSource(sourceURL, dir string) (string, bool) {
+	path, ok := strings.CutPrefix(sourceURL, "file://")
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Clean(path), true
+}
+
+// durationPattern matches the input duration ffmpeg logs before it starts
+var durationPattern = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+// run transcodes source into a file or directory per rendition under out
+func (f *FFmpeg) run(ctx context.Context, source, out string, params domain.TranscodeParams, key *domain.DRMKey, progress func(float64)) error {
+	for _, r := range params.Renditions {
+		if params.Format == domain.TranscodeHLS {
+			if err := os.Mkdir(filepath.Join(out, r.Name), 0o755); err != nil {
+				return fmt.Errorf("failed to create rendition dir: %w", err)
+			}
+		}
+	}
+
+	return f.execute(ctx, "", f.args(source, out, params, key), domain.ErrTranscodeFailed, progress)
+}
+
+// execute runs ffmpeg with args in dir, or the current directory when dir is
+// empty, reporting progress from its -progress output. Errors of ffmpeg
+// itself wrap failure.
+func (f *FFmpeg) execute(ctx context.Context, dir string, args []string, failure error, progress func(float64)) error {
+	cmd := exec.CommandContext(ctx, f.config.Path, args...)
+	cmd.Dir = dir
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+
+	// stderr carries the input's duration and, on failure, the reason
+	durations := make(chan time.Duration, 1)
+	logged := make(chan []string, 1)
+	go func() {
+		var lines []string
+		found := false
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !found {
+				if m := durationPattern.FindStringSubmatch(line); m != nil {
+					found = true
+					durations <- parseDuration(m[1], m[2], m[3])
+				}
+			}
+			if line != "" {
+				lines = append(lines, line)
+				if len(lines) > 5 {
+					lines = lines[1:]
+				}
+			}
+		}
+		logged <- lines
+	}()
+
+	var total time.Duration
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		if key != "out_time_us" {
+			continue
+		}
+		if total == 0 {
+			select {
+			case total = <-durations:
+			default:
+			}
+		}
+		done, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || total <= 0 || done < 0 {
+			continue
+		}
+		progress(min(float64(time.Duration(done)*time.Microsecond)/float64(total), 1))
+	}
+	lines := <-logged
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", failure, ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			reason := "ffmpeg could not process the source"
+			if len(lines) > 0 {
+				reason = lines[len(lines)-1]
+			}
+			return fmt.Errorf("%w: %s", failure, reason)
+		}
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// args builds the ffmpeg command line producing every rendition in one pass
+// over the source. Protected renditions are fragmented, as CENC players
+// expect, and all share key.
+func (f *FFmpeg) args(source, out string, params domain.TranscodeParams, key *domain.DRMKey) []string {
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-i", source}
+	videoCodec, audioCodec, preset := f.config.VideoCodec, f.config.AudioCodec, f.config.Preset
+	if params.VideoCodec != "" && params.VideoCodec != videoCodec {
+		// Presets are specific to the encoder they were configured for
+		videoCodec, preset = params.VideoCodec, ""
+	}
+	if params.AudioCodec != "" {
+		audioCodec = params.AudioCodec
+	}
+	for _, r := range params.Renditions {
+		// The first video and audio streams, whichever the source has
+		args = append(args, "-map", "0:v:0?", "-map", "0:a:0?")
+		if r.Height != 0 {
+			args = append(args, "-vf", fmt.Sprintf("scale=-2:%d", r.Height))
+		}
+		args = append(args, "-c:v", videoCodec)
+		if preset != "" {
+			args = append(args, "-preset", preset)
+		}
+		if r.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.FormatInt(r.VideoBitrate, 10))
+		}
+		args = append(args, "-c:a", audioCodec)
+		if r.AudioBitrate > 0 {
+			args = append(args, "-b:a", strconv.FormatInt(r.AudioBitrate, 10))
+		}
+
+		switch params.Format {
+		case domain.TranscodeHLS:
+			dir := filepath.Join(out, r.Name)
+			// Keyframes on segment boundaries, so every segment starts cleanly
+			args = append(args,
+				"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", params.SegmentSeconds),
+				"-f", "hls",
+				"-hls_time", strconv.Itoa(params.SegmentSeconds),
+				"-hls_playlist_type", "vod",
+				"-hls_segment_filename", filepath.Join(dir, "segment_%05d.ts"),
+				filepath.Join(dir, "index.m3u8"))
+		default:
+			if params.DRM != "" {
+				args = append(args,
+					"-movflags", "+frag_keyframe+empty_moov+default_base_moof",
+					"-encryption_scheme", ffmpegEncryptionSchemes[params.DRM],
+					"-encryption_key", hex.EncodeToString(key.Key),
+					"-encryption_kid", hex.EncodeToString(key.KeyID))
+			} else {
+				args = append(args, "-movflags", "+faststart")
+			}
+			args = append(args, "-f", "mp4", filepath.Join(out, r.Name+".mp4"))
+		}
+	}
+	return args
+}
+
+func parseDuration(hours, minutes, seconds string) time.Duration {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.ParseFloat(seconds, 64)
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+}
+
+// writeMasterPlaylist writes the HLS master playlist listing every rendition.
+// Bandwidths are measured from the segments, since renditions encoded by
+// quality have no fixed bitrate.
+func writeMasterPlaylist(out string, params domain.TranscodeParams) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range params.Renditions {
+		bandwidth, err := measureBandwidth(filepath.Join(out, r.Name, "index.m3u8"))
+		if err != nil {
+			return err
+		}
+		if bandwidth == 0 {
+			bandwidth = r.VideoBitrate + r.AudioBitrate
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,NAME=%q\n%s/index.m3u8\n", bandwidth, r.Name, r.Name)
+	}
+	if err := os.WriteFile(filepath.Join(out, MasterPlaylist), []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write HLS master playlist: %w", err)
+	}
+	return nil
+}
+
+// measureBandwidth returns the highest bits per second of a rendition's
+// segments, as listed in its media playlist
+func measureBandwidth(playlist string) (int64, error) {
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		return 0, fmt.Errorf("%w: missing rendition playlist: %w", domain.ErrTranscodeFailed, err)
+	}
+
+	var peak int64
+	var seconds float64
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, _ = strconv.ParseFloat(value, 64)
+		case line != "" && !strings.HasPrefix(line, "#") && seconds > 0:
+			info, err := os.Stat(filepath.Join(filepath.Dir(playlist), line))
+			if err == nil {
+				peak = max(peak, int64(float64(info.Size()*8)/seconds))
+			}
+			seconds = 0
+		}
+	}
+	return peak, nil
+}
+
+// pack writes the renditions under out to a tar archive at path
+func pack(out, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create transcode package: %w", err)
+	}
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	err = filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == out {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(out, p)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to package renditions: %w", err)
+	}
+	return nil
+}
+
+// scratchFile is the transcoded output; closing it removes the scratch dir
+type scratchFile struct {
+	*os.File
+	dir string
+}
+
+func (f *scratchFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.RemoveAll(f.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
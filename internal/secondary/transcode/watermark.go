@@ -0,0 +1,138 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"E.E/internal/core/domain"
+)
+
+// Where watermarks are placed, in pixels from the edges of the video
+const watermarkMargin = 24
+
+// forensicPeriod is how many seconds a forensic watermark stays in each
+// corner before it moves to the next
+const forensicPeriod = 10
+
+// Watermarker overlays jobs' watermarks on their sources with ffmpeg. It is a
+// source processor, run by workers before the source is transcoded or
+// encrypted. The video is encoded again with the transcoder's video codec
+// into an MP4; audio is copied as it is.
+type Watermarker struct {
+	ffmpeg *FFmpeg
+	font   string // Font file of text watermarks; empty uses ffmpeg's default
+}
+
+// NewWatermarker creates a watermarker running ffmpeg as the transcoder does,
+// drawing text watermarks with the font file at font
+func NewWatermarker(ffmpeg *FFmpeg, font string) (*Watermarker, error) {
+	if font != "" {
+		if _, err := os.Stat(font); err != nil {
+			return nil, fmt.Errorf("watermark font not found: %w", err)
+		}
+	}
+	return &Watermarker{ffmpeg: ffmpeg, font: font}, nil
+}
+
+func (w *Watermarker) Stage() domain.ProgressStage {
+	return domain.StageWatermarking
+}
+
+func (w *Watermarker) Applies(job *domain.EncryptionJob) bool {
+	return job.Watermark != nil
+}
+
+func (w *Watermarker) Process(ctx context.Context, job *domain.EncryptionJob, input, output string, progress func(float64)) error {
+	if job.Media != nil && job.Media.VideoCodec == "" {
+		return fmt.Errorf("%w: the source has no video to watermark", domain.ErrWatermarkFailed)
+	}
+	if w.ffmpeg.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.ffmpeg.config.Timeout)
+		defer cancel()
+	}
+
+	// ffmpeg runs in the output's directory, so the filter names its files
+	// without paths that would need escaping
+	dir := filepath.Dir(output)
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-i", input}
+	var filter string
+	if job.Watermark.Text != "" {
+		text := filepath.Join(dir, "watermark.txt")
+		if err := os.WriteFile(text, []byte(job.Watermark.Render(job.ID)), 0o644); err != nil {
+			return fmt.Errorf("failed to write watermark text: %w", err)
+		}
+		defer os.Remove(text)
+		filter = w.textFilter(job.Watermark, filepath.Base(text))
+	} else {
+		image := filepath.Join(dir, "watermark.png")
+		if err := w.ffmpeg.download(ctx, job.Watermark.ImageURL, image); err != nil {
+			return fmt.Errorf("%w: %w", domain.ErrWatermarkFailed, err)
+		}
+		defer os.Remove(image)
+		args = append(args, "-i", filepath.Base(image))
+		filter = imageFilter(job.Watermark)
+	}
+
+	args = append(args,
+		"-filter_complex", filter,
+		"-map", "[marked]", "-map", "0:a?",
+		"-c:v", w.ffmpeg.config.VideoCodec)
+	if w.ffmpeg.config.Preset != "" {
+		args = append(args, "-preset", w.ffmpeg.config.Preset)
+	}
+	args = append(args, "-c:a", "copy", "-movflags", "+faststart", "-f", "mp4", output)
+
+	return w.ffmpeg.execute(ctx, dir, args, domain.ErrWatermarkFailed, progress)
+}
+
+// textFilter draws the text in file over the first video stream
+func (w *Watermarker) textFilter(wm *domain.Watermark, file string) string {
+	x, y := placement(wm, "w", "h", "tw", "th")
+	opacity := strconv.FormatFloat(wm.Opacity, 'f', 2, 64)
+	filter := fmt.Sprintf("[0:v:0]drawtext=textfile=%s:expansion=none:fontsize=%d:fontcolor=white@%s:borderw=1:bordercolor=black@%s:x='%s':y='%s'",
+		file, wm.Size, opacity, opacity, x, y)
+	if w.font != "" {
+		filter += fmt.Sprintf(":fontfile='%s'", w.font)
+	}
+	return filter + "[marked]"
+}
+
+// imageFilter overlays the second input, scaled to the watermark's size and
+// faded to its opacity, on the first video stream
+func imageFilter(wm *domain.Watermark) string {
+	x, y := placement(wm, "W", "H", "w", "h")
+	return fmt.Sprintf("[1:v:0]format=rgba,colorchannelmixer=aa=%s,scale=-2:%d[overlay];[0:v:0][overlay]overlay=x='%s':y='%s'[marked]",
+		strconv.FormatFloat(wm.Opacity, 'f', 2, 64), wm.Size, x, y)
+}
+
+// placement returns the x and y expressions of a watermark, given the
+// filter's names for the width and height of the video and the watermark.
+// Forensic watermarks move clockwise from corner to corner.
+func placement(wm *domain.Watermark, videoW, videoH, markW, markH string) (string, string) {
+	left, top := strconv.Itoa(watermarkMargin), strconv.Itoa(watermarkMargin)
+	right := fmt.Sprintf("%s-%s-%d", videoW, markW, watermarkMargin)
+	bottom := fmt.Sprintf("%s-%s-%d", videoH, markH, watermarkMargin)
+
+	switch wm.Position {
+	case domain.WatermarkTopLeft:
+		return left, top
+	case domain.WatermarkTopRight:
+		return right, top
+	case domain.WatermarkBottomLeft:
+		return left, bottom
+	case domain.WatermarkCenter:
+		return fmt.Sprintf("(%s-%s)/2", videoW, markW), fmt.Sprintf("(%s-%s)/2", videoH, markH)
+	case domain.WatermarkBottomRight:
+		return right, bottom
+	}
+
+	// Top left, top right, bottom right, bottom left, then around again
+	phase := fmt.Sprintf("mod(t,%d)", 4*forensicPeriod)
+	x := fmt.Sprintf("if(between(%s,%d,%d),%s,%s)", phase, forensicPeriod, 3*forensicPeriod, right, left)
+	y := fmt.Sprintf("if(lt(%s,%d),%s,%s)", phase, 2*forensicPeriod, top, bottom)
+	return x, y
+}
@@ -0,0 +1,1536 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run modes select which parts of the service a process runs
+const (
+	ModeAPI    = "api"    // HTTP API only; jobs are queued for separate workers
+	ModeWorker = "worker" // Encryption workers only
+	ModeAll    = "all"    // HTTP API and workers in one process
+)
+
+// Rate limiter backends
+const (
+	RateLimitMemory = "memory" // Per-process buckets
+	RateLimitRedis  = "redis"  // Sliding window shared between processes
+)
+
+// Queue backends
+const (
+	QueueMemory = "memory" // In-process queue, only usable in mode all
+	QueueRedis  = "redis"  // Redis list shared between processes
+)
+
+// Worker backends
+const (
+	WorkerLocal      = "local"      // Jobs are encrypted in process
+	WorkerKubernetes = "kubernetes" // A Kubernetes Job is launched per job
+)
+
+// Recovery policies for jobs whose worker was lost
+const (
+	RecoveryRequeue = "requeue" // Start the jobs over
+	RecoveryFail    = "fail"    // Fail the jobs as worker_lost
+	RecoveryNone    = "none"    // Leave the jobs as they are
+)
+
+// Key store backends
+const (
+	KeyStoreInline = "inline" // Keys are kept in the clear on the job
+	KeyStoreKMS    = "kms"    // Keys are sealed with AWS KMS
+	KeyStoreVault  = "vault"  // Keys are sealed with the Vault transit engine
+)
+
+// Config is the complete service configuration. Values are resolved in order
+// of precedence: defaults, config file, environment variables, then flags.
+type Config struct {
+	Mode        string            `yaml:"mode" toml:"mode" usage:"run mode: api, worker or all"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Storage     StorageConfig     `yaml:"storage" toml:"storage"`
+	S3          S3Config          `yaml:"s3" toml:"s3"`
+	GCS         GCSConfig         `yaml:"gcs" toml:"gcs"`
+	Redis       RedisConfig       `yaml:"redis" toml:"redis"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	CORS        CORSConfig        `yaml:"cors" toml:"cors"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	Quotas      QuotaConfig       `yaml:"quotas" toml:"quotas"`
+	Worker      WorkerConfig      `yaml:"worker" toml:"worker"`
+	HTTPClient  HTTPClientConfig  `yaml:"http_client" toml:"http_client"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" toml:"webhooks"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Export      ExportConfig      `yaml:"export" toml:"export"`
+	Health      HealthConfig      `yaml:"health" toml:"health"`
+	Service     ServiceConfig     `yaml:"service" toml:"service"`
+	Media       MediaConfig       `yaml:"media" toml:"media"`
+	Preflight   PreflightConfig   `yaml:"preflight" toml:"preflight"`
+	Uploads     UploadsConfig     `yaml:"uploads" toml:"uploads"`
+	Destination DestinationConfig `yaml:"destination" toml:"destination"`
+	Engine      EngineConfig      `yaml:"engine" toml:"engine"`
+	Ingest      IngestConfig      `yaml:"ingest" toml:"ingest"`
+	Kubernetes  KubernetesConfig  `yaml:"kubernetes" toml:"kubernetes"`
+	Keys        KeysConfig        `yaml:"keys" toml:"keys"`
+	KeyStore    KeyStoreConfig    `yaml:"key_store" toml:"key_store"`
+	Share       ShareConfig       `yaml:"share" toml:"share"`
+	Recurring   RecurringConfig   `yaml:"recurring" toml:"recurring"`
+	Replication ReplicationConfig `yaml:"replication" toml:"replication"`
+	Pushgateway PushgatewayConfig `yaml:"pushgateway" toml:"pushgateway"`
+	Chaos       ChaosConfig       `yaml:"chaos" toml:"chaos"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port            int      `yaml:"port" toml:"port" usage:"HTTP listen port"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout" usage:"maximum duration for reading an entire request"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout" usage:"maximum duration before timing out response writes"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout" usage:"maximum time to keep idle keep-alive connections open"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" usage:"time allowed for graceful shutdown"`
+	MaxBodyBytes    int64    `yaml:"max_body_bytes" toml:"max_body_bytes" usage:"largest request body accepted, in bytes (0 for no limit)"`
+
+	ReadHeaderTimeout    Duration `yaml:"read_header_timeout" toml:"read_header_timeout" usage:"maximum duration for reading request headers (0 to use read_timeout)"`
+	MaxHeaderBytes       int      `yaml:"max_header_bytes" toml:"max_header_bytes" usage:"largest request header block accepted, in bytes"`
+	KeepAlive            bool     `yaml:"keep_alive" toml:"keep_alive" usage:"keep HTTP/1.1 connections open between requests"`
+	MaxConnections       int      `yaml:"max_connections" toml:"max_connections" usage:"connections served at once (0 for no limit)"`
+	H2C                  bool     `yaml:"h2c" toml:"h2c" usage:"serve HTTP/2 without TLS (h2c) alongside HTTP/1.1"`
+	MaxConcurrentStreams int      `yaml:"max_concurrent_streams" toml:"max_concurrent_streams" usage:"concurrent streams per HTTP/2 connection"`
+	StatusInterval       Duration `yaml:"status_interval" toml:"status_interval" usage:"how often streamed and WebSocket job statuses are checked for changes"`
+	GRPCAddress          string   `yaml:"grpc_address" toml:"grpc_address" usage:"listen address of the gRPC API, e.g. :9090 (empty does not serve it)"`
+}
+
+// StorageConfig configures local storage
+type StorageConfig struct {
+	WorkDir      string `yaml:"work_dir" toml:"work_dir" usage:"working directory for local files"`
+	ScratchDir   string `yaml:"scratch_dir" toml:"scratch_dir" usage:"where workers keep files while jobs run (empty uses scratch inside work_dir)"`
+	MinFreeBytes int64  `yaml:"min_free_bytes" toml:"min_free_bytes" usage:"refuse local writes that would leave fewer bytes free on the disk (0 does not check)"`
+
+	WorkspaceMaxBytes      int64    `yaml:"workspace_max_bytes" toml:"workspace_max_bytes" usage:"disk usage of job workspaces past which workers start no job needing one (0 is unlimited)"`
+	WorkspaceSweepInterval Duration `yaml:"workspace_sweep_interval" toml:"workspace_sweep_interval" usage:"time between removals of workspaces left by jobs that no longer run"`
+}
+
+// S3Config configures access to Amazon S3 or an S3-compatible store, used for
+// s3:// sources and, with an output bucket, for job outputs. Without an access
+// key, credentials are read from the environment, the shared AWS config files
+// or the instance or pod role.
+type S3Config struct {
+	Region            string `yaml:"region" toml:"region" usage:"AWS region of the buckets"`
+	Endpoint          string `yaml:"endpoint" toml:"endpoint" usage:"S3 API endpoint, e.g. for MinIO or LocalStack (empty uses Amazon S3)"`
+	PathStyle         bool   `yaml:"path_style" toml:"path_style" usage:"address buckets as endpoint/bucket, as most S3-compatible stores require"`
+	AccessKeyID       string `yaml:"access_key_id" toml:"access_key_id" usage:"static access key (empty uses the default AWS credential chain)"`
+	SecretAccessKey   string `yaml:"secret_access_key" toml:"secret_access_key" usage:"secret of the static access key"`
+	SessionToken      string `yaml:"session_token" toml:"session_token" usage:"session token of temporary static credentials"`
+	MaxAttempts       int    `yaml:"max_attempts" toml:"max_attempts" usage:"attempts per S3 request, retried with backoff"`
+	PartSize          int64  `yaml:"part_size" toml:"part_size" usage:"uploads larger than this many bytes are sent in parts of this size (at least 5 MiB)"`
+	UploadConcurrency int    `yaml:"upload_concurrency" toml:"upload_concurrency" usage:"parts of one upload sent at once"`
+	OutputBucket      string `yaml:"output_bucket" toml:"output_bucket" usage:"bucket job outputs are written to (empty writes them to storage.work_dir)"`
+	OutputPrefix      string `yaml:"output_prefix" toml:"output_prefix" usage:"key prefix of job outputs in the output bucket"`
+}
+
+// GCSConfig configures access to Google Cloud Storage, used with an output
+// bucket for job outputs in place of local storage or S3. Without a
+// credentials file, GOOGLE_APPLICATION_CREDENTIALS or else the service account
+// of the instance or pod is used.
+type GCSConfig struct {
+	Endpoint        string   `yaml:"endpoint" toml:"endpoint" usage:"JSON API endpoint, e.g. for fake-gcs-server (empty uses Google Cloud Storage)"`
+	CredentialsFile string   `yaml:"credentials_file" toml:"credentials_file" usage:"service account key file (empty uses GOOGLE_APPLICATION_CREDENTIALS or the metadata server)"`
+	MaxAttempts     int      `yaml:"max_attempts" toml:"max_attempts" usage:"attempts per GCS request, retried with backoff"`
+	ChunkSize       int      `yaml:"chunk_size" toml:"chunk_size" usage:"bytes sent per request of an upload, a multiple of 256 KiB"`
+	Timeout         Duration `yaml:"timeout" toml:"timeout" usage:"time allowed for GCS requests other than uploads and downloads"`
+	OutputBucket    string   `yaml:"output_bucket" toml:"output_bucket" usage:"bucket job outputs are written to (empty writes them to storage.work_dir or the S3 output bucket)"`
+	OutputPrefix    string   `yaml:"output_prefix" toml:"output_prefix" usage:"object name prefix of job outputs in the output bucket"`
+}
+
+// RedisConfig configures the Redis connection used by the repositories
+type RedisConfig struct {
+	URL            string   `yaml:"url" toml:"url" usage:"Redis address (host:port)"`
+	Password       string   `yaml:"password" toml:"password" usage:"Redis password"`
+	DB             int      `yaml:"db" toml:"db" usage:"Redis database number"`
+	MaxRetries     int      `yaml:"max_retries" toml:"max_retries" usage:"maximum command retries"`
+	MinIdleConns   int      `yaml:"min_idle_conns" toml:"min_idle_conns" usage:"minimum idle connections"`
+	PoolSize       int      `yaml:"pool_size" toml:"pool_size" usage:"connection pool size"`
+	PoolTimeout    Duration `yaml:"pool_timeout" toml:"pool_timeout" usage:"time to wait for a pooled connection"`
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout" usage:"dial timeout"`
+	ReadTimeout    Duration `yaml:"read_timeout" toml:"read_timeout" usage:"socket read timeout"`
+	WriteTimeout   Duration `yaml:"write_timeout" toml:"write_timeout" usage:"socket write timeout"`
+	JobTTL         Duration `yaml:"job_ttl" toml:"job_ttl" usage:"retention of job and batch records"`
+	ExpiryWarning  Duration `yaml:"expiry_warning" toml:"expiry_warning" usage:"warn in job listings about jobs expiring within this window (0 disables)"`
+}
+
+// RateLimitConfig configures the API rate limiter
+type RateLimitConfig struct {
+	Enabled     bool     `yaml:"enabled" toml:"enabled" usage:"enable per-client rate limiting"`
+	Requests    int      `yaml:"requests" toml:"requests" usage:"requests allowed per time window"`
+	TimeWindow  Duration `yaml:"time_window" toml:"time_window" usage:"rate limit time window"`
+	Backend     string   `yaml:"backend" toml:"backend" usage:"rate limiter backend: memory or redis"`
+	IdleTimeout Duration `yaml:"idle_timeout" toml:"idle_timeout" usage:"forget in-memory clients idle this long"`
+	MaxClients  int      `yaml:"max_clients" toml:"max_clients" usage:"most clients tracked in memory"`
+}
+
+// CORSConfig configures cross-origin resource sharing
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allow_origins" toml:"allow_origins" usage:"comma-separated allowed origins (* for any)"`
+	AllowMethods     []string `yaml:"allow_methods" toml:"allow_methods" usage:"comma-separated allowed methods"`
+	AllowHeaders     []string `yaml:"allow_headers" toml:"allow_headers" usage:"comma-separated allowed request headers"`
+	ExposeHeaders    []string `yaml:"expose_headers" toml:"expose_headers" usage:"comma-separated headers exposed to browsers"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" usage:"allow credentialed requests"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" usage:"how long preflight results may be cached"`
+}
+
+// WorkerConfig configures the encryption workers
+type WorkerConfig struct {
+	Concurrency       int      `yaml:"concurrency" toml:"concurrency" usage:"number of jobs encrypted in parallel"`
+	Queue             string   `yaml:"queue" toml:"queue" usage:"job queue backend: redis or memory"`
+	QueueSize         int      `yaml:"queue_size" toml:"queue_size" usage:"capacity of the in-process job queue"`
+	ProgressInterval  Duration `yaml:"progress_interval" toml:"progress_interval" usage:"minimum time between persisted progress updates"`
+	DrainTimeout      Duration `yaml:"drain_timeout" toml:"drain_timeout" usage:"time in-flight jobs get to finish on shutdown"`
+	Backend           string   `yaml:"backend" toml:"backend" usage:"where jobs are encrypted: local or kubernetes"`
+	JobID             string   `yaml:"job_id" toml:"job_id" usage:"encrypt only this job, then exit (set on launched Kubernetes Jobs)"`
+	Recovery          string   `yaml:"recovery" toml:"recovery" usage:"what startup does with jobs of lost workers: requeue, fail or none"`
+	StaleAfter        Duration `yaml:"stale_after" toml:"stale_after" usage:"time without a heartbeat after which a running job's worker counts as lost"`
+	HeartbeatInterval Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval" usage:"time between the heartbeats workers record for running jobs"`
+	StuckAfter        Duration `yaml:"stuck_after" toml:"stuck_after" usage:"time without a heartbeat or progress after which a running job counts as stuck"`
+	StuckAction       string   `yaml:"stuck_action" toml:"stuck_action" usage:"what the stuck job detector does with stuck jobs: requeue, fail or none"`
+	SweepInterval     Duration `yaml:"sweep_interval" toml:"sweep_interval" usage:"time between sweeps for stuck jobs; 0 disables the detector"`
+	CheckpointBytes   int64    `yaml:"checkpoint_bytes" toml:"checkpoint_bytes" usage:"source bytes between checkpoints of larger jobs, which continue from their last one (0 to disable)"`
+	LeaseTTL          Duration `yaml:"lease_ttl" toml:"lease_ttl" usage:"time a worker's lease on a job lasts unless renewed, so only one worker runs each job"`
+	ScheduleInterval  Duration `yaml:"schedule_interval" toml:"schedule_interval" usage:"time between checks for scheduled jobs whose start has come; 0 disables dispatching them"`
+	RetryMaxAttempts  int      `yaml:"retry_max_attempts" toml:"retry_max_attempts" usage:"runs a job gets before it fails, retrying failures of retry_classes (1 disables automatic retries)"`
+	RetryBackoff      Duration `yaml:"retry_backoff" toml:"retry_backoff" usage:"wait before the first automatic retry of a job, doubled for each further one"`
+	RetryMaxBackoff   Duration `yaml:"retry_max_backoff" toml:"retry_max_backoff" usage:"longest wait between automatic retries (0 for no limit)"`
+	RetryClasses      []string `yaml:"retry_classes" toml:"retry_classes" usage:"failures retried automatically: network, throttled, unavailable, disk_space"`
+}
+
+// HTTPClientConfig configures the connection pool shared by webhook
+// deliveries and http(s) source downloads
+type HTTPClientConfig struct {
+	MaxIdleConns          int      `yaml:"max_idle_conns" toml:"max_idle_conns" usage:"idle connections kept across all hosts"`
+	MaxIdleConnsPerHost   int      `yaml:"max_idle_conns_per_host" toml:"max_idle_conns_per_host" usage:"idle connections kept per host"`
+	MaxConnsPerHost       int      `yaml:"max_conns_per_host" toml:"max_conns_per_host" usage:"connections per host (0 for no limit)"`
+	IdleConnTimeout       Duration `yaml:"idle_conn_timeout" toml:"idle_conn_timeout" usage:"how long idle connections are kept"`
+	DialTimeout           Duration `yaml:"dial_timeout" toml:"dial_timeout" usage:"timeout for establishing a connection"`
+	KeepAlive             Duration `yaml:"keep_alive" toml:"keep_alive" usage:"TCP keep-alive probe interval"`
+	TLSHandshakeTimeout   Duration `yaml:"tls_handshake_timeout" toml:"tls_handshake_timeout" usage:"timeout for TLS handshakes"`
+	ResponseHeaderTimeout Duration `yaml:"response_header_timeout" toml:"response_header_timeout" usage:"time to wait for response headers (0 for no limit)"`
+	WebhookTimeout        Duration `yaml:"webhook_timeout" toml:"webhook_timeout" usage:"time allowed for one webhook delivery"`
+	DownloadTimeout       Duration `yaml:"download_timeout" toml:"download_timeout" usage:"time allowed for one source download"`
+}
+
+// WebhooksConfig configures delivery of job events to webhook endpoints.
+// Events wait in a queue of the worker.queue backend until one of the
+// dispatchers delivers them.
+type WebhooksConfig struct {
+	Endpoints           []string `yaml:"endpoints" toml:"endpoints" usage:"webhook endpoints as \"url secret [event...]\" (no events receives all)"`
+	Registration        bool     `yaml:"registration" toml:"registration" usage:"let tenants register webhooks through /api/v1/webhooks, kept in Redis"`
+	Workers             int      `yaml:"workers" toml:"workers" usage:"concurrent webhook deliveries"`
+	MaxAttempts         int      `yaml:"max_attempts" toml:"max_attempts" usage:"delivery attempts per event and endpoint"`
+	RetryDelay          Duration `yaml:"retry_delay" toml:"retry_delay" usage:"wait before the first retry, doubled for each further one"`
+	MaxRetryDelay       Duration `yaml:"max_retry_delay" toml:"max_retry_delay" usage:"longest wait between retries"`
+	QueueSize           int      `yaml:"queue_size" toml:"queue_size" usage:"capacity of the in-process event queue"`
+	DeadLetters         int      `yaml:"dead_letters" toml:"dead_letters" usage:"failed deliveries kept per webhook"`
+	DeadLetterRetention Duration `yaml:"dead_letter_retention" toml:"dead_letter_retention" usage:"how long failed deliveries are kept after a webhook's last one"`
+}
+
+// WebhookEndpoint is a parsed webhooks.endpoints entry
+type WebhookEndpoint struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+// Parse parses the configured webhook endpoints
+func (c WebhooksConfig) Parse() ([]WebhookEndpoint, error) {
+	endpoints := make([]WebhookEndpoint, 0, len(c.Endpoints))
+	seen := make(map[string]bool, len(c.Endpoints))
+	for i, entry := range c.Endpoints {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("webhooks.endpoints[%d] must be \"url secret [event...]\"", i)
+		}
+		if !strings.HasPrefix(fields[0], "http://") && !strings.HasPrefix(fields[0], "https://") {
+			return nil, fmt.Errorf("webhooks.endpoints[%d] must start with an http or https URL", i)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("webhooks.endpoints[%d] repeats a URL", i)
+		}
+		seen[fields[0]] = true
+		endpoints = append(endpoints, WebhookEndpoint{URL: fields[0], Secret: fields[1], Events: fields[2:]})
+	}
+	return endpoints, nil
+}
+
+// CacheConfig configures caching of expensive read endpoints
+type CacheConfig struct {
+	SummaryTTL Duration `yaml:"summary_ttl" toml:"summary_ttl" usage:"how long GET /jobs/status summaries are cached (0 disables)"`
+}
+
+// ExportConfig configures NDJSON job exports
+type ExportConfig struct {
+	MaxJobs int `yaml:"max_jobs" toml:"max_jobs" usage:"most jobs returned by one GET /jobs/export request"`
+}
+
+// HealthConfig configures the dependency checks that gate job intake
+type HealthConfig struct {
+	CheckInterval   Duration `yaml:"check_interval" toml:"check_interval" usage:"time between dependency health checks"`
+	CheckTimeout    Duration `yaml:"check_timeout" toml:"check_timeout" usage:"timeout for a single dependency check"`
+	DegradedLatency Duration `yaml:"degraded_latency" toml:"degraded_latency" usage:"check latency above which a dependency is reported degraded"`
+}
+
+// AuthConfig configures authentication of the /api/v1 endpoints, by API key,
+// by JWT or both. Keys created through /admin/api-keys are accepted while
+// authentication is enabled.
+type AuthConfig struct {
+	APIKeys []string  `yaml:"api_keys" toml:"api_keys" usage:"API keys as key:owner, key:owner:admin or key:owner:keys (authentication is disabled without keys or JWTs)"`
+	JWT     JWTConfig `yaml:"jwt" toml:"jwt"`
+}
+
+// JWTConfig configures validation of JWTs issued by an OpenID Connect
+// provider, sent as bearer tokens
+type JWTConfig struct {
+	Enabled       bool     `yaml:"enabled" toml:"enabled" usage:"accept JWTs from an OpenID Connect provider"`
+	Issuer        string   `yaml:"issuer" toml:"issuer" usage:"expected iss claim; signing keys are discovered from it"`
+	Audience      string   `yaml:"audience" toml:"audience" usage:"expected aud claim"`
+	JWKSURL       string   `yaml:"jwks_url" toml:"jwks_url" usage:"URL of the provider's signing keys (discovered from the issuer when empty)"`
+	OwnerClaim    string   `yaml:"owner_claim" toml:"owner_claim" usage:"claim naming the owner a token acts for"`
+	TenantClaim   string   `yaml:"tenant_claim" toml:"tenant_claim" usage:"claim naming the owner's tenant (the owner is its own tenant when empty)"`
+	ScopesClaim   string   `yaml:"scopes_claim" toml:"scopes_claim" usage:"claim listing a token's scopes, space-separated or as an array"`
+	DefaultScopes []string `yaml:"default_scopes" toml:"default_scopes" usage:"scopes of tokens that carry none of the service's scopes"`
+	Leeway        Duration `yaml:"leeway" toml:"leeway" usage:"clock skew allowed when checking exp and nbf"`
+	JWKSRefresh   Duration `yaml:"jwks_refresh" toml:"jwks_refresh" usage:"how often the provider's signing keys are refetched"`
+	Timeout       Duration `yaml:"timeout" toml:"timeout" usage:"timeout for requests to the provider"`
+}
+
+// Enabled reports whether callers must authenticate
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || c.JWT.Enabled
+}
+
+// APIKey is a parsed auth.api_keys entry
+type APIKey struct {
+	Key   string
+	Owner string
+	Admin bool
+	Keys  bool // May retrieve decryption keys through the API
+}
+
+// Keys parses the configured API keys
+func (c AuthConfig) Keys() ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(c.APIKeys))
+	seen := make(map[string]bool, len(c.APIKeys))
+	for i, entry := range c.APIKeys {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("auth.api_keys[%d] must be key:owner, key:owner:admin or key:owner:keys", i)
+		}
+		if len(parts) == 3 && parts[2] != "admin" && parts[2] != "keys" {
+			return nil, fmt.Errorf("auth.api_keys[%d] has unknown role %q", i, parts[2])
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("auth.api_keys[%d] repeats a key", i)
+		}
+		seen[parts[0]] = true
+		role := ""
+		if len(parts) == 3 {
+			role = parts[2]
+		}
+		keys = append(keys, APIKey{Key: parts[0], Owner: parts[1], Admin: role == "admin", Keys: role == "keys"})
+	}
+	return keys, nil
+}
+
+// QuotaConfig limits what each tenant may submit. The limits apply to every
+// tenant unless Tenants overrides them; zero leaves a limit unset.
+type QuotaConfig struct {
+	MaxConcurrentJobs int      `yaml:"max_concurrent_jobs" toml:"max_concurrent_jobs" usage:"jobs a tenant may have queued, running or paused at once (0 for no limit)"`
+	MaxJobsPerDay     int      `yaml:"max_jobs_per_day" toml:"max_jobs_per_day" usage:"jobs a tenant may submit per UTC day (0 for no limit)"`
+	MaxBytes          int64    `yaml:"max_bytes" toml:"max_bytes" usage:"output bytes a tenant's retained jobs may hold (0 for no limit)"`
+	Tenants           []string `yaml:"tenants" toml:"tenants" usage:"per-tenant limits as tenant:concurrent:daily:bytes, overriding the defaults"`
+}
+
+// TenantQuota is a parsed quotas.tenants entry
+type TenantQuota struct {
+	MaxConcurrentJobs int
+	MaxJobsPerDay     int
+	MaxBytes          int64
+}
+
+// Enabled reports whether any tenant has a limit
+func (c QuotaConfig) Enabled() bool {
+	return c.MaxConcurrentJobs > 0 || c.MaxJobsPerDay > 0 || c.MaxBytes > 0 || len(c.Tenants) > 0
+}
+
+// ParseTenants parses the per-tenant limits
+func (c QuotaConfig) ParseTenants() (map[string]TenantQuota, error) {
+	quotas := make(map[string]TenantQuota, len(c.Tenants))
+	for i, entry := range c.Tenants {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 4 || parts[0] == "" {
+			return nil, fmt.Errorf("quotas.tenants[%d] must be tenant:concurrent:daily:bytes", i)
+		}
+		if _, ok := quotas[parts[0]]; ok {
+			return nil, fmt.Errorf("quotas.tenants[%d] repeats tenant %q", i, parts[0])
+		}
+		var limits [3]int64
+		for j, part := range parts[1:] {
+			n, err := strconv.ParseInt(part, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("quotas.tenants[%d] has invalid limit %q, expected a non-negative integer", i, part)
+			}
+			limits[j] = n
+		}
+		quotas[parts[0]] = TenantQuota{
+			MaxConcurrentJobs: int(limits[0]),
+			MaxJobsPerDay:     int(limits[1]),
+			MaxBytes:          limits[2],
+		}
+	}
+	return quotas, nil
+}
+
+// ServiceConfig configures integration with the process supervisor
+type ServiceConfig struct {
+	PIDFile string `yaml:"pid_file" toml:"pid_file" usage:"write the process ID to this file while running"`
+}
+
+// MediaConfig configures probing of sources before encryption
+type MediaConfig struct {
+	Probe              bool     `yaml:"probe" toml:"probe" usage:"probe sources with ffprobe before encrypting them"`
+	ProbeOnSubmit      bool     `yaml:"probe_on_submit" toml:"probe_on_submit" usage:"also probe when a job is submitted, rejecting unsupported sources"`
+	FFProbePath        string   `yaml:"ffprobe_path" toml:"ffprobe_path" usage:"ffprobe binary"`
+	ProbeTimeout       Duration `yaml:"probe_timeout" toml:"probe_timeout" usage:"time allowed to probe one source"`
+	AllowedContainers  []string `yaml:"allowed_containers" toml:"allowed_containers" usage:"accepted containers as ffprobe demuxer names (empty accepts any)"`
+	AllowedVideoCodecs []string `yaml:"allowed_video_codecs" toml:"allowed_video_codecs" usage:"accepted video codecs (empty accepts any)"`
+
+	Transcode        bool     `yaml:"transcode" toml:"transcode" usage:"let jobs transcode their source with ffmpeg before it is encrypted"`
+	FFmpegPath       string   `yaml:"ffmpeg_path" toml:"ffmpeg_path" usage:"ffmpeg binary"`
+	TranscodeDir     string   `yaml:"transcode_dir" toml:"transcode_dir" usage:"scratch dir for sources and renditions being transcoded (empty uses storage.scratch_dir)"`
+	TranscodeTimeout Duration `yaml:"transcode_timeout" toml:"transcode_timeout" usage:"time allowed to transcode one source"`
+	VideoCodec       string   `yaml:"video_codec" toml:"video_codec" usage:"ffmpeg video encoder for renditions"`
+	AudioCodec       string   `yaml:"audio_codec" toml:"audio_codec" usage:"ffmpeg audio encoder for renditions"`
+	Preset           string   `yaml:"preset" toml:"preset" usage:"video encoder speed preset (empty uses the encoder's default)"`
+
+	TranscodeProfiles []string `yaml:"transcode_profiles" toml:"transcode_profiles" usage:"named transcodes jobs ask for with transcode_profile, as \"name format [option=value...] rendition...\""`
+
+	Watermark     bool   `yaml:"watermark" toml:"watermark" usage:"let jobs overlay a text or image watermark on their source with ffmpeg before it is transcoded or encrypted"`
+	WatermarkFont string `yaml:"watermark_font" toml:"watermark_font" usage:"font file of text watermarks (empty uses ffmpeg's default)"`
+}
+
+// TranscodeProfile is a parsed media.transcode_profiles entry
+type TranscodeProfile struct {
+	Name           string
+	Format         string
+	VideoCodec     string
+	AudioCodec     string
+	SegmentSeconds int
+	Renditions     []TranscodeRendition
+}
+
+// TranscodeRendition is a rendition of a transcode profile
+type TranscodeRendition struct {
+	Name         string
+	Height       int
+	VideoBitrate int64
+	AudioBitrate int64
+}
+
+// ParseTranscodeProfiles parses the configured transcode profiles. Each is
+// a name, a format (mp4 or hls), optional video_codec=, audio_codec= and
+// segment_seconds= options, and renditions as
+// name[:height[:video_bitrate[:audio_bitrate]]] with bitrates in bits per
+// second, e.g. "web hls 720p:720:3000000:128000 360p:360:800000:96000".
+func (c MediaConfig) ParseTranscodeProfiles() ([]TranscodeProfile, error) {
+	profiles := make([]TranscodeProfile, 0, len(c.TranscodeProfiles))
+	seen := make(map[string]bool, len(c.TranscodeProfiles))
+	for i, entry := range c.TranscodeProfiles {
+		fields := strings.Fields(entry)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("media.transcode_profiles[%d] must be \"name format [option=value...] rendition...\"", i)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("media.transcode_profiles[%d] repeats profile %q", i, fields[0])
+		}
+		seen[fields[0]] = true
+
+		profile := TranscodeProfile{Name: fields[0], Format: fields[1]}
+		for _, field := range fields[2:] {
+			option, value, ok := strings.Cut(field, "=")
+			if !ok {
+				rendition, err := parseTranscodeRendition(field)
+				if err != nil {
+					return nil, fmt.Errorf("media.transcode_profiles[%d]: %w", i, err)
+				}
+				profile.Renditions = append(profile.Renditions, rendition)
+				continue
+			}
+			switch option {
+			case "video_codec":
+				profile.VideoCodec = value
+			case "audio_codec":
+				profile.AudioCodec = value
+			case "segment_seconds":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("media.transcode_profiles[%d] has invalid segment_seconds %q", i, value)
+				}
+				profile.SegmentSeconds = n
+			default:
+				return nil, fmt.Errorf("media.transcode_profiles[%d] has unknown option %q", i, option)
+			}
+		}
+		if len(profile.Renditions) == 0 {
+			return nil, fmt.Errorf("media.transcode_profiles[%d] has no renditions", i)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// parseTranscodeRendition parses name[:height[:video_bitrate[:audio_bitrate]]]
+func parseTranscodeRendition(field string) (TranscodeRendition, error) {
+	parts := strings.Split(field, ":")
+	if len(parts) > 4 || parts[0] == "" {
+		return TranscodeRendition{}, fmt.Errorf("rendition %q must be name[:height[:video_bitrate[:audio_bitrate]]]", field)
+	}
+	rendition := TranscodeRendition{Name: parts[0]}
+	var numbers [3]int64
+	for j, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return TranscodeRendition{}, fmt.Errorf("rendition %q has invalid number %q", field, part)
+		}
+		numbers[j] = n
+	}
+	rendition.Height = int(numbers[0])
+	rendition.VideoBitrate, rendition.AudioBitrate = numbers[1], numbers[2]
+	return rendition, nil
+}
+
+// PreflightConfig configures validation of source URLs before jobs are
+// created for them
+type PreflightConfig struct {
+	Enabled             bool     `yaml:"enabled" toml:"enabled" usage:"validate source URLs when jobs are submitted"`
+	AllowedSchemes      []string `yaml:"allowed_schemes" toml:"allowed_schemes" usage:"source URL schemes jobs may use: https, http, s3 or file (empty accepts any)"`
+	CheckSource         bool     `yaml:"check_source" toml:"check_source" usage:"check that each source exists, with a HEAD request or its S3 metadata"`
+	MaxSize             int64    `yaml:"max_size" toml:"max_size" usage:"largest source in bytes (0 accepts any size)"`
+	AllowedContentTypes []string `yaml:"allowed_content_types" toml:"allowed_content_types" usage:"accepted source content types, or prefixes such as video/ (empty accepts any)"`
+	Timeout             Duration `yaml:"timeout" toml:"timeout" usage:"time allowed to check one source"`
+}
+
+// UploadsConfig configures POST /api/v1/encrypt/upload, which takes sources
+// that are not reachable by URL as multipart uploads, and the tus resumable
+// uploads at /api/v1/uploads
+type UploadsConfig struct {
+	Enabled bool     `yaml:"enabled" toml:"enabled" usage:"accept uploaded sources"`
+	MaxSize int64    `yaml:"max_size" toml:"max_size" usage:"largest upload in bytes"`
+	Prefix  string   `yaml:"prefix" toml:"prefix" usage:"path under the output storage where uploads are kept until their jobs end"`
+	Timeout Duration `yaml:"timeout" toml:"timeout" usage:"time allowed for one upload or chunk, in place of server.read_timeout"`
+
+	Resumable     bool     `yaml:"resumable" toml:"resumable" usage:"accept resumable uploads with the tus protocol"`
+	Expiry        Duration `yaml:"expiry" toml:"expiry" usage:"how long an incomplete resumable upload is kept after its last chunk"`
+	SweepInterval Duration `yaml:"sweep_interval" toml:"sweep_interval" usage:"how often expired resumable uploads are deleted"`
+}
+
+// DestinationConfig lets jobs name where their outputs are written in place
+// of the output storage
+type DestinationConfig struct {
+	Enabled    bool     `yaml:"enabled" toml:"enabled" usage:"let jobs choose a destination for their outputs"`
+	Buckets    []string `yaml:"buckets" toml:"buckets" usage:"S3 buckets jobs may write their outputs to"`
+	Local      bool     `yaml:"local" toml:"local" usage:"let jobs write their outputs to directories inside storage.work_dir"`
+	CheckWrite bool     `yaml:"check_write" toml:"check_write" usage:"write and delete a probe file in each destination when jobs are submitted"`
+	Timeout    Duration `yaml:"timeout" toml:"timeout" usage:"time allowed to check one destination"`
+}
+
+// EngineConfig sets the default encryption parameters and the bounds jobs
+// may override them within
+type EngineConfig struct {
+	Algorithm           string   `yaml:"algorithm" toml:"algorithm" usage:"default cipher: AES-256-GCM, AES-128-GCM or CHACHA20-POLY1305"`
+	ChunkSize           int      `yaml:"chunk_size" toml:"chunk_size" usage:"default plaintext bytes per sealed chunk"`
+	IVStrategy          string   `yaml:"iv_strategy" toml:"iv_strategy" usage:"default nonce strategy: counter or random"`
+	Container           string   `yaml:"container" toml:"container" usage:"default output container: stream or base64"`
+	AllowedAlgorithms   []string `yaml:"allowed_algorithms" toml:"allowed_algorithms" usage:"ciphers jobs may request"`
+	AllowedIVStrategies []string `yaml:"allowed_iv_strategies" toml:"allowed_iv_strategies" usage:"nonce strategies jobs may request"`
+	AllowedContainers   []string `yaml:"allowed_containers" toml:"allowed_containers" usage:"output containers jobs may request"`
+	MinChunkSize        int      `yaml:"min_chunk_size" toml:"min_chunk_size" usage:"smallest chunk size jobs may request"`
+	MaxChunkSize        int      `yaml:"max_chunk_size" toml:"max_chunk_size" usage:"largest chunk size jobs may request"`
+}
+
+// IngestConfig configures automatic job creation for new uploads: from S3
+// event notifications read from an SQS queue, and for files dropped into a
+// local folder. AWS credentials are read from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type IngestConfig struct {
+	SQSQueueURL string   `yaml:"sqs_queue_url" toml:"sqs_queue_url" usage:"SQS queue receiving S3 ObjectCreated notifications (empty disables)"`
+	SQSEndpoint string   `yaml:"sqs_endpoint" toml:"sqs_endpoint" usage:"SQS API endpoint, e.g. for LocalStack (empty uses the queue URL's host)"`
+	SQSRegion   string   `yaml:"sqs_region" toml:"sqs_region" usage:"AWS region of the queue (empty reads it from the queue URL)"`
+	SQSWaitTime Duration `yaml:"sqs_wait_time" toml:"sqs_wait_time" usage:"long poll per SQS receive, at most 20s"`
+	Buckets     []string `yaml:"buckets" toml:"buckets" usage:"buckets to encrypt new uploads of, as bucket or bucket/prefix"`
+	Owner       string   `yaml:"owner" toml:"owner" usage:"created_by recorded on ingested jobs"`
+	DedupeTTL   Duration `yaml:"dedupe_ttl" toml:"dedupe_ttl" usage:"how long an upload is remembered so repeated notifications create one job"`
+	RetryDelay  Duration `yaml:"retry_delay" toml:"retry_delay" usage:"wait before a notification whose job failed to be created is redelivered, doubled for each delivery"`
+
+	WatchDir          string   `yaml:"watch_dir" toml:"watch_dir" usage:"folder inside storage.work_dir whose new files are encrypted (empty disables)"`
+	WatchSettle       Duration `yaml:"watch_settle" toml:"watch_settle" usage:"how long a dropped file must stay unchanged before it is picked up"`
+	WatchPollInterval Duration `yaml:"watch_poll_interval" toml:"watch_poll_interval" usage:"time between checks of dropped files and their jobs"`
+}
+
+// IngestBucket is a parsed ingest.buckets entry
+type IngestBucket struct {
+	Bucket string
+	Prefix string
+}
+
+// ParseBuckets parses the configured ingest buckets
+func (c IngestConfig) ParseBuckets() ([]IngestBucket, error) {
+	buckets := make([]IngestBucket, 0, len(c.Buckets))
+	for i, entry := range c.Buckets {
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(entry), "s3://"), "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("ingest.buckets[%d] must be bucket or bucket/prefix", i)
+		}
+		buckets = append(buckets, IngestBucket{Bucket: bucket, Prefix: prefix})
+	}
+	return buckets, nil
+}
+
+// KubernetesConfig configures the kubernetes worker backend. Each job runs in
+// a Kubernetes Job whose pod starts the service with worker.job_id set; the
+// pod reads its Redis and storage settings from env and env_from like any
+// other instance.
+type KubernetesConfig struct {
+	APIServer        string   `yaml:"api_server" toml:"api_server" usage:"Kubernetes API server URL (empty uses the in-cluster address)"`
+	Namespace        string   `yaml:"namespace" toml:"namespace" usage:"namespace Jobs are created in (empty uses the pod's namespace)"`
+	TokenFile        string   `yaml:"token_file" toml:"token_file" usage:"bearer token file (empty uses the pod's service account)"`
+	CAFile           string   `yaml:"ca_file" toml:"ca_file" usage:"CA bundle of the API server (empty uses the pod's service account)"`
+	Image            string   `yaml:"image" toml:"image" usage:"image of the worker pods"`
+	ImagePullPolicy  string   `yaml:"image_pull_policy" toml:"image_pull_policy" usage:"Always, IfNotPresent or Never (empty uses the cluster default)"`
+	ServiceAccount   string   `yaml:"service_account" toml:"service_account" usage:"service account of the worker pods"`
+	CPURequest       string   `yaml:"cpu_request" toml:"cpu_request" usage:"CPU requested per worker pod, e.g. 500m"`
+	CPULimit         string   `yaml:"cpu_limit" toml:"cpu_limit" usage:"CPU limit per worker pod"`
+	MemoryRequest    string   `yaml:"memory_request" toml:"memory_request" usage:"memory requested per worker pod, e.g. 512Mi"`
+	MemoryLimit      string   `yaml:"memory_limit" toml:"memory_limit" usage:"memory limit per worker pod"`
+	Env              []string `yaml:"env" toml:"env" usage:"environment of the worker pods, as NAME=value"`
+	EnvFrom          []string `yaml:"env_from" toml:"env_from" usage:"secrets and config maps loaded into the worker pods' environment, as secret:name or configmap:name"`
+	StorageClaim     string   `yaml:"storage_claim" toml:"storage_claim" usage:"persistent volume claim mounted at storage.work_dir in the worker pods"`
+	ActiveDeadline   Duration `yaml:"active_deadline" toml:"active_deadline" usage:"longest a worker pod may run (0 for no limit)"`
+	TTLAfterFinished Duration `yaml:"ttl_after_finished" toml:"ttl_after_finished" usage:"how long finished Jobs are kept before Kubernetes deletes them"`
+	PollInterval     Duration `yaml:"poll_interval" toml:"poll_interval" usage:"time between checks of running Jobs"`
+}
+
+// EnvSource is a parsed kubernetes.env_from entry
+type EnvSource struct {
+	Kind string // "secret" or "configmap"
+	Name string
+}
+
+// ParseEnv parses the configured worker pod environment
+func (c KubernetesConfig) ParseEnv() (map[string]string, error) {
+	env := make(map[string]string, len(c.Env))
+	for i, entry := range c.Env {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("kubernetes.env[%d] must be NAME=value", i)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// ParseEnvFrom parses the configured worker pod environment sources
+func (c KubernetesConfig) ParseEnvFrom() ([]EnvSource, error) {
+	sources := make([]EnvSource, 0, len(c.EnvFrom))
+	for i, entry := range c.EnvFrom {
+		kind, name, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		kind = strings.ToLower(kind)
+		if (kind != "secret" && kind != "configmap") || name == "" {
+			return nil, fmt.Errorf("kubernetes.env_from[%d] must be secret:name or configmap:name", i)
+		}
+		sources = append(sources, EnvSource{Kind: kind, Name: name})
+	}
+	return sources, nil
+}
+
+// KeysConfig configures delivery of content keys to players and packagers
+type KeysConfig struct {
+	Enabled        bool     `yaml:"enabled" toml:"enabled" usage:"serve content keys of completed jobs to holders of key tokens"`
+	TokenSecret    string   `yaml:"token_secret" toml:"token_secret" usage:"secret key tokens are signed with (at least 32 characters)"`
+	TokenTTL       Duration `yaml:"token_ttl" toml:"token_ttl" usage:"lifetime of key tokens requested without one"`
+	MaxTokenTTL    Duration `yaml:"max_token_ttl" toml:"max_token_ttl" usage:"longest lifetime a key token may be issued with"`
+	AuditRetention Duration `yaml:"audit_retention" toml:"audit_retention" usage:"how long key audit events are kept"`
+}
+
+// KeyStoreConfig selects where the content keys of jobs are sealed. With kms
+// or vault only the sealed keys are persisted, and they are opened only when a
+// worker decrypts or a key is requested through the API.
+type KeyStoreConfig struct {
+	Backend        string   `yaml:"backend" toml:"backend" usage:"where content keys are kept: inline, kms or vault"`
+	KMSKeyID       string   `yaml:"kms_key_id" toml:"kms_key_id" usage:"KMS key ID, ARN or alias content keys are sealed under"`
+	KMSRegion      string   `yaml:"kms_region" toml:"kms_region" usage:"AWS region of the KMS key (empty uses s3.region)"`
+	KMSEndpoint    string   `yaml:"kms_endpoint" toml:"kms_endpoint" usage:"KMS API endpoint, e.g. for LocalStack (empty uses AWS KMS)"`
+	VaultAddress   string   `yaml:"vault_address" toml:"vault_address" usage:"address of the Vault server, e.g. https://vault:8200"`
+	VaultToken     string   `yaml:"vault_token" toml:"vault_token" usage:"Vault token allowed to encrypt and decrypt with the transit key"`
+	VaultNamespace string   `yaml:"vault_namespace" toml:"vault_namespace" usage:"Vault Enterprise namespace (empty for none)"`
+	VaultMount     string   `yaml:"vault_mount" toml:"vault_mount" usage:"path the transit secrets engine is mounted at"`
+	VaultKey       string   `yaml:"vault_key" toml:"vault_key" usage:"name of the transit key content keys are sealed under"`
+	Timeout        Duration `yaml:"timeout" toml:"timeout" usage:"time allowed for each seal or open request"`
+}
+
+// ShareConfig configures share links to job results
+type ShareConfig struct {
+	Enabled    bool     `yaml:"enabled" toml:"enabled" usage:"let job owners mint expiring share links to outputs and key manifests"`
+	BaseURL    string   `yaml:"base_url" toml:"base_url" usage:"public address of the API that share links point to"`
+	Secret     string   `yaml:"secret" toml:"secret" usage:"secret share links are signed with (at least 32 characters)"`
+	DefaultTTL Duration `yaml:"default_ttl" toml:"default_ttl" usage:"lifetime of share links requested without one"`
+	MaxTTL     Duration `yaml:"max_ttl" toml:"max_ttl" usage:"longest lifetime a share link may be minted with"`
+	PresignTTL Duration `yaml:"presign_ttl" toml:"presign_ttl" usage:"lifetime of presigned storage URLs share links redirect to"`
+}
+
+// RecurringConfig configures batches started on a cron schedule
+type RecurringConfig struct {
+	Interval Duration `yaml:"interval" toml:"interval" usage:"time between checks for recurring batches that are due; 0 disables starting them in this process"`
+}
+
+// ReplicationConfig configures mirroring of job and batch records to a
+// replica Redis in another region
+type ReplicationConfig struct {
+	Enabled       bool     `yaml:"enabled" toml:"enabled" usage:"mirror job and batch records to a replica Redis"`
+	RedisURL      string   `yaml:"redis_url" toml:"redis_url" usage:"replica Redis address (host:port)"`
+	RedisPassword string   `yaml:"redis_password" toml:"redis_password" usage:"replica Redis password"`
+	RedisDB       int      `yaml:"redis_db" toml:"redis_db" usage:"replica Redis database number"`
+	QueueSize     int      `yaml:"queue_size" toml:"queue_size" usage:"writes waiting to be mirrored before new ones are dropped"`
+	MaxRetryDelay Duration `yaml:"max_retry_delay" toml:"max_retry_delay" usage:"longest wait between attempts while the replica is unreachable"`
+	ReadOnly      bool     `yaml:"read_only" toml:"read_only" usage:"serve the API read-only, for failover to a replica"`
+}
+
+// PushgatewayConfig configures pushing the metrics of workers to a Prometheus
+// Pushgateway, for workers that exit before they are scraped
+type PushgatewayConfig struct {
+	URL      string   `yaml:"url" toml:"url" usage:"Pushgateway address workers push their metrics to (empty disables pushing)"`
+	Job      string   `yaml:"job" toml:"job" usage:"job label pushed metrics are grouped under"`
+	Interval Duration `yaml:"interval" toml:"interval" usage:"time between pushes while a worker runs (0 to push only on exit)"`
+	Timeout  Duration `yaml:"timeout" toml:"timeout" usage:"limit of a single push"`
+	Labels   []string `yaml:"labels" toml:"labels" usage:"further grouping labels as name=value"`
+}
+
+// ParseLabels parses the configured grouping labels
+func (c PushgatewayConfig) ParseLabels() (map[string]string, error) {
+	labels := make(map[string]string, len(c.Labels))
+	for i, entry := range c.Labels {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("pushgateway.labels[%d] must be name=value", i)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// ChaosConfig configures fault injection for resilience testing. It must
+// never be enabled in production.
+type ChaosConfig struct {
+	Enabled             bool     `yaml:"enabled" toml:"enabled" usage:"inject faults for resilience testing"`
+	StorageFailureRate  float64  `yaml:"storage_failure_rate" toml:"storage_failure_rate" usage:"probability (0-1) that a storage or source operation fails"`
+	RedisTimeoutRate    float64  `yaml:"redis_timeout_rate" toml:"redis_timeout_rate" usage:"probability (0-1) that a Redis operation times out"`
+	RedisTimeoutDelay   Duration `yaml:"redis_timeout_delay" toml:"redis_timeout_delay" usage:"how long an injected Redis timeout blocks"`
+	SlowEncryptionRate  float64  `yaml:"slow_encryption_rate" toml:"slow_encryption_rate" usage:"probability (0-1) that an encryption is slowed down"`
+	SlowEncryptionDelay Duration `yaml:"slow_encryption_delay" toml:"slow_encryption_delay" usage:"maximum delay added to a slow encryption"`
+	Seed                int64    `yaml:"seed" toml:"seed" usage:"random seed for fault injection (0 for a random seed)"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Mode: ModeAll,
+		Server: ServerConfig{
+			Port:            8080,
+			ReadTimeout:     Duration{15 * time.Second},
+			WriteTimeout:    Duration{15 * time.Second},
+			IdleTimeout:     Duration{60 * time.Second},
+			ShutdownTimeout: Duration{5 * time.Second},
+			MaxBodyBytes:    4 << 20,
+
+			ReadHeaderTimeout:    Duration{5 * time.Second},
+			MaxHeaderBytes:       1 << 20,
+			KeepAlive:            true,
+			MaxConcurrentStreams: 250,
+			StatusInterval:       Duration{time.Second},
+		},
+		Storage: StorageConfig{
+			WorkDir:                "./tmp/storage",
+			MinFreeBytes:           100 << 20,
+			WorkspaceSweepInterval: Duration{10 * time.Minute},
+		},
+		S3: S3Config{
+			Region:            "us-east-1",
+			MaxAttempts:       5,
+			PartSize:          16 << 20,
+			UploadConcurrency: 4,
+		},
+		GCS: GCSConfig{
+			MaxAttempts: 5,
+			ChunkSize:   16 << 20,
+			Timeout:     Duration{30 * time.Second},
+		},
+		Redis: RedisConfig{
+			URL:            "localhost:6379",
+			DB:             0,
+			MaxRetries:     3,
+			MinIdleConns:   10,
+			PoolSize:       100,
+			PoolTimeout:    Duration{30 * time.Second},
+			ConnectTimeout: Duration{5 * time.Second},
+			ReadTimeout:    Duration{3 * time.Second},
+			WriteTimeout:   Duration{3 * time.Second},
+			JobTTL:         Duration{24 * time.Hour},
+			ExpiryWarning:  Duration{time.Hour},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     true,
+			Requests:    100,
+			TimeWindow:  Duration{time.Minute},
+			Backend:     RateLimitMemory,
+			IdleTimeout: Duration{10 * time.Minute},
+			MaxClients:  100000,
+		},
+		CORS: CORSConfig{
+			AllowOrigins:     []string{"*"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
+			ExposeHeaders:    []string{"Content-Length"},
+			AllowCredentials: true,
+			MaxAge:           Duration{12 * time.Hour},
+		},
+		Auth: AuthConfig{
+			JWT: JWTConfig{
+				OwnerClaim:    "sub",
+				ScopesClaim:   "scope",
+				DefaultScopes: []string{"jobs:read", "jobs:write"},
+				Leeway:        Duration{time.Minute},
+				JWKSRefresh:   Duration{time.Hour},
+				Timeout:       Duration{10 * time.Second},
+			},
+		},
+		Worker: WorkerConfig{
+			Concurrency:       4,
+			Queue:             QueueRedis,
+			QueueSize:         1000,
+			ProgressInterval:  Duration{time.Second},
+			DrainTimeout:      Duration{30 * time.Second},
+			Backend:           WorkerLocal,
+			Recovery:          RecoveryRequeue,
+			StaleAfter:        Duration{15 * time.Minute},
+			HeartbeatInterval: Duration{30 * time.Second},
+			StuckAfter:        Duration{10 * time.Minute},
+			StuckAction:       RecoveryNone,
+			SweepInterval:     Duration{time.Minute},
+			LeaseTTL:          Duration{30 * time.Second},
+			ScheduleInterval:  Duration{10 * time.Second},
+			RetryMaxAttempts:  1,
+			RetryBackoff:      Duration{30 * time.Second},
+			RetryMaxBackoff:   Duration{10 * time.Minute},
+			RetryClasses:      []string{"network", "throttled", "unavailable"},
+		},
+		HTTPClient: HTTPClientConfig{
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   32,
+			IdleConnTimeout:       Duration{90 * time.Second},
+			DialTimeout:           Duration{10 * time.Second},
+			KeepAlive:             Duration{30 * time.Second},
+			TLSHandshakeTimeout:   Duration{10 * time.Second},
+			ResponseHeaderTimeout: Duration{30 * time.Second},
+			WebhookTimeout:        Duration{10 * time.Second},
+			DownloadTimeout:       Duration{30 * time.Minute},
+		},
+		Webhooks: WebhooksConfig{
+			Workers:             4,
+			MaxAttempts:         5,
+			RetryDelay:          Duration{5 * time.Second},
+			MaxRetryDelay:       Duration{10 * time.Minute},
+			QueueSize:           10000,
+			DeadLetters:         1000,
+			DeadLetterRetention: Duration{7 * 24 * time.Hour},
+		},
+		Cache: CacheConfig{
+			SummaryTTL: Duration{5 * time.Second},
+		},
+		Export: ExportConfig{
+			MaxJobs: 10000,
+		},
+		Health: HealthConfig{
+			CheckInterval:   Duration{5 * time.Second},
+			CheckTimeout:    Duration{2 * time.Second},
+			DegradedLatency: Duration{500 * time.Millisecond},
+		},
+		Media: MediaConfig{
+			FFProbePath:        "ffprobe",
+			ProbeTimeout:       Duration{30 * time.Second},
+			AllowedContainers:  []string{"mov", "mp4", "matroska", "webm", "mpegts"},
+			AllowedVideoCodecs: []string{"h264", "hevc", "vp9", "av1"},
+			FFmpegPath:         "ffmpeg",
+			TranscodeTimeout:   Duration{2 * time.Hour},
+			VideoCodec:         "libx264",
+			AudioCodec:         "aac",
+			Preset:             "veryfast",
+		},
+		Preflight: PreflightConfig{
+			AllowedSchemes:      []string{"https", "s3"},
+			CheckSource:         true,
+			AllowedContentTypes: []string{"video/", "audio/", "application/octet-stream", "binary/octet-stream"},
+			Timeout:             Duration{10 * time.Second},
+		},
+		Uploads: UploadsConfig{
+			MaxSize: 5 << 30,
+			Prefix:  "uploads",
+			Timeout: Duration{time.Hour},
+
+			Expiry:        Duration{24 * time.Hour},
+			SweepInterval: Duration{10 * time.Minute},
+		},
+		Destination: DestinationConfig{
+			CheckWrite: true,
+			Timeout:    Duration{10 * time.Second},
+		},
+		Engine: EngineConfig{
+			Algorithm:           "AES-256-GCM",
+			ChunkSize:           1 << 20,
+			IVStrategy:          "counter",
+			Container:           "stream",
+			AllowedAlgorithms:   []string{"AES-256-GCM", "AES-128-GCM", "CHACHA20-POLY1305"},
+			AllowedIVStrategies: []string{"counter", "random"},
+			AllowedContainers:   []string{"stream", "base64"},
+			MinChunkSize:        64 << 10,
+			MaxChunkSize:        16 << 20,
+		},
+		Ingest: IngestConfig{
+			SQSWaitTime: Duration{20 * time.Second},
+			Owner:       "ingest",
+			DedupeTTL:   Duration{7 * 24 * time.Hour},
+			RetryDelay:  Duration{30 * time.Second},
+
+			WatchSettle:       Duration{5 * time.Second},
+			WatchPollInterval: Duration{2 * time.Second},
+		},
+		Kubernetes: KubernetesConfig{
+			TTLAfterFinished: Duration{time.Hour},
+			PollInterval:     Duration{5 * time.Second},
+		},
+		KeyStore: KeyStoreConfig{
+			Backend:    KeyStoreInline,
+			VaultMount: "transit",
+			Timeout:    Duration{10 * time.Second},
+		},
+		Keys: KeysConfig{
+			TokenTTL:       Duration{5 * time.Minute},
+			MaxTokenTTL:    Duration{24 * time.Hour},
+			AuditRetention: Duration{90 * 24 * time.Hour},
+		},
+		Share: ShareConfig{
+			DefaultTTL: Duration{24 * time.Hour},
+			MaxTTL:     Duration{7 * 24 * time.Hour},
+			PresignTTL: Duration{5 * time.Minute},
+		},
+		Recurring: RecurringConfig{
+			Interval: Duration{30 * time.Second},
+		},
+		Replication: ReplicationConfig{
+			QueueSize:     10000,
+			MaxRetryDelay: Duration{30 * time.Second},
+		},
+		Pushgateway: PushgatewayConfig{
+			Job:     "encryption_service",
+			Timeout: Duration{10 * time.Second},
+		},
+		Chaos: ChaosConfig{
+			RedisTimeoutDelay:   Duration{3 * time.Second},
+			SlowEncryptionDelay: Duration{10 * time.Second},
+		},
+	}
+}
+
+// Validate checks the configuration for values the service cannot run with
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Mode {
+	case ModeAPI, ModeWorker, ModeAll:
+	default:
+		errs = append(errs, fmt.Errorf("mode must be api, worker or all, got %q", c.Mode))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.ReadTimeout.Duration < 0 || c.Server.WriteTimeout.Duration < 0 || c.Server.IdleTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server timeouts must not be negative"))
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("server.max_body_bytes must not be negative"))
+	}
+	if c.Server.ReadHeaderTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server.read_header_timeout must not be negative"))
+	}
+	if c.Server.MaxHeaderBytes < 1 {
+		errs = append(errs, errors.New("server.max_header_bytes must be at least 1"))
+	}
+	if c.Server.MaxConnections < 0 {
+		errs = append(errs, errors.New("server.max_connections must not be negative"))
+	}
+	if c.Server.MaxConcurrentStreams < 1 || c.Server.MaxConcurrentStreams > math.MaxUint32 {
+		errs = append(errs, fmt.Errorf("server.max_concurrent_streams must be between 1 and %d", uint32(math.MaxUint32)))
+	}
+	if c.Server.StatusInterval.Duration <= 0 {
+		errs = append(errs, errors.New("server.status_interval must be positive"))
+	}
+	if c.Server.GRPCAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Server.GRPCAddress); err != nil {
+			errs = append(errs, fmt.Errorf("server.grpc_address must be host:port or :port, got %q", c.Server.GRPCAddress))
+		}
+	}
+
+	if c.Storage.WorkDir == "" {
+		errs = append(errs, errors.New("storage.work_dir is required"))
+	}
+	if c.Storage.MinFreeBytes < 0 {
+		errs = append(errs, errors.New("storage.min_free_bytes must not be negative"))
+	}
+	if c.Storage.WorkspaceMaxBytes < 0 {
+		errs = append(errs, errors.New("storage.workspace_max_bytes must not be negative"))
+	}
+	if c.Storage.WorkspaceSweepInterval.Duration <= 0 {
+		errs = append(errs, errors.New("storage.workspace_sweep_interval must be positive"))
+	}
+
+	if c.S3.Region == "" {
+		errs = append(errs, errors.New("s3.region is required"))
+	}
+	if c.S3.AccessKeyID != "" && c.S3.SecretAccessKey == "" {
+		errs = append(errs, errors.New("s3.secret_access_key is required when s3.access_key_id is set"))
+	}
+	if c.S3.MaxAttempts < 1 {
+		errs = append(errs, errors.New("s3.max_attempts must be at least 1"))
+	}
+	if c.S3.PartSize < 5<<20 {
+		errs = append(errs, errors.New("s3.part_size must be at least 5 MiB (5242880)"))
+	}
+	if c.S3.UploadConcurrency < 1 {
+		errs = append(errs, errors.New("s3.upload_concurrency must be at least 1"))
+	}
+
+	if c.GCS.OutputBucket != "" && c.S3.OutputBucket != "" {
+		errs = append(errs, errors.New("gcs.output_bucket and s3.output_bucket cannot both be set"))
+	}
+	if c.GCS.MaxAttempts < 1 {
+		errs = append(errs, errors.New("gcs.max_attempts must be at least 1"))
+	}
+	if c.GCS.ChunkSize <= 0 || c.GCS.ChunkSize%(256<<10) != 0 {
+		errs = append(errs, errors.New("gcs.chunk_size must be a positive multiple of 256 KiB (262144)"))
+	}
+	if c.GCS.Timeout.Duration <= 0 {
+		errs = append(errs, errors.New("gcs.timeout must be positive"))
+	}
+
+	if c.Redis.URL == "" {
+		errs = append(errs, errors.New("redis.url is required"))
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, errors.New("redis.db must not be negative"))
+	}
+	if c.Redis.PoolSize <= 0 {
+		errs = append(errs, errors.New("redis.pool_size must be positive"))
+	}
+	if c.Redis.JobTTL.Duration <= 0 {
+		errs = append(errs, errors.New("redis.job_ttl must be positive"))
+	}
+	if c.Redis.ExpiryWarning.Duration < 0 {
+		errs = append(errs, errors.New("redis.expiry_warning must not be negative"))
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Requests <= 0 {
+			errs = append(errs, errors.New("rate_limit.requests must be positive when rate limiting is enabled"))
+		}
+		if c.RateLimit.TimeWindow.Duration <= 0 {
+			errs = append(errs, errors.New("rate_limit.time_window must be positive when rate limiting is enabled"))
+		}
+		switch c.RateLimit.Backend {
+		case RateLimitMemory:
+			if c.RateLimit.IdleTimeout.Duration <= 0 {
+				errs = append(errs, errors.New("rate_limit.idle_timeout must be positive"))
+			}
+			if c.RateLimit.MaxClients <= 0 {
+				errs = append(errs, errors.New("rate_limit.max_clients must be positive"))
+			}
+		case RateLimitRedis:
+		default:
+			errs = append(errs, fmt.Errorf("rate_limit.backend must be memory or redis, got %q", c.RateLimit.Backend))
+		}
+	}
+
+	if len(c.CORS.AllowOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allow_origins must list at least one origin"))
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" && len(c.CORS.AllowOrigins) > 1 {
+			errs = append(errs, errors.New("cors.allow_origins must not mix * with explicit origins"))
+			break
+		}
+	}
+
+	if _, err := c.Auth.Keys(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Auth.JWT.Enabled {
+		if u, err := url.Parse(c.Auth.JWT.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("auth.jwt.issuer %q must be an absolute http(s) URL", c.Auth.JWT.Issuer))
+		}
+		if c.Auth.JWT.Audience == "" {
+			errs = append(errs, errors.New("auth.jwt.audience is required"))
+		}
+		if c.Auth.JWT.JWKSURL != "" {
+			if u, err := url.Parse(c.Auth.JWT.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("auth.jwt.jwks_url %q must be an absolute http(s) URL", c.Auth.JWT.JWKSURL))
+			}
+		}
+		if c.Auth.JWT.OwnerClaim == "" || c.Auth.JWT.ScopesClaim == "" {
+			errs = append(errs, errors.New("auth.jwt.owner_claim and auth.jwt.scopes_claim are required"))
+		}
+		if c.Auth.JWT.Leeway.Duration < 0 {
+			errs = append(errs, errors.New("auth.jwt.leeway must not be negative"))
+		}
+		if c.Auth.JWT.JWKSRefresh.Duration <= 0 || c.Auth.JWT.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("auth.jwt.jwks_refresh and auth.jwt.timeout must be positive"))
+		}
+	}
+
+	if c.Worker.Concurrency <= 0 {
+		errs = append(errs, errors.New("worker.concurrency must be positive"))
+	}
+	switch c.Worker.Queue {
+	case QueueRedis:
+	case QueueMemory:
+		if c.Mode != ModeAll {
+			errs = append(errs, fmt.Errorf("worker.queue %q only works in mode all; use %q to share jobs between processes", QueueMemory, QueueRedis))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("worker.queue must be redis or memory, got %q", c.Worker.Queue))
+	}
+	if c.Worker.QueueSize <= 0 {
+		errs = append(errs, errors.New("worker.queue_size must be positive"))
+	}
+	if c.Worker.DrainTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("worker.drain_timeout must be positive"))
+	}
+	switch c.Worker.Recovery {
+	case RecoveryRequeue, RecoveryFail, RecoveryNone:
+	default:
+		errs = append(errs, fmt.Errorf("worker.recovery must be requeue, fail or none, got %q", c.Worker.Recovery))
+	}
+	if c.Worker.StaleAfter.Duration <= c.Worker.ProgressInterval.Duration {
+		errs = append(errs, errors.New("worker.stale_after must be longer than worker.progress_interval"))
+	}
+	if c.Worker.CheckpointBytes < 0 {
+		errs = append(errs, errors.New("worker.checkpoint_bytes must not be negative"))
+	}
+	if c.Worker.HeartbeatInterval.Duration <= 0 {
+		errs = append(errs, errors.New("worker.heartbeat_interval must be positive"))
+	}
+	if c.Worker.LeaseTTL.Duration <= 0 {
+		errs = append(errs, errors.New("worker.lease_ttl must be positive"))
+	}
+	if c.Worker.ScheduleInterval.Duration < 0 {
+		errs = append(errs, errors.New("worker.schedule_interval must not be negative"))
+	}
+	if c.Worker.RetryMaxAttempts < 1 {
+		errs = append(errs, errors.New("worker.retry_max_attempts must be at least 1"))
+	}
+	if c.Worker.RetryMaxAttempts > 1 && c.Worker.RetryBackoff.Duration <= 0 {
+		errs = append(errs, errors.New("worker.retry_backoff must be positive when jobs are retried"))
+	}
+	// Retried jobs wait SCHEDULED for the dispatcher, so without it they never
+	// run again. API-only processes neither retry jobs nor dispatch them.
+	if c.Mode != ModeAPI && c.Worker.RetryMaxAttempts > 1 && c.Worker.ScheduleInterval.Duration == 0 {
+		errs = append(errs, errors.New("worker.schedule_interval must be positive when jobs are retried"))
+	}
+	if c.Worker.RetryMaxBackoff.Duration < 0 {
+		errs = append(errs, errors.New("worker.retry_max_backoff must not be negative"))
+	}
+	for _, class := range c.Worker.RetryClasses {
+		if !slices.Contains([]string{"network", "throttled", "unavailable", "disk_space"}, class) {
+			errs = append(errs, fmt.Errorf("worker.retry_classes: unknown class %q", class))
+		}
+	}
+	switch c.Worker.StuckAction {
+	case RecoveryRequeue, RecoveryFail, RecoveryNone:
+	default:
+		errs = append(errs, fmt.Errorf("worker.stuck_action must be requeue, fail or none, got %q", c.Worker.StuckAction))
+	}
+	if c.Worker.SweepInterval.Duration < 0 {
+		errs = append(errs, errors.New("worker.sweep_interval must not be negative"))
+	}
+	if c.Worker.SweepInterval.Duration > 0 && (c.Worker.StuckAfter.Duration <= c.Worker.HeartbeatInterval.Duration ||
+		c.Worker.StuckAfter.Duration <= c.Worker.ProgressInterval.Duration) {
+		errs = append(errs, errors.New("worker.stuck_after must be longer than worker.heartbeat_interval and worker.progress_interval"))
+	}
+	switch c.Worker.Backend {
+	case WorkerLocal:
+	case WorkerKubernetes:
+		if c.Worker.Queue != QueueRedis {
+			errs = append(errs, fmt.Errorf("worker.backend %q needs worker.queue %q", WorkerKubernetes, QueueRedis))
+		}
+		if c.Kubernetes.Image == "" {
+			errs = append(errs, fmt.Errorf("kubernetes.image is required when worker.backend is %q", WorkerKubernetes))
+		}
+		if _, err := c.Kubernetes.ParseEnv(); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := c.Kubernetes.ParseEnvFrom(); err != nil {
+			errs = append(errs, err)
+		}
+		if c.Kubernetes.ActiveDeadline.Duration < 0 || c.Kubernetes.TTLAfterFinished.Duration < 0 || c.Kubernetes.PollInterval.Duration <= 0 {
+			errs = append(errs, errors.New("kubernetes.active_deadline and kubernetes.ttl_after_finished must not be negative, and kubernetes.poll_interval must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("worker.backend must be local or kubernetes, got %q", c.Worker.Backend))
+	}
+	if c.Worker.JobID != "" && c.Mode != ModeWorker {
+		errs = append(errs, fmt.Errorf("worker.job_id only works in mode %q", ModeWorker))
+	}
+
+	if c.HTTPClient.MaxIdleConns < 0 || c.HTTPClient.MaxIdleConnsPerHost <= 0 || c.HTTPClient.MaxConnsPerHost < 0 {
+		errs = append(errs, errors.New("http_client.max_idle_conns_per_host must be positive and the other connection limits not negative"))
+	}
+	if c.HTTPClient.IdleConnTimeout.Duration < 0 || c.HTTPClient.DialTimeout.Duration < 0 || c.HTTPClient.KeepAlive.Duration < 0 ||
+		c.HTTPClient.TLSHandshakeTimeout.Duration < 0 || c.HTTPClient.ResponseHeaderTimeout.Duration < 0 {
+		errs = append(errs, errors.New("http_client timeouts must not be negative"))
+	}
+	if c.HTTPClient.WebhookTimeout.Duration <= 0 || c.HTTPClient.DownloadTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("http_client.webhook_timeout and http_client.download_timeout must be positive"))
+	}
+
+	if _, err := c.Webhooks.Parse(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Webhooks.Workers <= 0 || c.Webhooks.MaxAttempts <= 0 || c.Webhooks.QueueSize <= 0 || c.Webhooks.DeadLetters <= 0 {
+		errs = append(errs, errors.New("webhooks.workers, webhooks.max_attempts, webhooks.queue_size and webhooks.dead_letters must be positive"))
+	}
+	if c.Webhooks.RetryDelay.Duration < 0 {
+		errs = append(errs, errors.New("webhooks.retry_delay must not be negative"))
+	}
+	if c.Webhooks.MaxRetryDelay.Duration < c.Webhooks.RetryDelay.Duration {
+		errs = append(errs, errors.New("webhooks.max_retry_delay must be at least webhooks.retry_delay"))
+	}
+	if c.Webhooks.DeadLetterRetention.Duration <= 0 {
+		errs = append(errs, errors.New("webhooks.dead_letter_retention must be positive"))
+	}
+
+	if c.Cache.SummaryTTL.Duration < 0 {
+		errs = append(errs, errors.New("cache.summary_ttl must not be negative"))
+	}
+
+	if c.Export.MaxJobs <= 0 {
+		errs = append(errs, errors.New("export.max_jobs must be positive"))
+	}
+
+	if c.Health.CheckInterval.Duration <= 0 {
+		errs = append(errs, errors.New("health.check_interval must be positive"))
+	}
+	if c.Health.CheckTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("health.check_timeout must be positive"))
+	}
+	if c.Health.DegradedLatency.Duration < 0 {
+		errs = append(errs, errors.New("health.degraded_latency must not be negative"))
+	}
+
+	if c.Media.Probe {
+		if c.Media.FFProbePath == "" {
+			errs = append(errs, errors.New("media.ffprobe_path is required when probing is enabled"))
+		}
+		if c.Media.ProbeTimeout.Duration <= 0 {
+			errs = append(errs, errors.New("media.probe_timeout must be positive when probing is enabled"))
+		}
+	} else if c.Media.ProbeOnSubmit {
+		errs = append(errs, errors.New("media.probe_on_submit requires media.probe"))
+	}
+	if c.Media.Transcode {
+		if c.Media.FFmpegPath == "" || c.Media.VideoCodec == "" || c.Media.AudioCodec == "" {
+			errs = append(errs, errors.New("media.ffmpeg_path, media.video_codec and media.audio_codec are required when transcoding is enabled"))
+		}
+		if c.Media.TranscodeTimeout.Duration <= 0 {
+			errs = append(errs, errors.New("media.transcode_timeout must be positive when transcoding is enabled"))
+		}
+	}
+	if _, err := c.Media.ParseTranscodeProfiles(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Media.Watermark {
+		if c.Media.FFmpegPath == "" || c.Media.VideoCodec == "" {
+			errs = append(errs, errors.New("media.ffmpeg_path and media.video_codec are required when watermarking is enabled"))
+		}
+		if c.Media.TranscodeTimeout.Duration <= 0 {
+			errs = append(errs, errors.New("media.transcode_timeout must be positive when watermarking is enabled"))
+		}
+	} else if c.Media.WatermarkFont != "" {
+		errs = append(errs, errors.New("media.watermark_font requires media.watermark"))
+	}
+
+	if c.Preflight.Enabled {
+		for _, scheme := range c.Preflight.AllowedSchemes {
+			if !containsFold([]string{"https", "http", "s3", "file"}, scheme) {
+				errs = append(errs, fmt.Errorf("preflight.allowed_schemes: unsupported scheme %q", scheme))
+			}
+		}
+		// Ingested jobs are submitted like any other, so their sources must pass
+		schemes := c.Preflight.AllowedSchemes
+		if len(schemes) > 0 && c.Ingest.SQSQueueURL != "" && !containsFold(schemes, "s3") {
+			errs = append(errs, errors.New("preflight.allowed_schemes must include s3 when ingest.sqs_queue_url is set"))
+		}
+		if len(schemes) > 0 && c.Ingest.WatchDir != "" && !containsFold(schemes, "file") {
+			errs = append(errs, errors.New("preflight.allowed_schemes must include file when ingest.watch_dir is set"))
+		}
+		if c.Preflight.MaxSize < 0 {
+			errs = append(errs, errors.New("preflight.max_size must not be negative"))
+		}
+		if c.Preflight.CheckSource && c.Preflight.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("preflight.timeout must be positive when preflight.check_source is set"))
+		}
+	}
+
+	if c.Uploads.Enabled {
+		if c.Uploads.MaxSize <= 0 || c.Uploads.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("uploads.max_size and uploads.timeout must be positive when uploads are enabled"))
+		}
+		if prefix := path.Clean(c.Uploads.Prefix); c.Uploads.Prefix == "" || path.IsAbs(prefix) || prefix == "." || strings.HasPrefix(prefix, "..") {
+			errs = append(errs, fmt.Errorf("uploads.prefix %q must be a relative path inside the output storage", c.Uploads.Prefix))
+		}
+	}
+	if c.Destination.Enabled {
+		if len(c.Destination.Buckets) == 0 && !c.Destination.Local {
+			errs = append(errs, errors.New("destination.buckets or destination.local is required when destinations are enabled"))
+		}
+		for _, bucket := range c.Destination.Buckets {
+			if bucket == "" || strings.Contains(bucket, "/") {
+				errs = append(errs, fmt.Errorf("destination.buckets entry %q must be a bucket name", bucket))
+			}
+		}
+		if c.Destination.CheckWrite && c.Destination.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("destination.timeout must be positive when destination.check_write is set"))
+		}
+	}
+	if c.Uploads.Resumable {
+		if !c.Uploads.Enabled {
+			errs = append(errs, errors.New("uploads.resumable requires uploads.enabled"))
+		}
+		if c.Uploads.Expiry.Duration <= 0 || c.Uploads.SweepInterval.Duration <= 0 {
+			errs = append(errs, errors.New("uploads.expiry and uploads.sweep_interval must be positive when resumable uploads are enabled"))
+		}
+	}
+
+	if c.Engine.MinChunkSize <= 0 || c.Engine.MinChunkSize > c.Engine.MaxChunkSize {
+		errs = append(errs, fmt.Errorf("engine.min_chunk_size must be positive and at most engine.max_chunk_size, got %d", c.Engine.MinChunkSize))
+	} else if c.Engine.ChunkSize < c.Engine.MinChunkSize || c.Engine.ChunkSize > c.Engine.MaxChunkSize {
+		errs = append(errs, fmt.Errorf("engine.chunk_size must be between engine.min_chunk_size and engine.max_chunk_size, got %d", c.Engine.ChunkSize))
+	}
+	if !containsFold(c.Engine.AllowedAlgorithms, c.Engine.Algorithm) {
+		errs = append(errs, fmt.Errorf("engine.algorithm %q must be one of engine.allowed_algorithms", c.Engine.Algorithm))
+	}
+	if !containsFold(c.Engine.AllowedIVStrategies, c.Engine.IVStrategy) {
+		errs = append(errs, fmt.Errorf("engine.iv_strategy %q must be one of engine.allowed_iv_strategies", c.Engine.IVStrategy))
+	}
+	if !containsFold(c.Engine.AllowedContainers, c.Engine.Container) {
+		errs = append(errs, fmt.Errorf("engine.container %q must be one of engine.allowed_containers", c.Engine.Container))
+	}
+
+	if c.Ingest.SQSQueueURL != "" {
+		if buckets, err := c.Ingest.ParseBuckets(); err != nil {
+			errs = append(errs, err)
+		} else if len(buckets) == 0 {
+			errs = append(errs, errors.New("ingest.buckets is required when ingest.sqs_queue_url is set"))
+		}
+		if c.Ingest.SQSWaitTime.Duration < 0 || c.Ingest.SQSWaitTime.Duration > 20*time.Second {
+			errs = append(errs, errors.New("ingest.sqs_wait_time must be between 0 and 20s"))
+		}
+		if c.Ingest.DedupeTTL.Duration <= 0 || c.Ingest.RetryDelay.Duration <= 0 {
+			errs = append(errs, errors.New("ingest.dedupe_ttl and ingest.retry_delay must be positive"))
+		}
+	}
+	if c.Ingest.WatchDir != "" {
+		// Workers read dropped files as local sources, which must lie inside
+		// the storage root
+		if !insideDir(c.Storage.WorkDir, c.Ingest.WatchDir) {
+			errs = append(errs, fmt.Errorf("ingest.watch_dir %q must be inside storage.work_dir", c.Ingest.WatchDir))
+		}
+		if c.Ingest.WatchSettle.Duration < 0 || c.Ingest.WatchPollInterval.Duration <= 0 || c.Ingest.RetryDelay.Duration <= 0 {
+			errs = append(errs, errors.New("ingest.watch_settle must not be negative, and ingest.watch_poll_interval and ingest.retry_delay must be positive"))
+		}
+	}
+
+	if c.Keys.Enabled {
+		if len(c.Keys.TokenSecret) < 32 {
+			errs = append(errs, errors.New("keys.token_secret must be at least 32 characters when key delivery is enabled"))
+		}
+		if c.Keys.TokenTTL.Duration <= 0 || c.Keys.MaxTokenTTL.Duration < c.Keys.TokenTTL.Duration {
+			errs = append(errs, errors.New("keys.token_ttl must be positive and no longer than keys.max_token_ttl"))
+		}
+	}
+	if c.Keys.AuditRetention.Duration <= 0 {
+		errs = append(errs, errors.New("keys.audit_retention must be positive"))
+	}
+
+	switch c.KeyStore.Backend {
+	case KeyStoreInline:
+	case KeyStoreKMS:
+		if c.KeyStore.KMSKeyID == "" {
+			errs = append(errs, errors.New("key_store.kms_key_id is required with the kms backend"))
+		}
+	case KeyStoreVault:
+		if u, err := url.Parse(c.KeyStore.VaultAddress); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("key_store.vault_address %q must be an absolute http(s) URL", c.KeyStore.VaultAddress))
+		}
+		if c.KeyStore.VaultToken == "" || c.KeyStore.VaultKey == "" {
+			errs = append(errs, errors.New("key_store.vault_token and key_store.vault_key are required with the vault backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("key_store.backend must be inline, kms or vault, got %q", c.KeyStore.Backend))
+	}
+	if c.KeyStore.Backend != KeyStoreInline && c.KeyStore.Timeout.Duration <= 0 {
+		errs = append(errs, errors.New("key_store.timeout must be positive"))
+	}
+
+	if c.Share.Enabled {
+		if u, err := url.Parse(c.Share.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("share.base_url %q must be an absolute http(s) URL", c.Share.BaseURL))
+		}
+		if len(c.Share.Secret) < 32 {
+			errs = append(errs, errors.New("share.secret must be at least 32 characters when share links are enabled"))
+		}
+		if c.Share.DefaultTTL.Duration <= 0 || c.Share.MaxTTL.Duration < c.Share.DefaultTTL.Duration || c.Share.PresignTTL.Duration <= 0 {
+			errs = append(errs, errors.New("share.default_ttl and share.presign_ttl must be positive, and share.default_ttl no longer than share.max_ttl"))
+		}
+	}
+
+	if c.Recurring.Interval.Duration < 0 {
+		errs = append(errs, errors.New("recurring.interval must not be negative"))
+	}
+
+	if c.Replication.Enabled {
+		if c.Replication.RedisURL == "" {
+			errs = append(errs, errors.New("replication.redis_url is required when replication is enabled"))
+		} else if c.Replication.RedisURL == c.Redis.URL && c.Replication.RedisDB == c.Redis.DB {
+			errs = append(errs, errors.New("replication.redis_url must not be the primary Redis database"))
+		}
+		if c.Replication.QueueSize <= 0 || c.Replication.MaxRetryDelay.Duration <= 0 {
+			errs = append(errs, errors.New("replication.queue_size and replication.max_retry_delay must be positive"))
+		}
+	}
+	if c.Replication.ReadOnly {
+		// A read-only instance serves the replica; nothing may write to it
+		if c.Mode != ModeAPI {
+			errs = append(errs, errors.New("replication.read_only requires mode api"))
+		}
+		if c.Replication.Enabled || c.Ingest.SQSQueueURL != "" || c.Ingest.WatchDir != "" {
+			errs = append(errs, errors.New("replication.read_only cannot be combined with replication or ingestion"))
+		}
+	}
+
+	if c.Quotas.MaxConcurrentJobs < 0 || c.Quotas.MaxJobsPerDay < 0 || c.Quotas.MaxBytes < 0 {
+		errs = append(errs, errors.New("quotas limits must not be negative"))
+	}
+	if _, err := c.Quotas.ParseTenants(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Pushgateway.URL != "" {
+		if u, err := url.Parse(c.Pushgateway.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("pushgateway.url %q must be an absolute http(s) URL", c.Pushgateway.URL))
+		}
+		if c.Pushgateway.Job == "" {
+			errs = append(errs, errors.New("pushgateway.job is required when pushing metrics"))
+		}
+		if c.Pushgateway.Interval.Duration < 0 || c.Pushgateway.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("pushgateway.interval must not be negative and pushgateway.timeout must be positive"))
+		}
+		if _, err := c.Pushgateway.ParseLabels(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Chaos.Enabled {
+		rates := []struct {
+			key  string
+			rate float64
+		}{
+			{"chaos.storage_failure_rate", c.Chaos.StorageFailureRate},
+			{"chaos.redis_timeout_rate", c.Chaos.RedisTimeoutRate},
+			{"chaos.slow_encryption_rate", c.Chaos.SlowEncryptionRate},
+		}
+		for _, r := range rates {
+			if r.rate < 0 || r.rate > 1 {
+				errs = append(errs, fmt.Errorf("%s must be between 0 and 1, got %g", r.key, r.rate))
+			}
+		}
+		if c.Chaos.RedisTimeoutDelay.Duration < 0 || c.Chaos.SlowEncryptionDelay.Duration < 0 {
+			errs = append(errs, errors.New("chaos delays must not be negative"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Duration is a time.Duration that reads and writes as a Go duration string
+// ("15s", "1m30s") in config files, environment variables and flags
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", string(text), err)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// insideDir reports whether dir lies below root
+func insideDir(root, dir string) bool {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
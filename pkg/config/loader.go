@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix prefixes every environment variable override, e.g. EE_SERVER_PORT
+	EnvPrefix = "EE_"
+
+	// EnvConfigFile names the config file when the -config flag is not given
+	EnvConfigFile = EnvPrefix + "CONFIG_FILE"
+)
+
+// legacyEnv maps settings to environment variables that predate the EE_ prefix
+var legacyEnv = map[string]string{
+	"redis.url": "REDIS_URL",
+}
+
+// setting is a single leaf value of the Config struct
+type setting struct {
+	key   string // dotted key, e.g. "rate_limit.time_window"
+	usage string
+	value reflect.Value
+}
+
+// EnvName returns the environment variable that overrides the setting
+func (s setting) EnvName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+// lookupEnv returns the setting's environment override, falling back to its
+// legacy variable name
+func (s setting) lookupEnv() (string, bool) {
+	if v, ok := os.LookupEnv(s.EnvName()); ok {
+		return v, true
+	}
+	if legacy, ok := legacyEnv[s.key]; ok {
+		return os.LookupEnv(legacy)
+	}
+	return "", false
+}
+
+// FlagName returns the command-line flag that overrides the setting
+func (s setting) FlagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// Load builds the configuration from defaults, an optional config file,
+// environment variables and the given command-line arguments, then validates it
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+	settings := collectSettings(reflect.ValueOf(&cfg).Elem(), "")
+
+	// Flags are parsed first so -config is known, but applied last
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvConfigFile), "path to a YAML or TOML config file")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		flagName := s.FlagName()
+		record := func(v string) error {
+			flagValues[flagName] = v
+			return nil
+		}
+		// Boolean settings may be given as a bare -flag
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(flagName, s.usage, record)
+		} else {
+			fs.Func(flagName, s.usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := s.lookupEnv(); ok {
+			if err := setValue(s.value, v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.EnvName(), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := flagValues[s.FlagName()]; ok {
+			if err := setValue(s.value, v); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.FlagName(), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file over cfg, chosen by file extension
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed to parse YAML config %s: %w", path, err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed to parse TOML config %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (use .yaml, .yml or .toml)", ext)
+	}
+
+	return nil
+}
+
+// collectSettings walks the config struct and returns all of its leaf values,
+// keyed by their YAML names
+func collectSettings(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct && !isTextValue(fv) {
+			settings = append(settings, collectSettings(fv, key)...)
+			continue
+		}
+
+		settings = append(settings, setting{
+			key:   key,
+			usage: field.Tag.Get("usage"),
+			value: fv,
+		})
+	}
+
+	return settings
+}
+
+func isTextValue(v reflect.Value) bool {
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setValue parses raw into the setting's value according to its type
+func setValue(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+
+	return nil
+}
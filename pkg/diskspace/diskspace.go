@@ -0,0 +1,60 @@
+// Package diskspace reports the free space of the filesystems files are
+// written to, so writes can be refused before they fill a disk
+package diskspace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrLow is returned when fewer bytes are free than are to be kept free
+var ErrLow = errors.New("disk space is low")
+
+// checkInterval is how many bytes Writer lets through between checks
+const checkInterval = 64 << 20
+
+// Check returns an error wrapping ErrLow if fewer than min bytes are free on
+// the filesystem holding dir. Platforms that cannot tell always pass.
+func Check(dir string, min int64) error {
+	if min <= 0 {
+		return nil
+	}
+	free, err := Free(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check free space of %s: %w", dir, err)
+	}
+	if free < uint64(min) {
+		return fmt.Errorf("%w: %d bytes free in %s, %d must stay free", ErrLow, free, dir, min)
+	}
+	return nil
+}
+
+// Writer checks the free space of dir every 64 MiB written through it,
+// failing the write that finds it below min, so a long stream stops before it
+// fills the disk
+type Writer struct {
+	w         io.Writer
+	dir       string
+	min       int64
+	unchecked int64
+}
+
+func NewWriter(w io.Writer, dir string, min int64) *Writer {
+	return &Writer{w: w, dir: dir, min: min}
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.min > 0 && w.unchecked >= checkInterval {
+		if err := Check(w.dir, w.min); err != nil {
+			return 0, err
+		}
+		w.unchecked = 0
+	}
+	n, err := w.w.Write(b)
+	w.unchecked += int64(n)
+	return n, err
+}
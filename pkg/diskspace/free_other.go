@@ -0,0 +1,10 @@
+//go:build !unix
+
+package diskspace
+
+import "errors"
+
+// Free is not supported on this platform
+func Free(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
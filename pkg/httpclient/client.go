@@ -0,0 +1,109 @@
+// Package httpclient builds the outbound HTTP clients used for webhooks and
+// source downloads. They share one tuned, pooled transport so bursts of
+// deliveries and large batches reuse connections instead of dialing anew.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"E.E/pkg/metrics"
+)
+
+// Config tunes the shared connection pool
+type Config struct {
+	MaxIdleConns          int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost   int           // Idle connections kept per host
+	MaxConnsPerHost       int           // Connections per host, 0 for no limit
+	IdleConnTimeout       time.Duration // How long an idle connection is kept
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration // TCP keep-alive probe interval
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // Time to wait for response headers, 0 for no limit
+}
+
+// DefaultConfig returns the pool settings used when none are configured
+func DefaultConfig() Config {
+	return Config{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           10 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+}
+
+// Pool is a transport shared by every client created from it
+type Pool struct {
+	transport *http.Transport
+	metrics   *metrics.Metrics
+}
+
+// NewPool creates a connection pool. metrics may be nil.
+func NewPool(config Config, metrics *metrics.Metrics) *Pool {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+
+	return &Pool{
+		transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          config.MaxIdleConns,
+			MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       config.MaxConnsPerHost,
+			IdleConnTimeout:       config.IdleConnTimeout,
+			TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+			ExpectContinueTimeout: time.Second,
+		},
+		metrics: metrics,
+	}
+}
+
+// Client returns a client on the shared pool whose requests, including
+// reading the body, must finish within timeout (0 for no limit). name labels
+// the client's pool metrics.
+func (p *Pool) Client(name string, timeout time.Duration) *http.Client {
+	var transport http.RoundTripper = p.transport
+	if p.metrics != nil {
+		transport = &instrumentedTransport{name: name, next: p.transport, metrics: p.metrics}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// CloseIdleConnections closes the pool's idle connections
+func (p *Pool) CloseIdleConnections() {
+	p.transport.CloseIdleConnections()
+}
+
+// instrumentedTransport counts requests in flight and whether each one got a
+// new or a reused connection
+type instrumentedTransport struct {
+	name    string
+	next    http.RoundTripper
+	metrics *metrics.Metrics
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.metrics.RecordHTTPClientConn(t.name, info.Reused)
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	t.metrics.HTTPClientInFlight.WithLabelValues(t.name).Inc()
+	defer t.metrics.HTTPClientInFlight.WithLabelValues(t.name).Dec()
+
+	return t.next.RoundTrip(req)
+}
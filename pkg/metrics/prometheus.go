@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -15,6 +17,41 @@ type Metrics struct {
 	EncryptionJobsTotal    *prometheus.CounterVec
 	EncryptionJobsDuration *prometheus.HistogramVec
 	ActiveEncryptionJobs   prometheus.Gauge
+	StuckJobsTotal         *prometheus.CounterVec
+	StuckJobs              prometheus.Gauge
+	JobRetriesTotal        *prometheus.CounterVec
+
+	// Batch metrics
+	BatchJobsTotal *prometheus.CounterVec
+	BatchDuration  *prometheus.HistogramVec
+
+	// Dependency health metrics
+	DependencyUp               *prometheus.GaugeVec
+	DependencyTransitionsTotal *prometheus.CounterVec
+
+	// Outbound HTTP client pool metrics
+	HTTPClientConnsTotal *prometheus.CounterVec
+	HTTPClientInFlight   *prometheus.GaugeVec
+
+	// Fault injection metrics
+	ChaosFaultsTotal *prometheus.CounterVec
+
+	// Automatic job creation metrics
+	IngestEventsTotal *prometheus.CounterVec
+
+	// Key delivery metrics
+	KeyDeliveriesTotal *prometheus.CounterVec
+
+	// Cross-region replication metrics
+	ReplicationWritesTotal *prometheus.CounterVec
+	ReplicationLag         prometheus.Gauge
+	ReplicationQueueDepth  prometheus.Gauge
+
+	// Output copy metrics
+	OutputCopiesTotal *prometheus.CounterVec
+
+	// Job workspace metrics
+	WorkspaceBytes prometheus.Gauge
 }
 
 // NewMetrics creates and registers all application metrics
@@ -69,12 +106,171 @@ func NewMetrics(namespace string) *Metrics {
 		},
 	)
 
+	m.JobRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "encryption_job_retries_total",
+			Help:      "Total number of failed job runs retried automatically, by failure class",
+		},
+		[]string{"class"},
+	)
+
+	m.StuckJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "encryption_jobs_stuck_total",
+			Help:      "Total number of running jobs detected as stuck, by reason and the action taken",
+		},
+		[]string{"reason", "action"},
+	)
+
+	m.StuckJobs = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "encryption_jobs_stuck",
+			Help:      "Number of running jobs found stuck by the latest sweep",
+		},
+	)
+
+	// Batch metrics
+	m.BatchJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "batch_jobs_total",
+			Help:      "Total number of jobs acted on by batch operations, by action and outcome",
+		},
+		[]string{"action", "outcome"},
+	)
+
+	m.BatchDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_duration_seconds",
+			Help:      "Duration of batch operations in seconds",
+			Buckets:   []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"action"},
+	)
+
+	// Dependency health metrics
+	m.DependencyUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "dependency_up",
+			Help:      "Whether a dependency passed its last health check (1) or not (0)",
+		},
+		[]string{"dependency"},
+	)
+
+	m.DependencyTransitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dependency_transitions_total",
+			Help:      "Total number of dependency health transitions",
+		},
+		[]string{"dependency", "state"},
+	)
+
+	// Outbound HTTP client pool metrics
+	m.HTTPClientConnsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_client_connections_total",
+			Help:      "Total number of connections used by outbound HTTP requests, new or reused from the pool",
+		},
+		[]string{"client", "state"},
+	)
+
+	m.HTTPClientInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_client_requests_in_flight",
+			Help:      "Number of outbound HTTP requests awaiting a response",
+		},
+		[]string{"client"},
+	)
+
+	// Fault injection metrics
+	m.ChaosFaultsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chaos_faults_injected_total",
+			Help:      "Total number of faults injected by chaos mode",
+		},
+		[]string{"fault"},
+	)
+
+	// Automatic job creation metrics
+	m.IngestEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingest_events_total",
+			Help:      "Total number of new object events handled, by outcome",
+		},
+		[]string{"source", "outcome"},
+	)
+
+	// Key delivery metrics
+	m.KeyDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "key_deliveries_total",
+			Help:      "Total number of content key requests, by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	// Output copy metrics
+	m.OutputCopiesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "output_copies_total",
+			Help:      "Total number of job output copies to copy_to destinations, by storage backend and outcome",
+		},
+		[]string{"storage", "outcome"},
+	)
+
+	// Job workspace metrics
+	m.WorkspaceBytes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "job_workspace_bytes",
+			Help:      "Disk usage of the job workspaces kept by the latest sweep",
+		},
+	)
+
+	// Cross-region replication metrics
+	m.ReplicationWritesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "replication_writes_total",
+			Help:      "Total number of records mirrored to the replica, by record kind and outcome",
+		},
+		[]string{"kind", "outcome"},
+	)
+
+	m.ReplicationLag = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "replication_lag_seconds",
+			Help:      "Time between a write and its mirroring to the replica, as of the latest mirrored write",
+		},
+	)
+
+	m.ReplicationQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "replication_queue_depth",
+			Help:      "Number of writes waiting to be mirrored to the replica",
+		},
+	)
+
 	return m
 }
 
 // RecordHTTPRequest records metrics for an HTTP request
 func (m *Metrics) RecordHTTPRequest(method, path string, status int) {
-	m.HTTPRequestsTotal.WithLabelValues(method, path, string(rune(status))).Inc()
+	m.HTTPRequestsTotal.WithLabelValues(method, path, strconv.Itoa(status)).Inc()
 }
 
 // ObserveHTTPRequestDuration records the duration of an HTTP request
@@ -105,4 +301,95 @@ func (m *Metrics) IncrementActiveEncryptionJobs() {
 // DecrementActiveEncryptionJobs decrements the active jobs counter
 func (m *Metrics) DecrementActiveEncryptionJobs() {
 	m.ActiveEncryptionJobs.Dec()
-}
\ No newline at end of file
+}
+
+// RecordStuckJob records a running job detected as stuck and what was done
+// about it
+func (m *Metrics) RecordStuckJob(reason, action string) {
+	m.StuckJobsTotal.WithLabelValues(reason, action).Inc()
+}
+
+// RecordJobRetry records a failed job run retried automatically
+func (m *Metrics) RecordJobRetry(class string) {
+	m.JobRetriesTotal.WithLabelValues(class).Inc()
+}
+
+// SetStuckJobs records the number of running jobs found stuck
+func (m *Metrics) SetStuckJobs(count int) {
+	m.StuckJobs.Set(float64(count))
+}
+
+// RecordBatch records a batch operation and how many of its jobs succeeded
+// and failed
+func (m *Metrics) RecordBatch(action string, successful, failed int, duration float64) {
+	m.BatchJobsTotal.WithLabelValues(action, "success").Add(float64(successful))
+	m.BatchJobsTotal.WithLabelValues(action, "failure").Add(float64(failed))
+	m.BatchDuration.WithLabelValues(action).Observe(duration)
+}
+
+// SetDependencyUp records the latest health check result of a dependency
+func (m *Metrics) SetDependencyUp(dependency string, up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	m.DependencyUp.WithLabelValues(dependency).Set(value)
+}
+
+// RecordDependencyTransition records a dependency changing health state
+func (m *Metrics) RecordDependencyTransition(dependency, state string) {
+	m.DependencyTransitionsTotal.WithLabelValues(dependency, state).Inc()
+}
+
+// RecordHTTPClientConn records whether an outbound request reused a pooled
+// connection or dialed a new one
+func (m *Metrics) RecordHTTPClientConn(client string, reused bool) {
+	state := "new"
+	if reused {
+		state = "reused"
+	}
+	m.HTTPClientConnsTotal.WithLabelValues(client, state).Inc()
+}
+
+// RecordChaosFault records a fault injected by chaos mode
+func (m *Metrics) RecordChaosFault(fault string) {
+	m.ChaosFaultsTotal.WithLabelValues(fault).Inc()
+}
+
+// RecordIngestEvent records how a new object event was handled
+func (m *Metrics) RecordIngestEvent(source, outcome string) {
+	m.IngestEventsTotal.WithLabelValues(source, outcome).Inc()
+}
+
+// RecordKeyDelivery records the outcome of a content key request
+func (m *Metrics) RecordKeyDelivery(outcome string) {
+	m.KeyDeliveriesTotal.WithLabelValues(outcome).Inc()
+}
+
+// RecordOutputCopy records the outcome of copying a job's outputs to one of
+// its destinations
+func (m *Metrics) RecordOutputCopy(storage, outcome string) {
+	m.OutputCopiesTotal.WithLabelValues(storage, outcome).Inc()
+}
+
+// SetWorkspaceBytes records the disk usage of the job workspaces kept by the
+// latest sweep
+func (m *Metrics) SetWorkspaceBytes(bytes int64) {
+	m.WorkspaceBytes.Set(float64(bytes))
+}
+
+// RecordReplicationWrite records the outcome of mirroring a record to the
+// replica
+func (m *Metrics) RecordReplicationWrite(kind, outcome string) {
+	m.ReplicationWritesTotal.WithLabelValues(kind, outcome).Inc()
+}
+
+// SetReplicationLag records how long the latest mirrored write waited
+func (m *Metrics) SetReplicationLag(seconds float64) {
+	m.ReplicationLag.Set(seconds)
+}
+
+// SetReplicationQueueDepth records the writes waiting to be mirrored
+func (m *Metrics) SetReplicationQueueDepth(depth int) {
+	m.ReplicationQueueDepth.Set(float64(depth))
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushConfig configures a Pusher
+type PushConfig struct {
+	URL      string            // Pushgateway address
+	Job      string            // Value of the job grouping label
+	Grouping map[string]string // Further grouping labels, e.g. instance
+	Interval time.Duration     // Time between pushes while running (0 to push only on Stop)
+	Timeout  time.Duration     // Limit of a single push
+}
+
+// Pusher pushes the metrics of a short-lived process to a Prometheus
+// Pushgateway, so they outlive the process. Each push replaces the metrics of
+// the process's group.
+type Pusher struct {
+	pusher  *push.Pusher
+	config  PushConfig
+	onError func(error)
+
+	stop     chan struct{}
+	done     chan struct{} // Closed when periodic pushes have stopped; nil if never started
+	stopOnce sync.Once
+}
+
+// NewPusher returns a Pusher for the metrics registered by NewMetrics and the
+// Go runtime. onError is called with the errors of periodic pushes and may be
+// nil.
+func NewPusher(config PushConfig, onError func(error)) *Pusher {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	pusher := push.New(config.URL, config.Job).Gatherer(prometheus.DefaultGatherer)
+	for name, value := range config.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	return &Pusher{
+		pusher:  pusher,
+		config:  config,
+		onError: onError,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Push pushes the current metrics once
+func (p *Pusher) Push(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	return p.pusher.PushContext(ctx)
+}
+
+// Start pushes the metrics every interval until Stop is called. It does
+// nothing without an interval.
+func (p *Pusher) Start() {
+	if p.config.Interval <= 0 {
+		return
+	}
+
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if err := p.Push(context.Background()); err != nil {
+					p.onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops periodic pushes and pushes the final metrics. It may be called
+// without Start, e.g. by a process that handles a single job.
+func (p *Pusher) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	if p.done != nil {
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return p.Push(ctx)
+}
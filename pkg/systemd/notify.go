@@ -0,0 +1,72 @@
+// Package systemd implements the parts of the systemd service protocol the
+// service needs without linking libsystemd: readiness and stopping
+// notifications, watchdog pings and PID files.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states understood by systemd
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the service manager over $NOTIFY_SOCKET. It reports
+// false without error when the process is not run by systemd with
+// Type=notify.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading @ denotes a socket in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to send %q: %w", state, err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout configured with WatchdogSec=,
+// or zero when the watchdog is not enabled for this process
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+
+	// WATCHDOG_PID is set when the watchdog is meant for a specific process
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		n, err := strconv.Atoi(pid)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q: %w", pid, err)
+		}
+		if n != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}